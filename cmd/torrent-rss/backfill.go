@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"torrent-rss/internal/config"
+)
+
+// defaultBackfillPages is how many pages `backfill` reads without --pages
+const defaultBackfillPages = 10
+
+// runBackfill handles `torrent-rss backfill [--feed name] [--pages n]`,
+// walking back through a feed's older pages to grab a back catalog
+func runBackfill(args []string) int {
+	flags := flag.NewFlagSet("backfill", flag.ExitOnError)
+	name := flags.String("feed", "", "feed to backfill, may be left out when there's only one")
+	pages := flags.Int("pages", defaultBackfillPages, "most pages to read, newest first")
+	flags.Parse(args)
+	if *pages < 1 {
+		fmt.Println("usage: torrent-rss backfill [--feed name] [--pages n], with n at least 1")
+		return 2
+	}
+
+	cfg := loadConfig()
+	var feed config.Feed
+	switch {
+	case *name != "":
+		var ok bool
+		if feed, ok = cfg.Feed(*name); !ok {
+			log.Fatalf("%s💀 Unknown feed %q 💀%s", colorNeonRed, *name, colorReset)
+		}
+	case len(cfg.Feeds) == 1:
+		feed = cfg.Feeds[0]
+	default:
+		log.Fatalf("%s💀 Pick the feed to backfill with --feed 💀%s", colorNeonRed, colorReset)
+	}
+	if feed.Torznab == nil && feed.PageParam == "" {
+		fmt.Printf("%s⚠️  %s has no page_param, so only its current items are read%s\n", colorNeonYellow, feed.Name, colorReset)
+	}
+
+	// Interrupting stops between items, the next poll finishes the page
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	a := newApp(cfg)
+	defer a.Close()
+
+	fmt.Printf("%s⏪ Backfilling %s%s%s, up to %d page(s)...%s\n\n", colorNeonBlue, colorNeonPink, feed.Name, colorNeonBlue, *pages, colorReset)
+	read, err := a.pipe.Backfill(ctx, feed, *pages, func(page, items int) {
+		fmt.Printf("%s📄 Page %d: %d item(s)%s\n", colorGray, page, items, colorReset)
+	})
+	a.notifier.Flush(context.Background())
+
+	exitCode := 0
+	if err != nil {
+		fmt.Printf("%s💀 %v 💀%s\n", colorNeonRed, err, colorReset)
+		exitCode = 1
+	}
+	if jsonOutput {
+		printJSON(map[string]any{"feed": feed.Name, "pages": read})
+		return exitCode
+	}
+	fmt.Printf("\n%s⚡️Backfilled %s%d%s page(s) of %s ⚡️%s\n", colorNeonYellow, colorNeonBlue, read, colorNeonYellow, feed.Name, colorReset)
+	return exitCode
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+)
+
+// command is a torrent-rss subcommand
+type command struct {
+	name    string
+	usage   string // Arguments after the name
+	summary string
+	run     func(args []string) int
+}
+
+// commands is set in init because help lists them
+var commands []command
+
+func init() {
+	commands = []command{
+		{"run", "[--feed name]", "Poll every feed once and exit (the default)", runOnce},
+		{"daemon", "", "Poll every feed on its interval until stopped", runDaemon},
+		{"grab", "[--feed name] <url>", "Download a single torrent page, .torrent URL or magnet link", runGrab},
+		{"test-feed", "[--feed name] [url]", "Fetch a feed and list its items without downloading anything", runTestFeed},
+		{"search", "[--feed name] <query>|--watchlist", "Search a feed's tracker, or grab what it has for the watch-list", runSearch},
+		{"missing", "[--search] [--feed name]", "List the episodes skipped over per show, or search for them", runMissing},
+		{"backfill", "[--feed name] [--pages n]", "Walk back through a feed's older pages to grab a back catalog", runBackfill},
+		{"retry-failed", "[--feed name]", "Retry failed downloads now, including those given up on", runRetryFailed},
+		{"history", "<list|episodes|failed|purge|import>", "Show, prune or seed what was downloaded", func(args []string) int {
+			runHistory(loadConfig(), args)
+			return 0
+		}},
+		{"feeds", "<export|import> [file]", "Export the feeds to OPML, or turn an OPML file into feeds", runFeeds},
+		{"tui", "[--addr host:port] [--key key]", "Browse feed items, grab them by hand, see history and live daemon events", runTUI},
+		{"stats", "", "Show what each feed's polls found, grabbed and missed", runStats},
+		{"pending", "<list|approve|reject> [id]", "Decide on releases held for approval", runPending},
+		{"watchlist", "<add|remove|list> [title]", "Edit the shows and movies to follow", func(args []string) int {
+			runWatchlist(loadConfig(), args)
+			return 0
+		}},
+		{"service", "<install|uninstall|start|stop>", "Run the daemon as a Windows or macOS background service", runService},
+		{"secrets", "<encrypt|decrypt> [value]", "Encrypt credentials for the config file with the master key", runSecrets},
+		{"config", "validate [path]", "Check a config file and list what it will do", func(args []string) int {
+			runConfig(args)
+			return 0
+		}},
+		{"help", "", "Show this help", func([]string) int {
+			printUsage()
+			return 0
+		}},
+	}
+}
+
+func lookupCommand(name string) (command, bool) {
+	switch name {
+	case "-h", "-help", "--help":
+		name = "help"
+	}
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+func printUsage() {
+	fmt.Printf("%susage: torrent-rss <command> [flags]%s\n\n", colorNeonBlue, colorReset)
+	for _, cmd := range commands {
+		fmt.Printf("  %s%-10s%s %-28s %s%s%s\n", colorNeonPink, cmd.name, colorReset, cmd.usage, colorGray, cmd.summary, colorReset)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"torrent-rss/internal/config"
+)
+
+// runConfig handles `torrent-rss config validate [path]`
+func runConfig(args []string) {
+	if len(args) == 0 || args[0] != "validate" {
+		fmt.Println("usage: torrent-rss config validate [path]")
+		os.Exit(2)
+	}
+
+	path := os.Getenv("TD_CONFIG")
+	if len(args) > 1 {
+		path = args[1]
+	}
+	if path == "" {
+		fmt.Printf("%s💀 No config file given and TD_CONFIG is not set 💀%s\n", colorNeonRed, colorReset)
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load(path)
+	if jsonOutput {
+		printValidationJSON(path, cfg, err)
+	}
+	if err != nil {
+		fmt.Printf("%s💀 %v%s\n", colorNeonRed, err, colorReset)
+		os.Exit(1)
+	}
+
+	fmt.Printf("%s✅ %s is valid%s\n", colorNeonGreen, path, colorReset)
+	fmt.Printf("%s   %d tracker(s), %d client(s), %d delivery target(s), %d notifier(s), %d feed(s)%s\n",
+		colorGray, len(cfg.Trackers), len(cfg.Clients), len(cfg.Deliveries), len(cfg.Notifiers), len(cfg.Feeds), colorReset)
+	for _, feed := range cfg.Feeds {
+		fmt.Printf("%s   • %s%s%s via %s → %s %s%s\n", colorGray, colorNeonPink, feed.Name, colorGray, feed.Tracker, cfg.Destination(feed), feed.Polling(), colorReset)
+	}
+	if cfg.NameTemplate != nil {
+		example := "Show.Name.2024.S01E02.1080p.NF.WEB-DL.DDP5.1.H.264-GROUP"
+		fmt.Printf("%s   Torrents are named like %s%s.torrent%s\n", colorGray, colorNeonPink, cfg.NameTemplate.Name(example), colorReset)
+	}
+}
+
+// validationJSON is what `config validate --output json` prints
+type validationJSON struct {
+	Path       string           `json:"path"`
+	Valid      bool             `json:"valid"`
+	Error      string           `json:"error,omitempty"`
+	Trackers   int              `json:"trackers"`
+	Clients    int              `json:"clients"`
+	Deliveries int              `json:"deliveries"`
+	Notifiers  int              `json:"notifiers"`
+	Feeds      []validationFeed `json:"feeds"`
+}
+
+type validationFeed struct {
+	Name        string `json:"name"`
+	Tracker     string `json:"tracker"`
+	Destination string `json:"destination"`
+	Interval    string `json:"interval"`
+	Schedule    string `json:"schedule,omitempty"`
+}
+
+func printValidationJSON(path string, cfg *config.Config, err error) {
+	out := validationJSON{Path: path, Valid: err == nil, Feeds: []validationFeed{}}
+	if err != nil {
+		out.Error = err.Error()
+		printJSON(out)
+		return
+	}
+	out.Trackers, out.Clients, out.Deliveries, out.Notifiers = len(cfg.Trackers), len(cfg.Clients), len(cfg.Deliveries), len(cfg.Notifiers)
+	for _, feed := range cfg.Feeds {
+		out.Feeds = append(out.Feeds, validationFeed{
+			Name:        feed.Name,
+			Tracker:     feed.Tracker,
+			Destination: cfg.Destination(feed),
+			Interval:    feed.Interval.String(),
+			Schedule:    feed.Schedule.String(),
+		})
+	}
+	printJSON(out)
+}
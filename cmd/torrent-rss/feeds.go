@@ -0,0 +1,209 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"torrent-rss/internal/config"
+	"torrent-rss/internal/opml"
+
+	"gopkg.in/yaml.v3"
+)
+
+// runFeeds handles `torrent-rss feeds <export|import>`, moving feeds between
+// the config and RSS readers or other tools through OPML
+func runFeeds(args []string) int {
+	if len(args) == 0 {
+		fmt.Println("usage: torrent-rss feeds <export|import> [flags]")
+		return 2
+	}
+
+	switch args[0] {
+	case "export":
+		return exportFeeds(args[1:])
+	case "import":
+		return importFeeds(args[1:])
+	default:
+		fmt.Printf("unknown feeds command %q\n", args[0])
+		return 2
+	}
+}
+
+// exportFeeds writes the configured feeds as OPML, to a file or stdout.
+// Feed URLs often carry a passkey, so the file is only readable by its owner.
+func exportFeeds(args []string) int {
+	flags := flag.NewFlagSet("feeds export", flag.ExitOnError)
+	flags.Parse(args)
+
+	cfg := loadConfig()
+	feeds := make([]opml.Feed, 0, len(cfg.Feeds))
+	for _, feed := range cfg.Feeds {
+		feeds = append(feeds, opml.Feed{Title: feed.Name, URL: feed.URL})
+	}
+
+	if flags.NArg() == 0 {
+		if jsonOutput {
+			printJSON(feeds)
+			return 0
+		}
+		if err := opml.Write(results, "torrent-rss feeds", feeds); err != nil {
+			log.Fatalf("%s💀 %v 💀%s", colorNeonRed, err, colorReset)
+		}
+		return 0
+	}
+
+	path := flags.Arg(0)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		log.Fatalf("%s💀 %v 💀%s", colorNeonRed, err, colorReset)
+	}
+	if err := opml.Write(file, "torrent-rss feeds", feeds); err != nil {
+		file.Close()
+		log.Fatalf("%s💀 %v 💀%s", colorNeonRed, err, colorReset)
+	}
+	if err := file.Close(); err != nil {
+		log.Fatalf("%s💀 %v 💀%s", colorNeonRed, err, colorReset)
+	}
+	if jsonOutput {
+		printJSON(map[string]any{"path": path, "exported": len(feeds)})
+		return 0
+	}
+	fmt.Printf("%s✅ Exported %s%d%s feeds to %s%s\n", colorNeonGreen, colorNeonBlue, len(feeds), colorNeonGreen, path, colorReset)
+	fmt.Printf("%s   Feed URLs may hold your passkey, keep the file private%s\n", colorGray, colorReset)
+	return 0
+}
+
+// importedFeed is a feed of an OPML file as it goes into the config
+type importedFeed struct {
+	Name     string `yaml:"name" json:"name"`
+	URL      string `yaml:"url" json:"url"`
+	Tracker  string `yaml:"tracker" json:"tracker"`
+	Interval string `yaml:"interval,omitempty" json:"interval,omitempty"`
+}
+
+// importFeeds turns the feeds of an OPML file into config file entries and
+// prints them, for pasting under feeds: in the config. Feeds that are
+// configured already are left out. The tracker is the one serving the
+// feed's host, unless --tracker names one. Everything but the entries goes
+// to stderr, so they can be redirected to a file.
+func importFeeds(args []string) int {
+	flags := flag.NewFlagSet("feeds import", flag.ExitOnError)
+	trackerName := flags.String("tracker", "", "tracker of every imported feed, by default the one serving each feed's host")
+	interval := flags.Duration("interval", 0, "poll interval of the imported feeds, by default the config's")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		fmt.Println("usage: torrent-rss feeds import [--tracker name] [--interval 1h] <file.opml>")
+		return 2
+	}
+
+	cfg := loadConfig()
+	if _, ok := cfg.Trackers[*trackerName]; *trackerName != "" && !ok {
+		log.Fatalf("%s💀 Unknown tracker %q 💀%s", colorNeonRed, *trackerName, colorReset)
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		log.Fatalf("%s💀 %v 💀%s", colorNeonRed, err, colorReset)
+	}
+	defer file.Close()
+	feeds, err := opml.Parse(file)
+	if err != nil {
+		log.Fatalf("%s💀 %v 💀%s", colorNeonRed, err, colorReset)
+	}
+
+	names := make(map[string]bool)
+	configured := make(map[string]bool)
+	for _, feed := range cfg.Feeds {
+		names[feed.Name] = true
+		configured[feed.URL] = true
+	}
+
+	var imported []importedFeed
+	skipped := 0
+	for _, feed := range feeds {
+		if configured[feed.URL] {
+			fmt.Fprintf(os.Stderr, "%s⏭️  Skipped (already configured): %s%s\n", colorGray, feed.Title, colorReset)
+			skipped++
+			continue
+		}
+		tracker := *trackerName
+		if tracker == "" {
+			if tracker = feedTracker(cfg, feed.URL); tracker == "" {
+				fmt.Fprintf(os.Stderr, "%s⚠️  Skipped %s: no tracker serves %s, pick one with --tracker%s\n", colorNeonYellow, feed.Title, feed.URL, colorReset)
+				skipped++
+				continue
+			}
+		}
+		entry := importedFeed{Name: feedName(feed.Title, names), URL: feed.URL, Tracker: tracker}
+		if *interval > 0 {
+			entry.Interval = interval.String()
+		}
+		configured[feed.URL] = true
+		imported = append(imported, entry)
+	}
+
+	if jsonOutput {
+		printListJSON(imported)
+		return 0
+	}
+	if len(imported) > 0 {
+		enc := yaml.NewEncoder(results)
+		enc.SetIndent(2)
+		if err := enc.Encode(map[string][]importedFeed{"feeds": imported}); err != nil {
+			log.Fatalf("%s💀 %v 💀%s", colorNeonRed, err, colorReset)
+		}
+		enc.Close()
+	}
+	fmt.Fprintf(os.Stderr, "\n%s⚡️Imported %s%d%s feeds, %s%d%s skipped, add them to the feeds of your config file ⚡️%s\n",
+		colorNeonYellow, colorNeonBlue, len(imported), colorNeonYellow, colorNeonBlue, skipped, colorNeonYellow, colorReset)
+	return 0
+}
+
+// feedTracker returns the tracker that serves the host of link, or the only
+// tracker there is, or "" when it can't tell
+func feedTracker(cfg *config.Config, link string) string {
+	names := make([]string, 0, len(cfg.Trackers))
+	for name := range cfg.Trackers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if u, err := url.Parse(link); err == nil && u.Hostname() != "" {
+		for _, name := range names {
+			for _, host := range cfg.TrackerHosts(name) {
+				if host == u.Hostname() {
+					return name
+				}
+			}
+		}
+	}
+	if len(names) == 1 {
+		return names[0]
+	}
+	return ""
+}
+
+var nonName = regexp.MustCompile(`[^a-z0-9]+`)
+
+// feedName makes a feed name out of an OPML title, e.g. "TV Shows (HD)"
+// becomes "tv-shows-hd", unique among names, which it's added to
+func feedName(title string, names map[string]bool) string {
+	base := strings.Trim(nonName.ReplaceAllString(strings.ToLower(title), "-"), "-")
+	if base == "" {
+		base = "feed"
+	}
+	name := base
+	for i := 2; names[name]; i++ {
+		name = base + "-" + strconv.Itoa(i)
+	}
+	names[name] = true
+	return name
+}
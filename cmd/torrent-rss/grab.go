@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/url"
+
+	"torrent-rss/internal/config"
+	"torrent-rss/internal/magnet"
+	"torrent-rss/internal/models"
+)
+
+// runGrab handles `torrent-rss grab [--feed name] <url>`, downloading a
+// single torrent page, .torrent URL or magnet link that never showed up in
+// a feed. The feed decides the tracker credentials and where it's delivered.
+func runGrab(args []string) int {
+	flags := flag.NewFlagSet("grab", flag.ExitOnError)
+	name := flags.String("feed", "", "deliver like this feed, by default the one whose tracker hosts the URL")
+	title := flags.String("title", "", "title for the history and notifications, defaults to the URL")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		fmt.Println("usage: torrent-rss grab [--feed name] [--title title] <page-or-torrent-url>")
+		return 2
+	}
+	link := flags.Arg(0)
+
+	cfg := loadConfig()
+	feed, err := grabFeed(cfg, *name, link)
+	if err != nil {
+		log.Fatalf("%s💀 %v 💀%s", colorNeonRed, err, colorReset)
+	}
+
+	item := models.Item{Title: *title, Link: link}
+	if item.Title == "" {
+		item.Title = link
+	}
+	// The URL may be the torrent itself or the page linking to it. The
+	// enclosure resolver tries it as a torrent, and when that isn't valid
+	// the tracker scrapes it as a page.
+	if !magnet.IsMagnet(link) {
+		item.EnclosureURL = link
+	}
+
+	a := newApp(cfg)
+	defer a.Close()
+
+	_, err = a.pipe.Grab(context.Background(), feed, item)
+	a.notifier.Flush(context.Background())
+	if err != nil {
+		// The pipeline already reported the failure
+		return 1
+	}
+	return 0
+}
+
+// grabFeed picks the feed a grabbed URL is delivered like: the named one,
+// the first whose tracker serves the URL's host, or the only one there is
+func grabFeed(cfg *config.Config, name, link string) (config.Feed, error) {
+	if name != "" {
+		feed, ok := cfg.Feed(name)
+		if !ok {
+			return config.Feed{}, fmt.Errorf("unknown feed %q", name)
+		}
+		return feed, nil
+	}
+
+	if u, err := url.Parse(link); err == nil && u.Hostname() != "" {
+		for _, feed := range cfg.Feeds {
+			for _, host := range cfg.TrackerHosts(feed.Tracker) {
+				if host == u.Hostname() {
+					return feed, nil
+				}
+			}
+		}
+	}
+	if len(cfg.Feeds) == 1 {
+		return cfg.Feeds[0], nil
+	}
+	return config.Feed{}, fmt.Errorf("can't tell which feed %s belongs to, pick one with --feed", link)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"torrent-rss/internal/config"
+	"torrent-rss/internal/history"
+)
+
+// runHistory handles `torrent-rss history list|episodes|failed|purge|import`
+func runHistory(cfg *config.Config, args []string) {
+	if len(args) == 0 {
+		fmt.Println("usage: torrent-rss history <list|episodes|failed|purge|import> [flags]")
+		os.Exit(2)
+	}
+
+	store, err := cfg.OpenHistory()
+	if err != nil {
+		log.Fatalf("%s💀 Error opening history: %v 💀%s", colorNeonRed, err, colorReset)
+	}
+	defer store.Close()
+
+	switch args[0] {
+	case "list":
+		entries, err := store.List()
+		if err != nil {
+			log.Fatalf("%s💀 %v 💀%s", colorNeonRed, err, colorReset)
+		}
+		if jsonOutput {
+			printListJSON(entries)
+			return
+		}
+		if len(entries) == 0 {
+			fmt.Printf("%s🚫 History is empty 🚫%s\n", colorNeonRed, colorReset)
+			return
+		}
+		for _, entry := range entries {
+			fmt.Printf("%s%s%s  %s[%s]%s %s%s%s\n",
+				colorGray, entry.DownloadedAt.Format("2006-01-02 15:04"), colorReset,
+				colorNeonPink, entry.Feed, colorReset,
+				colorNeonGreen, entry.Title, colorReset)
+			if entry.Media != nil {
+				fmt.Printf("%s                  %s  %s%s\n", colorGray, entry.Media, entry.Media.IDs(), colorReset)
+			}
+			if entry.InfoHash != "" {
+				fmt.Printf("%s                  %s%s\n", colorGray, entry.InfoHash, colorReset)
+			}
+			if entry.Status == history.StatusCompleted {
+				fmt.Printf("%s                  🏁 Completed in %s %s%s\n", colorGray, entry.Client, entry.CompletedAt.Local().Format("2006-01-02 15:04"), colorReset)
+			}
+		}
+		fmt.Printf("\n%s⚡️Total entries: %s%d%s ⚡️\n", colorNeonYellow, colorNeonBlue, len(entries), colorReset)
+
+	case "episodes":
+		records, err := store.Episodes()
+		if err != nil {
+			log.Fatalf("%s💀 %v 💀%s", colorNeonRed, err, colorReset)
+		}
+		if jsonOutput {
+			printListJSON(records)
+			return
+		}
+		if len(records) == 0 {
+			fmt.Printf("%s🚫 No episodes tracked yet 🚫%s\n", colorNeonRed, colorReset)
+			return
+		}
+		for _, record := range records {
+			fmt.Printf("%s%-30s%s %s%-10s%s %s%s%s\n",
+				colorNeonPink, record.Show, colorReset,
+				colorNeonYellow, record.Episode, colorReset,
+				colorGray, record.Title, colorReset)
+		}
+
+	case "failed":
+		failures, err := store.Failures("")
+		if err != nil {
+			log.Fatalf("%s💀 %v 💀%s", colorNeonRed, err, colorReset)
+		}
+		if jsonOutput {
+			printListJSON(failures)
+			return
+		}
+		if len(failures) == 0 {
+			fmt.Printf("%s🚫 No failed downloads 🚫%s\n", colorNeonRed, colorReset)
+			return
+		}
+		for _, failure := range failures {
+			attempts := fmt.Sprintf("%d attempts", failure.Attempts)
+			if failure.GaveUp {
+				attempts += ", given up"
+			}
+			fmt.Printf("%s%s%s  %s[%s]%s %s%s%s %s(%s)%s\n",
+				colorGray, failure.LastFailed.Format("2006-01-02 15:04"), colorReset,
+				colorNeonPink, failure.Feed, colorReset,
+				colorNeonGreen, failure.Item.Title, colorReset,
+				colorNeonYellow, attempts, colorReset)
+			fmt.Printf("%s                  %s%s\n", colorGray, failure.Err, colorReset)
+		}
+		fmt.Printf("\n%s⚡️Total failed: %s%d%s ⚡️\n", colorNeonYellow, colorNeonBlue, len(failures), colorReset)
+
+	case "purge":
+		fs := flag.NewFlagSet("history purge", flag.ExitOnError)
+		olderThan := fs.Duration("older-than", 0, "only purge entries older than this duration (e.g. 720h)")
+		fs.Parse(args[1:])
+
+		var cutoff time.Time
+		if *olderThan > 0 {
+			cutoff = time.Now().Add(-*olderThan)
+		}
+		removed, err := store.Purge(cutoff)
+		if err != nil {
+			log.Fatalf("%s💀 %v 💀%s", colorNeonRed, err, colorReset)
+		}
+		if jsonOutput {
+			printJSON(map[string]int{"purged": removed})
+			return
+		}
+		fmt.Printf("%s🧹 Purged %s%d%s entries%s\n", colorNeonYellow, colorNeonBlue, removed, colorNeonYellow, colorReset)
+
+	case "import":
+		importHistory(cfg, store, args[1:])
+
+	default:
+		fmt.Printf("unknown history command %q\n", args[0])
+		os.Exit(2)
+	}
+}
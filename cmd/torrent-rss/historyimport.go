@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"torrent-rss/internal/client"
+	"torrent-rss/internal/config"
+	"torrent-rss/internal/episode"
+	"torrent-rss/internal/history"
+	"torrent-rss/internal/magnet"
+	"torrent-rss/internal/metainfo"
+	"torrent-rss/internal/quality"
+	"torrent-rss/internal/release"
+)
+
+// imported is a torrent found in a watch folder or a client
+type imported struct {
+	infoHash string
+	name     string
+}
+
+// importHistory handles `torrent-rss history import`, recording torrents that
+// were grabbed by hand so the feeds don't download them again
+func importHistory(cfg *config.Config, store *history.Store, args []string) {
+	flags := flag.NewFlagSet("history import", flag.ExitOnError)
+	dir := flags.String("dir", "", "scan this watch folder for .torrent and .magnet files")
+	clientName := flags.String("client", "", "list the torrents of this configured torrent client")
+	feed := flags.String("feed", "import", "feed name to record the torrents under")
+	flags.Parse(args)
+
+	if (*dir == "") == (*clientName == "") {
+		fmt.Println("usage: torrent-rss history import --dir path | --client name [--feed name]")
+		os.Exit(2)
+	}
+
+	var (
+		torrents []imported
+		err      error
+	)
+	if *dir != "" {
+		torrents, err = scanWatchFolder(*dir)
+	} else {
+		torrents, err = listClient(cfg, *clientName)
+	}
+	if err != nil {
+		log.Fatalf("%s💀 %v 💀%s", colorNeonRed, err, colorReset)
+	}
+
+	added, known := 0, 0
+	for _, t := range torrents {
+		existing, err := store.ByInfoHash(t.infoHash)
+		if err != nil {
+			log.Fatalf("%s💀 %v 💀%s", colorNeonRed, err, colorReset)
+		}
+		if existing != nil {
+			known++
+			continue
+		}
+		if err := importTorrent(store, *feed, t); err != nil {
+			log.Fatalf("%s💀 Error recording %s: %v 💀%s", colorNeonRed, t.name, err, colorReset)
+		}
+		fmt.Printf("%s➕ %s%s %s%s%s\n", colorNeonGreen, t.name, colorReset, colorGray, t.infoHash, colorReset)
+		added++
+	}
+	if jsonOutput {
+		printJSON(map[string]int{"imported": added, "known": known})
+		return
+	}
+	fmt.Printf("\n%s⚡️Imported %s%d%s torrents, %s%d%s already in history ⚡️%s\n",
+		colorNeonYellow, colorNeonBlue, added, colorNeonYellow, colorNeonBlue, known, colorNeonYellow, colorReset)
+}
+
+// importTorrent records a torrent like a download, and its episodes unless
+// they were grabbed already
+func importTorrent(store *history.Store, feed string, t imported) error {
+	err := store.Add(history.Entry{
+		Key:      "import:" + t.infoHash,
+		Feed:     feed,
+		Title:    t.name,
+		InfoHash: t.infoHash,
+		Release:  release.Key(t.name),
+	})
+	if err != nil {
+		return err
+	}
+
+	info, ok := episode.Parse(t.name)
+	if !ok {
+		return nil
+	}
+	records := []episode.Info{info}
+	if !info.Pack {
+		records = info.Episodes()
+	}
+	for _, single := range records {
+		existing, err := store.Episode(single.Key())
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			continue
+		}
+		err = store.PutEpisode(history.EpisodeRecord{
+			Key:      single.Key(),
+			Show:     single.Show,
+			Episode:  single.Code(),
+			Title:    t.name,
+			Quality:  quality.Parse(t.name).String(),
+			InfoHash: t.infoHash,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanWatchFolder reads the .torrent and .magnet files under dir, skipping
+// any that can't be parsed
+func scanWatchFolder(dir string) ([]imported, error) {
+	var torrents []imported
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		var t imported
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".torrent":
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			meta, err := metainfo.Parse(data)
+			if err != nil {
+				fmt.Printf("%s⚠️  Skipping %s: %v%s\n", colorNeonYellow, path, err, colorReset)
+				return nil
+			}
+			t = imported{infoHash: meta.InfoHash, name: meta.Name}
+		case ".magnet":
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			link, err := magnet.Parse(string(data))
+			if err != nil {
+				fmt.Printf("%s⚠️  Skipping %s: %v%s\n", colorNeonYellow, path, err, colorReset)
+				return nil
+			}
+			t = imported{infoHash: link.InfoHash, name: link.Name}
+		default:
+			return nil
+		}
+		if t.name == "" {
+			t.name = strings.TrimSuffix(d.Name(), filepath.Ext(path))
+		}
+		torrents = append(torrents, t)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", dir, err)
+	}
+	return torrents, nil
+}
+
+// listClient asks a configured torrent client for the torrents it holds
+func listClient(cfg *config.Config, name string) ([]imported, error) {
+	cc, ok := cfg.Clients[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown torrent client %q", name)
+	}
+	c, err := client.New(cc.Type, client.Options{
+		URL:      cc.URL,
+		Username: cc.Username,
+		Password: cc.Password,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create torrent client %s: %w", name, err)
+	}
+	lister, ok := c.(client.Lister)
+	if !ok {
+		return nil, fmt.Errorf("torrent client %s (%s) can't list its torrents", name, cc.Type)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	listed, err := lister.Torrents(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list torrents of %s: %w", name, err)
+	}
+	torrents := make([]imported, 0, len(listed))
+	for _, t := range listed {
+		torrents = append(torrents, imported{infoHash: t.InfoHash, name: t.Name})
+	}
+	return torrents, nil
+}
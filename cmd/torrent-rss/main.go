@@ -1,11 +1,51 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"torrent-rss/internal/api"
+	"torrent-rss/internal/bandwidth"
+	"torrent-rss/internal/challenge"
+	"torrent-rss/internal/client"
+	_ "torrent-rss/internal/client/deluge"
+	_ "torrent-rss/internal/client/qbittorrent"
+	_ "torrent-rss/internal/client/rtorrent"
 	"torrent-rss/internal/config"
+	"torrent-rss/internal/connpool"
+	"torrent-rss/internal/cookiestore"
+	"torrent-rss/internal/daemon"
+	"torrent-rss/internal/delivery"
+	"torrent-rss/internal/dnscache"
 	"torrent-rss/internal/downloader"
+	"torrent-rss/internal/headers"
+	"torrent-rss/internal/history"
+	"torrent-rss/internal/httpx"
+	"torrent-rss/internal/instance"
+	"torrent-rss/internal/login"
+	"torrent-rss/internal/maintenance"
+	"torrent-rss/internal/metadata"
+	"torrent-rss/internal/notify"
+	_ "torrent-rss/internal/notify/discord"
+	_ "torrent-rss/internal/notify/email"
+	_ "torrent-rss/internal/notify/webhook"
 	"torrent-rss/internal/parser"
+	"torrent-rss/internal/pipeline"
+	"torrent-rss/internal/ratelimit"
+	"torrent-rss/internal/service"
+	"torrent-rss/internal/systemd"
+	"torrent-rss/internal/tracker"
+	"torrent-rss/internal/watchlist"
 
 	"github.com/joho/godotenv"
 )
@@ -21,58 +61,618 @@ const (
 	colorGray       = "\033[1;90m"
 )
 
+// cliArgs are the command line arguments without --output
+var cliArgs []string
+
 func init() {
+	// A Windows service starts in the system directory, without a console
+	if err := service.Setup(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s💀 %v 💀%s\n", colorNeonRed, err, colorReset)
+		os.Exit(1)
+	}
+	// Before anything is printed, so nothing lands among JSON results
+	args, err := takeOutputFlag(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s💀 %v 💀%s\n", colorNeonRed, err, colorReset)
+		os.Exit(2)
+	}
+	cliArgs = args
+
+	for _, env := range os.Environ() {
+		key, _, _ := strings.Cut(env, "=")
+		inheritedEnv[key] = true
+	}
+	if values, err := godotenv.Read(); err == nil {
+		for key := range values {
+			if !inheritedEnv[key] {
+				dotenvKeys[key] = true
+			}
+		}
+	}
+	// On stderr, so output redirected to a file, like an OPML export, stays valid
 	if err := godotenv.Load(); err != nil {
-		fmt.Printf("%s⚠️  No .env file found, checking system environment variables...%s\n", colorNeonYellow, colorReset)
+		fmt.Fprintf(os.Stderr, "%s⚠️  No .env file found, checking system environment variables...%s\n", colorNeonYellow, colorReset)
 	}
 }
 
 func main() {
-	cfg := config.NewConfig()
-	p := parser.NewParser()
+	args := cliArgs
+	// Without a command, poll once like before there were commands
+	if len(args) == 0 {
+		args = []string{"run"}
+	}
+	cmd, ok := lookupCommand(args[0])
+	if !ok {
+		fmt.Printf("%s💀 Unknown command %q 💀%s\n\n", colorNeonRed, args[0], colorReset)
+		printUsage()
+		os.Exit(2)
+	}
+	os.Exit(cmd.run(args[1:]))
+}
+
+// loadConfig reads the config file named by TD_CONFIG, or the environment
+func loadConfig() *config.Config {
+	path := os.Getenv("TD_CONFIG")
+	if path == "" {
+		return config.NewConfig()
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		log.Fatalf("%s💀 %v 💀%s", colorNeonRed, err, colorReset)
+	}
+	return cfg
+}
+
+// requestMetrics counts the requests to every tracker host, kept across
+// config reloads
+var requestMetrics = httpx.NewMetrics()
+
+// liveEvents streams every pipeline event to the API's clients, kept across
+// config reloads
+var liveEvents = api.NewEvents()
 
-	fmt.Printf("%s⚡️>>> Searching for %s《%v》%s matches with %s1080p%s... ⚡️%s\n\n",
-		colorNeonBlue, colorNeonPink, cfg.SearchTerms, colorNeonBlue, colorNeonYellow, colorNeonBlue, colorReset)
+// network is the rate limiter, challenge handler, DNS cache and TLS settings
+// every request to a tracker goes through, and the feed parser built on them
+type network struct {
+	limiter    *ratelimit.Limiter
+	challenges *challenge.Handler
+	dns        *dnscache.Resolver
+	tls        map[string]*tls.Config // By tracker
+	bandwidth  *bandwidth.Limiter     // Shared by every tracker's downloads
+	parser     *parser.Parser
+}
+
+func newNetwork(cfg *config.Config) (*network, error) {
+	// Feed polls and tracker requests share one budget per host
+	limiter := ratelimit.New()
+	// Feed polls look like the browser of the tracker they go to
+	profiles := make(map[string]headers.Profile)
+	// Feed polls to a tracker's hosts use its TLS settings as well
+	trackerTLS := make(map[string]*tls.Config)
+	hostTLS := make(map[string]*tls.Config)
+	// and its connection settings where they differ from the global ones
+	hostConnections := make(map[string]connpool.Options)
+	for name, tc := range cfg.Trackers {
+		tlsConfig, err := tc.TLS.Config()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS settings of %s: %w", name, err)
+		}
+		if tc.TLS.InsecureSkipVerify {
+			fmt.Printf("%s⚠️  Not verifying the TLS certificate of %s, anyone on the network path can read its cookies and passkey%s\n", colorNeonRed, name, colorReset)
+		}
+		if tlsConfig != nil {
+			trackerTLS[name] = tlsConfig
+		}
+		for _, host := range cfg.TrackerHosts(name) {
+			profiles[host] = tc.Headers
+			if tc.RateLimit > 0 {
+				limiter.SetRate(host, tc.RateLimit)
+			}
+			if tlsConfig != nil {
+				hostTLS[host] = tlsConfig
+			}
+			if tc.Connections != cfg.Connections {
+				hostConnections[host] = tc.Connections
+			}
+		}
+	}
+	// Challenges solved for one client are reused by all of them
+	var solver challenge.Solver
+	if cfg.FlareSolverrURL != "" {
+		flareSolverr, err := challenge.NewFlareSolverr(cfg.FlareSolverrURL, cfg.FlareSolverrTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure FlareSolverr: %w", err)
+		}
+		solver = flareSolverr
+	}
+	challenges := challenge.NewHandler(solver)
+	dns := dnscache.New(cfg.DNSCacheTTL)
 
-	matches, err := p.FetchAndParse(cfg.GetRSSURL(), cfg.SearchTerms)
+	return &network{
+		limiter:    limiter,
+		challenges: challenges,
+		dns:        dns,
+		tls:        trackerTLS,
+		bandwidth:  bandwidth.New(cfg.BandwidthLimit),
+		parser: parser.NewParser(parser.Options{
+			Retry:           cfg.Retry,
+			Limiter:         limiter,
+			Headers:         profiles,
+			Challenges:      challenges,
+			DNS:             dns,
+			TLS:             hostTLS,
+			Connections:     cfg.Connections,
+			HostConnections: hostConnections,
+			Debugf:          debugf(cfg),
+			Metrics:         requestMetrics,
+		}),
+	}, nil
+}
+
+// debugf prints debug details when cfg.Debug is set, otherwise it's nil
+func debugf(cfg *config.Config) func(format string, args ...any) {
+	if !cfg.Debug {
+		return nil
+	}
+	return func(format string, args ...any) {
+		fmt.Printf("%s🐛 %s%s\n", colorGray, fmt.Sprintf(format, args...), colorReset)
+	}
+}
+
+// app is everything a poll needs: the pipeline with its trackers and
+// clients, the history behind it, the notifiers it reports to and the
+// cookie jar its trackers share
+type app struct {
+	cfg      *config.Config
+	lock     *instance.Lock
+	store    *history.Store
+	pipe     *pipeline.Pipeline
+	notifier *notify.Dispatcher
+	jar      *cookiestore.Jar
+}
+
+func newApp(cfg *config.Config) *app {
+	// Two processes grabbing into one state directory would download
+	// releases twice, whichever backend keeps the history
+	lock, err := instance.Acquire(cfg.StateDir)
 	if err != nil {
-		log.Fatalf("%s💀 Error parsing RSS feed: %v 💀%s", colorNeonRed, err, colorReset)
+		log.Fatalf("%s💀 %v 💀%s", colorNeonRed, err, colorReset)
+	}
+	store, err := cfg.OpenHistory()
+	if err != nil {
+		log.Fatalf("%s💀 Error opening history: %v 💀%s", colorNeonRed, err, colorReset)
 	}
 
-	if len(matches) == 0 {
-		fmt.Printf("%s🚫 No matches found! 🚫%s\n", colorNeonRed, colorReset)
-		return
+	notifier := newDispatcher(cfg)
+	// Cookies are domain-scoped, so every tracker can share one jar. It's
+	// kept across reloads, two jars would overwrite each other's saves.
+	jar, err := cookiestore.Open(cfg.CookiesPath(), cfg.CookieKey)
+	if err != nil {
+		log.Fatalf("%s💀 Error opening cookie jar: %v 💀%s", colorNeonRed, err, colorReset)
+	}
+	pipe, err := newPipeline(cfg, store, notifier, jar, nil)
+	if err != nil {
+		log.Fatalf("%s💀 %v 💀%s", colorNeonRed, err, colorReset)
+	}
+	return &app{cfg: cfg, lock: lock, store: store, pipe: pipe, notifier: notifier, jar: jar}
+}
+
+// newPipeline builds the pipeline of cfg with its trackers, clients and
+// deliveries, recording to store, reporting to notifier and keeping cookies
+// in jar. On a reload it takes over the state of old, the pipeline it
+// replaces.
+func newPipeline(cfg *config.Config, store *history.Store, notifier *notify.Dispatcher, jar *cookiestore.Jar, old *pipeline.Pipeline) (*pipeline.Pipeline, error) {
+	net, err := newNetwork(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := pipeline.New(net.parser, store, func(e pipeline.Event) {
+		printEvent(cfg, e)
+		notifyEvent(cfg, notifier, e)
+		liveEvents.Publish(e)
+	})
+	if old != nil {
+		pipe.TakeOver(old)
+	}
+
+	watched, err := watchlist.Open(cfg.WatchlistPath())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open watch-list: %w", err)
+	}
+	pipe.UseWatchlist(watched)
+	if provider := newMetadata(cfg); provider != nil {
+		pipe.UseMetadata(provider, func(format string, args ...any) {
+			fmt.Printf("%s⚠️  %s%s\n", colorNeonYellow, fmt.Sprintf(format, args...), colorReset)
+		})
+	}
+
+	for name, tc := range cfg.Trackers {
+		t, err := tracker.New(tc.Type, tracker.Options{
+			BaseURL:           tc.BaseURL,
+			Cookie:            cfg.TrackerCookie(name),
+			LinkSelector:      tc.LinkSelector,
+			FreeleechSelector: tc.FreeleechSelector,
+			DirectURL:         tc.DirectURL,
+			SearchURL:         tc.SearchURL,
+			SearchSelector:    tc.SearchSelector,
+			Passkey:           cfg.TrackerPasskey(name),
+			Auth:              tc.Auth,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create tracker %s: %w", name, err)
+		}
+
+		d, err := downloader.NewDownloader(t, downloader.Options{
+			Jar:            jar,
+			Retry:          cfg.Retry,
+			ConnectTimeout: cfg.ConnectTimeout,
+			ReadTimeout:    cfg.ReadTimeout,
+			Proxy:          tc.Proxy,
+			Resolvers:      tc.Resolvers,
+			Headers:        &tc.Headers,
+			Challenges:     net.challenges,
+			PartialDir:     cfg.PartialDir(),
+			Limiter:        net.limiter,
+			DNS:            net.dns,
+			MaxRedirects:   cfg.MaxRedirects,
+			MaxTorrentSize: cfg.MaxTorrentSize,
+			Debugf:         debugf(cfg),
+			Metrics:        requestMetrics,
+			NameTemplate:   cfg.NameTemplate,
+			Announce:       tc.Announce.WithPasskey(cfg.TrackerPasskey(name)),
+			TLS:            net.tls[name],
+			Connections:    tc.Connections,
+			Bandwidth:      []*bandwidth.Limiter{net.bandwidth, bandwidth.New(tc.BandwidthLimit)},
+			PageWorkers:    tc.PageWorkers,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create downloader of %s: %w", name, err)
+		}
+		if tc.Login != nil {
+			session, err := login.New(tc.LoginOptions())
+			if err != nil {
+				return nil, fmt.Errorf("failed to configure login for %s: %w", name, err)
+			}
+			d.UseLogin(session)
+		}
+		pipe.AddTracker(name, d)
+		pipe.SetTrackerCooldown(name, tc.Cooldown)
+		pipe.SetTrackerGrabDelay(name, tc.GrabDelay)
+	}
+
+	pipe.SetTrackerPriority(cfg.TrackerPriority)
+	pipe.SetMinFreeSpace(cfg.MinFreeSpace)
+	pipe.SetContentTypes(cfg.ContentTypes)
+	pipe.UseFolder(delivery.NewFolder(cfg.DownloadPath))
+	for name, dc := range cfg.Deliveries {
+		opts := dc.Options()
+		opts.Retry = cfg.Retry
+		d, err := delivery.New(dc.Type, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create delivery %s: %w", name, err)
+		}
+		pipe.AddDelivery(name, d)
+	}
+
+	for name, cc := range cfg.Clients {
+		c, err := client.New(cc.Type, client.Options{
+			URL:      cc.URL,
+			Username: cc.Username,
+			Password: cc.Password,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create torrent client %s: %w", name, err)
+		}
+		pipe.AddClient(name, c, client.AddOptions{
+			Category: cc.Category,
+			SavePath: cc.SavePath,
+		})
+	}
+	return pipe, nil
+}
+
+// Close releases the history database and the state directory
+func (a *app) Close() {
+	a.store.Close()
+	a.lock.Release()
+}
+
+// runOnce handles `torrent-rss run`, polling every feed, or the one named
+// with --feed, a single time
+func runOnce(args []string) int {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	only := flags.String("feed", "", "poll only the named feed")
+	flags.Parse(args)
+
+	cfg := loadConfig()
+	feeds := cfg.FeedsByPriority()
+	if *only != "" {
+		feed, ok := cfg.Feed(*only)
+		if !ok {
+			log.Fatalf("%s💀 Unknown feed %q 💀%s", colorNeonRed, *only, colorReset)
+		}
+		feeds = []config.Feed{feed}
+	}
+
+	a := newApp(cfg)
+	defer a.Close()
+
+	// Like the daemon, poll a few feeds at a time, starting in priority order
+	var wg sync.WaitGroup
+	var failed atomic.Bool
+	workers := make(chan struct{}, max(cfg.Workers, 1))
+	for _, feed := range feeds {
+		workers <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-workers }()
+			if err := pollFeed(context.Background(), a.pipe, a.notifier, feed); err != nil {
+				failed.Store(true)
+			}
+		}()
+	}
+	wg.Wait()
+	exitCode := 0
+	if failed.Load() {
+		exitCode = 1
+	}
+	// Earlier runs' torrents may have finished in the meantime
+	if cfg.CompletionCheck > 0 {
+		checkCompletions(context.Background(), a.pipe)
+	}
+	// A single run can't wait for the digest time, send what it found now
+	a.notifier.Flush(context.Background())
+	return exitCode
+}
+
+// runDaemon handles `torrent-rss daemon`, polling every feed on its interval
+// until SIGINT or SIGTERM. SIGHUP or a change to the config file reloads it.
+func runDaemon(args []string) int {
+	flags := flag.NewFlagSet("daemon", flag.ExitOnError)
+	flags.Parse(args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	// Under the Windows service manager, stopping the service stops the
+	// daemon, which is reported stopped once everything is closed
+	ctx, stopped := service.Attach(ctx)
+	defer stopped()
+
+	cfg := loadConfig()
+	a := newApp(cfg)
+	defer a.Close()
+	notifier := a.notifier
+	reloads := newReloader(a)
+
+	// Each poll uses the pipeline of the config as it was when it started
+	d := daemon.New(cfg.FeedsByPriority(), cfg.PollJitter, cfg.Workers, func(ctx context.Context, feed config.Feed) {
+		_ = pollFeed(ctx, reloads.pipeline(), notifier, feed)
+	})
+	reloads.daemon = d
+
+	if cfg.APIAddr != "" {
+		fmt.Printf("%s🌐 API listening on %s%s%s\n", colorNeonBlue, colorNeonPink, cfg.APIAddr, colorReset)
+		apiServer := api.New(cfg, a.pipe, a.store)
+		apiServer.UseDaemon(d)
+		apiServer.UseMetrics(requestMetrics)
+		apiServer.UseEvents(liveEvents)
+		if path := os.Getenv("TD_CONFIG"); path != "" {
+			apiServer.UseConfigFile(path, reloads.reload)
+		}
+		reloads.api = apiServer
+		go func() {
+			if err := apiServer.ListenAndServe(ctx, cfg.APIAddr); err != nil {
+				fmt.Printf("%s💀 %v 💀%s\n", colorNeonRed, err, colorReset)
+			}
+		}()
+	}
+
+	for _, feed := range cfg.Feeds {
+		fmt.Printf("%s⏰ Polling %s%s%s %s%s\n", colorNeonBlue, colorNeonPink, feed.Name, colorNeonBlue, feed.Polling(), colorReset)
+	}
+
+	digests := make(chan struct{})
+	go func() {
+		notifier.Run(ctx)
+		close(digests)
+	}()
+
+	// Under systemd with Type=notify, report readiness and keep the watchdog
+	// fed for as long as no poll is hung
+	if err := systemd.Ready(); err != nil {
+		fmt.Printf("%s💀 %v 💀%s\n", colorNeonRed, err, colorReset)
+	}
+	systemd.Status(fmt.Sprintf("Polling %d feed(s)", len(cfg.Feeds)))
+	if interval, ok := systemd.WatchdogInterval(); ok {
+		go runWatchdog(ctx, d, interval/2)
 	}
 
-	d, err := downloader.NewDownloader(cfg.DownloadPath, cfg.BaseURL, cfg.GetAuthCookie())
+	if cfg.CompletionCheck > 0 {
+		go runCompletions(ctx, reloads, cfg.CompletionCheck)
+	}
+
+	go reloads.Run(ctx)
+	d.Run(ctx)
+	systemd.Stopping()
+
+	// Pending digests are sent on the way out
+	<-digests
+
+	fmt.Printf("\n%s👋 Shutting down daemon%s\n", colorNeonYellow, colorReset)
+	return 0
+}
+
+// runWatchdog pings the systemd watchdog every interval while the daemon is
+// healthy. A hung poll stops the pings, so systemd restarts the service.
+func runWatchdog(ctx context.Context, d *daemon.Daemon, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := d.Check(); err != nil {
+			fmt.Printf("%s💀 Unhealthy, skipping watchdog ping: %v 💀%s\n", colorNeonRed, err, colorReset)
+			continue
+		}
+		if err := systemd.Watchdog(); err != nil {
+			fmt.Printf("%s💀 %v 💀%s\n", colorNeonRed, err, colorReset)
+		}
+	}
+}
+
+// runCompletions asks the torrent clients for finished torrents every
+// interval, with the pipeline of the current config
+func runCompletions(ctx context.Context, reloads *reloader, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		checkCompletions(ctx, reloads.pipeline())
+	}
+}
+
+func checkCompletions(ctx context.Context, pipe *pipeline.Pipeline) {
+	if _, err := pipe.CheckCompletions(ctx); err != nil && ctx.Err() == nil {
+		fmt.Printf("%s💀 Completion check: %v 💀%s\n", colorNeonRed, err, colorReset)
+	}
+}
+
+func pollFeed(ctx context.Context, pipe *pipeline.Pipeline, notifier *notify.Dispatcher, feed config.Feed) error {
+	fmt.Printf("%s⚡️>>> Searching for %s《%v》%s matches with %s%v%s... ⚡️%s\n\n",
+		colorNeonBlue, colorNeonPink, feed.SearchTerms, colorNeonBlue, colorNeonYellow, feed.Filter.Includes(), colorNeonBlue, colorReset)
+
+	matches, err := pipe.Run(ctx, feed)
+	if jsonOutput {
+		printPollJSON(feed.Name, matches, err)
+	}
+	if errors.Is(err, parser.ErrNotModified) {
+		fmt.Printf("%s💤 Feed unchanged since the last poll%s\n", colorGray, colorReset)
+		return nil
+	}
+	// Reported when the cool-down started, not again on every poll
+	if errors.Is(err, pipeline.ErrCoolingDown) {
+		fmt.Printf("%s💤 %v%s\n", colorGray, err, colorReset)
+		return nil
+	}
 	if err != nil {
-		log.Fatalf("%s💀 Error creating downloader: %v 💀%s", colorNeonRed, err, colorReset)
+		var down *maintenance.Error
+		switch {
+		case errors.Is(err, downloader.ErrAuthExpired):
+			fmt.Printf("%s💀 Tracker login expired, update the cookie or login: %v 💀%s\n", colorNeonRed, err, colorReset)
+		case errors.Is(err, downloader.ErrRateLimited), errors.As(err, &down) && down.RateLimit:
+			fmt.Printf("%s🐢 Rate limited, the rest waits for the next poll: %v%s\n", colorNeonYellow, err, colorReset)
+		case errors.Is(err, downloader.ErrUnavailable), errors.As(err, &down):
+			fmt.Printf("%s🚧 Tracker down for maintenance, the rest waits for the next poll: %v%s\n", colorNeonYellow, err, colorReset)
+		default:
+			fmt.Printf("%s💀 Error parsing RSS feed: %v 💀%s\n", colorNeonRed, err, colorReset)
+		}
+		if ctx.Err() == nil {
+			notifier.Notify(ctx, notify.Notification{Kind: notify.KindFailed, Feed: feed.Name, Tracker: feed.Tracker, Err: err})
+		}
+		return err
+	}
+
+	if matches == 0 {
+		fmt.Printf("%s🚫 No matches found! 🚫%s\n", colorNeonRed, colorReset)
+		return nil
 	}
+
+	// Summary message
+	fmt.Printf("\n%s⚡️Total matches found: %s%d%s ⚡️\n", colorNeonYellow, colorNeonBlue, matches, colorReset)
+	return nil
+}
+
+// newMetadata builds the configured metadata provider, nil if there is none
+func newMetadata(cfg *config.Config) metadata.Provider {
+	if cfg.MetadataProvider == "" {
+		return nil
+	}
+	provider, err := metadata.New(cfg.MetadataProvider, cfg.MetadataOptions())
 	if err != nil {
-		log.Fatalf("%s💀 Error creating downloader: %v 💀%s", colorNeonRed, err, colorReset)
+		log.Fatalf("%s💀 Error creating metadata provider: %v 💀%s", colorNeonRed, err, colorReset)
 	}
+	return provider
+}
 
-	for _, item := range matches {
+func printEvent(cfg *config.Config, e pipeline.Event) {
+	if jsonOutput {
+		printEventJSON(e)
+	}
+	switch e.Kind {
+	case pipeline.EventFiltered:
+		fmt.Printf("%s🧹 Filtered (%s): %s%s\n", colorGray, e.Reason, e.Item.Title, colorReset)
+
+	case pipeline.EventSkipped:
+		reason := "already downloaded"
+		if e.Reason != "" {
+			reason = e.Reason
+		}
+		fmt.Printf("%s⏭️  Skipped (%s): %s%s\n", colorGray, reason, e.Item.Title, colorReset)
+
+	case pipeline.EventMatch:
+		item := e.Item
 		// Each match header with distinctive icons
 		fmt.Printf("\n%s╔═════════════════════════════════╗%s\n", colorGray, colorReset)
 		fmt.Printf("%s⚡️=== Match Found ===⚡️%s\n", colorNeonPink, colorReset)
 		fmt.Printf("%sTitle:%s %s%s%s\n", colorNeonYellow, colorReset, colorNeonGreen, item.Title, colorReset)
+		if e.Media != nil {
+			fmt.Printf("%sMedia:%s %s%s %s%s%s\n", colorNeonYellow, colorReset, colorNeonGreen, e.Media, colorGray, e.Media.IDs(), colorReset)
+		}
 		fmt.Printf("%sLink:%s %s%s%s\n", colorNeonYellow, colorReset, colorNeonGreen, item.Link, colorReset)
-		fmt.Printf("%sDate:%s %s%s%s\n", colorNeonYellow, colorReset, colorNeonGreen, item.PubDate, colorReset)
+		fmt.Printf("%sDate:%s %s%s%s\n", colorNeonYellow, colorReset, colorNeonGreen, item.PubDate.Format(time.RFC1123), colorReset)
 		fmt.Printf("%sDescription:%s %s%s%s\n", colorNeonYellow, colorReset, colorNeonGreen, item.Description, colorReset)
 		fmt.Printf("%s⏬ Downloading torrent file...%s\n", colorNeonBlue, colorReset)
 
-		// Download the torrent
-		if err := d.DownloadTorrent(item.Link); err != nil {
-			fmt.Printf("%s💀 Error downloading torrent: %v 💀%s\n", colorNeonRed, err, colorReset)
-			continue
+	case pipeline.EventFailed:
+		if e.Item.Title == "" {
+			fmt.Printf("%s💀 %s failed: %v 💀%s\n", colorNeonRed, e.Reason, e.Err, colorReset)
+		} else if e.Reason != "" {
+			fmt.Printf("%s💀 Error downloading torrent (%s): %v 💀%s\n", colorNeonRed, e.Reason, e.Err, colorReset)
+		} else {
+			fmt.Printf("%s💀 Error downloading torrent: %v 💀%s\n", colorNeonRed, e.Err, colorReset)
 		}
 
+	case pipeline.EventPaused:
+		fmt.Printf("%s💾 Paused grabbing, %v 💾%s\n", colorNeonRed, e.Err, colorReset)
+
+	case pipeline.EventResumed:
+		fmt.Printf("%s💾 Resumed grabbing, %s%s\n", colorNeonGreen, e.Reason, colorReset)
+
+	case pipeline.EventStale:
+		fmt.Printf("%s🩺 Feed looks stale, %v: check its passkey and whether the tracker is up%s\n", colorNeonYellow, e.Err, colorReset)
+
+	case pipeline.EventCompleted:
+		fmt.Printf("%s🏁 Completed: %s%s\n", colorNeonGreen, e.Item.Title, colorReset)
+
+	case pipeline.EventPending:
+		fmt.Printf("%s⏳ Awaiting approval [%s]: %s%s\n", colorNeonPink, e.Reason, e.Item.Title, colorReset)
+
+	case pipeline.EventUpgraded:
+		if e.InfoHash != "" {
+			fmt.Printf("%sInfohash:%s %s%s%s\n", colorNeonYellow, colorReset, colorGray, e.InfoHash, colorReset)
+		}
+		fmt.Printf("%s⬆️  Upgraded:%s %s\n", colorNeonGreen, colorReset, e.Reason)
+		fmt.Printf("%s╚═════════════════════════════════╝%s\n", colorGray, colorReset)
+
+	case pipeline.EventDownloaded:
+		if e.InfoHash != "" {
+			fmt.Printf("%sInfohash:%s %s%s%s\n", colorNeonYellow, colorReset, colorGray, e.InfoHash, colorReset)
+		}
 		// Success message with a futuristic divider
-		fmt.Printf("%s✅ Successfully downloaded to:%s %s\n", colorNeonGreen, colorReset, cfg.DownloadPath)
+		if feed, _ := cfg.Feed(e.Feed); feed.Client != "" || feed.Delivery != "" {
+			fmt.Printf("%s✅ Successfully sent to:%s %s\n", colorNeonGreen, colorReset, cfg.Destination(feed))
+		} else {
+			fmt.Printf("%s✅ Successfully downloaded to:%s %s\n", colorNeonGreen, colorReset, cfg.Destination(feed))
+		}
 		fmt.Printf("%s╚═════════════════════════════════╝%s\n", colorGray, colorReset)
 	}
-
-	// Summary message
-	fmt.Printf("\n%s⚡️Total matches found: %s%d%s ⚡️\n", colorNeonYellow, colorNeonBlue, len(matches), colorReset)
 }
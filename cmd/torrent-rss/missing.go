@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"torrent-rss/internal/config"
+)
+
+// runMissing handles `torrent-rss missing [--search] [--feed name]`, listing
+// the episodes skipped over per show and, with --search, searching the
+// feed's tracker for them
+func runMissing(args []string) int {
+	flags := flag.NewFlagSet("missing", flag.ExitOnError)
+	search := flags.Bool("search", false, "search the feed's tracker for the missing episodes and grab what matches")
+	name := flags.String("feed", "", "feed to search with, may be left out when there's only one")
+	flags.Parse(args)
+
+	cfg := loadConfig()
+	a := newApp(cfg)
+	defer a.Close()
+
+	if *search {
+		var feed config.Feed
+		switch {
+		case *name != "":
+			var ok bool
+			if feed, ok = cfg.Feed(*name); !ok {
+				log.Fatalf("%s💀 Unknown feed %q 💀%s", colorNeonRed, *name, colorReset)
+			}
+		case len(cfg.Feeds) == 1:
+			feed = cfg.Feeds[0]
+		default:
+			log.Fatalf("%s💀 Pick the feed to search with --feed 💀%s", colorNeonRed, colorReset)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+
+		fmt.Printf("%s🔎 Searching %s%s%s for missing episodes...%s\n\n", colorNeonBlue, colorNeonPink, feed.Tracker, colorNeonBlue, colorReset)
+		found, err := a.pipe.SearchMissing(ctx, feed)
+		a.notifier.Flush(context.Background())
+
+		exitCode := 0
+		if err != nil {
+			fmt.Printf("%s💀 %v 💀%s\n", colorNeonRed, err, colorReset)
+			exitCode = 1
+		}
+		if jsonOutput {
+			printJSON(map[string]any{"feed": feed.Name, "results": found})
+			return exitCode
+		}
+		fmt.Printf("\n%s⚡️Searched for missing episodes, %s%d%s result(s) on %s ⚡️%s\n", colorNeonYellow, colorNeonBlue, found, colorNeonYellow, feed.Tracker, colorReset)
+		return exitCode
+	}
+
+	report, err := a.pipe.Missing()
+	if err != nil {
+		log.Fatalf("%s💀 %v 💀%s", colorNeonRed, err, colorReset)
+	}
+	if jsonOutput {
+		printListJSON(report)
+		return 0
+	}
+	if len(report) == 0 {
+		fmt.Printf("%s🚫 No episodes tracked yet 🚫%s\n", colorNeonRed, colorReset)
+		return 0
+	}
+	gaps := 0
+	for _, show := range report {
+		switch {
+		case show.Last == "":
+			fmt.Printf("%s%-30s%s %snothing grabbed yet%s\n", colorNeonPink, show.Show, colorReset, colorGray, colorReset)
+		case len(show.Episodes) == 0:
+			fmt.Printf("%s%-30s%s %s✅ complete up to %s%s\n", colorNeonPink, show.Show, colorReset, colorNeonGreen, show.Last, colorReset)
+		default:
+			fmt.Printf("%s%-30s%s %s%s%s %s(up to %s)%s\n",
+				colorNeonPink, show.Show, colorReset,
+				colorNeonYellow, strings.Join(show.Episodes, ", "), colorReset,
+				colorGray, show.Last, colorReset)
+			gaps += len(show.Episodes)
+		}
+	}
+	fmt.Printf("\n%s⚡️Missing: %s%d%s ⚡️\n", colorNeonYellow, colorNeonBlue, gaps, colorReset)
+	return 0
+}
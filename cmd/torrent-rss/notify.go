@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"torrent-rss/internal/config"
+	"torrent-rss/internal/notify"
+	"torrent-rss/internal/pipeline"
+)
+
+// newDispatcher builds the configured notification channels
+func newDispatcher(cfg *config.Config) *notify.Dispatcher {
+	dispatcher := notify.NewDispatcher(func(name string, err error) {
+		fmt.Printf("%s💀 Notification via %s failed: %v 💀%s\n", colorNeonRed, name, err, colorReset)
+	})
+	for name, nc := range cfg.Notifiers {
+		n, err := notify.New(nc.Type, nc.Options())
+		if err != nil {
+			log.Fatalf("%s💀 Error creating notifier %s: %v 💀%s", colorNeonRed, name, err, colorReset)
+		}
+		if nc.Digest {
+			batcher, ok := n.(notify.BatchNotifier)
+			if !ok {
+				log.Fatalf("%s💀 Notifier %s can't send digests 💀%s", colorNeonRed, name, colorReset)
+			}
+			n = notify.NewDigest(batcher, nc.DigestAt)
+		}
+		dispatcher.Add(name, n, nc.Events)
+	}
+	return dispatcher
+}
+
+// notifyEvent forwards the pipeline events worth a notification
+func notifyEvent(cfg *config.Config, dispatcher *notify.Dispatcher, e pipeline.Event) {
+	var kind notify.Kind
+	switch e.Kind {
+	case pipeline.EventDownloaded:
+		kind = notify.KindGrabbed
+	case pipeline.EventUpgraded:
+		kind = notify.KindUpgraded
+	case pipeline.EventFailed, pipeline.EventPaused:
+		kind = notify.KindFailed
+	case pipeline.EventStale:
+		kind = notify.KindStale
+	case pipeline.EventCompleted:
+		kind = notify.KindCompleted
+	case pipeline.EventPending:
+		kind = notify.KindPending
+		e.Reason = approvalHint(cfg, e.Reason)
+	default:
+		return
+	}
+
+	feed, _ := cfg.Feed(e.Feed)
+	dispatcher.Notify(context.Background(), notify.Notification{
+		Kind:     kind,
+		Feed:     e.Feed,
+		Tracker:  feed.Tracker,
+		Title:    e.Item.Title,
+		Link:     e.Item.Link,
+		Size:     e.Size,
+		InfoHash: e.InfoHash,
+		Reason:   e.Reason,
+		Err:      e.Err,
+		Media:    e.Media,
+	})
+}
+
+// approvalHint tells how to approve the pending item with the given ID
+func approvalHint(cfg *config.Config, id string) string {
+	hint := fmt.Sprintf("approve with `torrent-rss pending approve %s`", id)
+	if cfg.APIAddr != "" {
+		hint += fmt.Sprintf(" or POST /api/v1/pending/%s/approve", id)
+	}
+	return hint
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"torrent-rss/internal/metadata"
+	"torrent-rss/internal/models"
+	"torrent-rss/internal/parser"
+	"torrent-rss/internal/pipeline"
+)
+
+var (
+	// jsonOutput is set by --output json: results are written to stdout as
+	// JSON, one document per line, and everything meant for people goes to
+	// stderr instead
+	jsonOutput bool
+	results    = os.Stdout
+	resultsMu  sync.Mutex
+)
+
+// takeOutputFlag handles --output text|json wherever it is among args, so
+// every command takes it, and returns args without it
+func takeOutputFlag(args []string) ([]string, error) {
+	mode := "text"
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		name, value, hasValue := strings.Cut(args[i], "=")
+		if name != "--output" && name != "-output" {
+			rest = append(rest, args[i])
+			continue
+		}
+		if !hasValue {
+			if i+1 == len(args) {
+				return nil, fmt.Errorf("--output needs a value, text or json")
+			}
+			i++
+			value = args[i]
+		}
+		mode = value
+	}
+
+	switch mode {
+	case "text":
+	case "json":
+		jsonOutput = true
+		// The colored messages keep going to the terminal, out of the way
+		// of whatever reads the results
+		os.Stdout = os.Stderr
+	default:
+		return nil, fmt.Errorf("unknown output %q, must be text or json", mode)
+	}
+	return rest, nil
+}
+
+// printJSON writes a result to stdout as a single line of JSON. Results of
+// concurrent polls don't interleave.
+func printJSON(v any) {
+	resultsMu.Lock()
+	defer resultsMu.Unlock()
+	if err := json.NewEncoder(results).Encode(v); err != nil {
+		fmt.Fprintf(os.Stderr, "%s💀 %v 💀%s\n", colorNeonRed, err, colorReset)
+	}
+}
+
+// eventJSON is a pipeline event as --output json prints it
+type eventJSON struct {
+	Time     time.Time      `json:"time"`
+	Event    string         `json:"event"`
+	Feed     string         `json:"feed,omitempty"`
+	Item     *models.Item   `json:"item,omitempty"`
+	Reason   string         `json:"reason,omitempty"`
+	Error    string         `json:"error,omitempty"`
+	InfoHash string         `json:"infohash,omitempty"`
+	Size     int64          `json:"size,omitempty"`
+	Media    *metadata.Info `json:"media,omitempty"`
+}
+
+func printEventJSON(e pipeline.Event) {
+	out := eventJSON{
+		Time:     time.Now(),
+		Event:    e.Kind.String(),
+		Feed:     e.Feed,
+		Reason:   e.Reason,
+		InfoHash: e.InfoHash,
+		Size:     e.Size,
+		Media:    e.Media,
+	}
+	if e.Item.Title != "" || e.Item.Link != "" {
+		out.Item = &e.Item
+	}
+	if e.Err != nil {
+		out.Error = e.Err.Error()
+	}
+	printJSON(out)
+}
+
+// pollJSON is the outcome of polling a feed as --output json prints it
+type pollJSON struct {
+	Time    time.Time `json:"time"`
+	Event   string    `json:"event"` // Always "poll"
+	Feed    string    `json:"feed"`
+	Status  string    `json:"status"` // ok, unchanged, cooling_down or failed
+	Matches int       `json:"matches"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// printPollJSON prints the outcome of a poll that found matches and
+// returned err
+func printPollJSON(feed string, matches int, err error) {
+	out := pollJSON{Time: time.Now(), Event: "poll", Feed: feed, Status: "failed", Matches: matches}
+	switch {
+	case err == nil:
+		out.Status = "ok"
+	case errors.Is(err, parser.ErrNotModified):
+		out.Status = "unchanged"
+	case errors.Is(err, pipeline.ErrCoolingDown):
+		out.Status, out.Error = "cooling_down", err.Error()
+	default:
+		out.Error = err.Error()
+	}
+	printJSON(out)
+}
+
+// printListJSON prints a list of results, as [] rather than null when
+// there are none
+func printListJSON[T any](items []T) {
+	if items == nil {
+		items = []T{}
+	}
+	printJSON(items)
+}
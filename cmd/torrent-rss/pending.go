@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"torrent-rss/internal/config"
+	"torrent-rss/internal/history"
+)
+
+// runPending handles `torrent-rss pending <list|approve|reject>`, deciding on
+// releases held by feeds in approval mode. While the daemon runs it holds the
+// history, so decide through the API instead.
+func runPending(args []string) int {
+	if len(args) == 0 {
+		fmt.Println("usage: torrent-rss pending <list|approve|reject> [flags] [id]")
+		return 2
+	}
+	cfg := loadConfig()
+
+	switch args[0] {
+	case "list":
+		fs := flag.NewFlagSet("pending list", flag.ExitOnError)
+		feed := fs.String("feed", "", "only list the named feed's releases")
+		all := fs.Bool("all", false, "include approved and rejected releases")
+		fs.Parse(args[1:])
+
+		store, err := cfg.OpenHistory()
+		if err != nil {
+			log.Fatalf("%s💀 Error opening history: %v 💀%s", colorNeonRed, err, colorReset)
+		}
+		defer store.Close()
+
+		status := history.PendingWaiting
+		if *all {
+			status = ""
+		}
+		items, err := store.PendingItems(*feed, status)
+		if err != nil {
+			log.Fatalf("%s💀 %v 💀%s", colorNeonRed, err, colorReset)
+		}
+		if jsonOutput {
+			printListJSON(items)
+			return 0
+		}
+		if len(items) == 0 {
+			fmt.Printf("%s🚫 Nothing awaiting approval 🚫%s\n", colorNeonRed, colorReset)
+			return 0
+		}
+		for _, item := range items {
+			fmt.Printf("%s%s%s  %s%s%s  %s[%s]%s %s%s%s",
+				colorGray, item.Added.Format("2006-01-02 15:04"), colorReset,
+				colorNeonYellow, item.ID, colorReset,
+				colorNeonPink, item.Feed, colorReset,
+				colorNeonGreen, item.Item.Title, colorReset)
+			if item.Status != history.PendingWaiting {
+				fmt.Printf(" %s(%s)%s", colorGray, item.Status, colorReset)
+			}
+			fmt.Println()
+		}
+		fmt.Printf("\n%s⚡️Total pending: %s%d%s ⚡️\n", colorNeonYellow, colorNeonBlue, len(items), colorReset)
+		return 0
+
+	case "approve", "reject":
+		if len(args) != 2 {
+			fmt.Printf("usage: torrent-rss pending %s <id>\n", args[0])
+			return 2
+		}
+		return decidePending(cfg, args[0], args[1])
+
+	default:
+		fmt.Printf("unknown pending command %q\n", args[0])
+		return 2
+	}
+}
+
+// decidePending approves and grabs, or rejects, a single pending release
+func decidePending(cfg *config.Config, decision, id string) int {
+	a := newApp(cfg)
+	defer a.Close()
+
+	pending, err := a.store.Pending(id)
+	if err != nil {
+		log.Fatalf("%s💀 %v 💀%s", colorNeonRed, err, colorReset)
+	}
+	if pending == nil {
+		log.Fatalf("%s💀 No pending release %q 💀%s", colorNeonRed, id, colorReset)
+	}
+	feed, ok := cfg.Feed(pending.Feed)
+	if !ok {
+		log.Fatalf("%s💀 Unknown feed %q 💀%s", colorNeonRed, pending.Feed, colorReset)
+	}
+
+	if decision == "reject" {
+		if err := a.pipe.Reject(feed, id); err != nil {
+			log.Fatalf("%s💀 %v 💀%s", colorNeonRed, err, colorReset)
+		}
+		if jsonOutput {
+			printDecisionJSON(a.store, id)
+			return 0
+		}
+		fmt.Printf("%s🧹 Rejected: %s%s\n", colorNeonYellow, pending.Item.Title, colorReset)
+		return 0
+	}
+
+	err = a.pipe.Approve(context.Background(), feed, id)
+	a.notifier.Flush(context.Background())
+	if err != nil {
+		fmt.Printf("%s💀 %v 💀%s\n", colorNeonRed, err, colorReset)
+		return 1
+	}
+	if jsonOutput {
+		printDecisionJSON(a.store, id)
+	}
+	return 0
+}
+
+// printDecisionJSON prints a pending release as it is after a decision,
+// like the API responds
+func printDecisionJSON(store *history.Store, id string) {
+	pending, err := store.Pending(id)
+	if err != nil {
+		log.Fatalf("%s💀 %v 💀%s", colorNeonRed, err, colorReset)
+	}
+	printJSON(pending)
+}
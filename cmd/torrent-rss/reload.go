@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+
+	"torrent-rss/internal/api"
+	"torrent-rss/internal/config"
+	"torrent-rss/internal/daemon"
+	"torrent-rss/internal/pipeline"
+	"torrent-rss/internal/systemd"
+
+	"github.com/joho/godotenv"
+)
+
+// configCheckInterval is how often the daemon looks for changes to its
+// config file
+const configCheckInterval = 5 * time.Second
+
+// inheritedEnv holds the variables set before .env was read, which .env
+// doesn't override, and dotenvKeys those .env set
+var (
+	inheritedEnv = make(map[string]bool)
+	dotenvKeys   = make(map[string]bool)
+)
+
+// reloader re-reads the config of a running daemon on SIGHUP or when the
+// file changes, and swaps in a pipeline built from it. Polls in progress
+// finish with the pipeline they started with, which shares its locks,
+// cool-downs and pacing with the new one.
+type reloader struct {
+	path   string // Config file, or .env when configured through the environment
+	app    *app
+	daemon *daemon.Daemon
+	api    *api.Server // Nil without the API
+
+	// reloading serializes reloads, which the API starts too
+	reloading sync.Mutex
+	modified  time.Time
+
+	mu   sync.RWMutex
+	cfg  *config.Config
+	pipe *pipeline.Pipeline
+}
+
+func newReloader(a *app) *reloader {
+	path := os.Getenv("TD_CONFIG")
+	if path == "" {
+		path = ".env"
+	}
+	r := &reloader{path: path, app: a, cfg: a.cfg, pipe: a.pipe}
+	if info, err := os.Stat(path); err == nil {
+		r.modified = info.ModTime()
+	}
+	return r
+}
+
+// pipeline is the pipeline new polls should use
+func (r *reloader) pipeline() *pipeline.Pipeline {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.pipe
+}
+
+// Run reloads on SIGHUP and on changes to the config file until ctx is done
+func (r *reloader) Run(ctx context.Context) {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	defer signal.Stop(hangups)
+
+	ticker := time.NewTicker(configCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangups:
+			r.reload()
+		case <-ticker.C:
+			r.reloadIfChanged()
+		}
+	}
+}
+
+// reloadIfChanged reloads when the config file changed since it was last
+// read
+func (r *reloader) reloadIfChanged() {
+	r.reloading.Lock()
+	defer r.reloading.Unlock()
+	info, err := os.Stat(r.path)
+	if err != nil || info.ModTime().Equal(r.modified) {
+		return
+	}
+	fmt.Printf("%s🔄 %s changed%s\n", colorNeonBlue, r.path, colorReset)
+	r.apply()
+}
+
+// reload applies the config as it is now, or keeps the running one if it's
+// invalid
+func (r *reloader) reload() error {
+	r.reloading.Lock()
+	defer r.reloading.Unlock()
+	return r.apply()
+}
+
+func (r *reloader) apply() error {
+	systemd.Reloading()
+	defer systemd.Ready()
+
+	// The file as it is now is applied, however it turns out
+	if info, err := os.Stat(r.path); err == nil {
+		r.modified = info.ModTime()
+	}
+	cfg, err := r.load()
+	if err != nil {
+		fmt.Printf("%s💀 Not reloading, the config is invalid: %v 💀%s\n", colorNeonRed, err, colorReset)
+		return err
+	}
+	pipe, err := newPipeline(cfg, r.app.store, r.app.notifier, r.app.jar, r.pipeline())
+	if err != nil {
+		fmt.Printf("%s💀 Not reloading: %v 💀%s\n", colorNeonRed, err, colorReset)
+		return err
+	}
+
+	r.mu.Lock()
+	old := r.cfg
+	r.cfg, r.pipe = cfg, pipe
+	r.mu.Unlock()
+
+	r.daemon.Update(cfg.FeedsByPriority())
+	if r.api != nil {
+		r.api.Reload(cfg, pipe)
+	}
+	for _, setting := range restartSettings(old, cfg) {
+		fmt.Printf("%s⚠️  %s changed, restart the daemon to apply it%s\n", colorNeonYellow, setting, colorReset)
+	}
+	systemd.Status(fmt.Sprintf("Polling %d feed(s)", len(cfg.Feeds)))
+	fmt.Printf("%s🔄 Reloaded config, polling %s%d%s feed(s)%s\n", colorNeonBlue, colorNeonPink, len(cfg.Feeds), colorNeonBlue, colorReset)
+	return nil
+}
+
+// load reads the config file, or .env and the environment
+func (r *reloader) load() (cfg *config.Config, err error) {
+	if path := os.Getenv("TD_CONFIG"); path != "" {
+		return config.Load(path)
+	}
+	if err := reloadDotenv(); err != nil {
+		return nil, err
+	}
+	// NewConfig panics on invalid variables, which must not stop the daemon
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("%v", p)
+		}
+	}()
+	return config.NewConfig(), nil
+}
+
+// reloadDotenv applies the current .env, leaving variables that were set
+// before it alone like at start-up
+func reloadDotenv() error {
+	values, err := godotenv.Read()
+	if os.IsNotExist(err) {
+		values = nil
+	} else if err != nil {
+		return fmt.Errorf("failed to read .env: %w", err)
+	}
+	for key := range dotenvKeys {
+		if _, ok := values[key]; !ok {
+			os.Unsetenv(key)
+			delete(dotenvKeys, key)
+		}
+	}
+	for key, value := range values {
+		if inheritedEnv[key] {
+			continue
+		}
+		os.Setenv(key, value)
+		dotenvKeys[key] = true
+	}
+	return nil
+}
+
+// restartSettings lists what changed that a running daemon can't pick up
+func restartSettings(old, cfg *config.Config) []string {
+	var changed []string
+	if old.StateDir != cfg.StateDir || old.StateBackend != cfg.StateBackend {
+		changed = append(changed, "The state directory or backend")
+	}
+	if old.APIAddr != cfg.APIAddr {
+		changed = append(changed, "The API address")
+	}
+	if old.Workers != cfg.Workers {
+		changed = append(changed, "The number of workers")
+	}
+	if old.PollJitter != cfg.PollJitter {
+		changed = append(changed, "The poll jitter")
+	}
+	if old.CompletionCheck != cfg.CompletionCheck {
+		changed = append(changed, "The completion check interval")
+	}
+	if !reflect.DeepEqual(old.Notifiers, cfg.Notifiers) {
+		changed = append(changed, "The notifiers")
+	}
+	if old.CookieKey != cfg.CookieKey {
+		changed = append(changed, "The cookie key")
+	}
+	return changed
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"torrent-rss/internal/config"
+)
+
+// runRetryFailed handles `torrent-rss retry-failed [--feed name]`, trying
+// failed downloads again right away, e.g. after fixing credentials
+func runRetryFailed(args []string) int {
+	flags := flag.NewFlagSet("retry-failed", flag.ExitOnError)
+	only := flags.String("feed", "", "retry only the named feed's failed downloads")
+	flags.Parse(args)
+
+	cfg := loadConfig()
+	feeds := cfg.Feeds
+	if *only != "" {
+		feed, ok := cfg.Feed(*only)
+		if !ok {
+			log.Fatalf("%s💀 Unknown feed %q 💀%s", colorNeonRed, *only, colorReset)
+		}
+		feeds = []config.Feed{feed}
+	}
+
+	a := newApp(cfg)
+	defer a.Close()
+
+	exitCode := 0
+	total := 0
+	for _, feed := range feeds {
+		retried, err := a.pipe.RetryFailed(context.Background(), feed)
+		if err != nil {
+			fmt.Printf("%s💀 %v 💀%s\n", colorNeonRed, err, colorReset)
+			exitCode = 1
+		}
+		total += retried
+	}
+	a.notifier.Flush(context.Background())
+
+	if jsonOutput {
+		printJSON(map[string]int{"retried": total})
+		return exitCode
+	}
+	fmt.Printf("\n%s⚡️Retried: %s%d%s ⚡️\n", colorNeonYellow, colorNeonBlue, total, colorReset)
+	return exitCode
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"torrent-rss/internal/bytesize"
+	"torrent-rss/internal/config"
+	"torrent-rss/internal/models"
+)
+
+// searchResult is a result as `search --output json` prints it
+type searchResult struct {
+	models.Item
+	ContentType string `json:"content_type,omitempty"`
+	Filtered    string `json:"filtered,omitempty"`
+	Downloaded  bool   `json:"downloaded"`
+}
+
+// runSearch handles `torrent-rss search [--feed name] <query>`, listing what
+// the feed's tracker finds, and `torrent-rss search --watchlist [--feed name]`,
+// grabbing what it finds for the watch-list
+func runSearch(args []string) int {
+	flags := flag.NewFlagSet("search", flag.ExitOnError)
+	name := flags.String("feed", "", "feed whose tracker and filters to use, may be left out when there's only one")
+	watched := flags.Bool("watchlist", false, "search for every watch-list title and grab what the feed's filters match")
+	flags.Parse(args)
+	query := strings.Join(flags.Args(), " ")
+	if (query == "") == !*watched {
+		fmt.Println("usage: torrent-rss search [--feed name] <query>\n       torrent-rss search --watchlist [--feed name]")
+		return 2
+	}
+
+	cfg := loadConfig()
+	var feed config.Feed
+	switch {
+	case *name != "":
+		var ok bool
+		if feed, ok = cfg.Feed(*name); !ok {
+			log.Fatalf("%s💀 Unknown feed %q 💀%s", colorNeonRed, *name, colorReset)
+		}
+	case len(cfg.Feeds) == 1:
+		feed = cfg.Feeds[0]
+	default:
+		log.Fatalf("%s💀 Pick the feed to search with --feed 💀%s", colorNeonRed, colorReset)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	a := newApp(cfg)
+	defer a.Close()
+
+	if *watched {
+		return searchWatchlist(ctx, a, feed)
+	}
+
+	results, err := a.pipe.Search(ctx, feed, query)
+	if err != nil {
+		fmt.Printf("%s💀 %v 💀%s\n", colorNeonRed, err, colorReset)
+		return 1
+	}
+	if jsonOutput {
+		out := make([]searchResult, 0, len(results))
+		for _, r := range results {
+			out = append(out, searchResult{Item: r.Item, ContentType: r.ContentType, Filtered: r.Filtered, Downloaded: r.Downloaded})
+		}
+		printJSON(out)
+		return 0
+	}
+	if len(results) == 0 {
+		fmt.Printf("%s🚫 Nothing found for %q 🚫%s\n", colorNeonRed, query, colorReset)
+		return 0
+	}
+	for _, r := range results {
+		fmt.Printf("%s%s%s  %s%s%s\n", colorGray, r.Item.PubDate.Format(time.DateTime), colorReset, colorNeonGreen, r.Item.Title, colorReset)
+		var details string
+		if r.Item.Size > 0 {
+			details += bytesize.Format(r.Item.Size) + "  "
+		}
+		if r.Item.Freeleech {
+			details += "freeleech  "
+		}
+		if r.ContentType != "" {
+			details += r.ContentType + "  "
+		}
+		switch {
+		case r.Downloaded:
+			details += "downloaded  "
+		case r.Filtered != "":
+			details += fmt.Sprintf("filtered (%s)  ", r.Filtered)
+		default:
+			details += "matches  "
+		}
+		fmt.Printf("%s                     %s%s%s\n", colorGray, details, r.Item.Link, colorReset)
+	}
+	fmt.Printf("\n%s⚡️Total results: %s%d%s ⚡️\n", colorNeonYellow, colorNeonBlue, len(results), colorReset)
+	return 0
+}
+
+// searchWatchlist grabs what searching the feed's tracker for the
+// watch-list finds
+func searchWatchlist(ctx context.Context, a *app, feed config.Feed) int {
+	fmt.Printf("%s🔎 Searching %s%s%s for the watch-list...%s\n\n", colorNeonBlue, colorNeonPink, feed.Tracker, colorNeonBlue, colorReset)
+	found, err := a.pipe.SearchWatchlist(ctx, feed)
+	a.notifier.Flush(context.Background())
+
+	exitCode := 0
+	if err != nil {
+		fmt.Printf("%s💀 %v 💀%s\n", colorNeonRed, err, colorReset)
+		exitCode = 1
+	}
+	if jsonOutput {
+		printJSON(map[string]any{"feed": feed.Name, "results": found})
+		return exitCode
+	}
+	fmt.Printf("\n%s⚡️Searched for the watch-list, %s%d%s result(s) on %s ⚡️%s\n", colorNeonYellow, colorNeonBlue, found, colorNeonYellow, feed.Tracker, colorReset)
+	return exitCode
+}
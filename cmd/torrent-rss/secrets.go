@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"torrent-rss/internal/secrets"
+)
+
+// runSecrets handles `torrent-rss secrets <encrypt|decrypt> [value]`, turning
+// credentials into enc: values for the config file and back. Without a value
+// it's read from stdin, so it stays out of the shell history.
+func runSecrets(args []string) int {
+	if len(args) == 0 || (args[0] != "encrypt" && args[0] != "decrypt") {
+		fmt.Println("usage: torrent-rss secrets <encrypt|decrypt> [value]")
+		return 2
+	}
+
+	key, err := secrets.MasterKey()
+	if err != nil {
+		fmt.Printf("%s💀 %v 💀%s\n", colorNeonRed, err, colorReset)
+		return 1
+	}
+	if key == "" {
+		fmt.Printf("%s💀 No master key: set TD_SECRET_KEY or store one in the keyring as torrent-rss/%s 💀%s\n", colorNeonRed, secrets.KeyringAccount, colorReset)
+		return 1
+	}
+	box := secrets.New(key)
+
+	value := strings.Join(args[1:], " ")
+	if value == "" {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			fmt.Printf("%s💀 Nothing to %s on stdin 💀%s\n", colorNeonRed, args[0], colorReset)
+			return 1
+		}
+		value = strings.TrimRight(line, "\r\n")
+	}
+
+	var out string
+	if args[0] == "encrypt" {
+		out, err = box.EncryptString(value)
+	} else {
+		out, err = box.DecryptString(value)
+	}
+	if err != nil {
+		fmt.Printf("%s💀 %v 💀%s\n", colorNeonRed, err, colorReset)
+		return 1
+	}
+	if jsonOutput {
+		printJSON(map[string]string{"value": out})
+		return 0
+	}
+	fmt.Println(out)
+	return 0
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"torrent-rss/internal/service"
+)
+
+// runService handles `torrent-rss service <install|uninstall|start|stop>`,
+// running the daemon in the background under the Windows service manager
+// or macOS launchd
+func runService(args []string) int {
+	if len(args) != 1 {
+		fmt.Println("usage: torrent-rss service <install|uninstall|start|stop>")
+		return 2
+	}
+
+	var done string
+	var err error
+	switch args[0] {
+	case "install":
+		done, err = "Installed", installService()
+	case "uninstall":
+		done, err = "Uninstalled", service.Uninstall()
+	case "start":
+		done, err = "Started", service.Start()
+	case "stop":
+		done, err = "Stopped", service.Stop()
+	default:
+		fmt.Printf("unknown service command %q\n", args[0])
+		return 2
+	}
+	if errors.Is(err, service.ErrNotInstalled) {
+		err = fmt.Errorf("%w, run torrent-rss service install first", err)
+	}
+	if err != nil {
+		fmt.Printf("%s💀 %v 💀%s\n", colorNeonRed, err, colorReset)
+		return 1
+	}
+
+	if jsonOutput {
+		printJSON(map[string]string{"service": service.Name, "status": strings.ToLower(done)})
+		return 0
+	}
+	fmt.Printf("%s✅ %s service %s%s%s\n", colorNeonGreen, done, colorNeonBlue, service.Name, colorReset)
+	if args[0] == "install" {
+		fmt.Printf("%s   Start it with: torrent-rss service start%s\n", colorGray, colorReset)
+	}
+	return 0
+}
+
+// installService installs the daemon as a service working in the current
+// directory, so it reads the same .env. The config is checked first, a
+// broken one would only show in the service's log.
+func installService() error {
+	loadConfig()
+
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find the torrent-rss binary: %w", err)
+	}
+	if executable, err = filepath.EvalSymlinks(executable); err != nil {
+		return fmt.Errorf("failed to find the torrent-rss binary: %w", err)
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	env := make(map[string]string)
+	if path := os.Getenv("TD_CONFIG"); path != "" {
+		if env["TD_CONFIG"], err = filepath.Abs(path); err != nil {
+			return err
+		}
+	}
+
+	// The service sees .env, but not the variables of this shell
+	var unseen []string
+	for key := range inheritedEnv {
+		if strings.HasPrefix(key, "TD_") && key != "TD_CONFIG" {
+			unseen = append(unseen, key)
+		}
+	}
+	if len(unseen) > 0 {
+		sort.Strings(unseen)
+		fmt.Printf("%s⚠️  Not passed to the service, move them to .env: %s%s\n", colorNeonYellow, strings.Join(unseen, ", "), colorReset)
+	}
+
+	return service.Install(service.Config{
+		Executable: executable,
+		Args:       []string{"daemon"},
+		Dir:        dir,
+		Env:        env,
+	})
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"torrent-rss/internal/history"
+)
+
+// runStats handles `torrent-rss stats`, showing what each feed's polls did
+func runStats(args []string) int {
+	cfg := loadConfig()
+	store, err := cfg.OpenHistory()
+	if err != nil {
+		log.Fatalf("%s💀 Error opening history: %v 💀%s", colorNeonRed, err, colorReset)
+	}
+	defer store.Close()
+
+	all, err := store.Stats()
+	if err != nil {
+		log.Fatalf("%s💀 %v 💀%s", colorNeonRed, err, colorReset)
+	}
+	byFeed := make(map[string]history.FeedStats, len(all))
+	for _, stats := range all {
+		byFeed[stats.Feed] = stats
+	}
+
+	if jsonOutput {
+		// Every configured feed, with zeros for those never polled
+		feeds := make([]history.FeedStats, 0, len(cfg.Feeds))
+		for _, feed := range cfg.Feeds {
+			stats := byFeed[feed.Name]
+			stats.Feed = feed.Name
+			feeds = append(feeds, stats)
+		}
+		printJSON(feeds)
+		return 0
+	}
+
+	fmt.Printf("%s%-16s %8s %8s %10s %8s %6s  %-16s %s%s\n", colorNeonBlue,
+		"FEED", "SEEN", "MATCHED", "DOWNLOADED", "FILTERED", "FAILED", "LAST POLL", "AVG RESOLVE", colorReset)
+	for _, feed := range cfg.Feeds {
+		stats := byFeed[feed.Name]
+		lastPoll := "never"
+		if !stats.LastPoll.IsZero() {
+			lastPoll = stats.LastPoll.Local().Format("2006-01-02 15:04")
+		}
+		avg := "-"
+		if stats.Resolved > 0 {
+			avg = stats.AvgResolve().Round(time.Millisecond).String()
+		}
+		fmt.Printf("%s%-16s%s %8d %8d %s%10d%s %8d %s%6d%s  %s%-16s %s%s\n",
+			colorNeonPink, feed.Name, colorReset,
+			stats.Seen, stats.Matched,
+			colorNeonGreen, stats.Downloaded, colorReset,
+			stats.Filtered,
+			colorNeonRed, stats.Failed, colorReset,
+			colorGray, lastPoll, avg, colorReset)
+	}
+	return 0
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"torrent-rss/internal/bytesize"
+	"torrent-rss/internal/config"
+	"torrent-rss/internal/models"
+)
+
+// runTestFeed handles `torrent-rss test-feed [--feed name] [url]`. It
+// fetches a feed and lists what it holds, so a new feed can be checked
+// without downloading anything or touching the history.
+func runTestFeed(args []string) int {
+	flags := flag.NewFlagSet("test-feed", flag.ExitOnError)
+	name := flags.String("feed", "", "apply the search terms, filter and torznab settings of this configured feed")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 && *name == "" {
+		fmt.Println("usage: torrent-rss test-feed [--feed name] [url]")
+		return 2
+	}
+
+	cfg := loadConfig()
+	var feed config.Feed
+	if *name != "" {
+		var ok bool
+		if feed, ok = cfg.Feed(*name); !ok {
+			log.Fatalf("%s💀 Unknown feed %q 💀%s", colorNeonRed, *name, colorReset)
+		}
+	}
+	if flags.NArg() > 0 {
+		feed.URL = flags.Arg(0)
+	}
+
+	net, err := newNetwork(cfg)
+	if err != nil {
+		log.Fatalf("%s💀 %v 💀%s", colorNeonRed, err, colorReset)
+	}
+	p := net.parser
+	var items []models.Item
+	if feed.Torznab != nil {
+		items, err = p.FetchTorznab(context.Background(), feed.URL, *feed.Torznab, feed.SearchTerms)
+	} else {
+		items, err = p.FetchAndParse(context.Background(), feed.URL, feed.JSONAPI, feed.SearchTerms)
+	}
+	if err != nil {
+		fmt.Printf("%s💀 Error parsing RSS feed: %v 💀%s\n", colorNeonRed, err, colorReset)
+		return 1
+	}
+
+	if jsonOutput {
+		printTestFeedJSON(cfg, feed, items)
+		return 0
+	}
+	if len(items) == 0 {
+		fmt.Printf("%s🚫 No items found! 🚫%s\n", colorNeonRed, colorReset)
+		return 0
+	}
+	for _, item := range items {
+		fmt.Printf("%s%s%s  %s%s%s\n", colorGray, item.PubDate.Format(time.DateTime), colorReset, colorNeonGreen, item.Title, colorReset)
+		var details string
+		if item.Size > 0 {
+			details += bytesize.Format(item.Size) + "  "
+		}
+		if item.Freeleech {
+			details += "freeleech  "
+		}
+		// The categories help write content type rules for the feed
+		if len(item.Categories) > 0 {
+			if t := config.DetectContentType(cfg.ContentTypes, item.Categories); t != nil {
+				details += t.Type.Name + " "
+			}
+			details += "(" + strings.Join(item.Categories, ", ") + ")  "
+		}
+		if ok, rule := feed.Filter.Match(item.Title); !ok {
+			details += fmt.Sprintf("filtered (%s)  ", rule)
+		} else if ok, rule := feed.Formats.Match(item.Title); !ok {
+			details += fmt.Sprintf("filtered (%s)  ", rule)
+		}
+		fmt.Printf("%s                     %s%s%s\n", colorGray, details, item.Link, colorReset)
+	}
+	fmt.Printf("\n%s⚡️Total items: %s%d%s ⚡️\n", colorNeonYellow, colorNeonBlue, len(items), colorReset)
+	return 0
+}
+
+// testFeedItem is an item as `test-feed --output json` prints it, with its
+// content type and the rule of the feed's filter that rejects it, if any
+type testFeedItem struct {
+	models.Item
+	ContentType string `json:"content_type,omitempty"`
+	Filtered    string `json:"filtered,omitempty"`
+}
+
+func printTestFeedJSON(cfg *config.Config, feed config.Feed, items []models.Item) {
+	out := make([]testFeedItem, 0, len(items))
+	for _, item := range items {
+		_, rule := feed.Filter.Match(item.Title)
+		entry := testFeedItem{Item: item, Filtered: rule}
+		if t := config.DetectContentType(cfg.ContentTypes, item.Categories); t != nil {
+			entry.ContentType = t.Type.Name
+		}
+		out = append(out, entry)
+	}
+	printJSON(out)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+
+	"torrent-rss/internal/tui"
+)
+
+// runTUI handles `torrent-rss tui [--addr host:port] [--key key]`, browsing
+// a running daemon's feeds, history and events through its API
+func runTUI(args []string) int {
+	flags := flag.NewFlagSet("tui", flag.ExitOnError)
+	addr := flags.String("addr", "", "API address of the daemon, the configured TD_API_ADDR by default")
+	key := flags.String("key", os.Getenv("TD_API_KEY"), "full-access API key, the configured one with the configured address")
+	flags.Parse(args)
+
+	if *addr == "" {
+		cfg := loadConfig()
+		*addr = cfg.APIAddr
+		if *key == "" {
+			*key = cfg.APIAuth.Key
+		}
+	}
+	if *addr == "" {
+		log.Fatalf("%s💀 The TUI needs the daemon's API: set TD_API_ADDR or pass --addr 💀%s", colorNeonRed, colorReset)
+	}
+	client, err := tui.NewClient(*addr, *key)
+	if err != nil {
+		log.Fatalf("%s💀 %v 💀%s", colorNeonRed, err, colorReset)
+	}
+	if err := tui.Run(context.Background(), client); err != nil {
+		log.Fatalf("%s💀 %v 💀%s", colorNeonRed, err, colorReset)
+	}
+	return 0
+}
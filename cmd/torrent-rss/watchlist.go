@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"torrent-rss/internal/config"
+	"torrent-rss/internal/metadata"
+	"torrent-rss/internal/watchlist"
+)
+
+// runWatchlist handles `torrent-rss watchlist add|remove|list`
+func runWatchlist(cfg *config.Config, args []string) {
+	if len(args) == 0 {
+		fmt.Println("usage: torrent-rss watchlist <add|remove|list> [title]")
+		os.Exit(2)
+	}
+
+	list, err := watchlist.Open(cfg.WatchlistPath())
+	if err != nil {
+		log.Fatalf("%s💀 %v 💀%s", colorNeonRed, err, colorReset)
+	}
+
+	switch args[0] {
+	case "add":
+		title, year := watchlistTitle(args)
+		media := lookupTitle(cfg, title, year)
+		entry, err := list.Add(title, year, media)
+		if err != nil {
+			log.Fatalf("%s💀 %v 💀%s", colorNeonRed, err, colorReset)
+		}
+		if jsonOutput {
+			printJSON(entry)
+			return
+		}
+		fmt.Printf("%s👀 Watching %s%s%s\n", colorNeonGreen, colorNeonPink, entry, colorReset)
+		if media != nil {
+			fmt.Printf("%s   matched by %s as %s (%s)%s\n", colorGray, cfg.MetadataProvider, media, media.IDs(), colorReset)
+		}
+
+	case "remove":
+		title, year := watchlistTitle(args)
+		removed, err := list.Remove(title, year)
+		if err != nil {
+			log.Fatalf("%s💀 %v 💀%s", colorNeonRed, err, colorReset)
+		}
+		if len(removed) == 0 {
+			log.Fatalf("%s💀 %s is not on the watch-list 💀%s", colorNeonRed, title, colorReset)
+		}
+		if jsonOutput {
+			printJSON(removed)
+			return
+		}
+		for _, entry := range removed {
+			fmt.Printf("%s🗑️  No longer watching %s%s%s\n", colorNeonYellow, colorNeonPink, entry, colorReset)
+		}
+
+	case "list":
+		entries, err := list.Entries()
+		if err != nil {
+			log.Fatalf("%s💀 %v 💀%s", colorNeonRed, err, colorReset)
+		}
+		if jsonOutput {
+			printListJSON(entries)
+			return
+		}
+		if len(entries) == 0 {
+			fmt.Printf("%s🚫 Watch-list is empty 🚫%s\n", colorNeonRed, colorReset)
+			return
+		}
+		for _, entry := range entries {
+			var ids string
+			if entry.Media != nil {
+				ids = "  " + entry.Media.IDs()
+			}
+			fmt.Printf("%s%-40s%s %sadded %s%s%s\n",
+				colorNeonPink, entry, colorReset,
+				colorGray, entry.AddedAt.Format("2006-01-02"), ids, colorReset)
+		}
+		fmt.Printf("\n%s⚡️Total titles: %s%d%s ⚡️\n", colorNeonYellow, colorNeonBlue, len(entries), colorReset)
+
+	default:
+		fmt.Printf("unknown watchlist command %q\n", args[0])
+		os.Exit(2)
+	}
+}
+
+// lookupTitle finds a title on the metadata provider, so releases can be
+// matched against it by ID. Without a provider, or if the lookup fails, the
+// title is matched by name alone.
+func lookupTitle(cfg *config.Config, title string, year int) *metadata.Info {
+	provider := newMetadata(cfg)
+	if provider == nil {
+		return nil
+	}
+	media, err := provider.Lookup(context.Background(), metadata.Query{Title: title, Year: year})
+	if err != nil {
+		fmt.Printf("%s⚠️  Lookup failed, matching by name: %v%s\n", colorNeonYellow, err, colorReset)
+		return nil
+	}
+	if media == nil {
+		fmt.Printf("%s⚠️  %s doesn't know %s, matching by name%s\n", colorNeonYellow, cfg.MetadataProvider, title, colorReset)
+	}
+	return media
+}
+
+// watchlistTitle joins the remaining arguments, so titles don't need quoting
+func watchlistTitle(args []string) (string, int) {
+	if len(args) < 2 {
+		fmt.Printf("usage: torrent-rss watchlist %s <title> [year]\n", args[0])
+		os.Exit(2)
+	}
+	return watchlist.ParseTitle(strings.Join(args[1:], " "))
+}
@@ -0,0 +1,688 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"torrent-rss/internal/config"
+	"torrent-rss/internal/daemon"
+	"torrent-rss/internal/history"
+	"torrent-rss/internal/httpx"
+	"torrent-rss/internal/magnet"
+	"torrent-rss/internal/models"
+	"torrent-rss/internal/pipeline"
+)
+
+// Server exposes feeds, history and manual grabs over HTTP for other tools
+type Server struct {
+	history *history.Store
+	daemon  *daemon.Daemon
+	metrics *httpx.Metrics
+	events  *Events
+
+	// configFile is where feeds added through the API are saved, empty
+	// when configured through the environment
+	configFile string
+	reload     func() error
+	editing    sync.Mutex
+
+	mu   sync.RWMutex
+	cfg  *config.Config
+	pipe *pipeline.Pipeline
+}
+
+func New(cfg *config.Config, pipe *pipeline.Pipeline, store *history.Store) *Server {
+	return &Server{cfg: cfg, pipe: pipe, history: store}
+}
+
+// Reload switches to a reloaded config and the pipeline built from it.
+// Requests already being handled finish with the old ones.
+func (s *Server) Reload(cfg *config.Config, pipe *pipeline.Pipeline) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cfg, s.pipe = cfg, pipe
+}
+
+// current returns the config and pipeline a request works with
+func (s *Server) current() (*config.Config, *pipeline.Pipeline) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg, s.pipe
+}
+
+// UseDaemon lets /healthz report on the daemon's feed polling
+func (s *Server) UseDaemon(d *daemon.Daemon) {
+	s.daemon = d
+}
+
+// UseMetrics lets /api/v1/requests report on the requests to trackers
+func (s *Server) UseMetrics(m *httpx.Metrics) {
+	s.metrics = m
+}
+
+// UseEvents lets /api/v1/events stream the pipeline's events
+func (s *Server) UseEvents(ev *Events) {
+	s.events = ev
+}
+
+// UseConfigFile lets the API add, edit and remove the feeds of the config
+// file at path, applying each change with reload
+func (s *Server) UseConfigFile(path string, reload func() error) {
+	s.configFile, s.reload = path, reload
+}
+
+// Handler routes the /api/v1 endpoints and /healthz, asking for the
+// configured credentials
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", s.healthz)
+	mux.HandleFunc("GET /api/v1/feeds", s.listFeeds)
+	mux.HandleFunc("POST /api/v1/feeds", s.addFeed)
+	mux.HandleFunc("PUT /api/v1/feeds/{name}", s.updateFeed)
+	mux.HandleFunc("DELETE /api/v1/feeds/{name}", s.removeFeed)
+	mux.HandleFunc("GET /api/v1/feeds/{name}/items", s.listItems)
+	mux.HandleFunc("GET /api/v1/events", s.streamEvents)
+	mux.HandleFunc("GET /api/v1/history", s.listHistory)
+	mux.HandleFunc("POST /api/v1/grab", s.grab)
+	mux.HandleFunc("GET /api/v1/failed", s.listFailed)
+	mux.HandleFunc("POST /api/v1/failed/retry", s.retryFailed)
+	mux.HandleFunc("GET /api/v1/pending", s.listPending)
+	mux.HandleFunc("GET /api/v1/stats", s.listStats)
+	mux.HandleFunc("GET /api/v1/missing", s.listMissing)
+	mux.HandleFunc("GET /api/v1/requests", s.listRequests)
+	mux.HandleFunc("POST /api/v1/pending/{id}/approve", s.approvePending)
+	mux.HandleFunc("POST /api/v1/pending/{id}/reject", s.rejectPending)
+	return s.authorize(mux)
+}
+
+// ListenAndServe serves the API on addr until ctx is done
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		// Ends event streams when the daemon stops, rather than waiting for
+		// their clients to hang up
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+
+	errc := make(chan error, 1)
+	go func() { errc <- srv.ListenAndServe() }()
+
+	select {
+	case err := <-errc:
+		return fmt.Errorf("API server failed: %w", err)
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return srv.Shutdown(shutdownCtx)
+	}
+}
+
+type healthJSON struct {
+	Status string           `json:"status"`
+	Error  string           `json:"error,omitempty"`
+	Feeds  []feedHealthJSON `json:"feeds,omitempty"`
+}
+
+type feedHealthJSON struct {
+	Name         string     `json:"name"`
+	LastPoll     *time.Time `json:"last_poll,omitempty"`
+	PollingSince *time.Time `json:"polling_since,omitempty"`
+}
+
+// healthz answers 200 while the daemon is making progress and 503 once a poll
+// has hung, for load balancers, container health checks and monitoring
+func (s *Server) healthz(w http.ResponseWriter, r *http.Request) {
+	health := healthJSON{Status: "ok"}
+	if s.daemon == nil {
+		writeJSON(w, http.StatusOK, health)
+		return
+	}
+
+	for _, status := range s.daemon.Status() {
+		f := feedHealthJSON{Name: status.Feed}
+		if !status.LastPoll.IsZero() {
+			f.LastPoll = &status.LastPoll
+		}
+		if !status.PollingSince.IsZero() {
+			f.PollingSince = &status.PollingSince
+		}
+		health.Feeds = append(health.Feeds, f)
+	}
+
+	if err := s.daemon.Check(); err != nil {
+		health.Status, health.Error = "unhealthy", err.Error()
+		writeJSON(w, http.StatusServiceUnavailable, health)
+		return
+	}
+	writeJSON(w, http.StatusOK, health)
+}
+
+type feedJSON struct {
+	Name          string   `json:"name"`
+	URL           string   `json:"url"`
+	Tracker       string   `json:"tracker"`
+	Client        string   `json:"client,omitempty"`
+	Delivery      string   `json:"delivery,omitempty"`
+	DownloadPath  string   `json:"download_path,omitempty"`
+	Category      string   `json:"category,omitempty"`
+	SavePath      string   `json:"save_path,omitempty"`
+	SearchTerms   []string `json:"search_terms"`
+	ContentTypes  []string `json:"content_types,omitempty"`
+	Interval      string   `json:"interval"`
+	Schedule      string   `json:"schedule,omitempty"`
+	Include       []string `json:"include,omitempty"`
+	Exclude       []string `json:"exclude,omitempty"`
+	PreferGroups  []string `json:"prefer_groups,omitempty"`
+	BanGroups     []string `json:"ban_groups,omitempty"`
+	Formats       []string `json:"formats,omitempty"`
+	MinSize       int64    `json:"min_size,omitempty"`
+	MaxSize       int64    `json:"max_size,omitempty"`
+	FreeleechOnly bool     `json:"freeleech_only"`
+	Watchlist     bool     `json:"watchlist"`
+	TrackEpisodes bool     `json:"track_episodes"`
+	Quality       []string `json:"quality,omitempty"`
+	Scoring       bool     `json:"scoring"`
+	ReplaceProper bool     `json:"replace_propers"`
+	Torznab       bool     `json:"torznab"`
+	JSONAPI       bool     `json:"json_api"`
+	Approval      bool     `json:"approval"`
+	DedupeKey     string   `json:"dedupe_key"`
+	SeasonPacks   string   `json:"season_packs"`
+	Private       bool     `json:"private"`
+	StaleAfter    string   `json:"stale_after,omitempty"`
+	FailingAfter  string   `json:"failing_after,omitempty"`
+	MinRatio      float64  `json:"min_ratio,omitempty"`
+	MinSeedTime   string   `json:"min_seed_time,omitempty"`
+	// WatchSearch is how often the tracker is searched for the watch-list
+	WatchSearch string `json:"watchlist_search,omitempty"`
+}
+
+func (s *Server) listFeeds(w http.ResponseWriter, r *http.Request) {
+	cfg, _ := s.current()
+	feeds := make([]feedJSON, 0, len(cfg.Feeds))
+	for _, feed := range cfg.Feeds {
+		feeds = append(feeds, newFeedJSON(feed))
+	}
+	writeJSON(w, http.StatusOK, feeds)
+}
+
+// newFeedJSON describes a configured feed
+func newFeedJSON(feed config.Feed) feedJSON {
+	f := feedJSON{
+		Name:          feed.Name,
+		URL:           redactURL(feed.URL),
+		Tracker:       feed.Tracker,
+		Client:        feed.Client,
+		Delivery:      feed.Delivery,
+		DownloadPath:  feed.DownloadPath,
+		Category:      feed.Category,
+		SavePath:      feed.SavePath,
+		SearchTerms:   feed.SearchTerms,
+		ContentTypes:  feed.ContentTypes,
+		Interval:      feed.Interval.String(),
+		Schedule:      feed.Schedule.String(),
+		Include:       feed.Filter.Includes(),
+		Exclude:       feed.Filter.Excludes(),
+		PreferGroups:  feed.Groups.Preferred(),
+		BanGroups:     feed.Groups.Banned(),
+		Formats:       feed.Formats.List(),
+		MinSize:       feed.MinSize,
+		MaxSize:       feed.MaxSize,
+		FreeleechOnly: feed.FreeleechOnly,
+		Watchlist:     feed.Watchlist,
+		TrackEpisodes: feed.TrackEpisodes,
+		Scoring:       feed.Scoring != nil,
+		ReplaceProper: feed.ReplacePropers,
+		Torznab:       feed.Torznab != nil,
+		JSONAPI:       feed.JSONAPI != nil,
+		Approval:      feed.Approval,
+		DedupeKey:     string(feed.DedupeKey),
+		SeasonPacks:   string(feed.SeasonPacks),
+		Private:       feed.Private,
+	}
+	if feed.Quality != nil {
+		f.Quality = feed.Quality.Tiers()
+	}
+	if feed.StaleAfter > 0 {
+		f.StaleAfter = feed.StaleAfter.String()
+	}
+	if feed.FailingAfter > 0 {
+		f.FailingAfter = feed.FailingAfter.String()
+	}
+	if feed.WatchlistSearch > 0 {
+		f.WatchSearch = feed.WatchlistSearch.String()
+	}
+	f.MinRatio = feed.Seeding.Ratio
+	if feed.Seeding.Time > 0 {
+		f.MinSeedTime = feed.Seeding.Time.String()
+	}
+	return f
+}
+
+// itemJSON is a feed item as the API shows it
+type itemJSON struct {
+	Title        string     `json:"title"`
+	Link         string     `json:"link"`
+	EnclosureURL string     `json:"enclosure_url,omitempty"`
+	GUID         string     `json:"guid,omitempty"`
+	PubDate      *time.Time `json:"pub_date,omitempty"`
+	Description  string     `json:"description,omitempty"`
+	Size         int64      `json:"size,omitempty"`
+	Freeleech    bool       `json:"freeleech"`
+	InfoHash     string     `json:"infohash,omitempty"`
+	Categories   []string   `json:"categories,omitempty"`
+}
+
+// newItemJSON describes a feed item. Download links often carry a passkey,
+// and on some feeds the item link is one, so without full access the query
+// of both is redacted.
+func newItemJSON(item models.Item, full bool) itemJSON {
+	out := itemJSON{
+		Title:        item.Title,
+		Link:         item.Link,
+		EnclosureURL: item.EnclosureURL,
+		GUID:         item.GUID,
+		Description:  item.Description,
+		Size:         item.Size,
+		Freeleech:    item.Freeleech,
+		InfoHash:     item.InfoHash,
+		Categories:   item.Categories,
+	}
+	if !item.PubDate.IsZero() {
+		out.PubDate = &item.PubDate
+	}
+	if !full {
+		out.Link, out.EnclosureURL = redactLink(out.Link), redactLink(out.EnclosureURL)
+	}
+	return out
+}
+
+// feedItemJSON is a feed item as /api/v1/feeds/{name}/items lists it
+type feedItemJSON struct {
+	itemJSON
+	ContentType string `json:"content_type,omitempty"`
+	Filtered    string `json:"filtered,omitempty"` // Rule of the feed that rejects the item
+	Downloaded  bool   `json:"downloaded"`
+}
+
+// listItems fetches a feed and lists its items with whether the feed's
+// filters match them and whether they were downloaded, without grabbing
+// anything
+func (s *Server) listItems(w http.ResponseWriter, r *http.Request) {
+	cfg, pipe := s.current()
+	feed, ok := cfg.Feed(r.PathValue("name"))
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown feed %q", r.PathValue("name")))
+		return
+	}
+	previews, err := pipe.Preview(r.Context(), feed)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+
+	full := s.fullAccess(r)
+	items := make([]feedItemJSON, 0, len(previews))
+	for _, preview := range previews {
+		items = append(items, feedItemJSON{
+			itemJSON:    newItemJSON(preview.Item, full),
+			ContentType: preview.ContentType,
+			Filtered:    preview.Filtered,
+			Downloaded:  preview.Downloaded,
+		})
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+func (s *Server) listHistory(w http.ResponseWriter, r *http.Request) {
+	entries, err := s.history.List()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if feed := r.URL.Query().Get("feed"); feed != "" {
+		filtered := entries[:0]
+		for _, entry := range entries {
+			if entry.Feed == feed {
+				filtered = append(filtered, entry)
+			}
+		}
+		entries = filtered
+	}
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("limit must be a non-negative number"))
+			return
+		}
+		entries = entries[:min(limit, len(entries))]
+	}
+
+	if entries == nil {
+		entries = []history.Entry{}
+	}
+	if !s.fullAccess(r) {
+		for i := range entries {
+			entries[i].Link = redactLink(entries[i].Link)
+		}
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+func (s *Server) listFailed(w http.ResponseWriter, r *http.Request) {
+	failures, err := s.history.Failures(r.URL.Query().Get("feed"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if failures == nil {
+		failures = []history.Failure{}
+	}
+	if !s.fullAccess(r) {
+		for i := range failures {
+			failures[i].Item = redactItem(failures[i].Item)
+			failures[i].Err = redactText(failures[i].Err)
+		}
+	}
+	writeJSON(w, http.StatusOK, failures)
+}
+
+type retryRequest struct {
+	Feed string `json:"feed"` // Empty retries every feed
+}
+
+type retryResponse struct {
+	Retried int `json:"retried"`
+}
+
+// retryFailed tries failed downloads again, including those given up on,
+// e.g. after fixing a tracker's credentials
+func (s *Server) retryFailed(w http.ResponseWriter, r *http.Request) {
+	var req retryRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+			return
+		}
+	}
+
+	cfg, pipe := s.current()
+	feeds := cfg.Feeds
+	if req.Feed != "" {
+		feed, ok := cfg.Feed(req.Feed)
+		if !ok {
+			writeError(w, http.StatusNotFound, fmt.Errorf("unknown feed %q", req.Feed))
+			return
+		}
+		feeds = []config.Feed{feed}
+	}
+
+	var resp retryResponse
+	for _, feed := range feeds {
+		retried, err := pipe.RetryFailed(r.Context(), feed)
+		resp.Retried += retried
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) listStats(w http.ResponseWriter, r *http.Request) {
+	all, err := s.history.Stats()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if all == nil {
+		all = []history.FeedStats{}
+	}
+	writeJSON(w, http.StatusOK, all)
+}
+
+// listMissing reports the episodes the episode tracker skipped over per show
+func (s *Server) listMissing(w http.ResponseWriter, r *http.Request) {
+	_, pipe := s.current()
+	report, err := pipe.Missing()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if report == nil {
+		report = []pipeline.Missing{}
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+type requestsJSON struct {
+	Host     string    `json:"host"`
+	Requests int64     `json:"requests"`
+	Failures int64     `json:"failures"`
+	AvgMS    int64     `json:"avg_ms"`
+	Last     time.Time `json:"last"`
+}
+
+// listRequests reports how many requests went to each tracker host, how many
+// failed and how long they took
+func (s *Server) listRequests(w http.ResponseWriter, r *http.Request) {
+	hosts := []requestsJSON{}
+	for _, h := range s.metrics.Hosts() {
+		hosts = append(hosts, requestsJSON{
+			Host:     h.Host,
+			Requests: h.Requests,
+			Failures: h.Failures,
+			AvgMS:    (h.Time / time.Duration(h.Requests)).Milliseconds(),
+			Last:     h.Last,
+		})
+	}
+	writeJSON(w, http.StatusOK, hosts)
+}
+
+func (s *Server) listPending(w http.ResponseWriter, r *http.Request) {
+	items, err := s.history.PendingItems(r.URL.Query().Get("feed"), r.URL.Query().Get("status"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if items == nil {
+		items = []history.Pending{}
+	}
+	if !s.fullAccess(r) {
+		for i := range items {
+			items[i].Item = redactItem(items[i].Item)
+		}
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+// approvePending grabs a release held for approval right away
+func (s *Server) approvePending(w http.ResponseWriter, r *http.Request) {
+	cfg, pipe := s.current()
+	pending, feed, ok := s.pendingFeed(w, r, cfg)
+	if !ok {
+		return
+	}
+	if err := pipe.Approve(r.Context(), feed, pending.ID); err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	s.writePending(w, pending.ID)
+}
+
+func (s *Server) rejectPending(w http.ResponseWriter, r *http.Request) {
+	cfg, pipe := s.current()
+	pending, feed, ok := s.pendingFeed(w, r, cfg)
+	if !ok {
+		return
+	}
+	if err := pipe.Reject(feed, pending.ID); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	s.writePending(w, pending.ID)
+}
+
+// pendingFeed looks up the pending item of the request and its feed,
+// writing the error response if either is missing
+func (s *Server) pendingFeed(w http.ResponseWriter, r *http.Request, cfg *config.Config) (*history.Pending, config.Feed, bool) {
+	id := r.PathValue("id")
+	pending, err := s.history.Pending(id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return nil, config.Feed{}, false
+	}
+	if pending == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no pending item %q", id))
+		return nil, config.Feed{}, false
+	}
+	feed, ok := cfg.Feed(pending.Feed)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown feed %q", pending.Feed))
+		return nil, config.Feed{}, false
+	}
+	return pending, feed, true
+}
+
+// writePending responds with the pending item as it is after a decision
+func (s *Server) writePending(w http.ResponseWriter, id string) {
+	pending, err := s.history.Pending(id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, pending)
+}
+
+type grabRequest struct {
+	Feed  string `json:"feed"`
+	Link  string `json:"link"`
+	Title string `json:"title"`
+	GUID  string `json:"guid"`
+	// EnclosureURL is the .torrent of a feed item whose link is its page
+	EnclosureURL string `json:"enclosure_url"`
+}
+
+type grabResponse struct {
+	Name     string `json:"name"`
+	InfoHash string `json:"infohash,omitempty"`
+	Size     int64  `json:"size,omitempty"`
+}
+
+func (s *Server) grab(w http.ResponseWriter, r *http.Request) {
+	var req grabRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	if req.Link == "" {
+		writeError(w, http.StatusBadRequest, errors.New("link is required"))
+		return
+	}
+
+	// The feed decides tracker and destination; with a single feed it's implied
+	cfg, pipe := s.current()
+	feed, ok := cfg.Feed(req.Feed)
+	if req.Feed == "" && len(cfg.Feeds) == 1 {
+		feed, ok = cfg.Feeds[0], true
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown feed %q", req.Feed))
+		return
+	}
+
+	// The links are fetched with the tracker's cookies and passkey, which
+	// mustn't go to any other host
+	for _, link := range []string{req.Link, req.EnclosureURL} {
+		if err := trackerLink(cfg, feed, link); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	item := models.Item{Title: req.Title, Link: req.Link, GUID: req.GUID, EnclosureURL: req.EnclosureURL}
+	if item.Title == "" {
+		item.Title = req.Link
+	}
+
+	torrent, err := pipe.Grab(r.Context(), feed, item)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, grabResponse{Name: torrent.Name, InfoHash: torrent.InfoHash, Size: torrent.Size})
+}
+
+// trackerLink checks that a link to grab is a magnet link or points at one
+// of the hosts the feed's tracker is reached at
+func trackerLink(cfg *config.Config, feed config.Feed, link string) error {
+	if link == "" || magnet.IsMagnet(link) {
+		return nil
+	}
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("%q is neither an http(s) nor a magnet link", link)
+	}
+	if !slices.Contains(cfg.TrackerHosts(feed.Tracker), u.Hostname()) {
+		return fmt.Errorf("%s isn't a host of tracker %s", u.Hostname(), feed.Tracker)
+	}
+	return nil
+}
+
+// redactURL hides the query of feed URLs, which usually carries a passkey
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = "REDACTED"
+	}
+	u.User = nil
+	return u.String()
+}
+
+// redactLink is redactURL for links that may be empty
+func redactLink(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	return redactURL(raw)
+}
+
+// redactItem redacts the links of an item
+func redactItem(item models.Item) models.Item {
+	item.Link, item.EnclosureURL = redactLink(item.Link), redactLink(item.EnclosureURL)
+	return item
+}
+
+// urlPattern finds the URLs in error messages, which may be quoted
+var urlPattern = regexp.MustCompile(`https?://[^\s"'<>]+`)
+
+// redactText redacts the URLs in an error message
+func redactText(text string) string {
+	return urlPattern.ReplaceAllStringFunc(text, redactURL)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"torrent-rss/internal/config"
+	"torrent-rss/internal/models"
+)
+
+func TestItemJSON(t *testing.T) {
+	item := models.Item{
+		Title:        "Show.Name.S01E02.1080p.WEB-DL-GROUP",
+		Link:         "https://tracker.example/torrent.php?id=1",
+		EnclosureURL: "https://tracker.example/download.php/1/file.torrent?torrent_pass=secret",
+		PubDate:      time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Size:         1 << 30,
+	}
+
+	full, err := json.Marshal(newItemJSON(item, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `{"title":"Show.Name.S01E02.1080p.WEB-DL-GROUP","link":"https://tracker.example/torrent.php?id=1",` +
+		`"enclosure_url":"https://tracker.example/download.php/1/file.torrent?torrent_pass=secret",` +
+		`"pub_date":"2024-01-02T03:04:05Z","size":1073741824,"freeleech":false}`
+	if string(full) != want {
+		t.Errorf("full access:\n  %s, want\n  %s", full, want)
+	}
+
+	readOnly := newItemJSON(item, false)
+	if readOnly.EnclosureURL != "https://tracker.example/download.php/1/file.torrent?REDACTED" || readOnly.Link != "https://tracker.example/torrent.php?REDACTED" {
+		t.Errorf("read-only links = %q, %q", readOnly.Link, readOnly.EnclosureURL)
+	}
+	if got := newItemJSON(models.Item{Title: "x"}, false); got.EnclosureURL != "" || got.PubDate != nil {
+		t.Errorf("empty fields = %+v", got)
+	}
+}
+
+func TestRequestRole(t *testing.T) {
+	auth := config.APIAuth{Key: "full", ReadKey: "read", Username: "admin", Password: "hunter2"}
+	tests := []struct {
+		header, value string
+		user, pass    string
+		want          role
+	}{
+		{"x-api-key", "full", "", "", roleFull},
+		{"x-api-key", "read", "", "", roleRead},
+		{"authorization", "Bearer read", "", "", roleRead},
+		{"x-api-key", "wrong", "", "", roleNone},
+		{"", "", "admin", "hunter2", roleFull},
+		{"", "", "anyone", "read", roleRead},
+		{"", "", "admin", "wrong", roleNone},
+		{"", "", "", "", roleNone},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/api/v1/feeds", nil)
+		if tt.header != "" {
+			r.Header.Set(tt.header, tt.value)
+		}
+		if tt.user != "" {
+			r.SetBasicAuth(tt.user, tt.pass)
+		}
+		if got := requestRole(auth, r); got != tt.want {
+			t.Errorf("%s %q, basic %q:%q: role %d, want %d", tt.header, tt.value, tt.user, tt.pass, got, tt.want)
+		}
+	}
+}
+
+func TestTrackerLink(t *testing.T) {
+	cfg := &config.Config{
+		Trackers: map[string]config.TrackerConfig{
+			"td": {Type: "torrentday", BaseURL: "https://tracker.example", DirectURL: "https://dl.tracker.example/{id}.torrent?pass={passkey}"},
+		},
+		Feeds: []config.Feed{{Name: "tv", Tracker: "td", URL: "https://rss.tracker.example/t.rss"}},
+	}
+	feed := cfg.Feeds[0]
+	for link, ok := range map[string]bool{
+		"":                                     true,
+		"magnet:?xt=urn:btih:0123456789abcdef": true,
+		"https://tracker.example/torrent.php?id=1":    true,
+		"https://dl.tracker.example/1.torrent?pass=x": true,
+		"https://rss.tracker.example/download/1":      true,
+		"http://169.254.169.254/latest/meta-data":     false,
+		"https://attacker.example/collect":            false,
+		"file:///etc/passwd":                          false,
+	} {
+		if err := trackerLink(cfg, feed, link); (err == nil) != ok {
+			t.Errorf("trackerLink(%q) = %v, want allowed %v", link, err, ok)
+		}
+	}
+}
+
+func TestRedactEvent(t *testing.T) {
+	e := eventJSON{
+		Event: "failed",
+		Item:  &itemJSON{Title: "x", Link: "https://tracker.example/download.php/1/x.torrent?torrent_pass=secret"},
+		Error: `enclosure: failed to download torrent: Get "https://tracker.example/dl?passkey=secret": EOF`,
+	}
+	redacted := e.redacted()
+	if data, _ := json.Marshal(redacted); strings.Contains(string(data), "secret") {
+		t.Errorf("redacted event names the passkey: %s", data)
+	}
+	if redacted.Error != `enclosure: failed to download torrent: Get "https://tracker.example/dl?REDACTED": EOF` {
+		t.Errorf("error = %q", redacted.Error)
+	}
+	if !strings.Contains(e.Item.Link, "secret") {
+		t.Error("redacting changed the event other clients get")
+	}
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"torrent-rss/internal/config"
+)
+
+// role is what a request's credentials allow
+type role int
+
+const (
+	roleNone role = iota
+	roleRead      // Requests that don't change anything
+	roleFull
+)
+
+// authorize lets requests through once their credentials allow them, when
+// the config asks for any. Credentials are looked up on every request, so a
+// reload changes them right away. /healthz stays open for health checks.
+func (s *Server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg, _ := s.current()
+		if !cfg.APIAuth.Enabled() || r.URL.Path == "/healthz" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		need := roleFull
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			need = roleRead
+		}
+		switch got := requestRole(cfg.APIAuth, r); {
+		case got == roleNone:
+			w.Header().Set("www-authenticate", `Basic realm="torrent-rss"`)
+			writeError(w, http.StatusUnauthorized, errors.New("missing or wrong API key or password"))
+		case got < need:
+			writeError(w, http.StatusForbidden, errors.New("the read-only key can't change anything"))
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// fullAccess tells whether a request may see secrets like the passkeys in
+// download links: it has full credentials, or the config asks for none
+func (s *Server) fullAccess(r *http.Request) bool {
+	cfg, _ := s.current()
+	return !cfg.APIAuth.Enabled() || requestRole(cfg.APIAuth, r) == roleFull
+}
+
+// requestRole checks the key in the X-Api-Key header or as a bearer token,
+// or the basic auth credentials of a request
+func requestRole(auth config.APIAuth, r *http.Request) role {
+	key := r.Header.Get("x-api-key")
+	if token, ok := strings.CutPrefix(r.Header.Get("authorization"), "Bearer "); ok && key == "" {
+		key = token
+	}
+	user, password, basic := r.BasicAuth()
+	switch {
+	case key != "" && matches(key, auth.Key):
+		return roleFull
+	case key != "" && matches(key, auth.ReadKey):
+		return roleRead
+	case basic && matches(user, auth.Username) && matches(password, auth.Password):
+		return roleFull
+	// Dashboards that only do basic auth can send a key as the password
+	case basic && matches(password, auth.Key):
+		return roleFull
+	case basic && matches(password, auth.ReadKey):
+		return roleRead
+	}
+	return roleNone
+}
+
+// matches compares credentials in constant time, never matching unset ones
+func matches(given, want string) bool {
+	return want != "" && subtle.ConstantTimeCompare([]byte(given), []byte(want)) == 1
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"torrent-rss/internal/metadata"
+	"torrent-rss/internal/pipeline"
+)
+
+// eventBuffer is how many events a slow client may fall behind by before
+// it misses some
+const eventBuffer = 64
+
+// Events passes the pipeline's events on to the clients of /api/v1/events
+// as they happen. It outlives config reloads, like the daemon.
+type Events struct {
+	mu      sync.Mutex
+	clients map[chan eventJSON]struct{}
+}
+
+func NewEvents() *Events {
+	return &Events{clients: make(map[chan eventJSON]struct{})}
+}
+
+// eventJSON is a pipeline event as /api/v1/events sends it, like --output
+// json prints it but with the item shaped as elsewhere in the API
+type eventJSON struct {
+	Time     time.Time      `json:"time"`
+	Event    string         `json:"event"`
+	Feed     string         `json:"feed,omitempty"`
+	Item     *itemJSON      `json:"item,omitempty"`
+	Reason   string         `json:"reason,omitempty"`
+	Error    string         `json:"error,omitempty"`
+	InfoHash string         `json:"infohash,omitempty"`
+	Size     int64          `json:"size,omitempty"`
+	Media    *metadata.Info `json:"media,omitempty"`
+}
+
+// Publish sends an event to every client listening. Clients that fell too
+// far behind miss it rather than hold up the poll.
+func (ev *Events) Publish(e pipeline.Event) {
+	out := eventJSON{
+		Time:     time.Now(),
+		Event:    e.Kind.String(),
+		Feed:     e.Feed,
+		Reason:   e.Reason,
+		InfoHash: e.InfoHash,
+		Size:     e.Size,
+		Media:    e.Media,
+	}
+	if e.Item.Title != "" || e.Item.Link != "" {
+		item := newItemJSON(e.Item, true)
+		out.Item = &item
+	}
+	if e.Err != nil {
+		out.Error = e.Err.Error()
+	}
+
+	ev.mu.Lock()
+	defer ev.mu.Unlock()
+	for client := range ev.clients {
+		select {
+		case client <- out:
+		default:
+		}
+	}
+}
+
+// redacted is the event as shown without full access, with the queries of
+// the links in its item and error left out
+func (e eventJSON) redacted() eventJSON {
+	if e.Item != nil {
+		item := *e.Item
+		item.Link, item.EnclosureURL = redactLink(item.Link), redactLink(item.EnclosureURL)
+		e.Item = &item
+	}
+	e.Error = redactText(e.Error)
+	return e
+}
+
+// subscribe returns a channel of the events published from now on, until
+// cancel is called
+func (ev *Events) subscribe() (events <-chan eventJSON, cancel func()) {
+	client := make(chan eventJSON, eventBuffer)
+	ev.mu.Lock()
+	ev.clients[client] = struct{}{}
+	ev.mu.Unlock()
+	return client, func() {
+		ev.mu.Lock()
+		delete(ev.clients, client)
+		ev.mu.Unlock()
+	}
+}
+
+// streamEvents sends the pipeline's events as they happen, one JSON object
+// per line, until the client goes away or the daemon stops
+func (s *Server) streamEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if s.events == nil || !ok {
+		writeError(w, http.StatusNotImplemented, errors.New("events aren't streamed"))
+		return
+	}
+	events, cancel := s.events.subscribe()
+	defer cancel()
+	full := s.fullAccess(r)
+
+	w.Header().Set("content-type", "application/x-ndjson")
+	w.Header().Set("cache-control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	enc := json.NewEncoder(w)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e := <-events:
+			if !full {
+				e = e.redacted()
+			}
+			if err := enc.Encode(e); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
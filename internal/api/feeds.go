@@ -0,0 +1,109 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"torrent-rss/internal/config"
+)
+
+type invalidConfigJSON struct {
+	Error    string   `json:"error"`
+	Problems []string `json:"problems"`
+}
+
+// addFeed adds a feed to the config file and starts polling it. The body
+// takes the same fields as a feed in the config file.
+func (s *Server) addFeed(w http.ResponseWriter, r *http.Request) {
+	feed, ok := decodeFeed(w, r)
+	if !ok {
+		return
+	}
+	name, _ := feed["name"].(string)
+	if s.editFeeds(w, func() error { return config.AddFeed(s.configFile, feed) }) {
+		s.writeFeed(w, http.StatusCreated, name)
+	}
+}
+
+// updateFeed replaces a feed of the config file with the body, the same
+// fields as a feed in the config file. Fields left out are unset.
+func (s *Server) updateFeed(w http.ResponseWriter, r *http.Request) {
+	feed, ok := decodeFeed(w, r)
+	if !ok {
+		return
+	}
+	name := r.PathValue("name")
+	if s.editFeeds(w, func() error { return config.UpdateFeed(s.configFile, name, feed) }) {
+		renamed, _ := feed["name"].(string)
+		s.writeFeed(w, http.StatusOK, renamed)
+	}
+}
+
+// removeFeed removes a feed from the config file and stops polling it. Its
+// history is kept.
+func (s *Server) removeFeed(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if s.editFeeds(w, func() error { return config.RemoveFeed(s.configFile, name) }) {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func decodeFeed(w http.ResponseWriter, r *http.Request) (map[string]any, bool) {
+	var feed map[string]any
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&feed); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return nil, false
+	}
+	if feed == nil {
+		writeError(w, http.StatusBadRequest, errors.New("the feed must be an object"))
+		return nil, false
+	}
+	return feed, true
+}
+
+// editFeeds saves a change to the config file and reloads it, reporting
+// whether both worked. Edits are made one at a time so none is lost.
+func (s *Server) editFeeds(w http.ResponseWriter, edit func() error) bool {
+	if s.configFile == "" {
+		writeError(w, http.StatusNotImplemented, errors.New("feeds are configured through the environment, set TD_CONFIG to a config file to edit them through the API"))
+		return false
+	}
+	s.editing.Lock()
+	defer s.editing.Unlock()
+
+	var invalid *config.ValidationError
+	err := edit()
+	switch {
+	case errors.As(err, &invalid):
+		writeJSON(w, http.StatusBadRequest, invalidConfigJSON{Error: "the feed makes the config invalid", Problems: invalid.Problems})
+		return false
+	case errors.Is(err, config.ErrFeedExists):
+		writeError(w, http.StatusConflict, err)
+		return false
+	case errors.Is(err, config.ErrFeedNotFound):
+		writeError(w, http.StatusNotFound, err)
+		return false
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err)
+		return false
+	}
+
+	if err := s.reload(); err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("saved the config, but it can't be applied: %w", err))
+		return false
+	}
+	return true
+}
+
+// writeFeed answers with the named feed as the reloaded config has it
+func (s *Server) writeFeed(w http.ResponseWriter, status int, name string) {
+	cfg, _ := s.current()
+	feed, ok := cfg.Feed(name)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("feed %q is missing from the reloaded config", name))
+		return
+	}
+	writeJSON(w, status, newFeedJSON(feed))
+}
@@ -0,0 +1,109 @@
+// Package bandwidth caps how fast response bodies are read, so bulk
+// downloads don't saturate a small link
+package bandwidth
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"torrent-rss/internal/bytesize"
+)
+
+// chunk is the most read at once, so a slow limit is spread evenly over a
+// body instead of in large bursts
+const chunk = 16 << 10
+
+// Limiter is a budget of bytes per second shared by every reader throttled
+// with it. A nil Limiter doesn't limit.
+type Limiter struct {
+	rate float64 // Bytes per second
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// New returns a limiter of bytesPerSecond, or nil when it's zero or less
+func New(bytesPerSecond int64) *Limiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	return &Limiter{rate: float64(bytesPerSecond), tokens: float64(bytesPerSecond), last: time.Now()}
+}
+
+// ParseRate reads a rate such as "512KB" or "2MB/s" in bytes per second
+func ParseRate(value string) (int64, error) {
+	trimmed := strings.TrimSuffix(strings.TrimSpace(value), "/s")
+	rate, err := bytesize.Parse(trimmed)
+	if err != nil {
+		return 0, fmt.Errorf("rate must look like 512KB or 2MB/s, got %q", value)
+	}
+	return rate, nil
+}
+
+// burst is the most that can be saved up, a second's worth but at least a chunk
+func (l *Limiter) burst() float64 {
+	return max(l.rate, chunk)
+}
+
+// wait blocks until n bytes read fit the budget, or ctx is done. The bytes
+// are already read, so they're taken right away, going into debt.
+func (l *Limiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = min(l.burst(), l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens -= float64(n)
+	delay := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Reader throttles r to the slowest of limiters, nil ones are skipped.
+// Without any limiter r is returned as it is.
+func Reader(ctx context.Context, r io.Reader, limiters ...*Limiter) io.Reader {
+	var active []*Limiter
+	for _, l := range limiters {
+		if l != nil {
+			active = append(active, l)
+		}
+	}
+	if len(active) == 0 {
+		return r
+	}
+	return &reader{ctx: ctx, r: r, limiters: active}
+}
+
+type reader struct {
+	ctx      context.Context
+	r        io.Reader
+	limiters []*Limiter
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if len(p) > chunk {
+		p = p[:chunk]
+	}
+	n, err := r.r.Read(p)
+	for _, l := range r.limiters {
+		if waitErr := l.wait(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
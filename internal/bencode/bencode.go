@@ -0,0 +1,242 @@
+package bencode
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// maxDepth keeps hostile input from nesting lists until the stack runs out
+const maxDepth = 64
+
+// Decode parses a single bencoded value that must span all of data.
+// Integers decode to int64, strings to string, lists to []any and
+// dictionaries to map[string]any.
+func Decode(data []byte) (any, error) {
+	d := decoder{data: data}
+	value, err := d.value(0)
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(data) {
+		return nil, fmt.Errorf("bencode: trailing data at offset %d", d.pos)
+	}
+	return value, nil
+}
+
+// RawValue returns the exact encoded bytes of a top-level dictionary key,
+// which is how the info dict is hashed without re-encoding it
+func RawValue(data []byte, key string) ([]byte, error) {
+	d := decoder{data: data}
+	if d.peek() != 'd' {
+		return nil, fmt.Errorf("bencode: not a dictionary")
+	}
+	d.pos++
+
+	for d.peek() != 'e' {
+		k, err := d.string()
+		if err != nil {
+			return nil, err
+		}
+		start := d.pos
+		if _, err := d.value(1); err != nil {
+			return nil, err
+		}
+		if k == key {
+			return data[start:d.pos], nil
+		}
+	}
+	return nil, fmt.Errorf("bencode: key %q not found", key)
+}
+
+// Raw is a value that's already encoded, which Encode writes as is
+type Raw []byte
+
+// RawDict splits a dictionary into its keys and the exact encoded bytes of
+// their values, so a torrent can be edited without re-encoding the info dict
+func RawDict(data []byte) (map[string]Raw, error) {
+	d := decoder{data: data}
+	if d.peek() != 'd' {
+		return nil, fmt.Errorf("bencode: not a dictionary")
+	}
+	d.pos++
+
+	dict := make(map[string]Raw)
+	for d.peek() != 'e' {
+		if d.peek() == 0 {
+			return nil, fmt.Errorf("bencode: unterminated dictionary")
+		}
+		k, err := d.string()
+		if err != nil {
+			return nil, fmt.Errorf("bencode: dictionary key: %w", err)
+		}
+		start := d.pos
+		if _, err := d.value(1); err != nil {
+			return nil, err
+		}
+		dict[k] = Raw(data[start:d.pos])
+	}
+	if d.pos+1 != len(data) {
+		return nil, fmt.Errorf("bencode: trailing data at offset %d", d.pos+1)
+	}
+	return dict, nil
+}
+
+// Encode writes v as bencode. It takes what Decode returns, string slices,
+// ints and Raw values; dictionaries are written with sorted keys as the
+// spec requires.
+func Encode(v any) ([]byte, error) {
+	var b bytes.Buffer
+	if err := encode(&b, v); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func encode(b *bytes.Buffer, v any) error {
+	switch v := v.(type) {
+	case Raw:
+		b.Write(v)
+	case string:
+		b.WriteString(strconv.Itoa(len(v)))
+		b.WriteByte(':')
+		b.WriteString(v)
+	case int64:
+		fmt.Fprintf(b, "i%de", v)
+	case int:
+		fmt.Fprintf(b, "i%de", v)
+	case []string:
+		b.WriteByte('l')
+		for _, item := range v {
+			encode(b, item)
+		}
+		b.WriteByte('e')
+	case []any:
+		b.WriteByte('l')
+		for _, item := range v {
+			if err := encode(b, item); err != nil {
+				return err
+			}
+		}
+		b.WriteByte('e')
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b.WriteByte('d')
+		for _, k := range keys {
+			encode(b, k)
+			if err := encode(b, v[k]); err != nil {
+				return err
+			}
+		}
+		b.WriteByte('e')
+	default:
+		return fmt.Errorf("bencode: can't encode %T", v)
+	}
+	return nil
+}
+
+type decoder struct {
+	data []byte
+	pos  int
+}
+
+// peek returns the next byte, or 0 at the end of input
+func (d *decoder) peek() byte {
+	if d.pos >= len(d.data) {
+		return 0
+	}
+	return d.data[d.pos]
+}
+
+func (d *decoder) value(depth int) (any, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("bencode: nesting deeper than %d", maxDepth)
+	}
+
+	switch c := d.peek(); {
+	case c == 'i':
+		return d.int()
+	case c >= '0' && c <= '9':
+		return d.string()
+	case c == 'l':
+		d.pos++
+		list := []any{}
+		for d.peek() != 'e' {
+			if d.peek() == 0 {
+				return nil, fmt.Errorf("bencode: unterminated list")
+			}
+			v, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		d.pos++
+		return list, nil
+	case c == 'd':
+		d.pos++
+		dict := make(map[string]any)
+		for d.peek() != 'e' {
+			if d.peek() == 0 {
+				return nil, fmt.Errorf("bencode: unterminated dictionary")
+			}
+			key, err := d.string()
+			if err != nil {
+				return nil, fmt.Errorf("bencode: dictionary key: %w", err)
+			}
+			v, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			dict[key] = v
+		}
+		d.pos++
+		return dict, nil
+	case c == 0:
+		return nil, fmt.Errorf("bencode: unexpected end of data")
+	default:
+		return nil, fmt.Errorf("bencode: unexpected %q at offset %d", c, d.pos)
+	}
+}
+
+func (d *decoder) int() (int64, error) {
+	start := d.pos + 1
+	end := start
+	for end < len(d.data) && d.data[end] != 'e' {
+		end++
+	}
+	if end >= len(d.data) {
+		return 0, fmt.Errorf("bencode: unterminated integer at offset %d", d.pos)
+	}
+
+	digits := string(d.data[start:end])
+	n, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil || (len(digits) > 1 && (digits[0] == '0' || digits[:2] == "-0")) {
+		return 0, fmt.Errorf("bencode: invalid integer %q at offset %d", digits, d.pos)
+	}
+	d.pos = end + 1
+	return n, nil
+}
+
+func (d *decoder) string() (string, error) {
+	colon := d.pos
+	for colon < len(d.data) && d.data[colon] >= '0' && d.data[colon] <= '9' {
+		colon++
+	}
+	if colon == d.pos || colon >= len(d.data) || d.data[colon] != ':' {
+		return "", fmt.Errorf("bencode: invalid string length at offset %d", d.pos)
+	}
+
+	length, err := strconv.Atoi(string(d.data[d.pos:colon]))
+	if err != nil || length > len(d.data)-colon-1 {
+		return "", fmt.Errorf("bencode: string at offset %d runs past the end of data", d.pos)
+	}
+	start := colon + 1
+	d.pos = start + length
+	return string(d.data[start:d.pos]), nil
+}
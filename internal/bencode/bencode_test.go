@@ -0,0 +1,86 @@
+package bencode
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	const data = "d8:announce13:http://t/ann/4:infod6:lengthi42e4:name4:file12:piece lengthi16384ee4:listl1:ai-3eee"
+	value, err := Decode([]byte(data))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	want := map[string]any{
+		"announce": "http://t/ann/",
+		"info":     map[string]any{"length": int64(42), "name": "file", "piece length": int64(16384)},
+		"list":     []any{"a", int64(-3)},
+	}
+	if !reflect.DeepEqual(value, want) {
+		t.Fatalf("Decode = %#v, want %#v", value, want)
+	}
+	encoded, err := Encode(value)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if string(encoded) != data {
+		t.Errorf("Encode = %s, want %s", encoded, data)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	for _, data := range []string{
+		"",
+		"i42",
+		"i4x2e",
+		"5:abc",
+		"l1:a",
+		"d1:ae",
+		"i1ei2e",
+		strings.Repeat("l", maxDepth+2) + strings.Repeat("e", maxDepth+2),
+	} {
+		if value, err := Decode([]byte(data)); err == nil {
+			t.Errorf("Decode(%q) = %#v, want an error", data, value)
+		}
+	}
+}
+
+func TestRawValue(t *testing.T) {
+	const info = "d6:lengthi42e4:name4:filee"
+	data := []byte("d8:announce3:url4:info" + info + "e")
+	raw, err := RawValue(data, "info")
+	if err != nil {
+		t.Fatalf("RawValue: %v", err)
+	}
+	if string(raw) != info {
+		t.Errorf("RawValue = %s, want %s", raw, info)
+	}
+	if _, err := RawValue(data, "missing"); err == nil {
+		t.Error("RawValue found a missing key")
+	}
+}
+
+func TestRawDict(t *testing.T) {
+	const info = "d6:lengthi42e4:name4:filee"
+	dict, err := RawDict([]byte("d8:announce3:url4:info" + info + "e"))
+	if err != nil {
+		t.Fatalf("RawDict: %v", err)
+	}
+	if string(dict["info"]) != info {
+		t.Errorf("info = %s, want %s", dict["info"], info)
+	}
+
+	// Raw values are written back as they are
+	dict["announce"] = Raw("3:new")
+	encoded, err := Encode(map[string]any{"announce": dict["announce"], "info": dict["info"]})
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if want := "d8:announce3:new4:info" + info + "e"; string(encoded) != want {
+		t.Errorf("Encode = %s, want %s", encoded, want)
+	}
+	if _, err := RawDict([]byte("d1:ai1eextra")); err == nil {
+		t.Error("RawDict accepted trailing data")
+	}
+}
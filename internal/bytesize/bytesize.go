@@ -0,0 +1,66 @@
+package bytesize
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	sizePattern = regexp.MustCompile(`(?i)^(\d+(?:[.,]\d+)?)\s*([KMGT]i?B|B)?$`)
+	// Sizes inside free text, preferring one labelled "Size"
+	labelledPattern = regexp.MustCompile(`(?i)\bsize\W{0,3}(\d+(?:[.,]\d+)?\s*[KMGT]i?B)\b`)
+	textPattern     = regexp.MustCompile(`(?i)\b(\d+(?:[.,]\d+)?\s*[KMGT]i?B)\b`)
+)
+
+// Parse reads sizes such as "1.4 GB", "700MiB" or "1048576". Like torrent
+// sites, KB, MB, GB and TB are powers of 1024, the same as KiB, MiB, ...
+func Parse(value string) (int64, error) {
+	m := sizePattern.FindStringSubmatch(strings.TrimSpace(value))
+	if m == nil {
+		return 0, fmt.Errorf("size must look like 700MB or 1.5GB, got %q", value)
+	}
+	number, err := strconv.ParseFloat(strings.Replace(m[1], ",", ".", 1), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", value, err)
+	}
+
+	multiplier := int64(1)
+	if m[2] != "" && !strings.EqualFold(m[2], "B") {
+		for _, unit := range "KMGT" {
+			multiplier *= 1024
+			if strings.EqualFold(m[2][:1], string(unit)) {
+				break
+			}
+		}
+	}
+	return int64(number * float64(multiplier)), nil
+}
+
+// Find returns the first size mentioned in text, such as a feed item
+// description, or 0 if there is none
+func Find(text string) int64 {
+	for _, pattern := range []*regexp.Regexp{labelledPattern, textPattern} {
+		if m := pattern.FindStringSubmatch(text); m != nil {
+			if size, err := Parse(m[1]); err == nil {
+				return size
+			}
+		}
+	}
+	return 0
+}
+
+// Format renders a byte count the way torrent sites do, e.g. "1.4 GiB"
+func Format(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,126 @@
+// Package category tells what kind of content a release is, like TV or
+// movies, from the categories feeds put items in
+package category
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Type is a kind of content, recognized by category IDs, ranges of them and
+// category names
+type Type struct {
+	Name     string
+	rules    []string
+	ranges   [][2]int
+	patterns []*regexp.Regexp
+}
+
+// Defaults are the types known without configuring any, by Newznab IDs as
+// Torznab indexers use them and by the names trackers commonly use
+var Defaults = []struct {
+	Name  string
+	Rules []string
+}{
+	{"tv", []string{"5000-5999", "tv*", "*series*", "*episodes*"}},
+	{"movies", []string{"2000-2999", "movie*", "film*"}},
+	{"music", []string{"3000-3999", "music*", "audio*"}},
+}
+
+// NewType builds a type from rules, each a category ID like "7", a range
+// like "5000-5999", or a name where * matches anything, like "TV/*".
+// Names match ignoring case.
+func NewType(name string, rules []string) (*Type, error) {
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("content type %s needs at least one category", name)
+	}
+	t := &Type{Name: name, rules: rules}
+	for _, rule := range rules {
+		rule = strings.TrimSpace(rule)
+		if low, high, ok := idRange(rule); ok {
+			if low > high {
+				return nil, fmt.Errorf("category range %q runs backwards", rule)
+			}
+			t.ranges = append(t.ranges, [2]int{low, high})
+			continue
+		}
+		if rule == "" {
+			return nil, fmt.Errorf("content type %s has an empty category", name)
+		}
+		parts := strings.Split(rule, "*")
+		for i, part := range parts {
+			parts[i] = regexp.QuoteMeta(part)
+		}
+		t.patterns = append(t.patterns, regexp.MustCompile("(?i)^"+strings.Join(parts, ".*")+"$"))
+	}
+	return t, nil
+}
+
+// DefaultTypes builds the Defaults
+func DefaultTypes() []*Type {
+	types := make([]*Type, 0, len(Defaults))
+	for _, d := range Defaults {
+		t, err := NewType(d.Name, d.Rules)
+		if err != nil {
+			panic(err)
+		}
+		types = append(types, t)
+	}
+	return types
+}
+
+// idRange reads a category ID or a range of them
+func idRange(rule string) (int, int, bool) {
+	lowText, highText, isRange := strings.Cut(rule, "-")
+	low, err := strconv.Atoi(strings.TrimSpace(lowText))
+	if err != nil {
+		return 0, 0, false
+	}
+	if !isRange {
+		return low, low, true
+	}
+	high, err := strconv.Atoi(strings.TrimSpace(highText))
+	if err != nil {
+		return 0, 0, false
+	}
+	return low, high, true
+}
+
+// Match reports whether any of categories belongs to the type
+func (t *Type) Match(categories []string) bool {
+	for _, c := range categories {
+		c = strings.TrimSpace(c)
+		if id, err := strconv.Atoi(c); err == nil {
+			for _, r := range t.ranges {
+				if id >= r[0] && id <= r[1] {
+					return true
+				}
+			}
+			continue
+		}
+		for _, pattern := range t.patterns {
+			if pattern.MatchString(c) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Rules returns the rules the type was built from
+func (t *Type) Rules() []string {
+	return t.rules
+}
+
+// Detect returns the name of the first of types that categories belong to,
+// or "" when none does
+func Detect(types []*Type, categories []string) string {
+	for _, t := range types {
+		if t.Match(categories) {
+			return t.Name
+		}
+	}
+	return ""
+}
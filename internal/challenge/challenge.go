@@ -0,0 +1,189 @@
+package challenge
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Error means a request was answered with an anti-bot challenge page
+// instead of the content, and there was no way to solve it
+type Error struct {
+	Provider string // "Cloudflare" or "DDoS-Guard"
+	Host     string
+	Err      error // Why solving failed, nil without a solver
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s is behind a %s challenge that couldn't be solved: %v", e.Host, e.Provider, e.Err)
+	}
+	return fmt.Sprintf("%s is behind a %s challenge, configure FlareSolverr to get past it", e.Host, e.Provider)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Permanent tells the retry transport that sending the request again won't help
+func (e *Error) Permanent() bool {
+	return true
+}
+
+// Clearance is what passing a challenge yields: cookies that must be sent
+// together with the user agent that earned them
+type Clearance struct {
+	Cookies   []*http.Cookie
+	UserAgent string
+}
+
+// Solver gets past a challenge on pageURL, usually by loading it in a real
+// browser
+type Solver interface {
+	Solve(ctx context.Context, pageURL string) (*Clearance, error)
+}
+
+// bodyPeek is how much of a suspicious response is read to look for markers
+const bodyPeek = 64 << 10
+
+var (
+	cloudflareMarkers = []string{"<title>Just a moment...</title>", "challenge-platform", "cf-browser-verification", "cf_chl_opt", "cf-chl-"}
+	ddosGuardMarkers  = []string{"ddos-guard", "DDoS-Guard"}
+)
+
+// Detect reports which provider's challenge resp is, reading at most
+// bodyPeek bytes of the body and leaving it readable from the start
+func Detect(resp *http.Response) (string, bool) {
+	if resp.Header.Get("cf-mitigated") == "challenge" {
+		return "Cloudflare", true
+	}
+	// Challenges are served as 403 or 503, leave everything else alone
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusServiceUnavailable {
+		return "", false
+	}
+
+	prefix, err := io.ReadAll(io.LimitReader(resp.Body, bodyPeek))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(prefix), resp.Body), resp.Body}
+	if err != nil {
+		return "", false
+	}
+
+	body := string(prefix)
+	server := strings.ToLower(resp.Header.Get("server"))
+	switch {
+	case (server == "cloudflare" || resp.Header.Get("cf-ray") != "") && containsAny(body, cloudflareMarkers):
+		return "Cloudflare", true
+	case server == "ddos-guard" || containsAny(body, ddosGuardMarkers):
+		return "DDoS-Guard", true
+	}
+	return "", false
+}
+
+func containsAny(s string, markers []string) bool {
+	for _, marker := range markers {
+		if strings.Contains(s, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handler solves challenges and remembers the clearance per host, so every
+// client sharing it gets past a host once solved
+type Handler struct {
+	solver Solver
+
+	mu         sync.Mutex
+	clearances map[string]*Clearance
+}
+
+// NewHandler creates a handler solving challenges with solver. A nil solver
+// only detects them.
+func NewHandler(solver Solver) *Handler {
+	return &Handler{solver: solver, clearances: make(map[string]*Clearance)}
+}
+
+// Transport wraps next to notice challenge pages. When the handler has a
+// solver, the challenge is solved and the request sent again with the
+// clearance; otherwise requests fail with *Error rather than handing the
+// challenge page to a parser. A nil handler only detects.
+func Transport(next http.RoundTripper, h *Handler) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if h == nil {
+		h = NewHandler(nil)
+	}
+	return &transport{next: next, handler: h}
+}
+
+type transport struct {
+	next    http.RoundTripper
+	handler *Handler
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Hostname()
+	h := t.handler
+	resp, err := t.next.RoundTrip(withClearance(req, h.clearance(host)))
+	if err != nil {
+		return nil, err
+	}
+	provider, ok := Detect(resp)
+	if !ok {
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	// Browsers can only solve page loads, and a body may not be sendable twice
+	if h.solver == nil || req.Method != http.MethodGet {
+		return nil, &Error{Provider: provider, Host: host}
+	}
+	clearance, err := h.solver.Solve(req.Context(), req.URL.String())
+	if err != nil {
+		return nil, &Error{Provider: provider, Host: host, Err: err}
+	}
+	h.mu.Lock()
+	h.clearances[host] = clearance
+	h.mu.Unlock()
+
+	resp, err = t.next.RoundTrip(withClearance(req, clearance))
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := Detect(resp); ok {
+		resp.Body.Close()
+		return nil, &Error{Provider: provider, Host: host, Err: errors.New("still challenged after solving")}
+	}
+	return resp, nil
+}
+
+func (h *Handler) clearance(host string) *Clearance {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.clearances[host]
+}
+
+// withClearance returns a copy of req carrying the clearance cookies and
+// the user agent they are bound to
+func withClearance(req *http.Request, c *Clearance) *http.Request {
+	if c == nil {
+		return req
+	}
+	req = req.Clone(req.Context())
+	for _, cookie := range c.Cookies {
+		req.AddCookie(&http.Cookie{Name: cookie.Name, Value: cookie.Value})
+	}
+	if c.UserAgent != "" {
+		req.Header.Set("user-agent", c.UserAgent)
+	}
+	return req
+}
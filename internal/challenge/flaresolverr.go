@@ -0,0 +1,89 @@
+package challenge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultFlareSolverrTimeout is how long FlareSolverr may take per challenge
+const DefaultFlareSolverrTimeout = 60 * time.Second
+
+// FlareSolverr solves challenges with a FlareSolverr instance, which loads
+// the page in a headless browser
+type FlareSolverr struct {
+	url     string
+	timeout time.Duration
+	http    *http.Client
+}
+
+// NewFlareSolverr talks to the FlareSolverr at baseURL, e.g.
+// http://localhost:8191. A zero timeout means DefaultFlareSolverrTimeout.
+func NewFlareSolverr(baseURL string, timeout time.Duration) (*FlareSolverr, error) {
+	if !strings.HasPrefix(baseURL, "http://") && !strings.HasPrefix(baseURL, "https://") {
+		return nil, fmt.Errorf("flaresolverr: URL must start with http:// or https://, got %q", baseURL)
+	}
+	if timeout <= 0 {
+		timeout = DefaultFlareSolverrTimeout
+	}
+	return &FlareSolverr{
+		url:     strings.TrimRight(baseURL, "/") + "/v1",
+		timeout: timeout,
+		// The browser gets the timeout, the request some slack on top
+		http: &http.Client{Timeout: timeout + 15*time.Second},
+	}, nil
+}
+
+type flareRequest struct {
+	Cmd        string `json:"cmd"`
+	URL        string `json:"url"`
+	MaxTimeout int64  `json:"maxTimeout"` // Milliseconds
+}
+
+type flareResponse struct {
+	Status   string `json:"status"`
+	Message  string `json:"message"`
+	Solution struct {
+		UserAgent string `json:"userAgent"`
+		Cookies   []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"cookies"`
+	} `json:"solution"`
+}
+
+func (f *FlareSolverr) Solve(ctx context.Context, pageURL string) (*Clearance, error) {
+	body, err := json.Marshal(flareRequest{Cmd: "request.get", URL: pageURL, MaxTimeout: f.timeout.Milliseconds()})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", f.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("flaresolverr: failed to create request: %w", err)
+	}
+	req.Header.Set("content-type", "application/json")
+
+	resp, err := f.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("flaresolverr: request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result flareResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("flaresolverr: invalid response (%s): %w", resp.Status, err)
+	}
+	if result.Status != "ok" {
+		return nil, fmt.Errorf("flaresolverr: %s", result.Message)
+	}
+
+	clearance := &Clearance{UserAgent: result.Solution.UserAgent}
+	for _, c := range result.Solution.Cookies {
+		clearance.Cookies = append(clearance.Cookies, &http.Cookie{Name: c.Name, Value: c.Value})
+	}
+	return clearance, nil
+}
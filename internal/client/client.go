@@ -0,0 +1,136 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Client submits torrents straight to a torrent client instead of a watch folder
+type Client interface {
+	// AddTorrent uploads the contents of a .torrent file
+	AddTorrent(ctx context.Context, name string, data []byte, opts AddOptions) error
+	// AddMagnet adds a magnet URI and lets the client fetch the metadata
+	AddMagnet(ctx context.Context, uri string, opts AddOptions) error
+	// RemoveTorrent removes a torrent by infohash, optionally with its data
+	RemoveTorrent(ctx context.Context, infoHash string, deleteData bool) error
+}
+
+// SpaceChecker is implemented by backends that can tell how much room is
+// left where the client saves downloads
+type SpaceChecker interface {
+	// FreeSpace returns the free bytes at savePath, or at the client's
+	// default save path when it's empty
+	FreeSpace(ctx context.Context, savePath string) (int64, error)
+}
+
+// Lister is implemented by backends that can list the torrents they hold,
+// which is how completed downloads are found
+type Lister interface {
+	Torrents(ctx context.Context) ([]Torrent, error)
+}
+
+// SeedingLimiter is implemented by backends that can set how long each
+// torrent is seeded when adding it
+type SeedingLimiter interface {
+	// CheckSeeding returns an error saying which of the limits of s the
+	// backend can't set
+	CheckSeeding(s Seeding) error
+}
+
+// Torrent is a torrent held by a client
+type Torrent struct {
+	InfoHash string // Lowercase hex
+	Name     string
+	Category string // Category or label, if any
+	Done     bool   // Finished downloading, whether it's seeding or not
+}
+
+// AddOptions controls where the client puts a new torrent
+type AddOptions struct {
+	Category string
+	SavePath string
+	Seeding  Seeding
+}
+
+// Seeding is how long a torrent is seeded before the client may stop it,
+// for trackers that ask for a minimum: until it reached Ratio or was seeded
+// for Time, whichever comes first. A limit left at 0 never stops it, and
+// the zero Seeding leaves both to the client's own settings.
+type Seeding struct {
+	Ratio float64
+	Time  time.Duration
+}
+
+func (s Seeding) IsZero() bool {
+	return s.Ratio == 0 && s.Time == 0
+}
+
+// CheckSeeding tells whether c can set the seeding limits of s
+func CheckSeeding(c Client, s Seeding) error {
+	if s.IsZero() {
+		return nil
+	}
+	limiter, ok := c.(SeedingLimiter)
+	if !ok {
+		return errors.New("can't limit seeding per torrent")
+	}
+	return limiter.CheckSeeding(s)
+}
+
+// Options holds the connection settings shared by every backend
+type Options struct {
+	URL      string
+	Username string
+	Password string
+}
+
+// Factory builds a Client from options
+type Factory func(Options) (Client, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes a backend available under the given name
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if factory == nil {
+		panic("client: Register factory is nil")
+	}
+	if _, exists := registry[name]; exists {
+		panic("client: Register called twice for " + name)
+	}
+	registry[name] = factory
+}
+
+// New builds the backend registered under name
+func New(name string, opts Options) (Client, error) {
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown torrent client %q (available: %v)", name, Names())
+	}
+	return factory(opts)
+}
+
+// Names lists the registered backends in alphabetical order
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
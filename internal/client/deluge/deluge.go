@@ -0,0 +1,273 @@
+package deluge
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"strings"
+	"sync"
+	"time"
+
+	"torrent-rss/internal/client"
+)
+
+func init() {
+	client.Register("deluge", func(opts client.Options) (client.Client, error) {
+		return New(opts)
+	})
+}
+
+// Client talks to the Deluge Web UI JSON-RPC endpoint
+type Client struct {
+	endpoint string
+	password string
+	http     *http.Client
+
+	mu        sync.Mutex
+	requestID int
+	ready     bool
+}
+
+func New(opts client.Options) (*Client, error) {
+	if opts.URL == "" {
+		return nil, fmt.Errorf("deluge: URL is required")
+	}
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, fmt.Errorf("deluge: failed to create cookie jar: %w", err)
+	}
+	return &Client{
+		endpoint: strings.TrimRight(opts.URL, "/") + "/json",
+		password: opts.Password,
+		http: &http.Client{
+			Jar:     jar,
+			Timeout: 30 * time.Second,
+		},
+	}, nil
+}
+
+type rpcRequest struct {
+	Method string `json:"method"`
+	Params []any  `json:"params"`
+	ID     int    `json:"id"`
+}
+
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Message string `json:"message"`
+		Code    int    `json:"code"`
+	} `json:"error"`
+}
+
+// call performs a single JSON-RPC call; the caller must hold c.mu
+func (c *Client) call(ctx context.Context, method string, params []any, result any) error {
+	c.requestID++
+	body, err := json.Marshal(rpcRequest{Method: method, Params: params, ID: c.requestID})
+	if err != nil {
+		return fmt.Errorf("deluge: failed to encode %s: %w", method, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("deluge: failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("deluge: %s failed: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("deluge: %s failed: %s", method, resp.Status)
+	}
+
+	var rpcResp rpcResponse
+	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
+		return fmt.Errorf("deluge: failed to decode %s response: %w", method, err)
+	}
+	if rpcResp.Error != nil {
+		return fmt.Errorf("deluge: %s: %s", method, rpcResp.Error.Message)
+	}
+	if result != nil {
+		if err := json.Unmarshal(rpcResp.Result, result); err != nil {
+			return fmt.Errorf("deluge: unexpected %s result: %w", method, err)
+		}
+	}
+	return nil
+}
+
+// connect logs into the web UI and makes sure it is attached to a daemon
+func (c *Client) connect(ctx context.Context) error {
+	var ok bool
+	if err := c.call(ctx, "auth.login", []any{c.password}, &ok); err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("deluge: login rejected")
+	}
+
+	var connected bool
+	if err := c.call(ctx, "web.connected", []any{}, &connected); err != nil {
+		return err
+	}
+	if connected {
+		return nil
+	}
+
+	// Attach to the first configured daemon
+	var hosts [][]any
+	if err := c.call(ctx, "web.get_hosts", []any{}, &hosts); err != nil {
+		return err
+	}
+	if len(hosts) == 0 || len(hosts[0]) == 0 {
+		return fmt.Errorf("deluge: web UI has no daemon hosts configured")
+	}
+	return c.call(ctx, "web.connect", []any{hosts[0][0]}, nil)
+}
+
+// AddTorrent uploads a .torrent file and applies the category as a label
+func (c *Client) AddTorrent(ctx context.Context, name string, data []byte, opts client.AddOptions) error {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	return c.add(ctx, "core.add_torrent_file", []any{name, encoded}, opts)
+}
+
+// AddMagnet adds a magnet URI and applies the category as a label
+func (c *Client) AddMagnet(ctx context.Context, uri string, opts client.AddOptions) error {
+	return c.add(ctx, "core.add_torrent_magnet", []any{uri}, opts)
+}
+
+// RemoveTorrent removes a torrent by infohash, which Deluge uses as torrent ID
+func (c *Client) RemoveTorrent(ctx context.Context, infoHash string, deleteData bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.ready {
+		if err := c.connect(ctx); err != nil {
+			return err
+		}
+		c.ready = true
+	}
+
+	var removed bool
+	if err := c.call(ctx, "core.remove_torrent", []any{strings.ToLower(infoHash), deleteData}, &removed); err != nil {
+		return err
+	}
+	if !removed {
+		return fmt.Errorf("deluge: torrent %s was not removed", infoHash)
+	}
+	return nil
+}
+
+// FreeSpace asks the daemon how much room is left at savePath, or at its
+// download location
+func (c *Client) FreeSpace(ctx context.Context, savePath string) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.ready {
+		if err := c.connect(ctx); err != nil {
+			return 0, err
+		}
+		c.ready = true
+	}
+
+	params := []any{}
+	if savePath != "" {
+		params = append(params, savePath)
+	}
+	var free int64
+	if err := c.call(ctx, "core.get_free_space", params, &free); err != nil {
+		return 0, err
+	}
+	return free, nil
+}
+
+// Torrents lists every torrent the daemon holds and whether it's finished,
+// with its label when the Label plugin is enabled
+func (c *Client) Torrents(ctx context.Context) ([]client.Torrent, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.ready {
+		if err := c.connect(ctx); err != nil {
+			return nil, err
+		}
+		c.ready = true
+	}
+
+	var status map[string]struct {
+		Name       string `json:"name"`
+		Label      string `json:"label"`
+		IsFinished bool   `json:"is_finished"`
+	}
+	if err := c.call(ctx, "core.get_torrents_status", []any{map[string]any{}, []string{"name", "label", "is_finished"}}, &status); err != nil {
+		return nil, err
+	}
+	torrents := make([]client.Torrent, 0, len(status))
+	for id, t := range status {
+		torrents = append(torrents, client.Torrent{InfoHash: strings.ToLower(id), Name: t.Name, Category: t.Label, Done: t.IsFinished})
+	}
+	return torrents, nil
+}
+
+// CheckSeeding accepts a ratio, Deluge has no seeding time limit per
+// torrent, only the queue settings that apply to all of them
+func (c *Client) CheckSeeding(s client.Seeding) error {
+	if s.Time > 0 {
+		return errors.New("can only limit the ratio per torrent, not the seeding time")
+	}
+	return nil
+}
+
+func (c *Client) add(ctx context.Context, method string, params []any, opts client.AddOptions) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.ready {
+		if err := c.connect(ctx); err != nil {
+			return err
+		}
+		c.ready = true
+	}
+
+	options := map[string]any{}
+	if opts.SavePath != "" {
+		options["download_location"] = opts.SavePath
+	}
+	if opts.Seeding.Ratio > 0 {
+		options["stop_at_ratio"] = true
+		options["stop_ratio"] = opts.Seeding.Ratio
+	}
+
+	var torrentID string
+	if err := c.call(ctx, method, append(params, options), &torrentID); err != nil {
+		// The session may have expired, log in again once
+		if err := c.connect(ctx); err != nil {
+			c.ready = false
+			return err
+		}
+		if err := c.call(ctx, method, append(params, options), &torrentID); err != nil {
+			return err
+		}
+	}
+	if torrentID == "" {
+		return fmt.Errorf("deluge: torrent was rejected (invalid or duplicate)")
+	}
+
+	if opts.Category != "" {
+		// Requires the Label plugin; adding an existing label fails harmlessly
+		_ = c.call(ctx, "label.add", []any{opts.Category}, nil)
+		if err := c.call(ctx, "label.set_torrent", []any{torrentID, opts.Category}, nil); err != nil {
+			return fmt.Errorf("deluge: torrent added but labelling failed: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,250 @@
+package qbittorrent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"torrent-rss/internal/client"
+)
+
+func init() {
+	client.Register("qbittorrent", func(opts client.Options) (client.Client, error) {
+		return New(opts)
+	})
+}
+
+// Client talks to the qBittorrent Web API (v2)
+type Client struct {
+	baseURL  string
+	username string
+	password string
+	http     *http.Client
+
+	mu       sync.Mutex
+	loggedIn bool
+}
+
+func New(opts client.Options) (*Client, error) {
+	if opts.URL == "" {
+		return nil, fmt.Errorf("qbittorrent: URL is required")
+	}
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, fmt.Errorf("qbittorrent: failed to create cookie jar: %w", err)
+	}
+	return &Client{
+		baseURL:  strings.TrimRight(opts.URL, "/"),
+		username: opts.Username,
+		password: opts.Password,
+		http: &http.Client{
+			Jar:     jar,
+			Timeout: 30 * time.Second,
+		},
+	}, nil
+}
+
+// login obtains the SID session cookie, which the jar then sends on every call
+func (c *Client) login(ctx context.Context) error {
+	form := url.Values{
+		"username": {c.username},
+		"password": {c.password},
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/api/v2/auth/login", strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("qbittorrent: failed to create login request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// qBittorrent rejects requests whose Referer doesn't match its host (CSRF protection)
+	req.Header.Set("Referer", c.baseURL)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("qbittorrent: login failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || strings.TrimSpace(string(body)) != "Ok." {
+		return fmt.Errorf("qbittorrent: login rejected (%s): %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// AddTorrent uploads a .torrent file with the requested category and save path
+func (c *Client) AddTorrent(ctx context.Context, name string, data []byte, opts client.AddOptions) error {
+	return c.add(ctx, func(w *multipart.Writer) error {
+		part, err := w.CreateFormFile("torrents", name)
+		if err != nil {
+			return err
+		}
+		_, err = part.Write(data)
+		return err
+	}, opts)
+}
+
+// AddMagnet submits a magnet URI through the urls field
+func (c *Client) AddMagnet(ctx context.Context, uri string, opts client.AddOptions) error {
+	return c.add(ctx, func(w *multipart.Writer) error {
+		return w.WriteField("urls", uri)
+	}, opts)
+}
+
+// RemoveTorrent deletes a torrent, and optionally its files, by infohash
+func (c *Client) RemoveTorrent(ctx context.Context, infoHash string, deleteData bool) error {
+	form := url.Values{
+		"hashes":      {infoHash},
+		"deleteFiles": {fmt.Sprint(deleteData)},
+	}
+	_, err := c.post(ctx, "/api/v2/torrents/delete", func() (io.Reader, string, error) {
+		return strings.NewReader(form.Encode()), "application/x-www-form-urlencoded", nil
+	})
+	if err != nil {
+		return fmt.Errorf("qbittorrent: remove torrent failed: %w", err)
+	}
+	return nil
+}
+
+// FreeSpace returns the free space qBittorrent reports, which is always that
+// of its default save path
+func (c *Client) FreeSpace(ctx context.Context, savePath string) (int64, error) {
+	body, err := c.post(ctx, "/api/v2/sync/maindata", func() (io.Reader, string, error) {
+		return strings.NewReader("rid=0"), "application/x-www-form-urlencoded", nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("qbittorrent: failed to read free space: %w", err)
+	}
+	var data struct {
+		ServerState struct {
+			FreeSpaceOnDisk int64 `json:"free_space_on_disk"`
+		} `json:"server_state"`
+	}
+	if err := json.Unmarshal([]byte(body), &data); err != nil {
+		return 0, fmt.Errorf("qbittorrent: unexpected maindata response: %w", err)
+	}
+	return data.ServerState.FreeSpaceOnDisk, nil
+}
+
+// Torrents lists every torrent with its category and whether it's done
+func (c *Client) Torrents(ctx context.Context) ([]client.Torrent, error) {
+	body, err := c.post(ctx, "/api/v2/torrents/info", func() (io.Reader, string, error) {
+		return strings.NewReader(""), "application/x-www-form-urlencoded", nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("qbittorrent: failed to list torrents: %w", err)
+	}
+	var info []struct {
+		Hash     string  `json:"hash"`
+		Name     string  `json:"name"`
+		Category string  `json:"category"`
+		Progress float64 `json:"progress"` // 1 once every wanted file is done
+	}
+	if err := json.Unmarshal([]byte(body), &info); err != nil {
+		return nil, fmt.Errorf("qbittorrent: unexpected torrent list: %w", err)
+	}
+	torrents := make([]client.Torrent, 0, len(info))
+	for _, t := range info {
+		torrents = append(torrents, client.Torrent{InfoHash: strings.ToLower(t.Hash), Name: t.Name, Category: t.Category, Done: t.Progress >= 1})
+	}
+	return torrents, nil
+}
+
+// CheckSeeding accepts both limits, qBittorrent sets them per torrent
+func (c *Client) CheckSeeding(client.Seeding) error {
+	return nil
+}
+
+func (c *Client) add(ctx context.Context, writeSource func(*multipart.Writer) error, opts client.AddOptions) error {
+	body, err := c.post(ctx, "/api/v2/torrents/add", func() (io.Reader, string, error) {
+		var buf bytes.Buffer
+		w := multipart.NewWriter(&buf)
+		if err := writeSource(w); err != nil {
+			return nil, "", err
+		}
+		if opts.Category != "" {
+			w.WriteField("category", opts.Category)
+		}
+		if opts.SavePath != "" {
+			w.WriteField("savepath", opts.SavePath)
+			w.WriteField("autoTMM", "false")
+		}
+		if !opts.Seeding.IsZero() {
+			// -1 is no limit, so the global limits can't stop it earlier
+			ratio, minutes := -1.0, int64(-1)
+			if opts.Seeding.Ratio > 0 {
+				ratio = opts.Seeding.Ratio
+			}
+			if opts.Seeding.Time > 0 {
+				minutes = int64(math.Ceil(opts.Seeding.Time.Minutes()))
+			}
+			w.WriteField("ratioLimit", strconv.FormatFloat(ratio, 'f', -1, 64))
+			w.WriteField("seedingTimeLimit", strconv.FormatInt(minutes, 10))
+		}
+		if err := w.Close(); err != nil {
+			return nil, "", err
+		}
+		return &buf, w.FormDataContentType(), nil
+	})
+	if err != nil {
+		return fmt.Errorf("qbittorrent: add torrent failed: %w", err)
+	}
+	if body == "Fails." {
+		return fmt.Errorf("qbittorrent: torrent was rejected (invalid or duplicate)")
+	}
+	return nil
+}
+
+// post sends an API request, logging in first and once more if the session
+// expired. newBody is called per attempt since a body can only be read once.
+func (c *Client) post(ctx context.Context, path string, newBody func() (io.Reader, string, error)) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for attempt := 0; attempt < 2; attempt++ {
+		if !c.loggedIn {
+			if err := c.login(ctx); err != nil {
+				return "", err
+			}
+			c.loggedIn = true
+		}
+
+		body, contentType, err := newBody()
+		if err != nil {
+			return "", fmt.Errorf("failed to build request: %w", err)
+		}
+		req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+path, body)
+		if err != nil {
+			return "", fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Referer", c.baseURL)
+
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return "", err
+		}
+		respBody, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		switch {
+		case resp.StatusCode == http.StatusForbidden:
+			c.loggedIn = false
+			continue
+		case resp.StatusCode != http.StatusOK:
+			return "", fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+		}
+		return strings.TrimSpace(string(respBody)), nil
+	}
+	return "", fmt.Errorf("session rejected after re-login")
+}
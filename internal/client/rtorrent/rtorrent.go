@@ -0,0 +1,216 @@
+package rtorrent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"torrent-rss/internal/client"
+)
+
+func init() {
+	client.Register("rtorrent", func(opts client.Options) (client.Client, error) {
+		return New(opts)
+	})
+}
+
+// Client sends XML-RPC commands to rTorrent, either over HTTP (through a web
+// server's /RPC2 mount) or directly over SCGI with scgi://host:port or
+// scgi:///path/to/socket URLs
+type Client struct {
+	url      *url.URL
+	username string
+	password string
+	http     *http.Client
+}
+
+func New(opts client.Options) (*Client, error) {
+	if opts.URL == "" {
+		return nil, fmt.Errorf("rtorrent: URL is required")
+	}
+	u, err := url.Parse(opts.URL)
+	if err != nil {
+		return nil, fmt.Errorf("rtorrent: invalid URL: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "scgi":
+	default:
+		return nil, fmt.Errorf("rtorrent: unsupported URL scheme %q (use http, https or scgi)", u.Scheme)
+	}
+	return &Client{
+		url:      u,
+		username: opts.Username,
+		password: opts.Password,
+		http:     &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// AddTorrent loads and starts a .torrent file, setting the directory and label
+func (c *Client) AddTorrent(ctx context.Context, name string, data []byte, opts client.AddOptions) error {
+	return c.load(ctx, "load.raw_start", data, opts)
+}
+
+// AddMagnet loads and starts a magnet URI, rTorrent fetches the metadata via DHT
+func (c *Client) AddMagnet(ctx context.Context, uri string, opts client.AddOptions) error {
+	return c.load(ctx, "load.start", uri, opts)
+}
+
+// RemoveTorrent erases a torrent by infohash. rTorrent has no built-in
+// command to delete downloaded data, so deleteData cannot be honoured.
+func (c *Client) RemoveTorrent(ctx context.Context, infoHash string, deleteData bool) error {
+	return c.call(ctx, "d.erase", strings.ToUpper(infoHash))
+}
+
+// Torrents lists every torrent in the main view, with its ruTorrent label
+// and whether it's complete
+func (c *Client) Torrents(ctx context.Context) ([]client.Torrent, error) {
+	resp, err := c.request(ctx, "d.multicall2", "", "main", "d.hash=", "d.name=", "d.custom1=", "d.complete=")
+	if err != nil {
+		return nil, err
+	}
+	rows, err := decodeRows(resp)
+	if err != nil {
+		return nil, fmt.Errorf("rtorrent: d.multicall2: %w", err)
+	}
+	torrents := make([]client.Torrent, 0, len(rows))
+	for _, row := range rows {
+		if len(row) < 4 {
+			return nil, fmt.Errorf("rtorrent: d.multicall2: expected 4 values per torrent, got %d", len(row))
+		}
+		label, _ := url.QueryUnescape(row[2]) // ruTorrent stores labels URL-encoded
+		torrents = append(torrents, client.Torrent{InfoHash: strings.ToLower(row[0]), Name: row[1], Category: label, Done: row[3] == "1"})
+	}
+	return torrents, nil
+}
+
+func (c *Client) load(ctx context.Context, method string, source any, opts client.AddOptions) error {
+	// The first parameter is the (empty) target required by rTorrent 0.9+
+	params := []any{"", source}
+	if opts.SavePath != "" {
+		params = append(params, "d.directory.set="+quoteCommand(opts.SavePath))
+	}
+	if opts.Category != "" {
+		// custom1 is the label field used by ruTorrent and Flood
+		params = append(params, "d.custom1.set="+quoteCommand(opts.Category))
+	}
+
+	return c.call(ctx, method, params...)
+}
+
+// call sends a single XML-RPC method call over HTTP or SCGI
+func (c *Client) call(ctx context.Context, method string, params ...any) error {
+	_, err := c.request(ctx, method, params...)
+	return err
+}
+
+// request is call returning the response body, which is not a fault
+func (c *Client) request(ctx context.Context, method string, params ...any) ([]byte, error) {
+	body, err := encodeCall(method, params...)
+	if err != nil {
+		return nil, fmt.Errorf("rtorrent: %w", err)
+	}
+
+	var resp []byte
+	if c.url.Scheme == "scgi" {
+		resp, err = c.doSCGI(ctx, body)
+	} else {
+		resp, err = c.doHTTP(ctx, body)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("rtorrent: %s failed: %w", method, err)
+	}
+	if err := checkResponse(resp); err != nil {
+		return nil, fmt.Errorf("rtorrent: %s failed: %w", method, err)
+	}
+	return resp, nil
+}
+
+// quoteCommand quotes a value embedded in an rTorrent command string
+func quoteCommand(value string) string {
+	return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+}
+
+func (c *Client) doHTTP(ctx context.Context, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", c.url.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "text/xml")
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// doSCGI sends a single request over the SCGI protocol rTorrent speaks natively
+func (c *Client) doSCGI(ctx context.Context, body []byte) ([]byte, error) {
+	network, address := "tcp", c.url.Host
+	if c.url.Host == "" {
+		network, address = "unix", c.url.Path
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(30 * time.Second))
+	}
+
+	// Headers are a netstring of NUL-separated pairs, CONTENT_LENGTH first
+	var headers bytes.Buffer
+	for _, pair := range [][2]string{
+		{"CONTENT_LENGTH", strconv.Itoa(len(body))},
+		{"SCGI", "1"},
+		{"REQUEST_METHOD", "POST"},
+		{"REQUEST_URI", "/RPC2"},
+	} {
+		headers.WriteString(pair[0])
+		headers.WriteByte(0)
+		headers.WriteString(pair[1])
+		headers.WriteByte(0)
+	}
+
+	var req bytes.Buffer
+	fmt.Fprintf(&req, "%d:", headers.Len())
+	req.Write(headers.Bytes())
+	req.WriteByte(',')
+	req.Write(body)
+	if _, err := conn.Write(req.Bytes()); err != nil {
+		return nil, err
+	}
+
+	// The response is CGI style: headers, blank line, XML body
+	reader := bufio.NewReader(conn)
+	mime, err := textproto.NewReader(reader).ReadMIMEHeader()
+	if err != nil {
+		return nil, fmt.Errorf("invalid SCGI response: %w", err)
+	}
+	if status := mime.Get("Status"); status != "" && !strings.HasPrefix(status, "200") {
+		return nil, fmt.Errorf("unexpected status %s", status)
+	}
+	return io.ReadAll(reader)
+}
@@ -0,0 +1,123 @@
+package rtorrent
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// encodeCall builds an XML-RPC methodCall. Only strings and raw bytes
+// (sent as base64) are needed for rTorrent's load commands.
+func encodeCall(method string, params ...any) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0"?><methodCall><methodName>`)
+	xml.EscapeText(&buf, []byte(method))
+	buf.WriteString(`</methodName><params>`)
+	for _, param := range params {
+		buf.WriteString(`<param><value>`)
+		switch v := param.(type) {
+		case string:
+			buf.WriteString(`<string>`)
+			xml.EscapeText(&buf, []byte(v))
+			buf.WriteString(`</string>`)
+		case []byte:
+			buf.WriteString(`<base64>`)
+			buf.WriteString(base64.StdEncoding.EncodeToString(v))
+			buf.WriteString(`</base64>`)
+		default:
+			return nil, fmt.Errorf("unsupported XML-RPC parameter type %T", param)
+		}
+		buf.WriteString(`</value></param>`)
+	}
+	buf.WriteString(`</params></methodCall>`)
+	return buf.Bytes(), nil
+}
+
+type methodResponse struct {
+	Fault *struct {
+		Members []struct {
+			Name  string `xml:"name"`
+			Value struct {
+				Inner string `xml:",innerxml"`
+			} `xml:"value"`
+		} `xml:"value>struct>member"`
+	} `xml:"fault"`
+}
+
+// checkResponse returns the fault string if the response is an XML-RPC fault
+func checkResponse(body []byte) error {
+	var resp methodResponse
+	if err := xml.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("invalid XML-RPC response: %w", err)
+	}
+	if resp.Fault == nil {
+		return nil
+	}
+	for _, member := range resp.Fault.Members {
+		if member.Name == "faultString" {
+			return fmt.Errorf("%s", stripTags(member.Value.Inner))
+		}
+	}
+	return fmt.Errorf("unknown XML-RPC fault")
+}
+
+type value struct {
+	String string  `xml:"string"`
+	I4     string  `xml:"i4"`
+	I8     string  `xml:"i8"`
+	Int    string  `xml:"int"`
+	Array  []value `xml:"array>data>value"`
+	Text   string  `xml:",chardata"` // Untyped values are strings too
+}
+
+// text returns a scalar value as a string
+func (v value) text() string {
+	if v.String != "" {
+		return v.String
+	}
+	for _, number := range []string{v.I8, v.I4, v.Int} {
+		if number != "" {
+			return strings.TrimSpace(number)
+		}
+	}
+	return strings.TrimSpace(v.Text)
+}
+
+type rowsResponse struct {
+	Rows []value `xml:"params>param>value>array>data>value"`
+}
+
+// decodeRows reads the array of arrays a multicall returns
+func decodeRows(body []byte) ([][]string, error) {
+	var resp rowsResponse
+	if err := xml.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("invalid XML-RPC response: %w", err)
+	}
+	rows := make([][]string, 0, len(resp.Rows))
+	for _, row := range resp.Rows {
+		values := make([]string, 0, len(row.Array))
+		for _, v := range row.Array {
+			values = append(values, v.text())
+		}
+		rows = append(rows, values)
+	}
+	return rows, nil
+}
+
+func stripTags(s string) string {
+	var out strings.Builder
+	inTag := false
+	for _, r := range s {
+		switch {
+		case r == '<':
+			inTag = true
+		case r == '>':
+			inTag = false
+		case !inTag:
+			out.WriteRune(r)
+		}
+	}
+	return strings.TrimSpace(out.String())
+}
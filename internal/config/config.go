@@ -1,19 +1,500 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+
+	"torrent-rss/internal/bandwidth"
+	"torrent-rss/internal/bytesize"
+	"torrent-rss/internal/category"
+	"torrent-rss/internal/challenge"
+	"torrent-rss/internal/client"
+	"torrent-rss/internal/connpool"
+	"torrent-rss/internal/credentials"
+	"torrent-rss/internal/cron"
+	"torrent-rss/internal/delivery"
+	"torrent-rss/internal/dnscache"
+	"torrent-rss/internal/downloader"
+	"torrent-rss/internal/feed"
+	"torrent-rss/internal/filter"
+	"torrent-rss/internal/headers"
+	"torrent-rss/internal/history"
+	"torrent-rss/internal/login"
+	"torrent-rss/internal/metadata"
+	"torrent-rss/internal/metainfo"
+	"torrent-rss/internal/notify"
+	"torrent-rss/internal/parser"
+	"torrent-rss/internal/quality"
+	"torrent-rss/internal/release"
+	"torrent-rss/internal/retry"
+	"torrent-rss/internal/score"
+	"torrent-rss/internal/secrets"
+	"torrent-rss/internal/tlsconfig"
+	"torrent-rss/internal/tracker"
+)
+
+// DefaultWorkers is how many feeds the daemon polls at the same time
+const DefaultWorkers = 4
+
+// DefaultMaxFailures is how many polls in a row a failing item is tried on
+const DefaultMaxFailures = 5
+
+// DefaultCooldown is how long a tracker is left alone after a maintenance or
+// rate limit page
+const DefaultCooldown = 30 * time.Minute
+
+// DedupeKey is what identifies a feed item across polls in the history
+type DedupeKey string
+
+const (
+	DedupeGUID     DedupeKey = "guid"     // The item GUID, or its link without one
+	DedupeTitle    DedupeKey = "title"    // The release name, ignoring case and separators
+	DedupeInfoHash DedupeKey = "infohash" // The infohash the feed lists, or the download URL
+	DedupeURL      DedupeKey = "url"      // The download URL
 )
 
+// ParseDedupeKey validates a dedupe key name, where empty means DedupeGUID
+func ParseDedupeKey(name string) (DedupeKey, error) {
+	switch key := DedupeKey(strings.ToLower(strings.TrimSpace(name))); key {
+	case "":
+		return DedupeGUID, nil
+	case DedupeGUID, DedupeTitle, DedupeInfoHash, DedupeURL:
+		return key, nil
+	}
+	return "", fmt.Errorf("unknown dedupe key %q (available: guid, title, infohash, url)", name)
+}
+
+// PackPolicy is what a feed does with season packs
+type PackPolicy string
+
+const (
+	PacksAllow   PackPolicy = "allow"   // Grab packs like any release
+	PacksPrefer  PackPolicy = "prefer"  // Grab packs before the single episodes of the same poll
+	PacksSkip    PackPolicy = "skip"    // Never grab packs
+	PacksMissing PackPolicy = "missing" // Grab packs only when enough episodes of the season are missing
+)
+
+// DefaultPackMinMissing is how many episodes of a season must be missing for
+// PacksMissing to grab its pack
+const DefaultPackMinMissing = 1
+
+// ParsePackPolicy validates a season pack policy, where empty means PacksAllow
+func ParsePackPolicy(name string) (PackPolicy, error) {
+	switch policy := PackPolicy(strings.ToLower(strings.TrimSpace(name))); policy {
+	case "":
+		return PacksAllow, nil
+	case PacksAllow, PacksPrefer, PacksSkip, PacksMissing:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown season pack policy %q (available: allow, prefer, skip, missing)", name)
+}
+
 type Config struct {
-	SearchTerms   []string
-	DownloadPath  string
+	DownloadPath string
+	StateDir     string
+	// StateBackend stores the history: bolt, json, or sqlite in builds with
+	// the sqlite tag
+	StateBackend  string
 	CheckInterval string
 	BaseURL       string
 	UserID        string
 	RSSToken      string // For RSS feed
 	PassToken     string // For downloads
+	PollJitter    time.Duration
+	Workers       int    // Feeds polled at the same time
+	CookieKey     string // Encrypts the persisted cookie jar, defaults to SecretKey
+	// SecretKey is the master key of encrypted config values and the
+	// history database, from TD_SECRET_KEY or the OS keyring
+	SecretKey    string
+	APIAddr      string // Listen address of the HTTP API in daemon mode, empty disables it
+	APIAuth      APIAuth
+	Retry        retry.Policy
+	MaxRedirects int // Redirects a tracker request follows, 0 uses the downloader's default
+	// MinFreeSpace pauses grabbing while a feed's destination has fewer
+	// bytes free, 0 disables the check
+	MinFreeSpace int64
+	// CompletionCheck asks the torrent clients this often which of the
+	// torrents sent to them finished downloading, 0 never does
+	CompletionCheck time.Duration
+	// MaxTorrentSize is the most bytes a torrent download may have, 0 uses
+	// the downloader's default
+	MaxTorrentSize int64
+	// BandwidthLimit caps the bytes per second of all torrent downloads
+	// together, 0 doesn't limit
+	BandwidthLimit int64
+	Debug          bool // Logs details like every redirect hop
+	// NameTemplate names saved torrents after the parts of their release
+	// name, nil strips the tags
+	NameTemplate *release.Template
+	// Tracker connect and response timeouts
+	ConnectTimeout time.Duration
+	ReadTimeout    time.Duration
+	// DNSCacheTTL is how long host lookups are reused, 0 looks up every
+	// new connection
+	DNSCacheTTL time.Duration
+	// Connections tunes the connections to feed hosts, and to trackers
+	// without settings of their own
+	Connections connpool.Options
+	// FlareSolverr solves Cloudflare and DDoS-Guard challenges, empty URL
+	// leaves challenged requests failing
+	FlareSolverrURL     string
+	FlareSolverrTimeout time.Duration
+	// MetadataProvider looks releases up on TMDb or TVDb, empty disables it
+	MetadataProvider string
+	MetadataAPIKey   string
+	MetadataLanguage string // e.g. "en-US", for canonical titles
+	MetadataURL      string // Overrides the provider's API URL
+	Trackers         map[string]TrackerConfig
+	Clients          map[string]ClientConfig
+	Deliveries       map[string]DeliveryConfig
+	Notifiers        map[string]NotifierConfig
+	Feeds            []Feed
+	// ContentTypes tell releases apart by their feed categories, tried in
+	// order
+	ContentTypes []ContentType
+	// TrackerPriority orders trackers from most to least preferred, for
+	// releases carried by several of them
+	TrackerPriority []string
+}
+
+// TrackerConfig configures a tracker adapter
+type TrackerConfig struct {
+	Type         string // Registered tracker adapter name
+	BaseURL      string
+	LinkSelector string // Download anchor selector, required for the generic adapter
+	// FreeleechSelector matches an element only present on freeleech pages,
+	// for the generic adapter
+	FreeleechSelector string
+	// DirectURL builds download URLs from the torrent ID and passkey instead
+	// of scraping the torrent page, e.g. ".../download.php?torrent={id}&passkey={passkey}"
+	DirectURL string
+	// SearchURL is the search page with {query} for the search, and
+	// SearchSelector matches each result's link on it, for the generic adapter
+	SearchURL      string
+	SearchSelector string
+	Passkey        string // Defaults to the passkey in the RSS URL of the tracker's feeds
+	// Resolvers are the ways tried in order to get an item's torrent
+	Resolvers []downloader.Resolver
+	Cookie    string // Defaults to the credentials cookie
+	Login     *LoginConfig
+	// Auth picks how requests carry credentials: the cookie by default, or
+	// an API key as bearer token, query parameter or basic auth
+	Auth  tracker.AuthOptions
+	Proxy *url.URL // Routes page fetches and downloads, not feed polls
+	// Headers is the browser profile sent with every request to the tracker
+	Headers headers.Profile
+	// RateLimit caps requests per minute to the tracker's hosts, counting
+	// feed polls, page fetches and downloads together. 0 means no limit.
+	RateLimit int
+	// Cooldown is how long the tracker's feeds are skipped after it answers
+	// with a maintenance or rate limit page. 0 only stops that poll.
+	Cooldown time.Duration
+	// Announce rewrites and adds announce URLs in the tracker's torrents,
+	// {passkey} is filled with the tracker's passkey
+	Announce metainfo.AnnounceRules
+	// TLS sets a CA bundle or client certificate for the tracker's hosts,
+	// or turns certificate checks off
+	TLS tlsconfig.Options
+	// Connections tunes the connections to the tracker's hosts, e.g.
+	// sticking to HTTP/1.1 for trackers that misbehave over HTTP/2
+	Connections connpool.Options
+	// BandwidthLimit caps the bytes per second of the tracker's torrent
+	// downloads, on top of the global limit. 0 doesn't limit.
+	BandwidthLimit int64
+	// GrabDelay spaces out torrent downloads from the tracker, across all
+	// of its feeds, for trackers that flag clients grabbing in bursts
+	GrabDelay Delay
+	// PageWorkers scrapes up to this many torrent pages of a poll at once,
+	// ahead of their downloads. 0 or 1 scrapes each page when it's needed.
+	PageWorkers int
+}
+
+// ContentType is a kind of release like TV or movies, told by the
+// categories feeds put items in. The destination fields that are set
+// replace the feed's for releases of the type.
+type ContentType struct {
+	Type         *category.Type
+	Client       string
+	Delivery     string
+	DownloadPath string // Only without Client or Delivery
+	Category     string
+	SavePath     string
+}
+
+// Route returns feed with its destination replaced by the type's, for a
+// release of the type. A nil type leaves the feed as it is.
+func (t *ContentType) Route(feed Feed) Feed {
+	if t == nil {
+		return feed
+	}
+	switch {
+	case t.Client != "":
+		feed.Client, feed.Delivery, feed.DownloadPath, feed.Category, feed.SavePath = t.Client, "", "", "", ""
+	case t.Delivery != "":
+		feed.Client, feed.Delivery, feed.DownloadPath, feed.Category, feed.SavePath = "", t.Delivery, "", "", ""
+	case t.DownloadPath != "":
+		feed.Client, feed.Delivery, feed.DownloadPath, feed.Category, feed.SavePath = "", "", t.DownloadPath, "", ""
+	}
+	// The type's folder isn't the one the feed's client watches
+	if t.Client != "" || t.Delivery != "" || t.DownloadPath != "" {
+		feed.WatchClient = ""
+	}
+	if t.Category != "" {
+		feed.Category = t.Category
+	}
+	if t.SavePath != "" {
+		feed.SavePath = t.SavePath
+	}
+	return feed
+}
+
+// DetectContentType returns the first of types that categories belong to,
+// nil when there are no categories or none matches
+func DetectContentType(types []ContentType, categories []string) *ContentType {
+	for i := range types {
+		if types[i].Type.Match(categories) {
+			return &types[i]
+		}
+	}
+	return nil
+}
+
+// defaultContentTypes are the category.Defaults, without destinations
+func defaultContentTypes() []ContentType {
+	var types []ContentType
+	for _, t := range category.DefaultTypes() {
+		types = append(types, ContentType{Type: t})
+	}
+	return types
+}
+
+// Delay is a pause of Min, or of a random length between Min and Max
+type Delay struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+// ParseDelay reads a duration like "30s", or a range like "10s-1m"
+func ParseDelay(value string) (Delay, error) {
+	low, high, isRange := strings.Cut(strings.TrimSpace(value), "-")
+	if !isRange {
+		high = low
+	}
+	var d Delay
+	var errMin, errMax error
+	d.Min, errMin = time.ParseDuration(strings.TrimSpace(low))
+	d.Max, errMax = time.ParseDuration(strings.TrimSpace(high))
+	if errMin != nil || errMax != nil {
+		return Delay{}, fmt.Errorf("must be a duration like 30s or a range like 10s-1m, got %q", value)
+	}
+	if d.Min < 0 || d.Max < d.Min {
+		return Delay{}, fmt.Errorf("must run from a shorter to a longer duration, got %q", value)
+	}
+	return d, nil
+}
+
+// IsZero reports whether there's no delay at all
+func (d Delay) IsZero() bool {
+	return d.Max <= 0
+}
+
+func (d Delay) String() string {
+	if d.Min == d.Max {
+		return d.Min.String()
+	}
+	return d.Min.String() + "-" + d.Max.String()
+}
+
+// LoginConfig lets the downloader log into a tracker with a username and
+// password instead of a static cookie
+type LoginConfig struct {
+	URL           string
+	Username      string
+	Password      string
+	UsernameField string
+	PasswordField string
+}
+
+// APIAuth are the credentials the HTTP API asks for, none leaves it open.
+// Key and basic auth with Username and Password give full access, ReadKey
+// only to what doesn't change anything, e.g. for dashboards.
+type APIAuth struct {
+	Key      string
+	ReadKey  string
+	Username string
+	Password string
+}
+
+// Enabled tells whether the API asks for credentials
+func (a APIAuth) Enabled() bool {
+	return a.Key != "" || a.ReadKey != "" || a.Username != ""
+}
+
+// Validate checks that the credentials go together
+func (a APIAuth) Validate() error {
+	switch {
+	case (a.Username == "") != (a.Password == ""):
+		return errors.New("basic auth needs both a username and a password")
+	case a.ReadKey != "" && a.ReadKey == a.Key:
+		return errors.New("the read-only key must differ from the full-access key")
+	case a.ReadKey != "" && a.Key == "" && a.Username == "":
+		return errors.New("a read-only key needs a full-access key or basic auth as well")
+	}
+	return nil
+}
+
+// ClientConfig configures a torrent client that torrents are pushed to
+type ClientConfig struct {
+	Type     string // Registered client backend name
+	URL      string
+	Username string
+	Password string
+	Category string
+	SavePath string
+}
+
+// DeliveryConfig configures somewhere other than a torrent client or the
+// download directory that feeds can deliver torrents to
+type DeliveryConfig struct {
+	Type          string // Registered delivery backend name
+	Path          string
+	URL           string
+	KeyFile       string
+	KeyPassphrase string
+	KnownHosts    string
+	Timeout       time.Duration
+}
+
+// NotifierConfig configures a notification channel
+type NotifierConfig struct {
+	Type     string // Registered notifier name
+	URL      string
+	Username string
+	Password string
+	From     string
+	To       []string
+	Method   string
+	Headers  map[string]string
+	Template string
+	Events   []notify.Kind
+	// Digest batches notifications into one message a day at DigestAt
+	Digest   bool
+	DigestAt time.Duration
+}
+
+// Feed is a single RSS feed polled by the daemon
+type Feed struct {
+	Name    string
+	URL     string
+	Tracker string // Key into Config.Trackers
+	Client  string // Key into Config.Clients, empty to save into DownloadPath
+	// Delivery is a key into Config.Deliveries, used instead of a client
+	Delivery string
+	// Per-feed destination, overriding Config.DownloadPath, the client's
+	// category and save path or the delivery's path
+	DownloadPath string
+	Category     string
+	SavePath     string
+	SearchTerms  []string
+	// ContentTypes only grabs releases of these types, by name. Empty grabs
+	// every release, and so do feeds that don't categorize items.
+	ContentTypes []string
+	Interval     time.Duration
+	// Schedule polls the feed at the times of a cron expression instead of
+	// every Interval, nil polls on the interval
+	Schedule *cron.Schedule
+	Filter   *filter.Filter
+	// Groups rejects releases of banned groups, and of any group that isn't
+	// preferred if it says so. Preferred groups are grabbed first when a
+	// poll holds several releases. Nil accepts every group.
+	Groups *filter.Groups
+	// Formats reads releases as music and only grabs those in these formats,
+	// the first listed grabbed first. Nil accepts every release.
+	Formats *filter.Formats
+	// Torznab queries URL as a Jackett or Prowlarr Torznab endpoint, searching
+	// for each search term, instead of reading it as an RSS feed
+	Torznab *parser.Torznab
+	// JSONAPI reads URL as a tracker's JSON API response, picking the fields
+	// of items out with JSONPath, instead of as an RSS, Atom or JSON feed
+	JSONAPI *feed.JSONMapping
+	// MinSize and MaxSize skip releases outside the range, 0 means no limit.
+	// Releases whose feed doesn't say how big they are always pass.
+	MinSize int64
+	MaxSize int64
+	// FreeleechOnly skips releases that neither the feed nor the tracker's
+	// torrent page mark as freeleech
+	FreeleechOnly bool
+	// Watchlist only grabs releases of titles on the watch-list
+	Watchlist bool
+	// WatchlistSearch also searches the tracker for every watch-list title
+	// this often, to find what the feed missed, 0 never does
+	WatchlistSearch time.Duration
+	// WatchClient is the client watching the feed's download folder, asked
+	// whether the torrents written there completed like those sent to a
+	// client are. RemoveTorrentFile then removes each torrent's file once
+	// it did.
+	WatchClient       string
+	RemoveTorrentFile bool
+	// Seeding has the feed's client seed each torrent to a ratio or for a
+	// time before it may stop, as private trackers ask. Zero leaves it to
+	// the client's settings.
+	Seeding client.Seeding
+	// TrackEpisodes grabs each episode of a show only once, whichever release comes first
+	TrackEpisodes bool
+	// Quality restricts accepted releases and drives upgrades, nil accepts anything
+	Quality *quality.Profile
+	// RemoveUpgraded removes the superseded release from the torrent client
+	RemoveUpgraded bool
+	// ReplacePropers grabs a PROPER, REPACK or RERIP of an episode that was
+	// already grabbed in the same quality, and RemoveReplaced then removes
+	// the release it fixes from the torrent client
+	ReplacePropers bool
+	RemoveReplaced bool
+	// Scoring ranks releases that pass the filters, so the best release of
+	// an episode is grabbed rather than the first. Nil keeps feed order.
+	Scoring *score.Profile
+	// MaxFailures is how many failed downloads of an item are retried on
+	// later polls before it's given up on until `retry-failed`
+	MaxFailures int
+	// IgnoreOlderThan skips items published longer ago, 0 keeps them all.
+	// Items without a publish date are never too old.
+	IgnoreOlderThan time.Duration
+	// MaxItemsPerPoll only looks at the first items of each poll, 0 means
+	// no limit. Together they keep the first poll of a long feed from
+	// grabbing its whole backlog.
+	MaxItemsPerPoll int
+	// PageParam is the query parameter that picks a later page of the
+	// feed, for `backfill`. Empty means the feed has a single page.
+	PageParam string
+	// Approval holds matched items until they're approved through the API
+	// or the `pending` command, instead of grabbing them
+	Approval bool
+	// DedupeKey picks what identifies an item in the history, for trackers
+	// that reuse GUIDs or rotate download URLs
+	DedupeKey DedupeKey
+	// SeasonPacks decides whether season packs are grabbed, and
+	// PackMinMissing how many of the season's episodes must be missing when
+	// it's PacksMissing
+	SeasonPacks    PackPolicy
+	PackMinMissing int
+	// Private marks the feed's tracker as private, so torrents without the
+	// private flag, which clients would share over DHT and PEX, and magnet
+	// links aren't delivered
+	Private bool
+	// StaleAfter alerts once the feed has gone that long without a poll that
+	// returned items, and FailingAfter once it has gone that long without a
+	// poll that succeeded, e.g. after the passkey expired or the tracker
+	// died. 0 turns either off.
+	StaleAfter   time.Duration
+	FailingAfter time.Duration
 }
 
 func NewConfig() *Config {
@@ -29,6 +510,19 @@ func NewConfig() *Config {
 		downloadPath = filepath.Join(homeDir, "Downloads", "torrents")
 	}
 
+	// Get state directory for the history database
+	stateDir := os.Getenv("TD_STATE_DIR")
+	if stateDir == "" {
+		stateDir = filepath.Join(homeDir, ".torrent-rss")
+	}
+	stateBackend := os.Getenv("TD_STATE_BACKEND")
+	if stateBackend == "" {
+		stateBackend = history.DefaultBackend
+	}
+	if !slices.Contains(history.Backends(), stateBackend) {
+		panic(fmt.Sprintf("TD_STATE_BACKEND: unknown state backend %q (available: %v)", stateBackend, history.Backends()))
+	}
+
 	// Get base URL from environment
 	baseURL := os.Getenv("TD_BASE_URL")
 	if baseURL == "" {
@@ -48,35 +542,799 @@ func NewConfig() *Config {
 		checkInterval = "0 */12 * * *" // default to every 12 hours
 	}
 
-	// Get authentication tokens
-	userID := os.Getenv("TD_USER_ID")
-	passToken := os.Getenv("TD_TOKEN")
-	rssToken := os.Getenv("TD_RSS_TOKEN")
+	// Get include/exclude regex filters, by default only 1080p releases are grabbed
+	include := []string{"1080p"}
+	if value, ok := os.LookupEnv("TD_INCLUDE"); ok {
+		include = splitList(value)
+	}
+	feedFilter, err := filter.New(include, splitList(os.Getenv("TD_EXCLUDE")))
+	if err != nil {
+		panic("TD_INCLUDE/TD_EXCLUDE: " + err.Error())
+	}
+
+	// Get optional release group lists, e.g. "NTb,FLUX" and "YIFY"
+	var groups *filter.Groups
+	preferGroups, banGroups := splitList(os.Getenv("TD_PREFER_GROUPS")), splitList(os.Getenv("TD_BAN_GROUPS"))
+	if len(preferGroups) > 0 || len(banGroups) > 0 {
+		groups, err = filter.NewGroups(preferGroups, banGroups, os.Getenv("TD_PREFERRED_GROUPS_ONLY") == "true")
+		if err != nil {
+			panic("TD_PREFER_GROUPS/TD_BAN_GROUPS: " + err.Error())
+		}
+	}
+
+	// Get optional music formats, e.g. "FLAC" or "FLAC,V0"
+	var formats *filter.Formats
+	if list := splitList(os.Getenv("TD_FORMATS")); len(list) > 0 {
+		if formats, err = filter.NewFormats(list); err != nil {
+			panic("TD_FORMATS: " + err.Error())
+		}
+	}
+
+	// Get optional size limits, e.g. "200MB" and "20GB"
+	minSize := sizeEnv("TD_MIN_SIZE")
+	maxSize := sizeEnv("TD_MAX_SIZE")
+	if maxSize > 0 && minSize > maxSize {
+		panic("TD_MIN_SIZE is larger than TD_MAX_SIZE")
+	}
+
+	// Get optional quality profile, e.g. "1080p WEB-DL,1080p,720p"
+	var qualityProfile *quality.Profile
+	if tiers := splitList(os.Getenv("TD_QUALITY")); len(tiers) > 0 {
+		qualityProfile, err = quality.NewProfile(tiers, os.Getenv("TD_QUALITY_CUTOFF"), os.Getenv("TD_QUALITY_UPGRADE") == "true")
+		if err != nil {
+			panic("TD_QUALITY: " + err.Error())
+		}
+		if err := qualityProfile.SetDelays(delaysEnv("TD_QUALITY_DELAY")); err != nil {
+			panic("TD_QUALITY_DELAY: " + err.Error())
+		}
+		if qualityProfile.HasDelays() && os.Getenv("TD_TRACK_EPISODES") == "false" {
+			panic("TD_QUALITY_DELAY needs TD_TRACK_EPISODES")
+		}
+	}
+
+	// Get optional release scores, e.g. "1080p=30,720p=10"
+	var scoring *score.Profile
+	if scoreOpts, ok := scoreEnv(); ok {
+		if scoring, err = score.New(scoreOpts); err != nil {
+			panic("TD_SCORE_*: " + err.Error())
+		}
+		if scoring.Window() > 0 && os.Getenv("TD_TRACK_EPISODES") == "false" {
+			panic("TD_SCORE_WINDOW needs TD_TRACK_EPISODES")
+		}
+	}
+
+	watchlistSearch := durationEnv("TD_WATCHLIST_SEARCH", 0)
+	if watchlistSearch > 0 && os.Getenv("TD_WATCHLIST") != "true" {
+		panic("TD_WATCHLIST_SEARCH needs TD_WATCHLIST")
+	}
+
+	// Get daemon polling interval and jitter
+	pollInterval := durationEnv("TD_POLL_INTERVAL", 12*time.Hour)
+	pollJitter := durationEnv("TD_POLL_JITTER", 5*time.Minute)
+	var pollSchedule *cron.Schedule
+	if value := os.Getenv("TD_SCHEDULE"); value != "" {
+		if pollSchedule, err = cron.Parse(value); err != nil {
+			panic("TD_SCHEDULE: " + err.Error())
+		}
+	}
+
+	// Get optional notification channels
+	notifiers := make(map[string]NotifierConfig)
+	if webhook := os.Getenv("TD_DISCORD_WEBHOOK"); webhook != "" {
+		events, err := notify.ParseKinds(splitList(os.Getenv("TD_DISCORD_EVENTS")))
+		if err != nil {
+			panic("TD_DISCORD_EVENTS: " + err.Error())
+		}
+		notifiers["discord"] = NotifierConfig{Type: "discord", URL: webhook, Events: events}
+	}
+	if smtpURL := os.Getenv("TD_SMTP_URL"); smtpURL != "" {
+		events, err := notify.ParseKinds(splitList(os.Getenv("TD_SMTP_EVENTS")))
+		if err != nil {
+			panic("TD_SMTP_EVENTS: " + err.Error())
+		}
+		nc := NotifierConfig{
+			Type:     "email",
+			URL:      smtpURL,
+			Username: os.Getenv("TD_SMTP_USERNAME"),
+			Password: os.Getenv("TD_SMTP_PASSWORD"),
+			From:     os.Getenv("TD_SMTP_FROM"),
+			To:       splitList(os.Getenv("TD_SMTP_TO")),
+			Events:   events,
+		}
+		if digest := os.Getenv("TD_SMTP_DIGEST"); digest != "" {
+			if nc.DigestAt, err = notify.ParseTimeOfDay(digest); err != nil {
+				panic("TD_SMTP_DIGEST: " + err.Error())
+			}
+			nc.Digest = true
+		}
+		notifiers["email"] = nc
+	}
+	if webhookURL := os.Getenv("TD_WEBHOOK_URL"); webhookURL != "" {
+		events, err := notify.ParseKinds(splitList(os.Getenv("TD_WEBHOOK_EVENTS")))
+		if err != nil {
+			panic("TD_WEBHOOK_EVENTS: " + err.Error())
+		}
+		notifiers["webhook"] = NotifierConfig{
+			Type:     "webhook",
+			URL:      webhookURL,
+			Template: os.Getenv("TD_WEBHOOK_TEMPLATE"),
+			Events:   events,
+		}
+	}
+
+	// Get retry policy for feed, page and torrent requests
+	retryPolicy := retry.DefaultPolicy()
+	retryPolicy.Attempts = intEnv("TD_RETRY_ATTEMPTS", retryPolicy.Attempts)
+	retryPolicy.Backoff = durationEnv("TD_RETRY_BACKOFF", retryPolicy.Backoff)
+
+	// Get tracker timeouts
+	connectTimeout := durationEnv("TD_CONNECT_TIMEOUT", 10*time.Second)
+	readTimeout := durationEnv("TD_READ_TIMEOUT", 30*time.Second)
+
+	// Get optional FlareSolverr for trackers behind anti-bot challenges
+	flareSolverrURL := os.Getenv("TD_FLARESOLVERR_URL")
+	flareSolverrTimeout := durationEnv("TD_FLARESOLVERR_TIMEOUT", challenge.DefaultFlareSolverrTimeout)
+	if flareSolverrURL != "" {
+		if _, err := challenge.NewFlareSolverr(flareSolverrURL, flareSolverrTimeout); err != nil {
+			panic("TD_FLARESOLVERR_URL: " + err.Error())
+		}
+	}
+
+	// Get optional metadata provider for canonical titles and IDs
+	metadataProvider := os.Getenv("TD_METADATA_PROVIDER")
+	metadataOptions := metadata.Options{
+		APIKey:   os.Getenv("TD_METADATA_API_KEY"),
+		Language: os.Getenv("TD_METADATA_LANGUAGE"),
+		BaseURL:  os.Getenv("TD_METADATA_URL"),
+	}
+	if metadataProvider != "" {
+		if _, err := metadata.New(metadataProvider, metadataOptions); err != nil {
+			panic("TD_METADATA_PROVIDER: " + err.Error())
+		}
+	}
 
-	if userID == "" || passToken == "" || rssToken == "" {
+	// Get authentication tokens from the environment, falling back to the OS keyring
+	creds, err := credentials.Chain{
+		credentials.Env{},
+		credentials.Keyring{Service: "torrent-rss"},
+	}.Credentials()
+	if err != nil {
+		panic("Could not read credentials: " + err.Error())
+	}
+	if !creds.Complete() {
 		panic("TD_USER_ID, TD_TOKEN, and TD_RSS_TOKEN environment variables are required")
 	}
 
-	return &Config{
+	// Get tracker adapter, TorrentDay unless configured otherwise
+	trackerName := os.Getenv("TD_TRACKER")
+	if trackerName == "" {
+		trackerName = "torrentday"
+	}
+	linkSelector := os.Getenv("TD_LINK_SELECTOR")
+	if trackerName == "generic" && linkSelector == "" {
+		panic("TD_LINK_SELECTOR environment variable is required for the generic tracker")
+	}
+	if trackerName == "generic" && watchlistSearch > 0 && os.Getenv("TD_SEARCH_URL") == "" {
+		panic("TD_WATCHLIST_SEARCH needs TD_SEARCH_URL for the generic tracker")
+	}
+
+	// Get optional resolver order, e.g. "direct,scrape"
+	resolvers, err := downloader.ParseResolvers(splitList(os.Getenv("TD_RESOLVERS")))
+	if err != nil {
+		panic("TD_RESOLVERS: " + err.Error())
+	}
+
+	// Get optional login form, used instead of the credentials cookie
+	var loginConfig *LoginConfig
+	if loginURL := os.Getenv("TD_LOGIN_URL"); loginURL != "" {
+		loginConfig = &LoginConfig{
+			URL:           loginURL,
+			Username:      os.Getenv("TD_LOGIN_USERNAME"),
+			Password:      os.Getenv("TD_LOGIN_PASSWORD"),
+			UsernameField: os.Getenv("TD_LOGIN_USERNAME_FIELD"),
+			PasswordField: os.Getenv("TD_LOGIN_PASSWORD_FIELD"),
+		}
+		if loginConfig.Username == "" || loginConfig.Password == "" {
+			panic("TD_LOGIN_USERNAME and TD_LOGIN_PASSWORD environment variables are required when TD_LOGIN_URL is set")
+		}
+	}
+
+	// Get optional API key auth, used instead of the cookie
+	authOptions := tracker.AuthOptions{
+		Type:     os.Getenv("TD_AUTH_TYPE"),
+		Token:    os.Getenv("TD_AUTH_TOKEN"),
+		Param:    os.Getenv("TD_AUTH_PARAM"),
+		Username: os.Getenv("TD_AUTH_USERNAME"),
+		Password: os.Getenv("TD_AUTH_PASSWORD"),
+	}
+	if _, err := tracker.NewAuth(authOptions, ""); err != nil {
+		panic("TD_AUTH_TYPE: " + err.Error())
+	}
+
+	// Get browser header profile, optionally customized
+	profile, err := headers.Named(os.Getenv("TD_HEADER_PROFILE"))
+	if err != nil {
+		panic("TD_HEADER_PROFILE: " + err.Error())
+	}
+	customHeaders, err := headers.ParseList(strings.FieldsFunc(os.Getenv("TD_HEADERS"), func(r rune) bool { return r == '|' }))
+	if err != nil {
+		panic("TD_HEADERS: " + err.Error())
+	}
+	profile = profile.Merge(headers.Profile{
+		UserAgent:      os.Getenv("TD_USER_AGENT"),
+		AcceptLanguage: os.Getenv("TD_ACCEPT_LANGUAGE"),
+		Headers:        customHeaders,
+	})
+
+	// Get optional proxy for tracker requests
+	var proxy *url.URL
+	if value := os.Getenv("TD_PROXY"); value != "" {
+		if proxy, err = downloader.ParseProxy(value); err != nil {
+			panic("TD_PROXY: " + err.Error())
+		}
+	}
+
+	tlsOptions := tlsconfig.Options{
+		CAFile:             os.Getenv("TD_TLS_CA_FILE"),
+		CertFile:           os.Getenv("TD_TLS_CERT_FILE"),
+		KeyFile:            os.Getenv("TD_TLS_KEY_FILE"),
+		InsecureSkipVerify: os.Getenv("TD_TLS_INSECURE_SKIP_VERIFY") == "true",
+	}
+	if _, err := tlsOptions.Config(); err != nil {
+		panic("TD_TLS_*: " + err.Error())
+	}
+
+	connections := connpool.Options{
+		MaxIdlePerHost: intEnv("TD_MAX_IDLE_CONNS_PER_HOST", connpool.DefaultMaxIdlePerHost),
+		IdleTimeout:    durationEnv("TD_IDLE_CONN_TIMEOUT", connpool.DefaultIdleTimeout),
+		DisableHTTP2:   os.Getenv("TD_HTTP2") == "false",
+	}
+	if err := connections.Validate(); err != nil {
+		panic("TD_IDLE_CONN_TIMEOUT: " + err.Error())
+	}
+
+	rewrites, err := metainfo.ParseRewrites(strings.FieldsFunc(os.Getenv("TD_ANNOUNCE_REWRITE"), func(r rune) bool { return r == '|' }))
+	if err != nil {
+		panic("TD_ANNOUNCE_REWRITE: " + err.Error())
+	}
+	announce := metainfo.AnnounceRules{Rewrite: rewrites, Append: splitList(os.Getenv("TD_ANNOUNCE_APPEND"))}
+	if err := announce.Validate(); err != nil {
+		panic("TD_ANNOUNCE_REWRITE/TD_ANNOUNCE_APPEND: " + err.Error())
+	}
+
+	var nameTemplate *release.Template
+	if value := os.Getenv("TD_NAME_TEMPLATE"); value != "" {
+		if nameTemplate, err = release.NewTemplate(value); err != nil {
+			panic("TD_NAME_TEMPLATE: " + err.Error())
+		}
+	}
+
+	dedupeKey, err := ParseDedupeKey(os.Getenv("TD_DEDUPE_KEY"))
+	if err != nil {
+		panic("TD_DEDUPE_KEY: " + err.Error())
+	}
+
+	seasonPacks, err := ParsePackPolicy(os.Getenv("TD_SEASON_PACKS"))
+	if err != nil {
+		panic("TD_SEASON_PACKS: " + err.Error())
+	}
+
+	// Get optional master key that encrypts the cookie jar and history
+	secretKey, err := secrets.MasterKey()
+	if err != nil {
+		panic("TD_SECRET_KEY: " + err.Error())
+	}
+	cookieKey := os.Getenv("TD_COOKIE_KEY")
+	if cookieKey == "" {
+		cookieKey = secretKey
+	}
+
+	// Get optional torrent client settings
+	clientName := os.Getenv("TD_CLIENT")
+	clients := make(map[string]ClientConfig)
+	if clientName != "" {
+		clients[clientName] = ClientConfig{
+			Type:     clientName,
+			URL:      os.Getenv("TD_CLIENT_URL"),
+			Username: os.Getenv("TD_CLIENT_USERNAME"),
+			Password: os.Getenv("TD_CLIENT_PASSWORD"),
+			Category: os.Getenv("TD_CLIENT_CATEGORY"),
+			SavePath: os.Getenv("TD_CLIENT_SAVE_PATH"),
+		}
+		if clients[clientName].URL == "" {
+			panic("TD_CLIENT_URL environment variable is required when TD_CLIENT is set")
+		}
+	}
+
+	cfg := &Config{
+		DownloadPath:   downloadPath,
+		StateDir:       stateDir,
+		StateBackend:   stateBackend,
+		CheckInterval:  checkInterval,
+		BaseURL:        strings.TrimRight(baseURL, "/"),
+		UserID:         creds.UserID,
+		RSSToken:       creds.RSSToken,
+		PassToken:      creds.PassToken,
+		PollJitter:     pollJitter,
+		Workers:        intEnv("TD_WORKERS", DefaultWorkers),
+		CookieKey:      cookieKey,
+		SecretKey:      secretKey,
+		Retry:          retryPolicy,
+		MaxRedirects:   intEnv("TD_MAX_REDIRECTS", 0),
+		MinFreeSpace:   sizeEnv("TD_MIN_FREE_SPACE"),
+		MaxTorrentSize: sizeEnv("TD_MAX_TORRENT_SIZE"),
+		BandwidthLimit: rateEnv("TD_BANDWIDTH_LIMIT"),
+		Debug:          os.Getenv("TD_DEBUG") == "true",
+		NameTemplate:   nameTemplate,
+		ConnectTimeout: connectTimeout,
+		ReadTimeout:    readTimeout,
+		DNSCacheTTL:    durationEnv("TD_DNS_CACHE_TTL", dnscache.DefaultTTL),
+		Connections:    connections,
+		APIAddr:        os.Getenv("TD_API_ADDR"),
+		APIAuth: APIAuth{
+			Key:      os.Getenv("TD_API_KEY"),
+			ReadKey:  os.Getenv("TD_API_READ_KEY"),
+			Username: os.Getenv("TD_API_USERNAME"),
+			Password: os.Getenv("TD_API_PASSWORD"),
+		},
+		Trackers: map[string]TrackerConfig{
+			trackerName: {
+				Type:              trackerName,
+				BaseURL:           strings.TrimRight(baseURL, "/"),
+				LinkSelector:      linkSelector,
+				FreeleechSelector: os.Getenv("TD_FREELEECH_SELECTOR"),
+				DirectURL:         os.Getenv("TD_DIRECT_URL"),
+				SearchURL:         os.Getenv("TD_SEARCH_URL"),
+				SearchSelector:    os.Getenv("TD_SEARCH_SELECTOR"),
+				Passkey:           os.Getenv("TD_PASSKEY"),
+				Resolvers:         resolvers,
+				Login:             loginConfig,
+				Auth:              authOptions,
+				Proxy:             proxy,
+				TLS:               tlsOptions,
+				Connections:       connections,
+				Headers:           profile,
+				RateLimit:         intEnv("TD_RATE_LIMIT", 0),
+				PageWorkers:       intEnv("TD_PAGE_WORKERS", 0),
+				Cooldown:          durationEnv("TD_TRACKER_COOLDOWN", DefaultCooldown),
+				Announce:          announce,
+				GrabDelay:         delayEnv("TD_GRAB_DELAY"),
+			},
+		},
+		Clients:             clients,
+		CompletionCheck:     durationEnv("TD_COMPLETION_CHECK", 0),
+		Notifiers:           notifiers,
+		FlareSolverrURL:     flareSolverrURL,
+		FlareSolverrTimeout: flareSolverrTimeout,
+		MetadataProvider:    metadataProvider,
+		MetadataAPIKey:      metadataOptions.APIKey,
+		MetadataLanguage:    metadataOptions.Language,
+		MetadataURL:         metadataOptions.BaseURL,
+		ContentTypes:        defaultContentTypes(),
+	}
+
+	contentTypes := splitList(os.Getenv("TD_CONTENT_TYPES"))
+	for _, name := range contentTypes {
+		if !slices.ContainsFunc(cfg.ContentTypes, func(t ContentType) bool { return t.Type.Name == name }) {
+			panic(fmt.Sprintf("TD_CONTENT_TYPES: unknown content type %q (available: tv, movies, music)", name))
+		}
+	}
+
+	// The environment describes a single feed
+	cfg.Feeds = []Feed{{
+		Name:          "default",
+		URL:           cfg.GetRSSURL(),
+		Tracker:       trackerName,
+		Client:        clientName,
 		SearchTerms:   searchTerms,
-		DownloadPath:  downloadPath,
-		CheckInterval: checkInterval,
-		BaseURL:       strings.TrimRight(baseURL, "/"),
-		UserID:        userID,
-		RSSToken:      rssToken,
-		PassToken:     passToken,
+		ContentTypes:  contentTypes,
+		Interval:      pollInterval,
+		Schedule:      pollSchedule,
+		Filter:        feedFilter,
+		Groups:        groups,
+		Formats:       formats,
+		MinSize:       minSize,
+		MaxSize:       maxSize,
+		FreeleechOnly: os.Getenv("TD_FREELEECH_ONLY") == "true",
+		Watchlist:     os.Getenv("TD_WATCHLIST") == "true",
+		// Searching the tracker is off unless set
+		WatchlistSearch: watchlistSearch,
+		// Episode tracking is on unless explicitly disabled
+		TrackEpisodes:  os.Getenv("TD_TRACK_EPISODES") != "false",
+		Quality:        qualityProfile,
+		RemoveUpgraded: os.Getenv("TD_QUALITY_REMOVE_UPGRADED") == "true",
+		ReplacePropers: os.Getenv("TD_REPLACE_PROPERS") == "true",
+		RemoveReplaced: os.Getenv("TD_REMOVE_REPLACED") == "true",
+		Scoring:        scoring,
+		MaxFailures:    intEnv("TD_MAX_FAILURES", DefaultMaxFailures),
+		// Both are off unless set
+		IgnoreOlderThan: durationEnv("TD_IGNORE_OLDER_THAN", 0),
+		MaxItemsPerPoll: intEnv("TD_MAX_ITEMS_PER_POLL", 0),
+		PageParam:       os.Getenv("TD_PAGE_PARAM"),
+		Approval:        os.Getenv("TD_APPROVAL") == "true",
+		DedupeKey:       dedupeKey,
+		SeasonPacks:     seasonPacks,
+		PackMinMissing:  intEnv("TD_PACK_MIN_MISSING", DefaultPackMinMissing),
+		Private:         os.Getenv("TD_PRIVATE") == "true",
+		Seeding:         seedingEnv(),
+		StaleAfter:      durationEnv("TD_STALE_AFTER", 0),
+		FailingAfter:    durationEnv("TD_FAILING_AFTER", 0),
+	}}
+	if cfg.Feeds[0].MaxFailures < 1 {
+		panic("TD_MAX_FAILURES must be at least 1")
+	}
+	if cfg.Feeds[0].PackMinMissing < 1 {
+		panic("TD_PACK_MIN_MISSING must be at least 1")
+	}
+	if !cfg.Feeds[0].Seeding.IsZero() && clientName == "" {
+		panic("TD_MIN_RATIO and TD_MIN_SEED_TIME need TD_CLIENT")
+	}
+	if announce.UsesPasskey() && cfg.TrackerPasskey(trackerName) == "" {
+		panic("TD_ANNOUNCE_REWRITE/TD_ANNOUNCE_APPEND use {passkey}, set TD_PASSKEY or use a feed URL that contains one")
+	}
+	if err := cfg.APIAuth.Validate(); err != nil {
+		panic("TD_API_KEY/TD_API_READ_KEY/TD_API_USERNAME/TD_API_PASSWORD: " + err.Error())
+	}
+
+	return cfg
+}
+
+// Feed looks up a feed by name
+func (c *Config) Feed(name string) (Feed, bool) {
+	for _, feed := range c.Feeds {
+		if feed.Name == name {
+			return feed, true
+		}
+	}
+	return Feed{}, false
+}
+
+// FeedsByPriority returns the feeds with those of preferred trackers first,
+// so they get first pick when polled together
+func (c *Config) FeedsByPriority() []Feed {
+	rank := make(map[string]int, len(c.TrackerPriority))
+	for i, name := range c.TrackerPriority {
+		rank[name] = i + 1
+	}
+	feeds := slices.Clone(c.Feeds)
+	// Unlisted trackers rank 0 here, move them after the listed ones
+	last := len(c.TrackerPriority) + 1
+	sort.SliceStable(feeds, func(i, j int) bool {
+		ri, rj := rank[feeds[i].Tracker], rank[feeds[j].Tracker]
+		if ri == 0 {
+			ri = last
+		}
+		if rj == 0 {
+			rj = last
+		}
+		return ri < rj
+	})
+	return feeds
+}
+
+// TrackerCookie returns the cookie a tracker authenticates with. Trackers
+// that log in get their session cookie from the cookie jar instead. Only
+// TorrentDay trackers fall back to the TorrentDay credentials, other hosts
+// must never see them.
+func (c *Config) TrackerCookie(name string) string {
+	tc := c.Trackers[name]
+	if tc.Cookie != "" || tc.Login != nil || tc.Type != "torrentday" {
+		return tc.Cookie
+	}
+	return c.GetAuthCookie()
+}
+
+// TrackerPasskey returns the passkey for a tracker's direct download URLs,
+// taken from the RSS URL of its first feed carrying one unless set explicitly
+func (c *Config) TrackerPasskey(name string) string {
+	if passkey := c.Trackers[name].Passkey; passkey != "" {
+		return passkey
+	}
+	for _, feed := range c.Feeds {
+		if feed.Tracker != name {
+			continue
+		}
+		if passkey := downloader.PasskeyFromRSS(feed.URL); passkey != "" {
+			return passkey
+		}
+	}
+	return ""
+}
+
+// TrackerHosts returns the hostnames a tracker is reached at, from its base
+// and direct download URLs and the URLs of its feeds
+func (c *Config) TrackerHosts(name string) []string {
+	urls := []string{c.Trackers[name].BaseURL, c.Trackers[name].DirectURL}
+	for _, feed := range c.Feeds {
+		if feed.Tracker == name {
+			urls = append(urls, feed.URL)
+		}
+	}
+
+	var hosts []string
+	seen := make(map[string]bool)
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil || u.Hostname() == "" || seen[u.Hostname()] {
+			continue
+		}
+		seen[u.Hostname()] = true
+		hosts = append(hosts, u.Hostname())
+	}
+	return hosts
+}
+
+// HistoryPath returns the location of the download history database
+func (c *Config) HistoryPath() string {
+	switch c.StateBackend {
+	case "json":
+		return filepath.Join(c.StateDir, "history.json")
+	case "sqlite":
+		return filepath.Join(c.StateDir, "history.sqlite")
+	default:
+		return filepath.Join(c.StateDir, "history.db")
+	}
+}
+
+// OpenHistory opens the download history with the configured backend
+func (c *Config) OpenHistory() (*history.Store, error) {
+	return history.Open(c.StateBackend, c.HistoryPath(), c.Secrets())
+}
+
+// Polling describes when a feed is polled, for display
+func (f Feed) Polling() string {
+	if f.Schedule != nil {
+		return "at " + f.Schedule.String()
+	}
+	return "every " + f.Interval.String()
+}
+
+// Destination describes where a feed's torrents end up, for display
+func (c *Config) Destination(feed Feed) string {
+	switch {
+	case feed.Delivery != "":
+		if feed.SavePath != "" {
+			return feed.Delivery + " (" + feed.SavePath + ")"
+		}
+		return feed.Delivery
+	case feed.Client == "":
+		if feed.DownloadPath != "" {
+			return feed.DownloadPath
+		}
+		return c.DownloadPath
+	case feed.Category != "":
+		return feed.Client + " (" + feed.Category + ")"
+	case c.Clients[feed.Client].Category != "":
+		return feed.Client + " (" + c.Clients[feed.Client].Category + ")"
+	default:
+		return feed.Client
+	}
+}
+
+// WatchlistPath returns the location of the watch-list
+func (c *Config) WatchlistPath() string {
+	return filepath.Join(c.StateDir, "watchlist.json")
+}
+
+// PartialDir returns where interrupted torrent downloads are kept for resuming
+func (c *Config) PartialDir() string {
+	return filepath.Join(c.StateDir, "partial")
+}
+
+// CookiesPath returns the location of the persisted cookie jar
+func (c *Config) CookiesPath() string {
+	return filepath.Join(c.StateDir, "cookies.json")
+}
+
+// MetadataOptions converts the metadata settings for the metadata package
+func (c *Config) MetadataOptions() metadata.Options {
+	return metadata.Options{APIKey: c.MetadataAPIKey, Language: c.MetadataLanguage, BaseURL: c.MetadataURL}
+}
+
+// LoginOptions converts the tracker's login settings for the login package
+func (tc TrackerConfig) LoginOptions() login.Options {
+	if tc.Login == nil {
+		return login.Options{}
+	}
+	return login.Options{
+		URL:           tc.Login.URL,
+		Username:      tc.Login.Username,
+		Password:      tc.Login.Password,
+		UsernameField: tc.Login.UsernameField,
+		PasswordField: tc.Login.PasswordField,
+	}
+}
+
+// Options converts the channel settings for the notify package
+// Options converts the delivery settings for the delivery package
+func (dc DeliveryConfig) Options() delivery.Options {
+	return delivery.Options{
+		Path:          dc.Path,
+		URL:           dc.URL,
+		KeyFile:       dc.KeyFile,
+		KeyPassphrase: dc.KeyPassphrase,
+		KnownHosts:    dc.KnownHosts,
+		Timeout:       dc.Timeout,
+	}
+}
+
+func (nc NotifierConfig) Options() notify.Options {
+	return notify.Options{
+		URL:      nc.URL,
+		Username: nc.Username,
+		Password: nc.Password,
+		From:     nc.From,
+		To:       nc.To,
+		Method:   nc.Method,
+		Headers:  nc.Headers,
+		Template: nc.Template,
+	}
+}
+
+// splitList splits a comma-separated value, dropping empty entries
+func splitList(value string) []string {
+	var list []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			list = append(list, part)
+		}
+	}
+	return list
+}
+
+// durationEnv parses a Go duration such as "30m" from the environment
+func durationEnv(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		panic(key + " must be a duration like 30m or 12h: " + err.Error())
+	}
+	return d
+}
+
+// rateEnv parses a rate in bytes per second such as "2MB/s" from the environment
+func rateEnv(name string) int64 {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0
+	}
+	rate, err := bandwidth.ParseRate(value)
+	if err != nil {
+		panic(name + ": " + err.Error())
+	}
+	return rate
+}
+
+// delayEnv parses a delay such as "30s" or "10s-1m" from the environment
+func delayEnv(key string) Delay {
+	value := os.Getenv(key)
+	if value == "" {
+		return Delay{}
+	}
+	d, err := ParseDelay(value)
+	if err != nil {
+		panic(key + " " + err.Error())
+	}
+	return d
+}
+
+// delaysEnv parses durations by quality tier such as "720p=2h,1080p=30m"
+// from the environment
+func delaysEnv(key string) map[string]time.Duration {
+	delays := make(map[string]time.Duration)
+	for _, part := range splitList(os.Getenv(key)) {
+		tier, value, ok := strings.Cut(part, "=")
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if !ok || err != nil {
+			panic(key + " must be tier=duration pairs like 720p=2h, got " + part)
+		}
+		delays[strings.TrimSpace(tier)] = d
+	}
+	return delays
+}
+
+// scoreEnv reads the TD_SCORE_* variables, ok is false when none is set
+func scoreEnv() (opts score.Options, ok bool) {
+	points := func(key string) map[string]int {
+		value := os.Getenv(key)
+		ok = ok || value != ""
+		parsed, err := score.ParsePoints(value)
+		if err != nil {
+			panic(key + " " + err.Error())
+		}
+		return parsed
+	}
+	number := func(key string) int {
+		value := os.Getenv(key)
+		if value == "" {
+			return 0
+		}
+		ok = true
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			panic(key + " must be a number, got " + value)
+		}
+		return n
+	}
+
+	opts.Resolution = points("TD_SCORE_RESOLUTION")
+	opts.Source = points("TD_SCORE_SOURCE")
+	opts.Group = points("TD_SCORE_GROUP")
+	opts.Freeleech = number("TD_SCORE_FREELEECH")
+	opts.Size = number("TD_SCORE_SIZE")
+	opts.TargetSize = sizeEnv("TD_SCORE_TARGET_SIZE")
+	opts.Window = durationEnv("TD_SCORE_WINDOW", 0)
+	ok = ok || opts.TargetSize > 0 || opts.Window > 0
+	return opts, ok
+}
+
+// seedingEnv reads the seeding limits from TD_MIN_RATIO and TD_MIN_SEED_TIME
+func seedingEnv() client.Seeding {
+	seeding := client.Seeding{Time: durationEnv("TD_MIN_SEED_TIME", 0)}
+	if value := os.Getenv("TD_MIN_RATIO"); value != "" {
+		ratio, err := strconv.ParseFloat(value, 64)
+		if err != nil || ratio <= 0 {
+			panic("TD_MIN_RATIO must be a positive number like 1.0, got " + value)
+		}
+		seeding.Ratio = ratio
 	}
+	if seeding.Time < 0 {
+		panic("TD_MIN_SEED_TIME must be positive")
+	}
+	return seeding
+}
+
+// intEnv parses a positive integer from the environment
+func intEnv(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		panic(key + " must be a positive number, got " + value)
+	}
+	return n
 }
 
 // GetRSSURL constructs the RSS URL using the exact working format
 func (c *Config) GetRSSURL() string {
+	return torrentDayRSSURL(c.BaseURL, c.Credentials())
+}
+
+func torrentDayRSSURL(baseURL string, creds credentials.Credentials) string {
 	// TODO: - Improvement area:
 	// The number 7 corresponds to the torrent category TV/x264. Every category has a number or a sequence of numbers for multiple categories if the RSS feed is configured as such.
 	// anime(29), TV/x264(7)
-	return c.BaseURL + "/t.rss?29;7;u=" + c.UserID + ";tp=" + c.RSSToken + ";GuitarIpod;private;do-not-share"
+	return baseURL + "/t.rss?29;7;u=" + creds.UserID + ";tp=" + creds.RSSToken + ";GuitarIpod;private;do-not-share"
 }
 
 // GetAuthCookie returns the cookie string for downloads
 func (c *Config) GetAuthCookie() string {
-	return "uid=" + c.UserID + "; pass=" + c.PassToken
+	return c.Credentials().Cookie()
+}
+
+// Credentials returns the configured tracker credentials
+func (c *Config) Credentials() credentials.Credentials {
+	return credentials.Credentials{
+		UserID:    c.UserID,
+		PassToken: c.PassToken,
+		RSSToken:  c.RSSToken,
+	}
+}
+
+// sizeEnv reads an optional size such as "20GB", 0 when unset
+func sizeEnv(name string) int64 {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0
+	}
+	size, err := bytesize.Parse(value)
+	if err != nil {
+		panic(name + ": " + err.Error())
+	}
+	return size
 }
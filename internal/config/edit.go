@@ -0,0 +1,195 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrFeedExists   = errors.New("a feed by that name exists already")
+	ErrFeedNotFound = errors.New("no feed by that name")
+)
+
+// feedKeyOrder is the order of the leading keys of a feed written to the
+// config file, the rest follow alphabetically
+var feedKeyOrder = []string{"name", "url", "tracker"}
+
+// AddFeed appends a feed, given as the fields of a feed in the config file,
+// to the feeds of the config file at path
+func AddFeed(path string, feed map[string]any) error {
+	return editFeeds(path, func(feeds *yaml.Node) error {
+		name, _ := feed["name"].(string)
+		if feedIndex(feeds, name) >= 0 {
+			return ErrFeedExists
+		}
+		node, err := feedNode(feed)
+		if err != nil {
+			return err
+		}
+		feeds.Content = append(feeds.Content, node)
+		return nil
+	})
+}
+
+// UpdateFeed replaces the named feed of the config file at path, keeping its
+// place. The feed keeps its name unless feed renames it.
+func UpdateFeed(path, name string, feed map[string]any) error {
+	return editFeeds(path, func(feeds *yaml.Node) error {
+		i := feedIndex(feeds, name)
+		if i < 0 {
+			return ErrFeedNotFound
+		}
+		if _, ok := feed["name"]; !ok {
+			feed["name"] = name
+		}
+		if renamed, _ := feed["name"].(string); renamed != name && feedIndex(feeds, renamed) >= 0 {
+			return ErrFeedExists
+		}
+		node, err := feedNode(feed)
+		if err != nil {
+			return err
+		}
+		// Comments on the feed stay with it
+		node.HeadComment, node.LineComment, node.FootComment = feeds.Content[i].HeadComment, feeds.Content[i].LineComment, feeds.Content[i].FootComment
+		feeds.Content[i] = node
+		return nil
+	})
+}
+
+// RemoveFeed removes the named feed from the config file at path
+func RemoveFeed(path, name string) error {
+	return editFeeds(path, func(feeds *yaml.Node) error {
+		i := feedIndex(feeds, name)
+		if i < 0 {
+			return ErrFeedNotFound
+		}
+		feeds.Content = slices.Delete(feeds.Content, i, i+1)
+		return nil
+	})
+}
+
+// editFeeds applies edit to the feeds of the config file at path and saves
+// it, keeping comments and the other settings as they are. The file is only
+// replaced when the edited config is valid.
+func editFeeds(path string, edit func(feeds *yaml.Node) error) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return &ValidationError{Path: path, Problems: []string{err.Error()}}
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return &ValidationError{Path: path, Problems: []string{"the config file isn't a mapping"}}
+	}
+
+	var feeds *yaml.Node
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == "feeds" {
+			feeds = root.Content[i+1]
+		}
+	}
+	if feeds == nil {
+		feeds = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "feeds"}, feeds)
+	}
+	if feeds.Kind != yaml.SequenceNode {
+		return &ValidationError{Path: path, Problems: []string{"feeds: must be a list"}}
+	}
+	if err := edit(feeds); err != nil {
+		return err
+	}
+	// An empty list was written as [], new feeds go below each other
+	feeds.Style = 0
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	if _, err := parse(path, buf.Bytes()); err != nil {
+		return err
+	}
+	return writeFile(path, buf.Bytes())
+}
+
+// feedIndex returns the position of the named feed in feeds, or -1
+func feedIndex(feeds *yaml.Node, name string) int {
+	for i, feed := range feeds.Content {
+		if feed.Kind != yaml.MappingNode {
+			continue
+		}
+		for j := 0; j+1 < len(feed.Content); j += 2 {
+			if feed.Content[j].Value == "name" && feed.Content[j+1].Value == name {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// feedNode turns the fields of a feed into the mapping written to the file
+func feedNode(feed map[string]any) (*yaml.Node, error) {
+	var node yaml.Node
+	if err := node.Encode(feed); err != nil {
+		return nil, err
+	}
+	// The encoder sorts the keys, put the ones that tell feeds apart first
+	placed := 0
+	for _, key := range feedKeyOrder {
+		for i := placed * 2; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value != key {
+				continue
+			}
+			pair := slices.Clone(node.Content[i : i+2])
+			node.Content = slices.Delete(node.Content, i, i+2)
+			node.Content = slices.Insert(node.Content, placed*2, pair...)
+			placed++
+			break
+		}
+	}
+	return &node, nil
+}
+
+// writeFile replaces the file at path in one go, keeping its permissions
+func writeFile(path string, data []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,1193 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"torrent-rss/internal/bandwidth"
+	"torrent-rss/internal/bytesize"
+	"torrent-rss/internal/category"
+	"torrent-rss/internal/challenge"
+	"torrent-rss/internal/client"
+	"torrent-rss/internal/connpool"
+	"torrent-rss/internal/credentials"
+	"torrent-rss/internal/cron"
+	"torrent-rss/internal/delivery"
+	"torrent-rss/internal/dnscache"
+	"torrent-rss/internal/downloader"
+	"torrent-rss/internal/feed"
+	"torrent-rss/internal/filter"
+	"torrent-rss/internal/headers"
+	"torrent-rss/internal/history"
+	"torrent-rss/internal/login"
+	"torrent-rss/internal/metadata"
+	"torrent-rss/internal/metainfo"
+	"torrent-rss/internal/notify"
+	"torrent-rss/internal/parser"
+	"torrent-rss/internal/quality"
+	"torrent-rss/internal/release"
+	"torrent-rss/internal/retry"
+	"torrent-rss/internal/score"
+	"torrent-rss/internal/secrets"
+	"torrent-rss/internal/tlsconfig"
+	"torrent-rss/internal/tracker"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileConfig mirrors the YAML config file layout
+type fileConfig struct {
+	DownloadPath   string                  `yaml:"download_path"`
+	StateDir       string                  `yaml:"state_dir"`
+	StateBackend   string                  `yaml:"state_backend"`
+	PollJitter     string                  `yaml:"poll_jitter"`
+	Workers        int                     `yaml:"workers"`
+	DNSCacheTTL    string                  `yaml:"dns_cache_ttl"`
+	Connections    *fileConnections        `yaml:"connections"`
+	MaxFailures    int                     `yaml:"max_failures"`
+	StaleAfter     string                  `yaml:"stale_after"`
+	FailingAfter   string                  `yaml:"failing_after"`
+	MaxRedirects   int                     `yaml:"max_redirects"`
+	MinFreeSpace   string                  `yaml:"min_free_space"`
+	Completion     string                  `yaml:"completion_check"`
+	MaxTorrentSize string                  `yaml:"max_torrent_size"`
+	BandwidthLimit string                  `yaml:"bandwidth_limit"` // All torrent downloads together, e.g. "2MB/s"
+	Debug          bool                    `yaml:"debug"`
+	NameTemplate   string                  `yaml:"name_template"`
+	CookieKey      string                  `yaml:"cookie_key"`
+	Retry          fileRetry               `yaml:"retry"`
+	Timeouts       fileTimeouts            `yaml:"timeouts"`
+	API            fileAPI                 `yaml:"api"`
+	FlareSolverr   *fileFlareSolverr       `yaml:"flaresolverr"`
+	Metadata       *fileMetadata           `yaml:"metadata"`
+	Credentials    fileCredentials         `yaml:"credentials"`
+	Trackers       map[string]fileTracker  `yaml:"trackers"`
+	Priority       []string                `yaml:"tracker_priority"`
+	Clients        map[string]fileClient   `yaml:"clients"`
+	Deliveries     map[string]fileDelivery `yaml:"deliveries"`
+	Notifiers      map[string]fileNotifier `yaml:"notifiers"`
+	ContentTypes   []fileContentType       `yaml:"content_types"`
+	Feeds          []fileFeed              `yaml:"feeds"`
+}
+
+// fileContentType defines a content type, or changes one of the defaults
+// when named like it
+type fileContentType struct {
+	Name         string   `yaml:"name"`
+	Categories   []string `yaml:"categories"` // IDs, ranges like 5000-5999 and names like TV*
+	Client       string   `yaml:"client"`
+	Delivery     string   `yaml:"delivery"`
+	DownloadPath string   `yaml:"download_path"`
+	Category     string   `yaml:"category"`
+	SavePath     string   `yaml:"save_path"`
+}
+
+type fileRetry struct {
+	Attempts   int    `yaml:"attempts"`
+	Backoff    string `yaml:"backoff"`
+	MaxBackoff string `yaml:"max_backoff"`
+}
+
+type fileTimeouts struct {
+	Connect string `yaml:"connect"`
+	Read    string `yaml:"read"`
+}
+
+type fileAPI struct {
+	Listen   string `yaml:"listen"`
+	Key      string `yaml:"key"`      // Full access
+	ReadKey  string `yaml:"read_key"` // Read-only
+	Username string `yaml:"username"` // Basic auth, full access
+	Password string `yaml:"password"`
+}
+
+type fileFlareSolverr struct {
+	URL     string `yaml:"url"`
+	Timeout string `yaml:"timeout"`
+}
+
+type fileMetadata struct {
+	Provider string `yaml:"provider"` // tmdb or tvdb
+	APIKey   string `yaml:"api_key"`
+	Language string `yaml:"language"`
+	URL      string `yaml:"url"`
+}
+
+type fileCredentials struct {
+	UserID   string `yaml:"user_id"`
+	Token    string `yaml:"token"`
+	RSSToken string `yaml:"rss_token"`
+}
+
+type fileTracker struct {
+	Type         string `yaml:"type"`
+	BaseURL      string `yaml:"base_url"`
+	LinkSelector string `yaml:"link_selector"`
+	// DownloadLinkSelector is another name for LinkSelector
+	DownloadLinkSelector string        `yaml:"download_link_selector"`
+	FreeleechSelector    string        `yaml:"freeleech_selector"`
+	DirectURL            string        `yaml:"direct_url"`
+	SearchURL            string        `yaml:"search_url"`
+	SearchSelector       string        `yaml:"search_selector"`
+	Passkey              string        `yaml:"passkey"`
+	Resolvers            []string      `yaml:"resolvers"`
+	Cookie               string        `yaml:"cookie"`
+	Login                *fileLogin    `yaml:"login"`
+	Auth                 *fileAuth     `yaml:"auth"`
+	Proxy                string        `yaml:"proxy"`
+	RateLimit            int           `yaml:"rate_limit"` // Requests per minute
+	PageWorkers          int           `yaml:"page_workers"`
+	Cooldown             string        `yaml:"cooldown"`
+	GrabDelay            string        `yaml:"grab_delay"` // "30s" or "10s-1m"
+	BandwidthLimit       string        `yaml:"bandwidth_limit"`
+	TLS                  *fileTLS      `yaml:"tls"`
+	Headers              *fileHeaders  `yaml:"headers"`
+	Announce             *fileAnnounce `yaml:"announce"`
+	// Connections override the global connection settings for the
+	// tracker's hosts
+	Connections *fileConnections `yaml:"connections"`
+}
+
+type fileAnnounce struct {
+	Rewrite []fileRewrite `yaml:"rewrite"`
+	Append  []string      `yaml:"append"`
+}
+
+type fileRewrite struct {
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+}
+
+type fileTLS struct {
+	CAFile             string `yaml:"ca_file"`
+	CertFile           string `yaml:"cert_file"`
+	KeyFile            string `yaml:"key_file"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+type fileConnections struct {
+	MaxIdlePerHost int    `yaml:"max_idle_per_host"`
+	IdleTimeout    string `yaml:"idle_timeout"`
+	HTTP2          *bool  `yaml:"http2"` // Unset keeps the inherited setting
+}
+
+type fileHeaders struct {
+	Profile        string            `yaml:"profile"`
+	UserAgent      string            `yaml:"user_agent"`
+	AcceptLanguage string            `yaml:"accept_language"`
+	Extra          map[string]string `yaml:"extra"`
+}
+
+type fileLogin struct {
+	URL           string `yaml:"url"`
+	Username      string `yaml:"username"`
+	Password      string `yaml:"password"`
+	UsernameField string `yaml:"username_field"`
+	PasswordField string `yaml:"password_field"`
+}
+
+type fileAuth struct {
+	Type     string `yaml:"type"` // cookie, bearer, query or basic
+	Token    string `yaml:"token"`
+	Param    string `yaml:"param"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+type fileClient struct {
+	Type     string `yaml:"type"`
+	URL      string `yaml:"url"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	Category string `yaml:"category"`
+	SavePath string `yaml:"save_path"`
+}
+
+type fileDelivery struct {
+	Type          string `yaml:"type"`
+	Path          string `yaml:"path"`
+	URL           string `yaml:"url"`
+	KeyFile       string `yaml:"key_file"`
+	KeyPassphrase string `yaml:"key_passphrase"`
+	KnownHosts    string `yaml:"known_hosts"`
+	Timeout       string `yaml:"timeout"`
+}
+
+type fileNotifier struct {
+	Type     string            `yaml:"type"`
+	URL      string            `yaml:"url"`
+	Username string            `yaml:"username"`
+	Password string            `yaml:"password"`
+	From     string            `yaml:"from"`
+	To       []string          `yaml:"to"`
+	Method   string            `yaml:"method"`
+	Headers  map[string]string `yaml:"headers"`
+	Template string            `yaml:"template"`
+	Events   []string          `yaml:"events"`
+	Digest   string            `yaml:"digest"` // Daily "HH:MM", empty sends right away
+}
+
+type fileFeed struct {
+	Name          string       `yaml:"name"`
+	URL           string       `yaml:"url"`
+	Tracker       string       `yaml:"tracker"`
+	Client        string       `yaml:"client"`
+	Delivery      string       `yaml:"delivery"`
+	DownloadPath  string       `yaml:"download_path"`
+	WatchClient   string       `yaml:"watch_client"`
+	RemoveFile    bool         `yaml:"remove_torrent_file"`
+	Seeding       *fileSeeding `yaml:"seeding"`
+	Category      string       `yaml:"category"`
+	SavePath      string       `yaml:"save_path"`
+	SearchTerms   []string     `yaml:"search_terms"`
+	ContentTypes  []string     `yaml:"content_types"`
+	Interval      string       `yaml:"interval"`
+	Schedule      string       `yaml:"schedule"` // Cron expression, instead of interval
+	Include       []string     `yaml:"include"`
+	Exclude       []string     `yaml:"exclude"`
+	Groups        *fileGroups  `yaml:"groups"`
+	Formats       []string     `yaml:"formats"` // Music formats, e.g. [FLAC, V0]
+	MinSize       string       `yaml:"min_size"`
+	MaxSize       string       `yaml:"max_size"`
+	FreeleechOnly bool         `yaml:"freeleech_only"`
+	Watchlist     bool         `yaml:"watchlist"`
+	WatchSearch   string       `yaml:"watchlist_search"`
+	MaxFailures   int          `yaml:"max_failures"`
+	StaleAfter    string       `yaml:"stale_after"`
+	FailingAfter  string       `yaml:"failing_after"`
+	IgnoreOlder   string       `yaml:"ignore_older_than"`
+	MaxItems      int          `yaml:"max_items_per_poll"`
+	PageParam     string       `yaml:"page_param"`
+	Approval      bool         `yaml:"approval"`
+	DedupeKey     string       `yaml:"dedupe_key"`
+	SeasonPacks   string       `yaml:"season_packs"`
+	PackMin       int          `yaml:"pack_min_missing"`
+	Private       bool         `yaml:"private"`
+	TrackEpisodes *bool        `yaml:"track_episodes"`
+	ReplaceProper bool         `yaml:"replace_propers"`
+	RemoveReplace bool         `yaml:"remove_replaced"`
+	Quality       *fileQuality `yaml:"quality"`
+	Scoring       *fileScoring `yaml:"scoring"`
+	Torznab       *fileTorznab `yaml:"torznab"`
+	JSONAPI       *fileJSONAPI `yaml:"json_api"`
+}
+
+type fileTorznab struct {
+	APIKey     string `yaml:"api_key"`
+	Categories []int  `yaml:"categories"`
+	Limit      int    `yaml:"limit"`
+	Pages      int    `yaml:"pages"`
+}
+
+// fileJSONAPI holds a JSONPath per item field
+type fileJSONAPI struct {
+	Items      string `yaml:"items"`
+	Title      string `yaml:"title"`
+	Link       string `yaml:"link"`
+	Download   string `yaml:"download"`
+	GUID       string `yaml:"guid"`
+	InfoHash   string `yaml:"infohash"`
+	Size       string `yaml:"size"`
+	Date       string `yaml:"date"`
+	Freeleech  string `yaml:"freeleech"`
+	Categories string `yaml:"categories"`
+}
+
+type fileSeeding struct {
+	MinRatio    float64 `yaml:"min_ratio"`
+	MinSeedTime string  `yaml:"min_seed_time"`
+}
+
+type fileGroups struct {
+	Prefer        []string `yaml:"prefer"`
+	Ban           []string `yaml:"ban"`
+	PreferredOnly bool     `yaml:"preferred_only"`
+}
+
+type fileScoring struct {
+	Resolution map[string]int `yaml:"resolution"`
+	Source     map[string]int `yaml:"source"`
+	Group      map[string]int `yaml:"group"`
+	Freeleech  int            `yaml:"freeleech"`
+	TargetSize string         `yaml:"target_size"`
+	Size       int            `yaml:"size"`
+	Window     string         `yaml:"window"`
+}
+
+type fileQuality struct {
+	Tiers          []string `yaml:"tiers"`
+	Cutoff         string   `yaml:"cutoff"`
+	Upgrade        bool     `yaml:"upgrade"`
+	RemoveUpgraded bool     `yaml:"remove_upgraded"`
+	// Delays are how long to wait for a better release, by tier
+	Delays map[string]string `yaml:"delays"`
+}
+
+// ValidationError lists every problem found in a config file
+type ValidationError struct {
+	Path     string
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid config %s:\n  - %s", e.Path, strings.Join(e.Problems, "\n  - "))
+}
+
+// problems collects validation messages prefixed with the field they concern
+type problems []string
+
+func (p *problems) add(field, format string, args ...any) {
+	*p = append(*p, field+": "+fmt.Sprintf(format, args...))
+}
+
+// Load reads and validates a YAML config file. Credentials missing from the
+// file are looked up in the environment and then the OS keyring.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	return parse(path, data)
+}
+
+// parse validates the contents of the config file at path
+func parse(path string, data []byte) (*Config, error) {
+	var raw fileConfig
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&raw); err != nil && !errors.Is(err, io.EOF) {
+		return nil, &ValidationError{Path: path, Problems: []string{err.Error()}}
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("could not find home directory: %w", err)
+	}
+
+	var errs problems
+	secretKey, err := secrets.MasterKey()
+	if err != nil {
+		errs.add("secret_key", "%v", err)
+	}
+	revealSecrets(&errs, &raw, secrets.New(secretKey))
+
+	cfg := &Config{
+		DownloadPath:  expandHome(raw.DownloadPath, homeDir),
+		StateDir:      expandHome(raw.StateDir, homeDir),
+		CheckInterval: "0 */12 * * *",
+		PollJitter:    parseDuration(&errs, "poll_jitter", raw.PollJitter, 5*time.Minute),
+		Workers:       DefaultWorkers,
+		CookieKey:     raw.CookieKey,
+		SecretKey:     secretKey,
+		APIAddr:       raw.API.Listen,
+		MaxRedirects:  raw.MaxRedirects,
+		Debug:         raw.Debug || os.Getenv("TD_DEBUG") == "true",
+		Trackers:      make(map[string]TrackerConfig),
+		Clients:       make(map[string]ClientConfig),
+		Deliveries:    make(map[string]DeliveryConfig),
+		Notifiers:     make(map[string]NotifierConfig),
+	}
+	if cfg.DownloadPath == "" {
+		cfg.DownloadPath = filepath.Join(homeDir, "Downloads", "torrents")
+	}
+	if cfg.StateDir == "" {
+		cfg.StateDir = filepath.Join(homeDir, ".torrent-rss")
+	}
+	cfg.StateBackend = raw.StateBackend
+	if cfg.StateBackend == "" {
+		cfg.StateBackend = history.DefaultBackend
+	}
+	if !slices.Contains(history.Backends(), cfg.StateBackend) {
+		errs.add("state_backend", "unknown state backend %q (available: %v)", cfg.StateBackend, history.Backends())
+	}
+	if cfg.CookieKey == "" {
+		cfg.CookieKey = os.Getenv("TD_COOKIE_KEY")
+	}
+	if cfg.CookieKey == "" {
+		cfg.CookieKey = secretKey
+	}
+
+	if raw.Workers < 0 {
+		errs.add("workers", "must not be negative, got %d", raw.Workers)
+	} else if raw.Workers > 0 {
+		cfg.Workers = raw.Workers
+	}
+	cfg.APIAuth = APIAuth{Key: raw.API.Key, ReadKey: raw.API.ReadKey, Username: raw.API.Username, Password: raw.API.Password}
+	if err := cfg.APIAuth.Validate(); err != nil {
+		errs.add("api", "%v", err)
+	}
+	cfg.MinFreeSpace = parseSize(&errs, "min_free_space", raw.MinFreeSpace)
+	cfg.CompletionCheck = parseDuration(&errs, "completion_check", raw.Completion, 0)
+	cfg.MaxTorrentSize = parseSize(&errs, "max_torrent_size", raw.MaxTorrentSize)
+	cfg.BandwidthLimit = parseRate(&errs, "bandwidth_limit", raw.BandwidthLimit)
+	if raw.NameTemplate == "" {
+		raw.NameTemplate = os.Getenv("TD_NAME_TEMPLATE")
+	}
+	if raw.NameTemplate != "" {
+		if cfg.NameTemplate, err = release.NewTemplate(raw.NameTemplate); err != nil {
+			errs.add("name_template", "%v", err)
+		}
+	}
+	if raw.MaxRedirects < 0 {
+		errs.add("max_redirects", "must not be negative, got %d", raw.MaxRedirects)
+	}
+	maxFailures := DefaultMaxFailures
+	if raw.MaxFailures < 0 {
+		errs.add("max_failures", "must not be negative, got %d", raw.MaxFailures)
+	} else if raw.MaxFailures > 0 {
+		maxFailures = raw.MaxFailures
+	}
+	// Defaults of every feed, off unless set
+	staleAfter := parseDuration(&errs, "stale_after", raw.StaleAfter, 0)
+	failingAfter := parseDuration(&errs, "failing_after", raw.FailingAfter, 0)
+
+	cfg.Retry = retry.DefaultPolicy()
+	if raw.Retry.Attempts < 0 {
+		errs.add("retry.attempts", "must not be negative, got %d", raw.Retry.Attempts)
+	} else if raw.Retry.Attempts > 0 {
+		cfg.Retry.Attempts = raw.Retry.Attempts
+	}
+	cfg.Retry.Backoff = parseDuration(&errs, "retry.backoff", raw.Retry.Backoff, cfg.Retry.Backoff)
+	cfg.Retry.MaxBackoff = parseDuration(&errs, "retry.max_backoff", raw.Retry.MaxBackoff, cfg.Retry.MaxBackoff)
+	cfg.ConnectTimeout = parseDuration(&errs, "timeouts.connect", raw.Timeouts.Connect, 10*time.Second)
+	cfg.ReadTimeout = parseDuration(&errs, "timeouts.read", raw.Timeouts.Read, 30*time.Second)
+	// 0 turns the cache off
+	if strings.TrimSpace(raw.DNSCacheTTL) == "0" {
+		cfg.DNSCacheTTL = 0
+	} else {
+		cfg.DNSCacheTTL = parseDuration(&errs, "dns_cache_ttl", raw.DNSCacheTTL, dnscache.DefaultTTL)
+	}
+	cfg.Connections = parseConnections(&errs, "connections", raw.Connections, connpool.Options{})
+	cfg.FlareSolverrTimeout = challenge.DefaultFlareSolverrTimeout
+	if raw.FlareSolverr != nil {
+		cfg.FlareSolverrURL = raw.FlareSolverr.URL
+		cfg.FlareSolverrTimeout = parseDuration(&errs, "flaresolverr.timeout", raw.FlareSolverr.Timeout, cfg.FlareSolverrTimeout)
+		if _, err := challenge.NewFlareSolverr(cfg.FlareSolverrURL, cfg.FlareSolverrTimeout); err != nil {
+			errs.add("flaresolverr.url", "%v", err)
+		}
+	}
+	if raw.Metadata != nil {
+		cfg.MetadataProvider = raw.Metadata.Provider
+		cfg.MetadataAPIKey = raw.Metadata.APIKey
+		cfg.MetadataLanguage = raw.Metadata.Language
+		cfg.MetadataURL = raw.Metadata.URL
+		if _, err := metadata.New(cfg.MetadataProvider, cfg.MetadataOptions()); err != nil {
+			errs.add("metadata", "%v", err)
+		}
+	}
+
+	creds, err := credentials.Chain{
+		credentials.Static{
+			UserID:    raw.Credentials.UserID,
+			PassToken: raw.Credentials.Token,
+			RSSToken:  raw.Credentials.RSSToken,
+		},
+		credentials.Env{},
+		credentials.Keyring{Service: "torrent-rss"},
+	}.Credentials()
+	if err != nil {
+		errs.add("credentials", "%v", err)
+	}
+	cfg.UserID, cfg.PassToken, cfg.RSSToken = creds.UserID, creds.PassToken, creds.RSSToken
+
+	for _, name := range sortedKeys(raw.Trackers) {
+		t := raw.Trackers[name]
+		field := "trackers." + name
+		tc := TrackerConfig{
+			Type:              t.Type,
+			BaseURL:           strings.TrimRight(t.BaseURL, "/"),
+			LinkSelector:      t.LinkSelector,
+			FreeleechSelector: t.FreeleechSelector,
+			DirectURL:         t.DirectURL,
+			SearchURL:         t.SearchURL,
+			SearchSelector:    t.SearchSelector,
+			Passkey:           t.Passkey,
+			Cookie:            t.Cookie,
+			RateLimit:         t.RateLimit,
+			PageWorkers:       t.PageWorkers,
+			Cooldown:          DefaultCooldown,
+		}
+		tc.Headers = headers.Default()
+		if t.Headers != nil {
+			profile, err := headers.Named(t.Headers.Profile)
+			if err != nil {
+				errs.add(field+".headers.profile", "%v", err)
+			}
+			tc.Headers = profile.Merge(headers.Profile{
+				UserAgent:      t.Headers.UserAgent,
+				AcceptLanguage: t.Headers.AcceptLanguage,
+				Headers:        t.Headers.Extra,
+			})
+		}
+		if tc.RateLimit < 0 {
+			errs.add(field+".rate_limit", "must not be negative, got %d", tc.RateLimit)
+		}
+		if tc.PageWorkers < 0 {
+			errs.add(field+".page_workers", "must not be negative, got %d", tc.PageWorkers)
+		}
+		// 0 turns the cool-down off
+		if strings.TrimSpace(t.Cooldown) == "0" {
+			tc.Cooldown = 0
+		} else {
+			tc.Cooldown = parseDuration(&errs, field+".cooldown", t.Cooldown, DefaultCooldown)
+		}
+		tc.BandwidthLimit = parseRate(&errs, field+".bandwidth_limit", t.BandwidthLimit)
+		if t.GrabDelay != "" {
+			if tc.GrabDelay, err = ParseDelay(t.GrabDelay); err != nil {
+				errs.add(field+".grab_delay", "%v", err)
+			}
+		}
+		if t.Announce != nil {
+			for _, rw := range t.Announce.Rewrite {
+				tc.Announce.Rewrite = append(tc.Announce.Rewrite, metainfo.Rewrite{From: rw.From, To: rw.To})
+			}
+			tc.Announce.Append = t.Announce.Append
+			if err := tc.Announce.Validate(); err != nil {
+				errs.add(field+".announce", "%v", err)
+			}
+		}
+		if tc.Type == "" {
+			tc.Type = name
+		}
+		if t.DownloadLinkSelector != "" {
+			if tc.LinkSelector != "" {
+				errs.add(field, "set either link_selector or download_link_selector, not both")
+			}
+			tc.LinkSelector = t.DownloadLinkSelector
+		}
+		if tc.Resolvers, err = downloader.ParseResolvers(t.Resolvers); err != nil {
+			errs.add(field+".resolvers", "%v", err)
+		}
+		// Building the adapter validates type, base URL and selector in one go
+		_, err := tracker.New(tc.Type, tracker.Options{
+			BaseURL:           tc.BaseURL,
+			Cookie:            tc.Cookie,
+			LinkSelector:      tc.LinkSelector,
+			FreeleechSelector: tc.FreeleechSelector,
+			DirectURL:         tc.DirectURL,
+			SearchURL:         tc.SearchURL,
+			SearchSelector:    tc.SearchSelector,
+		})
+		if err != nil {
+			errs.add(field, "%v", err)
+		}
+		if t.Login != nil {
+			tc.Login = &LoginConfig{
+				URL:           t.Login.URL,
+				Username:      t.Login.Username,
+				Password:      t.Login.Password,
+				UsernameField: t.Login.UsernameField,
+				PasswordField: t.Login.PasswordField,
+			}
+			if _, err := login.New(tc.LoginOptions()); err != nil {
+				errs.add(field+".login", "%v", err)
+			}
+		}
+		if t.Auth != nil {
+			tc.Auth = tracker.AuthOptions{
+				Type:     t.Auth.Type,
+				Token:    t.Auth.Token,
+				Param:    t.Auth.Param,
+				Username: t.Auth.Username,
+				Password: t.Auth.Password,
+			}
+			if _, err := tracker.NewAuth(tc.Auth, tc.Cookie); err != nil {
+				errs.add(field+".auth", "%v", err)
+			}
+		}
+		if t.Proxy != "" {
+			if tc.Proxy, err = downloader.ParseProxy(t.Proxy); err != nil {
+				errs.add(field+".proxy", "%v", err)
+			}
+		}
+		if t.TLS != nil {
+			tc.TLS = tlsconfig.Options{
+				CAFile:             t.TLS.CAFile,
+				CertFile:           t.TLS.CertFile,
+				KeyFile:            t.TLS.KeyFile,
+				InsecureSkipVerify: t.TLS.InsecureSkipVerify,
+			}
+			if _, err := tc.TLS.Config(); err != nil {
+				errs.add(field+".tls", "%v", err)
+			}
+		}
+		tc.Connections = parseConnections(&errs, field+".connections", t.Connections, cfg.Connections)
+		if tc.Type == "torrentday" {
+			if !creds.Complete() {
+				errs.add(field, "torrentday needs credentials.user_id, token and rss_token (or TD_USER_ID, TD_TOKEN, TD_RSS_TOKEN)")
+			}
+			cfg.BaseURL = tc.BaseURL
+		}
+		cfg.Trackers[name] = tc
+	}
+
+	// Built to check what the backends can do
+	clients := make(map[string]client.Client)
+	for _, name := range sortedKeys(raw.Clients) {
+		c := raw.Clients[name]
+		field := "clients." + name
+		cc := ClientConfig{
+			Type:     c.Type,
+			URL:      c.URL,
+			Username: c.Username,
+			Password: c.Password,
+			Category: c.Category,
+			SavePath: c.SavePath,
+		}
+		if cc.Type == "" {
+			cc.Type = name
+		}
+		backend, err := client.New(cc.Type, client.Options{URL: cc.URL, Username: cc.Username, Password: cc.Password})
+		if err != nil {
+			errs.add(field, "%v", err)
+		}
+		cfg.Clients[name] = cc
+		clients[name] = backend
+	}
+
+	for _, name := range sortedKeys(raw.Deliveries) {
+		d := raw.Deliveries[name]
+		field := "deliveries." + name
+		dc := DeliveryConfig{
+			Type:          d.Type,
+			Path:          d.Path,
+			URL:           d.URL,
+			KeyFile:       expandHome(d.KeyFile, homeDir),
+			KeyPassphrase: d.KeyPassphrase,
+			KnownHosts:    expandHome(d.KnownHosts, homeDir),
+			Timeout:       parseDuration(&errs, field+".timeout", d.Timeout, 0),
+		}
+		// Remote paths are relative to the server, not this machine
+		if dc.Type == "folder" {
+			dc.Path = expandHome(dc.Path, homeDir)
+		}
+		if dc.Type == "" {
+			errs.add(field+".type", "is required")
+		} else if _, err := delivery.New(dc.Type, dc.Options()); err != nil {
+			errs.add(field, "%v", err)
+		}
+		cfg.Deliveries[name] = dc
+	}
+
+	cfg.ContentTypes = parseContentTypes(&errs, raw.ContentTypes, cfg, homeDir)
+
+	for _, name := range sortedKeys(raw.Notifiers) {
+		n := raw.Notifiers[name]
+		field := "notifiers." + name
+		nc := NotifierConfig{
+			Type:     n.Type,
+			URL:      n.URL,
+			Username: n.Username,
+			Password: n.Password,
+			From:     n.From,
+			To:       n.To,
+			Method:   n.Method,
+			Headers:  n.Headers,
+			Template: n.Template,
+		}
+		if nc.Type == "" {
+			nc.Type = name
+		}
+		notifier, err := notify.New(nc.Type, nc.Options())
+		if err != nil {
+			errs.add(field, "%v", err)
+		}
+		if n.Digest != "" {
+			if nc.DigestAt, err = notify.ParseTimeOfDay(n.Digest); err != nil {
+				errs.add(field+".digest", "%v", err)
+			}
+			if _, ok := notifier.(notify.BatchNotifier); notifier != nil && !ok {
+				errs.add(field+".digest", "%s notifications can't be sent as a digest", nc.Type)
+			}
+			nc.Digest = true
+		}
+		if nc.Events, err = notify.ParseKinds(n.Events); err != nil {
+			errs.add(field+".events", "%v", err)
+		}
+		cfg.Notifiers[name] = nc
+	}
+
+	if len(raw.Feeds) == 0 {
+		errs.add("feeds", "at least one feed is required")
+	}
+	seen := make(map[string]bool)
+	for i, f := range raw.Feeds {
+		field := fmt.Sprintf("feeds[%d]", i)
+		if f.Name == "" {
+			errs.add(field+".name", "is required")
+		} else {
+			field = fmt.Sprintf("feeds[%s]", f.Name)
+			if seen[f.Name] {
+				errs.add(field+".name", "duplicate feed name")
+			}
+			seen[f.Name] = true
+		}
+
+		feed := Feed{
+			Name:          f.Name,
+			URL:           f.URL,
+			Tracker:       f.Tracker,
+			Client:        f.Client,
+			Delivery:      f.Delivery,
+			SearchTerms:   f.SearchTerms,
+			Interval:      parseDuration(&errs, field+".interval", f.Interval, 12*time.Hour),
+			TrackEpisodes: f.TrackEpisodes == nil || *f.TrackEpisodes,
+		}
+		if f.Schedule != "" {
+			if f.Interval != "" {
+				errs.add(field, "set either interval or schedule, not both")
+			}
+			if feed.Schedule, err = cron.Parse(f.Schedule); err != nil {
+				errs.add(field+".schedule", "%v", err)
+			}
+		}
+
+		// With a single tracker, feeds don't have to name it
+		if feed.Tracker == "" && len(cfg.Trackers) == 1 {
+			for name := range cfg.Trackers {
+				feed.Tracker = name
+			}
+		}
+		tc, ok := cfg.Trackers[feed.Tracker]
+		switch {
+		case feed.Tracker == "":
+			errs.add(field+".tracker", "is required when more than one tracker is configured")
+		case !ok:
+			errs.add(field+".tracker", "unknown tracker %q", feed.Tracker)
+		}
+
+		if feed.URL == "" {
+			if tc.Type == "torrentday" && creds.Complete() {
+				feed.URL = torrentDayRSSURL(tc.BaseURL, creds)
+			} else {
+				errs.add(field+".url", "is required")
+			}
+		}
+
+		if _, ok := cfg.Clients[feed.Client]; feed.Client != "" && !ok {
+			errs.add(field+".client", "unknown client %q", feed.Client)
+		}
+
+		if _, ok := cfg.Deliveries[feed.Delivery]; feed.Delivery != "" && !ok {
+			errs.add(field+".delivery", "unknown delivery %q", feed.Delivery)
+		}
+		if feed.Client != "" && feed.Delivery != "" {
+			errs.add(field, "client and delivery can't both be set")
+		}
+
+		feed.DownloadPath = expandHome(f.DownloadPath, homeDir)
+		feed.Category = f.Category
+		feed.SavePath = f.SavePath
+		if feed.Client == "" && feed.Category != "" {
+			errs.add(field+".category", "needs the feed to use a client")
+		}
+		if feed.Client == "" && feed.Delivery == "" && feed.SavePath != "" {
+			errs.add(field+".save_path", "needs the feed to use a client or delivery")
+		}
+		if (feed.Client != "" || feed.Delivery != "") && feed.DownloadPath != "" {
+			errs.add(field+".download_path", "is only used without a client or delivery, set save_path instead")
+		}
+		feed.WatchClient = f.WatchClient
+		feed.RemoveTorrentFile = f.RemoveFile
+		if feed.WatchClient != "" {
+			dc, isDelivery := cfg.Deliveries[feed.Delivery]
+			switch {
+			case feed.Client != "":
+				errs.add(field+".watch_client", "is for feeds writing to a folder, the feed's client is asked already")
+			case isDelivery && dc.Type != "folder":
+				errs.add(field+".watch_client", "is for feeds writing to a folder, %s is a %s delivery", feed.Delivery, dc.Type)
+			}
+			if _, ok := cfg.Clients[feed.WatchClient]; !ok {
+				errs.add(field+".watch_client", "unknown client %q", feed.WatchClient)
+			}
+			if cfg.CompletionCheck == 0 {
+				errs.add(field+".watch_client", "needs completion_check")
+			}
+		}
+		if feed.RemoveTorrentFile && feed.WatchClient == "" {
+			errs.add(field+".remove_torrent_file", "needs watch_client")
+		}
+		if f.Seeding != nil {
+			feed.Seeding = parseSeeding(&errs, field+".seeding", f.Seeding)
+			if feed.Client == "" {
+				errs.add(field+".seeding", "needs the feed to use a client")
+			}
+			if c, ok := clients[feed.Client]; ok && c != nil {
+				if err := client.CheckSeeding(c, feed.Seeding); err != nil {
+					errs.add(field+".seeding", "client %s %v", feed.Client, err)
+				}
+			}
+		}
+
+		if feed.Filter, err = filter.New(f.Include, f.Exclude); err != nil {
+			errs.add(field, "%v", err)
+		}
+		if f.Groups != nil {
+			if feed.Groups, err = filter.NewGroups(f.Groups.Prefer, f.Groups.Ban, f.Groups.PreferredOnly); err != nil {
+				errs.add(field+".groups", "%v", err)
+			}
+		}
+		if len(f.Formats) > 0 {
+			if feed.Formats, err = filter.NewFormats(f.Formats); err != nil {
+				errs.add(field+".formats", "%v", err)
+			}
+		}
+		feed.MinSize = parseSize(&errs, field+".min_size", f.MinSize)
+		feed.MaxSize = parseSize(&errs, field+".max_size", f.MaxSize)
+		if feed.MaxSize > 0 && feed.MinSize > feed.MaxSize {
+			errs.add(field, "min_size is larger than max_size")
+		}
+		for _, name := range f.ContentTypes {
+			if !slices.ContainsFunc(cfg.ContentTypes, func(t ContentType) bool { return t.Type.Name == name }) {
+				errs.add(field+".content_types", "unknown content type %q", name)
+			}
+		}
+		feed.ContentTypes = f.ContentTypes
+		feed.FreeleechOnly = f.FreeleechOnly
+		feed.Watchlist = f.Watchlist
+		feed.WatchlistSearch = parseDuration(&errs, field+".watchlist_search", f.WatchSearch, 0)
+		switch {
+		case feed.WatchlistSearch > 0 && !f.Watchlist:
+			errs.add(field+".watchlist_search", "needs watchlist: true")
+		case feed.WatchlistSearch > 0 && ok && tc.Type == "generic" && tc.SearchURL == "":
+			errs.add(field+".watchlist_search", "tracker %s has no search_url to search", feed.Tracker)
+		}
+		feed.Private = f.Private
+		feed.MaxFailures = maxFailures
+		if f.MaxFailures < 0 {
+			errs.add(field+".max_failures", "must not be negative, got %d", f.MaxFailures)
+		} else if f.MaxFailures > 0 {
+			feed.MaxFailures = f.MaxFailures
+		}
+		feed.IgnoreOlderThan = parseDuration(&errs, field+".ignore_older_than", f.IgnoreOlder, 0)
+		feed.StaleAfter = parseDuration(&errs, field+".stale_after", f.StaleAfter, staleAfter)
+		feed.FailingAfter = parseDuration(&errs, field+".failing_after", f.FailingAfter, failingAfter)
+		feed.MaxItemsPerPoll = f.MaxItems
+		if f.MaxItems < 0 {
+			errs.add(field+".max_items_per_poll", "must not be negative, got %d", f.MaxItems)
+		}
+		feed.PageParam = f.PageParam
+		if f.PageParam != "" && f.Torznab != nil {
+			errs.add(field+".page_param", "Torznab feeds are paged by offset, leave it unset")
+		}
+		feed.Approval = f.Approval
+		if feed.DedupeKey, err = ParseDedupeKey(f.DedupeKey); err != nil {
+			errs.add(field+".dedupe_key", "%v", err)
+		}
+		if feed.SeasonPacks, err = ParsePackPolicy(f.SeasonPacks); err != nil {
+			errs.add(field+".season_packs", "%v", err)
+		}
+		feed.PackMinMissing = DefaultPackMinMissing
+		if f.PackMin < 0 {
+			errs.add(field+".pack_min_missing", "must not be negative, got %d", f.PackMin)
+		} else if f.PackMin > 0 {
+			feed.PackMinMissing = f.PackMin
+			if feed.SeasonPacks != PacksMissing {
+				errs.add(field+".pack_min_missing", "only applies with season_packs: missing")
+			}
+		}
+
+		if f.Quality != nil {
+			feed.Quality, err = quality.NewProfile(f.Quality.Tiers, f.Quality.Cutoff, f.Quality.Upgrade)
+			if err != nil {
+				errs.add(field+".quality", "%v", err)
+			} else if len(f.Quality.Delays) > 0 {
+				delays := make(map[string]time.Duration, len(f.Quality.Delays))
+				for _, tier := range sortedKeys(f.Quality.Delays) {
+					delays[tier] = parseDuration(&errs, field+".quality.delays."+tier, f.Quality.Delays[tier], 0)
+				}
+				if err := feed.Quality.SetDelays(delays); err != nil {
+					errs.add(field+".quality.delays", "%v", err)
+				} else if feed.Quality.HasDelays() && !feed.TrackEpisodes {
+					errs.add(field+".quality.delays", "needs track_episodes")
+				}
+			}
+			feed.RemoveUpgraded = f.Quality.RemoveUpgraded
+			if feed.RemoveUpgraded && feed.Client == "" {
+				errs.add(field+".quality.remove_upgraded", "needs the feed to use a client")
+			}
+		}
+
+		feed.ReplacePropers = f.ReplaceProper
+		feed.RemoveReplaced = f.RemoveReplace
+		if feed.ReplacePropers && !feed.TrackEpisodes {
+			errs.add(field+".replace_propers", "needs track_episodes")
+		}
+		if feed.RemoveReplaced && (!feed.ReplacePropers || feed.Client == "") {
+			errs.add(field+".remove_replaced", "needs replace_propers and the feed to use a client")
+		}
+
+		if f.Scoring != nil {
+			feed.Scoring, err = score.New(score.Options{
+				Resolution: f.Scoring.Resolution,
+				Source:     f.Scoring.Source,
+				Group:      f.Scoring.Group,
+				Freeleech:  f.Scoring.Freeleech,
+				TargetSize: parseSize(&errs, field+".scoring.target_size", f.Scoring.TargetSize),
+				Size:       f.Scoring.Size,
+				Window:     parseDuration(&errs, field+".scoring.window", f.Scoring.Window, 0),
+			})
+			if err != nil {
+				errs.add(field+".scoring", "%v", err)
+			} else if feed.Scoring.Window() > 0 && !feed.TrackEpisodes {
+				errs.add(field+".scoring.window", "needs track_episodes")
+			}
+		}
+
+		if f.Torznab != nil {
+			feed.Torznab = &parser.Torznab{
+				APIKey:     f.Torznab.APIKey,
+				Categories: f.Torznab.Categories,
+				Limit:      f.Torznab.Limit,
+				Pages:      f.Torznab.Pages,
+			}
+			for _, cat := range f.Torznab.Categories {
+				if cat <= 0 {
+					errs.add(field+".torznab.categories", "must be positive category IDs, got %d", cat)
+				}
+			}
+			if f.Torznab.Limit < 0 {
+				errs.add(field+".torznab.limit", "must not be negative, got %d", f.Torznab.Limit)
+			}
+			if f.Torznab.Pages < 0 {
+				errs.add(field+".torznab.pages", "must not be negative, got %d", f.Torznab.Pages)
+			}
+			// Other adapters would send the tracker's cookie to the indexer
+			if ok && tc.Type != "torznab" {
+				errs.add(field+".tracker", "torznab feeds need a tracker of type torznab, %s is %s", feed.Tracker, tc.Type)
+			}
+		}
+
+		if f.JSONAPI != nil {
+			feed.JSONAPI = parseJSONAPI(&errs, field+".json_api", *f.JSONAPI)
+			if f.Torznab != nil {
+				errs.add(field+".json_api", "can't be combined with torznab")
+			}
+		}
+
+		cfg.Feeds = append(cfg.Feeds, feed)
+	}
+
+	for _, name := range sortedKeys(cfg.Trackers) {
+		if cfg.Trackers[name].DirectURL != "" && cfg.TrackerPasskey(name) == "" {
+			errs.add("trackers."+name+".direct_url", "needs a passkey, set passkey or use a feed URL that contains one")
+		}
+		if cfg.Trackers[name].Announce.UsesPasskey() && cfg.TrackerPasskey(name) == "" {
+			errs.add("trackers."+name+".announce", "uses {passkey}, set passkey or use a feed URL that contains one")
+		}
+	}
+
+	ranked := make(map[string]bool)
+	for i, name := range raw.Priority {
+		field := fmt.Sprintf("tracker_priority[%d]", i)
+		if _, ok := cfg.Trackers[name]; !ok {
+			errs.add(field, "unknown tracker %q", name)
+		} else if ranked[name] {
+			errs.add(field, "%s is listed twice", name)
+		}
+		ranked[name] = true
+	}
+	cfg.TrackerPriority = raw.Priority
+
+	if len(errs) > 0 {
+		return nil, &ValidationError{Path: path, Problems: errs}
+	}
+	return cfg, nil
+}
+
+func parseDuration(errs *problems, field, value string, fallback time.Duration) time.Duration {
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		errs.add(field, "must be a duration like 30m or 12h, got %q", value)
+		return fallback
+	}
+	if d <= 0 {
+		errs.add(field, "must be positive, got %q", value)
+		return fallback
+	}
+	return d
+}
+
+// parseContentTypes reads the configured content types, followed by the
+// defaults not configured. A type named like a default and without
+// categories of its own uses the default's.
+func parseContentTypes(errs *problems, raw []fileContentType, cfg *Config, homeDir string) []ContentType {
+	defaults := make(map[string][]string)
+	for _, d := range category.Defaults {
+		defaults[d.Name] = d.Rules
+	}
+
+	var types []ContentType
+	seen := make(map[string]bool)
+	for i, t := range raw {
+		field := fmt.Sprintf("content_types[%d]", i)
+		if t.Name == "" {
+			errs.add(field+".name", "is required")
+			continue
+		}
+		field = "content_types." + t.Name
+		if seen[t.Name] {
+			errs.add(field, "is defined twice")
+			continue
+		}
+		seen[t.Name] = true
+
+		rules := t.Categories
+		if len(rules) == 0 {
+			rules = defaults[t.Name]
+		}
+		ct := ContentType{
+			Client:       t.Client,
+			Delivery:     t.Delivery,
+			DownloadPath: expandHome(t.DownloadPath, homeDir),
+			Category:     t.Category,
+			SavePath:     t.SavePath,
+		}
+		var err error
+		if ct.Type, err = category.NewType(t.Name, rules); err != nil {
+			errs.add(field+".categories", "%v", err)
+			continue
+		}
+		if _, ok := cfg.Clients[ct.Client]; ct.Client != "" && !ok {
+			errs.add(field+".client", "unknown client %q", ct.Client)
+		}
+		if _, ok := cfg.Deliveries[ct.Delivery]; ct.Delivery != "" && !ok {
+			errs.add(field+".delivery", "unknown delivery %q", ct.Delivery)
+		}
+		if ct.Client != "" && ct.Delivery != "" {
+			errs.add(field, "client and delivery can't both be set")
+		}
+		if (ct.Client != "" || ct.Delivery != "") && ct.DownloadPath != "" {
+			errs.add(field+".download_path", "is only used without a client or delivery, set save_path instead")
+		}
+		types = append(types, ct)
+	}
+	for _, t := range defaultContentTypes() {
+		if !seen[t.Type.Name] {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+// parseConnections reads a connections block, settings it leaves out are
+// those of inherited
+func parseConnections(errs *problems, field string, raw *fileConnections, inherited connpool.Options) connpool.Options {
+	if raw == nil {
+		return inherited
+	}
+	opts := inherited
+	if raw.MaxIdlePerHost != 0 {
+		opts.MaxIdlePerHost = raw.MaxIdlePerHost
+	}
+	opts.IdleTimeout = parseDuration(errs, field+".idle_timeout", raw.IdleTimeout, inherited.IdleTimeout)
+	if raw.HTTP2 != nil {
+		opts.DisableHTTP2 = !*raw.HTTP2
+	}
+	if err := opts.Validate(); err != nil {
+		errs.add(field, "%v", err)
+	}
+	return opts
+}
+
+func parseRate(errs *problems, field, value string) int64 {
+	if value == "" {
+		return 0
+	}
+	rate, err := bandwidth.ParseRate(value)
+	if err != nil {
+		errs.add(field, "%v", err)
+	}
+	return rate
+}
+
+// parseSeeding reads a feed's seeding limits, at least one of which is set
+func parseSeeding(errs *problems, field string, raw *fileSeeding) client.Seeding {
+	seeding := client.Seeding{
+		Ratio: raw.MinRatio,
+		Time:  parseDuration(errs, field+".min_seed_time", raw.MinSeedTime, 0),
+	}
+	if raw.MinRatio < 0 {
+		errs.add(field+".min_ratio", "must be positive, got %v", raw.MinRatio)
+		seeding.Ratio = 0
+	}
+	if raw.MinRatio == 0 && raw.MinSeedTime == "" {
+		errs.add(field, "needs min_ratio or min_seed_time")
+	}
+	return seeding
+}
+
+// parseJSONAPI compiles the JSONPath of each field of a JSON API mapping
+func parseJSONAPI(errs *problems, field string, raw fileJSONAPI) *feed.JSONMapping {
+	var m feed.JSONMapping
+	for _, path := range []struct {
+		name  string
+		value string
+		to    *feed.JSONPath
+	}{
+		{"items", raw.Items, &m.Items},
+		{"title", raw.Title, &m.Title},
+		{"link", raw.Link, &m.Link},
+		{"download", raw.Download, &m.Download},
+		{"guid", raw.GUID, &m.GUID},
+		{"infohash", raw.InfoHash, &m.InfoHash},
+		{"size", raw.Size, &m.Size},
+		{"date", raw.Date, &m.Date},
+		{"freeleech", raw.Freeleech, &m.Freeleech},
+		{"categories", raw.Categories, &m.Categories},
+	} {
+		p, err := feed.ParseJSONPath(path.value)
+		if err != nil {
+			errs.add(field+"."+path.name, "%v", err)
+		}
+		*path.to = p
+	}
+	if strings.TrimSpace(raw.Title) == "" {
+		errs.add(field+".title", "is required")
+	}
+	if strings.TrimSpace(raw.Link) == "" && strings.TrimSpace(raw.Download) == "" {
+		errs.add(field, "needs a link or download path")
+	}
+	return &m
+}
+
+func parseSize(errs *problems, field, value string) int64 {
+	if value == "" {
+		return 0
+	}
+	size, err := bytesize.Parse(value)
+	if err != nil {
+		errs.add(field, "%v", err)
+	}
+	return size
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func expandHome(path, homeDir string) string {
+	if path == "~" {
+		return homeDir
+	}
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		return filepath.Join(homeDir, rest)
+	}
+	return path
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"fmt"
+
+	"torrent-rss/internal/secrets"
+)
+
+// Secrets returns the box that encrypts stored credentials, nil when no
+// master key is set
+func (c *Config) Secrets() *secrets.Box {
+	return secrets.New(c.SecretKey)
+}
+
+// revealSecrets decrypts the credentials in a config file that were written
+// with `torrent-rss secrets encrypt`, leaving plaintext values alone
+func revealSecrets(errs *problems, raw *fileConfig, box *secrets.Box) {
+	reveal := func(field string, value *string) {
+		plain, err := box.DecryptString(*value)
+		if err != nil {
+			errs.add(field, "%v", err)
+			return
+		}
+		*value = plain
+	}
+
+	reveal("credentials.token", &raw.Credentials.Token)
+	reveal("credentials.rss_token", &raw.Credentials.RSSToken)
+	reveal("api.key", &raw.API.Key)
+	reveal("api.read_key", &raw.API.ReadKey)
+	reveal("api.password", &raw.API.Password)
+	if raw.Metadata != nil {
+		reveal("metadata.api_key", &raw.Metadata.APIKey)
+	}
+	for name, t := range raw.Trackers {
+		field := "trackers." + name
+		reveal(field+".cookie", &t.Cookie)
+		reveal(field+".passkey", &t.Passkey)
+		if t.Login != nil {
+			reveal(field+".login.password", &t.Login.Password)
+		}
+		if t.Auth != nil {
+			reveal(field+".auth.token", &t.Auth.Token)
+			reveal(field+".auth.password", &t.Auth.Password)
+		}
+		raw.Trackers[name] = t
+	}
+	for name, c := range raw.Clients {
+		reveal("clients."+name+".password", &c.Password)
+		raw.Clients[name] = c
+	}
+	for name, d := range raw.Deliveries {
+		reveal("deliveries."+name+".key_passphrase", &d.KeyPassphrase)
+		raw.Deliveries[name] = d
+	}
+	for name, n := range raw.Notifiers {
+		reveal("notifiers."+name+".url", &n.URL)
+		reveal("notifiers."+name+".password", &n.Password)
+		raw.Notifiers[name] = n
+	}
+	for i := range raw.Feeds {
+		f := &raw.Feeds[i]
+		field := fmt.Sprintf("feeds[%d]", i)
+		if f.Name != "" {
+			field = fmt.Sprintf("feeds[%s]", f.Name)
+		}
+		// Feed URLs usually carry the RSS token or passkey
+		reveal(field+".url", &f.URL)
+		if f.Torznab != nil {
+			reveal(field+".torznab.api_key", &f.Torznab.APIKey)
+		}
+	}
+}
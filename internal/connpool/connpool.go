@@ -0,0 +1,63 @@
+// Package connpool tunes how many connections to trackers are kept open,
+// for how long, and whether they may speak HTTP/2
+package connpool
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	// DefaultMaxIdlePerHost is how many connections to a host are kept open
+	// for reuse, where net/http keeps two, so feeds polled and torrents
+	// fetched at the same time don't each dial again
+	DefaultMaxIdlePerHost = 8
+	// DefaultIdleTimeout is how long an unused connection is kept open
+	DefaultIdleTimeout = 90 * time.Second
+)
+
+// Options tune the connections of a transport. The zero value keeps
+// DefaultMaxIdlePerHost connections for DefaultIdleTimeout and speaks
+// HTTP/2 to servers that offer it.
+type Options struct {
+	MaxIdlePerHost int           // 0 means DefaultMaxIdlePerHost
+	IdleTimeout    time.Duration // 0 means DefaultIdleTimeout
+	// DisableHTTP2 sticks to HTTP/1.1, for trackers and proxies in front of
+	// them that misbehave over HTTP/2
+	DisableHTTP2 bool
+}
+
+// Validate checks that no setting is negative
+func (o Options) Validate() error {
+	if o.MaxIdlePerHost < 0 {
+		return fmt.Errorf("max_idle_per_host must not be negative, got %d", o.MaxIdlePerHost)
+	}
+	if o.IdleTimeout < 0 {
+		return fmt.Errorf("idle_timeout must not be negative, got %s", o.IdleTimeout)
+	}
+	return nil
+}
+
+// Apply sets the options on t, which must not have been used yet
+func (o Options) Apply(t *http.Transport) {
+	t.MaxIdleConnsPerHost = DefaultMaxIdlePerHost
+	if o.MaxIdlePerHost > 0 {
+		t.MaxIdleConnsPerHost = o.MaxIdlePerHost
+	}
+	t.IdleConnTimeout = DefaultIdleTimeout
+	if o.IdleTimeout > 0 {
+		t.IdleConnTimeout = o.IdleTimeout
+	}
+	if o.DisableHTTP2 {
+		// A non-nil, empty map is how net/http is told not to upgrade
+		t.ForceAttemptHTTP2 = false
+		t.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	} else {
+		// t may be cloned from a transport with HTTP/2 turned off, which
+		// left it the empty map
+		t.ForceAttemptHTTP2 = true
+		t.TLSNextProto = nil
+	}
+}
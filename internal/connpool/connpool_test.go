@@ -0,0 +1,21 @@
+package connpool
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestApplyTurnsHTTP2BackOn(t *testing.T) {
+	base := &http.Transport{}
+	Options{DisableHTTP2: true}.Apply(base)
+	if base.TLSNextProto == nil || base.ForceAttemptHTTP2 {
+		t.Fatal("HTTP/2 wasn't turned off")
+	}
+
+	// A tracker speaking HTTP/2 while the others don't
+	host := base.Clone()
+	Options{}.Apply(host)
+	if host.TLSNextProto != nil || !host.ForceAttemptHTTP2 {
+		t.Error("HTTP/2 stayed off on a transport cloned from one without it")
+	}
+}
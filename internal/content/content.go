@@ -0,0 +1,143 @@
+package content
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"golang.org/x/net/html/charset"
+)
+
+// AcceptEncoding is what Transport asks servers for
+const AcceptEncoding = "gzip, deflate, br"
+
+// Transport wraps next so requests ask for compressed responses and every
+// response body arrives decoded, whatever Content-Encoding the server picked.
+// Go only decodes gzip by itself, and not even that once a header profile
+// sets Accept-Encoding.
+func Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &transport{next: next}
+}
+
+type transport struct {
+	next http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A range of a compressed body can't be decoded on its own
+	if req.Header.Get("accept-encoding") == "" && req.Header.Get("range") == "" {
+		// RoundTrippers must not modify the caller's request
+		req = req.Clone(req.Context())
+		req.Header.Set("accept-encoding", AcceptEncoding)
+	}
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := Decode(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Decode replaces a compressed response body with the decoded one, dropping
+// the Content-Encoding and Content-Length that no longer apply
+func Decode(resp *http.Response) error {
+	coding := strings.ToLower(strings.TrimSpace(resp.Header.Get("content-encoding")))
+	if coding == "" || coding == "identity" || !hasBody(resp) {
+		return nil
+	}
+
+	var decoded io.Reader
+	switch coding {
+	case "gzip", "x-gzip":
+		r, err := gzip.NewReader(resp.Body)
+		if errors.Is(err, io.EOF) {
+			// Empty despite the header
+			decoded = strings.NewReader("")
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to decode gzip response: %w", err)
+		}
+		decoded = r
+	case "deflate":
+		decoded = deflateReader(resp.Body)
+	case "br":
+		decoded = brotli.NewReader(resp.Body)
+	default:
+		return fmt.Errorf("unsupported content encoding %q", coding)
+	}
+
+	resp.Body = &decodedBody{Reader: decoded, raw: resp.Body}
+	resp.Header.Del("content-encoding")
+	resp.Header.Del("content-length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+func hasBody(resp *http.Response) bool {
+	if resp.Request != nil && resp.Request.Method == http.MethodHead {
+		return false
+	}
+	return resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotModified
+}
+
+// deflateReader reads "deflate" bodies, which should be zlib streams but are
+// raw deflate from some servers
+func deflateReader(body io.Reader) io.Reader {
+	buffered := bufio.NewReader(body)
+	header, err := buffered.Peek(2)
+	if err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		if r, err := zlib.NewReader(buffered); err == nil {
+			return r
+		}
+	}
+	return flate.NewReader(buffered)
+}
+
+// decodedBody reads the decoded stream and closes the raw one
+type decodedBody struct {
+	io.Reader
+	raw io.ReadCloser
+}
+
+func (b *decodedBody) Close() error {
+	if closer, ok := b.Reader.(io.Closer); ok {
+		closer.Close()
+	}
+	return b.raw.Close()
+}
+
+// UTF8 converts an HTML or XML body to UTF-8, going by the charset in
+// contentType, a byte order mark or a <meta> tag in that order. Pages that
+// say nothing are taken as UTF-8, or windows-1252 if they aren't valid UTF-8.
+func UTF8(body io.Reader, contentType string) (io.Reader, error) {
+	r, err := charset.NewReader(body, contentType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode charset: %w", err)
+	}
+	return r, nil
+}
+
+// CharsetReader is an xml.Decoder CharsetReader for the encodings an XML
+// declaration may name, such as windows-1251 or ISO-8859-1
+func CharsetReader(label string, input io.Reader) (io.Reader, error) {
+	r, err := charset.NewReaderLabel(label, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode charset: %w", err)
+	}
+	return r, nil
+}
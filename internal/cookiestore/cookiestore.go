@@ -0,0 +1,188 @@
+package cookiestore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"torrent-rss/internal/secrets"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Jar is an http.CookieJar that persists every cookie it is given to a file,
+// so sessions obtained from tracker logins survive restarts
+type Jar struct {
+	jar  *cookiejar.Jar
+	path string
+	box  *secrets.Box // nil stores cookies as plain JSON
+
+	mu      sync.Mutex
+	entries map[string]entry
+}
+
+// entry is a cookie together with the URL it was set for, enough to replay it
+type entry struct {
+	URL      string    `json:"url"`
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Path     string    `json:"path,omitempty"`
+	Domain   string    `json:"domain,omitempty"`
+	Expires  time.Time `json:"expires,omitempty"`
+	Secure   bool      `json:"secure,omitempty"`
+	HttpOnly bool      `json:"http_only,omitempty"`
+}
+
+// Open loads the jar stored at path, or starts an empty one if it doesn't
+// exist. A non-empty passphrase encrypts the file with AES-GCM.
+func Open(path, passphrase string) (*Jar, error) {
+	jar, err := cookiejar.New(&cookiejar.Options{
+		PublicSuffixList: publicsuffix.List,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cookie jar: %w", err)
+	}
+
+	j := &Jar{
+		jar:     jar,
+		path:    path,
+		box:     secrets.New(passphrase),
+		entries: make(map[string]entry),
+	}
+
+	if err := j.load(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+func (j *Jar) load() error {
+	data, err := os.ReadFile(j.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read cookies: %w", err)
+	}
+
+	// A jar saved before a key was set is read as is and encrypted on the
+	// next save
+	if j.box != nil {
+		plain, err := j.box.Decrypt(data)
+		if err != nil && !json.Valid(data) {
+			return fmt.Errorf("failed to decrypt cookies (wrong key?): %w", err)
+		}
+		if err == nil {
+			data = plain
+		}
+	}
+
+	var stored []entry
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return fmt.Errorf("failed to parse cookies: %w", err)
+	}
+
+	now := time.Now()
+	for _, e := range stored {
+		if !e.Expires.IsZero() && e.Expires.Before(now) {
+			continue
+		}
+		u, err := url.Parse(e.URL)
+		if err != nil {
+			continue
+		}
+		j.entries[entryKey(u, e)] = e
+		j.jar.SetCookies(u, []*http.Cookie{e.cookie()})
+	}
+	return nil
+}
+
+// SetCookies stores the cookies in memory and writes the jar to disk
+func (j *Jar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.jar.SetCookies(u, cookies)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	origin := (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/"}).String()
+	for _, c := range cookies {
+		e := entry{
+			URL:      origin,
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Domain:   c.Domain,
+			Expires:  c.Expires,
+			Secure:   c.Secure,
+			HttpOnly: c.HttpOnly,
+		}
+		if c.MaxAge > 0 {
+			e.Expires = time.Now().Add(time.Duration(c.MaxAge) * time.Second)
+		}
+
+		key := entryKey(u, e)
+		if c.MaxAge < 0 || (!e.Expires.IsZero() && e.Expires.Before(time.Now())) {
+			delete(j.entries, key)
+			continue
+		}
+		j.entries[key] = e
+	}
+
+	// The jar has no way to report write errors, the next SetCookies retries
+	_ = j.save()
+}
+
+func (j *Jar) Cookies(u *url.URL) []*http.Cookie {
+	return j.jar.Cookies(u)
+}
+
+// save writes the jar atomically; the caller must hold j.mu
+func (j *Jar) save() error {
+	stored := make([]entry, 0, len(j.entries))
+	for _, e := range j.entries {
+		stored = append(stored, e)
+	}
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+	if data, err = j.box.Encrypt(data); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(j.path), 0700); err != nil {
+		return err
+	}
+	tmp := j.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, j.path)
+}
+
+func (e entry) cookie() *http.Cookie {
+	return &http.Cookie{
+		Name:     e.Name,
+		Value:    e.Value,
+		Path:     e.Path,
+		Domain:   e.Domain,
+		Expires:  e.Expires,
+		Secure:   e.Secure,
+		HttpOnly: e.HttpOnly,
+	}
+}
+
+func entryKey(u *url.URL, e entry) string {
+	domain := e.Domain
+	if domain == "" {
+		domain = u.Hostname()
+	}
+	return domain + ";" + e.Path + ";" + e.Name
+}
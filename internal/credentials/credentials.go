@@ -0,0 +1,126 @@
+package credentials
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Credentials are the tracker secrets needed for RSS polling and downloads
+type Credentials struct {
+	UserID    string
+	PassToken string // For downloads
+	RSSToken  string // For RSS feed
+}
+
+// Cookie returns the cookie string used to authenticate downloads
+func (c Credentials) Cookie() string {
+	return "uid=" + c.UserID + "; pass=" + c.PassToken
+}
+
+// Complete reports whether every field is set
+func (c Credentials) Complete() bool {
+	return c.UserID != "" && c.PassToken != "" && c.RSSToken != ""
+}
+
+// Provider supplies credentials from some source
+type Provider interface {
+	Credentials() (Credentials, error)
+}
+
+// Static returns fixed credentials, e.g. values loaded from a config file
+type Static Credentials
+
+func (s Static) Credentials() (Credentials, error) {
+	return Credentials(s), nil
+}
+
+// Env reads credentials from the TD_USER_ID, TD_TOKEN and TD_RSS_TOKEN variables
+type Env struct{}
+
+func (Env) Credentials() (Credentials, error) {
+	return Credentials{
+		UserID:    os.Getenv("TD_USER_ID"),
+		PassToken: os.Getenv("TD_TOKEN"),
+		RSSToken:  os.Getenv("TD_RSS_TOKEN"),
+	}, nil
+}
+
+// Keyring reads credentials from the OS keyring, using secret-tool on Linux
+// and the security CLI on macOS. Entries are stored under Service with the
+// accounts user_id, token and rss_token.
+type Keyring struct {
+	Service string
+}
+
+func (k Keyring) Credentials() (Credentials, error) {
+	var creds Credentials
+	var err error
+	if creds.UserID, err = k.Lookup("user_id"); err != nil {
+		return creds, err
+	}
+	if creds.PassToken, err = k.Lookup("token"); err != nil {
+		return creds, err
+	}
+	if creds.RSSToken, err = k.Lookup("rss_token"); err != nil {
+		return creds, err
+	}
+	return creds, nil
+}
+
+// Lookup reads a single account of the service, "" if there is none
+func (k Keyring) Lookup(account string) (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "linux":
+		cmd = exec.Command("secret-tool", "lookup", "service", k.Service, "account", account)
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", k.Service, "-a", account, "-w")
+	default:
+		return "", nil
+	}
+
+	// No keyring tool installed simply means no keyring credentials
+	if cmd.Err != nil {
+		return "", nil
+	}
+
+	out, err := cmd.Output()
+	if err != nil {
+		// Both tools exit non-zero when the entry doesn't exist
+		if _, ok := err.(*exec.ExitError); ok {
+			return "", nil
+		}
+		return "", fmt.Errorf("keyring lookup for %s failed: %w", account, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Chain asks each provider in order and takes the first non-empty value for
+// every field, so e.g. the user ID can come from config and tokens from the keyring
+type Chain []Provider
+
+func (c Chain) Credentials() (Credentials, error) {
+	var merged Credentials
+	for _, provider := range c {
+		if merged.Complete() {
+			break
+		}
+		creds, err := provider.Credentials()
+		if err != nil {
+			return merged, err
+		}
+		if merged.UserID == "" {
+			merged.UserID = creds.UserID
+		}
+		if merged.PassToken == "" {
+			merged.PassToken = creds.PassToken
+		}
+		if merged.RSSToken == "" {
+			merged.RSSToken = creds.RSSToken
+		}
+	}
+	return merged, nil
+}
@@ -0,0 +1,192 @@
+// Package cron parses the five-field cron expressions of crontab(5), for
+// feeds polled at set times rather than on an interval
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// searchYears is how far ahead Next looks for a matching time
+const searchYears = 5
+
+// Schedule is a parsed cron expression
+type Schedule struct {
+	expr   string
+	minute uint64 // Bit n set when minute n matches
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+	// Like cron, a day matches either the day of month or the weekday when
+	// both are restricted, and both when either starts with *
+	domStar bool
+	dowStar bool
+}
+
+// field is the range of one of the five fields
+type field struct {
+	name     string
+	min, max int
+	names    []string // Names of the values from min on, e.g. jan
+}
+
+var (
+	minutes  = field{name: "minute", min: 0, max: 59}
+	hours    = field{name: "hour", min: 0, max: 23}
+	days     = field{name: "day of month", min: 1, max: 31}
+	months   = field{name: "month", min: 1, max: 12, names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}}
+	weekdays = field{name: "day of week", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}}
+)
+
+// macros are the shorthands cron knows
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse reads an expression of minute, hour, day of month, month and day
+// of week, like "0 3 * * *" for 03:00 every day, or a macro like @daily.
+// Fields take *, values, ranges, steps and lists, e.g. "*/15" or
+// "mon-fri", with months and weekdays by number or name.
+func Parse(expr string) (*Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	fields := strings.Fields(expr)
+	if len(fields) == 1 {
+		macro, ok := macros[strings.ToLower(fields[0])]
+		if !ok {
+			return nil, fmt.Errorf("unknown cron macro %q", fields[0])
+		}
+		fields = strings.Fields(macro)
+	}
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields (minute hour day month weekday), got %d", expr, len(fields))
+	}
+
+	s := &Schedule{
+		expr:    expr,
+		domStar: strings.HasPrefix(fields[2], "*"),
+		dowStar: strings.HasPrefix(fields[4], "*"),
+	}
+	var err error
+	for i, target := range []struct {
+		bits *uint64
+		f    field
+	}{{&s.minute, minutes}, {&s.hour, hours}, {&s.dom, days}, {&s.month, months}, {&s.dow, weekdays}} {
+		if *target.bits, err = parseField(fields[i], target.f); err != nil {
+			return nil, fmt.Errorf("cron expression %q: %w", expr, err)
+		}
+	}
+	// 7 is Sunday as well
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+
+	if s.Next(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)).IsZero() {
+		return nil, fmt.Errorf("cron expression %q never matches", expr)
+	}
+	return s, nil
+}
+
+// parseField reads a comma-separated list of *, values and ranges, each
+// with an optional /step
+func parseField(value string, f field) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(value, ",") {
+		rng, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepText)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step %q in %s", stepText, f.name)
+			}
+			step = n
+		}
+
+		low, high := f.min, f.max
+		if rng != "*" {
+			lowText, highText, isRange := strings.Cut(rng, "-")
+			var err error
+			if low, err = f.value(lowText); err != nil {
+				return 0, err
+			}
+			high = low
+			if isRange {
+				if high, err = f.value(highText); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				// Like cron, "5/10" runs from 5 to the end
+				high = f.max
+			}
+			if low > high {
+				return 0, fmt.Errorf("%s range %q runs backwards", f.name, rng)
+			}
+		}
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (f field) value(text string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(text, name) {
+			return f.min + i, nil
+		}
+	}
+	n, err := strconv.Atoi(text)
+	if err != nil || n < f.min || n > f.max {
+		return 0, fmt.Errorf("%s must be between %d and %d, got %q", f.name, f.min, f.max, text)
+	}
+	return n, nil
+}
+
+// Next returns the first matching minute after t, in t's location, or the
+// zero time if none comes within a few years
+func (s *Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, loc)
+	limit := t.AddDate(searchYears, 0, 0)
+
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// String returns the expression as it was parsed, or "" for no schedule
+func (s *Schedule) String() string {
+	if s == nil {
+		return ""
+	}
+	return s.expr
+}
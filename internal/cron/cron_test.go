@@ -0,0 +1,61 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	// A Wednesday
+	from := time.Date(2024, 1, 10, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"0 3 * * *", time.Date(2024, 1, 11, 3, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 1, 10, 10, 45, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, 1, 10, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2024, 1, 11, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC)},
+		{"@MONTHLY", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 9 * * mon-fri", time.Date(2024, 1, 11, 9, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC)},
+		{"0 12 1 jun *", time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)},
+		{"0,30 8-10 * * *", time.Date(2024, 1, 11, 8, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Either the day of month or the weekday when both are restricted
+		{"0 0 13 * fri", time.Date(2024, 1, 12, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.expr)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := s.Next(from); !got.Equal(tt.want) {
+			t.Errorf("%q: Next = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"@sometimes",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"* * * foo *",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"0 0 31 feb *",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", expr)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"torrent-rss/internal/config"
+)
+
+// StallTimeout is how long a single poll may run before the daemon counts
+// as hung
+const StallTimeout = 30 * time.Minute
+
+// PollFunc polls a single feed once, stopping early if ctx is cancelled
+type PollFunc func(ctx context.Context, feed config.Feed)
+
+// FeedStatus is what the daemon knows about the polling of one feed
+type FeedStatus struct {
+	Feed         string
+	LastPoll     time.Time // When the last poll finished, zero before the first
+	PollingSince time.Time // When the running poll started, zero when idle
+}
+
+// Daemon polls every feed on its own interval until the context is cancelled.
+// Feeds are scheduled independently but share a pool of workers, so only so
+// many polls run at the same time.
+type Daemon struct {
+	poll    PollFunc
+	jitter  time.Duration
+	workers chan struct{}
+	wg      sync.WaitGroup
+
+	mu     sync.Mutex
+	ctx    context.Context // Of Run, nil before it started and once it's done
+	feeds  []config.Feed
+	loops  map[string]*feedLoop
+	status map[string]*FeedStatus
+}
+
+// feedLoop is the schedule of one feed
+type feedLoop struct {
+	mu      sync.Mutex
+	feed    config.Feed
+	changed chan struct{} // Wakes the loop to pick up a new interval
+	stop    chan struct{} // Closed once the feed is gone
+}
+
+func (l *feedLoop) current() config.Feed {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.feed
+}
+
+// New creates a daemon running at most workers polls at once; every wait is
+// stretched by a random amount up to jitter so polls of several feeds don't
+// hit the tracker in bursts
+func New(feeds []config.Feed, jitter time.Duration, workers int, poll PollFunc) *Daemon {
+	d := &Daemon{
+		poll:    poll,
+		jitter:  jitter,
+		workers: make(chan struct{}, max(workers, 1)),
+		loops:   make(map[string]*feedLoop),
+		status:  make(map[string]*FeedStatus),
+	}
+	d.Update(feeds)
+	return d
+}
+
+// Run blocks until ctx is done and every feed loop has returned. A poll in
+// progress is allowed to finish before its loop exits.
+func (d *Daemon) Run(ctx context.Context) {
+	d.mu.Lock()
+	d.ctx = ctx
+	for _, feed := range d.feeds {
+		d.start(d.loops[feed.Name])
+	}
+	d.mu.Unlock()
+
+	<-ctx.Done()
+	d.mu.Lock()
+	d.ctx = nil
+	d.mu.Unlock()
+	d.wg.Wait()
+}
+
+// Update replaces the feeds, e.g. after the config was reloaded. New feeds
+// are scheduled like at start-up, removed ones stop once a poll in progress
+// finishes, and feeds whose interval or schedule changed are rescheduled
+// from their last poll.
+func (d *Daemon) Update(feeds []config.Feed) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	keep := make(map[string]bool, len(feeds))
+	for _, feed := range feeds {
+		keep[feed.Name] = true
+		if l, ok := d.loops[feed.Name]; ok {
+			l.mu.Lock()
+			l.feed = feed
+			l.mu.Unlock()
+			select {
+			case l.changed <- struct{}{}:
+			default:
+			}
+			continue
+		}
+		l := &feedLoop{feed: feed, changed: make(chan struct{}, 1), stop: make(chan struct{})}
+		d.loops[feed.Name] = l
+		d.status[feed.Name] = &FeedStatus{Feed: feed.Name}
+		if d.ctx != nil {
+			d.start(l)
+		}
+	}
+	for name, l := range d.loops {
+		if !keep[name] {
+			close(l.stop)
+			delete(d.loops, name)
+			delete(d.status, name)
+		}
+	}
+	d.feeds = append([]config.Feed(nil), feeds...)
+}
+
+// start runs a feed's loop; the caller must hold d.mu while d.ctx is set
+func (d *Daemon) start(l *feedLoop) {
+	d.wg.Add(1)
+	go func(ctx context.Context) {
+		defer d.wg.Done()
+		d.loop(ctx, l)
+	}(d.ctx)
+}
+
+func (d *Daemon) loop(ctx context.Context, l *feedLoop) {
+	// Stagger the first poll as well so feeds don't all start together
+	started, jitter := time.Now(), d.randomJitter()
+	var lastPoll time.Time
+	next := due(l.current(), started, lastPoll, jitter)
+	for {
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-l.stop:
+			timer.Stop()
+			return
+		case <-l.changed:
+			timer.Stop()
+			next = due(l.current(), started, lastPoll, jitter)
+			continue
+		case <-timer.C:
+		}
+
+		// Wait for a free worker, a feed due while all are busy polls late
+		select {
+		case <-ctx.Done():
+			return
+		case <-l.stop:
+			return
+		case d.workers <- struct{}{}:
+		}
+		feed := l.current()
+		d.setPolling(feed.Name, true)
+		d.poll(ctx, feed)
+		d.setPolling(feed.Name, false)
+		<-d.workers
+		lastPoll, jitter = time.Now(), d.randomJitter()
+		next = due(l.current(), started, lastPoll, jitter)
+	}
+}
+
+// due returns when a feed whose loop started at started is polled next,
+// after its last poll if there was one. Feeds on an interval poll right
+// away at first, feeds with a schedule at the first of its times after
+// the last poll, which may have passed while a poll ran late.
+func due(feed config.Feed, started, lastPoll time.Time, jitter time.Duration) time.Time {
+	if feed.Schedule != nil {
+		if lastPoll.IsZero() {
+			lastPoll = started
+		}
+		return feed.Schedule.Next(lastPoll).Add(jitter)
+	}
+	if lastPoll.IsZero() {
+		return started.Add(jitter)
+	}
+	return lastPoll.Add(feed.Interval + jitter)
+}
+
+func (d *Daemon) setPolling(name string, polling bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// The feed may have been removed while it was polled
+	status, ok := d.status[name]
+	if !ok {
+		return
+	}
+	if polling {
+		status.PollingSince = time.Now()
+	} else {
+		status.PollingSince = time.Time{}
+		status.LastPoll = time.Now()
+	}
+}
+
+// Status reports the polling state of every feed, in config order
+func (d *Daemon) Status() []FeedStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	statuses := make([]FeedStatus, 0, len(d.feeds))
+	for _, feed := range d.feeds {
+		statuses = append(statuses, *d.status[feed.Name])
+	}
+	return statuses
+}
+
+// Check returns an error if a poll has been running longer than StallTimeout,
+// which means the daemon is hung and should be restarted
+func (d *Daemon) Check() error {
+	for _, status := range d.Status() {
+		if !status.PollingSince.IsZero() && time.Since(status.PollingSince) > StallTimeout {
+			return fmt.Errorf("feed %s has been polling since %s", status.Feed, status.PollingSince.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+func (d *Daemon) randomJitter() time.Duration {
+	if d.jitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int64N(int64(d.jitter)))
+}
@@ -0,0 +1,61 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"torrent-rss/internal/client"
+	"torrent-rss/internal/downloader"
+)
+
+// Client adds torrents to a torrent client through its API
+type Client struct {
+	client client.Client
+	opts   client.AddOptions
+}
+
+// NewClient delivers to c, into the category and save path of opts unless a
+// feed picks its own
+func NewClient(c client.Client, opts client.AddOptions) *Client {
+	return &Client{client: c, opts: opts}
+}
+
+// FreeSpace asks the client how much room is left at the save path, if the
+// backend can tell
+func (c *Client) FreeSpace(ctx context.Context, target Target) (int64, error) {
+	checker, ok := c.client.(client.SpaceChecker)
+	if !ok {
+		return 0, errors.ErrUnsupported
+	}
+	savePath := c.opts.SavePath
+	if target.Dir != "" {
+		savePath = target.Dir
+	}
+	return checker.FreeSpace(ctx, savePath)
+}
+
+func (c *Client) Deliver(ctx context.Context, torrent *downloader.Torrent, target Target) error {
+	opts := c.opts
+	if target.Category != "" {
+		opts.Category = target.Category
+	}
+	if target.Dir != "" {
+		opts.SavePath = target.Dir
+	}
+	opts.Seeding = target.Seeding
+	if err := client.CheckSeeding(c.client, opts.Seeding); err != nil {
+		return fmt.Errorf("failed to add torrent to client: the client %w", err)
+	}
+
+	var err error
+	if torrent.Magnet != "" {
+		err = c.client.AddMagnet(ctx, torrent.Magnet, opts)
+	} else {
+		err = c.client.AddTorrent(ctx, torrent.Name, torrent.Data, opts)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to add torrent to client: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package delivery
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"torrent-rss/internal/client"
+	"torrent-rss/internal/downloader"
+	"torrent-rss/internal/retry"
+)
+
+// Delivery puts a fetched torrent where it gets seeded, like a watch folder
+// or a torrent client
+type Delivery interface {
+	Deliver(ctx context.Context, torrent *downloader.Torrent, target Target) error
+}
+
+// SpaceChecker is implemented by deliveries that can tell how much room is
+// left where a target's torrents end up. Those that can't for a particular
+// target return errors.ErrUnsupported.
+type SpaceChecker interface {
+	FreeSpace(ctx context.Context, target Target) (int64, error)
+}
+
+// Target is what a feed overrides about where its torrents go
+type Target struct {
+	Dir      string // Directory or client save path, empty for the delivery's own
+	Category string // Client category, empty for the client's own
+	// Seeding limits the torrent's seeding in a client, zero leaves it to
+	// the client
+	Seeding client.Seeding
+}
+
+// Options holds the settings of a configured delivery backend
+type Options struct {
+	Path string // Directory torrents are written to
+	// Remote backends
+	URL           string // e.g. sftp://user@seedbox.example:22
+	KeyFile       string // Private key for key-based auth
+	KeyPassphrase string
+	KnownHosts    string // Host keys the server is checked against
+	Timeout       time.Duration
+	Retry         retry.Policy
+}
+
+// Factory builds a Delivery from options
+type Factory func(Options) (Delivery, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes a backend available under the given name
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if factory == nil {
+		panic("delivery: Register factory is nil")
+	}
+	if _, exists := registry[name]; exists {
+		panic("delivery: Register called twice for " + name)
+	}
+	registry[name] = factory
+}
+
+// New builds the backend registered under name
+func New(name string, opts Options) (Delivery, error) {
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown delivery %q (available: %v)", name, Names())
+	}
+	return factory(opts)
+}
+
+// Names lists the registered backends in alphabetical order
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,102 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"torrent-rss/internal/diskspace"
+	"torrent-rss/internal/downloader"
+)
+
+func init() {
+	Register("folder", func(opts Options) (Delivery, error) {
+		if opts.Path == "" {
+			return nil, errors.New("folder delivery needs a path")
+		}
+		return NewFolder(opts.Path), nil
+	})
+}
+
+// Folder writes torrents into a directory, usually a torrent client's watch
+// folder. Magnet links are written as .magnet files holding the URI, which
+// most watch folders accept.
+type Folder struct {
+	dir string
+}
+
+func NewFolder(dir string) *Folder {
+	return &Folder{dir: dir}
+}
+
+// FreeSpace returns the room left on the filesystem of the target directory
+func (f *Folder) FreeSpace(ctx context.Context, target Target) (int64, error) {
+	dir := f.dir
+	if target.Dir != "" {
+		dir = target.Dir
+	}
+	return diskspace.Free(dir)
+}
+
+// Deliver writes the torrent to a .part file first and renames it into place
+// once complete, so clients watching the folder never see a truncated torrent
+func (f *Folder) Deliver(ctx context.Context, torrent *downloader.Torrent, target Target) error {
+	dir := f.dir
+	if target.Dir != "" {
+		dir = target.Dir
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create download directory: %w", err)
+	}
+
+	path := f.Path(torrent, target)
+	fmt.Printf("Saving as: %s\n", torrent.Name)
+
+	part := path + ".part"
+	if err := writeFileSync(part, fileData(torrent), 0644); err != nil {
+		os.Remove(part)
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := os.Rename(part, path); err != nil {
+		os.Remove(part)
+		return fmt.Errorf("failed to move file into place: %w", err)
+	}
+	return nil
+}
+
+// Path is where Deliver writes the torrent
+func (f *Folder) Path(torrent *downloader.Torrent, target Target) string {
+	dir := f.dir
+	if target.Dir != "" {
+		dir = target.Dir
+	}
+	return filepath.Join(dir, torrent.Name)
+}
+
+// fileData is what a watch folder expects in the torrent's file
+func fileData(torrent *downloader.Torrent) []byte {
+	if torrent.Magnet != "" {
+		return []byte(torrent.Magnet + "\n")
+	}
+	return torrent.Data
+}
+
+// writeFileSync is os.WriteFile that also flushes the data to disk, so the
+// rename can't land before the contents do
+func writeFileSync(path string, data []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
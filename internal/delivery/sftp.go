@@ -0,0 +1,297 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"torrent-rss/internal/downloader"
+	"torrent-rss/internal/retry"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// DefaultSFTPTimeout bounds connecting and logging into the server
+const DefaultSFTPTimeout = 30 * time.Second
+
+func init() {
+	Register("sftp", func(opts Options) (Delivery, error) {
+		return NewSFTP(opts)
+	})
+}
+
+// SFTP uploads torrents into a watch folder on a remote seedbox, logging in
+// with a private key. Every upload opens its own connection, deliveries are
+// far apart and a kept connection would just go stale.
+type SFTP struct {
+	addr          string
+	user          string
+	dir           string
+	keyFile       string
+	keyPassphrase string
+	knownHosts    string
+	timeout       time.Duration
+	policy        retry.Policy
+}
+
+func NewSFTP(opts Options) (*SFTP, error) {
+	u, err := url.Parse(opts.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sftp URL: %w", err)
+	}
+	if u.Scheme != "sftp" || u.Hostname() == "" {
+		return nil, fmt.Errorf("sftp URL must look like sftp://user@host[:port], got %q", opts.URL)
+	}
+	if u.User.Username() == "" {
+		return nil, errors.New("sftp URL needs a user, e.g. sftp://user@host")
+	}
+	if opts.Path == "" {
+		return nil, errors.New("sftp delivery needs a path")
+	}
+	if opts.KeyFile == "" {
+		return nil, errors.New("sftp delivery needs a key file")
+	}
+
+	knownHosts := opts.KnownHosts
+	if knownHosts == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("could not find home directory: %w", err)
+		}
+		knownHosts = filepath.Join(home, ".ssh", "known_hosts")
+	}
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultSFTPTimeout
+	}
+	port := u.Port()
+	if port == "" {
+		port = "22"
+	}
+
+	return &SFTP{
+		addr:          net.JoinHostPort(u.Hostname(), port),
+		user:          u.User.Username(),
+		dir:           opts.Path,
+		keyFile:       opts.KeyFile,
+		keyPassphrase: opts.KeyPassphrase,
+		knownHosts:    knownHosts,
+		timeout:       timeout,
+		policy:        opts.Retry,
+	}, nil
+}
+
+// clientConfig reads the key and known hosts. They're read on every upload
+// rather than up front, so a config can be checked on another machine and a
+// rotated key is picked up without a restart.
+func (s *SFTP) clientConfig() (*ssh.ClientConfig, error) {
+	signer, err := readKey(s.keyFile, s.keyPassphrase)
+	if err != nil {
+		return nil, err
+	}
+	hostKeys, err := knownhosts.New(s.knownHosts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read known hosts: %w", err)
+	}
+	return &ssh.ClientConfig{
+		User:            s.user,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: hostKeys,
+		Timeout:         s.timeout,
+	}, nil
+}
+
+func readKey(file, passphrase string) (ssh.Signer, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	var signer ssh.Signer
+	if passphrase != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(data, []byte(passphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse key file %s: %w", file, err)
+	}
+	return signer, nil
+}
+
+// Deliver uploads the torrent, retrying connection and transfer failures.
+// Uploading the same torrent again is harmless: an identical file is left
+// alone and a different one is replaced.
+func (s *SFTP) Deliver(ctx context.Context, torrent *downloader.Torrent, target Target) error {
+	dir := s.dir
+	if target.Dir != "" {
+		dir = target.Dir
+	}
+	fmt.Printf("Uploading as: %s\n", torrent.Name)
+
+	err := retry.Do(ctx, s.policy, func() error {
+		return s.upload(ctx, path.Join(dir, torrent.Name), fileData(torrent))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload to %s@%s: %w", s.user, s.addr, err)
+	}
+	return nil
+}
+
+// FreeSpace asks the server how much room is left in the upload directory,
+// which needs the statvfs extension OpenSSH servers have
+func (s *SFTP) FreeSpace(ctx context.Context, target Target) (int64, error) {
+	dir := s.dir
+	if target.Dir != "" {
+		dir = target.Dir
+	}
+	client, err := s.connect(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check free space on %s: %w", s.addr, err)
+	}
+	defer client.Close()
+
+	if _, ok := client.HasExtension("statvfs@openssh.com"); !ok {
+		return 0, errors.ErrUnsupported
+	}
+	// The directory may not exist until the first upload
+	for dir != "/" && dir != "." {
+		if _, err := client.Stat(dir); err == nil {
+			break
+		}
+		dir = path.Dir(dir)
+	}
+	stat, err := client.StatVFS(dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check free space on %s: %w", s.addr, err)
+	}
+	// FreeSpace() counts blocks reserved for root too
+	return int64(stat.Frsize * stat.Bavail), nil
+}
+
+func (s *SFTP) upload(ctx context.Context, remotePath string, data []byte) error {
+	client, err := s.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if existing, err := readRemote(client, remotePath); err == nil && bytes.Equal(existing, data) {
+		return nil
+	}
+
+	if err := client.MkdirAll(path.Dir(remotePath)); err != nil {
+		return fmt.Errorf("failed to create %s: %w", path.Dir(remotePath), err)
+	}
+
+	// Written next to the target and renamed over it once complete, so the
+	// seedbox client never picks up a truncated torrent
+	part := remotePath + ".part"
+	if err := writeRemote(client, part, data); err != nil {
+		client.Remove(part)
+		return err
+	}
+	if err := client.PosixRename(part, remotePath); err != nil {
+		// Servers without the posix-rename extension refuse to rename over
+		// an existing file
+		client.Remove(remotePath)
+		if err := client.Rename(part, remotePath); err != nil {
+			client.Remove(part)
+			return fmt.Errorf("failed to move file into place: %w", err)
+		}
+	}
+	return nil
+}
+
+// connect logs into the server. A cancelled ctx closes the connection, which
+// aborts the transfer in flight.
+func (s *SFTP) connect(ctx context.Context) (*sftp.Client, error) {
+	config, err := s.clientConfig()
+	if err != nil {
+		return nil, permanentError{err}
+	}
+
+	dialer := net.Dialer{Timeout: s.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+
+	conn.SetDeadline(time.Now().Add(s.timeout))
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, s.addr, config)
+	if err != nil {
+		stop()
+		conn.Close()
+		return nil, loginError(err)
+	}
+	conn.SetDeadline(time.Time{})
+
+	sshClient := ssh.NewClient(sshConn, chans, reqs)
+	client, err := sftp.NewClient(sshClient)
+	if err != nil {
+		stop()
+		sshClient.Close()
+		return nil, fmt.Errorf("failed to start sftp: %w", err)
+	}
+	go func() {
+		client.Wait()
+		stop()
+		sshClient.Close()
+	}()
+	return client, nil
+}
+
+// loginError marks rejected keys and unknown or changed host keys as
+// permanent, trying again won't change the server's mind
+func loginError(err error) error {
+	var keyErr *knownhosts.KeyError
+	if errors.As(err, &keyErr) || strings.Contains(err.Error(), "unable to authenticate") {
+		return permanentError{fmt.Errorf("failed to log in: %w", err)}
+	}
+	return fmt.Errorf("failed to log in: %w", err)
+}
+
+func readRemote(client *sftp.Client, name string) ([]byte, error) {
+	f, err := client.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+func writeRemote(client *sftp.Client, name string, data []byte) error {
+	f, err := client.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", name, err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
+
+// permanentError is a failure retrying can't fix
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string   { return e.err.Error() }
+func (e permanentError) Unwrap() error   { return e.err }
+func (e permanentError) Permanent() bool { return true }
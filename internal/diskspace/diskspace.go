@@ -0,0 +1,29 @@
+// Package diskspace reports how much room is left on a filesystem
+package diskspace
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Free returns the bytes available to this process on the filesystem
+// holding path. A path that doesn't exist yet is looked up through its
+// closest existing parent, since download directories are created on first
+// use. Platforms it can't tell on return errors.ErrUnsupported.
+func Free(path string) (int64, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return 0, err
+	}
+	for {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			break
+		}
+		path = parent
+	}
+	return free(path)
+}
@@ -0,0 +1,15 @@
+package diskspace
+
+import "golang.org/x/sys/windows"
+
+func free(path string) (int64, error) {
+	name, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	var available, total, totalFree uint64
+	if err := windows.GetDiskFreeSpaceEx(name, &available, &total, &totalFree); err != nil {
+		return 0, err
+	}
+	return int64(available), nil
+}
@@ -0,0 +1,127 @@
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultTTL is how long a lookup is reused
+const DefaultTTL = 5 * time.Minute
+
+// Resolver caches host lookups, so polling many feeds on the same trackers
+// doesn't ask DNS for every request. Concurrent lookups of the same host
+// share one query, and when a refresh fails the last known addresses are
+// used instead.
+type Resolver struct {
+	ttl    time.Duration
+	lookup func(ctx context.Context, host string) ([]string, error)
+
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+type entry struct {
+	addrs   []string
+	expires time.Time
+	ready   chan struct{} // Closed once a lookup in flight is done
+	err     error
+}
+
+// New creates a resolver caching lookups for ttl. A nil *Resolver doesn't
+// cache, so dialers work the same without one.
+func New(ttl time.Duration) *Resolver {
+	if ttl <= 0 {
+		return nil
+	}
+	return &Resolver{ttl: ttl, lookup: net.DefaultResolver.LookupHost, entries: make(map[string]*entry)}
+}
+
+// LookupHost returns the addresses of host, from the cache while they're fresh
+func (r *Resolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if r == nil || net.ParseIP(host) != nil {
+		return net.DefaultResolver.LookupHost(ctx, host)
+	}
+
+	r.mu.Lock()
+	e, ok := r.entries[host]
+	if ok && e.ready == nil && time.Now().Before(e.expires) {
+		r.mu.Unlock()
+		return e.addrs, nil
+	}
+	if ok && e.ready != nil {
+		// Someone else is looking it up already
+		r.mu.Unlock()
+		select {
+		case <-e.ready:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if e.err != nil {
+			return nil, e.err
+		}
+		return e.addrs, nil
+	}
+	var stale []string
+	if ok {
+		stale = e.addrs
+	}
+	e = &entry{ready: make(chan struct{})}
+	r.entries[host] = e
+	r.mu.Unlock()
+
+	addrs, err := r.lookup(ctx, host)
+	r.mu.Lock()
+	switch {
+	case err == nil:
+		e.addrs, e.expires = addrs, time.Now().Add(r.ttl)
+	case len(stale) > 0:
+		// Keep going on what worked last, and ask again next time
+		e.addrs, e.expires, err = stale, time.Time{}, nil
+	default:
+		e.err = err
+		delete(r.entries, host)
+	}
+	ready := e.ready
+	e.ready = nil
+	r.mu.Unlock()
+	close(ready)
+	return e.addrs, err
+}
+
+// DialContext wraps dialer to resolve hosts through the cache, trying the
+// addresses in random order until one connects
+func (r *Resolver) DialContext(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if r == nil {
+		return dialer.DialContext
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		addrs, err := r.LookupHost(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		if len(addrs) == 0 {
+			return nil, &net.DNSError{Err: "no addresses", Name: host, IsNotFound: true}
+		}
+
+		var errs []error
+		for _, i := range rand.Perm(len(addrs)) {
+			conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(addrs[i], port))
+			if err == nil {
+				return conn, nil
+			}
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			errs = append(errs, err)
+		}
+		return nil, errors.Join(errs...)
+	}
+}
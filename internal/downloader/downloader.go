@@ -1,25 +1,57 @@
 package downloader
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
-	"io"
+	"mime"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
-	"regexp"
 	"strings"
+	"sync"
+	"time"
+
+	"torrent-rss/internal/bandwidth"
+	"torrent-rss/internal/challenge"
+	"torrent-rss/internal/connpool"
+	"torrent-rss/internal/dnscache"
+	"torrent-rss/internal/headers"
+	"torrent-rss/internal/httpx"
+	"torrent-rss/internal/login"
+	"torrent-rss/internal/magnet"
+	"torrent-rss/internal/metainfo"
+	"torrent-rss/internal/models"
+	"torrent-rss/internal/ratelimit"
+	"torrent-rss/internal/release"
+	"torrent-rss/internal/retry"
+	"torrent-rss/internal/tracker"
 
-	"golang.org/x/net/html"
 	"golang.org/x/net/publicsuffix"
 )
 
 type Downloader struct {
-	client      *http.Client
-	downloadDir string
-	baseURL     string
-	authCookie  string
+	client     *httpx.Client
+	tracker    tracker.Tracker
+	login      *login.Session // nil when the tracker uses a static cookie
+	resolvers  []Resolver
+	partialDir string
+	// Redirect policy, see checkRedirect
+	maxRedirects int
+	// Largest torrent download, see fetchRange
+	maxTorrentSize int64
+	logf           func(format string, args ...any)
+	nameTemplate   *release.Template
+	announce       metainfo.AnnounceRules
+	bandwidth      []*bandwidth.Limiter
+	// Download links scraped ahead by Prefetch, by page URL
+	pageWorkers int
+	prefetchMu  sync.Mutex
+	prefetched  map[string]*prefetch
 }
 
 func extractAuthFromRSS(rssURL string) map[string]string {
@@ -28,8 +60,9 @@ func extractAuthFromRSS(rssURL string) map[string]string {
 		return nil
 	}
 
-	// Split the query string on semicolons since it's not standard URL formatting
-	params := strings.Split(parsedURL.RawQuery, ";")
+	// Split the query string on semicolons since it's not standard URL
+	// formatting, as well as on the usual ampersands
+	params := strings.FieldsFunc(parsedURL.RawQuery, func(r rune) bool { return r == ';' || r == '&' })
 	auth := make(map[string]string)
 
 	for _, param := range params {
@@ -45,87 +78,176 @@ func extractAuthFromRSS(rssURL string) map[string]string {
 	return auth
 }
 
-func NewDownloader(downloadDir, baseURL, cookieAuth string) (*Downloader, error) {
-	jar, err := cookiejar.New(&cookiejar.Options{
-		PublicSuffixList: publicsuffix.List,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to create cookie jar: %w", err)
-	}
+// passkeyParams are the RSS URL parameters trackers put the passkey in
+var passkeyParams = []string{"passkey", "torrent_pass", "tp", "pk", "authkey"}
 
-	client := &http.Client{
-		Jar: jar,
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return nil
-		},
+// PasskeyFromRSS returns the passkey in a tracker's RSS URL, or "" if it has none
+func PasskeyFromRSS(rssURL string) string {
+	auth := extractAuthFromRSS(rssURL)
+	for _, param := range passkeyParams {
+		if value, err := url.QueryUnescape(auth[param]); err == nil && value != "" {
+			return value
+		}
 	}
+	return ""
+}
 
-	if err := os.MkdirAll(downloadDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create download directory: %w", err)
-	}
+// Options configures the downloader's HTTP client
+type Options struct {
+	// Jar keeps tracker sessions, a persistent one survives restarts.
+	// Nil uses an in-memory jar.
+	Jar http.CookieJar
+	// Retry is applied to page fetches and downloads
+	Retry retry.Policy
+	// ConnectTimeout bounds dialing and the TLS handshake, ReadTimeout the
+	// wait for response headers. Zero means no timeout.
+	ConnectTimeout time.Duration
+	ReadTimeout    time.Duration
+	// Proxy routes tracker requests through an http://, https:// or
+	// socks5:// proxy. Nil falls back to HTTP_PROXY and HTTPS_PROXY.
+	Proxy *url.URL
+	// Resolvers are tried in order until one yields the torrent, nil means
+	// Resolvers()
+	Resolvers []Resolver
+	// Headers is the browser profile sent with every request, nil uses
+	// headers.Default()
+	Headers *headers.Profile
+	// Challenges detects, and with a solver gets past, anti-bot challenge
+	// pages. Nil only detects.
+	Challenges *challenge.Handler
+	// PartialDir keeps interrupted downloads so they can be resumed, even
+	// across restarts. Empty uses a directory in os.TempDir().
+	PartialDir string
+	// Limiter spaces out requests per host, shared with the feed parser so
+	// polls, page fetches and downloads draw from one budget. Nil doesn't limit.
+	Limiter *ratelimit.Limiter
+	// DNS caches host lookups, shared with the feed parser. Nil looks up
+	// every new connection.
+	DNS *dnscache.Resolver
+	// MaxRedirects caps the redirects a request follows, 0 means
+	// DefaultMaxRedirects
+	MaxRedirects int
+	// MaxTorrentSize is the most bytes a torrent download may have, larger
+	// ones fail with ErrParse before filling the disk. 0 means
+	// DefaultMaxTorrentSize.
+	MaxTorrentSize int64
+	// Debugf logs each request and redirect hop, nil stays quiet
+	Debugf func(format string, args ...any)
+	// Metrics counts requests per host, shared with the feed parser. Nil
+	// doesn't count.
+	Metrics *httpx.Metrics
+	// NameTemplate names saved torrents after the parts of their release
+	// name. Nil uses the tracker's CleanName.
+	NameTemplate *release.Template
+	// Announce rewrites and adds tracker URLs in downloaded torrents, the
+	// zero value leaves them as the tracker sent them
+	Announce metainfo.AnnounceRules
+	// Bandwidth throttles torrent downloads to the slowest of its limiters,
+	// which may be shared with other downloaders. Nil ones don't limit.
+	Bandwidth []*bandwidth.Limiter
+	// TLS replaces the default TLS settings, for trackers with their own CA
+	// or that want a client certificate. Nil uses the system roots.
+	TLS *tls.Config
+	// Connections tunes the connections to the tracker, e.g. turning off
+	// HTTP/2
+	Connections connpool.Options
+	// PageWorkers is how many torrent pages Prefetch scrapes at once. 0 or
+	// 1 turns prefetching off, pages are then scraped when they're needed.
+	PageWorkers int
+}
 
-	return &Downloader{
-		client:      client,
-		downloadDir: downloadDir,
-		baseURL:     baseURL,
-		authCookie:  cookieAuth,
-	}, nil
+// ParseProxy validates a proxy URL for Options.Proxy
+func ParseProxy(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("proxy URL must start with http://, https://, socks5:// or socks5h://, got %q", raw)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("proxy URL %q has no host", raw)
+	}
+	return u, nil
 }
 
-func (d *Downloader) findDownloadLink(pageURL string) (string, error) {
-	torrentID := filepath.Base(pageURL)
-	authenticatedURL := fmt.Sprintf("%s/torrent.php?id=%s", d.baseURL, torrentID)
+func NewDownloader(t tracker.Tracker, opts Options) (*Downloader, error) {
+	resolvers := opts.Resolvers
+	if len(resolvers) == 0 {
+		resolvers = Resolvers()
+	}
 
-	req, err := http.NewRequest("GET", authenticatedURL, nil)
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+	jar := opts.Jar
+	if jar == nil {
+		memoryJar, err := cookiejar.New(&cookiejar.Options{
+			PublicSuffixList: publicsuffix.List,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cookie jar: %w", err)
+		}
+		jar = memoryJar
 	}
 
-	req.Header.Set("accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7")
-	req.Header.Set("accept-language", "en-US,en;q=0.9")
-	req.Header.Set("cache-control", "max-age=0")
-	req.Header.Set("cookie", d.authCookie)
-	req.Header.Set("user-agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/130.0.0.0 Safari/537.36")
+	transport := httpx.NewTransport(httpx.TransportOptions{
+		DNS:            opts.DNS,
+		ConnectTimeout: opts.ConnectTimeout,
+		ReadTimeout:    opts.ReadTimeout,
+		Proxy:          opts.Proxy,
+		TLS:            opts.TLS,
+		// Page fetches and downloads of a poll go to the same few hosts
+		Connections: opts.Connections,
+	})
 
-	resp, err := d.client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to fetch torrent page: %w", err)
+	profile := headers.Default()
+	if opts.Headers != nil {
+		profile = *opts.Headers
 	}
-	defer resp.Body.Close()
 
-	doc, err := html.Parse(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to parse HTML: %w", err)
-	}
-
-	var downloadLink string
-	var crawler func(*html.Node)
-	crawler = func(node *html.Node) {
-		if node.Type == html.ElementNode && node.Data == "a" {
-
-			for _, attr := range node.Attr {
-				if attr.Key == "class" && attr.Val == "dl_Btn" {
-					for _, href := range node.Attr {
-						if href.Key == "href" {
-							// Add base URL to relative path
-							downloadLink = fmt.Sprintf("https://www.torrentday.com/%s", strings.TrimPrefix(href.Val, "/"))
-							return
-						}
-					}
-				}
-			}
-		}
-		for c := node.FirstChild; c != nil; c = c.NextSibling {
-			crawler(c)
-		}
+	partialDir := opts.PartialDir
+	if partialDir == "" {
+		partialDir = filepath.Join(os.TempDir(), "torrent-rss-partial")
+	}
+	maxRedirects := opts.MaxRedirects
+	if maxRedirects <= 0 {
+		maxRedirects = DefaultMaxRedirects
+	}
+	maxTorrentSize := opts.MaxTorrentSize
+	if maxTorrentSize <= 0 {
+		maxTorrentSize = DefaultMaxTorrentSize
 	}
-	crawler(doc)
 
-	if downloadLink == "" {
-		return "", fmt.Errorf("download link not found in HTML")
+	d := &Downloader{
+		tracker:        t,
+		resolvers:      resolvers,
+		partialDir:     partialDir,
+		maxRedirects:   maxRedirects,
+		maxTorrentSize: maxTorrentSize,
+		logf:           opts.Debugf,
+		nameTemplate:   opts.NameTemplate,
+		announce:       opts.Announce,
+		bandwidth:      opts.Bandwidth,
+		pageWorkers:    opts.PageWorkers,
+		prefetched:     make(map[string]*prefetch),
 	}
+	stack := httpx.Stack(httpx.StackOptions{
+		Retry:      opts.Retry,
+		Challenges: opts.Challenges,
+		Limiter:    opts.Limiter,
+		Headers:    httpx.Headers(profile),
+		Logf:       opts.Debugf,
+		Metrics:    opts.Metrics,
+	})
+	d.client = httpx.New(transport, httpx.Options{Jar: jar, CheckRedirect: d.checkRedirect}, stack...)
+	return d, nil
+}
 
-	return downloadLink, nil
+// UseLogin makes the downloader log in with the session whenever the tracker
+// asks for it, then retry the download
+func (d *Downloader) UseLogin(s *login.Session) {
+	d.login = s
+	d.client.Use(s.Transport)
 }
 
 func min(a, b int) int {
@@ -135,77 +257,152 @@ func min(a, b int) int {
 	return b
 }
 
-func (d *Downloader) DownloadTorrent(pageURL string) error {
-	downloadLink, err := d.findDownloadLink(pageURL)
-	if err != nil {
-		return fmt.Errorf("failed to find download link: %w", err)
+// Torrent is a downloaded .torrent file held in memory, or a magnet link
+// when the tracker only offers those
+type Torrent struct {
+	Name     string // Cleaned filename
+	Data     []byte
+	Magnet   string // Set instead of Data for magnet links
+	InfoHash string // Lowercase hex, empty when unknown
+	Size     int64  // Content size in bytes, 0 for magnets
+	Private  bool   // The info dict has the private flag, never set for magnets
+}
+
+// IsFreeleech asks the tracker whether the torrent on pageURL is freeleech.
+// It returns tracker.ErrFreeleechUnknown if the tracker can't tell.
+func (d *Downloader) IsFreeleech(ctx context.Context, pageURL string) (bool, error) {
+	checker, ok := d.tracker.(tracker.FreeleechChecker)
+	if !ok {
+		return false, tracker.ErrFreeleechUnknown
 	}
+	var freeleech bool
+	err := d.withLogin(ctx, func() (err error) {
+		freeleech, err = checker.IsFreeleech(ctx, d.client.Client, pageURL)
+		return err
+	})
+	return freeleech, classify(err)
+}
 
-	// Use same auth for download request
-	cookieValue := "uid=2550949; pass=8f645a7b1785f3b624c7a151456953c8"
+// Search searches the tracker for query. It returns
+// tracker.ErrSearchUnsupported if the tracker can't be searched.
+func (d *Downloader) Search(ctx context.Context, query string) ([]models.Item, error) {
+	searcher, ok := d.tracker.(tracker.Searcher)
+	if !ok {
+		return nil, tracker.ErrSearchUnsupported
+	}
+	var items []models.Item
+	err := d.withLogin(ctx, func() (err error) {
+		items, err = searcher.Search(ctx, d.client.Client, query)
+		return err
+	})
+	return items, classify(err)
+}
 
-	req, err := http.NewRequest("GET", downloadLink, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create download request: %w", err)
+// withLogin runs a request against the tracker, logging in and running it
+// once more if the session expired or was never established. A session
+// about to expire is renewed first, so the request doesn't fail halfway.
+func (d *Downloader) withLogin(ctx context.Context, request func() error) error {
+	if d.login != nil {
+		expires := d.login.Expires()
+		renewed, err := d.login.Refresh(ctx, d.client.Client)
+		if err != nil {
+			return fmt.Errorf("failed to renew session: %w", err)
+		}
+		if renewed {
+			d.debugf("Session was to expire at %s, logged in again", expires.Format(time.DateTime))
+		}
+	}
+	err := request()
+	if d.login == nil || !errors.Is(classify(err), ErrAuthExpired) {
+		return err
 	}
+	if err := d.login.Login(ctx, d.client.Client); err != nil {
+		return fmt.Errorf("failed to log in: %w", err)
+	}
+	return request()
+}
 
-	// Use same headers for download
-	req.Header.Set("accept", "*/*")
-	req.Header.Set("accept-language", "en-US,en;q=0.9")
-	req.Header.Set("cookie", cookieValue)
-	req.Header.Set("user-agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/130.0.0.0 Safari/537.36")
+// download fetches a .torrent file with the tracker's auth and checks that
+// it really is one. Interrupted downloads are resumed from where they broke
+// off rather than started over.
+func (d *Downloader) download(ctx context.Context, downloadLink string) (*Torrent, error) {
+	// The user agent and language come from the header profile
+	header := make(http.Header)
+	header.Set("accept", "*/*")
 
-	resp, err := d.client.Do(req)
+	resp, data, err := d.fetchResumable(ctx, downloadLink, header)
 	if err != nil {
-		return fmt.Errorf("failed to download torrent: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
-
-	// Get original filename and clean it
-	origFilename := filepath.Base(downloadLink)
-	cleanedFilename := cleanTorrentName(origFilename)
-
-	filepath := filepath.Join(d.downloadDir, cleanedFilename)
-	fmt.Printf("Saving as: %s\n", cleanedFilename)
 
-	out, err := os.Create(filepath)
+	// Error pages saved as .torrent would poison the watch folder, and a
+	// resumed download that doesn't parse must not be resumed again
+	os.Remove(d.partialPath(downloadLink))
+	meta, err := metainfo.Parse(data)
 	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
+		return nil, &kindError{err: fmt.Errorf("download from %s: %w", logLink(downloadLink), err), kind: ErrParse}
+	}
+	if data, err = metainfo.RewriteAnnounce(data, d.announce); err != nil {
+		return nil, &kindError{err: fmt.Errorf("failed to rewrite announce URLs of %s: %w", logLink(downloadLink), err), kind: ErrParse}
 	}
-	defer out.Close()
 
-	// Write the body to file
-	_, err = io.Copy(out, resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to write file: %w", err)
+	return &Torrent{
+		Name:     d.torrentName(torrentFilename(resp, downloadLink, meta.Name)),
+		Data:     data,
+		InfoHash: meta.InfoHash,
+		Size:     meta.Length,
+		Private:  meta.Private,
+	}, nil
+}
+
+// torrentFilename picks the name the tracker gave the file: the
+// Content-Disposition filename, the last path segment of the link if it's a
+// .torrent, or else the torrent's own name. The result is URL-escaped, as
+// CleanName expects.
+func torrentFilename(resp *http.Response, downloadLink, torrentName string) string {
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("content-disposition")); err == nil && params["filename"] != "" {
+		return url.QueryEscape(filepath.Base(params["filename"]))
+	}
+	if u, err := url.Parse(downloadLink); err == nil && strings.HasSuffix(u.EscapedPath(), ".torrent") {
+		return path.Base(u.EscapedPath())
+	}
+	if torrentName != "" {
+		return url.QueryEscape(strings.ReplaceAll(torrentName, "/", "_")) + ".torrent"
 	}
+	return filepath.Base(downloadLink)
+}
 
-	return nil
+// torrentName names a downloaded torrent after the URL-escaped filename the
+// tracker gave it, with the name template or else the tracker's CleanName
+func (d *Downloader) torrentName(filename string) string {
+	if d.nameTemplate != nil {
+		if decoded, err := url.QueryUnescape(filename); err == nil {
+			if name := d.nameTemplate.Name(decoded); name != "" {
+				return name + ".torrent"
+			}
+		}
+	}
+	return d.tracker.CleanName(filename)
 }
 
-// cleanTorrentName removes unwanted tags and normalizes the filename format
-func cleanTorrentName(filename string) string {
-	// First, URL decode the name to handle encoded characters
-	decoded, err := url.QueryUnescape(filename)
+func (d *Downloader) magnetTorrent(uri string) (*Torrent, error) {
+	link, err := magnet.Parse(uri)
 	if err != nil {
-		return filename // fallback to the original name if decoding fails
-	}
-
-	// Remove common suffixes, streaming service tags, and redundant info
-	cleaned := strings.TrimSuffix(decoded, ".torrent")
-	cleaned = strings.ReplaceAll(cleaned, " NF", "")
-	cleaned = strings.ReplaceAll(cleaned, " WEB-DL", "")
-	cleaned = strings.ReplaceAll(cleaned, " DD 5 1", "")
-	cleaned = strings.ReplaceAll(cleaned, " DD 2 0", "")
-	cleaned = strings.ReplaceAll(cleaned, " H 264", "")
-	cleaned = strings.ReplaceAll(cleaned, "-playWEB", "")
-	cleaned = strings.ReplaceAll(cleaned, " 1080p", "")
-	cleaned = strings.TrimSpace(cleaned)
-
-	// Use regex to remove unnecessary tokens like quality tags or unwanted extra info
-	cleaned = regexp.MustCompile(`\b(1080p|720p|x264|BluRay|HDRip)\b`).ReplaceAllString(cleaned, "")
-	cleaned = regexp.MustCompile(`\s+`).ReplaceAllString(cleaned, " ") // replace multiple spaces with a single space
-
-	// Add .torrent extension back
-	return cleaned + ".torrent"
+		return nil, &kindError{err: err, kind: ErrParse}
+	}
+
+	name := link.InfoHash
+	if link.Name != "" {
+		name = strings.TrimSuffix(link.Name, ".torrent")
+		if d.nameTemplate != nil {
+			if named := d.nameTemplate.Name(link.Name); named != "" {
+				name = named
+			}
+		}
+	}
+	return &Torrent{
+		Name:     strings.ReplaceAll(name, "/", "_") + ".magnet",
+		Magnet:   link.URI,
+		InfoHash: link.InfoHash,
+	}, nil
 }
@@ -0,0 +1,42 @@
+package downloader
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"torrent-rss/internal/retry"
+	"torrent-rss/internal/tracker"
+)
+
+func TestErrorsLeaveOutPasskeys(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/x-bittorrent")
+		w.Write([]byte("not a torrent"))
+	}))
+	defer srv.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	tr, err := tracker.New("torrentday", tracker.Options{BaseURL: srv.URL, Cookie: "uid=1; pass=2"})
+	if err != nil {
+		t.Fatalf("tracker.New: %v", err)
+	}
+	d, err := NewDownloader(tr, Options{Retry: retry.Policy{Attempts: 1}, PartialDir: t.TempDir(), Resolvers: []Resolver{ResolveEnclosure}})
+	if err != nil {
+		t.Fatalf("NewDownloader: %v", err)
+	}
+
+	for _, base := range []string{srv.URL, closed.URL} {
+		link := base + "/download.php/1/file.torrent?torrent_pass=secret"
+		_, err := d.Fetch(context.Background(), Source{EnclosureURL: link})
+		if err == nil {
+			t.Fatalf("Fetch(%s) succeeded", link)
+		}
+		if strings.Contains(err.Error(), "secret") {
+			t.Errorf("error names the passkey: %v", err)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package downloader
+
+import (
+	"errors"
+	"net/http"
+
+	"torrent-rss/internal/login"
+	"torrent-rss/internal/maintenance"
+	"torrent-rss/internal/tracker"
+)
+
+// Error kinds returned by Fetch and IsFreeleech, to be checked with
+// errors.Is. The error message stays the underlying one.
+var (
+	// ErrAuthExpired means the tracker no longer accepts the cookie, passkey
+	// or login session. Trackers with a login configured already logged in
+	// again and still got it.
+	ErrAuthExpired = errors.New("tracker authentication expired")
+	// ErrNotFound means the torrent is gone from the tracker
+	ErrNotFound = errors.New("torrent not found")
+	// ErrRateLimited means the tracker is turning requests away for now,
+	// even after the retry policy backed off
+	ErrRateLimited = errors.New("rate limited by tracker")
+	// ErrUnavailable means the tracker is down, e.g. for maintenance
+	ErrUnavailable = errors.New("tracker unavailable")
+	// ErrParse means the tracker sent something that isn't a torrent or
+	// magnet link
+	ErrParse = errors.New("invalid torrent")
+)
+
+// kindError tags an error with its kind, keeping its message
+type kindError struct {
+	err  error
+	kind error
+}
+
+func (e *kindError) Error() string   { return e.err.Error() }
+func (e *kindError) Unwrap() []error { return []error{e.err, e.kind} }
+
+// classify tags err with the kind of failure it is, if it's one of them,
+// and leaves the query out of the URL an HTTP error names
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+	err = scrubURL(err)
+	for _, kind := range []error{ErrAuthExpired, ErrNotFound, ErrRateLimited, ErrUnavailable, ErrParse} {
+		if errors.Is(err, kind) {
+			return err
+		}
+	}
+
+	var kind error
+	var status *tracker.StatusError
+	var down *maintenance.Error
+	switch {
+	case errors.Is(err, login.ErrLoginRequired):
+		kind = ErrAuthExpired
+	case errors.As(err, &down) && down.RateLimit:
+		kind = ErrRateLimited
+	case errors.As(err, &down):
+		kind = ErrUnavailable
+	case errors.As(err, &status):
+		kind = statusKind(status.Code)
+	}
+	if kind == nil {
+		return err
+	}
+	return &kindError{err: err, kind: kind}
+}
+
+// statusKind maps a tracker response status to an error kind, or nil
+func statusKind(code int) error {
+	switch code {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrAuthExpired
+	case http.StatusNotFound, http.StatusGone:
+		return ErrNotFound
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return ErrRateLimited
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package downloader
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"torrent-rss/internal/bandwidth"
+	"torrent-rss/internal/tracker"
+)
+
+// maxResumes is how often an interrupted download is resumed right away
+// before giving up until the next attempt
+const maxResumes = 3
+
+// DefaultMaxTorrentSize is the largest torrent downloaded when
+// Options.MaxTorrentSize is 0. Torrents of even huge season packs stay well
+// below it.
+const DefaultMaxTorrentSize = 32 << 20
+
+// errTruncated means the body ended before the advertised length
+var errTruncated = errors.New("download truncated")
+
+// torrentTypes are the Content-Types trackers send torrents with. Responses
+// without one are let through, metainfo.Parse has the last word.
+var torrentTypes = map[string]bool{
+	"application/x-bittorrent":   true,
+	"application/octet-stream":   true,
+	"binary/octet-stream":        true,
+	"application/force-download": true,
+	"application/x-download":     true,
+}
+
+// partialPath is where the download of link is kept until it completes, so
+// an interrupted download can pick up where it left off, even after a restart
+func (d *Downloader) partialPath(link string) string {
+	sum := sha1.Sum([]byte(link))
+	return filepath.Join(d.partialDir, hex.EncodeToString(sum[:])+".partial")
+}
+
+// fetchResumable downloads link into its partial file, resuming with Range
+// requests when the connection drops midway. It returns the response of the
+// last request, with its body closed, and the complete file contents.
+func (d *Downloader) fetchResumable(ctx context.Context, link string, header http.Header) (*http.Response, []byte, error) {
+	if err := os.MkdirAll(d.partialDir, 0755); err != nil {
+		return nil, nil, fmt.Errorf("failed to create partial download directory: %w", err)
+	}
+	partial := d.partialPath(link)
+
+	var resp *http.Response
+	var err error
+	for attempt := 0; attempt <= maxResumes; attempt++ {
+		var progressed bool
+		resp, progressed, err = d.fetchRange(ctx, link, header, partial)
+		// Only resume right away when bytes are arriving, other failures
+		// are retried by the retry policy or on the next poll
+		if err == nil || ctx.Err() != nil || !progressed {
+			break
+		}
+	}
+	if err != nil {
+		return resp, nil, err
+	}
+
+	data, err := os.ReadFile(partial)
+	if err != nil {
+		return resp, nil, fmt.Errorf("failed to read partial download: %w", err)
+	}
+	return resp, data, nil
+}
+
+// fetchRange requests whatever part of link the partial file is missing and
+// appends it. progressed reports whether any bytes were written.
+func (d *Downloader) fetchRange(ctx context.Context, link string, header http.Header, partial string) (*http.Response, bool, error) {
+	var offset int64
+	if info, err := os.Stat(partial); err == nil {
+		offset = info.Size()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", link, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create download request: %w", err)
+	}
+	req.Header = header.Clone()
+	// Ranges count the bytes of the file, not of a compressed body
+	req.Header.Set("accept-encoding", "identity")
+	if offset > 0 {
+		req.Header.Set("range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	// Reuse the tracker auth supplied at construction time
+	d.tracker.Authorize(req)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to download torrent: %w", err)
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_WRONLY | os.O_CREATE
+	total := resp.ContentLength
+	switch {
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// Everything was already downloaded, the content check decides
+		return resp, false, nil
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		start, size, ok := parseContentRange(resp.Header.Get("content-range"))
+		if !ok || start != offset {
+			return resp, false, fmt.Errorf("failed to resume download: unexpected Content-Range %q", resp.Header.Get("content-range"))
+		}
+		flags |= os.O_APPEND
+		total = size
+	case resp.StatusCode == http.StatusOK:
+		// The server ignored the range, start over
+		flags |= os.O_TRUNC
+		offset = 0
+	default:
+		return resp, false, fmt.Errorf("failed to download torrent: %w", &tracker.StatusError{Code: resp.StatusCode, Status: resp.Status})
+	}
+
+	// Error pages and whatever else isn't a torrent are turned away before
+	// they're written, however big they are
+	if err := d.checkResponse(resp, total); err != nil {
+		os.Remove(partial)
+		return resp, false, err
+	}
+
+	f, err := os.OpenFile(partial, flags, 0644)
+	if err != nil {
+		return resp, false, fmt.Errorf("failed to write partial download: %w", err)
+	}
+	// Read one byte past the limit to tell a body of exactly the limit from
+	// one that's too large
+	body := io.LimitReader(resp.Body, d.maxTorrentSize-offset+1)
+	written, copyErr := io.Copy(f, bandwidth.Reader(ctx, body, d.bandwidth...))
+	if err := f.Close(); err != nil && copyErr == nil {
+		copyErr = err
+	}
+	if copyErr == nil && offset+written > d.maxTorrentSize {
+		os.Remove(partial)
+		return resp, false, d.tooLarge(-1)
+	}
+	if copyErr != nil {
+		return resp, written > 0, fmt.Errorf("failed to read torrent after %d bytes: %w", offset+written, copyErr)
+	}
+	if total >= 0 && offset+written != total {
+		return resp, written > 0, fmt.Errorf("%w: got %d of %d bytes", errTruncated, offset+written, total)
+	}
+	return resp, written > 0, nil
+}
+
+// checkResponse fails responses whose Content-Type isn't one of a torrent,
+// or that say they're larger than the size limit. total is the size of the
+// whole file, -1 when unknown.
+func (d *Downloader) checkResponse(resp *http.Response, total int64) error {
+	if value := resp.Header.Get("content-type"); value != "" {
+		mediaType, _, err := mime.ParseMediaType(value)
+		if err != nil || !torrentTypes[mediaType] {
+			return &kindError{err: fmt.Errorf("download is not a torrent: got Content-Type %q", value), kind: ErrParse}
+		}
+	}
+	if total > d.maxTorrentSize {
+		return d.tooLarge(total)
+	}
+	return nil
+}
+
+// tooLarge fails a download of size bytes, -1 when it only turned out too
+// large while reading it
+func (d *Downloader) tooLarge(size int64) error {
+	err := fmt.Errorf("download is larger than the %d byte limit", d.maxTorrentSize)
+	if size >= 0 {
+		err = fmt.Errorf("%w: %d bytes", err, size)
+	}
+	return &kindError{err: err, kind: ErrParse}
+}
+
+// parseContentRange reads "bytes 100-199/200", where the size is -1 if the
+// server doesn't know it ("bytes 100-199/*")
+func parseContentRange(value string) (start, size int64, ok bool) {
+	rest, found := strings.CutPrefix(value, "bytes ")
+	if !found {
+		return 0, 0, false
+	}
+	span, total, found := strings.Cut(rest, "/")
+	if !found {
+		return 0, 0, false
+	}
+	first, _, found := strings.Cut(span, "-")
+	if !found {
+		return 0, 0, false
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	if total == "*" {
+		return start, -1, true
+	}
+	size, err = strconv.ParseInt(total, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return start, size, true
+}
@@ -0,0 +1,153 @@
+package downloader
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"torrent-rss/internal/magnet"
+	"torrent-rss/internal/tracker"
+)
+
+// prefetchTTL is how long a prefetched download link is kept for its
+// torrent, links of items that were filtered out instead go after it
+const prefetchTTL = 10 * time.Minute
+
+// prefetch is a download link being scraped ahead of its torrent's download
+type prefetch struct {
+	done chan struct{} // Closed once link or err is set
+	link string
+	err  error
+	at   time.Time
+}
+
+// Prefetching tells whether Prefetch scrapes pages ahead, so callers can
+// skip picking the sources
+func (d *Downloader) Prefetching() bool {
+	return d.pageWorkers > 1
+}
+
+// Prefetch scrapes the download links of the sources whose torrent Fetch
+// would get by scraping the page, several pages at a time and in order, so
+// their downloads don't wait on the page one after the other. The pages are
+// claimed before it returns, Fetch picks up their links, waiting for those
+// still being scraped. Each page is scraped once wait returns, which paces
+// the requests along with the caller's others; nil doesn't wait. The
+// returned channel is closed once every page was scraped, or ctx ended.
+func (d *Downloader) Prefetch(ctx context.Context, srcs []Source, wait func(context.Context) error) <-chan struct{} {
+	done := make(chan struct{})
+	if !d.Prefetching() {
+		close(done)
+		return done
+	}
+
+	d.prefetchMu.Lock()
+	for page, p := range d.prefetched {
+		if time.Since(p.at) > prefetchTTL {
+			delete(d.prefetched, page)
+		}
+	}
+	var pages []string
+	pending := make(map[string]*prefetch)
+	for _, src := range srcs {
+		if !d.scrapes(src) || d.prefetched[src.PageURL] != nil {
+			continue
+		}
+		p := &prefetch{done: make(chan struct{}), at: time.Now()}
+		d.prefetched[src.PageURL] = p
+		pending[src.PageURL] = p
+		pages = append(pages, src.PageURL)
+	}
+	d.prefetchMu.Unlock()
+
+	go func() {
+		defer close(done)
+		workers := make(chan struct{}, d.pageWorkers)
+		var wg sync.WaitGroup
+		for _, page := range pages {
+			p := pending[page]
+			select {
+			case workers <- struct{}{}:
+			case <-ctx.Done():
+				p.err = ctx.Err()
+				close(p.done)
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-workers }()
+				defer close(p.done)
+				if wait != nil {
+					if p.err = wait(ctx); p.err != nil {
+						return
+					}
+				}
+				p.link, p.err = d.scrape(ctx, page)
+			}()
+		}
+		wg.Wait()
+	}()
+	return done
+}
+
+// scrapeLink finds the download link on a torrent page, taking the one
+// Prefetch scraped when there is one. A prefetch that failed is tried
+// again, it may have been cut short.
+func (d *Downloader) scrapeLink(ctx context.Context, pageURL string) (string, error) {
+	d.prefetchMu.Lock()
+	p := d.prefetched[pageURL]
+	delete(d.prefetched, pageURL)
+	d.prefetchMu.Unlock()
+
+	if p != nil {
+		select {
+		case <-p.done:
+			if p.err == nil {
+				return p.link, nil
+			}
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+	return d.scrape(ctx, pageURL)
+}
+
+// scrape finds the download link on a torrent page
+func (d *Downloader) scrape(ctx context.Context, pageURL string) (string, error) {
+	var link string
+	err := d.withLogin(ctx, func() (err error) {
+		link, err = d.tracker.FindDownloadLink(ctx, d.client.Client, pageURL)
+		return err
+	})
+	return link, err
+}
+
+// scrapes tells whether the first resolver that applies to src scrapes its
+// page, i.e. whether Fetch will need the page
+func (d *Downloader) scrapes(src Source) bool {
+	if src.PageURL == "" || magnet.IsMagnet(src.PageURL) {
+		return false
+	}
+	for _, resolver := range d.resolvers {
+		switch resolver {
+		case ResolveEnclosure:
+			if src.EnclosureURL != "" && !magnet.IsMagnet(src.EnclosureURL) {
+				return false
+			}
+		case ResolveDirect:
+			if linker, ok := d.tracker.(tracker.DirectLinker); ok {
+				if _, ok := linker.DirectLink(src.PageURL); ok {
+					return false
+				}
+			}
+		case ResolveScrape:
+			return true
+		case ResolveMagnet:
+			if magnet.IsMagnet(src.EnclosureURL) {
+				return false
+			}
+		}
+	}
+	return false
+}
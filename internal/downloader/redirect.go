@@ -0,0 +1,105 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"torrent-rss/internal/login"
+)
+
+// DefaultMaxRedirects is how many redirects a request follows when
+// Options.MaxRedirects is 0, the same as net/http
+const DefaultMaxRedirects = 10
+
+// loginPagePattern matches the last path segment of tracker login pages,
+// such as /login.php, /takelogin.php or /account/signin
+var loginPagePattern = regexp.MustCompile(`(?i)^(take)?(login|log-in|signin|sign-in)(\.\w+)?$`)
+
+// SessionExpiredError means the tracker redirected a request to its login
+// page: the cookie or passkey doesn't let us in anymore
+type SessionExpiredError struct {
+	URL       string // The request that was redirected
+	LoginPage string
+}
+
+func (e *SessionExpiredError) Error() string {
+	return fmt.Sprintf("tracker session expired: %s redirected to the login page %s", e.URL, e.LoginPage)
+}
+
+// Is makes the error match login.ErrLoginRequired, so trackers with a login
+// configured log in again and retry, and ErrAuthExpired
+func (e *SessionExpiredError) Is(target error) bool {
+	return target == login.ErrLoginRequired || target == ErrAuthExpired
+}
+
+// checkRedirect limits how many redirects a request follows, stops at loops
+// and turns a redirect to a login page into a SessionExpiredError
+func (d *Downloader) checkRedirect(req *http.Request, via []*http.Request) error {
+	d.debugf("redirect %d: %s -> %s", len(via), logURL(via[len(via)-1].URL), logURL(req.URL))
+
+	// Logging in is expected to bounce between login pages
+	if !login.IsLoginRequest(req.Context()) && isLoginPage(req.URL) {
+		return &SessionExpiredError{URL: logURL(via[0].URL), LoginPage: logURL(req.URL)}
+	}
+	for _, previous := range via {
+		if previous.URL.String() == req.URL.String() {
+			return fmt.Errorf("redirect loop at %s", logURL(req.URL))
+		}
+	}
+	if len(via) >= d.maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", len(via))
+	}
+	return nil
+}
+
+func (d *Downloader) debugf(format string, args ...any) {
+	if d.logf != nil {
+		d.logf(format, args...)
+	}
+}
+
+func isLoginPage(u *url.URL) bool {
+	return loginPagePattern.MatchString(path.Base(u.Path))
+}
+
+// logURL leaves out the query, where trackers put passkeys and tokens
+func logURL(u *url.URL) string {
+	stripped := *u
+	stripped.RawQuery = ""
+	stripped.User = nil
+	return stripped.String()
+}
+
+// logLink is logURL for a link that's still a string
+func logLink(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "the download link"
+	}
+	return logURL(u)
+}
+
+// scrubbedError is an error whose message leaves out the query of the URL
+// it names
+type scrubbedError struct {
+	err error
+	msg string
+}
+
+func (e *scrubbedError) Error() string { return e.msg }
+func (e *scrubbedError) Unwrap() error { return e.err }
+
+// scrubURL leaves the query out of the URL an HTTP error names, as errors
+// end up in notifications and the history
+func scrubURL(err error) error {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err
+	}
+	return &scrubbedError{err: err, msg: strings.ReplaceAll(err.Error(), urlErr.URL, logLink(urlErr.URL))}
+}
@@ -0,0 +1,173 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"torrent-rss/internal/magnet"
+	"torrent-rss/internal/tracker"
+)
+
+// Resolver is one way of getting from a feed item to its torrent
+type Resolver string
+
+const (
+	ResolveEnclosure Resolver = "enclosure" // Download the feed item's enclosure URL
+	ResolveDirect    Resolver = "direct"    // Build the download URL from the passkey
+	ResolveScrape    Resolver = "scrape"    // Find the download link on the torrent page
+	ResolveMagnet    Resolver = "magnet"    // Use a magnet link from the feed item
+)
+
+// Resolvers lists every resolver in the default order, cheapest first and
+// magnets last since a .torrent file already carries the metadata
+func Resolvers() []Resolver {
+	return []Resolver{ResolveEnclosure, ResolveDirect, ResolveScrape, ResolveMagnet}
+}
+
+// ParseResolvers validates a list of resolver names, where empty means the
+// default order
+func ParseResolvers(names []string) ([]Resolver, error) {
+	if len(names) == 0 {
+		return Resolvers(), nil
+	}
+	var resolvers []Resolver
+	for _, name := range names {
+		resolver := Resolver(name)
+		known := false
+		for _, r := range Resolvers() {
+			known = known || r == resolver
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown resolver %q (available: %v)", name, Resolvers())
+		}
+		resolvers = append(resolvers, resolver)
+	}
+	return resolvers, nil
+}
+
+// Source is what a feed item says about where its torrent is. Either URL may
+// be a magnet link.
+type Source struct {
+	PageURL      string // The item link, usually the torrent page
+	EnclosureURL string
+}
+
+// errNotApplicable means a resolver has nothing to work with for a source
+var errNotApplicable = errors.New("not applicable")
+
+// resolveError collects the failures of every resolver that was tried
+type resolveError []error
+
+func (e resolveError) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Is matches only if every resolver failed that way: a missing enclosure
+// doesn't mean the torrent page is gone too
+func (e resolveError) Is(target error) bool {
+	for _, err := range e {
+		if !errors.Is(err, target) {
+			return false
+		}
+	}
+	return len(e) > 0
+}
+
+// Fetch tries each resolver in turn until one yields the torrent, so a
+// changed page layout or a dead enclosure doesn't stop downloads outright.
+// A tracker down for maintenance or rate limiting ends the chain, as does
+// cancelling ctx, which also aborts the request in flight.
+func (d *Downloader) Fetch(ctx context.Context, src Source) (*Torrent, error) {
+	var errs resolveError
+	for _, resolver := range d.resolvers {
+		torrent, err := d.resolve(ctx, resolver, src)
+		if err == nil {
+			return torrent, nil
+		}
+		if errors.Is(err, errNotApplicable) {
+			continue
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		err = fmt.Errorf("%s: %w", resolver, classify(err))
+		// A tracker that's down or rate limiting won't do better for the
+		// next resolver
+		if errors.Is(err, ErrUnavailable) || errors.Is(err, ErrRateLimited) {
+			return nil, err
+		}
+		errs = append(errs, err)
+	}
+
+	switch len(errs) {
+	case 0:
+		return nil, fmt.Errorf("no way to download %s with resolvers %v", logLink(src.PageURL), d.resolvers)
+	case 1:
+		return nil, errs[0]
+	default:
+		return nil, errs
+	}
+}
+
+func (d *Downloader) resolve(ctx context.Context, resolver Resolver, src Source) (*Torrent, error) {
+	pageURL := src.PageURL
+	if magnet.IsMagnet(pageURL) {
+		pageURL = ""
+	}
+
+	switch resolver {
+	case ResolveEnclosure:
+		if src.EnclosureURL == "" || magnet.IsMagnet(src.EnclosureURL) {
+			return nil, errNotApplicable
+		}
+		return d.downloadWithLogin(ctx, src.EnclosureURL)
+
+	case ResolveDirect:
+		linker, ok := d.tracker.(tracker.DirectLinker)
+		if !ok || pageURL == "" {
+			return nil, errNotApplicable
+		}
+		link, ok := linker.DirectLink(pageURL)
+		if !ok {
+			return nil, errNotApplicable
+		}
+		return d.downloadWithLogin(ctx, link)
+
+	case ResolveScrape:
+		if pageURL == "" {
+			return nil, errNotApplicable
+		}
+		link, err := d.scrapeLink(ctx, pageURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find download link: %w", err)
+		}
+		if magnet.IsMagnet(link) {
+			return d.magnetTorrent(link)
+		}
+		return d.downloadWithLogin(ctx, link)
+
+	case ResolveMagnet:
+		for _, link := range []string{src.PageURL, src.EnclosureURL} {
+			if magnet.IsMagnet(link) {
+				return d.magnetTorrent(link)
+			}
+		}
+		return nil, errNotApplicable
+	}
+	return nil, fmt.Errorf("unknown resolver %q", resolver)
+}
+
+func (d *Downloader) downloadWithLogin(ctx context.Context, link string) (*Torrent, error) {
+	var torrent *Torrent
+	err := d.withLogin(ctx, func() (err error) {
+		torrent, err = d.download(ctx, link)
+		return err
+	})
+	return torrent, err
+}
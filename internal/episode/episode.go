@@ -0,0 +1,259 @@
+package episode
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Info identifies the episode, episodes or season a release name is of
+type Info struct {
+	Show       string    // Show title as written in the release, dots replaced by spaces
+	Season     int       // Zero for date-based episodes
+	Episode    int       // Zero for date-based episodes and season packs
+	EndEpisode int       // Last episode of a multi-episode release like S01E01-E03, zero otherwise
+	Pack       bool      // A whole season, e.g. "S01" or "Season 1 Complete"
+	Date       time.Time // Air date for daily shows, zero otherwise
+	// Absolute marks anime numbering, which counts episodes from the show's
+	// first on without seasons. Season is zero then, and a pack is a batch
+	// of the whole show.
+	Absolute bool
+}
+
+var (
+	seasonEpisodePattern = regexp.MustCompile(`(?i)\bS(\d{1,2})[ ._-]?E(\d{1,3})((?:-?E\d{1,3}|-\d{1,3})*)\b`)
+	seasonPattern        = regexp.MustCompile(`(?i)\b(?:S(\d{1,2})|Season[ ._-]?(\d{1,2}))\b`)
+	lastNumberPattern    = regexp.MustCompile(`\d+$`)
+	crossPattern         = regexp.MustCompile(`(?i)\b(\d{1,2})x(\d{2,3})\b`)
+	datePattern          = regexp.MustCompile(`\b((?:19|20)\d{2})[ ._-](\d{2})[ ._-](\d{2})\b`)
+	separatorPattern     = regexp.MustCompile(`[._]+`)
+	yearSuffixPattern    = regexp.MustCompile(`\s*\(?\b(?:19|20)\d{2}\)?$`)
+
+	// Anime: "[Group] Title - 012v2 [1080p]", batches "[Group] Title - 01-12"
+	// or "[Group] Title (01-12)", and "[Group] Title [Batch]"
+	absolutePattern    = regexp.MustCompile(`[ _]-[ _](\d{1,4})(?:v\d{1,2})?(?:[ _]?[-~][ _]?(\d{1,4})(?:v\d{1,2})?)?(?:[ _.\[(]|$)`)
+	batchRangePattern  = regexp.MustCompile(`[\[(](\d{1,4})[ _]?[-~][ _]?(\d{1,4})[\])]`)
+	batchPattern       = regexp.MustCompile(`(?i)[\[(]?\b(?:Batch|Complete)\b[\])]?`)
+	groupPrefixPattern = regexp.MustCompile(`^\s*\[[^\]]*\][ _]*`)
+	// "Title S2 - 05" numbers episodes within the season after all
+	showSeasonPattern = regexp.MustCompile(`(?i)[ _](?:S|Season[ _]?)(\d{1,2})$`)
+	// The Code of absolute episodes, as the history records them
+	absoluteCodePattern = regexp.MustCompile(`^E(\d{1,4})(?:-E(\d{1,4}))?$`)
+)
+
+// Parse extracts show, season and episode from names like
+// "Show.Name.S01E02.1080p", "Show Name 1x02" or "Show.Name.2024.03.15",
+// multi-episode releases like "Show.Name.S01E01-E03" and season packs like
+// "Show.Name.S01.Complete", as well as anime like "[Group] Title - 012
+// [1080p]" and its batches
+func Parse(name string) (Info, bool) {
+	info, loc := Find(name)
+	return info, loc != nil
+}
+
+// Find is Parse, also returning where the episode marker starts and ends in
+// name, or nil if there is none
+func Find(name string) (Info, []int) {
+	if m := seasonEpisodePattern.FindStringSubmatchIndex(name); m != nil {
+		info := Info{
+			Show:    cleanShow(name[:m[0]]),
+			Season:  atoi(name[m[2]:m[3]]),
+			Episode: atoi(name[m[4]:m[5]]),
+		}
+		// S01E01E02, S01E01-E03 and S01E01-03 all end on the last number
+		if end := atoi(lastNumberPattern.FindString(name[m[6]:m[7]])); end > info.Episode {
+			info.EndEpisode = end
+		}
+		return info, m[:2]
+	}
+	if m := crossPattern.FindStringSubmatchIndex(name); m != nil {
+		return Info{
+			Show:    cleanShow(name[:m[0]]),
+			Season:  atoi(name[m[2]:m[3]]),
+			Episode: atoi(name[m[4]:m[5]]),
+		}, m[:2]
+	}
+	if m := datePattern.FindStringSubmatchIndex(name); m != nil {
+		date, err := time.Parse("2006-01-02", fmt.Sprintf("%s-%s-%s", name[m[2]:m[3]], name[m[4]:m[5]], name[m[6]:m[7]]))
+		if err == nil {
+			return Info{
+				Show: cleanShow(name[:m[0]]),
+				Date: date,
+			}, m[:2]
+		}
+	}
+	// Only after a group in front, as anime have it, "Artist - 24 Hours" is
+	// an album and "Show Name - 100 Greatest Hits" a title
+	anime := groupPrefixPattern.MatchString(name)
+	for _, pattern := range []*regexp.Regexp{absolutePattern, batchRangePattern} {
+		for _, m := range pattern.FindAllStringSubmatchIndex(name, -1) {
+			// "[Group] Artist - Album - 2019" and "[Group] Show (2019-2021)"
+			// are years
+			if !anime || isYear(name, m[2:4]) || isYear(name, m[4:6]) {
+				continue
+			}
+			return absolute(name, m[0], m[1], m[2:4], m[4:6])
+		}
+	}
+	// Only without any episode, "Season 1" could otherwise be part of a title
+	if m := seasonPattern.FindStringSubmatchIndex(name); m != nil {
+		season := m[2:4]
+		if season[0] < 0 {
+			season = m[4:6]
+		}
+		return Info{
+			Show:   cleanShow(name[:m[0]]),
+			Season: atoi(name[season[0]:season[1]]),
+			Pack:   true,
+		}, m[:2]
+	}
+	// "Complete" is also part of season packs and titles
+	if m := batchPattern.FindStringIndex(name); m != nil && anime {
+		return Info{
+			Show:     cleanShow(name[:m[0]]),
+			Pack:     true,
+			Absolute: true,
+		}, m
+	}
+	if m := absoluteCodePattern.FindStringSubmatchIndex(name); m != nil {
+		return absolute(name, m[0], m[1], m[2:4], m[4:6])
+	}
+	return Info{}, nil
+}
+
+// isYear tells whether the number at loc in name, if any, reads as a year
+// rather than an episode: four digits starting with 19 or 20. Anime don't
+// run that long.
+func isYear(name string, loc []int) bool {
+	if loc[0] < 0 {
+		return false
+	}
+	n := name[loc[0]:loc[1]]
+	return len(n) == 4 && (strings.HasPrefix(n, "19") || strings.HasPrefix(n, "20"))
+}
+
+// absolute builds the info of an anime episode or batch from where its
+// marker starts and ends and where its first and last episode are in name
+func absolute(name string, start, end int, first, last []int) (Info, []int) {
+	info := Info{
+		Episode:  atoi(name[first[0]:first[1]]),
+		Absolute: true,
+	}
+	if last[0] >= 0 {
+		if n := atoi(name[last[0]:last[1]]); n > info.Episode {
+			info.EndEpisode = n
+		}
+	}
+	show := strings.TrimRight(name[:start], " _")
+	if m := showSeasonPattern.FindStringSubmatchIndex(show); m != nil {
+		info.Season = atoi(show[m[2]:m[3]])
+		info.Absolute = false
+		show, start = show[:m[0]], m[0]
+	}
+	info.Show = cleanShow(show)
+	return info, []int{start, end}
+}
+
+// Key uniquely identifies the episode regardless of release group or quality.
+// A season pack's key is its SeasonKey.
+func (i Info) Key() string {
+	if i.Pack {
+		return i.SeasonKey()
+	}
+	return NormalizeShow(i.Show) + "|" + i.Code()
+}
+
+// SeasonKey identifies the season the episode is in. Keys of the season's
+// episodes start with it, followed by "E". With absolute numbering the
+// whole show is one season.
+func (i Info) SeasonKey() string {
+	if i.Absolute {
+		return NormalizeShow(i.Show) + "|"
+	}
+	return NormalizeShow(i.Show) + "|" + fmt.Sprintf("S%02d", i.Season)
+}
+
+// Code formats the episode as S01E02, S01E01-E03 for multi-episode releases,
+// S01 for season packs or as the air date for daily shows. Absolute
+// episodes are E12 or E01-E12, and batches of them Batch.
+func (i Info) Code() string {
+	switch {
+	case !i.Date.IsZero():
+		return i.Date.Format("2006-01-02")
+	case i.Absolute && i.Pack:
+		return "Batch"
+	case i.Absolute && i.EndEpisode != 0:
+		return fmt.Sprintf("E%02d-E%02d", i.Episode, i.EndEpisode)
+	case i.Absolute:
+		return fmt.Sprintf("E%02d", i.Episode)
+	case i.Pack:
+		return fmt.Sprintf("S%02d", i.Season)
+	case i.EndEpisode != 0:
+		return fmt.Sprintf("S%02dE%02d-E%02d", i.Season, i.Episode, i.EndEpisode)
+	}
+	return fmt.Sprintf("S%02dE%02d", i.Season, i.Episode)
+}
+
+// Episodes splits a multi-episode release into its single episodes. Single
+// episodes return themselves, season packs nothing.
+func (i Info) Episodes() []Info {
+	if i.Pack {
+		return nil
+	}
+	if i.EndEpisode == 0 {
+		return []Info{i}
+	}
+	episodes := make([]Info, 0, i.EndEpisode-i.Episode+1)
+	for n := i.Episode; n <= i.EndEpisode; n++ {
+		single := i
+		single.Episode, single.EndEpisode = n, 0
+		episodes = append(episodes, single)
+	}
+	return episodes
+}
+
+func (i Info) String() string {
+	return i.Show + " " + i.Code()
+}
+
+// NormalizeShow lowercases a show title and strips punctuation so that
+// "Show.Name", "Show Name" and "show-name" compare equal
+func NormalizeShow(show string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range strings.ToLower(show) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r > 127:
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			space = false
+			b.WriteRune(r)
+		case r == '\'':
+			// Drop apostrophes entirely: "Grey's" == "Greys"
+		default:
+			space = true
+		}
+	}
+	return b.String()
+}
+
+func cleanShow(raw string) string {
+	// "[Group] Show Name - 012" has the group in front
+	show := groupPrefixPattern.ReplaceAllString(raw, "")
+	show = separatorPattern.ReplaceAllString(show, " ")
+	show = strings.Trim(show, " -[]")
+	// "Show Name 2019 S01E01" refers to the same show as "Show Name S01E01",
+	// but "1923 S01E01" is titled after the year
+	if stripped := strings.TrimSpace(yearSuffixPattern.ReplaceAllString(show, "")); stripped != "" {
+		show = stripped
+	}
+	return strings.TrimSpace(show)
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
@@ -0,0 +1,84 @@
+package episode
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		show string
+		key  string // Empty for names that aren't episodes
+	}{
+		{"Show.Name.S01E02.1080p.WEB-DL-GROUP", "Show Name", "show name|S01E02"},
+		{"Show.Name.S01E01-E03.720p", "Show Name", "show name|S01E01-E03"},
+		{"Show Name 1x02 HDTV", "Show Name", "show name|S01E02"},
+		{"Show.Name.S02.Complete.1080p", "Show Name", "show name|S02"},
+		{"Show.Name.2024.03.15.1080p", "Show Name", "show name|2024-03-15"},
+
+		// A year after the title names the same show, a year alone is the title
+		{"Show.Name.2019.S01E01.1080p", "Show Name", "show name|S01E01"},
+		{"Show Name (2019) S01E01", "Show Name", "show name|S01E01"},
+		{"1923.S01E01.1080p.WEB-DL-GROUP", "1923", "1923|S01E01"},
+		{"1883.S01E01.1080p.WEB-DL-GROUP", "1883", "1883|S01E01"},
+		{"1923 (2022) S01E02", "1923", "1923|S01E02"},
+
+		{"Movie.Name.2024.1080p.BluRay-GROUP", "", ""},
+	}
+	for _, tt := range tests {
+		info, ok := Parse(tt.name)
+		if tt.key == "" {
+			if ok {
+				t.Errorf("Parse(%q) = %+v, want no episode", tt.name, info)
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("Parse(%q) found no episode", tt.name)
+			continue
+		}
+		if info.Show != tt.show || info.Key() != tt.key {
+			t.Errorf("Parse(%q) = show %q, key %q, want %q, %q", tt.name, info.Show, info.Key(), tt.show, tt.key)
+		}
+	}
+}
+
+func TestParseAbsolute(t *testing.T) {
+	tests := []struct {
+		name string
+		show string
+		code string // Empty for names that aren't episodes
+	}{
+		{"[SubsPlease] Frieren - 12 (1080p) [ABCDEF01].mkv", "Frieren", "E12"},
+		{"[Group] One Piece - 1089v2 [1080p]", "One Piece", "E1089"},
+		{"[Group] Title - 01-12 [1080p]", "Title", "E01-E12"},
+		{"[Group] Title (01-12) [BD 1080p]", "Title", "E01-E12"},
+		{"[Group] Title [Batch]", "Title", "Batch"},
+		{"[Group] Title S2 - 05 [720p]", "Title", "S02E05"},
+
+		// Years aren't episodes
+		{"Artist - Album - 2019 [MP3 320]", "", ""},
+		{"Artist - Album - 1998 [FLAC]", "", ""},
+		{"Documentary Name (2019-2021) [1080p]", "", ""},
+		{"[Group] Documentary Name (2019-2021) [1080p]", "", ""},
+
+		// Without a group in front, numbers are part of titles
+		{"Artist - 24 Hours [FLAC]", "", ""},
+		{"Show Name - 100 Greatest Hits", "", ""},
+		{"Title - 05 [720p]", "", ""},
+	}
+	for _, tt := range tests {
+		info, ok := Parse(tt.name)
+		if tt.code == "" {
+			if ok {
+				t.Errorf("Parse(%q) = %+v, want no episode", tt.name, info)
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("Parse(%q) found no episode", tt.name)
+			continue
+		}
+		if info.Show != tt.show || info.Code() != tt.code {
+			t.Errorf("Parse(%q) = show %q, code %q, want %q, %q", tt.name, info.Show, info.Code(), tt.show, tt.code)
+		}
+	}
+}
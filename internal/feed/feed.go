@@ -0,0 +1,377 @@
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"torrent-rss/internal/bytesize"
+	"torrent-rss/internal/content"
+	"torrent-rss/internal/magnet"
+	"torrent-rss/internal/models"
+)
+
+type rssDocument struct {
+	Channel struct {
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+type rssItem struct {
+	Title       string      `xml:"title"`
+	Link        string      `xml:"link"`
+	GUID        string      `xml:"guid"`
+	PubDate     string      `xml:"pubDate"`
+	Description string      `xml:"description"`
+	Enclosures  []enclosure `xml:"enclosure"`
+	Categories  []string    `xml:"category"`
+	// Torznab results (Jackett, Prowlarr) add the content size and
+	// attributes. Size matches any namespace, so it's also nyaa's
+	// <nyaa:size>1.4 GiB</nyaa:size>, hence read leniently.
+	Size  string        `xml:"size"`
+	Attrs []torznabAttr `xml:"http://torznab.com/schemas/2015/feed attr"`
+}
+
+type enclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+type torznabAttr struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// torznabError is what Torznab endpoints answer with instead of a feed
+type torznabError struct {
+	Code        string `xml:"code,attr"`
+	Description string `xml:"description,attr"`
+}
+
+type atomDocument struct {
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Summary    string         `xml:"summary"`
+	Content    string         `xml:"content"`
+	Links      []atomLink     `xml:"link"`
+	Categories []atomCategory `xml:"category"`
+}
+
+type atomCategory struct {
+	Term  string `xml:"term,attr"`
+	Label string `xml:"label,attr"`
+}
+
+type atomLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+// Layouts seen in the wild for RSS pubDate, Atom and JSON timestamps
+var dateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC822Z,
+	time.RFC822,
+	time.RFC3339,
+	time.RFC3339Nano,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+}
+
+// Parse reads an RSS 2.0, Atom or JSON Feed document and returns its items
+func Parse(r io.Reader) ([]models.Item, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read feed: %w", err)
+	}
+	if isJSON(data) {
+		items, err := parseJSONFeed(data)
+		if err != nil {
+			return nil, err
+		}
+		return finish(items), nil
+	}
+
+	root, err := rootElement(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []models.Item
+	switch root {
+	case "rss":
+		items, err = parseRSS(data)
+	case "feed":
+		items, err = parseAtom(data)
+	case "error":
+		var e torznabError
+		if err := unmarshal(data, &e); err != nil {
+			return nil, fmt.Errorf("failed to parse error response: %w", err)
+		}
+		return nil, fmt.Errorf("indexer returned error %s: %s", e.Code, e.Description)
+	default:
+		return nil, fmt.Errorf("unsupported feed format: <%s>", root)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return finish(items), nil
+}
+
+// finish fills in what every format gets the same way
+func finish(items []models.Item) []models.Item {
+	for i := range items {
+		if items[i].InfoHash == "" {
+			items[i].InfoHash = magnetHash(items[i])
+		}
+	}
+	return items
+}
+
+// magnetHash returns the infohash of a magnet link the item points at
+func magnetHash(item models.Item) string {
+	for _, link := range []string{item.EnclosureURL, item.Link} {
+		if !magnet.IsMagnet(link) {
+			continue
+		}
+		if parsed, err := magnet.Parse(link); err == nil {
+			return parsed.InfoHash
+		}
+	}
+	return ""
+}
+
+// rootElement returns the local name of the first element in the document
+func rootElement(data []byte) (string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	decoder.CharsetReader = content.CharsetReader
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", fmt.Errorf("failed to parse XML: %w", err)
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local, nil
+		}
+	}
+}
+
+// unmarshal is xml.Unmarshal for documents in any encoding their XML
+// declaration names, e.g. windows-1251 on some trackers
+func unmarshal(data []byte, v any) error {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = content.CharsetReader
+	return decoder.Decode(v)
+}
+
+func parseRSS(data []byte) ([]models.Item, error) {
+	var doc rssDocument
+	if err := unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse RSS: %w", err)
+	}
+
+	items := make([]models.Item, 0, len(doc.Channel.Items))
+	for _, raw := range doc.Channel.Items {
+		enc := torrentEnclosure(raw.Enclosures)
+		size, _ := bytesize.Parse(raw.Size)
+		item := models.Item{
+			Title:        strings.TrimSpace(raw.Title),
+			Link:         strings.TrimSpace(raw.Link),
+			EnclosureURL: enc.URL,
+			GUID:         strings.TrimSpace(raw.GUID),
+			PubDate:      parseDate(raw.PubDate),
+			Description:  strings.TrimSpace(raw.Description),
+			Size:         itemSize(max(enc.Length, size), raw.Description),
+			Freeleech:    isFreeleech(raw.Title + "\n" + raw.Description),
+			Categories:   addCategories(nil, raw.Categories...),
+		}
+		applyTorznabAttrs(&item, raw.Attrs)
+		linkEnclosure(&item)
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// applyTorznabAttrs fills in what Torznab attributes say about an item. A
+// download volume factor of 0 is how indexers mark freeleech.
+func applyTorznabAttrs(item *models.Item, attrs []torznabAttr) {
+	for _, attr := range attrs {
+		value := strings.TrimSpace(attr.Value)
+		switch attr.Name {
+		case "size":
+			if size, err := strconv.ParseInt(value, 10, 64); err == nil && size > 0 {
+				item.Size = size
+			}
+		case "downloadvolumefactor":
+			if factor, err := strconv.ParseFloat(value, 64); err == nil {
+				item.Freeleech = factor == 0
+			}
+		case "magneturl":
+			if item.EnclosureURL == "" {
+				item.EnclosureURL = value
+			}
+		case "infohash":
+			if hash, err := magnet.NormalizeHash(value); err == nil {
+				item.InfoHash = hash
+			}
+		case "category":
+			item.Categories = addCategories(item.Categories, value)
+		}
+	}
+}
+
+func parseAtom(data []byte) ([]models.Item, error) {
+	var doc atomDocument
+	if err := unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse Atom: %w", err)
+	}
+
+	items := make([]models.Item, 0, len(doc.Entries))
+	for _, raw := range doc.Entries {
+		item := models.Item{
+			Title:       strings.TrimSpace(raw.Title),
+			GUID:        strings.TrimSpace(raw.ID),
+			Description: strings.TrimSpace(raw.Summary),
+		}
+		if item.Description == "" {
+			item.Description = strings.TrimSpace(raw.Content)
+		}
+
+		// Prefer the published date, Atom only requires updated
+		item.PubDate = parseDate(raw.Published)
+		if item.PubDate.IsZero() {
+			item.PubDate = parseDate(raw.Updated)
+		}
+
+		var enclosures []enclosure
+		for _, link := range raw.Links {
+			switch link.Rel {
+			case "", "alternate":
+				if item.Link == "" {
+					item.Link = strings.TrimSpace(link.Href)
+				}
+			case "enclosure":
+				enclosures = append(enclosures, enclosure{URL: link.Href, Length: link.Length, Type: link.Type})
+			}
+		}
+		for _, c := range raw.Categories {
+			item.Categories = addCategories(item.Categories, c.Term, c.Label)
+		}
+		enc := torrentEnclosure(enclosures)
+		item.EnclosureURL = enc.URL
+		item.Size = itemSize(enc.Length, item.Description)
+		linkEnclosure(&item)
+		item.Freeleech = isFreeleech(item.Title + "\n" + item.Description)
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// addCategories adds the non-empty categories not in list yet
+func addCategories(list []string, categories ...string) []string {
+	for _, c := range categories {
+		if c = strings.TrimSpace(c); c != "" && !slices.Contains(list, c) {
+			list = append(list, c)
+		}
+	}
+	return list
+}
+
+// torrentEnclosure picks the enclosure holding the torrent. Feeds may list
+// cover images or samples as enclosures too: those are passed over, and an
+// enclosure typed as a torrent or magnet wins over an untyped one.
+func torrentEnclosure(enclosures []enclosure) enclosure {
+	var best enclosure
+	bestRank := 0
+	for _, enc := range enclosures {
+		enc.URL = strings.TrimSpace(enc.URL)
+		kind := strings.ToLower(strings.TrimSpace(enc.Type))
+		rank := 1
+		switch {
+		case enc.URL == "":
+			continue
+		case strings.Contains(kind, "bittorrent"), magnet.IsMagnet(enc.URL), isTorrentURL(enc.URL):
+			rank = 2
+		case strings.HasPrefix(kind, "image/"), strings.HasPrefix(kind, "audio/"),
+			strings.HasPrefix(kind, "video/"), strings.HasPrefix(kind, "text/"):
+			continue
+		}
+		if rank > bestRank {
+			best, bestRank = enc, rank
+		}
+	}
+	return best
+}
+
+// linkEnclosure treats an item link to a .torrent file as its enclosure,
+// as some trackers put the authenticated download URL there instead, so it's
+// downloaded rather than scraped like a torrent page
+func linkEnclosure(item *models.Item) {
+	if item.EnclosureURL == "" && isTorrentURL(item.Link) {
+		item.EnclosureURL = item.Link
+	}
+}
+
+// isTorrentURL reports whether a URL's path names a .torrent file
+func isTorrentURL(link string) bool {
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	return strings.HasSuffix(strings.ToLower(u.Path), ".torrent")
+}
+
+// itemSize prefers the enclosure length, falling back to a size mentioned in
+// the description like "Size: 1.4 GB". Lengths under 1 MiB are the size of
+// the .torrent file itself or a placeholder, not the size of the content.
+func itemSize(length int64, description string) int64 {
+	if length > 1<<20 {
+		return length
+	}
+	return bytesize.Find(description)
+}
+
+var (
+	freeleechPattern    = regexp.MustCompile(`(?i)\bfree[ -]?leech\b|\[FL\]`)
+	notFreeleechPattern = regexp.MustCompile(`(?i)\bfree[ -]?leech\W{0,3}(no|false|0)\b`)
+)
+
+// isFreeleech looks for freeleech markers like "[FL]" or "Freeleech" in
+// item text, ignoring explicit negatives such as "Freeleech: No"
+func isFreeleech(text string) bool {
+	return freeleechPattern.MatchString(text) && !notFreeleechPattern.MatchString(text)
+}
+
+// parseDate tries every known layout and returns the zero time if none match
+func parseDate(value string) time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
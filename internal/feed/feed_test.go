@@ -0,0 +1,70 @@
+package feed
+
+import (
+	"strings"
+	"testing"
+
+	"torrent-rss/internal/bytesize"
+)
+
+func TestParseNyaaSize(t *testing.T) {
+	const doc = `<?xml version="1.0" encoding="UTF-8"?>
+<rss xmlns:nyaa="https://nyaa.si/xmlns/nyaa" version="2.0">
+  <channel>
+    <title>Nyaa - Home - Torrent File RSS</title>
+    <item>
+      <title>[SubsPlease] Frieren - 12 (1080p) [ABCDEF01].mkv</title>
+      <link>https://nyaa.si/download/1234567.torrent</link>
+      <guid isPermaLink="true">https://nyaa.si/view/1234567</guid>
+      <pubDate>Fri, 01 Dec 2023 17:01:02 -0000</pubDate>
+      <nyaa:seeders>1200</nyaa:seeders>
+      <nyaa:infoHash>0123456789abcdef0123456789abcdef01234567</nyaa:infoHash>
+      <nyaa:size>1.4 GiB</nyaa:size>
+    </item>
+  </channel>
+</rss>`
+
+	items, err := Parse(strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("got %d items, want 1", len(items))
+	}
+	if want, _ := bytesize.Parse("1.4 GiB"); items[0].Size != want {
+		t.Errorf("Size = %d, want %d", items[0].Size, want)
+	}
+}
+
+func TestParseTorznabSize(t *testing.T) {
+	const doc = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:torznab="http://torznab.com/schemas/2015/feed">
+  <channel>
+    <item>
+      <title>Show.Name.S01E02.1080p.WEB-DL-GROUP</title>
+      <link>https://indexer.example/dl/1</link>
+      <size>2147483648</size>
+      <torznab:attr name="downloadvolumefactor" value="0" />
+    </item>
+    <item>
+      <title>Show.Name.S01E03.1080p.WEB-DL-GROUP</title>
+      <link>https://indexer.example/dl/2</link>
+      <size>not a size</size>
+    </item>
+  </channel>
+</rss>`
+
+	items, err := Parse(strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("got %d items, want 2", len(items))
+	}
+	if items[0].Size != 2<<30 || !items[0].Freeleech {
+		t.Errorf("first item: Size = %d, Freeleech = %v", items[0].Size, items[0].Freeleech)
+	}
+	if items[1].Size != 0 {
+		t.Errorf("unreadable size: Size = %d, want 0", items[1].Size)
+	}
+}
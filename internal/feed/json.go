@@ -0,0 +1,278 @@
+package feed
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"torrent-rss/internal/bytesize"
+	"torrent-rss/internal/magnet"
+	"torrent-rss/internal/models"
+)
+
+// jsonFeedVersion prefixes the version URL of every JSON Feed, e.g.
+// https://jsonfeed.org/version/1.1
+const jsonFeedVersion = "https://jsonfeed.org/version/"
+
+type jsonFeedDocument struct {
+	Version string         `json:"version"`
+	Items   []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            any                  `json:"id"` // A string, but some feeds use numbers
+	URL           string               `json:"url"`
+	ExternalURL   string               `json:"external_url"`
+	Title         string               `json:"title"`
+	ContentHTML   string               `json:"content_html"`
+	ContentText   string               `json:"content_text"`
+	Summary       string               `json:"summary"`
+	DatePublished string               `json:"date_published"`
+	DateModified  string               `json:"date_modified"`
+	Tags          []string             `json:"tags"`
+	Attachments   []jsonFeedAttachment `json:"attachments"`
+}
+
+type jsonFeedAttachment struct {
+	URL         string `json:"url"`
+	MimeType    string `json:"mime_type"`
+	Title       string `json:"title"`
+	SizeInBytes int64  `json:"size_in_bytes"`
+}
+
+// JSONMapping picks the fields of items out of a tracker's JSON API
+// response. Items selects the items, an array or each element it matches,
+// and the other paths are relative to each item. Zero paths are left unset,
+// except a zero Items, which takes the response as the array of items.
+type JSONMapping struct {
+	Items    JSONPath
+	Title    JSONPath
+	Link     JSONPath // The torrent page
+	Download JSONPath // The torrent file or magnet link
+	GUID     JSONPath
+	InfoHash JSONPath
+	// Size is a number of bytes or a size like "1.4 GB"
+	Size JSONPath
+	// Date is a timestamp in any layout feeds use, or Unix seconds or
+	// milliseconds
+	Date JSONPath
+	// Freeleech is true, a number other than 0, or "yes" or "true"
+	Freeleech JSONPath
+	// Categories are every string or number the path selects
+	Categories JSONPath
+}
+
+// isJSON tells whether a document is JSON rather than XML
+func isJSON(data []byte) bool {
+	data = bytes.TrimLeft(bytes.TrimPrefix(data, []byte("\ufeff")), " \t\r\n")
+	return len(data) > 0 && (data[0] == '{' || data[0] == '[')
+}
+
+// decodeJSON decodes a document keeping numbers as written, so big IDs and
+// sizes aren't rounded
+func decodeJSON(data []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(bytes.TrimPrefix(data, []byte("\ufeff"))))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+func parseJSONFeed(data []byte) ([]models.Item, error) {
+	var doc jsonFeedDocument
+	if err := decodeJSON(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON Feed: %w", err)
+	}
+	if !strings.HasPrefix(doc.Version, jsonFeedVersion) {
+		return nil, errors.New("unsupported feed format: JSON that isn't a JSON Feed, give the feed a json_api mapping")
+	}
+
+	items := make([]models.Item, 0, len(doc.Items))
+	for _, raw := range doc.Items {
+		guid, _ := jsonString(raw.ID)
+		item := models.Item{
+			Title:       strings.TrimSpace(raw.Title),
+			Link:        strings.TrimSpace(raw.URL),
+			GUID:        strings.TrimSpace(guid),
+			Description: strings.TrimSpace(raw.Summary),
+			Categories:  addCategories(nil, raw.Tags...),
+		}
+		if item.Link == "" {
+			item.Link = strings.TrimSpace(raw.ExternalURL)
+		}
+		for _, text := range []string{raw.ContentText, raw.ContentHTML} {
+			if item.Description == "" {
+				item.Description = strings.TrimSpace(text)
+			}
+		}
+		item.PubDate = parseDate(raw.DatePublished)
+		if item.PubDate.IsZero() {
+			item.PubDate = parseDate(raw.DateModified)
+		}
+
+		enclosures := make([]enclosure, 0, len(raw.Attachments))
+		for _, a := range raw.Attachments {
+			enclosures = append(enclosures, enclosure{URL: a.URL, Length: a.SizeInBytes, Type: a.MimeType})
+		}
+		enc := torrentEnclosure(enclosures)
+		// Titles are optional, an attachment's may name the release
+		for _, a := range raw.Attachments {
+			if item.Title == "" && a.URL == enc.URL {
+				item.Title = strings.TrimSpace(a.Title)
+			}
+		}
+		item.EnclosureURL = enc.URL
+		item.Size = itemSize(enc.Length, item.Description)
+		linkEnclosure(&item)
+		item.Freeleech = isFreeleech(item.Title + "\n" + item.Description)
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// ParseJSON reads a tracker's JSON API response with a mapping and returns
+// its items. Relative links are resolved against baseURL, the URL the
+// response came from. Items without a title are skipped.
+func ParseJSON(r io.Reader, m JSONMapping, baseURL string) ([]models.Item, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read feed: %w", err)
+	}
+	var doc any
+	if err := decodeJSON(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid feed URL: %w", err)
+	}
+
+	found := m.Items.values(doc)
+	if len(found) == 0 {
+		return nil, fmt.Errorf("the JSON response has nothing at %s", itemsPath(m))
+	}
+	// A path to the array of items, rather than to each item
+	if list, ok := found[0].([]any); ok && len(found) == 1 {
+		found = list
+	}
+
+	items := make([]models.Item, 0, len(found))
+	for _, raw := range found {
+		item := models.Item{
+			Title:        strings.TrimSpace(field(raw, m.Title)),
+			Link:         resolve(base, field(raw, m.Link)),
+			EnclosureURL: resolve(base, field(raw, m.Download)),
+			GUID:         strings.TrimSpace(field(raw, m.GUID)),
+			PubDate:      jsonDate(raw, m.Date),
+			Size:         jsonSize(raw, m.Size),
+			Freeleech:    jsonBool(raw, m.Freeleech),
+		}
+		if item.Title == "" {
+			continue
+		}
+		if hash, err := magnet.NormalizeHash(field(raw, m.InfoHash)); err == nil {
+			item.InfoHash = hash
+		}
+		if !m.Categories.IsZero() {
+			for _, value := range m.Categories.values(raw) {
+				list, ok := value.([]any)
+				if !ok {
+					list = []any{value}
+				}
+				for _, v := range list {
+					if category, ok := jsonString(v); ok {
+						item.Categories = addCategories(item.Categories, category)
+					}
+				}
+			}
+		}
+		linkEnclosure(&item)
+		item.Freeleech = item.Freeleech || isFreeleech(item.Title)
+		items = append(items, item)
+	}
+	return finish(items), nil
+}
+
+// itemsPath names the items path in errors, the response itself when unset
+func itemsPath(m JSONMapping) string {
+	if m.Items.IsZero() {
+		return "$"
+	}
+	return m.Items.String()
+}
+
+// field returns the string, number or boolean a path selects in an item,
+// or "" when the path is unset or selects nothing of the kind
+func field(item any, p JSONPath) string {
+	if p.IsZero() {
+		return ""
+	}
+	value, ok := p.first(item)
+	if !ok {
+		return ""
+	}
+	s, _ := jsonString(value)
+	return s
+}
+
+// jsonString converts a JSON scalar to a string
+func jsonString(value any) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	return "", false
+}
+
+// resolve makes a link absolute against the feed URL
+func resolve(base *url.URL, link string) string {
+	link = strings.TrimSpace(link)
+	if link == "" {
+		return ""
+	}
+	ref, err := url.Parse(link)
+	if err != nil || ref.IsAbs() {
+		return link
+	}
+	return base.ResolveReference(ref).String()
+}
+
+// jsonDate reads a timestamp, or Unix seconds or milliseconds
+func jsonDate(item any, p JSONPath) time.Time {
+	value := field(item, p)
+	if unix, err := strconv.ParseInt(value, 10, 64); err == nil && unix > 0 {
+		if unix > 1e12 {
+			return time.UnixMilli(unix).UTC()
+		}
+		return time.Unix(unix, 0).UTC()
+	}
+	return parseDate(value)
+}
+
+// jsonSize reads a number of bytes or a size like "1.4 GB", 0 if neither
+func jsonSize(item any, p JSONPath) int64 {
+	size, err := bytesize.Parse(field(item, p))
+	if err != nil {
+		return 0
+	}
+	return size
+}
+
+// jsonBool reads true, a number other than 0, or "yes" or "true"
+func jsonBool(item any, p JSONPath) bool {
+	value := strings.ToLower(strings.TrimSpace(field(item, p)))
+	if n, err := strconv.ParseFloat(value, 64); err == nil {
+		return n != 0
+	}
+	return value == "true" || value == "yes"
+}
@@ -0,0 +1,156 @@
+package feed
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// JSONPath is a JSONPath expression limited to what picking fields out of
+// an API response takes: member names like $.data.name or $['file name'],
+// array indexes like $.files[0], and the wildcard [*] or .* for every
+// element or member. The leading $ may be left out.
+type JSONPath struct {
+	raw   string
+	steps []pathStep
+}
+
+// pathStep is a member name, an array index, or with all set every element
+type pathStep struct {
+	name  string
+	index int
+	array bool
+	all   bool
+}
+
+// ParseJSONPath reads a JSONPath expression. An empty expression is the
+// zero JSONPath, which IsZero reports.
+func ParseJSONPath(expr string) (JSONPath, error) {
+	expr = strings.TrimSpace(expr)
+	p := JSONPath{raw: expr}
+	if expr == "" {
+		return p, nil
+	}
+	rest := strings.TrimPrefix(expr, "$")
+	// A path without $ starts with a member name
+	if rest == expr && !strings.HasPrefix(rest, "[") && !strings.HasPrefix(rest, ".") {
+		rest = "." + rest
+	}
+
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			if strings.HasPrefix(rest, ".") {
+				return JSONPath{}, fmt.Errorf("invalid JSONPath %q: recursive descent isn't supported", expr)
+			}
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			name := rest[:end]
+			if name == "" {
+				return JSONPath{}, fmt.Errorf("invalid JSONPath %q: empty member name", expr)
+			}
+			p.steps = append(p.steps, pathStep{name: name, all: name == "*"})
+			rest = rest[end:]
+
+		case '[':
+			step, n, err := parseBracket(rest)
+			if err != nil {
+				return JSONPath{}, fmt.Errorf("invalid JSONPath %q: %w", expr, err)
+			}
+			p.steps = append(p.steps, step)
+			rest = rest[n:]
+
+		default:
+			return JSONPath{}, fmt.Errorf("invalid JSONPath %q: unexpected %q", expr, rest[0])
+		}
+	}
+	return p, nil
+}
+
+// parseBracket reads a [...] step at the start of s and how long it is
+func parseBracket(s string) (pathStep, int, error) {
+	if len(s) > 1 && (s[1] == '\'' || s[1] == '"') {
+		end := strings.IndexByte(s[2:], s[1])
+		if end < 0 || !strings.HasPrefix(s[2+end+1:], "]") {
+			return pathStep{}, 0, fmt.Errorf("unterminated member name in %s", s)
+		}
+		return pathStep{name: s[2 : 2+end]}, 2 + end + 2, nil
+	}
+	end := strings.IndexByte(s, ']')
+	if end < 0 {
+		return pathStep{}, 0, fmt.Errorf("missing ] in %s", s)
+	}
+	inner := strings.TrimSpace(s[1:end])
+	if inner == "*" {
+		return pathStep{all: true}, end + 1, nil
+	}
+	index, err := strconv.Atoi(inner)
+	if err != nil {
+		return pathStep{}, 0, fmt.Errorf("[%s] is neither an index, a quoted name nor *", inner)
+	}
+	return pathStep{index: index, array: true}, end + 1, nil
+}
+
+// IsZero tells whether the path was left empty
+func (p JSONPath) IsZero() bool {
+	return p.raw == ""
+}
+
+func (p JSONPath) String() string {
+	return p.raw
+}
+
+// values returns what the path selects in a decoded JSON value, in order.
+// The zero path selects v itself. Negative indexes count from the end.
+func (p JSONPath) values(v any) []any {
+	current := []any{v}
+	for _, step := range p.steps {
+		var next []any
+		for _, value := range current {
+			switch node := value.(type) {
+			case map[string]any:
+				switch {
+				case step.all:
+					// By name, as objects keep no order
+					for _, name := range slices.Sorted(maps.Keys(node)) {
+						next = append(next, node[name])
+					}
+				case !step.array:
+					if member, ok := node[step.name]; ok {
+						next = append(next, member)
+					}
+				}
+			case []any:
+				switch {
+				case step.all:
+					next = append(next, node...)
+				case step.array:
+					i := step.index
+					if i < 0 {
+						i += len(node)
+					}
+					if i >= 0 && i < len(node) {
+						next = append(next, node[i])
+					}
+				}
+			}
+		}
+		current = next
+	}
+	return current
+}
+
+// first returns the first value the path selects that isn't null
+func (p JSONPath) first(v any) (any, bool) {
+	for _, value := range p.values(v) {
+		if value != nil {
+			return value, true
+		}
+	}
+	return nil, false
+}
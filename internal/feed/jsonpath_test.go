@@ -0,0 +1,58 @@
+package feed
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestJSONPath(t *testing.T) {
+	var doc any
+	if err := json.Unmarshal([]byte(`{
+		"data": {
+			"torrents": [
+				{"name": "first", "files": [{"size": 1}, {"size": 2}]},
+				{"name": "second", "files": [{"size": 3}]}
+			],
+			"file name": "spaced"
+		},
+		"tags": {"b": "two", "a": "one"}
+	}`), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		expr string
+		want []any
+	}{
+		{"", []any{doc}},
+		{"$.data.torrents[0].name", []any{"first"}},
+		{"data.torrents[1].name", []any{"second"}},
+		{"$.data.torrents[-1].name", []any{"second"}},
+		{"$.data.torrents[5].name", nil},
+		{"$['data']['file name']", []any{"spaced"}},
+		{`$["data"]["file name"]`, []any{"spaced"}},
+		{"$.data.torrents[*].name", []any{"first", "second"}},
+		{"$.data.torrents.*.files[*].size", []any{1.0, 2.0, 3.0}},
+		{"$.tags.*", []any{"one", "two"}},
+		{"$.missing.name", nil},
+	}
+	for _, tt := range tests {
+		p, err := ParseJSONPath(tt.expr)
+		if err != nil {
+			t.Errorf("ParseJSONPath(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := p.values(doc); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q selects %#v, want %#v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseJSONPathErrors(t *testing.T) {
+	for _, expr := range []string{"$..name", "$.data.", "$[0", "$['name]", "$[name]", "$x"} {
+		if _, err := ParseJSONPath(expr); err == nil {
+			t.Errorf("ParseJSONPath(%q) succeeded, want an error", expr)
+		}
+	}
+}
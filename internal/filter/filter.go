@@ -0,0 +1,92 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Filter decides whether a release title is wanted. Every include pattern
+// must match and no exclude pattern may match. Patterns are case-insensitive.
+type Filter struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+// New compiles include and exclude patterns
+func New(include, exclude []string) (*Filter, error) {
+	f := &Filter{}
+	var err error
+	if f.include, err = compile(include); err != nil {
+		return nil, fmt.Errorf("invalid include pattern: %w", err)
+	}
+	if f.exclude, err = compile(exclude); err != nil {
+		return nil, fmt.Errorf("invalid exclude pattern: %w", err)
+	}
+	return f, nil
+}
+
+func compile(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		if pattern == "" {
+			continue
+		}
+		// Check the pattern as written so errors don't mention the added flag
+		if _, err := regexp.Compile(pattern); err != nil {
+			return nil, err
+		}
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// Match reports whether title passes the filter. When it doesn't, the
+// returned rule describes why, e.g. `exclude "CAM|HDTS"`.
+func (f *Filter) Match(title string) (bool, string) {
+	if f == nil {
+		return true, ""
+	}
+	for _, re := range f.include {
+		if !re.MatchString(title) {
+			return false, fmt.Sprintf("include %q", stripFlags(re))
+		}
+	}
+	for _, re := range f.exclude {
+		if re.MatchString(title) {
+			return false, fmt.Sprintf("exclude %q", stripFlags(re))
+		}
+	}
+	return true, ""
+}
+
+// Includes returns the include patterns as configured
+func (f *Filter) Includes() []string {
+	if f == nil {
+		return nil
+	}
+	patterns := make([]string, len(f.include))
+	for i, re := range f.include {
+		patterns[i] = stripFlags(re)
+	}
+	return patterns
+}
+
+// Excludes returns the exclude patterns as configured
+func (f *Filter) Excludes() []string {
+	if f == nil {
+		return nil
+	}
+	patterns := make([]string, len(f.exclude))
+	for i, re := range f.exclude {
+		patterns[i] = stripFlags(re)
+	}
+	return patterns
+}
+
+func stripFlags(re *regexp.Regexp) string {
+	return re.String()[len("(?i)"):]
+}
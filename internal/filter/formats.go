@@ -0,0 +1,94 @@
+package filter
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"torrent-rss/internal/music"
+)
+
+// Formats only accepts music releases in the listed formats, e.g. FLAC only,
+// preferring them in the order listed. MP3 stands for MP3 of any bitrate.
+type Formats struct {
+	formats []string
+}
+
+// NewFormats builds the format list from names like FLAC, V0 or 320,
+// ignoring case
+func NewFormats(formats []string) (*Formats, error) {
+	f := &Formats{}
+	for _, name := range formats {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		i := slices.IndexFunc(music.Formats, func(known string) bool {
+			return strings.EqualFold(known, name)
+		})
+		if i < 0 {
+			return nil, fmt.Errorf("unknown format %q (known: %s)", name, strings.Join(music.Formats, ", "))
+		}
+		f.formats = append(f.formats, music.Formats[i])
+	}
+	if len(f.formats) == 0 {
+		return nil, fmt.Errorf("formats needs at least one format")
+	}
+	return f, nil
+}
+
+// Match reports whether title is a release in one of the formats. When it
+// isn't, the returned rule describes why, e.g. `format "320" not wanted`.
+func (f *Formats) Match(title string) (bool, string) {
+	if f == nil {
+		return true, ""
+	}
+	format := format(title)
+	switch {
+	case format == "":
+		return false, "no music format"
+	case f.rank(format) < 0:
+		return false, fmt.Sprintf("format %q not wanted", format)
+	}
+	return true, ""
+}
+
+// Rank returns how preferred the format of title is, 0 for the first of the
+// formats, or len(formats) when it isn't one of them
+func (f *Formats) Rank(title string) int {
+	if f == nil {
+		return 0
+	}
+	if rank := f.rank(format(title)); rank >= 0 {
+		return rank
+	}
+	return len(f.formats)
+}
+
+func (f *Formats) rank(format string) int {
+	if format == "" {
+		return -1
+	}
+	for i, wanted := range f.formats {
+		if wanted == format || (wanted == "MP3" && music.IsMP3(format)) {
+			return i
+		}
+	}
+	return -1
+}
+
+// List returns the formats as configured
+func (f *Formats) List() []string {
+	if f == nil {
+		return nil
+	}
+	return f.formats
+}
+
+func format(title string) string {
+	r, ok := music.Parse(title)
+	if !ok {
+		return ""
+	}
+	return r.Format
+}
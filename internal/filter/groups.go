@@ -0,0 +1,91 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+
+	"torrent-rss/internal/release"
+)
+
+// Groups decides on releases by the group that put them out, e.g. preferring
+// NTb and FLUX and banning YIFY. Group names are compared ignoring case.
+type Groups struct {
+	preferred []string
+	banned    []string
+	// PreferredOnly rejects releases of every group that isn't preferred,
+	// including releases without a group
+	PreferredOnly bool
+
+	prefer map[string]bool
+	ban    map[string]bool
+}
+
+// NewGroups builds the group lists. A group can't be both preferred and
+// banned, and PreferredOnly needs at least one preferred group.
+func NewGroups(preferred, banned []string, preferredOnly bool) (*Groups, error) {
+	g := &Groups{PreferredOnly: preferredOnly, prefer: make(map[string]bool), ban: make(map[string]bool)}
+	for _, name := range preferred {
+		if name = strings.TrimSpace(name); name != "" {
+			g.preferred = append(g.preferred, name)
+			g.prefer[strings.ToLower(name)] = true
+		}
+	}
+	for _, name := range banned {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if g.prefer[strings.ToLower(name)] {
+			return nil, fmt.Errorf("group %q is both preferred and banned", name)
+		}
+		g.banned = append(g.banned, name)
+		g.ban[strings.ToLower(name)] = true
+	}
+	if preferredOnly && len(g.preferred) == 0 {
+		return nil, fmt.Errorf("preferred_only needs preferred groups")
+	}
+	return g, nil
+}
+
+// Match reports whether the group of title is acceptable. When it isn't, the
+// returned rule describes why, e.g. `banned group "YIFY"`.
+func (g *Groups) Match(title string) (bool, string) {
+	if g == nil {
+		return true, ""
+	}
+	group := release.Parse(title).Group
+	switch {
+	case g.ban[strings.ToLower(group)]:
+		return false, fmt.Sprintf("banned group %q", group)
+	case g.PreferredOnly && group == "":
+		return false, "no release group, only preferred groups are grabbed"
+	case g.PreferredOnly && !g.prefer[strings.ToLower(group)]:
+		return false, fmt.Sprintf("group %q is not preferred", group)
+	}
+	return true, ""
+}
+
+// Prefers reports whether title is a release of a preferred group
+func (g *Groups) Prefers(title string) bool {
+	if g == nil {
+		return false
+	}
+	group := release.Parse(title).Group
+	return group != "" && g.prefer[strings.ToLower(group)]
+}
+
+// Preferred returns the preferred groups as configured
+func (g *Groups) Preferred() []string {
+	if g == nil {
+		return nil
+	}
+	return g.preferred
+}
+
+// Banned returns the banned groups as configured
+func (g *Groups) Banned() []string {
+	if g == nil {
+		return nil
+	}
+	return g.banned
+}
@@ -0,0 +1,164 @@
+package headers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// DefaultProfile is the browser trackers see when nothing else is configured
+const DefaultProfile = "chrome"
+
+// Profile is the set of headers sent with every request to a tracker, so
+// it looks like a browser instead of a Go program
+type Profile struct {
+	UserAgent      string
+	AcceptLanguage string
+	// Headers are sent as is, overriding the profile and anything the
+	// request already set
+	Headers map[string]string
+}
+
+// profiles are realistic header sets of current desktop browsers
+var profiles = map[string]Profile{
+	"chrome": {
+		UserAgent:      "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/130.0.0.0 Safari/537.36",
+		AcceptLanguage: "en-US,en;q=0.9",
+		Headers: map[string]string{
+			"sec-ch-ua":          `"Chromium";v="130", "Google Chrome";v="130", "Not?A_Brand";v="99"`,
+			"sec-ch-ua-mobile":   "?0",
+			"sec-ch-ua-platform": `"macOS"`,
+		},
+	},
+	"firefox": {
+		UserAgent:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:132.0) Gecko/20100101 Firefox/132.0",
+		AcceptLanguage: "en-US,en;q=0.5",
+	},
+	"safari": {
+		UserAgent:      "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/18.1 Safari/605.1.15",
+		AcceptLanguage: "en-US,en;q=0.9",
+	},
+}
+
+// Named returns a copy of a built-in profile, DefaultProfile for ""
+func Named(name string) (Profile, error) {
+	if name == "" {
+		name = DefaultProfile
+	}
+	p, ok := profiles[strings.ToLower(name)]
+	if !ok {
+		return Profile{}, fmt.Errorf("unknown header profile %q (available: %s)", name, strings.Join(Names(), ", "))
+	}
+	p.Headers = copyHeaders(p.Headers)
+	return p, nil
+}
+
+// Default returns the DefaultProfile
+func Default() Profile {
+	p, _ := Named(DefaultProfile)
+	return p
+}
+
+// Names lists the built-in profiles
+func Names() []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Merge returns p with the non-empty fields of override applied on top
+func (p Profile) Merge(override Profile) Profile {
+	if override.UserAgent != "" {
+		p.UserAgent = override.UserAgent
+	}
+	if override.AcceptLanguage != "" {
+		p.AcceptLanguage = override.AcceptLanguage
+	}
+	p.Headers = copyHeaders(p.Headers)
+	for key, value := range override.Headers {
+		if p.Headers == nil {
+			p.Headers = make(map[string]string)
+		}
+		p.Headers[key] = value
+	}
+	return p
+}
+
+// Apply sets the profile's headers on h. The User-Agent and Accept-Language
+// are only set when h has none, as a challenge clearance is bound to the user
+// agent that earned it. Custom headers always win.
+func (p Profile) Apply(h http.Header) {
+	if p.UserAgent != "" && h.Get("user-agent") == "" {
+		h.Set("user-agent", p.UserAgent)
+	}
+	if p.AcceptLanguage != "" && h.Get("accept-language") == "" {
+		h.Set("accept-language", p.AcceptLanguage)
+	}
+	for key, value := range p.Headers {
+		h.Set(key, value)
+	}
+}
+
+// ParseList reads custom headers written as "Name: value", as in
+// "X-Requested-With: XMLHttpRequest"
+func ParseList(lines []string) (map[string]string, error) {
+	if len(lines) == 0 {
+		return nil, nil
+	}
+	parsed := make(map[string]string, len(lines))
+	for _, line := range lines {
+		key, value, ok := strings.Cut(line, ":")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("header must look like \"Name: value\", got %q", line)
+		}
+		parsed[key] = strings.TrimSpace(value)
+	}
+	return parsed, nil
+}
+
+func copyHeaders(h map[string]string) map[string]string {
+	if h == nil {
+		return nil
+	}
+	copied := make(map[string]string, len(h))
+	for key, value := range h {
+		copied[key] = value
+	}
+	return copied
+}
+
+// Transport wraps next so every request carries the profile's headers
+func Transport(next http.RoundTripper, p Profile) http.RoundTripper {
+	return HostTransport(next, nil, p)
+}
+
+// HostTransport wraps next so requests carry the profile of their host, or
+// fallback for hosts without one
+func HostTransport(next http.RoundTripper, byHost map[string]Profile, fallback Profile) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &transport{next: next, byHost: byHost, fallback: fallback}
+}
+
+type transport struct {
+	next     http.RoundTripper
+	byHost   map[string]Profile
+	fallback Profile
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	p, ok := t.byHost[strings.ToLower(req.URL.Hostname())]
+	if !ok {
+		p = t.fallback
+	}
+	// RoundTrippers must not modify the caller's request
+	req = req.Clone(req.Context())
+	p.Apply(req.Header)
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,92 @@
+package history
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// DefaultBackend is the backend used when none is configured
+const DefaultBackend = "bolt"
+
+// Backend is where a Store keeps its records: named buckets of keys and
+// values, read and written in transactions. Values are JSON, or sealed JSON
+// when the store encrypts.
+type Backend interface {
+	// View runs fn in a read-only transaction
+	View(fn func(Tx) error) error
+	// Update runs fn in a read-write transaction, which is rolled back if
+	// fn returns an error
+	Update(fn func(Tx) error) error
+	Close() error
+}
+
+// Tx is a backend transaction
+type Tx interface {
+	// Bucket returns the named bucket, created on first write
+	Bucket(name string) Bucket
+}
+
+// Bucket is a set of records ordered by key. Its records must not be
+// changed from within ForEach or Prefix.
+type Bucket interface {
+	// Get returns the value of key, or nil if there's none
+	Get(key string) ([]byte, error)
+	Put(key string, value []byte) error
+	Delete(key string) error
+	// ForEach calls fn for every record in key order
+	ForEach(fn func(key string, value []byte) error) error
+	// Prefix calls fn for the records whose keys start with prefix, in key order
+	Prefix(prefix string, fn func(key string, value []byte) error) error
+}
+
+// BackendFactory opens or creates a backend's database at path
+type BackendFactory func(path string) (Backend, error)
+
+// ErrLocked means another process has the database open
+var ErrLocked = errors.New("database is locked by another process")
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]BackendFactory)
+)
+
+// RegisterBackend makes a storage backend available under the given name
+func RegisterBackend(name string, factory BackendFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if factory == nil {
+		panic("history: RegisterBackend factory is nil")
+	}
+	if _, exists := registry[name]; exists {
+		panic("history: RegisterBackend called twice for " + name)
+	}
+	registry[name] = factory
+}
+
+// OpenBackend opens the backend registered under name at path
+func OpenBackend(name, path string) (Backend, error) {
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown state backend %q (available: %v)", name, Backends())
+	}
+	return factory(path)
+}
+
+// Backends lists the registered backends in alphabetical order
+func Backends() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,120 @@
+package history
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func init() {
+	RegisterBackend("bolt", openBolt)
+}
+
+// boltBackend keeps records in a BoltDB file, one bolt bucket per bucket
+type boltBackend struct {
+	db *bolt.DB
+}
+
+func openBolt(path string) (Backend, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if errors.Is(err, bolt.ErrTimeout) {
+		return nil, ErrLocked
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &boltBackend{db: db}, nil
+}
+
+func (b *boltBackend) View(fn func(Tx) error) error {
+	return b.db.View(func(tx *bolt.Tx) error {
+		return fn(boltTx{tx})
+	})
+}
+
+func (b *boltBackend) Update(fn func(Tx) error) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return fn(boltTx{tx})
+	})
+}
+
+func (b *boltBackend) Close() error {
+	return b.db.Close()
+}
+
+type boltTx struct {
+	tx *bolt.Tx
+}
+
+func (t boltTx) Bucket(name string) Bucket {
+	return &boltBucket{tx: t.tx, name: []byte(name)}
+}
+
+// boltBucket looks its bolt bucket up when used, so a bucket that was never
+// written reads as empty instead of failing read-only transactions
+type boltBucket struct {
+	tx   *bolt.Tx
+	name []byte
+}
+
+func (b *boltBucket) bucket(create bool) (*bolt.Bucket, error) {
+	if bucket := b.tx.Bucket(b.name); bucket != nil || !create {
+		return bucket, nil
+	}
+	return b.tx.CreateBucket(b.name)
+}
+
+func (b *boltBucket) Get(key string) ([]byte, error) {
+	bucket, _ := b.bucket(false)
+	if bucket == nil {
+		return nil, nil
+	}
+	// Bolt's slices are only valid during the transaction
+	if value := bucket.Get([]byte(key)); value != nil {
+		return append([]byte(nil), value...), nil
+	}
+	return nil, nil
+}
+
+func (b *boltBucket) Put(key string, value []byte) error {
+	bucket, err := b.bucket(true)
+	if err != nil {
+		return fmt.Errorf("failed to create bucket %s: %w", b.name, err)
+	}
+	return bucket.Put([]byte(key), value)
+}
+
+func (b *boltBucket) Delete(key string) error {
+	bucket, _ := b.bucket(false)
+	if bucket == nil {
+		return nil
+	}
+	return bucket.Delete([]byte(key))
+}
+
+func (b *boltBucket) ForEach(fn func(key string, value []byte) error) error {
+	bucket, _ := b.bucket(false)
+	if bucket == nil {
+		return nil
+	}
+	return bucket.ForEach(func(k, v []byte) error {
+		return fn(string(k), v)
+	})
+}
+
+func (b *boltBucket) Prefix(prefix string, fn func(key string, value []byte) error) error {
+	bucket, _ := b.bucket(false)
+	if bucket == nil {
+		return nil
+	}
+	c := bucket.Cursor()
+	for k, v := c.Seek([]byte(prefix)); k != nil && strings.HasPrefix(string(k), prefix); k, v = c.Next() {
+		if err := fn(string(k), v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package history
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Incomplete returns the entries sent to a torrent client, or to a folder
+// one watches, that it hasn't finished yet, oldest first
+func (s *Store) Incomplete() ([]Entry, error) {
+	var entries []Entry
+	err := s.db.View(func(tx Tx) error {
+		return tx.Bucket(bucketName).ForEach(func(_ string, v []byte) error {
+			var entry Entry
+			if err := s.decode(v, &entry); err != nil {
+				return err
+			}
+			if entry.Client != "" && entry.InfoHash != "" && entry.Status == "" {
+				entries = append(entries, entry)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].DownloadedAt.Before(entries[j].DownloadedAt)
+	})
+	return entries, nil
+}
+
+// Complete marks an entry's torrent completed at the given time, keeping
+// the file only when removing it failed. It returns nil if the entry is
+// gone, e.g. purged in the meantime.
+func (s *Store) Complete(key string, at time.Time, file string) (*Entry, error) {
+	var entry *Entry
+	err := s.db.Update(func(tx Tx) error {
+		b := tx.Bucket(bucketName)
+		data, err := b.Get(key)
+		if data == nil || err != nil {
+			return err
+		}
+		entry = &Entry{}
+		if err := s.decode(data, entry); err != nil {
+			return err
+		}
+		entry.Status = StatusCompleted
+		entry.CompletedAt = at
+		entry.File = file
+
+		if data, err = s.encode(entry); err != nil {
+			return err
+		}
+		return b.Put(key, data)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record completion: %w", err)
+	}
+	return entry, nil
+}
@@ -0,0 +1,155 @@
+package history
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"torrent-rss/internal/models"
+)
+
+const contestsName = "contests"
+
+// Contest collects the releases of one episode a feed saw while it waited
+// for a better one, so the best of them is grabbed instead of the first
+type Contest struct {
+	Key        string      `json:"key"` // Feed name and episode key
+	Feed       string      `json:"feed"`
+	Episode    string      `json:"episode"` // Episode code, e.g. S01E02
+	Opened     time.Time   `json:"opened"`  // When the first release showed up
+	Candidates []Candidate `json:"candidates"`
+	// Winner is the history key of the release picked once the window
+	// closed, empty while it's open
+	Winner string `json:"winner,omitempty"`
+}
+
+// Candidate is one release competing in a contest
+type Candidate struct {
+	Key   string      `json:"key"` // History key of the item
+	Item  models.Item `json:"item"`
+	Score int         `json:"score"`
+	Seen  time.Time   `json:"seen"`
+}
+
+// Candidate returns the candidate with the given history key, or nil
+func (c *Contest) Candidate(key string) *Candidate {
+	for i := range c.Candidates {
+		if c.Candidates[i].Key == key {
+			return &c.Candidates[i]
+		}
+	}
+	return nil
+}
+
+// AddCandidate enters a release into the contest under key, opening the
+// contest if it's the first, and returns the updated contest. A release
+// entered before keeps its place and is only updated.
+func (s *Store) AddCandidate(key, feed, episode string, candidate Candidate) (*Contest, error) {
+	now := time.Now()
+	contest := &Contest{Key: key, Feed: feed, Episode: episode, Opened: now}
+	err := s.db.Update(func(tx Tx) error {
+		b := tx.Bucket(contestsName)
+		data, err := b.Get(key)
+		if err != nil {
+			return err
+		}
+		if data != nil {
+			if err := s.decode(data, contest); err != nil {
+				return err
+			}
+		}
+		if existing := contest.Candidate(candidate.Key); existing != nil {
+			existing.Item, existing.Score = candidate.Item, candidate.Score
+		} else {
+			candidate.Seen = now
+			contest.Candidates = append(contest.Candidates, candidate)
+		}
+
+		if data, err = s.encode(contest); err != nil {
+			return err
+		}
+		return b.Put(key, data)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record candidate: %w", err)
+	}
+	return contest, nil
+}
+
+// Contest returns the contest with the given key, or nil if there's none
+func (s *Store) Contest(key string) (*Contest, error) {
+	var contest *Contest
+	err := s.db.View(func(tx Tx) error {
+		data, err := tx.Bucket(contestsName).Get(key)
+		if data == nil || err != nil {
+			return err
+		}
+		contest = &Contest{}
+		return s.decode(data, contest)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read contest: %w", err)
+	}
+	return contest, nil
+}
+
+// Contests returns the contests of a feed, or of every feed when feed is
+// empty, oldest first
+func (s *Store) Contests(feed string) ([]Contest, error) {
+	var contests []Contest
+	err := s.db.View(func(tx Tx) error {
+		return tx.Bucket(contestsName).ForEach(func(_ string, v []byte) error {
+			var contest Contest
+			if err := s.decode(v, &contest); err != nil {
+				return err
+			}
+			if feed == "" || contest.Feed == feed {
+				contests = append(contests, contest)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read contests: %w", err)
+	}
+
+	sort.Slice(contests, func(i, j int) bool {
+		return contests[i].Opened.Before(contests[j].Opened)
+	})
+	return contests, nil
+}
+
+// SetWinner records the release picked in a contest
+func (s *Store) SetWinner(key, winner string) error {
+	err := s.db.Update(func(tx Tx) error {
+		b := tx.Bucket(contestsName)
+		data, err := b.Get(key)
+		if err != nil {
+			return err
+		}
+		if data == nil {
+			return fmt.Errorf("no contest %s", key)
+		}
+		var contest Contest
+		if err := s.decode(data, &contest); err != nil {
+			return err
+		}
+		contest.Winner = winner
+
+		if data, err = s.encode(contest); err != nil {
+			return err
+		}
+		return b.Put(key, data)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record contest winner: %w", err)
+	}
+	return nil
+}
+
+// RemoveContest forgets a contest, e.g. once its winner was grabbed
+func (s *Store) RemoveContest(key string) error {
+	return s.db.Update(func(tx Tx) error {
+		return tx.Bucket(contestsName).Delete(key)
+	})
+}
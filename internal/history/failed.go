@@ -0,0 +1,185 @@
+package history
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"torrent-rss/internal/models"
+)
+
+const failedName = "failed"
+
+// Failure records an item whose download failed, so it's retried on later
+// polls even once it has dropped out of the feed
+type Failure struct {
+	Key         string      `json:"key"`
+	Feed        string      `json:"feed"`
+	Item        models.Item `json:"item"`
+	Err         string      `json:"error"`
+	Attempts    int         `json:"attempts"`
+	GaveUp      bool        `json:"gave_up,omitempty"` // No point in retrying, whatever the attempts
+	FirstFailed time.Time   `json:"first_failed"`
+	LastFailed  time.Time   `json:"last_failed"`
+}
+
+// AddFailure records another failed attempt at an item and returns the
+// updated record
+func (s *Store) AddFailure(key, feed string, item models.Item, cause error) (*Failure, error) {
+	now := time.Now()
+	failure := &Failure{Key: key, Feed: feed, FirstFailed: now}
+	err := s.db.Update(func(tx Tx) error {
+		b := tx.Bucket(failedName)
+		data, err := b.Get(key)
+		if err != nil {
+			return err
+		}
+		if data != nil {
+			if err := s.decode(data, failure); err != nil {
+				return err
+			}
+		}
+		failure.Feed = feed
+		failure.Item = item
+		failure.Err = cause.Error()
+		failure.Attempts++
+		failure.LastFailed = now
+
+		if data, err = s.encode(failure); err != nil {
+			return err
+		}
+		return b.Put(key, data)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record failure: %w", err)
+	}
+	return failure, nil
+}
+
+// Exhausted reports whether the item is no longer retried on polls
+func (f *Failure) Exhausted(maxAttempts int) bool {
+	return f.GaveUp || f.Attempts >= maxAttempts
+}
+
+// GiveUp stops a failed item from being retried on polls until
+// ResetFailures, e.g. because the torrent was removed
+func (s *Store) GiveUp(key string) error {
+	err := s.db.Update(func(tx Tx) error {
+		b := tx.Bucket(failedName)
+		data, err := b.Get(key)
+		if err != nil {
+			return err
+		}
+		if data == nil {
+			return fmt.Errorf("no failure recorded for %s", key)
+		}
+		var failure Failure
+		if err := s.decode(data, &failure); err != nil {
+			return err
+		}
+		failure.GaveUp = true
+
+		if data, err = s.encode(failure); err != nil {
+			return err
+		}
+		return b.Put(key, data)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record failure: %w", err)
+	}
+	return nil
+}
+
+// Failure returns the failure record for an item key, or nil if it never
+// failed or has since been downloaded
+func (s *Store) Failure(key string) (*Failure, error) {
+	var failure *Failure
+	err := s.db.View(func(tx Tx) error {
+		data, err := tx.Bucket(failedName).Get(key)
+		if data == nil || err != nil {
+			return err
+		}
+		failure = &Failure{}
+		return s.decode(data, failure)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read failure: %w", err)
+	}
+	return failure, nil
+}
+
+// Failures returns the failed items of a feed, or of every feed when feed
+// is empty, most recent first
+func (s *Store) Failures(feed string) ([]Failure, error) {
+	var failures []Failure
+	err := s.db.View(func(tx Tx) error {
+		return tx.Bucket(failedName).ForEach(func(_ string, v []byte) error {
+			var failure Failure
+			if err := s.decode(v, &failure); err != nil {
+				return err
+			}
+			if feed == "" || failure.Feed == feed {
+				failures = append(failures, failure)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read failures: %w", err)
+	}
+
+	sort.Slice(failures, func(i, j int) bool {
+		return failures[i].LastFailed.After(failures[j].LastFailed)
+	})
+	return failures, nil
+}
+
+// ResetFailures sets the attempt count of a feed's failed items back to
+// zero and takes back giving up on them, or of every feed's when feed is empty, so items that were given up
+// on are tried again. It returns how many were reset.
+func (s *Store) ResetFailures(feed string) (int, error) {
+	reset := 0
+	err := s.db.Update(func(tx Tx) error {
+		b := tx.Bucket(failedName)
+		var updated []Failure
+		err := b.ForEach(func(_ string, v []byte) error {
+			var failure Failure
+			if err := s.decode(v, &failure); err != nil {
+				return err
+			}
+			if feed == "" || failure.Feed == feed {
+				failure.Attempts = 0
+				failure.GaveUp = false
+				updated = append(updated, failure)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		// Writing while iterating with ForEach is not allowed
+		for _, failure := range updated {
+			data, err := s.encode(failure)
+			if err != nil {
+				return err
+			}
+			if err := b.Put(failure.Key, data); err != nil {
+				return err
+			}
+		}
+		reset = len(updated)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to reset failures: %w", err)
+	}
+	return reset, nil
+}
+
+// RemoveFailure forgets a failed item, e.g. once it can't be retried
+func (s *Store) RemoveFailure(key string) error {
+	return s.db.Update(func(tx Tx) error {
+		return tx.Bucket(failedName).Delete(key)
+	})
+}
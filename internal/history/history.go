@@ -0,0 +1,441 @@
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"torrent-rss/internal/metadata"
+	"torrent-rss/internal/secrets"
+)
+
+// Bucket names
+const (
+	bucketName     = "history"
+	episodesName   = "episodes"
+	infoHashesName = "infohashes" // Infohash to history key
+	releasesName   = "releases"   // Release key to history key
+)
+
+// Entry records a single downloaded torrent
+type Entry struct {
+	Key          string    `json:"key"`
+	Feed         string    `json:"feed"`
+	Tracker      string    `json:"tracker,omitempty"` // Tracker of the feed, empty in entries from before it was recorded
+	Title        string    `json:"title"`
+	Link         string    `json:"link"`
+	InfoHash     string    `json:"infohash,omitempty"`
+	Release      string    `json:"release,omitempty"` // Normalized release name, see release.Key
+	DownloadedAt time.Time `json:"downloaded_at"`
+	// Media is the show or movie the release is of, when a metadata
+	// provider knows it
+	Media *metadata.Info `json:"media,omitempty"`
+	// Client is the torrent client the torrent was sent to, or that watches
+	// the folder it was written to, asked whether it completed
+	Client string `json:"client,omitempty"`
+	// File is the torrent's file in a watch folder, removed once it completed
+	File        string    `json:"file,omitempty"`
+	Status      string    `json:"status,omitempty"` // StatusCompleted, or empty until then
+	CompletedAt time.Time `json:"completed_at,omitempty"`
+}
+
+// StatusCompleted marks an entry whose torrent the client finished
+// downloading
+const StatusCompleted = "completed"
+
+// EpisodeRecord records that an episode of a show has been grabbed, from
+// whichever release happened to come first
+type EpisodeRecord struct {
+	Key       string    `json:"key"`
+	Show      string    `json:"show"`
+	Episode   string    `json:"episode"` // S01E02, S01 for a season pack, or air date
+	Title     string    `json:"title"`   // Release that was grabbed
+	Quality   string    `json:"quality,omitempty"`
+	InfoHash  string    `json:"infohash,omitempty"`
+	GrabbedAt time.Time `json:"grabbed_at"`
+}
+
+// Store persists download history so items are never grabbed twice
+type Store struct {
+	db  Backend
+	box *secrets.Box // Seals records written from now on, nil writes JSON
+}
+
+// errStop ends a ForEach early
+var errStop = errors.New("stop")
+
+// Open opens or creates the history database at path with the named
+// backend, see Backends. With a box, records are encrypted as they're
+// written; records written without one stay readable either way. Keys, such
+// as item GUIDs, are never encrypted.
+func Open(backend, path string, box *secrets.Box) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	db, err := OpenBackend(backend, path)
+	if errors.Is(err, ErrLocked) {
+		return nil, fmt.Errorf("history database %s is locked by another process (is the daemon running?)", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history database: %w", err)
+	}
+
+	s := &Store{db: db, box: box}
+	err = db.Update(func(tx Tx) error {
+		// Index entries recorded before infohashes were indexed
+		index := tx.Bucket(infoHashesName)
+		indexed := false
+		err := index.ForEach(func(string, []byte) error {
+			indexed = true
+			return errStop
+		})
+		if err != nil && !errors.Is(err, errStop) {
+			return err
+		}
+		if indexed {
+			return nil
+		}
+		return tx.Bucket(bucketName).ForEach(func(k string, v []byte) error {
+			var entry Entry
+			if err := s.decode(v, &entry); err != nil || entry.InfoHash == "" {
+				return err
+			}
+			return index.Put(entry.InfoHash, []byte(k))
+		})
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize history database: %w", err)
+	}
+
+	return s, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// encode marshals a record, encrypted when the store has a box
+func (s *Store) encode(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || s.box == nil {
+		return data, err
+	}
+	sealed, err := s.box.EncryptString(string(data))
+	return []byte(sealed), err
+}
+
+// decode unmarshals a record written by encode, encrypted or not
+func (s *Store) decode(data []byte, v any) error {
+	if secrets.IsEncrypted(string(data)) {
+		plain, err := s.box.DecryptString(string(data))
+		if err != nil {
+			return err
+		}
+		data = []byte(plain)
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Has reports whether an item with this key was already downloaded
+func (s *Store) Has(key string) (bool, error) {
+	var found bool
+	err := s.db.View(func(tx Tx) error {
+		data, err := tx.Bucket(bucketName).Get(key)
+		found = data != nil
+		return err
+	})
+	return found, err
+}
+
+// Add records a downloaded item, which is then no longer a failed or
+// queued one
+func (s *Store) Add(entry Entry) error {
+	if entry.DownloadedAt.IsZero() {
+		entry.DownloadedAt = time.Now()
+	}
+	data, err := s.encode(entry)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx Tx) error {
+		if entry.InfoHash != "" {
+			if err := tx.Bucket(infoHashesName).Put(entry.InfoHash, []byte(entry.Key)); err != nil {
+				return err
+			}
+		}
+		if entry.Release != "" {
+			if err := tx.Bucket(releasesName).Put(entry.Release, []byte(entry.Key)); err != nil {
+				return err
+			}
+		}
+		if err := tx.Bucket(failedName).Delete(entry.Key); err != nil {
+			return err
+		}
+		if err := tx.Bucket(pendingName).Delete(PendingID(entry.Key)); err != nil {
+			return err
+		}
+		if err := tx.Bucket(queueName).Delete(entry.Key); err != nil {
+			return err
+		}
+		return tx.Bucket(bucketName).Put(entry.Key, data)
+	})
+}
+
+// ByInfoHash returns the entry a torrent was recorded under, or nil if it
+// was never downloaded, whichever feed or tracker it came from
+func (s *Store) ByInfoHash(infoHash string) (*Entry, error) {
+	entry, err := s.byIndex(infoHashesName, infoHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	return entry, nil
+}
+
+// ByRelease returns the entry a release was recorded under, or nil if it was
+// never downloaded, whichever feed or tracker it came from
+func (s *Store) ByRelease(key string) (*Entry, error) {
+	entry, err := s.byIndex(releasesName, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	return entry, nil
+}
+
+// byIndex looks an entry up through an index bucket mapping to history keys
+func (s *Store) byIndex(index, key string) (*Entry, error) {
+	var entry *Entry
+	err := s.db.View(func(tx Tx) error {
+		historyKey, err := tx.Bucket(index).Get(key)
+		if historyKey == nil || err != nil {
+			return err
+		}
+		data, err := tx.Bucket(bucketName).Get(string(historyKey))
+		if data == nil || err != nil {
+			return err
+		}
+		entry = &Entry{}
+		return s.decode(data, entry)
+	})
+	return entry, err
+}
+
+// List returns every entry, most recent first
+func (s *Store) List() ([]Entry, error) {
+	var entries []Entry
+	err := s.db.View(func(tx Tx) error {
+		return tx.Bucket(bucketName).ForEach(func(_ string, v []byte) error {
+			var entry Entry
+			if err := s.decode(v, &entry); err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].DownloadedAt.After(entries[j].DownloadedAt)
+	})
+	return entries, nil
+}
+
+// Purge removes entries downloaded, failures last seen, items held for
+// approval, contests opened and items queued before cutoff; a zero cutoff
+// removes everything
+func (s *Store) Purge(cutoff time.Time) (int, error) {
+	removed := 0
+	err := s.db.Update(func(tx Tx) error {
+		b := tx.Bucket(bucketName)
+		index := tx.Bucket(infoHashesName)
+		releases := tx.Bucket(releasesName)
+
+		var stale []Entry
+		err := b.ForEach(func(k string, v []byte) error {
+			var entry Entry
+			if err := s.decode(v, &entry); err != nil {
+				return err
+			}
+			if cutoff.IsZero() || entry.DownloadedAt.Before(cutoff) {
+				entry.Key = k
+				stale = append(stale, entry)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		// Deleting while iterating with ForEach is not allowed
+		for _, entry := range stale {
+			if err := b.Delete(entry.Key); err != nil {
+				return err
+			}
+			// The index may already point at a newer entry for the same torrent
+			if err := deleteIndex(index, entry.InfoHash, entry.Key); err != nil {
+				return err
+			}
+			if err := deleteIndex(releases, entry.Release, entry.Key); err != nil {
+				return err
+			}
+		}
+		removed = len(stale)
+
+		staleFailures, err := s.deleteStale(tx.Bucket(failedName), func(data []byte) (bool, error) {
+			var failure Failure
+			err := s.decode(data, &failure)
+			return cutoff.IsZero() || failure.LastFailed.Before(cutoff), err
+		})
+		if err != nil {
+			return err
+		}
+		removed += staleFailures
+
+		stalePending, err := s.deleteStale(tx.Bucket(pendingName), func(data []byte) (bool, error) {
+			var item Pending
+			err := s.decode(data, &item)
+			return cutoff.IsZero() || item.Added.Before(cutoff), err
+		})
+		if err != nil {
+			return err
+		}
+		removed += stalePending
+
+		staleContests, err := s.deleteStale(tx.Bucket(contestsName), func(data []byte) (bool, error) {
+			var contest Contest
+			err := s.decode(data, &contest)
+			return cutoff.IsZero() || contest.Opened.Before(cutoff), err
+		})
+		if err != nil {
+			return err
+		}
+		removed += staleContests
+
+		staleQueue, err := s.deleteStale(tx.Bucket(queueName), func(data []byte) (bool, error) {
+			var queued Queued
+			err := s.decode(data, &queued)
+			return cutoff.IsZero() || queued.Queued.Before(cutoff), err
+		})
+		if err != nil {
+			return err
+		}
+		removed += staleQueue
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge history: %w", err)
+	}
+	return removed, nil
+}
+
+// deleteIndex removes an index record if it still points at historyKey
+func deleteIndex(index Bucket, key, historyKey string) error {
+	if key == "" {
+		return nil
+	}
+	current, err := index.Get(key)
+	if err != nil || string(current) != historyKey {
+		return err
+	}
+	return index.Delete(key)
+}
+
+// deleteStale deletes the records of b that stale reports true for and
+// returns how many there were
+func (s *Store) deleteStale(b Bucket, stale func(data []byte) (bool, error)) (int, error) {
+	var keys []string
+	err := b.ForEach(func(k string, v []byte) error {
+		ok, err := stale(v)
+		if ok && err == nil {
+			keys = append(keys, k)
+		}
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, key := range keys {
+		if err := b.Delete(key); err != nil {
+			return 0, err
+		}
+	}
+	return len(keys), nil
+}
+
+// Episode returns the record for an episode key, or nil if it was never grabbed
+func (s *Store) Episode(key string) (*EpisodeRecord, error) {
+	var record *EpisodeRecord
+	err := s.db.View(func(tx Tx) error {
+		data, err := tx.Bucket(episodesName).Get(key)
+		if data == nil || err != nil {
+			return err
+		}
+		record = &EpisodeRecord{}
+		return s.decode(data, record)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read episode: %w", err)
+	}
+	return record, nil
+}
+
+// PutEpisode records a grabbed episode, replacing any previous record
+func (s *Store) PutEpisode(record EpisodeRecord) error {
+	if record.GrabbedAt.IsZero() {
+		record.GrabbedAt = time.Now()
+	}
+	data, err := s.encode(record)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx Tx) error {
+		return tx.Bucket(episodesName).Put(record.Key, data)
+	})
+}
+
+// SeasonEpisodes returns the grabbed episodes whose keys start with the
+// season key followed by "E", i.e. the single episodes of that season
+func (s *Store) SeasonEpisodes(seasonKey string) ([]EpisodeRecord, error) {
+	var records []EpisodeRecord
+	err := s.db.View(func(tx Tx) error {
+		return tx.Bucket(episodesName).Prefix(seasonKey+"E", func(_ string, v []byte) error {
+			var record EpisodeRecord
+			if err := s.decode(v, &record); err != nil {
+				return err
+			}
+			records = append(records, record)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read episodes: %w", err)
+	}
+	return records, nil
+}
+
+// Episodes returns every grabbed episode ordered by key, i.e. by show and episode
+func (s *Store) Episodes() ([]EpisodeRecord, error) {
+	var records []EpisodeRecord
+	err := s.db.View(func(tx Tx) error {
+		return tx.Bucket(episodesName).ForEach(func(_ string, v []byte) error {
+			var record EpisodeRecord
+			if err := s.decode(v, &record); err != nil {
+				return err
+			}
+			records = append(records, record)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read episodes: %w", err)
+	}
+	return records, nil
+}
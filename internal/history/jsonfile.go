@@ -0,0 +1,254 @@
+package history
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"torrent-rss/internal/instance"
+)
+
+func init() {
+	RegisterBackend("json", openJSONFile)
+}
+
+// jsonFile keeps every record in memory and rewrites the whole file after
+// each update, which suits the small histories of routers and NAS boxes
+// without needing CGO or mmap. Only one process may use the file at a time,
+// which a lock file next to it makes sure of.
+type jsonFile struct {
+	path string
+	lock *os.File
+
+	mu      sync.RWMutex
+	buckets map[string]map[string][]byte
+}
+
+func openJSONFile(path string) (Backend, error) {
+	// The file itself is replaced on every write, so the lock is held on a
+	// file next to it that stays put
+	lock, err := instance.LockFile(path + ".lock")
+	if errors.Is(err, instance.ErrLocked) {
+		return nil, ErrLocked
+	}
+	if err != nil {
+		return nil, err
+	}
+	f := &jsonFile{path: path, lock: lock, buckets: make(map[string]map[string][]byte)}
+	if err := f.load(); err != nil {
+		lock.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// load reads the records of the file, if there is one yet
+func (f *jsonFile) load() error {
+	path := f.path
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var raw map[string]map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("%s is not a history file: %w", path, err)
+	}
+	for name, records := range raw {
+		bucket := make(map[string][]byte, len(records))
+		for key, value := range records {
+			// Sealed records are stored as strings, plain ones as they are
+			if bytes.HasPrefix(value, []byte(`"`)) {
+				var sealed string
+				if err := json.Unmarshal(value, &sealed); err != nil {
+					return fmt.Errorf("%s: record %s: %w", path, key, err)
+				}
+				bucket[key] = []byte(sealed)
+			} else {
+				bucket[key] = value
+			}
+		}
+		f.buckets[name] = bucket
+	}
+	return nil
+}
+
+func (f *jsonFile) View(fn func(Tx) error) error {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return fn(&jsonTx{file: f})
+}
+
+// Update works on copies of the buckets it touches, which replace the
+// originals only once fn succeeded and the file was written
+func (f *jsonFile) Update(fn func(Tx) error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	tx := &jsonTx{file: f, writable: true, changed: make(map[string]map[string][]byte)}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if len(tx.changed) == 0 {
+		return nil
+	}
+
+	buckets := make(map[string]map[string][]byte, len(f.buckets))
+	for name, bucket := range f.buckets {
+		buckets[name] = bucket
+	}
+	for name, bucket := range tx.changed {
+		buckets[name] = bucket
+	}
+	if err := f.write(buckets); err != nil {
+		return err
+	}
+	f.buckets = buckets
+	return nil
+}
+
+// Close releases the lock. The lock file is left in place, like the state
+// directory's.
+func (f *jsonFile) Close() error {
+	return f.lock.Close()
+}
+
+// write replaces the file atomically, so a crash never leaves half of it
+func (f *jsonFile) write(buckets map[string]map[string][]byte) error {
+	raw := make(map[string]map[string]json.RawMessage, len(buckets))
+	for name, bucket := range buckets {
+		records := make(map[string]json.RawMessage, len(bucket))
+		for key, value := range bucket {
+			if json.Valid(value) && bytes.HasPrefix(value, []byte("{")) {
+				records[key] = value
+				continue
+			}
+			quoted, err := json.Marshal(string(value))
+			if err != nil {
+				return err
+			}
+			records[key] = quoted
+		}
+		raw[name] = records
+	}
+	data, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
+
+type jsonTx struct {
+	file     *jsonFile
+	writable bool
+	changed  map[string]map[string][]byte // Copies of the buckets written to
+}
+
+func (t *jsonTx) Bucket(name string) Bucket {
+	return &jsonBucket{tx: t, name: name}
+}
+
+type jsonBucket struct {
+	tx   *jsonTx
+	name string
+}
+
+// records returns the bucket's records as the transaction sees them
+func (b *jsonBucket) records() map[string][]byte {
+	if changed, ok := b.tx.changed[b.name]; ok {
+		return changed
+	}
+	return b.tx.file.buckets[b.name]
+}
+
+// writable returns the transaction's own copy of the bucket
+func (b *jsonBucket) writable() (map[string][]byte, error) {
+	if !b.tx.writable {
+		return nil, errors.New("write in a read-only transaction")
+	}
+	if changed, ok := b.tx.changed[b.name]; ok {
+		return changed, nil
+	}
+	current := b.tx.file.buckets[b.name]
+	copied := make(map[string][]byte, len(current)+1)
+	for key, value := range current {
+		copied[key] = value
+	}
+	b.tx.changed[b.name] = copied
+	return copied, nil
+}
+
+func (b *jsonBucket) Get(key string) ([]byte, error) {
+	return b.records()[key], nil
+}
+
+func (b *jsonBucket) Put(key string, value []byte) error {
+	records, err := b.writable()
+	if err != nil {
+		return err
+	}
+	records[key] = append([]byte(nil), value...)
+	return nil
+}
+
+func (b *jsonBucket) Delete(key string) error {
+	if _, ok := b.records()[key]; !ok {
+		return nil
+	}
+	records, err := b.writable()
+	if err != nil {
+		return err
+	}
+	delete(records, key)
+	return nil
+}
+
+func (b *jsonBucket) ForEach(fn func(key string, value []byte) error) error {
+	return b.Prefix("", fn)
+}
+
+func (b *jsonBucket) Prefix(prefix string, fn func(key string, value []byte) error) error {
+	records := b.records()
+	keys := make([]string, 0, len(records))
+	for key := range records {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := fn(key, records[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
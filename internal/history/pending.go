@@ -0,0 +1,141 @@
+package history
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"torrent-rss/internal/models"
+)
+
+const pendingName = "pending"
+
+// Approval states of a pending item
+const (
+	PendingWaiting  = "waiting"
+	PendingApproved = "approved"
+	PendingRejected = "rejected"
+)
+
+// Pending is a matched item of a feed in approval mode, held until someone
+// approves or rejects it. Rejected items are kept so they aren't asked
+// about again.
+type Pending struct {
+	ID      string      `json:"id"` // Short handle for approving by hand
+	Key     string      `json:"key"`
+	Feed    string      `json:"feed"`
+	Item    models.Item `json:"item"`
+	Status  string      `json:"status"`
+	Added   time.Time   `json:"added"`
+	Decided time.Time   `json:"decided"` // Zero while waiting
+}
+
+// PendingID derives the ID of a pending item from its history key
+func PendingID(key string) string {
+	sum := sha1.Sum([]byte(key))
+	return hex.EncodeToString(sum[:6])
+}
+
+// AddPending holds an item for approval. If it's held already, the existing
+// record is returned and added is false.
+func (s *Store) AddPending(key, feed string, item models.Item) (pending *Pending, added bool, err error) {
+	id := PendingID(key)
+	err = s.db.Update(func(tx Tx) error {
+		b := tx.Bucket(pendingName)
+		data, err := b.Get(id)
+		if err != nil {
+			return err
+		}
+		if data != nil {
+			pending = &Pending{}
+			return s.decode(data, pending)
+		}
+
+		pending = &Pending{ID: id, Key: key, Feed: feed, Item: item, Status: PendingWaiting, Added: time.Now()}
+		added = true
+		if data, err = s.encode(pending); err != nil {
+			return err
+		}
+		return b.Put(id, data)
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to record pending item: %w", err)
+	}
+	return pending, added, nil
+}
+
+// Pending returns the pending item with the given ID, or nil if there's none
+func (s *Store) Pending(id string) (*Pending, error) {
+	var pending *Pending
+	err := s.db.View(func(tx Tx) error {
+		data, err := tx.Bucket(pendingName).Get(id)
+		if data == nil || err != nil {
+			return err
+		}
+		pending = &Pending{}
+		return s.decode(data, pending)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pending item: %w", err)
+	}
+	return pending, nil
+}
+
+// PendingItems returns the pending items of a feed in the given status,
+// where empty matches any feed or status, newest first
+func (s *Store) PendingItems(feed, status string) ([]Pending, error) {
+	var items []Pending
+	err := s.db.View(func(tx Tx) error {
+		return tx.Bucket(pendingName).ForEach(func(_ string, v []byte) error {
+			var pending Pending
+			if err := s.decode(v, &pending); err != nil {
+				return err
+			}
+			if (feed == "" || pending.Feed == feed) && (status == "" || pending.Status == status) {
+				items = append(items, pending)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pending items: %w", err)
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Added.After(items[j].Added)
+	})
+	return items, nil
+}
+
+// DecidePending approves or rejects a pending item and returns the updated
+// record, or nil if there's no item with that ID
+func (s *Store) DecidePending(id, status string) (*Pending, error) {
+	if status != PendingApproved && status != PendingRejected {
+		return nil, fmt.Errorf("invalid approval status %q", status)
+	}
+	var pending *Pending
+	err := s.db.Update(func(tx Tx) error {
+		b := tx.Bucket(pendingName)
+		data, err := b.Get(id)
+		if data == nil || err != nil {
+			return err
+		}
+		pending = &Pending{}
+		if err := s.decode(data, pending); err != nil {
+			return err
+		}
+		pending.Status = status
+		pending.Decided = time.Now()
+
+		if data, err = s.encode(pending); err != nil {
+			return err
+		}
+		return b.Put(id, data)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record decision: %w", err)
+	}
+	return pending, nil
+}
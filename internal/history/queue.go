@@ -0,0 +1,155 @@
+package history
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"torrent-rss/internal/models"
+)
+
+const queueName = "queue"
+
+// Stages of a queued item
+const (
+	// QueueMatched items were picked by a poll that hasn't got to them yet
+	QueueMatched = "matched"
+	// QueueDelivered items were handed to their destination, but the poll
+	// stopped before recording them in the history
+	QueueDelivered = "delivered"
+)
+
+// Queued is an item a poll picked, kept until the poll is done with it so a
+// poll cut short by a crash or shutdown is resumed where it left off, even
+// once the items have dropped out of the feed
+type Queued struct {
+	Key      string      `json:"key"`
+	Feed     string      `json:"feed"`
+	Item     models.Item `json:"item"`
+	Stage    string      `json:"stage"`
+	Position int         `json:"position"` // Order within the poll
+	InfoHash string      `json:"infohash,omitempty"`
+	Size     int64       `json:"size,omitempty"`
+	Client   string      `json:"client,omitempty"` // See Entry.Client
+	File     string      `json:"file,omitempty"`   // See Entry.File
+	Queued   time.Time   `json:"queued"`
+}
+
+// Enqueue queues the items of a poll of feed, in order, under their history
+// keys. Items queued already keep their record, so a delivered one isn't
+// delivered again, and downloaded ones aren't queued.
+func (s *Store) Enqueue(feed string, keys []string, items []models.Item) error {
+	now := time.Now()
+	err := s.db.Update(func(tx Tx) error {
+		b := tx.Bucket(queueName)
+		downloaded := tx.Bucket(bucketName)
+		for i, key := range keys {
+			existing, err := b.Get(key)
+			if err != nil {
+				return err
+			}
+			entry, err := downloaded.Get(key)
+			if err != nil {
+				return err
+			}
+			if existing != nil || entry != nil {
+				continue
+			}
+
+			queued := Queued{Key: key, Feed: feed, Item: items[i], Stage: QueueMatched, Position: i, Queued: now}
+			data, err := s.encode(queued)
+			if err != nil {
+				return err
+			}
+			if err := b.Put(key, data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to queue items: %w", err)
+	}
+	return nil
+}
+
+// MarkDelivered records that a queued item reached its destination, so a
+// poll resuming it only records it in the history, with the client and
+// file of its entry
+func (s *Store) MarkDelivered(key, infoHash string, size int64, client, file string) error {
+	err := s.db.Update(func(tx Tx) error {
+		b := tx.Bucket(queueName)
+		data, err := b.Get(key)
+		if data == nil || err != nil {
+			return err
+		}
+		var queued Queued
+		if err := s.decode(data, &queued); err != nil {
+			return err
+		}
+		queued.Stage = QueueDelivered
+		queued.InfoHash = infoHash
+		queued.Size = size
+		queued.Client = client
+		queued.File = file
+
+		if data, err = s.encode(queued); err != nil {
+			return err
+		}
+		return b.Put(key, data)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record delivery: %w", err)
+	}
+	return nil
+}
+
+// Queue returns the queued items of a feed, or of every feed when feed is
+// empty, in the order they were queued
+func (s *Store) Queue(feed string) ([]Queued, error) {
+	var queue []Queued
+	err := s.db.View(func(tx Tx) error {
+		return tx.Bucket(queueName).ForEach(func(_ string, v []byte) error {
+			var queued Queued
+			if err := s.decode(v, &queued); err != nil {
+				return err
+			}
+			if feed == "" || queued.Feed == feed {
+				queue = append(queue, queued)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read queue: %w", err)
+	}
+
+	sort.Slice(queue, func(i, j int) bool {
+		if !queue[i].Queued.Equal(queue[j].Queued) {
+			return queue[i].Queued.Before(queue[j].Queued)
+		}
+		return queue[i].Position < queue[j].Position
+	})
+	return queue, nil
+}
+
+// Dequeue forgets queued items once the poll is done with them, whether
+// they were downloaded, filtered or failed
+func (s *Store) Dequeue(keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	err := s.db.Update(func(tx Tx) error {
+		b := tx.Bucket(queueName)
+		for _, key := range keys {
+			if err := b.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to dequeue items: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+//go:build sqlite
+
+package history
+
+import (
+	"context"
+	"database/sql"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func init() {
+	RegisterBackend("sqlite", openSQLite)
+}
+
+// sqliteBackend keeps records in one SQLite table, so the history can be
+// queried with SQL, e.g. json_extract(value, '$.title') of bucket 'history'.
+// It needs CGO, so it's only built with the sqlite build tag.
+type sqliteBackend struct {
+	db *sql.DB
+}
+
+const sqliteSchema = `CREATE TABLE IF NOT EXISTS records (
+	bucket TEXT NOT NULL,
+	key    TEXT NOT NULL,
+	value  BLOB NOT NULL,
+	PRIMARY KEY (bucket, key)
+) WITHOUT ROWID`
+
+func openSQLite(path string) (Backend, error) {
+	// Writers wait for each other instead of failing right away
+	db, err := sql.Open("sqlite3", "file:"+path+"?_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &sqliteBackend{db: db}, nil
+}
+
+func (b *sqliteBackend) View(fn func(Tx) error) error {
+	return b.run(&sql.TxOptions{ReadOnly: true}, fn)
+}
+
+func (b *sqliteBackend) Update(fn func(Tx) error) error {
+	return b.run(nil, fn)
+}
+
+func (b *sqliteBackend) run(opts *sql.TxOptions, fn func(Tx) error) error {
+	tx, err := b.db.BeginTx(context.Background(), opts)
+	if err != nil {
+		return err
+	}
+	if err := fn(sqliteTx{tx}); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (b *sqliteBackend) Close() error {
+	return b.db.Close()
+}
+
+type sqliteTx struct {
+	tx *sql.Tx
+}
+
+func (t sqliteTx) Bucket(name string) Bucket {
+	return sqliteBucket{tx: t.tx, name: name}
+}
+
+type sqliteBucket struct {
+	tx   *sql.Tx
+	name string
+}
+
+func (b sqliteBucket) Get(key string) ([]byte, error) {
+	var value []byte
+	err := b.tx.QueryRow(`SELECT value FROM records WHERE bucket = ? AND key = ?`, b.name, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return value, err
+}
+
+func (b sqliteBucket) Put(key string, value []byte) error {
+	_, err := b.tx.Exec(`INSERT INTO records (bucket, key, value) VALUES (?, ?, ?)
+		ON CONFLICT (bucket, key) DO UPDATE SET value = excluded.value`, b.name, key, value)
+	return err
+}
+
+func (b sqliteBucket) Delete(key string) error {
+	_, err := b.tx.Exec(`DELETE FROM records WHERE bucket = ? AND key = ?`, b.name, key)
+	return err
+}
+
+func (b sqliteBucket) ForEach(fn func(key string, value []byte) error) error {
+	return b.Prefix("", fn)
+}
+
+// Prefix reads the matching records before calling fn, since a transaction
+// can't run other statements while rows are open
+func (b sqliteBucket) Prefix(prefix string, fn func(key string, value []byte) error) error {
+	// Comparing as blobs counts bytes rather than characters
+	rows, err := b.tx.Query(`SELECT key, value FROM records
+		WHERE bucket = ? AND substr(CAST(key AS BLOB), 1, ?) = CAST(? AS BLOB) ORDER BY key`,
+		b.name, len(prefix), prefix)
+	if err != nil {
+		return err
+	}
+	type record struct {
+		key   string
+		value []byte
+	}
+	var records []record
+	for rows.Next() {
+		var r record
+		if err := rows.Scan(&r.key, &r.value); err != nil {
+			rows.Close()
+			return err
+		}
+		records = append(records, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, r := range records {
+		if err := fn(r.key, r.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package history
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const statsName = "stats"
+
+// FeedStats are running totals of what a feed's polls did
+type FeedStats struct {
+	Feed       string    `json:"feed"`
+	Seen       int64     `json:"seen"`       // Items polls returned, after search terms
+	Matched    int64     `json:"matched"`    // Items that passed every check and were fetched
+	Downloaded int64     `json:"downloaded"` // Including upgrades
+	Filtered   int64     `json:"filtered"`
+	Failed     int64     `json:"failed"`
+	LastPoll   time.Time `json:"last_poll"` // Last poll that succeeded, zero if none did
+	LastItem   time.Time `json:"last_item"` // Last poll that returned items
+	// StaleAlert is when the feed was last reported stale or failing, so
+	// it's reported once until it recovers
+	StaleAlert time.Time `json:"stale_alert,omitempty"`
+	// LastSearch is when the tracker was last searched for the watch-list
+	LastSearch time.Time `json:"last_search,omitempty"`
+	// Torrents fetched and the total time it took, for the average
+	Resolved    int64         `json:"resolved"`
+	ResolveTime time.Duration `json:"resolve_time"`
+}
+
+// AvgResolve is how long fetching a torrent took on average, 0 if none was
+func (f FeedStats) AvgResolve() time.Duration {
+	if f.Resolved == 0 {
+		return 0
+	}
+	return f.ResolveTime / time.Duration(f.Resolved)
+}
+
+// add sums the counters of delta into f, keeping the later times
+func (f *FeedStats) add(delta FeedStats) {
+	f.Seen += delta.Seen
+	f.Matched += delta.Matched
+	f.Downloaded += delta.Downloaded
+	f.Filtered += delta.Filtered
+	f.Failed += delta.Failed
+	f.Resolved += delta.Resolved
+	f.ResolveTime += delta.ResolveTime
+	if delta.LastPoll.After(f.LastPoll) {
+		f.LastPoll = delta.LastPoll
+	}
+	if delta.LastItem.After(f.LastItem) {
+		f.LastItem = delta.LastItem
+	}
+	if delta.StaleAlert.After(f.StaleAlert) {
+		f.StaleAlert = delta.StaleAlert
+	}
+	if delta.LastSearch.After(f.LastSearch) {
+		f.LastSearch = delta.LastSearch
+	}
+}
+
+// AddStats adds the counters of each delta to its feed's totals
+func (s *Store) AddStats(deltas ...FeedStats) error {
+	err := s.db.Update(func(tx Tx) error {
+		b := tx.Bucket(statsName)
+		for _, delta := range deltas {
+			stats := FeedStats{Feed: delta.Feed}
+			data, err := b.Get(delta.Feed)
+			if err != nil {
+				return err
+			}
+			if data != nil {
+				if err := s.decode(data, &stats); err != nil {
+					return err
+				}
+			}
+			stats.add(delta)
+
+			if data, err = s.encode(stats); err != nil {
+				return err
+			}
+			if err := b.Put(delta.Feed, data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record stats: %w", err)
+	}
+	return nil
+}
+
+// FeedStats returns the totals of a feed, zero if it has none
+func (s *Store) FeedStats(feed string) (FeedStats, error) {
+	stats := FeedStats{Feed: feed}
+	err := s.db.View(func(tx Tx) error {
+		data, err := tx.Bucket(statsName).Get(feed)
+		if data == nil || err != nil {
+			return err
+		}
+		return s.decode(data, &stats)
+	})
+	if err != nil {
+		return FeedStats{}, fmt.Errorf("failed to read stats: %w", err)
+	}
+	return stats, nil
+}
+
+// Stats returns the totals of every feed that has any, by feed name
+func (s *Store) Stats() ([]FeedStats, error) {
+	var all []FeedStats
+	err := s.db.View(func(tx Tx) error {
+		return tx.Bucket(statsName).ForEach(func(_ string, v []byte) error {
+			var stats FeedStats
+			if err := s.decode(v, &stats); err != nil {
+				return err
+			}
+			all = append(all, stats)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stats: %w", err)
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Feed < all[j].Feed
+	})
+	return all, nil
+}
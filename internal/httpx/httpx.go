@@ -0,0 +1,105 @@
+// Package httpx builds the HTTP clients torrent-rss talks to trackers,
+// indexers and metadata services with: a base transport wrapped in a chain
+// of middleware, each adding one concern like retries, rate limits or
+// logging around every request
+package httpx
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"torrent-rss/internal/connpool"
+	"torrent-rss/internal/dnscache"
+)
+
+// Middleware wraps a transport, handling each request on its way to next
+// and the response on its way back
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// Chain wraps next in middleware, the first listed outermost, so it sees
+// each request first and its response last. Nil middleware are skipped.
+func Chain(next http.RoundTripper, middleware ...Middleware) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	for i := len(middleware) - 1; i >= 0; i-- {
+		if middleware[i] != nil {
+			next = middleware[i](next)
+		}
+	}
+	return next
+}
+
+// Options configures a Client
+type Options struct {
+	// Timeout bounds each request including reading the body, zero means
+	// no timeout
+	Timeout time.Duration
+	// Jar keeps cookies, nil doesn't
+	Jar http.CookieJar
+	// CheckRedirect decides on redirects, nil follows up to 10
+	CheckRedirect func(req *http.Request, via []*http.Request) error
+}
+
+// Client is an http.Client whose transport is a middleware chain, which
+// more middleware can be added to once it's built
+type Client struct {
+	*http.Client
+}
+
+// New builds a client sending requests through middleware, the first listed
+// outermost, to base. Nil base uses http.DefaultTransport.
+func New(base http.RoundTripper, opts Options, middleware ...Middleware) *Client {
+	return &Client{Client: &http.Client{
+		Transport:     Chain(base, middleware...),
+		Timeout:       opts.Timeout,
+		Jar:           opts.Jar,
+		CheckRedirect: opts.CheckRedirect,
+	}}
+}
+
+// Use wraps the client's chain in more middleware, which see requests
+// before the rest. It must be called before the client is used.
+func (c *Client) Use(middleware ...Middleware) {
+	c.Transport = Chain(c.Transport, middleware...)
+}
+
+// TransportOptions configures the base transport of a client
+type TransportOptions struct {
+	// DNS caches host lookups, nil looks up every new connection
+	DNS *dnscache.Resolver
+	// ConnectTimeout bounds dialing and the TLS handshake, ReadTimeout the
+	// wait for response headers. Zero means no timeout.
+	ConnectTimeout time.Duration
+	ReadTimeout    time.Duration
+	// Proxy routes requests through a proxy, nil falls back to HTTP_PROXY
+	// and HTTPS_PROXY
+	Proxy *url.URL
+	// TLS replaces the default TLS settings, nil uses the system roots
+	TLS *tls.Config
+	// Connections tunes the connection pool and HTTP/2
+	Connections connpool.Options
+}
+
+// NewTransport builds a base transport, a copy of http.DefaultTransport
+// with the options applied
+func NewTransport(opts TransportOptions) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = opts.DNS.DialContext(&net.Dialer{
+		Timeout:   opts.ConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	})
+	opts.Connections.Apply(transport)
+	transport.TLSHandshakeTimeout = opts.ConnectTimeout
+	transport.ResponseHeaderTimeout = opts.ReadTimeout
+	if opts.Proxy != nil {
+		transport.Proxy = http.ProxyURL(opts.Proxy)
+	}
+	if opts.TLS != nil {
+		transport.TLSClientConfig = opts.TLS.Clone()
+	}
+	return transport
+}
@@ -0,0 +1,121 @@
+package httpx
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"torrent-rss/internal/retry"
+)
+
+// tracing is middleware noting when a request passes it and when its
+// response comes back
+func tracing(name string, trace *[]string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			*trace = append(*trace, name+" request")
+			resp, err := next.RoundTrip(req)
+			*trace = append(*trace, name+" response")
+			return resp, err
+		})
+	}
+}
+
+// replies answers each request with the next status, 0 failing it without
+// a response, and counts the requests
+func replies(statuses []int, sent *int) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		status := statuses[min(*sent, len(statuses)-1)]
+		*sent++
+		if status == 0 {
+			return nil, errors.New("connection reset")
+		}
+		return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+	})
+}
+
+func TestChainOrder(t *testing.T) {
+	tests := []struct {
+		name       string
+		middleware []string // Empty names are nil middleware
+		want       string
+	}{
+		{"none", nil, ""},
+		{"one", []string{"a"}, "a request, a response"},
+		{"first outermost", []string{"a", "b", "c"}, "a request, b request, c request, c response, b response, a response"},
+		{"nil skipped", []string{"a", "", "b"}, "a request, b request, b response, a response"},
+	}
+	for _, tt := range tests {
+		var trace []string
+		var middleware []Middleware
+		for _, name := range tt.middleware {
+			if name == "" {
+				middleware = append(middleware, nil)
+				continue
+			}
+			middleware = append(middleware, tracing(name, &trace))
+		}
+		var sent int
+		rt := Chain(replies([]int{200}, &sent), middleware...)
+		req, _ := http.NewRequest("GET", "https://tracker.example/t.rss", nil)
+		if _, err := rt.RoundTrip(req); err != nil {
+			t.Fatalf("%s: RoundTrip: %v", tt.name, err)
+		}
+		if got := strings.Join(trace, ", "); got != tt.want || sent != 1 {
+			t.Errorf("%s: trace %q with %d requests sent, want %q with 1", tt.name, got, sent, tt.want)
+		}
+	}
+}
+
+func TestRetryCountsEveryAttempt(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		attempts     int
+		wantStatus   int // 0 for an error
+		wantSent     int
+		wantFailures int64
+	}{
+		{"success", []int{200}, 3, 200, 1, 0},
+		{"retried until it works", []int{503, 0, 200}, 3, 200, 3, 2},
+		{"gives up", []int{503}, 3, 503, 3, 3},
+		{"error after the last attempt", []int{0}, 2, 0, 2, 2},
+		{"not retried", []int{404}, 3, 404, 1, 0},
+		{"no retries", []int{503}, 1, 503, 1, 1},
+	}
+	for _, tt := range tests {
+		var sent int
+		metrics := NewMetrics()
+		policy := retry.Policy{Attempts: tt.attempts, Backoff: time.Millisecond, MaxBackoff: time.Millisecond}
+		rt := Chain(replies(tt.statuses, &sent), Retry(policy), metrics.Middleware())
+		req, _ := http.NewRequest("GET", "https://Tracker.example/t.rss", nil)
+		resp, err := rt.RoundTrip(req)
+
+		status := 0
+		if err == nil {
+			status = resp.StatusCode
+		}
+		if status != tt.wantStatus || sent != tt.wantSent {
+			t.Errorf("%s: status %d after %d requests (err %v), want %d after %d", tt.name, status, sent, err, tt.wantStatus, tt.wantSent)
+		}
+		// Metrics sit inside the retries, so each attempt counts
+		hosts := metrics.Hosts()
+		want := []HostMetrics{{Host: "tracker.example", Requests: int64(tt.wantSent), Failures: tt.wantFailures}}
+		if !slices.EqualFunc(hosts, want, func(a, b HostMetrics) bool {
+			return a.Host == b.Host && a.Requests == b.Requests && a.Failures == b.Failures
+		}) {
+			t.Errorf("%s: metrics %+v, want %+v", tt.name, hosts, want)
+		}
+	}
+}
+
+func TestNilMetricsSkipped(t *testing.T) {
+	var metrics *Metrics
+	if metrics.Middleware() != nil || metrics.Hosts() != nil {
+		t.Error("nil metrics counted")
+	}
+}
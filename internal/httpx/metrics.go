@@ -0,0 +1,80 @@
+package httpx
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metrics counts the requests of the clients sharing it, by host
+type Metrics struct {
+	mu    sync.Mutex
+	hosts map[string]*HostMetrics
+}
+
+// HostMetrics are the counts of requests to one host
+type HostMetrics struct {
+	Host     string
+	Requests int64
+	// Failures are requests that got no response, or a 5xx one
+	Failures int64
+	// Time is how long the requests took until the response headers came
+	// in, in total
+	Time time.Duration
+	Last time.Time
+}
+
+// NewMetrics starts counting from nothing. Add its Middleware to every
+// client whose requests should count.
+func NewMetrics() *Metrics {
+	return &Metrics{hosts: make(map[string]*HostMetrics)}
+}
+
+// Middleware counts each request, nil metrics return nil, which Chain skips
+func (m *Metrics) Middleware() Middleware {
+	if m == nil {
+		return nil
+	}
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			m.record(req.URL.Hostname(), start, err != nil || resp.StatusCode >= 500)
+			return resp, err
+		})
+	}
+}
+
+func (m *Metrics) record(host string, start time.Time, failed bool) {
+	host = strings.ToLower(host)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h, ok := m.hosts[host]
+	if !ok {
+		h = &HostMetrics{Host: host}
+		m.hosts[host] = h
+	}
+	h.Requests++
+	if failed {
+		h.Failures++
+	}
+	h.Time += time.Since(start)
+	h.Last = start
+}
+
+// Hosts returns the counts of every host requests went to, by host name
+func (m *Metrics) Hosts() []HostMetrics {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	hosts := make([]HostMetrics, 0, len(m.hosts))
+	for _, h := range m.hosts {
+		hosts = append(hosts, *h)
+	}
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].Host < hosts[j].Host })
+	return hosts
+}
@@ -0,0 +1,148 @@
+package httpx
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"torrent-rss/internal/challenge"
+	"torrent-rss/internal/content"
+	"torrent-rss/internal/headers"
+	"torrent-rss/internal/maintenance"
+	"torrent-rss/internal/ratelimit"
+	"torrent-rss/internal/retry"
+)
+
+// StackOptions picks the middleware of Stack
+type StackOptions struct {
+	// Retry is applied to every request
+	Retry retry.Policy
+	// Challenges detects, and with a solver gets past, anti-bot challenge
+	// pages. Nil only detects.
+	Challenges *challenge.Handler
+	// Limiter spaces out requests per host, nil doesn't limit
+	Limiter *ratelimit.Limiter
+	// Headers sets the browser headers, Headers or HostHeaders. Nil sends
+	// Go's own.
+	Headers Middleware
+	// Logf logs every request, nil stays quiet
+	Logf func(format string, args ...any)
+	// Metrics counts every request, nil doesn't
+	Metrics *Metrics
+}
+
+// Stack is the middleware of requests to trackers and indexers, outermost
+// first: retries, then turning maintenance pages into errors, getting past
+// challenges, rate limits, browser headers, logging and metrics, and last
+// decoding whatever compression the response came in
+func Stack(opts StackOptions) []Middleware {
+	return []Middleware{
+		Retry(opts.Retry),
+		Maintenance(),
+		Challenges(opts.Challenges),
+		RateLimit(opts.Limiter),
+		opts.Headers,
+		Logging(opts.Logf),
+		opts.Metrics.Middleware(),
+		Content(),
+	}
+}
+
+// Retry retries failed requests according to policy, see retry.Transport
+func Retry(policy retry.Policy) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return retry.Transport(next, policy)
+	}
+}
+
+// Maintenance fails maintenance and rate limit pages with a
+// *maintenance.Error, see maintenance.Transport
+func Maintenance() Middleware {
+	return maintenance.Transport
+}
+
+// Challenges detects anti-bot challenge pages and has h solve them, see
+// challenge.Transport
+func Challenges(h *challenge.Handler) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return challenge.Transport(next, h)
+	}
+}
+
+// RateLimit spaces out requests per host, see ratelimit.Transport
+func RateLimit(l *ratelimit.Limiter) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return ratelimit.Transport(next, l)
+	}
+}
+
+// Headers sends the headers of a browser profile, see headers.Transport
+func Headers(p headers.Profile) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return headers.Transport(next, p)
+	}
+}
+
+// HostHeaders sends the headers of each host's profile, and fallback's to
+// other hosts, see headers.HostTransport
+func HostHeaders(byHost map[string]headers.Profile, fallback headers.Profile) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return headers.HostTransport(next, byHost, fallback)
+	}
+}
+
+// Content asks for compressed responses and decodes them, see
+// content.Transport
+func Content() Middleware {
+	return content.Transport
+}
+
+// Auth adds credentials to every request with authorize, which gets a copy
+// of the request to change. Only use it for clients talking to a single
+// service, as it also authorizes redirects to other hosts.
+func Auth(authorize func(req *http.Request)) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			// RoundTrippers must not modify the caller's request
+			req = req.Clone(req.Context())
+			authorize(req)
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// Logging logs each request with its outcome and how long it took. URLs
+// are logged without their query, where trackers put passkeys and tokens.
+// Nil logf returns nil, which Chain skips.
+func Logging(logf func(format string, args ...any)) Middleware {
+	if logf == nil {
+		return nil
+	}
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			took := time.Since(start).Round(time.Millisecond)
+			if err != nil {
+				logf("%s %s: %v after %s", req.Method, logURL(req.URL), err, took)
+				return nil, err
+			}
+			logf("%s %s: %s in %s", req.Method, logURL(req.URL), resp.Status, took)
+			return resp, nil
+		})
+	}
+}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// logURL leaves out the query and user info
+func logURL(u *url.URL) string {
+	stripped := *u
+	stripped.RawQuery = ""
+	stripped.User = nil
+	return stripped.String()
+}
@@ -0,0 +1,114 @@
+// Package instance keeps two processes from grabbing into the same state
+// directory at once, which would download releases twice and race on the
+// history
+package instance
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileName is the lock file in the state directory
+const FileName = "torrent-rss.lock"
+
+// ErrRunning means another process holds the lock
+var ErrRunning = errors.New("another instance is using the state directory")
+
+// ErrLocked is returned by LockFile when another process holds the file
+var ErrLocked = errors.New("locked")
+
+// Holder describes the process holding the lock
+type Holder struct {
+	PID     int       `json:"pid"`
+	Host    string    `json:"host"`
+	Command string    `json:"command"`
+	Started time.Time `json:"started"`
+}
+
+func (h Holder) String() string {
+	return fmt.Sprintf("%s (pid %d on %s) since %s", h.Command, h.PID, h.Host, h.Started.Format(time.DateTime))
+}
+
+// Lock is held by the process working on a state directory until Release.
+// The operating system drops it when the process dies, so a crash never
+// leaves a stale lock behind.
+type Lock struct {
+	file *os.File
+}
+
+// Acquire locks the state directory dir for this process, creating it if
+// needed. If another process holds it, the error wraps ErrRunning and says
+// which one.
+func Acquire(dir string) (*Lock, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+	path := filepath.Join(dir, FileName)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	if err := lock(f); err != nil {
+		defer f.Close()
+		if !errors.Is(err, ErrLocked) {
+			return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+		var holder Holder
+		if data, readErr := io.ReadAll(f); readErr == nil && json.Unmarshal(data, &holder) == nil && holder.PID != 0 {
+			return nil, fmt.Errorf("%w: %s", ErrRunning, holder)
+		}
+		return nil, fmt.Errorf("%w: %s is locked", ErrRunning, path)
+	}
+
+	host, _ := os.Hostname()
+	data, err := json.Marshal(Holder{
+		PID:     os.Getpid(),
+		Host:    host,
+		Command: strings.Join(append([]string{filepath.Base(os.Args[0])}, os.Args[1:]...), " "),
+		Started: time.Now(),
+	})
+	if err == nil {
+		err = f.Truncate(0)
+	}
+	if err == nil {
+		_, err = f.WriteAt(data, 0)
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to write lock file: %w", err)
+	}
+	return &Lock{file: f}, nil
+}
+
+// LockFile locks the file at path for this process, creating it if needed,
+// until the returned file is closed. The lock is the same kind Acquire
+// takes, so it goes when the process dies.
+func LockFile(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lock(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// Release unlocks the state directory. The file is left in place: removing
+// it could let a third process lock a new file while a second one holds
+// the old.
+func (l *Lock) Release() error {
+	if l == nil {
+		return nil
+	}
+	l.file.Truncate(0)
+	return l.file.Close()
+}
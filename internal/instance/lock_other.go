@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package instance
+
+import "os"
+
+// lock doesn't guard anything on platforms without file locks
+func lock(*os.File) error {
+	return nil
+}
@@ -0,0 +1,351 @@
+package login
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"torrent-rss/internal/content"
+
+	"golang.org/x/net/html"
+)
+
+// ErrLoginRequired is returned by requests the tracker answered with a 403 or
+// a redirect to its login page
+var ErrLoginRequired = errors.New("tracker session expired, login required")
+
+// RefreshMargin is how long before the session cookie expires the session
+// is renewed, rather than waiting for the tracker to turn a request away
+const RefreshMargin = 10 * time.Minute
+
+// minSessionLifetime leaves out cookies that expire soon after they're set:
+// challenge and load balancer cookies come and go while sessions last days
+const minSessionLifetime = time.Hour
+
+// Options describes a tracker's login form
+type Options struct {
+	URL      string // Page holding the login form
+	Username string
+	Password string
+	// Form field names, "username" and "password" by default
+	UsernameField string
+	PasswordField string
+}
+
+// Session logs into a tracker with a username and password and notices when
+// the session cookie it obtained stops working
+type Session struct {
+	opts      Options
+	loginPath string
+
+	mu sync.Mutex
+
+	// cookiesMu guards cookies, the cookies submitting the login form set
+	// and when each expires, zero for those that last as long as the process
+	cookiesMu sync.Mutex
+	cookies   map[string]time.Time
+}
+
+func New(opts Options) (*Session, error) {
+	if opts.URL == "" {
+		return nil, fmt.Errorf("login URL is required")
+	}
+	if opts.Username == "" || opts.Password == "" {
+		return nil, fmt.Errorf("login username and password are required")
+	}
+	u, err := url.Parse(opts.URL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid login URL %q", opts.URL)
+	}
+	if opts.UsernameField == "" {
+		opts.UsernameField = "username"
+	}
+	if opts.PasswordField == "" {
+		opts.PasswordField = "password"
+	}
+	return &Session{opts: opts, loginPath: u.Path}, nil
+}
+
+// loginRequest marks the requests made while logging in, so they aren't
+// mistaken for an expired session
+type loginRequest struct{}
+
+// IsLoginRequest reports whether ctx belongs to a request made while logging in
+func IsLoginRequest(ctx context.Context) bool {
+	return ctx.Value(loginRequest{}) != nil
+}
+
+// submitRequest marks the login form submission and its redirects, whose
+// cookies are the session
+type submitRequest struct{}
+
+// Login submits the login form, leaving the session cookie in the client's jar.
+// Hidden form inputs are sent back as is, which covers CSRF tokens.
+func (s *Session) Login(ctx context.Context, client *http.Client) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.login(ctx, client)
+}
+
+// Refresh logs in again if the session runs out within RefreshMargin.
+// Of concurrent callers only the first logs in. It reports whether it did.
+func (s *Session) Refresh(ctx context.Context, client *http.Client) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ExpiresSoon() {
+		return false, nil
+	}
+	return true, s.login(ctx, client)
+}
+
+func (s *Session) login(ctx context.Context, client *http.Client) error {
+	ctx = context.WithValue(ctx, loginRequest{}, true)
+	s.cookiesMu.Lock()
+	s.cookies = make(map[string]time.Time)
+	s.cookiesMu.Unlock()
+
+	page, err := s.get(ctx, client)
+	if err != nil {
+		return err
+	}
+	form := findLoginForm(page.doc)
+	if form == nil {
+		return fmt.Errorf("no login form found on %s", s.opts.URL)
+	}
+
+	values := hiddenInputs(form)
+	values.Set(s.opts.UsernameField, s.opts.Username)
+	values.Set(s.opts.PasswordField, s.opts.Password)
+
+	action, err := page.url.Parse(attr(form, "action"))
+	if err != nil {
+		return fmt.Errorf("invalid login form action: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(context.WithValue(ctx, submitRequest{}, true), "POST", action.String(), strings.NewReader(values.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create login request: %w", err)
+	}
+	req.Header.Set("content-type", "application/x-www-form-urlencoded")
+	req.Header.Set("referer", page.url.String())
+	if token := metaCSRFToken(page.doc); token != "" {
+		req.Header.Set("x-csrf-token", token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to submit login form: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("login failed: %s", resp.Status)
+	}
+
+	// Trackers answer bad credentials by showing the login form again
+	body, err := content.UTF8(resp.Body, resp.Header.Get("content-type"))
+	if err != nil {
+		return fmt.Errorf("failed to read login response: %w", err)
+	}
+	doc, err := html.Parse(body)
+	if err != nil {
+		return fmt.Errorf("failed to parse login response: %w", err)
+	}
+	if resp.Request.URL.Path == s.loginPath || findLoginForm(doc) != nil {
+		return fmt.Errorf("login failed: check the username and password")
+	}
+	return nil
+}
+
+// Expires is when the first session cookie runs out, zero when it's not
+// known: before logging in, or when the cookies last as long as the process
+func (s *Session) Expires() time.Time {
+	s.cookiesMu.Lock()
+	defer s.cookiesMu.Unlock()
+	var first time.Time
+	for _, expires := range s.cookies {
+		if !expires.IsZero() && (first.IsZero() || expires.Before(first)) {
+			first = expires
+		}
+	}
+	return first
+}
+
+// ExpiresSoon reports whether the session runs out within RefreshMargin
+func (s *Session) ExpiresSoon() bool {
+	expires := s.Expires()
+	return !expires.IsZero() && time.Now().Add(RefreshMargin).After(expires)
+}
+
+// track notes the expiry of the session cookies a response sets. Cookies
+// set by the form submission start the session; later responses may
+// extend it by setting them again.
+func (s *Session) track(req *http.Request, resp *http.Response) {
+	submitted := req.Context().Value(submitRequest{}) != nil
+	now := time.Now()
+	s.cookiesMu.Lock()
+	defer s.cookiesMu.Unlock()
+	for _, c := range resp.Cookies() {
+		if _, ok := s.cookies[c.Name]; !ok && !submitted {
+			continue
+		}
+		expires := c.Expires
+		if c.MaxAge > 0 {
+			expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+		}
+		switch {
+		case c.MaxAge < 0 || (!expires.IsZero() && !expires.After(now)):
+			delete(s.cookies, c.Name)
+		case submitted && !expires.IsZero() && expires.Sub(now) < minSessionLifetime:
+			// Not the session, see minSessionLifetime
+		default:
+			if s.cookies == nil {
+				s.cookies = make(map[string]time.Time)
+			}
+			s.cookies[c.Name] = expires
+		}
+	}
+}
+
+type loginPage struct {
+	url *url.URL
+	doc *html.Node
+}
+
+func (s *Session) get(ctx context.Context, client *http.Client) (*loginPage, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", s.opts.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create login request: %w", err)
+	}
+	req.Header.Set("accept", "text/html,application/xhtml+xml")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch login page: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch login page: %s", resp.Status)
+	}
+	body, err := content.UTF8(resp.Body, resp.Header.Get("content-type"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read login page: %w", err)
+	}
+	doc, err := html.Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse login page: %w", err)
+	}
+	return &loginPage{url: resp.Request.URL, doc: doc}, nil
+}
+
+// Transport wraps next so that responses asking for a login fail with
+// ErrLoginRequired, letting the caller log in and retry
+func (s *Session) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &transport{session: s, next: next}
+}
+
+type transport struct {
+	session *Session
+	next    http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	t.session.track(req, resp)
+	if IsLoginRequest(req.Context()) {
+		return resp, nil
+	}
+
+	if resp.StatusCode == http.StatusForbidden || t.session.redirectsToLogin(resp) {
+		resp.Body.Close()
+		return nil, ErrLoginRequired
+	}
+	return resp, nil
+}
+
+func (s *Session) redirectsToLogin(resp *http.Response) bool {
+	if resp.StatusCode < 300 || resp.StatusCode >= 400 {
+		return false
+	}
+	location, err := resp.Location()
+	return err == nil && location.Path == s.loginPath
+}
+
+// findLoginForm returns the first form with a password input
+func findLoginForm(doc *html.Node) *html.Node {
+	var form *html.Node
+	walk(doc, func(n *html.Node) bool {
+		if n.Data != "form" {
+			return true
+		}
+		walk(n, func(input *html.Node) bool {
+			if input.Data == "input" && strings.EqualFold(attr(input, "type"), "password") {
+				form = n
+			}
+			return form == nil
+		})
+		return form == nil
+	})
+	return form
+}
+
+func hiddenInputs(form *html.Node) url.Values {
+	values := make(url.Values)
+	walk(form, func(n *html.Node) bool {
+		if n.Data == "input" && strings.EqualFold(attr(n, "type"), "hidden") && attr(n, "name") != "" {
+			values.Set(attr(n, "name"), attr(n, "value"))
+		}
+		return true
+	})
+	return values
+}
+
+// metaCSRFToken finds the <meta name="csrf-token"> tag frameworks like Laravel
+// and Rails expect back as a header
+func metaCSRFToken(doc *html.Node) string {
+	var token string
+	walk(doc, func(n *html.Node) bool {
+		if n.Data == "meta" {
+			switch strings.ToLower(attr(n, "name")) {
+			case "csrf-token", "_csrf", "csrf_token":
+				token = attr(n, "content")
+			}
+		}
+		return token == ""
+	})
+	return token
+}
+
+// walk visits element nodes depth first until visit returns false
+func walk(node *html.Node, visit func(*html.Node) bool) bool {
+	if node.Type == html.ElementNode && !visit(node) {
+		return false
+	}
+	for c := node.FirstChild; c != nil; c = c.NextSibling {
+		if !walk(c, visit) {
+			return false
+		}
+	}
+	return true
+}
+
+func attr(node *html.Node, key string) string {
+	for _, a := range node.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
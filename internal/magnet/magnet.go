@@ -0,0 +1,75 @@
+package magnet
+
+import (
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Link is the useful part of a magnet URI
+type Link struct {
+	URI      string
+	InfoHash string // Lowercase hex BitTorrent v1 infohash
+	Name     string // Display name (dn), may be empty
+	Trackers []string
+}
+
+// IsMagnet reports whether s is a magnet URI
+func IsMagnet(s string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(s)), "magnet:")
+}
+
+// Parse extracts the infohash, display name and trackers from a magnet URI
+func Parse(uri string) (Link, error) {
+	uri = strings.TrimSpace(uri)
+	if !IsMagnet(uri) {
+		return Link{}, fmt.Errorf("not a magnet link: %q", uri)
+	}
+
+	// The part after "magnet:?" is a regular query string
+	_, rawQuery, _ := strings.Cut(uri, "?")
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return Link{}, fmt.Errorf("invalid magnet link: %w", err)
+	}
+
+	link := Link{
+		URI:      uri,
+		Name:     values.Get("dn"),
+		Trackers: values["tr"],
+	}
+	for _, xt := range values["xt"] {
+		if hash, ok := strings.CutPrefix(xt, "urn:btih:"); ok {
+			link.InfoHash, err = NormalizeHash(hash)
+			if err != nil {
+				return Link{}, err
+			}
+			break
+		}
+	}
+	if link.InfoHash == "" {
+		return Link{}, fmt.Errorf("magnet link has no btih infohash")
+	}
+	return link, nil
+}
+
+// NormalizeHash accepts hex or base32 infohashes and returns lowercase hex
+func NormalizeHash(hash string) (string, error) {
+	switch len(hash) {
+	case 40:
+		if _, err := hex.DecodeString(hash); err != nil {
+			return "", fmt.Errorf("invalid hex infohash %q", hash)
+		}
+		return strings.ToLower(hash), nil
+	case 32:
+		raw, err := base32.StdEncoding.DecodeString(strings.ToUpper(hash))
+		if err != nil {
+			return "", fmt.Errorf("invalid base32 infohash %q", hash)
+		}
+		return hex.EncodeToString(raw), nil
+	default:
+		return "", fmt.Errorf("invalid infohash length %d", len(hash))
+	}
+}
@@ -0,0 +1,128 @@
+package maintenance
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"torrent-rss/internal/retry"
+)
+
+// Error means a tracker answered with a maintenance or rate limit page
+// instead of the content
+type Error struct {
+	Host       string
+	Reason     string        // What the page said, e.g. "down for maintenance"
+	RateLimit  bool          // The page is a rate limit rather than maintenance
+	RetryAfter time.Duration // From the Retry-After header, zero if there was none
+}
+
+func (e *Error) Error() string {
+	if e.RateLimit {
+		return fmt.Sprintf("%s is rate limiting requests (%s)", e.Host, e.Reason)
+	}
+	return fmt.Sprintf("%s is unavailable (%s)", e.Host, e.Reason)
+}
+
+// Permanent tells the retry transport that sending the request again right
+// away won't help
+func (e *Error) Permanent() bool {
+	return true
+}
+
+// bodyPeek is how much of a response is read to look for markers
+const bodyPeek = 64 << 10
+
+var (
+	maintenanceMarkers = []string{
+		"down for maintenance", "under maintenance", "scheduled maintenance",
+		"maintenance mode", "temporarily unavailable", "site is offline", "be back soon",
+	}
+	rateLimitMarkers = []string{
+		"rate limit exceeded", "too many requests", "you are being rate limited",
+		"request limit reached", "slow down",
+	}
+	titlePattern = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+)
+
+// Detect reports what a maintenance or rate limit page says, reading at most
+// bodyPeek bytes of the body and leaving it readable from the start. Only
+// HTML is looked at. The whole page counts when the status is 429 or 5xx,
+// otherwise only its title does, so a forum post or torrent description
+// saying "slow down" isn't taken for a notice.
+func Detect(resp *http.Response) (reason string, rateLimit, ok bool) {
+	if !strings.Contains(strings.ToLower(resp.Header.Get("content-type")), "html") {
+		return "", false, false
+	}
+
+	prefix, err := io.ReadAll(io.LimitReader(resp.Body, bodyPeek))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(prefix), resp.Body), resp.Body}
+	if err != nil {
+		return "", false, false
+	}
+
+	text := strings.ToLower(string(prefix))
+	if !noticeStatus(resp.StatusCode) {
+		m := titlePattern.FindStringSubmatch(text)
+		if m == nil {
+			return "", false, false
+		}
+		text = m[1]
+	}
+	if marker, ok := findMarker(text, rateLimitMarkers); ok {
+		return marker, true, true
+	}
+	if marker, ok := findMarker(text, maintenanceMarkers); ok {
+		return marker, false, true
+	}
+	return "", false, false
+}
+
+// noticeStatus tells whether a response with the status is expected to be
+// a notice rather than content
+func noticeStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+func findMarker(text string, markers []string) (string, bool) {
+	for _, marker := range markers {
+		if strings.Contains(text, marker) {
+			return marker, true
+		}
+	}
+	return "", false
+}
+
+// Transport wraps next so maintenance and rate limit pages fail with *Error
+// instead of reaching a parser, or being retried over and over
+func Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &transport{next: next}
+}
+
+type transport struct {
+	next http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	reason, rateLimit, ok := Detect(resp)
+	if !ok {
+		return resp, nil
+	}
+	resp.Body.Close()
+	retryAfter, _ := retry.RetryAfter(resp.Header.Get("retry-after"))
+	return nil, &Error{Host: req.URL.Hostname(), Reason: reason, RateLimit: rateLimit, RetryAfter: retryAfter}
+}
@@ -0,0 +1,41 @@
+package maintenance
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		status    int
+		page      string
+		reason    string
+		rateLimit bool
+	}{
+		{503, "<html><body>We're down for maintenance, back soon</body></html>", "down for maintenance", false},
+		{429, "<html><body>Too many requests</body></html>", "too many requests", true},
+		{200, "<html><head><title>Site Under Maintenance</title></head><body></body></html>", "under maintenance", false},
+
+		// On a page that isn't an error, only the title counts
+		{200, "<html><head><title>Forums</title></head><body>Slow down, the site is offline on sundays</body></html>", "", false},
+		{200, "<html><body>rate limit exceeded</body></html>", "", false},
+		{404, "<html><head><title>Not found</title></head><body>Be back soon</body></html>", "", false},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{
+			StatusCode: tt.status,
+			Header:     http.Header{"Content-Type": {"text/html"}},
+			Body:       io.NopCloser(strings.NewReader(tt.page)),
+		}
+		reason, rateLimit, ok := Detect(resp)
+		if ok != (tt.reason != "") || reason != tt.reason || rateLimit != tt.rateLimit {
+			t.Errorf("Detect(%d %q) = %q, %v, %v, want %q, %v", tt.status, tt.page, reason, rateLimit, ok, tt.reason, tt.rateLimit)
+		}
+		// The page can still be read whole
+		if body, _ := io.ReadAll(resp.Body); string(body) != tt.page {
+			t.Errorf("body after Detect = %q, want %q", body, tt.page)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package metadata
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"torrent-rss/internal/httpx"
+	"torrent-rss/internal/retry"
+)
+
+// errUnauthorized is an API key or token the service turned down
+var errUnauthorized = errors.New("unauthorized")
+
+// newHTTPClient is the client providers make their API calls with, retrying
+// failed calls before they go through middleware
+func newHTTPClient(middleware ...httpx.Middleware) *http.Client {
+	middleware = append([]httpx.Middleware{httpx.Retry(retry.DefaultPolicy())}, middleware...)
+	return httpx.New(nil, httpx.Options{Timeout: 30 * time.Second}, middleware...).Client
+}
+
+// doJSON sends a request and decodes the JSON response into v
+func doJSON(client *http.Client, req *http.Request, service string, v any) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: request failed: %w", service, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return fmt.Errorf("%s: API key rejected: %w", service, errUnauthorized)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: unexpected status %s", service, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("%s: failed to parse response: %w", service, err)
+	}
+	return nil
+}
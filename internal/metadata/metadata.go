@@ -0,0 +1,231 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"torrent-rss/internal/release"
+)
+
+// Kind is whether a title is a show or a movie
+type Kind string
+
+const (
+	KindAny   Kind = "" // Only in queries, for titles that could be either
+	KindShow  Kind = "tv"
+	KindMovie Kind = "movie"
+)
+
+// Info is what a provider knows about a show or movie
+type Info struct {
+	Kind   Kind   `json:"kind"`
+	Title  string `json:"title"` // Canonical title, e.g. "The Office (US)"
+	Year   int    `json:"year,omitempty"`
+	TMDbID int    `json:"tmdb_id,omitempty"`
+	TVDbID int    `json:"tvdb_id,omitempty"`
+	IMDbID string `json:"imdb_id,omitempty"`
+}
+
+func (i Info) String() string {
+	if i.Year == 0 {
+		return i.Title
+	}
+	return fmt.Sprintf("%s (%d)", i.Title, i.Year)
+}
+
+// IDs lists the IDs that are known, e.g. "imdb:tt0386676 tmdb:2316"
+func (i Info) IDs() string {
+	var ids []string
+	if i.IMDbID != "" {
+		ids = append(ids, "imdb:"+i.IMDbID)
+	}
+	if i.TMDbID != 0 {
+		ids = append(ids, fmt.Sprintf("tmdb:%d", i.TMDbID))
+	}
+	if i.TVDbID != 0 {
+		ids = append(ids, fmt.Sprintf("tvdb:%d", i.TVDbID))
+	}
+	return strings.Join(ids, " ")
+}
+
+// Same reports whether two lookups found the same show or movie. TMDb
+// numbers shows and movies separately, so its IDs only count for one kind.
+func Same(a, b *Info) bool {
+	switch {
+	case a == nil || b == nil:
+		return false
+	case a.IMDbID != "" && b.IMDbID != "":
+		return a.IMDbID == b.IMDbID
+	case a.TVDbID != 0 && b.TVDbID != 0:
+		return a.TVDbID == b.TVDbID
+	case a.TMDbID != 0 && b.TMDbID != 0:
+		return a.TMDbID == b.TMDbID && a.Kind == b.Kind
+	}
+	return false
+}
+
+// Query is a title to look up
+type Query struct {
+	Title string
+	Year  int // Zero for any year
+	Kind  Kind
+}
+
+// ForRelease builds the query for a release name: episodes are shows,
+// releases with a year and no episode are movies
+func ForRelease(name string) Query {
+	r := release.Parse(name)
+	q := Query{Title: r.Title, Year: r.Year}
+	switch {
+	case r.Episode != nil:
+		q.Kind = KindShow
+	case r.Year != 0:
+		q.Kind = KindMovie
+	}
+	return q
+}
+
+// Provider looks up shows and movies on a metadata service
+type Provider interface {
+	// Lookup returns the best match for the query, or nil if there is none
+	Lookup(ctx context.Context, q Query) (*Info, error)
+}
+
+// Options holds the settings of a metadata provider
+type Options struct {
+	APIKey   string
+	BaseURL  string // Overrides the service's API URL
+	Language string // e.g. "en-US", for canonical titles
+}
+
+// Factory builds a Provider from options
+type Factory func(Options) (Provider, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes a provider available under the given name
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if factory == nil {
+		panic("metadata: Register factory is nil")
+	}
+	if _, exists := registry[name]; exists {
+		panic("metadata: Register called twice for " + name)
+	}
+	registry[name] = factory
+}
+
+// New builds the provider registered under name
+func New(name string, opts Options) (Provider, error) {
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown metadata provider %q (available: %v)", name, Names())
+	}
+	return factory(opts)
+}
+
+// Names lists the registered providers in alphabetical order
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DefaultCacheTTL is how long Cached remembers a lookup
+const DefaultCacheTTL = 24 * time.Hour
+
+// failureTTL is how long Cached remembers that a lookup failed
+const failureTTL = 10 * time.Minute
+
+// Cached wraps p so every title is looked up once per ttl, titles it
+// doesn't know included. A failed lookup returns its error once, then nil
+// until it's tried again failureTTL later, so a service that's down isn't
+// asked about every release.
+func Cached(p Provider, ttl time.Duration) Provider {
+	return &cache{next: p, ttl: ttl, entries: make(map[Query]cached)}
+}
+
+type cache struct {
+	next Provider
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[Query]cached
+}
+
+type cached struct {
+	info    *Info
+	failed  bool
+	expires time.Time
+}
+
+func (c *cache) Lookup(ctx context.Context, q Query) (*Info, error) {
+	key := q
+	key.Title = strings.ToLower(strings.TrimSpace(q.Title))
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.info, nil
+	}
+
+	info, err := c.next.Lookup(ctx, q)
+	entry = cached{info: info, expires: time.Now().Add(c.ttl)}
+	if err != nil {
+		entry = cached{failed: true, expires: time.Now().Add(min(failureTTL, c.ttl))}
+	}
+	// A lookup cut short by the caller says nothing about the service
+	if !errors.Is(err, context.Canceled) {
+		c.mu.Lock()
+		c.entries[key] = entry
+		c.mu.Unlock()
+	}
+	return info, err
+}
+
+// closest picks the first candidate within a year of the wanted one, or
+// else the first candidate, as services rank by relevance
+func closest(candidates []Info, year int) *Info {
+	if len(candidates) == 0 {
+		return nil
+	}
+	if year != 0 {
+		for i, c := range candidates {
+			if c.Year != 0 && c.Year-year <= 1 && year-c.Year <= 1 {
+				return &candidates[i]
+			}
+		}
+	}
+	return &candidates[0]
+}
+
+// yearOf reads the year from a date like "2005-03-24"
+func yearOf(date string) int {
+	if len(date) < 4 {
+		return 0
+	}
+	var year int
+	if _, err := fmt.Sscanf(date[:4], "%d", &year); err != nil {
+		return 0
+	}
+	return year
+}
@@ -0,0 +1,43 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// countingProvider answers every lookup with its info and err
+type countingProvider struct {
+	info    *Info
+	err     error
+	lookups int
+}
+
+func (p *countingProvider) Lookup(ctx context.Context, q Query) (*Info, error) {
+	p.lookups++
+	return p.info, p.err
+}
+
+func TestCachedRemembersFailures(t *testing.T) {
+	down := &countingProvider{err: errors.New("tmdb: unexpected status 503")}
+	cache := Cached(down, DefaultCacheTTL)
+	q := Query{Title: "Show Name", Kind: KindShow}
+
+	if _, err := cache.Lookup(context.Background(), q); err == nil {
+		t.Fatal("first lookup hid the error")
+	}
+	// The service isn't asked again about the title for a while
+	info, err := cache.Lookup(context.Background(), q)
+	if info != nil || err != nil || down.lookups != 1 {
+		t.Errorf("second lookup = %v, %v after %d lookups, want nil, nil after 1", info, err, down.lookups)
+	}
+
+	// A lookup the caller gave up on is tried again
+	canceled := &countingProvider{err: context.Canceled}
+	cache = Cached(canceled, DefaultCacheTTL)
+	cache.Lookup(context.Background(), q)
+	cache.Lookup(context.Background(), q)
+	if canceled.lookups != 2 {
+		t.Errorf("canceled lookup asked %d times, want 2", canceled.lookups)
+	}
+}
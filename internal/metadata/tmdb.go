@@ -0,0 +1,133 @@
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"torrent-rss/internal/httpx"
+)
+
+// DefaultTMDbURL is the TMDb API the tmdb provider talks to
+const DefaultTMDbURL = "https://api.themoviedb.org/3"
+
+func init() {
+	Register("tmdb", func(opts Options) (Provider, error) {
+		if opts.APIKey == "" {
+			return nil, fmt.Errorf("tmdb: API key is required")
+		}
+		baseURL := opts.BaseURL
+		if baseURL == "" {
+			baseURL = DefaultTMDbURL
+		}
+		return &TMDb{
+			baseURL:  strings.TrimRight(baseURL, "/"),
+			language: opts.Language,
+			http:     newHTTPClient(httpx.Auth(tmdbAuth(opts.APIKey))),
+		}, nil
+	})
+}
+
+// TMDb looks titles up on The Movie Database. It takes either a v3 API key
+// or a v4 read access token, which is sent as a bearer token.
+type TMDb struct {
+	baseURL  string
+	language string
+	http     *http.Client
+}
+
+// tmdbAuth sends v4 tokens, which are JWTs, as bearer tokens and v3 keys,
+// which are hex, as a query parameter
+func tmdbAuth(apiKey string) func(req *http.Request) {
+	if strings.HasPrefix(apiKey, "eyJ") {
+		return func(req *http.Request) {
+			req.Header.Set("authorization", "Bearer "+apiKey)
+		}
+	}
+	return func(req *http.Request) {
+		query := req.URL.Query()
+		query.Set("api_key", apiKey)
+		req.URL.RawQuery = query.Encode()
+	}
+}
+
+type tmdbResult struct {
+	ID           int    `json:"id"`
+	MediaType    string `json:"media_type"` // Only in multi searches
+	Name         string `json:"name"`       // Shows
+	FirstAirDate string `json:"first_air_date"`
+	Title        string `json:"title"` // Movies
+	ReleaseDate  string `json:"release_date"`
+}
+
+func (t *TMDb) Lookup(ctx context.Context, q Query) (*Info, error) {
+	params := url.Values{"query": {q.Title}}
+	path := "/search/multi"
+	switch q.Kind {
+	case KindShow:
+		path = "/search/tv"
+		if q.Year != 0 {
+			params.Set("first_air_date_year", strconv.Itoa(q.Year))
+		}
+	case KindMovie:
+		path = "/search/movie"
+		if q.Year != 0 {
+			params.Set("year", strconv.Itoa(q.Year))
+		}
+	}
+
+	var search struct {
+		Results []tmdbResult `json:"results"`
+	}
+	if err := t.get(ctx, path, params, &search); err != nil {
+		return nil, err
+	}
+
+	var candidates []Info
+	for _, r := range search.Results {
+		kind := Kind(r.MediaType)
+		if kind == "" {
+			kind = q.Kind
+		}
+		switch kind {
+		case KindShow:
+			candidates = append(candidates, Info{Kind: kind, Title: r.Name, Year: yearOf(r.FirstAirDate), TMDbID: r.ID})
+		case KindMovie:
+			candidates = append(candidates, Info{Kind: kind, Title: r.Title, Year: yearOf(r.ReleaseDate), TMDbID: r.ID})
+		}
+	}
+	info := closest(candidates, q.Year)
+	if info == nil {
+		return nil, nil
+	}
+
+	// Searches don't include the IDs on other services
+	var external struct {
+		IMDbID string `json:"imdb_id"`
+		TVDbID int    `json:"tvdb_id"`
+	}
+	if err := t.get(ctx, fmt.Sprintf("/%s/%d/external_ids", info.Kind, info.TMDbID), nil, &external); err != nil {
+		return nil, err
+	}
+	info.IMDbID, info.TVDbID = external.IMDbID, external.TVDbID
+	return info, nil
+}
+
+func (t *TMDb) get(ctx context.Context, path string, params url.Values, v any) error {
+	if params == nil {
+		params = url.Values{}
+	}
+	if t.language != "" {
+		params.Set("language", t.language)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", t.baseURL+path+"?"+params.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("tmdb: failed to create request: %w", err)
+	}
+	req.Header.Set("accept", "application/json")
+	return doJSON(t.http, req, "tmdb", v)
+}
@@ -0,0 +1,174 @@
+package metadata
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultTVDbURL is the TVDb v4 API the tvdb provider talks to
+const DefaultTVDbURL = "https://api4.thetvdb.com/v4"
+
+func init() {
+	Register("tvdb", func(opts Options) (Provider, error) {
+		if opts.APIKey == "" {
+			return nil, fmt.Errorf("tvdb: API key is required")
+		}
+		baseURL := opts.BaseURL
+		if baseURL == "" {
+			baseURL = DefaultTVDbURL
+		}
+		return &TVDb{
+			baseURL:  strings.TrimRight(baseURL, "/"),
+			apiKey:   opts.APIKey,
+			language: opts.Language,
+			http:     newHTTPClient(),
+		}, nil
+	})
+}
+
+// TVDb looks titles up on TheTVDB. The API key is traded for a token on the
+// first lookup, and again whenever the token expires.
+type TVDb struct {
+	baseURL  string
+	apiKey   string
+	language string
+	http     *http.Client
+
+	mu    sync.Mutex
+	token string
+}
+
+type tvdbResult struct {
+	TVDbID    string `json:"tvdb_id"`
+	Type      string `json:"type"` // series or movie
+	Name      string `json:"name"`
+	Year      string `json:"year"`
+	RemoteIDs []struct {
+		ID         string `json:"id"`
+		SourceName string `json:"sourceName"`
+	} `json:"remote_ids"`
+	Translations map[string]string `json:"translations"` // Names by three letter language
+}
+
+func (t *TVDb) Lookup(ctx context.Context, q Query) (*Info, error) {
+	params := url.Values{"query": {q.Title}}
+	switch q.Kind {
+	case KindShow:
+		params.Set("type", "series")
+	case KindMovie:
+		params.Set("type", "movie")
+	}
+	if q.Year != 0 {
+		params.Set("year", strconv.Itoa(q.Year))
+	}
+
+	var search struct {
+		Data []tvdbResult `json:"data"`
+	}
+	err := t.get(ctx, "/search?"+params.Encode(), &search)
+	if errors.Is(err, errUnauthorized) {
+		// The token expired, tokens last a month
+		t.mu.Lock()
+		t.token = ""
+		t.mu.Unlock()
+		err = t.get(ctx, "/search?"+params.Encode(), &search)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []Info
+	for _, r := range search.Data {
+		info := Info{Title: r.Name}
+		switch r.Type {
+		case "series":
+			info.Kind = KindShow
+		case "movie":
+			info.Kind = KindMovie
+		default:
+			continue
+		}
+		info.Year, _ = strconv.Atoi(r.Year)
+		info.TVDbID, _ = strconv.Atoi(r.TVDbID)
+		if name := r.Translations[t.language3()]; name != "" {
+			info.Title = name
+		}
+		for _, remote := range r.RemoteIDs {
+			switch remote.SourceName {
+			case "IMDB":
+				info.IMDbID = remote.ID
+			case "TheMovieDB.com":
+				info.TMDbID, _ = strconv.Atoi(remote.ID)
+			}
+		}
+		candidates = append(candidates, info)
+	}
+	return closest(candidates, q.Year), nil
+}
+
+// language3 maps the configured language, e.g. "en-US", to the three
+// letter codes TVDb translations are keyed by
+func (t *TVDb) language3() string {
+	codes := map[string]string{
+		"en": "eng", "de": "deu", "fr": "fra", "es": "spa", "it": "ita", "nl": "nld",
+		"pt": "por", "sv": "swe", "da": "dan", "no": "nor", "fi": "fin", "pl": "pol",
+		"ru": "rus", "ja": "jpn", "ko": "kor", "zh": "zho",
+	}
+	lang, _, _ := strings.Cut(strings.ToLower(t.language), "-")
+	return codes[lang]
+}
+
+func (t *TVDb) get(ctx context.Context, path string, v any) error {
+	token, err := t.login(ctx)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", t.baseURL+path, nil)
+	if err != nil {
+		return fmt.Errorf("tvdb: failed to create request: %w", err)
+	}
+	req.Header.Set("accept", "application/json")
+	req.Header.Set("authorization", "Bearer "+token)
+	return doJSON(t.http, req, "tvdb", v)
+}
+
+// login returns the API token, logging in if there is none yet
+func (t *TVDb) login(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token != "" {
+		return t.token, nil
+	}
+
+	body, err := json.Marshal(map[string]string{"apikey": t.apiKey})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", t.baseURL+"/login", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("tvdb: failed to create request: %w", err)
+	}
+	req.Header.Set("content-type", "application/json")
+
+	var resp struct {
+		Data struct {
+			Token string `json:"token"`
+		} `json:"data"`
+	}
+	if err := doJSON(t.http, req, "tvdb", &resp); err != nil {
+		return "", err
+	}
+	if resp.Data.Token == "" {
+		return "", fmt.Errorf("tvdb: login returned no token")
+	}
+	t.token = resp.Data.Token
+	return t.token, nil
+}
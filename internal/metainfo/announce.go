@@ -0,0 +1,181 @@
+package metainfo
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"torrent-rss/internal/bencode"
+)
+
+// Rewrite replaces the From prefix of an announce URL with To
+type Rewrite struct {
+	From string
+	To   string
+}
+
+// AnnounceRules edits the trackers of a downloaded torrent, to swap in a
+// per-user announce URL or add a backup tracker
+type AnnounceRules struct {
+	// Rewrite is applied to every announce URL, the first matching rule wins
+	Rewrite []Rewrite
+	// Append adds announce URLs the torrent doesn't already have, as a
+	// tier after its own
+	Append []string
+}
+
+// IsZero reports whether the rules leave torrents as they are
+func (r AnnounceRules) IsZero() bool {
+	return len(r.Rewrite) == 0 && len(r.Append) == 0
+}
+
+// ParseRewrites parses rewrite rules written as "from => to"
+func ParseRewrites(lines []string) ([]Rewrite, error) {
+	var rewrites []Rewrite
+	for _, line := range lines {
+		from, to, ok := strings.Cut(line, "=>")
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		if !ok || from == "" || to == "" {
+			return nil, fmt.Errorf("rewrite must look like \"from => to\", got %q", line)
+		}
+		rewrites = append(rewrites, Rewrite{From: from, To: to})
+	}
+	return rewrites, nil
+}
+
+// Validate checks that the rules produce announce URLs clients accept
+func (r AnnounceRules) Validate() error {
+	for _, rw := range r.Rewrite {
+		if rw.From == "" {
+			return fmt.Errorf("rewrite of %q has nothing to replace", rw.To)
+		}
+		if err := checkAnnounceURL(rw.To); err != nil {
+			return err
+		}
+	}
+	for _, u := range r.Append {
+		if err := checkAnnounceURL(u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UsesPasskey reports whether the rules have a {passkey} placeholder to fill
+func (r AnnounceRules) UsesPasskey() bool {
+	for _, rw := range r.Rewrite {
+		if strings.Contains(rw.To, "{passkey}") {
+			return true
+		}
+	}
+	for _, u := range r.Append {
+		if strings.Contains(u, "{passkey}") {
+			return true
+		}
+	}
+	return false
+}
+
+func checkAnnounceURL(raw string) error {
+	u, err := url.Parse(strings.ReplaceAll(raw, "{passkey}", "passkey"))
+	if err != nil {
+		return fmt.Errorf("invalid announce URL: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "udp", "ws", "wss":
+	default:
+		return fmt.Errorf("announce URL must start with http://, https://, udp://, ws:// or wss://, got %q", raw)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("announce URL %q has no host", raw)
+	}
+	return nil
+}
+
+// WithPasskey fills the {passkey} placeholder in the rules' URLs
+func (r AnnounceRules) WithPasskey(passkey string) AnnounceRules {
+	out := AnnounceRules{}
+	for _, rw := range r.Rewrite {
+		out.Rewrite = append(out.Rewrite, Rewrite{From: rw.From, To: strings.ReplaceAll(rw.To, "{passkey}", passkey)})
+	}
+	for _, u := range r.Append {
+		out.Append = append(out.Append, strings.ReplaceAll(u, "{passkey}", passkey))
+	}
+	return out
+}
+
+func (r AnnounceRules) rewrite(u string) string {
+	for _, rw := range r.Rewrite {
+		if strings.HasPrefix(u, rw.From) {
+			return rw.To + strings.TrimPrefix(u, rw.From)
+		}
+	}
+	return u
+}
+
+// RewriteAnnounce applies the rules to a .torrent file. Only announce and
+// announce-list change, every other entry keeps its exact bytes so the
+// infohash stays the same.
+func RewriteAnnounce(data []byte, rules AnnounceRules) ([]byte, error) {
+	if rules.IsZero() {
+		return data, nil
+	}
+	m, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	root, err := bencode.RawDict(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid torrent: %w", err)
+	}
+
+	announce := rules.rewrite(m.Announce)
+	// Rewrites may turn two trackers into the same one
+	seen := make(map[string]bool)
+	var tiers [][]string
+	for _, tier := range m.AnnounceList {
+		var urls []string
+		for _, u := range tier {
+			if u = rules.rewrite(u); !seen[u] {
+				seen[u] = true
+				urls = append(urls, u)
+			}
+		}
+		if len(urls) > 0 {
+			tiers = append(tiers, urls)
+		}
+	}
+
+	var extra []string
+	for _, u := range rules.Append {
+		if u != announce && !seen[u] {
+			seen[u] = true
+			extra = append(extra, u)
+		}
+	}
+	if len(extra) > 0 {
+		// Clients ignore announce once there's a list, so it becomes the
+		// first tier if the torrent had none
+		if len(tiers) == 0 && announce != "" {
+			tiers = [][]string{{announce}}
+		}
+		tiers = append(tiers, extra)
+	}
+	if announce == "" && len(tiers) > 0 {
+		announce = tiers[0][0]
+	}
+
+	dict := make(map[string]any, len(root)+1)
+	for k, v := range root {
+		dict[k] = v
+	}
+	dict["announce"] = announce
+	if len(tiers) > 0 {
+		list := make([]any, len(tiers))
+		for i, tier := range tiers {
+			list[i] = tier
+		}
+		dict["announce-list"] = list
+	}
+	return bencode.Encode(dict)
+}
@@ -0,0 +1,113 @@
+package metainfo
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+
+	"torrent-rss/internal/bencode"
+)
+
+// MetaInfo is the part of a .torrent file the downloader cares about
+type MetaInfo struct {
+	Announce     string
+	AnnounceList [][]string
+	Name         string
+	PieceLength  int64
+	Length       int64 // Total size of all files
+	Files        int
+	Private      bool
+	InfoHash     string // Lowercase hex SHA-1 of the bencoded info dict
+}
+
+// Parse validates that data is a real .torrent file: a bencoded dictionary
+// with a tracker and an info dict holding a name, a piece length, a whole
+// number of SHA-1 piece hashes and either a length or a file list
+func Parse(data []byte) (*MetaInfo, error) {
+	if looksLikeHTML(data) {
+		return nil, fmt.Errorf("not a torrent file: got an HTML page, the tracker may have returned an error")
+	}
+
+	decoded, err := bencode.Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("not a torrent file: %w", err)
+	}
+	root, ok := decoded.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("not a torrent file: top level is not a dictionary")
+	}
+
+	m := &MetaInfo{}
+	m.Announce, _ = root["announce"].(string)
+	if tiers, ok := root["announce-list"].([]any); ok {
+		for _, tier := range tiers {
+			var urls []string
+			list, _ := tier.([]any)
+			for _, u := range list {
+				if s, ok := u.(string); ok && s != "" {
+					urls = append(urls, s)
+				}
+			}
+			if len(urls) > 0 {
+				m.AnnounceList = append(m.AnnounceList, urls)
+			}
+		}
+	}
+	if m.Announce == "" && len(m.AnnounceList) == 0 {
+		return nil, fmt.Errorf("invalid torrent: no announce URL")
+	}
+
+	info, ok := root["info"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("invalid torrent: missing info dictionary")
+	}
+
+	if m.Name, ok = info["name"].(string); !ok || m.Name == "" {
+		return nil, fmt.Errorf("invalid torrent: info has no name")
+	}
+	if m.PieceLength, ok = info["piece length"].(int64); !ok || m.PieceLength <= 0 {
+		return nil, fmt.Errorf("invalid torrent: info has no valid piece length")
+	}
+	pieces, ok := info["pieces"].(string)
+	if !ok || len(pieces) == 0 || len(pieces)%20 != 0 {
+		return nil, fmt.Errorf("invalid torrent: piece hashes must be a non-empty multiple of 20 bytes")
+	}
+	private, _ := info["private"].(int64)
+	m.Private = private == 1
+
+	if length, ok := info["length"].(int64); ok {
+		m.Length, m.Files = length, 1
+	} else if files, ok := info["files"].([]any); ok && len(files) > 0 {
+		for i, f := range files {
+			file, _ := f.(map[string]any)
+			length, ok := file["length"].(int64)
+			if !ok || length < 0 {
+				return nil, fmt.Errorf("invalid torrent: file %d has no valid length", i)
+			}
+			m.Length += length
+		}
+		m.Files = len(files)
+	} else {
+		return nil, fmt.Errorf("invalid torrent: info has neither length nor files")
+	}
+
+	// Pieces must cover the content, or clients reject the torrent
+	if want := (m.Length + m.PieceLength - 1) / m.PieceLength; int64(len(pieces)/20) != want {
+		return nil, fmt.Errorf("invalid torrent: %d piece hashes for %d pieces of content", len(pieces)/20, want)
+	}
+
+	rawInfo, err := bencode.RawValue(data, "info")
+	if err != nil {
+		return nil, fmt.Errorf("invalid torrent: %w", err)
+	}
+	sum := sha1.Sum(rawInfo)
+	m.InfoHash = hex.EncodeToString(sum[:])
+
+	return m, nil
+}
+
+// looksLikeHTML catches login and error pages, which bencode can't start with
+func looksLikeHTML(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte("<"))
+}
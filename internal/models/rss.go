@@ -1,16 +1,19 @@
 package models
 
-type RSS struct {
-	Channel Channel `xml:"channel"`
-}
-
-type Channel struct {
-	Items []Item `xml:"item"`
-}
+import "time"
 
+// Item is a single feed entry normalized from RSS 2.0, Atom or JSON.
 type Item struct {
-	Title       string `xml:"title"`
-	Link        string `xml:"link"`
-	PubDate     string `xml:"pubDate"`
-	Description string `xml:"description"`
+	Title        string
+	Link         string
+	EnclosureURL string
+	GUID         string
+	PubDate      time.Time
+	Description  string
+	Size         int64  // Bytes, from the enclosure or description, 0 when unknown
+	Freeleech    bool   // The title or description marks the release as freeleech
+	InfoHash     string // From a Torznab attribute or magnet link, empty when the feed doesn't say
+	// Categories are the feed's category names and IDs of the item, like
+	// "TV/x264" or the Newznab ID "5040"
+	Categories []string
 }
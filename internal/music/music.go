@@ -0,0 +1,174 @@
+// Package music breaks down the names of music releases, which name artist
+// and album rather than show and episode, e.g. "Artist - Album (2020) [FLAC]"
+// on trackers or "Artist-Album-WEB-2020-GROUP" from the scene
+package music
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"torrent-rss/internal/episode"
+)
+
+// Release is a music release name broken down into its parts
+type Release struct {
+	Artist   string
+	Album    string
+	Year     int    // Zero when the name has none
+	Format   string // FLAC, ALAC or AAC whatever the bitrate, else V0, V2, 320, 256 or 192 for MP3, or MP3 when the bitrate isn't given
+	BitDepth int    // 24 for hi-res releases, zero otherwise
+	Media    string // CD, WEB, Vinyl or SACD
+	Group    string // Scene group, empty for tracker-style names
+}
+
+// Formats are the formats Parse knows, lossless first
+var Formats = []string{"FLAC", "ALAC", "V0", "V2", "320", "256", "192", "AAC", "MP3"}
+
+type tag struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+var (
+	// In the order they're picked: a bitrate next to a format other than
+	// MP3 is that format's, and MP3 alone only when no bitrate is given
+	formats = []tag{
+		{"FLAC", regexp.MustCompile(`(?i)\bFLAC\b`)},
+		{"ALAC", regexp.MustCompile(`(?i)\bALAC\b`)},
+		{"AAC", regexp.MustCompile(`(?i)\b(?:AAC|M4A)\b`)},
+		{"V0", regexp.MustCompile(`(?i)\bV0\b`)},
+		{"V2", regexp.MustCompile(`(?i)\bV2\b`)},
+		{"320", regexp.MustCompile(`(?i)\b320(?:[ ]?kbps|k)?\b`)},
+		{"256", regexp.MustCompile(`(?i)\b256(?:[ ]?kbps|k)?\b`)},
+		{"192", regexp.MustCompile(`(?i)\b192(?:[ ]?kbps|k)?\b`)},
+		{"MP3", regexp.MustCompile(`(?i)\bMP3\b`)},
+	}
+	media = []tag{
+		{"CD", regexp.MustCompile(`(?i)\b\d?CDS?\b`)},
+		{"WEB", regexp.MustCompile(`(?i)\bWEB\b`)},
+		{"Vinyl", regexp.MustCompile(`(?i)\b(?:Vinyl|\d?LP)\b`)},
+		{"SACD", regexp.MustCompile(`(?i)\bSACD\b`)},
+	}
+	hiResPattern = regexp.MustCompile(`(?i)\b24[ ._-]?bits?\b|\b24[ ._-]?(?:44\.1|48|88\.2|96|176\.4|192)\b`)
+	yearPattern  = regexp.MustCompile(`\b(?:19|20)\d{2}\b`)
+	// "Artist - Album", with a hyphen or a dash between spaces
+	artistPattern = regexp.MustCompile(`\s[-–—]\s`)
+	// A bracketed part at the end of the name, e.g. "(2020)" or "[FLAC]"
+	trailingPattern  = regexp.MustCompile(`\s*(\([^()]*\)|\[[^\[\]]*\]|\{[^{}]*\})$`)
+	extensionPattern = regexp.MustCompile(`(?i)\.torrent$`)
+	// Scene tokens that aren't part of the album, e.g. catalog numbers
+	catalogPattern = regexp.MustCompile(`^\(.*\)$`)
+	// Scene names have no spaces, "Artist-Album-WEB-2020-GROUP"
+	sceneGroupPattern = regexp.MustCompile(`^[A-Za-z0-9]+$`)
+)
+
+// Parse breaks a music release name down. It reports false when the name
+// has neither the "Artist - Album" of trackers nor the tags of scene names,
+// and for TV and anime episodes like "Show - S01E05 - Title" or "[Group]
+// Title - 05", though not for albums like "Artist - 21".
+func Parse(name string) (Release, bool) {
+	name = strings.TrimSpace(extensionPattern.ReplaceAllString(strings.TrimSpace(name), ""))
+	if info, ok := episode.Parse(name); ok && (info.Episode > 0 || info.Absolute) {
+		return Release{}, false
+	}
+	if loc := artistPattern.FindStringIndex(name); loc != nil {
+		return parseTracker(name[:loc[0]], name[loc[1]:]), true
+	}
+	if !strings.Contains(name, " ") && strings.Count(name, "-") >= 2 {
+		return parseScene(name)
+	}
+	return Release{}, false
+}
+
+// parseTracker reads "Artist - Album (Deluxe) (2020) [WEB FLAC 24bit]":
+// bracketed parts at the end holding a year or tags are tags, the rest of
+// the name is the album
+func parseTracker(artist, rest string) Release {
+	r := Release{Artist: strings.TrimSpace(artist)}
+	var tags []string
+	for {
+		loc := trailingPattern.FindStringSubmatchIndex(rest)
+		if loc == nil {
+			break
+		}
+		part := rest[loc[2]:loc[3]]
+		if !isTag(part) {
+			break
+		}
+		tags = append(tags, part)
+		rest = rest[:loc[0]]
+	}
+	// Tags may also follow the album unbracketed, "Album 2020 FLAC"
+	album := strings.Fields(rest)
+	for len(album) > 1 && isTag(album[len(album)-1]) {
+		tags = append(tags, album[len(album)-1])
+		album = album[:len(album)-1]
+	}
+	// "Artist - Album - 2019" leaves the dash before the year
+	r.Album = strings.TrimRight(strings.Join(album, " "), " -–—")
+	r.readTags(strings.Join(tags, " "))
+	return r
+}
+
+// parseScene reads "Artist-Album_Title-(CAT001)-WEB-FLAC-2020-GROUP", where
+// the first two parts are artist and album and the last is the group
+func parseScene(name string) (Release, bool) {
+	parts := strings.Split(name, "-")
+	clean := func(s string) string {
+		return strings.TrimSpace(strings.NewReplacer("_", " ", ".", " ").Replace(s))
+	}
+	r := Release{Artist: clean(parts[0]), Album: clean(parts[1])}
+	tags := parts[2:]
+	if last := tags[len(tags)-1]; len(tags) > 1 && sceneGroupPattern.MatchString(last) && !isTag(last) {
+		r.Group = last
+		tags = tags[:len(tags)-1]
+	}
+	var text []string
+	for _, t := range tags {
+		if !catalogPattern.MatchString(t) {
+			text = append(text, clean(t))
+		}
+	}
+	r.readTags(strings.Join(text, " "))
+	// Without a year or tags it's more likely a hyphenated title than music
+	return r, r.Year != 0 || r.Format != "" || r.Media != ""
+}
+
+// isTag reports whether part of a name holds a year or a tag
+func isTag(part string) bool {
+	if yearPattern.MatchString(part) || hiResPattern.MatchString(part) {
+		return true
+	}
+	return find(part, formats) != "" || find(part, media) != ""
+}
+
+func (r *Release) readTags(text string) {
+	if m := yearPattern.FindString(text); m != "" {
+		r.Year, _ = strconv.Atoi(m)
+	}
+	r.Media = find(text, media)
+	if hiResPattern.MatchString(text) {
+		r.BitDepth = 24
+	}
+	// The 192 of "24-192" is a sample rate, not a bitrate
+	r.Format = find(hiResPattern.ReplaceAllString(text, " "), formats)
+}
+
+func find(text string, tags []tag) string {
+	for _, t := range tags {
+		if t.pattern.MatchString(text) {
+			return t.name
+		}
+	}
+	return ""
+}
+
+// IsMP3 reports whether a format is MP3, of whichever bitrate
+func IsMP3(format string) bool {
+	switch format {
+	case "V0", "V2", "320", "256", "192", "MP3":
+		return true
+	}
+	return false
+}
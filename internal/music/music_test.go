@@ -0,0 +1,50 @@
+package music
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		want Release
+	}{
+		{"Artist - Album (2020) [FLAC]", Release{Artist: "Artist", Album: "Album", Year: 2020, Format: "FLAC"}},
+		{"Artist - Album (Deluxe) (2020) [WEB FLAC 24bit]", Release{Artist: "Artist", Album: "Album (Deluxe)", Year: 2020, Format: "FLAC", BitDepth: 24, Media: "WEB"}},
+		{"Artist - Album - 2019 [MP3 320]", Release{Artist: "Artist", Album: "Album", Year: 2019, Format: "320"}},
+		{"Artist - Album 2018 MP3", Release{Artist: "Artist", Album: "Album", Year: 2018, Format: "MP3"}},
+		{"Artist - Album [MP3 V0]", Release{Artist: "Artist", Album: "Album", Format: "V0"}},
+		{"Artist - Album (2021) [AAC 256]", Release{Artist: "Artist", Album: "Album", Year: 2021, Format: "AAC"}},
+		{"Artist - Album [WEB 24-192]", Release{Artist: "Artist", Album: "Album", BitDepth: 24, Media: "WEB"}},
+		{"Prince - 1999 (1982) [FLAC]", Release{Artist: "Prince", Album: "1999", Year: 1982, Format: "FLAC"}},
+		{"Artist-Album_Title-(CAT001)-WEB-FLAC-2020-GROUP", Release{Artist: "Artist", Album: "Album Title", Year: 2020, Format: "FLAC", Media: "WEB", Group: "GROUP"}},
+
+		// Numbers in albums aren't episodes
+		{"Artist - 21 (2011) [FLAC]", Release{Artist: "Artist", Album: "21", Year: 2011, Format: "FLAC"}},
+		{"Blink-182 - 182 Reasons [FLAC]", Release{Artist: "Blink-182", Album: "182 Reasons", Format: "FLAC"}},
+		{"Artist - 24 Hours (2019) [FLAC]", Release{Artist: "Artist", Album: "24 Hours", Year: 2019, Format: "FLAC"}},
+	}
+	for _, tt := range tests {
+		got, ok := Parse(tt.name)
+		if !ok {
+			t.Errorf("Parse(%q) isn't music", tt.name)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseRejectsEpisodes(t *testing.T) {
+	for _, name := range []string{
+		"[SubsPlease] Frieren - 12 (1080p)",
+		"[Group] Title [Batch]",
+		"Show Name - S01E05 - Episode Title",
+		"Show Name - 1x05 - Episode Title [720p]",
+		"Show-Name-S01E05-WEB-2020-GROUP",
+		"Just A Title",
+	} {
+		if r, ok := Parse(name); ok {
+			t.Errorf("Parse(%q) = %+v, want not music", name, r)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BatchNotifier can deliver many notifications as a single message
+type BatchNotifier interface {
+	Notifier
+	NotifyBatch(ctx context.Context, batch []Notification) error
+}
+
+// Digest collects notifications and delivers them once a day as one batch
+type Digest struct {
+	next BatchNotifier
+	at   time.Duration // Time of day, as an offset from midnight
+
+	mu      sync.Mutex
+	pending []Notification
+}
+
+// NewDigest batches notifications for next, delivered daily at the given
+// time of day. Run must be running for the digest to be sent.
+func NewDigest(next BatchNotifier, at time.Duration) *Digest {
+	return &Digest{next: next, at: at}
+}
+
+// ParseTimeOfDay parses a 24-hour "HH:MM" time into an offset from midnight
+func ParseTimeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("time of day must look like 08:00, got %q", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Notify queues n for the next digest
+func (d *Digest) Notify(_ context.Context, n Notification) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pending = append(d.pending, n)
+	return nil
+}
+
+// Flush sends everything queued so far, if anything
+func (d *Digest) Flush(ctx context.Context) error {
+	d.mu.Lock()
+	batch := d.pending
+	d.pending = nil
+	d.mu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+	if err := d.next.NotifyBatch(ctx, batch); err != nil {
+		// Keep the batch for the next attempt
+		d.mu.Lock()
+		d.pending = append(batch, d.pending...)
+		d.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Run sends the digest every day at the configured time until ctx is done,
+// then sends whatever is left so nothing is lost on shutdown
+func (d *Digest) Run(ctx context.Context, onError func(error)) {
+	for {
+		timer := time.NewTimer(time.Until(d.nextRun(time.Now())))
+		select {
+		case <-timer.C:
+			sendCtx, cancel := context.WithTimeout(ctx, time.Minute)
+			if err := d.Flush(sendCtx); err != nil {
+				onError(err)
+			}
+			cancel()
+		case <-ctx.Done():
+			timer.Stop()
+			sendCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+			if err := d.Flush(sendCtx); err != nil {
+				onError(err)
+			}
+			cancel()
+			return
+		}
+	}
+}
+
+func (d *Digest) nextRun(now time.Time) time.Time {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	next := midnight.Add(d.at)
+	if !next.After(now) {
+		next = midnight.AddDate(0, 0, 1).Add(d.at)
+	}
+	return next
+}
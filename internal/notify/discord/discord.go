@@ -0,0 +1,145 @@
+package discord
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"torrent-rss/internal/bytesize"
+	"torrent-rss/internal/notify"
+	"torrent-rss/internal/retry"
+)
+
+func init() {
+	notify.Register("discord", func(opts notify.Options) (notify.Notifier, error) {
+		return New(opts)
+	})
+}
+
+// Embed colors per notification kind
+var colors = map[notify.Kind]int{
+	notify.KindGrabbed:   0x39ff14, // Neon green
+	notify.KindUpgraded:  0x00e5ff, // Neon blue
+	notify.KindFailed:    0xff073a, // Neon red
+	notify.KindPending:   0xff6ec7, // Neon pink
+	notify.KindStale:     0xfff01f, // Neon yellow
+	notify.KindCompleted: 0xbc13fe, // Neon purple
+}
+
+// Notifier posts embeds to a Discord channel webhook
+type Notifier struct {
+	url  string
+	http *http.Client
+}
+
+func New(opts notify.Options) (*Notifier, error) {
+	if !strings.HasPrefix(opts.URL, "https://") {
+		return nil, fmt.Errorf("discord: webhook URL must start with https://")
+	}
+	return &Notifier{
+		url: opts.URL,
+		http: &http.Client{
+			Timeout: 30 * time.Second,
+			// Discord rate limits webhooks and says how long to wait
+			Transport: retry.Transport(nil, retry.DefaultPolicy()),
+		},
+	}, nil
+}
+
+type message struct {
+	Username string  `json:"username"`
+	Embeds   []embed `json:"embeds"`
+}
+
+type embed struct {
+	Title       string  `json:"title"`
+	Description string  `json:"description,omitempty"`
+	URL         string  `json:"url,omitempty"`
+	Color       int     `json:"color"`
+	Fields      []field `json:"fields,omitempty"`
+	Timestamp   string  `json:"timestamp"`
+}
+
+type field struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+func (n *Notifier) Notify(ctx context.Context, note notify.Notification) error {
+	e := embed{
+		Color:     colors[note.Kind],
+		URL:       note.Link,
+		Timestamp: note.Time.UTC().Format(time.RFC3339),
+	}
+
+	switch note.Kind {
+	case notify.KindGrabbed:
+		e.Title = "⚡️ Grabbed"
+		e.Description = note.Title
+	case notify.KindUpgraded:
+		e.Title = "⬆️ Upgraded"
+		e.Description = note.Title + "\n" + note.Reason
+	case notify.KindPending:
+		e.Title = "⏳ Awaiting approval"
+		e.Description = note.Title + "\n" + note.Reason
+	case notify.KindCompleted:
+		e.Title = "🏁 Completed"
+		e.Description = note.Title
+	case notify.KindStale:
+		e.Title = "🩺 Stale feed"
+		if note.Err != nil {
+			e.Description = note.Err.Error()
+		}
+	case notify.KindFailed:
+		e.Title = "💀 Failed"
+		e.Description = note.Title
+		if note.Err != nil {
+			e.Description = strings.TrimSpace(note.Title + "\n```" + note.Err.Error() + "```")
+		}
+	}
+
+	if note.Media != nil {
+		e.Fields = append(e.Fields, field{Name: "Title", Value: note.Media.String(), Inline: true})
+		if ids := note.Media.IDs(); ids != "" {
+			e.Fields = append(e.Fields, field{Name: "IDs", Value: ids, Inline: true})
+		}
+	}
+	if note.Size > 0 {
+		e.Fields = append(e.Fields, field{Name: "Size", Value: bytesize.Format(note.Size), Inline: true})
+	}
+	if note.Tracker != "" {
+		e.Fields = append(e.Fields, field{Name: "Tracker", Value: note.Tracker, Inline: true})
+	}
+	if note.Feed != "" {
+		e.Fields = append(e.Fields, field{Name: "Feed", Value: note.Feed, Inline: true})
+	}
+
+	body, err := json.Marshal(message{Username: "torrent-rss", Embeds: []embed{e}})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", n.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("discord: failed to create request: %w", err)
+	}
+	req.Header.Set("content-type", "application/json")
+
+	resp, err := n.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("discord: failed to post webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("discord: webhook returned %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
@@ -0,0 +1,246 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"time"
+
+	"torrent-rss/internal/bytesize"
+	"torrent-rss/internal/notify"
+)
+
+func init() {
+	notify.Register("email", func(opts notify.Options) (notify.Notifier, error) {
+		return New(opts)
+	})
+}
+
+// Notifier sends notifications as plain-text email over SMTP
+type Notifier struct {
+	addr     string // host:port
+	host     string
+	implicit bool // smtps://, TLS from the first byte instead of STARTTLS
+	username string
+	password string
+	from     string
+	to       []string
+}
+
+// New configures an SMTP notifier. opts.URL is smtp://host:587 for STARTTLS
+// or smtps://host:465 for implicit TLS.
+func New(opts notify.Options) (*Notifier, error) {
+	u, err := url.Parse(opts.URL)
+	if err != nil || u.Hostname() == "" {
+		return nil, fmt.Errorf("email: invalid SMTP URL %q", opts.URL)
+	}
+
+	n := &Notifier{
+		host:     u.Hostname(),
+		username: opts.Username,
+		password: opts.Password,
+		from:     opts.From,
+		to:       opts.To,
+	}
+	port := u.Port()
+	switch u.Scheme {
+	case "smtp":
+		if port == "" {
+			port = "587"
+		}
+	case "smtps":
+		n.implicit = true
+		if port == "" {
+			port = "465"
+		}
+	default:
+		return nil, fmt.Errorf("email: SMTP URL must start with smtp:// or smtps://")
+	}
+	n.addr = net.JoinHostPort(n.host, port)
+
+	if _, err := mail.ParseAddress(n.from); err != nil {
+		return nil, fmt.Errorf("email: invalid from address %q", n.from)
+	}
+	if len(n.to) == 0 {
+		return nil, fmt.Errorf("email: at least one recipient is required")
+	}
+	for _, to := range n.to {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return nil, fmt.Errorf("email: invalid recipient %q", to)
+		}
+	}
+	return n, nil
+}
+
+func (n *Notifier) Notify(ctx context.Context, note notify.Notification) error {
+	var body strings.Builder
+	writeNotification(&body, note)
+	return n.send(ctx, subject(note), body.String())
+}
+
+// NotifyBatch sends a digest of every notification in one email
+func (n *Notifier) NotifyBatch(ctx context.Context, batch []notify.Notification) error {
+	counts := make(map[notify.Kind]int)
+	for _, note := range batch {
+		counts[note.Kind]++
+	}
+
+	var parts []string
+	for _, kind := range notify.Kinds() {
+		if counts[kind] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[kind], kind))
+		}
+	}
+
+	var body strings.Builder
+	for _, kind := range notify.Kinds() {
+		if counts[kind] == 0 {
+			continue
+		}
+		fmt.Fprintf(&body, "%s (%d)\n%s\n", strings.ToUpper(string(kind)), counts[kind], strings.Repeat("=", 40))
+		for _, note := range batch {
+			if note.Kind == kind {
+				writeNotification(&body, note)
+				body.WriteString("\n")
+			}
+		}
+	}
+
+	return n.send(ctx, "Digest: "+strings.Join(parts, ", "), body.String())
+}
+
+func subject(note notify.Notification) string {
+	title := note.Title
+	if title == "" {
+		title = note.Feed
+	}
+	switch note.Kind {
+	case notify.KindGrabbed:
+		return "Grabbed " + title
+	case notify.KindUpgraded:
+		return "Upgraded " + title
+	case notify.KindPending:
+		return "Awaiting approval: " + title
+	case notify.KindStale:
+		return "Stale feed: " + title
+	case notify.KindCompleted:
+		return "Completed " + title
+	default:
+		return "Failed: " + title
+	}
+}
+
+func writeNotification(b *strings.Builder, note notify.Notification) {
+	if note.Title != "" {
+		fmt.Fprintf(b, "%s\n", note.Title)
+	}
+	fmt.Fprintf(b, "  Time:     %s\n", note.Time.Format(time.RFC1123))
+	if note.Media != nil {
+		fmt.Fprintf(b, "  Title:    %s\n", note.Media)
+		if ids := note.Media.IDs(); ids != "" {
+			fmt.Fprintf(b, "  IDs:      %s\n", ids)
+		}
+	}
+	if note.Feed != "" {
+		fmt.Fprintf(b, "  Feed:     %s\n", note.Feed)
+	}
+	if note.Tracker != "" {
+		fmt.Fprintf(b, "  Tracker:  %s\n", note.Tracker)
+	}
+	if note.Size > 0 {
+		fmt.Fprintf(b, "  Size:     %s\n", bytesize.Format(note.Size))
+	}
+	if note.Reason != "" {
+		fmt.Fprintf(b, "  Details:  %s\n", note.Reason)
+	}
+	if note.Err != nil {
+		fmt.Fprintf(b, "  Error:    %v\n", note.Err)
+	}
+	if note.Link != "" {
+		fmt.Fprintf(b, "  Link:     %s\n", note.Link)
+	}
+}
+
+func (n *Notifier) send(ctx context.Context, subject, body string) error {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", n.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "[torrent-rss] "+subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	c, err := n.dial(ctx)
+	if err != nil {
+		return fmt.Errorf("email: failed to connect to %s: %w", n.addr, err)
+	}
+	defer c.Close()
+
+	if !n.implicit {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(&tls.Config{ServerName: n.host}); err != nil {
+				return fmt.Errorf("email: STARTTLS failed: %w", err)
+			}
+		}
+	}
+	if n.username != "" {
+		// PlainAuth refuses to send the password over an unencrypted connection
+		if err := c.Auth(smtp.PlainAuth("", n.username, n.password, n.host)); err != nil {
+			return fmt.Errorf("email: authentication failed: %w", err)
+		}
+	}
+
+	if err := c.Mail(n.from); err != nil {
+		return fmt.Errorf("email: MAIL FROM rejected: %w", err)
+	}
+	for _, to := range n.to {
+		if err := c.Rcpt(to); err != nil {
+			return fmt.Errorf("email: recipient %s rejected: %w", to, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("email: DATA rejected: %w", err)
+	}
+	if _, err := w.Write(msg.Bytes()); err != nil {
+		return fmt.Errorf("email: failed to write message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("email: message rejected: %w", err)
+	}
+	return c.Quit()
+}
+
+// dial connects to the server, bounding the whole conversation by ctx
+func (n *Notifier) dial(ctx context.Context) (*smtp.Client, error) {
+	dialer := &net.Dialer{}
+	var conn net.Conn
+	var err error
+	if n.implicit {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: n.host}}).DialContext(ctx, "tcp", n.addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", n.addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, n.host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
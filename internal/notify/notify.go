@@ -0,0 +1,205 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"torrent-rss/internal/metadata"
+)
+
+// Kind is what a notification is about
+type Kind string
+
+const (
+	KindGrabbed  Kind = "grabbed"
+	KindUpgraded Kind = "upgraded"
+	KindFailed   Kind = "failed"  // Download, feed or login failure
+	KindPending  Kind = "pending" // Held for approval
+	KindStale    Kind = "stale"   // A feed went quiet or kept failing, see Err
+	// KindCompleted is a grabbed torrent its client finished downloading
+	KindCompleted Kind = "completed"
+)
+
+// Kinds lists every notification kind, in the order they're documented
+func Kinds() []Kind {
+	return []Kind{KindGrabbed, KindUpgraded, KindFailed, KindPending, KindStale, KindCompleted}
+}
+
+// Notification describes a grabbed release or a failure
+type Notification struct {
+	Kind     Kind
+	Feed     string
+	Tracker  string
+	Title    string // Empty for failures that aren't about a single release
+	Link     string
+	Size     int64 // Bytes, 0 when unknown
+	InfoHash string
+	Reason   string // Upgrade details, or how to approve a pending release
+	Err      error
+	Time     time.Time
+	Media    *metadata.Info // The show or movie, when a metadata provider knows it
+}
+
+// Notifier delivers notifications to a single service
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// Options holds the settings of a notification channel
+type Options struct {
+	URL      string
+	Username string
+	Password string
+	From     string   // Sender address, for email
+	To       []string // Recipient addresses, for email
+	Method   string   // HTTP method, for webhooks
+	Headers  map[string]string
+	Template string // Go template of the request body, for webhooks
+}
+
+// Factory builds a Notifier from options
+type Factory func(Options) (Notifier, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes a notifier available under the given name
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if factory == nil {
+		panic("notify: Register factory is nil")
+	}
+	if _, exists := registry[name]; exists {
+		panic("notify: Register called twice for " + name)
+	}
+	registry[name] = factory
+}
+
+// New builds the notifier registered under name
+func New(name string, opts Options) (Notifier, error) {
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown notifier %q (available: %v)", name, Names())
+	}
+	return factory(opts)
+}
+
+// Names lists the registered notifiers in alphabetical order
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseKinds validates a list of kind names, where empty means every kind
+func ParseKinds(names []string) ([]Kind, error) {
+	if len(names) == 0 {
+		return Kinds(), nil
+	}
+	var kinds []Kind
+	for _, name := range names {
+		kind := Kind(name)
+		if !containsKind(Kinds(), kind) {
+			return nil, fmt.Errorf("unknown event %q (available: %v)", name, Kinds())
+		}
+		kinds = append(kinds, kind)
+	}
+	return kinds, nil
+}
+
+func containsKind(kinds []Kind, kind Kind) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Dispatcher fans notifications out to every channel subscribed to their kind
+type Dispatcher struct {
+	channels []channel
+	onError  func(name string, err error)
+}
+
+type channel struct {
+	name     string
+	notifier Notifier
+	kinds    []Kind
+}
+
+// NewDispatcher creates an empty dispatcher. Delivery failures are passed to
+// onError, they never interrupt the caller.
+func NewDispatcher(onError func(name string, err error)) *Dispatcher {
+	if onError == nil {
+		onError = func(string, error) {}
+	}
+	return &Dispatcher{onError: onError}
+}
+
+// Add subscribes a notifier to the given kinds
+func (d *Dispatcher) Add(name string, n Notifier, kinds []Kind) {
+	d.channels = append(d.channels, channel{name: name, notifier: n, kinds: kinds})
+}
+
+// Run delivers the digests of digest channels on schedule until ctx is done
+func (d *Dispatcher) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, c := range d.channels {
+		digest, ok := c.notifier.(*Digest)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			digest.Run(ctx, func(err error) { d.onError(name, err) })
+		}(c.name)
+	}
+	wg.Wait()
+}
+
+// Flush sends pending digests right away, for runs that don't stay around
+// until the scheduled time
+func (d *Dispatcher) Flush(ctx context.Context) {
+	for _, c := range d.channels {
+		if digest, ok := c.notifier.(*Digest); ok {
+			if err := digest.Flush(ctx); err != nil {
+				d.onError(c.name, err)
+			}
+		}
+	}
+}
+
+// Notify sends n to every subscribed channel, giving each a few seconds
+func (d *Dispatcher) Notify(ctx context.Context, n Notification) {
+	if n.Time.IsZero() {
+		n.Time = time.Now()
+	}
+	for _, c := range d.channels {
+		if !containsKind(c.kinds, n.Kind) {
+			continue
+		}
+		sendCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+		if err := c.notifier.Notify(sendCtx, n); err != nil {
+			d.onError(c.name, err)
+		}
+		cancel()
+	}
+}
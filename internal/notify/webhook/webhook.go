@@ -0,0 +1,151 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"torrent-rss/internal/bytesize"
+	"torrent-rss/internal/metadata"
+	"torrent-rss/internal/notify"
+	"torrent-rss/internal/retry"
+)
+
+func init() {
+	notify.Register("webhook", func(opts notify.Options) (notify.Notifier, error) {
+		return New(opts)
+	})
+}
+
+// DefaultTemplate posts every field as a JSON object
+const DefaultTemplate = `{
+  "event": {{json .Event}},
+  "feed": {{json .Feed}},
+  "tracker": {{json .Tracker}},
+  "title": {{json .Title}},
+  "link": {{json .Link}},
+  "size": {{.Size}},
+  "infohash": {{json .InfoHash}},
+  "reason": {{json .Reason}},
+  "error": {{json .Error}},
+  "time": {{json .Time}},
+  "media": {{json .Media}}
+}`
+
+// Payload is what templates render, with errors and times already flattened
+type Payload struct {
+	Event     string
+	Feed      string
+	Tracker   string
+	Title     string
+	Link      string
+	Size      int64
+	SizeHuman string
+	InfoHash  string
+	Reason    string
+	Error     string
+	Time      string         // RFC 3339
+	Media     *metadata.Info // Nil unless a metadata provider knows the title
+}
+
+// Notifier sends each notification to an arbitrary URL, rendering the body
+// from a Go template so it fits whatever the receiving end expects
+type Notifier struct {
+	url      string
+	method   string
+	headers  map[string]string
+	template *template.Template
+	http     *http.Client
+}
+
+func New(opts notify.Options) (*Notifier, error) {
+	if !strings.HasPrefix(opts.URL, "http://") && !strings.HasPrefix(opts.URL, "https://") {
+		return nil, fmt.Errorf("webhook: URL must start with http:// or https://")
+	}
+
+	text := opts.Template
+	if text == "" {
+		text = DefaultTemplate
+	}
+	tmpl, err := template.New("webhook").Funcs(template.FuncMap{
+		"json": func(v any) (string, error) {
+			data, err := json.Marshal(v)
+			return string(data), err
+		},
+	}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("webhook: invalid template: %w", err)
+	}
+
+	method := strings.ToUpper(opts.Method)
+	if method == "" {
+		method = "POST"
+	}
+
+	headers := make(map[string]string, len(opts.Headers)+1)
+	headers["content-type"] = "application/json"
+	for key, value := range opts.Headers {
+		headers[strings.ToLower(key)] = value
+	}
+
+	return &Notifier{
+		url:      opts.URL,
+		method:   method,
+		headers:  headers,
+		template: tmpl,
+		http: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: retry.Transport(nil, retry.DefaultPolicy()),
+		},
+	}, nil
+}
+
+func (n *Notifier) Notify(ctx context.Context, note notify.Notification) error {
+	payload := Payload{
+		Event:     string(note.Kind),
+		Feed:      note.Feed,
+		Tracker:   note.Tracker,
+		Title:     note.Title,
+		Link:      note.Link,
+		Size:      note.Size,
+		SizeHuman: bytesize.Format(note.Size),
+		InfoHash:  note.InfoHash,
+		Reason:    note.Reason,
+		Time:      note.Time.Format(time.RFC3339),
+		Media:     note.Media,
+	}
+	if note.Err != nil {
+		payload.Error = note.Err.Error()
+	}
+
+	var body bytes.Buffer
+	if err := n.template.Execute(&body, payload); err != nil {
+		return fmt.Errorf("webhook: failed to render template: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, n.method, n.url, bytes.NewReader(body.Bytes()))
+	if err != nil {
+		return fmt.Errorf("webhook: failed to create request: %w", err)
+	}
+	for key, value := range n.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := n.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook: request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook: %s returned %s: %s", n.url, resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package opml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Feed is a subscription as RSS readers list it in OPML
+type Feed struct {
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+type document struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    head     `xml:"head"`
+	Body    struct {
+		Outlines []outline `xml:"outline"`
+	} `xml:"body"`
+}
+
+type head struct {
+	Title       string `xml:"title,omitempty"`
+	DateCreated string `xml:"dateCreated,omitempty"`
+}
+
+type outline struct {
+	Type     string    `xml:"type,attr,omitempty"`
+	Text     string    `xml:"text,attr"`
+	Title    string    `xml:"title,attr,omitempty"`
+	XMLURL   string    `xml:"xmlUrl,attr,omitempty"`
+	Outlines []outline `xml:"outline"`
+}
+
+// Parse reads the feeds of an OPML file. Readers nest feeds in folders,
+// which are flattened; outlines without an xmlUrl are folders, not feeds.
+func Parse(r io.Reader) ([]Feed, error) {
+	var doc document
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse OPML: %w", err)
+	}
+
+	var feeds []Feed
+	var walk func([]outline)
+	walk = func(outlines []outline) {
+		for _, o := range outlines {
+			if url := strings.TrimSpace(o.XMLURL); url != "" {
+				title := strings.TrimSpace(o.Title)
+				if title == "" {
+					title = strings.TrimSpace(o.Text)
+				}
+				feeds = append(feeds, Feed{Title: title, URL: url})
+			}
+			walk(o.Outlines)
+		}
+	}
+	walk(doc.Body.Outlines)
+	return feeds, nil
+}
+
+// Write writes feeds as an OPML 2.0 file titled title
+func Write(w io.Writer, title string, feeds []Feed) error {
+	doc := document{Version: "2.0", Head: head{Title: title, DateCreated: time.Now().UTC().Format(time.RFC1123Z)}}
+	for _, feed := range feeds {
+		doc.Body.Outlines = append(doc.Body.Outlines, outline{Type: "rss", Text: feed.Title, Title: feed.Title, XMLURL: feed.URL})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("failed to write OPML: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
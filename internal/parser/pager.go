@@ -0,0 +1,123 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"torrent-rss/internal/feed"
+	"torrent-rss/internal/models"
+)
+
+// Pager reads a feed's results a page at a time, newest first, for walking
+// back through a feed's archive. Items already on an earlier page aren't
+// returned again.
+type Pager struct {
+	next        func(ctx context.Context) ([]models.Item, error)
+	searchTerms []string
+	seen        map[string]bool
+	done        bool
+}
+
+// RSSPager pages through an RSS, Atom or JSON feed, read with mapping like
+// FetchAndParse does. The first page is the feed URL as it is, later ones
+// set the query parameter pageParam to 2, 3 and so on. Without pageParam
+// the feed has just the one page.
+func (p *Parser) RSSPager(feedURL, pageParam string, mapping *feed.JSONMapping, searchTerms []string) *Pager {
+	pager := &Pager{searchTerms: searchTerms, seen: make(map[string]bool)}
+	page := 1
+	pager.next = func(ctx context.Context) ([]models.Item, error) {
+		pageURL := feedURL
+		if page > 1 {
+			u, err := url.Parse(feedURL)
+			if err != nil {
+				return nil, fmt.Errorf("invalid feed URL: %w", err)
+			}
+			params := u.Query()
+			params.Set(pageParam, strconv.Itoa(page))
+			u.RawQuery = params.Encode()
+			pageURL = u.String()
+		}
+		items, err := p.fetch(ctx, pageURL, mapping, nil, false)
+		if err != nil {
+			return nil, err
+		}
+		page++
+		if pageParam == "" {
+			pager.done = true
+		}
+		return items, nil
+	}
+	return pager
+}
+
+// TorznabPager pages through the results of a Torznab endpoint, with a
+// query per search term like FetchTorznab. Every query moves on to its next
+// offset on each page until it runs out.
+func (p *Parser) TorznabPager(endpoint string, tz Torznab, searchTerms []string) *Pager {
+	pager := &Pager{searchTerms: searchTerms, seen: make(map[string]bool)}
+	queries := searchTerms
+	if len(queries) == 0 {
+		queries = []string{""}
+	}
+	offsets := make(map[string]int, len(queries))
+	pager.next = func(ctx context.Context) ([]models.Item, error) {
+		var items []models.Item
+		var left []string
+		for _, query := range queries {
+			searchURL, err := torznabURL(endpoint, tz, query, offsets[query])
+			if err != nil {
+				return nil, err
+			}
+			results, err := p.fetchTorznabPage(ctx, searchURL)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, results...)
+			offsets[query] += len(results)
+			// A short page is the query's last one
+			if len(results) > 0 && (tz.Limit == 0 || len(results) == tz.Limit) {
+				left = append(left, query)
+			}
+		}
+		queries = left
+		if len(queries) == 0 {
+			pager.done = true
+		}
+		return items, nil
+	}
+	return pager
+}
+
+// Next fetches the next page and returns its items that match a search
+// term and weren't on an earlier page
+func (pg *Pager) Next(ctx context.Context) ([]models.Item, error) {
+	items, err := pg.next(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var fresh []models.Item
+	for _, item := range items {
+		key := item.GUID
+		if key == "" {
+			key = item.Link
+		}
+		if !pg.seen[key] {
+			pg.seen[key] = true
+			fresh = append(fresh, item)
+		}
+	}
+	// A page of nothing new is past the end, or a feed that ignores the
+	// page parameter
+	if len(fresh) == 0 {
+		pg.done = true
+	}
+	return matchTerms(fresh, pg.searchTerms), nil
+}
+
+// Done tells whether the feed ran out of pages
+func (pg *Pager) Done() bool {
+	return pg.done
+}
@@ -1,53 +1,210 @@
 package parser
 
 import (
-	"encoding/xml"
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
+	"torrent-rss/internal/challenge"
+	"torrent-rss/internal/connpool"
+	"torrent-rss/internal/dnscache"
+	"torrent-rss/internal/feed"
+	"torrent-rss/internal/headers"
+	"torrent-rss/internal/httpx"
+	"torrent-rss/internal/maintenance"
 	"torrent-rss/internal/models"
+	"torrent-rss/internal/ratelimit"
+	"torrent-rss/internal/retry"
 )
 
+// ErrNotModified is returned when the feed hasn't changed since the last poll
+var ErrNotModified = errors.New("feed not modified")
+
 type Parser struct {
-	config *http.Client
+	config *httpx.Client
+
+	mu    sync.Mutex
+	cache map[string]validators
+}
+
+// validators are the caching headers of the last successful fetch of a feed
+type validators struct {
+	etag         string
+	lastModified string
+}
+
+// Options configures the parser's HTTP client
+type Options struct {
+	// Retry is applied to feed requests
+	Retry retry.Policy
+	// Limiter spaces out requests per host, nil doesn't limit
+	Limiter *ratelimit.Limiter
+	// Headers are the header profiles of tracker hosts, other hosts get
+	// headers.Default()
+	Headers map[string]headers.Profile
+	// Challenges detects, and with a solver gets past, anti-bot challenge
+	// pages. Nil only detects.
+	Challenges *challenge.Handler
+	// DNS caches host lookups, nil looks up every new connection
+	DNS *dnscache.Resolver
+	// TLS are the TLS settings of tracker hosts with their own CA or client
+	// certificate, other hosts use the system roots
+	TLS map[string]*tls.Config
+	// Connections tunes the connections to every host, and HostConnections
+	// those to tracker hosts with settings of their own
+	Connections     connpool.Options
+	HostConnections map[string]connpool.Options
+	// Debugf logs every request, nil stays quiet
+	Debugf func(format string, args ...any)
+	// Metrics counts requests per host, shared with the downloaders. Nil
+	// doesn't count.
+	Metrics *httpx.Metrics
 }
 
-func NewParser() *Parser {
+func NewParser(opts Options) *Parser {
+	base := httpx.NewTransport(httpx.TransportOptions{
+		DNS:            opts.DNS,
+		ConnectTimeout: 30 * time.Second,
+		Connections:    opts.Connections,
+	})
+	stack := httpx.Stack(httpx.StackOptions{
+		Retry:      opts.Retry,
+		Challenges: opts.Challenges,
+		Limiter:    opts.Limiter,
+		Headers:    httpx.HostHeaders(opts.Headers, headers.Default()),
+		Logf:       opts.Debugf,
+		Metrics:    opts.Metrics,
+	})
 	return &Parser{
-		config: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		config: httpx.New(hostTransports(base, opts.TLS, opts.HostConnections), httpx.Options{Timeout: 30 * time.Second}, stack...),
+		cache:  make(map[string]validators),
 	}
 }
 
-func (p *Parser) FetchAndParse(feedURL string, searchTerms []string) ([]models.Item, error) {
-	resp, err := p.config.Get(feedURL)
+// hostTransports sends requests to hosts with TLS or connection settings of
+// their own through a copy of base using them, and all others through base
+func hostTransports(base *http.Transport, tlsByHost map[string]*tls.Config, connsByHost map[string]connpool.Options) http.RoundTripper {
+	if len(tlsByHost) == 0 && len(connsByHost) == 0 {
+		return base
+	}
+	hosts := &hostTransport{byHost: make(map[string]http.RoundTripper), fallback: base}
+	transport := func(host string) *http.Transport {
+		host = strings.ToLower(host)
+		if t, ok := hosts.byHost[host]; ok {
+			return t.(*http.Transport)
+		}
+		t := base.Clone()
+		hosts.byHost[host] = t
+		return t
+	}
+	for host, config := range tlsByHost {
+		transport(host).TLSClientConfig = config.Clone()
+	}
+	for host, conns := range connsByHost {
+		conns.Apply(transport(host))
+	}
+	return hosts
+}
+
+type hostTransport struct {
+	byHost   map[string]http.RoundTripper
+	fallback http.RoundTripper
+}
+
+func (t *hostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if transport, ok := t.byHost[strings.ToLower(req.URL.Hostname())]; ok {
+		return transport.RoundTrip(req)
+	}
+	return t.fallback.RoundTrip(req)
+}
+
+// FetchAndParse fetches a feed and returns the items matching any search term.
+// The feed is read as RSS, Atom or JSON Feed, or with a mapping as a
+// tracker's JSON API response. Feeds that answered with an ETag or
+// Last-Modified header before are fetched conditionally, returning
+// ErrNotModified when nothing changed.
+func (p *Parser) FetchAndParse(ctx context.Context, feedURL string, mapping *feed.JSONMapping, searchTerms []string) ([]models.Item, error) {
+	return p.fetch(ctx, feedURL, mapping, searchTerms, true)
+}
+
+// Peek fetches a feed like FetchAndParse, but always in full and without
+// remembering its caching headers, so the next poll still gets whatever
+// changed since the last one
+func (p *Parser) Peek(ctx context.Context, feedURL string, mapping *feed.JSONMapping, searchTerms []string) ([]models.Item, error) {
+	return p.fetch(ctx, feedURL, mapping, searchTerms, false)
+}
+
+func (p *Parser) fetch(ctx context.Context, feedURL string, mapping *feed.JSONMapping, searchTerms []string, conditional bool) ([]models.Item, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create RSS request: %w", err)
+	}
+
+	// Feeds sharing a URL may match different terms, so cache them separately
+	cacheKey := feedURL + "\x00" + strings.Join(searchTerms, ",")
+	var cached validators
+	if conditional {
+		p.mu.Lock()
+		cached = p.cache[cacheKey]
+		p.mu.Unlock()
+	}
+	if cached.etag != "" {
+		req.Header.Set("if-none-match", cached.etag)
+	}
+	if cached.lastModified != "" {
+		req.Header.Set("if-modified-since", cached.lastModified)
+	}
+
+	resp, err := p.config.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch RSS feed: %w", err)
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, ErrNotModified
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch RSS feed: %w", statusError(resp))
+	}
+
+	var items []models.Item
+	if mapping != nil {
+		items, err = feed.ParseJSON(resp.Body, *mapping, feedURL)
+	} else {
+		items, err = feed.Parse(resp.Body)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return nil, err
+	}
+
+	if conditional {
+		p.mu.Lock()
+		p.cache[cacheKey] = validators{
+			etag:         resp.Header.Get("etag"),
+			lastModified: resp.Header.Get("last-modified"),
+		}
+		p.mu.Unlock()
 	}
 
-	var rss models.RSS
-	if err := xml.Unmarshal(body, &rss); err != nil {
-		return nil, fmt.Errorf("failed to parse XML: %w", err)
+	return matchTerms(items, searchTerms), nil
+}
+
+// matchTerms keeps the items whose title contains any search term. No search
+// terms means every item is a candidate.
+func matchTerms(items []models.Item, searchTerms []string) []models.Item {
+	if len(searchTerms) == 0 {
+		return items
 	}
 
-	// Filter items based on search terms AND 1080p
 	var matchedItems []models.Item
-	for _, item := range rss.Channel.Items {
+	for _, item := range items {
 		title := strings.ToLower(item.Title)
-		// Check if title contains 1080p
-		if !strings.Contains(title, "1080p") {
-			continue
-		}
 		// Check if title contains any of our search terms
 		for _, term := range searchTerms {
 			if strings.Contains(title, strings.ToLower(term)) {
@@ -56,6 +213,15 @@ func (p *Parser) FetchAndParse(feedURL string, searchTerms []string) ([]models.I
 			}
 		}
 	}
+	return matchedItems
+}
 
-	return matchedItems, nil
+// statusError describes a failed response. Rate limits that outlasted the
+// retries are a *maintenance.Error, so the tracker is left alone for a while.
+func statusError(resp *http.Response) error {
+	if resp.StatusCode == http.StatusTooManyRequests {
+		retryAfter, _ := retry.RetryAfter(resp.Header.Get("retry-after"))
+		return &maintenance.Error{Host: resp.Request.URL.Hostname(), Reason: resp.Status, RateLimit: true, RetryAfter: retryAfter}
+	}
+	return errors.New(resp.Status)
 }
@@ -0,0 +1,139 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"torrent-rss/internal/feed"
+	"torrent-rss/internal/models"
+)
+
+// DefaultTorznabPages is how many pages of results are read per query
+const DefaultTorznabPages = 1
+
+// Torznab describes how to query a Torznab endpoint such as Jackett's or
+// Prowlarr's, which searches many indexers through a single URL
+type Torznab struct {
+	APIKey     string
+	Categories []int // Newznab category IDs, e.g. 5000 for TV; empty searches all
+	Limit      int   // Results per page, 0 uses the indexer's default
+	Pages      int   // Pages read per query, 0 means DefaultTorznabPages
+}
+
+// FetchTorznab searches a Torznab endpoint once per search term, or once for
+// the latest releases without terms, and returns the items whose title
+// contains a term. Results found by several queries are returned once.
+func (p *Parser) FetchTorznab(ctx context.Context, endpoint string, tz Torznab, searchTerms []string) ([]models.Item, error) {
+	queries := searchTerms
+	if len(queries) == 0 {
+		queries = []string{""}
+	}
+
+	var items []models.Item
+	seen := make(map[string]bool)
+	for _, query := range queries {
+		results, err := p.searchTorznab(ctx, endpoint, tz, query)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range results {
+			key := item.GUID
+			if key == "" {
+				key = item.Link
+			}
+			if !seen[key] {
+				seen[key] = true
+				items = append(items, item)
+			}
+		}
+	}
+	return matchTerms(items, searchTerms), nil
+}
+
+// searchTorznab runs one query, following offsets until the last page
+func (p *Parser) searchTorznab(ctx context.Context, endpoint string, tz Torznab, query string) ([]models.Item, error) {
+	pages := tz.Pages
+	if pages <= 0 {
+		pages = DefaultTorznabPages
+	}
+
+	var items []models.Item
+	for page := 0; page < pages; page++ {
+		searchURL, err := torznabURL(endpoint, tz, query, len(items))
+		if err != nil {
+			return nil, err
+		}
+		results, err := p.fetchTorznabPage(ctx, searchURL)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, results...)
+		// A short page is the last one
+		if len(results) == 0 || (tz.Limit > 0 && len(results) < tz.Limit) {
+			break
+		}
+	}
+	return items, nil
+}
+
+func (p *Parser) fetchTorznabPage(ctx context.Context, searchURL string) ([]models.Item, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", searchURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Torznab request: %w", err)
+	}
+
+	resp, err := p.config.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Torznab endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Errors come back as an <error> document, often with a non-200 status
+	items, err := feed.Parse(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Torznab endpoint: %s: %w", resp.Status, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to query Torznab endpoint: %w", statusError(resp))
+	}
+	return items, nil
+}
+
+// torznabURL adds the search parameters to the endpoint, keeping any query
+// the endpoint already has
+func torznabURL(endpoint string, tz Torznab, query string, offset int) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid Torznab URL: %w", err)
+	}
+
+	params := u.Query()
+	params.Set("t", "search")
+	if tz.APIKey != "" {
+		params.Set("apikey", tz.APIKey)
+	}
+	if query != "" {
+		params.Set("q", query)
+	}
+	if len(tz.Categories) > 0 {
+		cats := make([]string, len(tz.Categories))
+		for i, cat := range tz.Categories {
+			cats[i] = strconv.Itoa(cat)
+		}
+		params.Set("cat", strings.Join(cats, ","))
+	}
+	if tz.Limit > 0 {
+		params.Set("limit", strconv.Itoa(tz.Limit))
+	}
+	if offset > 0 {
+		params.Set("offset", strconv.Itoa(offset))
+	}
+	// Ask for every torznab:attr, including size and download volume factor
+	params.Set("extended", "1")
+	u.RawQuery = params.Encode()
+	return u.String(), nil
+}
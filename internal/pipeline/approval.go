@@ -0,0 +1,59 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+
+	"torrent-rss/internal/config"
+	"torrent-rss/internal/history"
+	"torrent-rss/internal/metadata"
+	"torrent-rss/internal/models"
+)
+
+// awaitApproval holds an item of a feed in approval mode until it's decided
+// on, reporting it with EventPending the first time. It tells whether the
+// item was approved and may be grabbed.
+func (p *Pipeline) awaitApproval(feed config.Feed, key string, item models.Item, media *metadata.Info) (bool, error) {
+	pending, added, err := p.history.AddPending(key, feed.Name, item)
+	if err != nil {
+		return false, err
+	}
+	switch {
+	case added:
+		p.onEvent(Event{Kind: EventPending, Feed: feed.Name, Item: item, Reason: pending.ID, Size: item.Size, Media: media})
+	case pending.Status == history.PendingWaiting:
+		p.onEvent(Event{Kind: EventSkipped, Feed: feed.Name, Item: item, Reason: "awaiting approval"})
+	case pending.Status == history.PendingRejected:
+		p.onEvent(Event{Kind: EventSkipped, Feed: feed.Name, Item: item, Reason: "rejected"})
+	}
+	return pending.Status == history.PendingApproved, nil
+}
+
+// Approve marks a pending item of the feed approved and grabs it right away.
+// Items approved while nothing polls are grabbed on the next poll that
+// still lists them.
+func (p *Pipeline) Approve(ctx context.Context, feed config.Feed, id string) error {
+	pending, err := p.decide(feed, id, history.PendingApproved)
+	if err != nil {
+		return err
+	}
+	defer p.flushStats()
+	return p.process(ctx, feed, pending.Item)
+}
+
+// Reject marks a pending item of the feed rejected, so it's never grabbed
+func (p *Pipeline) Reject(feed config.Feed, id string) error {
+	_, err := p.decide(feed, id, history.PendingRejected)
+	return err
+}
+
+func (p *Pipeline) decide(feed config.Feed, id, status string) (*history.Pending, error) {
+	pending, err := p.history.Pending(id)
+	if err != nil {
+		return nil, err
+	}
+	if pending == nil || pending.Feed != feed.Name {
+		return nil, fmt.Errorf("feed %s: no pending item %q", feed.Name, id)
+	}
+	return p.history.DecidePending(id, status)
+}
@@ -0,0 +1,77 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+
+	"torrent-rss/internal/config"
+	"torrent-rss/internal/models"
+	"torrent-rss/internal/parser"
+)
+
+// Backfill walks back through up to pages pages of a feed's results, newest
+// first, grabbing what its filters match like a poll would, and what the
+// history has already is skipped. The back catalog is the point, so the
+// feed's age cutoff and per-poll limit don't apply. onPage is called after
+// each page with how many of its items matched the search terms. It returns
+// how many pages were read.
+func (p *Pipeline) Backfill(ctx context.Context, feed config.Feed, pages int, onPage func(page, items int)) (int, error) {
+	if err := p.checkFeed(feed); err != nil {
+		return 0, err
+	}
+	if until, ok := p.cooldowns.coolingDown(feed.Tracker); ok {
+		return 0, fmt.Errorf("feed %s: %w, %s is left alone until %s", feed.Name, ErrCoolingDown, feed.Tracker, until.Format("15:04"))
+	}
+	defer p.flushStats()
+
+	var pager *parser.Pager
+	if feed.Torznab != nil {
+		pager = p.parser.TorznabPager(feed.URL, *feed.Torznab, feed.SearchTerms)
+	} else {
+		pager = p.parser.RSSPager(feed.URL, feed.PageParam, feed.JSONAPI, feed.SearchTerms)
+	}
+	feed.IgnoreOlderThan, feed.MaxItemsPerPoll = 0, 0
+
+	polled := make(map[string]bool)
+	read := 0
+	for read < pages && !pager.Done() {
+		items, err := pager.Next(ctx)
+		if err != nil {
+			if trackerDown(err) {
+				p.cooldowns.coolDown(feed.Tracker, err)
+			}
+			return read, fmt.Errorf("feed %s: page %d: %w", feed.Name, read+1, err)
+		}
+		read++
+
+		// Pages are processed one at a time, so a pack is only preferred
+		// over episodes on the same page
+		items = rank(feed, items)
+		keys := make([]string, 0, len(items))
+		var picked []models.Item
+		for _, item := range items {
+			key := historyKey(feed, item)
+			if polled[key] {
+				continue
+			}
+			picked = append(picked, item)
+			keys = append(keys, key)
+		}
+		if err := p.processQueued(ctx, feed, keys, picked, polled); err != nil {
+			if ctx.Err() != nil {
+				return read, ctx.Err()
+			}
+			return read, fmt.Errorf("feed %s: %w", feed.Name, err)
+		}
+		if onPage != nil {
+			onPage(read, len(items))
+		}
+	}
+
+	if holdsReleases(feed) {
+		if err := p.decideContests(ctx, feed); err != nil {
+			return read, fmt.Errorf("feed %s: %w", feed.Name, err)
+		}
+	}
+	return read, nil
+}
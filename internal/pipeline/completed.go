@@ -0,0 +1,92 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+
+	"torrent-rss/internal/client"
+	"torrent-rss/internal/history"
+	"torrent-rss/internal/models"
+)
+
+// CheckCompletions asks the torrent clients which of the torrents sent to
+// them, or to folders they watch, finished downloading. Those are marked
+// completed in the history, their watch-folder file is removed when the
+// feed asked for it, and each is reported as EventCompleted. Clients that
+// can't list their torrents are skipped. It returns how many completed.
+func (p *Pipeline) CheckCompletions(ctx context.Context) (int, error) {
+	entries, err := p.history.Incomplete()
+	if err != nil {
+		return 0, err
+	}
+	byClient := make(map[string][]history.Entry)
+	for _, entry := range entries {
+		byClient[entry.Client] = append(byClient[entry.Client], entry)
+	}
+
+	completed := 0
+	var errs []error
+	for name, pending := range byClient {
+		target, ok := p.clients[name]
+		if !ok {
+			continue
+		}
+		lister, ok := target.client.(client.Lister)
+		if !ok {
+			continue
+		}
+		torrents, err := lister.Torrents(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to list the torrents of %s: %w", name, err))
+			continue
+		}
+		done := make(map[string]bool, len(torrents))
+		for _, t := range torrents {
+			done[t.InfoHash] = t.Done
+		}
+
+		for _, entry := range pending {
+			if !done[entry.InfoHash] {
+				continue
+			}
+			if err := p.complete(entry); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			completed++
+		}
+	}
+	return completed, errors.Join(errs...)
+}
+
+// complete records an entry's torrent as completed and removes its
+// watch-folder file. A file that can't be removed is kept in the entry and
+// reported, the torrent still counts as completed.
+func (p *Pipeline) complete(entry history.Entry) error {
+	file := entry.File
+	var removeErr error
+	if file != "" {
+		if err := os.Remove(file); err == nil || errors.Is(err, fs.ErrNotExist) {
+			file = ""
+		} else {
+			removeErr = fmt.Errorf("failed to remove %s: %w", file, err)
+		}
+	}
+
+	updated, err := p.history.Complete(entry.Key, time.Now(), file)
+	if err != nil || updated == nil {
+		return errors.Join(err, removeErr)
+	}
+	p.onEvent(Event{
+		Kind:     EventCompleted,
+		Feed:     entry.Feed,
+		Item:     models.Item{Title: entry.Title, Link: entry.Link},
+		InfoHash: entry.InfoHash,
+		Media:    entry.Media,
+	})
+	return removeErr
+}
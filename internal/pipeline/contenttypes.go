@@ -0,0 +1,33 @@
+package pipeline
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"torrent-rss/internal/config"
+	"torrent-rss/internal/models"
+)
+
+// SetContentTypes sets the types releases are told apart by, for feeds
+// that only want some of them and types with a destination of their own
+func (p *Pipeline) SetContentTypes(types []config.ContentType) {
+	p.contentTypes = types
+}
+
+// matchContentType checks the content type of an item against the ones the
+// feed wants, returning the type, nil when the item has none. Items the
+// feed doesn't categorize always pass.
+func (p *Pipeline) matchContentType(feed config.Feed, item models.Item) (*config.ContentType, bool, string) {
+	t := config.DetectContentType(p.contentTypes, item.Categories)
+	if len(feed.ContentTypes) == 0 || len(item.Categories) == 0 {
+		return t, true, ""
+	}
+	if t == nil {
+		return nil, false, fmt.Sprintf("category %s is none of %s", strings.Join(item.Categories, ", "), strings.Join(feed.ContentTypes, ", "))
+	}
+	if !slices.Contains(feed.ContentTypes, t.Type.Name) {
+		return t, false, fmt.Sprintf("content type %s is none of %s", t.Type.Name, strings.Join(feed.ContentTypes, ", "))
+	}
+	return t, true, ""
+}
@@ -0,0 +1,220 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"torrent-rss/internal/config"
+	"torrent-rss/internal/episode"
+	"torrent-rss/internal/history"
+	"torrent-rss/internal/models"
+	"torrent-rss/internal/quality"
+	"torrent-rss/internal/release"
+)
+
+// bestFirst orders items by the feed's scores, keeping the order otherwise,
+// so of several releases of an episode in one poll the best is grabbed
+func bestFirst(feed config.Feed, items []models.Item) []models.Item {
+	scores := make(map[string]int, len(items))
+	for _, item := range items {
+		scores[item.Title] = feed.Scoring.Score(item)
+	}
+	sorted := append([]models.Item(nil), items...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return scores[sorted[i].Title] > scores[sorted[j].Title]
+	})
+	return sorted
+}
+
+// holdsReleases reports whether the feed waits for better releases of an
+// episode before grabbing one, through a scoring window or quality delays
+func holdsReleases(feed config.Feed) bool {
+	return (feed.Scoring != nil && feed.Scoring.Window() > 0) || feed.Quality.HasDelays()
+}
+
+// contestKey identifies the contest of an episode within a feed
+func contestKey(feed config.Feed, info episode.Info) string {
+	return feed.Name + "|" + info.Key()
+}
+
+// candidateDelay is how long a release makes the feed wait for a better one:
+// the delay of its quality tier, or else the scoring window
+func candidateDelay(feed config.Feed, item models.Item) time.Duration {
+	if delay, ok := feed.Quality.Delay(quality.Parse(item.Title)); ok {
+		return delay
+	}
+	if feed.Scoring != nil {
+		return feed.Scoring.Window()
+	}
+	return 0
+}
+
+// closesAt is when a contest is decided: the shortest delay of its
+// candidates after the first of them showed up
+func closesAt(feed config.Feed, contest *history.Contest) time.Time {
+	window := time.Duration(math.MaxInt64)
+	for _, candidate := range contest.Candidates {
+		window = min(window, candidateDelay(feed, candidate.Item))
+	}
+	return contest.Opened.Add(window)
+}
+
+// ranked orders a contest's candidates from best to worst: by quality tier,
+// then a PROPER or REPACK ahead of the release it fixes, then by score, and
+// the earlier one first when that's all the same
+func ranked(feed config.Feed, contest *history.Contest) []history.Candidate {
+	type rank struct {
+		tier   int
+		proper bool
+	}
+	ranks := make(map[string]rank, len(contest.Candidates))
+	for _, candidate := range contest.Candidates {
+		r := rank{proper: release.Parse(candidate.Item.Title).Proper}
+		if feed.Quality != nil {
+			if r.tier = feed.Quality.Rank(quality.Parse(candidate.Item.Title)); r.tier < 0 {
+				r.tier = math.MaxInt
+			}
+		}
+		ranks[candidate.Key] = r
+	}
+
+	sorted := append([]history.Candidate(nil), contest.Candidates...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := ranks[sorted[i].Key], ranks[sorted[j].Key]
+		switch {
+		case a.tier != b.tier:
+			return a.tier < b.tier
+		case a.proper != b.proper:
+			return a.proper
+		case sorted[i].Score != sorted[j].Score:
+			return sorted[i].Score > sorted[j].Score
+		}
+		return sorted[i].Seen.Before(sorted[j].Seen)
+	})
+	return sorted
+}
+
+// enterContest holds an item of a feed that waits for better releases as a
+// candidate for its episode. It tells whether the item was picked as the
+// best and may be grabbed now.
+func (p *Pipeline) enterContest(feed config.Feed, key string, item models.Item, info episode.Info) (bool, error) {
+	contest, err := p.history.Contest(contestKey(feed, info))
+	if err != nil {
+		return false, err
+	}
+	if contest != nil && contest.Winner != "" {
+		if contest.Winner == key {
+			return true, nil
+		}
+		reason := "a better release"
+		if winner := contest.Candidate(contest.Winner); winner != nil {
+			reason = fmt.Sprintf("%q, which ranked higher", winner.Item.Title)
+		}
+		p.onEvent(Event{Kind: EventSkipped, Feed: feed.Name, Item: item, Reason: info.Code() + " goes to " + reason})
+		return false, nil
+	}
+
+	score := 0
+	if feed.Scoring != nil {
+		score = feed.Scoring.Score(item)
+	}
+	contest, err = p.history.AddCandidate(contestKey(feed, info), feed.Name, info.Code(), history.Candidate{Key: key, Item: item, Score: score})
+	if err != nil {
+		return false, err
+	}
+
+	// A release in a tier without a delay decides right away
+	closes := closesAt(feed, contest)
+	if !time.Now().Before(closes) {
+		winner, retrying, err := p.pickWinner(feed, contest)
+		if err != nil {
+			return false, err
+		}
+		if winner != nil && winner.Key == key && !retrying {
+			return true, p.history.SetWinner(contest.Key, key)
+		}
+		// Someone else won, which decideContests grabs at the end of the poll
+		p.onEvent(Event{Kind: EventSkipped, Feed: feed.Name, Item: item, Reason: info.Code() + " goes to a better release"})
+		return false, nil
+	}
+
+	reason := fmt.Sprintf("waiting for a better release of %s until %s", info.Code(), closes.Format("Jan 2 15:04"))
+	if feed.Scoring != nil {
+		reason = fmt.Sprintf("scored %d, %s", score, reason)
+	}
+	p.onEvent(Event{Kind: EventSkipped, Feed: feed.Name, Item: item, Reason: reason})
+	return false, nil
+}
+
+// decideContests grabs the best release of every episode of the feed whose
+// wait is over. When the pick fails for good, the next best is tried.
+// Picks that failed but will be retried are left to retryQueued.
+func (p *Pipeline) decideContests(ctx context.Context, feed config.Feed) error {
+	contests, err := p.history.Contests(feed.Name)
+	if err != nil {
+		return err
+	}
+	for _, contest := range contests {
+		if contest.Winner == "" && time.Now().Before(closesAt(feed, &contest)) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		winner, retrying, err := p.pickWinner(feed, &contest)
+		if err != nil {
+			return err
+		}
+		if winner == nil {
+			if err := p.history.RemoveContest(contest.Key); err != nil {
+				return err
+			}
+			continue
+		}
+		if winner.Key != contest.Winner {
+			if err := p.history.SetWinner(contest.Key, winner.Key); err != nil {
+				return err
+			}
+		}
+		if retrying {
+			continue
+		}
+		if err := p.process(ctx, feed, winner.Item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pickWinner returns the best candidate of a contest that hasn't failed for
+// good, and whether it's queued for a retry. It returns nil once there's
+// none left, or when a candidate was grabbed some other way.
+func (p *Pipeline) pickWinner(feed config.Feed, contest *history.Contest) (*history.Candidate, bool, error) {
+	var winner *history.Candidate
+	retrying := false
+	for _, candidate := range ranked(feed, contest) {
+		grabbed, err := p.history.Has(candidate.Key)
+		if err != nil {
+			return nil, false, err
+		}
+		if grabbed {
+			return nil, false, nil
+		}
+		if winner != nil {
+			continue
+		}
+		failure, err := p.history.Failure(candidate.Key)
+		if err != nil {
+			return nil, false, err
+		}
+		if failure == nil || !failure.Exhausted(feed.MaxFailures) {
+			winner = &candidate
+			retrying = failure != nil
+		}
+	}
+	return winner, retrying, nil
+}
@@ -0,0 +1,76 @@
+package pipeline
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"torrent-rss/internal/downloader"
+	"torrent-rss/internal/maintenance"
+)
+
+// ErrCoolingDown means a feed wasn't polled because its tracker recently
+// answered with a maintenance or rate limit page, see SetTrackerCooldown
+var ErrCoolingDown = errors.New("tracker cooling down")
+
+// cooldowns remembers which trackers are left alone until when
+type cooldowns struct {
+	mu      sync.Mutex
+	periods map[string]time.Duration
+	until   map[string]time.Time
+}
+
+// SetTrackerCooldown sets how long a tracker is left alone once it's down
+// for maintenance or rate limiting, so its feeds and items don't all fail
+// one after another. Zero only stops the poll that ran into it.
+func (p *Pipeline) SetTrackerCooldown(tracker string, period time.Duration) {
+	p.cooldowns.mu.Lock()
+	defer p.cooldowns.mu.Unlock()
+
+	if p.cooldowns.periods == nil {
+		p.cooldowns.periods = make(map[string]time.Duration)
+	}
+	p.cooldowns.periods[tracker] = period
+}
+
+// coolDown starts a tracker's cool-down after err, lasting at least as long
+// as the tracker asked with Retry-After
+func (c *cooldowns) coolDown(tracker string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	period := c.periods[tracker]
+	if period <= 0 {
+		return
+	}
+	var down *maintenance.Error
+	if errors.As(err, &down) {
+		period = max(period, down.RetryAfter)
+	}
+	if c.until == nil {
+		c.until = make(map[string]time.Time)
+	}
+	if until := time.Now().Add(period); until.After(c.until[tracker]) {
+		c.until[tracker] = until
+	}
+}
+
+// coolingDown returns until when a tracker is left alone, if it is
+func (c *cooldowns) coolingDown(tracker string) (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	until, ok := c.until[tracker]
+	if !ok || time.Now().After(until) {
+		delete(c.until, tracker)
+		return time.Time{}, false
+	}
+	return until, true
+}
+
+// trackerDown reports whether err means the whole tracker is turning
+// requests away rather than just this request failing
+func trackerDown(err error) bool {
+	var down *maintenance.Error
+	return errors.Is(err, downloader.ErrRateLimited) || errors.Is(err, downloader.ErrUnavailable) || errors.As(err, &down)
+}
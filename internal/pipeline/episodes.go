@@ -0,0 +1,155 @@
+package pipeline
+
+import (
+	"fmt"
+	"sort"
+
+	"torrent-rss/internal/config"
+	"torrent-rss/internal/episode"
+	"torrent-rss/internal/history"
+	"torrent-rss/internal/models"
+	"torrent-rss/internal/quality"
+	"torrent-rss/internal/release"
+)
+
+// checkEpisodes decides whether an episode, multi-episode release or season
+// pack is still wanted given what was grabbed before. It returns why not, or
+// the record of the release it upgrades or fixes, nil if it's new.
+func (p *Pipeline) checkEpisodes(feed config.Feed, info episode.Info, title string, candidate quality.Quality) (*history.EpisodeRecord, string, error) {
+	upgrades := func(record *history.EpisodeRecord) bool {
+		if feed.Quality != nil && feed.Quality.IsUpgrade(quality.Parse(record.Quality), candidate) {
+			return true
+		}
+		return feed.ReplacePropers && fixes(title, record)
+	}
+
+	if info.Pack {
+		previous, err := p.history.Episode(info.SeasonKey())
+		if err != nil {
+			return nil, "", err
+		}
+		if previous != nil {
+			if !upgrades(previous) {
+				return nil, info.Code() + " already grabbed", nil
+			}
+			return previous, "", nil
+		}
+		if feed.SeasonPacks == config.PacksMissing {
+			missing, err := p.missingEpisodes(info)
+			if err != nil {
+				return nil, "", err
+			}
+			if missing >= 0 && missing < feed.PackMinMissing {
+				return nil, fmt.Sprintf("only %d of %s missing", missing, info.Code()), nil
+			}
+		}
+		return nil, "", nil
+	}
+
+	// Episodes of a season whose pack was grabbed count as grabbed with it
+	var pack *history.EpisodeRecord
+	if info.Date.IsZero() {
+		var err error
+		if pack, err = p.history.Episode(info.SeasonKey()); err != nil {
+			return nil, "", err
+		}
+	}
+
+	// A multi-episode release is wanted when any of its episodes is
+	var upgraded *history.EpisodeRecord
+	for _, single := range info.Episodes() {
+		record, err := p.history.Episode(single.Key())
+		if err != nil {
+			return nil, "", err
+		}
+		switch {
+		case record == nil && pack == nil:
+			return nil, "", nil
+		case record == nil:
+			// Upgrading on a pack leaves the rest of the season in place
+			if upgrades(pack) {
+				return nil, "", nil
+			}
+		case upgrades(record):
+			if upgraded == nil {
+				upgraded = record
+			}
+		}
+	}
+	if upgraded != nil {
+		return upgraded, "", nil
+	}
+	if pack != nil {
+		return nil, fmt.Sprintf("%s already grabbed in %q", info.Code(), pack.Title), nil
+	}
+	return nil, info.Code() + " already grabbed", nil
+}
+
+// fixes reports whether title is a PROPER, REPACK or RERIP of the grabbed
+// release, i.e. one of the same quality that isn't a fix itself. The fix may
+// come from another group than the original.
+func fixes(title string, record *history.EpisodeRecord) bool {
+	if !release.Parse(title).Proper || release.Parse(record.Title).Proper {
+		return false
+	}
+	return quality.Parse(title).String() == record.Quality
+}
+
+// missingEpisodes counts the episodes of a pack's season missing before the
+// last one grabbed. It returns -1 when none of the season was grabbed, as
+// then it's unknown how many episodes it has.
+func (p *Pipeline) missingEpisodes(info episode.Info) (int, error) {
+	records, err := p.history.SeasonEpisodes(info.SeasonKey())
+	if err != nil {
+		return 0, err
+	}
+	grabbed := make(map[int]bool)
+	last := 0
+	for _, record := range records {
+		single, ok := episode.Parse(record.Episode)
+		if !ok || single.Pack {
+			continue
+		}
+		grabbed[single.Episode] = true
+		last = max(last, single.Episode)
+	}
+	if last == 0 {
+		return -1, nil
+	}
+	return last - len(grabbed), nil
+}
+
+// recordEpisodes remembers every episode of a grabbed release, or its season
+// for a pack
+func (p *Pipeline) recordEpisodes(info episode.Info, item models.Item, releaseQuality quality.Quality, infoHash string) error {
+	records := []episode.Info{info}
+	if !info.Pack {
+		records = info.Episodes()
+	}
+	for _, single := range records {
+		err := p.history.PutEpisode(history.EpisodeRecord{
+			Key:      single.Key(),
+			Show:     single.Show,
+			Episode:  single.Code(),
+			Title:    item.Title,
+			Quality:  releaseQuality.String(),
+			InfoHash: infoHash,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// packsFirst moves season packs ahead of the other items, keeping the order
+// otherwise, so single episodes they cover are skipped in the same poll
+func packsFirst(items []models.Item) []models.Item {
+	sorted := append([]models.Item(nil), items...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, _ := episode.Parse(sorted[i].Title)
+		b, _ := episode.Parse(sorted[j].Title)
+		return a.Pack && !b.Pack
+	})
+	return sorted
+}
@@ -0,0 +1,63 @@
+package pipeline
+
+import (
+	"fmt"
+	"time"
+
+	"torrent-rss/internal/config"
+	"torrent-rss/internal/history"
+)
+
+// checkHealth reports a feed that went FailingAfter without a poll that
+// succeeded, or StaleAfter without a poll that returned items. It's
+// reported once, and again only after it recovered and went quiet anew;
+// the alert is kept in the feed's stats, so a restart doesn't repeat it.
+// Feeds that never had either count from when the pipeline was created.
+func (p *Pipeline) checkHealth(feed config.Feed) {
+	if feed.StaleAfter == 0 && feed.FailingAfter == 0 {
+		return
+	}
+	stats, err := p.history.FeedStats(feed.Name)
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	lastPoll := latest(stats.LastPoll, p.started)
+	lastItem := latest(stats.LastItem, p.started)
+	var cause error
+	switch {
+	case feed.FailingAfter > 0 && now.Sub(lastPoll) >= feed.FailingAfter && !alerted(stats, stats.LastPoll):
+		cause = fmt.Errorf("no poll of %s succeeded %s", feed.Name, since(stats.LastPoll, lastPoll))
+	case feed.StaleAfter > 0 && now.Sub(lastItem) >= feed.StaleAfter && !alerted(stats, stats.LastItem):
+		cause = fmt.Errorf("%s returned no items %s", feed.Name, since(stats.LastItem, lastItem))
+	default:
+		return
+	}
+
+	p.stats.update(feed.Name, func(s *history.FeedStats) { s.StaleAlert = now })
+	p.flushStats()
+	p.onEvent(Event{Kind: EventStale, Feed: feed.Name, Err: cause})
+}
+
+// alerted tells whether the feed was reported since it last recovered,
+// which may have been before the pipeline was created
+func alerted(stats history.FeedStats, recovered time.Time) bool {
+	return !stats.StaleAlert.IsZero() && !stats.StaleAlert.Before(recovered)
+}
+
+func latest(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// since describes how long it has been since last, or since counting
+// started when there was no last time
+func since(last, from time.Time) string {
+	if last.IsZero() {
+		return "in " + time.Since(from).Round(time.Minute).String()
+	}
+	return "since " + last.Local().Format("Jan 2 15:04")
+}
@@ -0,0 +1,361 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"torrent-rss/internal/config"
+	"torrent-rss/internal/delivery"
+	"torrent-rss/internal/downloader"
+	"torrent-rss/internal/filter"
+	"torrent-rss/internal/history"
+	"torrent-rss/internal/parser"
+	"torrent-rss/internal/retry"
+	"torrent-rss/internal/testserver"
+	"torrent-rss/internal/tracker"
+	"torrent-rss/internal/watchlist"
+)
+
+// harness is a pipeline polling a fake tracker into a temporary folder
+type harness struct {
+	srv     *testserver.Server
+	pipe    *Pipeline
+	tracker tracker.Tracker
+	feed    config.Feed
+	dir     string
+
+	mu     sync.Mutex
+	events []Event
+}
+
+func newHarness(t *testing.T, opts testserver.Options, cookie string, torrents ...testserver.Torrent) *harness {
+	t.Helper()
+	h := &harness{srv: testserver.New(opts, torrents...), dir: t.TempDir()}
+	t.Cleanup(h.srv.Close)
+	if cookie == "" {
+		cookie = h.srv.Cookie()
+	}
+
+	store, err := history.Open("json", filepath.Join(t.TempDir(), "history.json"), nil)
+	if err != nil {
+		t.Fatalf("history.Open: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	noRetry := retry.Policy{Attempts: 1}
+	h.pipe = New(parser.NewParser(parser.Options{Retry: noRetry}), store, func(e Event) {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.events = append(h.events, e)
+	})
+
+	tr, err := tracker.New("torrentday", tracker.Options{BaseURL: h.srv.URL, Cookie: cookie})
+	if err != nil {
+		t.Fatalf("tracker.New: %v", err)
+	}
+	d, err := downloader.NewDownloader(tr, downloader.Options{Retry: noRetry, PartialDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewDownloader: %v", err)
+	}
+	h.pipe.AddTracker("torrentday", d)
+	h.tracker = tr
+	h.pipe.UseFolder(delivery.NewFolder(h.dir))
+
+	include, err := filter.New(nil, nil)
+	if err != nil {
+		t.Fatalf("filter.New: %v", err)
+	}
+	h.feed = config.Feed{
+		Name:        "tv",
+		URL:         h.srv.RSSURL(),
+		Tracker:     "torrentday",
+		Filter:      include,
+		MaxFailures: config.DefaultMaxFailures,
+	}
+	return h
+}
+
+// scrapeAhead has the tracker's pages scraped by workers at once
+func (h *harness) scrapeAhead(t *testing.T, workers int) {
+	t.Helper()
+	d, err := downloader.NewDownloader(h.tracker, downloader.Options{Retry: retry.Policy{Attempts: 1}, PartialDir: t.TempDir(), PageWorkers: workers})
+	if err != nil {
+		t.Fatalf("NewDownloader: %v", err)
+	}
+	h.pipe.AddTracker("torrentday", d)
+}
+
+func (h *harness) kinds(kind EventKind) []Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var found []Event
+	for _, e := range h.events {
+		if e.Kind == kind {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+func (h *harness) saved(t *testing.T) []string {
+	t.Helper()
+	entries, err := os.ReadDir(h.dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestIntegrationScrapesTorrentPage(t *testing.T) {
+	h := newHarness(t, testserver.Options{}, "",
+		testserver.Torrent{ID: "101", Title: "Show.Name.S01E01.1080p.WEB-DL-GROUP"},
+		testserver.Torrent{ID: "102", Title: "Other.Show.S02E03.720p.HDTV-GROUP"},
+	)
+
+	if _, err := h.pipe.Run(context.Background(), h.feed); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if got := len(h.kinds(EventDownloaded)); got != 2 {
+		t.Fatalf("downloaded %d torrents, want 2 (failures: %v)", got, h.kinds(EventFailed))
+	}
+	if got := len(h.saved(t)); got != 2 {
+		t.Errorf("saved %d files, want 2", got)
+	}
+	if h.srv.Downloads("101") != 1 || h.srv.Downloads("102") != 1 {
+		t.Errorf("downloads = %d, %d, want 1 each", h.srv.Downloads("101"), h.srv.Downloads("102"))
+	}
+
+	// The history keeps the next poll from grabbing them again
+	if _, err := h.pipe.Run(context.Background(), h.feed); err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+	if h.srv.Downloads("101") != 1 {
+		t.Errorf("second poll downloaded again: %d", h.srv.Downloads("101"))
+	}
+}
+
+func TestIntegrationDownloadsEnclosure(t *testing.T) {
+	h := newHarness(t, testserver.Options{Enclosures: true}, "",
+		testserver.Torrent{ID: "201", Title: "Movie.Name.2024.1080p.BluRay-GROUP"},
+	)
+
+	if _, err := h.pipe.Run(context.Background(), h.feed); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	downloaded := h.kinds(EventDownloaded)
+	if len(downloaded) != 1 {
+		t.Fatalf("downloaded %d torrents, want 1 (failures: %v)", len(downloaded), h.kinds(EventFailed))
+	}
+	if downloaded[0].InfoHash == "" {
+		t.Error("downloaded torrent has no infohash")
+	}
+	if h.srv.Downloads("201") != 1 {
+		t.Errorf("downloads = %d, want 1", h.srv.Downloads("201"))
+	}
+}
+
+func TestIntegrationExpiredCookieRedirectsToLogin(t *testing.T) {
+	h := newHarness(t, testserver.Options{}, "uid=1234; pass=expired",
+		testserver.Torrent{ID: "301", Title: "Show.Name.S01E02.1080p.WEB-DL-GROUP"},
+	)
+
+	// An expired session stops the poll rather than failing every item
+	_, err := h.pipe.Run(context.Background(), h.feed)
+	if !errors.Is(err, downloader.ErrAuthExpired) {
+		t.Errorf("Run = %v, want ErrAuthExpired", err)
+	}
+	if h.srv.Downloads("301") != 0 || len(h.kinds(EventDownloaded)) != 0 || len(h.saved(t)) != 0 {
+		t.Error("a torrent was saved without a valid session")
+	}
+}
+
+func TestIntegrationMaintenance(t *testing.T) {
+	h := newHarness(t, testserver.Options{}, "",
+		testserver.Torrent{ID: "401", Title: "Show.Name.S01E03.1080p.WEB-DL-GROUP"},
+	)
+	h.pipe.SetTrackerCooldown("torrentday", time.Hour)
+	h.srv.SetMaintenance(true)
+
+	_, err := h.pipe.Run(context.Background(), h.feed)
+	if err == nil {
+		t.Fatal("Run succeeded against a tracker down for maintenance")
+	}
+	if len(h.kinds(EventDownloaded)) != 0 {
+		t.Error("downloaded during maintenance")
+	}
+
+	// The tracker is left alone for a while rather than polled again
+	h.srv.SetMaintenance(false)
+	if _, err := h.pipe.Run(context.Background(), h.feed); !errors.Is(err, ErrCoolingDown) {
+		t.Errorf("poll right after maintenance = %v, want ErrCoolingDown", err)
+	}
+}
+
+func TestIntegrationScrapesAheadPaced(t *testing.T) {
+	h := newHarness(t, testserver.Options{}, "",
+		testserver.Torrent{ID: "501", Title: "Show.Name.S01E01.1080p.WEB-DL-GROUP"},
+	)
+	if _, err := h.pipe.Run(context.Background(), h.feed); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	h.srv.Add(testserver.Torrent{ID: "502", Title: "Show.Name.S01E02.1080p.WEB-DL-GROUP"})
+	h.srv.Add(testserver.Torrent{ID: "503", Title: "Show.Name.S01E03.1080p.WEB-DL-GROUP"})
+	// The same release as the one grabbed, under another link
+	h.srv.Add(testserver.Torrent{ID: "504", Title: "Show.Name.S01E01.1080p.WEB-DL-GROUP"})
+
+	h.scrapeAhead(t, 4)
+	const delay = 40 * time.Millisecond
+	h.pipe.SetTrackerGrabDelay("torrentday", config.Delay{Min: delay, Max: delay})
+	started := time.Now()
+	if _, err := h.pipe.Run(context.Background(), h.feed); err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+	elapsed := time.Since(started)
+
+	if got := len(h.kinds(EventDownloaded)); got != 3 {
+		t.Fatalf("downloaded %d torrents, want 3 (failures: %v)", got, h.kinds(EventFailed))
+	}
+	// Duplicates aren't scraped ahead
+	if h.srv.Pages("501") != 1 || h.srv.Pages("504") != 0 {
+		t.Errorf("pages of duplicates viewed %d, %d times, want 1, 0", h.srv.Pages("501"), h.srv.Pages("504"))
+	}
+	if h.srv.Pages("502") != 1 || h.srv.Pages("503") != 1 {
+		t.Errorf("pages viewed %d, %d times, want 1 each", h.srv.Pages("502"), h.srv.Pages("503"))
+	}
+	// Two pages and two downloads, each after the one before by the delay
+	if elapsed < 3*delay {
+		t.Errorf("poll took %v, pages weren't paced with the downloads", elapsed)
+	}
+}
+
+func TestIntegrationReloadKeepsCooldown(t *testing.T) {
+	h := newHarness(t, testserver.Options{}, "",
+		testserver.Torrent{ID: "601", Title: "Show.Name.S01E04.1080p.WEB-DL-GROUP"},
+	)
+	h.pipe.SetTrackerCooldown("torrentday", time.Hour)
+	h.srv.SetMaintenance(true)
+	if _, err := h.pipe.Run(context.Background(), h.feed); err == nil {
+		t.Fatal("Run succeeded against a tracker down for maintenance")
+	}
+	h.srv.SetMaintenance(false)
+
+	// The pipeline a reload builds leaves the tracker alone as well
+	reloaded := New(h.pipe.parser, h.pipe.history, nil)
+	reloaded.TakeOver(h.pipe)
+	reloaded.AddTracker("torrentday", h.pipe.downloaders["torrentday"])
+	reloaded.SetTrackerCooldown("torrentday", time.Hour)
+	reloaded.UseFolder(delivery.NewFolder(h.dir))
+	if _, err := reloaded.Run(context.Background(), h.feed); !errors.Is(err, ErrCoolingDown) {
+		t.Errorf("poll after the reload = %v, want ErrCoolingDown", err)
+	}
+	if h.srv.Downloads("601") != 0 {
+		t.Error("the reloaded pipeline downloaded from a tracker cooling down")
+	}
+}
+
+func TestIntegrationTrackerPriority(t *testing.T) {
+	h := newHarness(t, testserver.Options{}, "",
+		testserver.Torrent{ID: "701", Title: "Show.Name.S01E05.1080p.WEB-DL-GROUP"},
+	)
+	// The most preferred tracker has lost its session
+	expired, err := tracker.New("torrentday", tracker.Options{BaseURL: h.srv.URL, Cookie: "uid=1; pass=expired"})
+	if err != nil {
+		t.Fatalf("tracker.New: %v", err)
+	}
+	d, err := downloader.NewDownloader(expired, downloader.Options{Retry: retry.Policy{Attempts: 1}, PartialDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewDownloader: %v", err)
+	}
+	h.pipe.AddTracker("preferred", d)
+	h.pipe.AddTracker("backup", h.pipe.downloaders["torrentday"])
+	h.pipe.SetTrackerPriority([]string{"preferred", "torrentday", "backup"})
+	feedOf := func(tracker string) config.Feed {
+		feed := h.feed
+		feed.Name, feed.Tracker = tracker+"-tv", tracker
+		return feed
+	}
+
+	if _, err := h.pipe.Run(context.Background(), feedOf("preferred")); !errors.Is(err, downloader.ErrAuthExpired) {
+		t.Fatalf("Run = %v, want ErrAuthExpired", err)
+	}
+	// A release the preferred tracker failed to grab is still grabbed
+	if _, err := h.pipe.Run(context.Background(), h.feed); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if got := len(h.kinds(EventDownloaded)); got != 1 {
+		t.Fatalf("downloaded %d torrents, want 1", got)
+	}
+
+	// Trackers behind the one it was grabbed from skip it
+	if _, err := h.pipe.Run(context.Background(), feedOf("backup")); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	skipped := h.kinds(EventSkipped)
+	if len(skipped) != 1 || skipped[0].Reason != "grabbed from preferred tracker torrentday" {
+		t.Errorf("skipped %+v, want the release left to torrentday", skipped)
+	}
+}
+
+func TestIntegrationFailedSearchKeepsPoll(t *testing.T) {
+	h := newHarness(t, testserver.Options{}, "",
+		testserver.Torrent{ID: "801", Title: "Show.Name.S01E06.1080p.WEB-DL-GROUP"},
+	)
+	list, err := watchlist.Open(filepath.Join(t.TempDir(), "watchlist.json"))
+	if err != nil {
+		t.Fatalf("watchlist.Open: %v", err)
+	}
+	if _, err := list.Add("Show Name", 0, nil); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	h.pipe.UseWatchlist(list)
+	h.feed.Watchlist = true
+	h.feed.WatchlistSearch = time.Hour
+
+	// The fake tracker has no search, so only the feed is read
+	if _, err := h.pipe.Run(context.Background(), h.feed); err != nil {
+		t.Fatalf("Run = %v, want the poll to succeed", err)
+	}
+	if got := len(h.kinds(EventDownloaded)); got != 1 {
+		t.Errorf("downloaded %d torrents, want 1", got)
+	}
+	failed := h.kinds(EventFailed)
+	if len(failed) != 1 || failed[0].Reason != "watch-list search" {
+		t.Errorf("failures %+v, want the search", failed)
+	}
+}
+
+func TestIntegrationStaleAlertSurvivesRestart(t *testing.T) {
+	h := newHarness(t, testserver.Options{}, "")
+	h.feed.FailingAfter = time.Millisecond
+	// Before the restart the feed was failing and reported
+	now := time.Now()
+	err := h.pipe.history.AddStats(history.FeedStats{Feed: h.feed.Name, LastPoll: now.Add(-2 * time.Hour), StaleAlert: now.Add(-time.Hour)})
+	if err != nil {
+		t.Fatalf("AddStats: %v", err)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	h.pipe.checkHealth(h.feed)
+	if got := len(h.kinds(EventStale)); got != 0 {
+		t.Fatalf("reported %d times after the restart, want 0", got)
+	}
+
+	// Once it recovered it's reported again
+	if err := h.pipe.history.AddStats(history.FeedStats{Feed: h.feed.Name, LastPoll: time.Now()}); err != nil {
+		t.Fatalf("AddStats: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	h.pipe.checkHealth(h.feed)
+	if got := len(h.kinds(EventStale)); got != 1 {
+		t.Errorf("reported %d times after it recovered and failed again, want 1", got)
+	}
+}
@@ -0,0 +1,43 @@
+package pipeline
+
+import (
+	"context"
+	"time"
+
+	"torrent-rss/internal/metadata"
+)
+
+// lookupTimeout bounds a lookup, retries included, so a slow provider
+// doesn't hold up polls
+const lookupTimeout = 5 * time.Second
+
+// UseMetadata sets the provider releases are looked up on, to match them
+// against the watch-list by ID and to record what show or movie they are.
+// Failed lookups are reported to logf, which may be nil.
+func (p *Pipeline) UseMetadata(m metadata.Provider, logf func(format string, args ...any)) {
+	p.metadata = metadata.Cached(m, metadata.DefaultCacheTTL)
+	p.metadataLogf = logf
+}
+
+// lookup finds the show or movie a release is of. Metadata is a nicety, so
+// a provider that's down or doesn't know the title gives nil rather than
+// failing the item.
+func (p *Pipeline) lookup(ctx context.Context, name string) *metadata.Info {
+	if p.metadata == nil {
+		return nil
+	}
+	query := metadata.ForRelease(name)
+	if query.Title == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, lookupTimeout)
+	defer cancel()
+	info, err := p.metadata.Lookup(ctx, query)
+	if err != nil {
+		if p.metadataLogf != nil {
+			p.metadataLogf("Metadata lookup of %q failed, going on without: %v", query.Title, err)
+		}
+		return nil
+	}
+	return info
+}
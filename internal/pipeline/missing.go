@@ -0,0 +1,172 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"torrent-rss/internal/config"
+	"torrent-rss/internal/episode"
+	"torrent-rss/internal/history"
+	"torrent-rss/internal/watchlist"
+)
+
+// Missing is a show's episodes that the episode tracker skipped over
+type Missing struct {
+	Show string `json:"show"`
+	// Episodes are codes like S01E03, or S02 for a season none of was
+	// grabbed. Empty when nothing is missing or nothing was grabbed.
+	Episodes []string `json:"episodes"`
+	Last     string   `json:"last,omitempty"` // Latest episode grabbed, empty if none was
+}
+
+// season is what was grabbed of one season of a show
+type season struct {
+	grabbed map[int]bool
+	last    int
+	pack    bool
+}
+
+// Missing reports the gaps in the episodes grabbed of each show: those
+// before the last one grabbed of a season, from its first, and seasons
+// between the first and the last one grabbed. Packs cover their season and
+// daily shows have no gaps to tell. With a watch-list, only its shows are
+// reported, including those nothing was grabbed of yet. Shows are ordered
+// by name.
+func (p *Pipeline) Missing() ([]Missing, error) {
+	records, err := p.history.Episodes()
+	if err != nil {
+		return nil, err
+	}
+	var entries []watchlist.Entry
+	if p.watchlist != nil {
+		if entries, err = p.watchlist.Entries(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Records are ordered by key, so a show's are together
+	var shows [][]history.EpisodeRecord
+	prev := ""
+	for _, record := range records {
+		show, _, _ := strings.Cut(record.Key, "|")
+		if len(shows) == 0 || show != prev {
+			shows = append(shows, nil)
+		}
+		shows[len(shows)-1] = append(shows[len(shows)-1], record)
+		prev = show
+	}
+
+	var report []Missing
+	followed := make(map[string]bool)
+	for _, show := range shows {
+		if len(entries) > 0 {
+			entry, err := p.watchlist.Match(show[0].Title)
+			if err != nil {
+				return nil, err
+			}
+			if entry == nil {
+				continue
+			}
+			followed[entry.String()] = true
+		}
+		report = append(report, showGaps(show))
+	}
+	for _, entry := range entries {
+		if !followed[entry.String()] {
+			report = append(report, Missing{Show: entry.String(), Episodes: []string{}})
+		}
+	}
+	sort.SliceStable(report, func(i, j int) bool {
+		return episode.NormalizeShow(report[i].Show) < episode.NormalizeShow(report[j].Show)
+	})
+	return report, nil
+}
+
+// showGaps finds the gaps in the episodes grabbed of a show
+func showGaps(records []history.EpisodeRecord) Missing {
+	m := Missing{Show: records[len(records)-1].Show, Episodes: []string{}}
+	seasons := make(map[int]*season)
+	var absolute *season
+	var lastInfo episode.Info
+	for _, record := range records {
+		info, ok := episode.Parse(record.Episode)
+		if !ok || !info.Date.IsZero() {
+			continue
+		}
+		if info.Season > lastInfo.Season || (info.Season == lastInfo.Season && info.Episode > lastInfo.Episode) {
+			lastInfo = info
+		}
+
+		var s *season
+		if info.Absolute {
+			if absolute == nil {
+				absolute = &season{grabbed: make(map[int]bool)}
+			}
+			s = absolute
+		} else {
+			if seasons[info.Season] == nil {
+				seasons[info.Season] = &season{grabbed: make(map[int]bool)}
+			}
+			s = seasons[info.Season]
+		}
+		if info.Pack {
+			s.pack = true
+			continue
+		}
+		s.grabbed[info.Episode] = true
+		s.last = max(s.last, info.Episode)
+	}
+	if lastInfo.Season > 0 || lastInfo.Episode > 0 {
+		m.Last = lastInfo.Code()
+	}
+
+	if absolute != nil && !absolute.pack {
+		for n := 1; n < absolute.last; n++ {
+			if !absolute.grabbed[n] {
+				m.Episodes = append(m.Episodes, episode.Info{Episode: n, Absolute: true}.Code())
+			}
+		}
+	}
+	numbers := make([]int, 0, len(seasons))
+	for n := range seasons {
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+	for i, n := range numbers {
+		// Seasons in between that nothing was grabbed of
+		if i > 0 {
+			for gap := numbers[i-1] + 1; gap < n; gap++ {
+				m.Episodes = append(m.Episodes, episode.Info{Season: gap, Pack: true}.Code())
+			}
+		}
+		s := seasons[n]
+		if s.pack {
+			continue
+		}
+		for e := 1; e < s.last; e++ {
+			if !s.grabbed[e] {
+				m.Episodes = append(m.Episodes, episode.Info{Season: n, Episode: e}.Code())
+			}
+		}
+	}
+	return m
+}
+
+// SearchMissing searches the feed's tracker for each episode and season
+// Missing reports, and grabs what the feed's filters match like a poll
+// would. It returns how many results the searches found.
+func (p *Pipeline) SearchMissing(ctx context.Context, feed config.Feed) (int, error) {
+	report, err := p.Missing()
+	if err != nil {
+		return 0, fmt.Errorf("feed %s: %w", feed.Name, err)
+	}
+	var queries []string
+	for _, show := range report {
+		for _, code := range show.Episodes {
+			queries = append(queries, show.Show+" "+code)
+		}
+	}
+	return p.searchNow(ctx, feed, queries)
+}
@@ -0,0 +1,65 @@
+package pipeline
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"torrent-rss/internal/config"
+)
+
+// pacing spaces out the torrent downloads of each tracker, see
+// SetTrackerGrabDelay
+type pacing struct {
+	mu     sync.Mutex
+	delays map[string]config.Delay
+	next   map[string]time.Time // Earliest start of a tracker's next download
+}
+
+// SetTrackerGrabDelay makes downloads from a tracker start at least delay
+// apart, a new random length within its range each time, however many of
+// the tracker's feeds are polled at once
+func (p *Pipeline) SetTrackerGrabDelay(tracker string, delay config.Delay) {
+	p.pacing.mu.Lock()
+	defer p.pacing.mu.Unlock()
+
+	if p.pacing.delays == nil {
+		p.pacing.delays = make(map[string]config.Delay)
+		p.pacing.next = make(map[string]time.Time)
+	}
+	p.pacing.delays[tracker] = delay
+}
+
+// wait blocks until the tracker's turn for another download comes, or ctx
+// is done. Each call takes the next turn, so concurrent polls queue up.
+func (c *pacing) wait(ctx context.Context, tracker string) error {
+	c.mu.Lock()
+	delay := c.delays[tracker]
+	if delay.IsZero() {
+		c.mu.Unlock()
+		return nil
+	}
+	start := time.Now()
+	if next := c.next[tracker]; next.After(start) {
+		start = next
+	}
+	c.next[tracker] = start.Add(randomDelay(delay))
+	c.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(start))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func randomDelay(d config.Delay) time.Duration {
+	if d.Max <= d.Min {
+		return d.Min
+	}
+	return d.Min + time.Duration(rand.Int64N(int64(d.Max-d.Min)+1))
+}
@@ -0,0 +1,878 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"torrent-rss/internal/bytesize"
+	"torrent-rss/internal/client"
+	"torrent-rss/internal/config"
+	"torrent-rss/internal/delivery"
+	"torrent-rss/internal/downloader"
+	"torrent-rss/internal/episode"
+	"torrent-rss/internal/history"
+	"torrent-rss/internal/magnet"
+	"torrent-rss/internal/metadata"
+	"torrent-rss/internal/models"
+	"torrent-rss/internal/parser"
+	"torrent-rss/internal/quality"
+	"torrent-rss/internal/release"
+	"torrent-rss/internal/tracker"
+	"torrent-rss/internal/watchlist"
+)
+
+// EventKind identifies what happened to a feed item
+type EventKind int
+
+const (
+	EventMatch EventKind = iota
+	EventDownloaded
+	EventFailed
+	EventSkipped  // Already in download history
+	EventFiltered // Rejected by the feed's filter, see Reason
+	EventUpgraded // Replaced an earlier release of the same episode
+	EventPaused   // A feed's destination is low on space, see Err
+	EventResumed  // Space was freed up again
+	EventPending  // Held for approval, Reason is the pending ID
+	EventStale    // A feed went without items or successful polls, see Err
+	EventCompleted
+)
+
+var eventNames = [...]string{"match", "downloaded", "failed", "skipped", "filtered", "upgraded", "paused", "resumed", "pending", "stale", "completed"}
+
+// String names the kind, e.g. "downloaded", as scripts see it
+func (k EventKind) String() string {
+	if k >= 0 && int(k) < len(eventNames) {
+		return eventNames[k]
+	}
+	return fmt.Sprintf("EventKind(%d)", int(k))
+}
+
+// Event reports progress on a single item so callers can render or forward it
+type Event struct {
+	Kind     EventKind
+	Feed     string
+	Item     models.Item
+	Err      error
+	Reason   string
+	InfoHash string // Set once the torrent has been fetched
+	Size     int64  // Content size in bytes, when known
+	// Media is the show or movie of the release, when a metadata provider
+	// is set and knows it
+	Media *metadata.Info
+}
+
+// Pipeline fetches a feed, picks matching items and downloads them
+type Pipeline struct {
+	parser      *parser.Parser
+	history     *history.Store
+	onEvent     func(Event)
+	downloaders map[string]*downloader.Downloader
+	clients     map[string]clientTarget
+	deliveries  map[string]delivery.Delivery
+	folder      delivery.Delivery // For feeds with neither a client nor a delivery
+	watchlist   *watchlist.List
+	metadata    metadata.Provider
+	priority    map[string]int // Tracker to rank, see SetTrackerPriority
+	space       spaceGuard
+	stats       tally
+	// contentTypes tell releases apart, see SetContentTypes
+	contentTypes []config.ContentType
+	// metadataLogf reports failed lookups, see UseMetadata
+	metadataLogf func(format string, args ...any)
+	*shared
+}
+
+// shared is the state of a pipeline that the one replacing it on a config
+// reload takes over, see TakeOver
+type shared struct {
+	locks     keyLocks
+	cooldowns cooldowns
+	pacing    pacing
+	started   time.Time // Feeds that never polled successfully count from here
+}
+
+// clientTarget is a torrent client together with its delivery, which knows
+// where the client should put torrents
+type clientTarget struct {
+	client   client.Client
+	delivery *delivery.Client
+}
+
+func New(p *parser.Parser, h *history.Store, onEvent func(Event)) *Pipeline {
+	if onEvent == nil {
+		onEvent = func(Event) {}
+	}
+	pipe := &Pipeline{
+		parser:      p,
+		history:     h,
+		downloaders: make(map[string]*downloader.Downloader),
+		clients:     make(map[string]clientTarget),
+		deliveries:  make(map[string]delivery.Delivery),
+		shared:      &shared{started: time.Now()},
+	}
+	// Every event also counts towards the feed's stats
+	pipe.onEvent = func(e Event) {
+		pipe.stats.count(e)
+		onEvent(e)
+	}
+	return pipe
+}
+
+// TakeOver makes p share the state of the pipeline it replaces: the locks
+// of items being grabbed, trackers cooling down and the pacing of their
+// downloads, so polls of old still running and new ones starting don't grab
+// the same item or hit a tracker old is leaving alone. Call it before
+// setting up trackers, whose cool-downs and grab delays then apply to both.
+func (p *Pipeline) TakeOver(old *Pipeline) {
+	p.shared = old.shared
+}
+
+// AddTracker registers the downloader used by feeds of the named tracker
+func (p *Pipeline) AddTracker(name string, d *downloader.Downloader) {
+	p.downloaders[name] = d
+}
+
+// AddClient registers a torrent client that feeds can send torrents to
+// instead of the download directory
+func (p *Pipeline) AddClient(name string, c client.Client, opts client.AddOptions) {
+	p.clients[name] = clientTarget{client: c, delivery: delivery.NewClient(c, opts)}
+}
+
+// AddDelivery registers a delivery that feeds can pick by name
+func (p *Pipeline) AddDelivery(name string, d delivery.Delivery) {
+	p.deliveries[name] = d
+}
+
+// UseFolder sets where feeds with neither a client nor a delivery save their
+// torrents, usually the download directory
+func (p *Pipeline) UseFolder(d delivery.Delivery) {
+	p.folder = d
+}
+
+// UseWatchlist sets the watch-list that feeds with Watchlist set match against
+func (p *Pipeline) UseWatchlist(l *watchlist.List) {
+	p.watchlist = l
+}
+
+// Run polls a feed once and returns the number of matched items. An unchanged
+// feed returns parser.ErrNotModified.
+func (p *Pipeline) Run(ctx context.Context, feed config.Feed) (int, error) {
+	if err := p.checkFeed(feed); err != nil {
+		return 0, err
+	}
+	// Checked after the stats of this poll are flushed, whatever its outcome
+	defer p.checkHealth(feed)
+	if until, ok := p.cooldowns.coolingDown(feed.Tracker); ok {
+		return 0, fmt.Errorf("feed %s: %w, %s is left alone until %s", feed.Name, ErrCoolingDown, feed.Tracker, until.Format("15:04"))
+	}
+	defer p.flushStats()
+
+	// Items a poll cut short by a crash or shutdown didn't get to come first
+	polled := make(map[string]bool)
+	if err := p.resumeQueued(ctx, feed, polled); err != nil {
+		return 0, fmt.Errorf("feed %s: %w", feed.Name, err)
+	}
+
+	var matches []models.Item
+	var err error
+	if feed.Torznab != nil {
+		matches, err = p.parser.FetchTorznab(ctx, feed.URL, *feed.Torznab, feed.SearchTerms)
+	} else {
+		matches, err = p.parser.FetchAndParse(ctx, feed.URL, feed.JSONAPI, feed.SearchTerms)
+	}
+	if errors.Is(err, parser.ErrNotModified) {
+		p.stats.update(feed.Name, func(s *history.FeedStats) { s.LastPoll = time.Now() })
+	}
+	if err != nil {
+		if trackerDown(err) {
+			p.cooldowns.coolDown(feed.Tracker, err)
+		}
+		return 0, fmt.Errorf("feed %s: %w", feed.Name, err)
+	}
+	p.stats.update(feed.Name, func(s *history.FeedStats) {
+		s.Seen += int64(len(matches))
+		if len(matches) > 0 {
+			s.LastItem = time.Now()
+		}
+	})
+
+	matches = rank(feed, matches)
+
+	var picked []models.Item
+	var keys []string
+	for _, item := range matches {
+		if ok, rule := matchBacklog(feed, item, len(picked)); !ok {
+			p.onEvent(Event{Kind: EventFiltered, Feed: feed.Name, Item: item, Reason: rule})
+			continue
+		}
+		picked = append(picked, item)
+		keys = append(keys, historyKey(feed, item))
+	}
+	if err := p.processQueued(ctx, feed, keys, picked, polled); err != nil {
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		return 0, fmt.Errorf("feed %s: %w", feed.Name, err)
+	}
+
+	// Searching for the watch-list finds what the feed itself missed. The
+	// feed was polled fine, so a failed search is reported and tried again
+	// next time it's due rather than failing the poll.
+	if p.searchDue(feed) {
+		queries, err := p.watchlistQueries(feed)
+		if err == nil {
+			_, err = p.searchFor(ctx, feed, queries, polled)
+		}
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		if err != nil {
+			p.onEvent(Event{Kind: EventFailed, Feed: feed.Name, Reason: "watch-list search", Err: err})
+		}
+		p.stats.update(feed.Name, func(s *history.FeedStats) { s.LastSearch = time.Now() })
+	}
+
+	if holdsReleases(feed) {
+		if err := p.decideContests(ctx, feed); err != nil {
+			return 0, fmt.Errorf("feed %s: %w", feed.Name, err)
+		}
+	}
+
+	// Items that failed before get another try even once they're gone from the feed
+	if _, err := p.retryQueued(ctx, feed, polled); err != nil {
+		return 0, fmt.Errorf("feed %s: %w", feed.Name, err)
+	}
+
+	p.stats.update(feed.Name, func(s *history.FeedStats) { s.LastPoll = time.Now() })
+	return len(matches), nil
+}
+
+// checkFeed checks that the tracker, client and delivery of a feed exist
+func (p *Pipeline) checkFeed(feed config.Feed) error {
+	if _, ok := p.downloaders[feed.Tracker]; !ok {
+		return fmt.Errorf("feed %s: unknown tracker %q", feed.Name, feed.Tracker)
+	}
+	if _, ok := p.clients[feed.Client]; feed.Client != "" && !ok {
+		return fmt.Errorf("feed %s: unknown client %q", feed.Name, feed.Client)
+	}
+	if _, ok := p.deliveries[feed.Delivery]; feed.Delivery != "" && !ok {
+		return fmt.Errorf("feed %s: unknown delivery %q", feed.Name, feed.Delivery)
+	}
+	return nil
+}
+
+// rank orders the items of a poll so those the feed prefers are grabbed
+// first
+func rank(feed config.Feed, items []models.Item) []models.Item {
+	if feed.Groups != nil {
+		items = preferredFirst(feed, items)
+	}
+	if feed.Formats != nil {
+		items = formatsFirst(feed, items)
+	}
+	if feed.Scoring != nil {
+		items = bestFirst(feed, items)
+	}
+	if feed.SeasonPacks == config.PacksPrefer {
+		items = packsFirst(items)
+	}
+	return items
+}
+
+// RetryFailed tries every failed item of the feed again right away, including
+// those it gave up on, e.g. after fixing the tracker's credentials. It returns
+// how many items were retried.
+func (p *Pipeline) RetryFailed(ctx context.Context, feed config.Feed) (int, error) {
+	if _, ok := p.downloaders[feed.Tracker]; !ok {
+		return 0, fmt.Errorf("feed %s: unknown tracker %q", feed.Name, feed.Tracker)
+	}
+	defer p.flushStats()
+	if _, err := p.history.ResetFailures(feed.Name); err != nil {
+		return 0, err
+	}
+	retried, err := p.retryQueued(ctx, feed, nil)
+	if err != nil {
+		return 0, fmt.Errorf("feed %s: %w", feed.Name, err)
+	}
+	return retried, nil
+}
+
+// retryQueued processes the feed's failed items again, except those in skip
+// and those that ran out of attempts
+func (p *Pipeline) retryQueued(ctx context.Context, feed config.Feed, skip map[string]bool) (int, error) {
+	failures, err := p.history.Failures(feed.Name)
+	if err != nil {
+		return 0, err
+	}
+	retried := 0
+	for _, failure := range failures {
+		if skip[failure.Key] || failure.Exhausted(feed.MaxFailures) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return retried, err
+		}
+		if err := p.process(ctx, feed, failure.Item); err != nil {
+			return retried, err
+		}
+		retried++
+
+		// Neither downloaded nor failed again means the item was filtered or
+		// skipped this time, so it's no longer wanted
+		current, err := p.history.Failure(failure.Key)
+		if err != nil {
+			return retried, err
+		}
+		if current != nil && current.Attempts == failure.Attempts {
+			if err := p.history.RemoveFailure(failure.Key); err != nil {
+				return retried, err
+			}
+		}
+	}
+	return retried, nil
+}
+
+// matchBacklog checks an item against the feed's age cutoff and, given how
+// many items of the poll were already considered, its per-poll limit
+func matchBacklog(feed config.Feed, item models.Item, considered int) (bool, string) {
+	if feed.IgnoreOlderThan > 0 && !item.PubDate.IsZero() {
+		if age := time.Since(item.PubDate); age > feed.IgnoreOlderThan {
+			return false, fmt.Sprintf("published %s ago, older than ignore_older_than %s", age.Round(time.Minute), feed.IgnoreOlderThan)
+		}
+	}
+	if feed.MaxItemsPerPoll > 0 && considered >= feed.MaxItemsPerPoll {
+		return false, fmt.Sprintf("beyond max_items_per_poll %d", feed.MaxItemsPerPoll)
+	}
+	return true, ""
+}
+
+// matchSize checks the size the feed reported against the feed's limits.
+// Unknown sizes pass, the feed just didn't say.
+func matchSize(feed config.Feed, size int64) (bool, string) {
+	switch {
+	case size == 0:
+		return true, ""
+	case feed.MinSize > 0 && size < feed.MinSize:
+		return false, fmt.Sprintf("size %s below min_size %s", bytesize.Format(size), bytesize.Format(feed.MinSize))
+	case feed.MaxSize > 0 && size > feed.MaxSize:
+		return false, fmt.Sprintf("size %s above max_size %s", bytesize.Format(size), bytesize.Format(feed.MaxSize))
+	}
+	return true, ""
+}
+
+// preferredFirst moves releases of the feed's preferred groups to the front,
+// so when a poll holds several releases of an episode theirs is grabbed
+func preferredFirst(feed config.Feed, items []models.Item) []models.Item {
+	sorted := append([]models.Item(nil), items...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return feed.Groups.Prefers(sorted[i].Title) && !feed.Groups.Prefers(sorted[j].Title)
+	})
+	return sorted
+}
+
+// formatsFirst orders releases by the feed's music formats, so when a poll
+// holds the album in FLAC and in MP3 the preferred one is grabbed first
+func formatsFirst(feed config.Feed, items []models.Item) []models.Item {
+	sorted := append([]models.Item(nil), items...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return feed.Formats.Rank(sorted[i].Title) < feed.Formats.Rank(sorted[j].Title)
+	})
+	return sorted
+}
+
+// process takes a single matched item through filtering, dedupe and download.
+// Only storage errors and failures that stop the poll are returned; other
+// download failures are reported as events.
+func (p *Pipeline) process(ctx context.Context, feed config.Feed, item models.Item) error {
+	feed, rule, err := p.match(ctx, feed, item)
+	if err != nil {
+		return err
+	}
+	if rule != "" {
+		p.onEvent(Event{Kind: EventFiltered, Feed: feed.Name, Item: item, Reason: rule, Size: item.Size})
+		return nil
+	}
+	releaseQuality := quality.Parse(item.Title)
+	info, isEpisode := episode.Parse(item.Title)
+	releaseKey := release.Key(item.Title)
+	trackEpisode := feed.TrackEpisodes && isEpisode
+
+	// The locks the checks take are held until the item is recorded
+	key := historyKey(feed, item)
+	var unlocks []func()
+	defer func() {
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			unlocks[i]()
+		}
+	}()
+	skip, reason, previous, err := p.skipCheck(feed, key, item, func(name string) {
+		unlocks = append(unlocks, p.locks.Lock(name))
+	})
+	if err != nil {
+		return err
+	}
+	if skip {
+		p.onEvent(Event{Kind: EventSkipped, Feed: feed.Name, Item: item, Reason: reason})
+		return nil
+	}
+
+	if feed.FreeleechOnly {
+		freeleech, err := p.isFreeleech(ctx, feed, item)
+		if err != nil {
+			return p.fail(ctx, feed, key, item, "", fmt.Errorf("failed to check freeleech: %w", err))
+		}
+		if !freeleech {
+			p.onEvent(Event{Kind: EventFiltered, Feed: feed.Name, Item: item, Reason: "not freeleech"})
+			return nil
+		}
+	}
+
+	// With a scoring window, releases of an episode are collected first
+	// and only the best of them gets this far
+	if trackEpisode && holdsReleases(feed) {
+		picked, err := p.enterContest(feed, key, item, info)
+		if err != nil || !picked {
+			return err
+		}
+	}
+
+	media := p.lookup(ctx, item.Title)
+	if feed.Approval {
+		approved, err := p.awaitApproval(feed, key, item, media)
+		if err != nil {
+			return err
+		}
+		if !approved {
+			return nil
+		}
+	}
+
+	// Left for a later poll, once there's room again
+	if !p.hasSpace(ctx, feed) {
+		p.onEvent(Event{Kind: EventSkipped, Feed: feed.Name, Item: item, Reason: "paused, low disk space"})
+		return nil
+	}
+
+	p.onEvent(Event{Kind: EventMatch, Feed: feed.Name, Item: item, Media: media})
+
+	torrent, err := p.fetch(ctx, feed, item)
+	if err != nil {
+		return p.fail(ctx, feed, key, item, "", err)
+	}
+
+	// The same torrent may show up in several feeds under different GUIDs
+	if torrent.InfoHash != "" {
+		defer p.locks.Lock("torrent:" + torrent.InfoHash)()
+		existing, err := p.history.ByInfoHash(torrent.InfoHash)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			// Remember this item too, so it isn't fetched again next poll
+			err = p.history.Add(history.Entry{
+				Key:      key,
+				Feed:     feed.Name,
+				Tracker:  feed.Tracker,
+				Title:    item.Title,
+				Link:     item.Link,
+				InfoHash: torrent.InfoHash,
+				Release:  releaseKey,
+				Media:    media,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to record history: %w", err)
+			}
+			p.onEvent(Event{Kind: EventSkipped, Feed: feed.Name, Item: item, InfoHash: torrent.InfoHash,
+				Reason: fmt.Sprintf("same torrent as %q from %s", existing.Title, existing.Feed)})
+			return nil
+		}
+	}
+
+	to, err := p.deliver(ctx, feed, torrent)
+	if err != nil {
+		return p.fail(ctx, feed, key, item, torrent.InfoHash, err)
+	}
+	// Should the poll stop right here, resuming it mustn't deliver again
+	if err := p.history.MarkDelivered(key, torrent.InfoHash, torrent.Size, to.client, to.file); err != nil {
+		return err
+	}
+	if err := p.record(feed, key, item, torrent.InfoHash, media, to); err != nil {
+		return err
+	}
+
+	if previous == nil {
+		p.onEvent(Event{Kind: EventDownloaded, Feed: feed.Name, Item: item, InfoHash: torrent.InfoHash, Size: torrent.Size, Media: media})
+		return nil
+	}
+
+	reason = fmt.Sprintf("%s → %s", previous.Quality, releaseQuality)
+	remove := feed.RemoveUpgraded
+	if feed.ReplacePropers && fixes(item.Title, previous) {
+		reason = fmt.Sprintf("fixes %q", previous.Title)
+		remove = feed.RemoveReplaced
+	}
+	if remove {
+		if err := p.removePrevious(ctx, feed, previous); err != nil {
+			reason += fmt.Sprintf(" (old release not removed: %v)", err)
+		} else {
+			reason += " (old release removed)"
+		}
+	}
+	p.onEvent(Event{Kind: EventUpgraded, Feed: feed.Name, Item: item, Reason: reason, InfoHash: torrent.InfoHash, Size: torrent.Size, Media: media})
+	return nil
+}
+
+// skipCheck tells whether an item that passed the feed's filters is skipped
+// before anything of it is fetched, and why: it was grabbed already, from
+// this or a preferred tracker, failed too often, or is an episode already
+// grabbed that isn't an upgrade. The reason is empty for items in the
+// history. For episodes it returns the release grabbed before, if any.
+// lock, when set, takes the named lock before the check it guards.
+func (p *Pipeline) skipCheck(feed config.Feed, key string, item models.Item, lock func(name string)) (skip bool, reason string, previous *history.EpisodeRecord, err error) {
+	if lock == nil {
+		lock = func(string) {}
+	}
+
+	// The same release on a preferred tracker is left to that tracker's feed
+	releaseKey := release.Key(item.Title)
+	if releaseKey != "" {
+		preferred, ok, err := p.preferredTracker(releaseKey, feed.Tracker)
+		if err != nil || ok {
+			return ok, "grabbed from preferred tracker " + preferred, nil, err
+		}
+	}
+
+	// Locks are always taken in the order item, release, episode, torrent
+	lock("item:" + key)
+	seen, err := p.history.Has(key)
+	if err != nil || seen {
+		return seen, "", nil, err
+	}
+	failure, err := p.history.Failure(key)
+	if err != nil {
+		return false, "", nil, err
+	}
+	if failure != nil && failure.Exhausted(feed.MaxFailures) {
+		reason := fmt.Sprintf("failed %d times, last with %s", failure.Attempts, failure.Err)
+		if failure.GaveUp {
+			reason = "given up on after " + failure.Err
+		}
+		return true, reason, nil, nil
+	}
+
+	// The same release may show up on another tracker under another link
+	if releaseKey != "" {
+		lock("release:" + releaseKey)
+		existing, err := p.history.ByRelease(releaseKey)
+		if err != nil {
+			return false, "", nil, err
+		}
+		if existing != nil {
+			return true, fmt.Sprintf("same release as %q from %s", existing.Title, existing.Feed), nil, nil
+		}
+	}
+
+	// A different release of an episode we already have is still a duplicate,
+	// unless the quality profile considers it an upgrade. Season packs and
+	// the episodes they hold are locked together.
+	info, isEpisode := episode.Parse(item.Title)
+	if !feed.TrackEpisodes || !isEpisode {
+		return false, "", nil, nil
+	}
+	episodeLock := info.Key()
+	if info.Date.IsZero() {
+		episodeLock = info.SeasonKey()
+	}
+	lock("episode:" + episodeLock)
+	previous, reason, err = p.checkEpisodes(feed, info, item.Title, quality.Parse(item.Title))
+	return reason != "", reason, previous, err
+}
+
+// match checks an item against the feed's filters, returning the rule that
+// rejects it, empty when it passes, and the feed routed to the destination
+// of the item's content type
+func (p *Pipeline) match(ctx context.Context, feed config.Feed, item models.Item) (config.Feed, string, error) {
+	if ok, rule := feed.Filter.Match(item.Title); !ok {
+		return feed, rule, nil
+	}
+	if ok, rule := feed.Groups.Match(item.Title); !ok {
+		return feed, rule, nil
+	}
+	if ok, rule := feed.Formats.Match(item.Title); !ok {
+		return feed, rule, nil
+	}
+	contentType, ok, rule := p.matchContentType(feed, item)
+	if !ok {
+		return feed, rule, nil
+	}
+	// Releases of a type with its own destination go there
+	feed = contentType.Route(feed)
+	if feed.Watchlist && p.watchlist != nil {
+		entry, err := p.watchlist.MatchMedia(item.Title, p.lookup(ctx, item.Title))
+		if err != nil {
+			return feed, "", err
+		}
+		if entry == nil {
+			return feed, "not on the watch-list", nil
+		}
+	}
+	if ok, rule := matchSize(feed, item.Size); !ok {
+		return feed, rule, nil
+	}
+
+	releaseQuality := quality.Parse(item.Title)
+	if feed.Quality != nil && !feed.Quality.Accepts(releaseQuality) {
+		return feed, fmt.Sprintf("quality %s not in profile", releaseQuality), nil
+	}
+	info, isEpisode := episode.Parse(item.Title)
+	if isEpisode && info.Pack && feed.SeasonPacks == config.PacksSkip {
+		return feed, "season pack", nil
+	}
+	return feed, "", nil
+}
+
+// record adds a delivered item to the history, along with the episodes it
+// holds when the feed tracks them, and closes its contest
+func (p *Pipeline) record(feed config.Feed, key string, item models.Item, infoHash string, media *metadata.Info, to delivered) error {
+	info, isEpisode := episode.Parse(item.Title)
+	trackEpisode := feed.TrackEpisodes && isEpisode
+	err := p.history.Add(history.Entry{
+		Key:      key,
+		Feed:     feed.Name,
+		Tracker:  feed.Tracker,
+		Title:    item.Title,
+		Link:     item.Link,
+		InfoHash: infoHash,
+		Release:  release.Key(item.Title),
+		Media:    media,
+		Client:   to.client,
+		File:     to.file,
+	})
+	if err == nil && trackEpisode {
+		err = p.recordEpisodes(info, item, quality.Parse(item.Title), infoHash)
+	}
+	if err == nil && trackEpisode && holdsReleases(feed) {
+		err = p.history.RemoveContest(contestKey(feed, info))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record history: %w", err)
+	}
+	return nil
+}
+
+// fail reports a failed download and queues the item to be tried again on
+// later polls, until the feed's MaxFailures is reached. An expired login, a
+// rate limit or maintenance would fail every other item too, so it stops the
+// poll instead, and the latter two put the tracker on cool-down.
+func (p *Pipeline) fail(ctx context.Context, feed config.Feed, key string, item models.Item, infoHash string, err error) error {
+	// Shutting down isn't the item's fault, and neither is the tracker
+	// turning everything away
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if trackerDown(err) {
+		p.cooldowns.coolDown(feed.Tracker, err)
+		return fmt.Errorf("stopped at %q: %w", item.Title, err)
+	}
+	if errors.Is(err, downloader.ErrAuthExpired) {
+		return fmt.Errorf("stopped at %q: %w", item.Title, err)
+	}
+
+	failure, recordErr := p.history.AddFailure(key, feed.Name, item, err)
+	if recordErr != nil {
+		return recordErr
+	}
+	reason := fmt.Sprintf("attempt %d of %d", failure.Attempts, feed.MaxFailures)
+	// A torrent removed from the tracker won't come back, and a public one
+	// won't turn private
+	var giveUp string
+	switch {
+	case errors.Is(err, downloader.ErrNotFound):
+		giveUp = "torrent is gone, not retrying"
+	case errors.Is(err, ErrNotPrivate):
+		giveUp = "not retrying"
+	}
+	if giveUp != "" {
+		if recordErr := p.history.GiveUp(key); recordErr != nil {
+			return recordErr
+		}
+		reason = giveUp
+	}
+	p.onEvent(Event{Kind: EventFailed, Feed: feed.Name, Item: item, Err: err, InfoHash: infoHash, Reason: reason})
+	return nil
+}
+
+// Grab downloads a single item on request, skipping the feed's filters and
+// history checks, and records it like any other download
+func (p *Pipeline) Grab(ctx context.Context, feed config.Feed, item models.Item) (*downloader.Torrent, error) {
+	if _, ok := p.downloaders[feed.Tracker]; !ok {
+		return nil, fmt.Errorf("feed %s: unknown tracker %q", feed.Name, feed.Tracker)
+	}
+	defer p.flushStats()
+
+	media := p.lookup(ctx, item.Title)
+	p.onEvent(Event{Kind: EventMatch, Feed: feed.Name, Item: item, Media: media})
+
+	feed = config.DetectContentType(p.contentTypes, item.Categories).Route(feed)
+	var to delivered
+	torrent, err := p.fetch(ctx, feed, item)
+	if err == nil {
+		to, err = p.deliver(ctx, feed, torrent)
+	}
+	if err != nil {
+		p.onEvent(Event{Kind: EventFailed, Feed: feed.Name, Item: item, Err: err})
+		return nil, err
+	}
+
+	err = p.history.Add(history.Entry{
+		Key:      historyKey(feed, item),
+		Feed:     feed.Name,
+		Tracker:  feed.Tracker,
+		Title:    item.Title,
+		Link:     item.Link,
+		InfoHash: torrent.InfoHash,
+		Release:  release.Key(item.Title),
+		Media:    media,
+		Client:   to.client,
+		File:     to.file,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record history: %w", err)
+	}
+
+	p.onEvent(Event{Kind: EventDownloaded, Feed: feed.Name, Item: item, InfoHash: torrent.InfoHash, Size: torrent.Size, Media: media})
+	return torrent, nil
+}
+
+// removePrevious drops a superseded release from the torrent client
+func (p *Pipeline) removePrevious(ctx context.Context, feed config.Feed, previous *history.EpisodeRecord) error {
+	target, ok := p.clients[feed.Client]
+	if !ok {
+		return fmt.Errorf("no torrent client configured")
+	}
+	if previous.InfoHash == "" {
+		return fmt.Errorf("infohash of %s unknown", previous.Title)
+	}
+	return target.client.RemoveTorrent(ctx, previous.InfoHash, true)
+}
+
+// fetch downloads an item's torrent or resolves its magnet link
+func (p *Pipeline) fetch(ctx context.Context, feed config.Feed, item models.Item) (*downloader.Torrent, error) {
+	if err := p.pacing.wait(ctx, feed.Tracker); err != nil {
+		return nil, err
+	}
+	started := time.Now()
+	torrent, err := p.downloaders[feed.Tracker].Fetch(ctx, downloader.Source{PageURL: item.Link, EnclosureURL: item.EnclosureURL})
+	if err == nil {
+		p.timeFetch(feed.Name, started)
+	}
+	return torrent, err
+}
+
+// isFreeleech trusts a freeleech marker in the feed, and otherwise asks the
+// tracker to look at the torrent page. Trackers that can't tell count as no.
+func (p *Pipeline) isFreeleech(ctx context.Context, feed config.Feed, item models.Item) (bool, error) {
+	if item.Freeleech {
+		return true, nil
+	}
+	if magnet.IsMagnet(item.Link) {
+		return false, nil
+	}
+	freeleech, err := p.downloaders[feed.Tracker].IsFreeleech(ctx, item.Link)
+	if errors.Is(err, tracker.ErrFreeleechUnknown) {
+		return false, nil
+	}
+	return freeleech, err
+}
+
+// delivered is who downloads a delivered torrent: the client it was sent
+// to, or the one watching the folder it was written to, which then holds
+// file, the torrent's file if the feed removes it once completed
+type delivered struct {
+	client string
+	file   string
+}
+
+// deliver hands a fetched torrent to the feed's client, its delivery or the
+// download directory
+func (p *Pipeline) deliver(ctx context.Context, feed config.Feed, torrent *downloader.Torrent) (delivered, error) {
+	if err := checkPrivate(feed, torrent); err != nil {
+		return delivered{}, err
+	}
+	d, target, _, err := p.destination(feed)
+	if err != nil {
+		return delivered{}, err
+	}
+	if err := d.Deliver(ctx, torrent, target); err != nil {
+		return delivered{}, err
+	}
+
+	var to delivered
+	if _, ok := p.clients[feed.Client]; ok {
+		to.client = feed.Client
+	} else if folder, ok := d.(*delivery.Folder); ok && feed.WatchClient != "" {
+		to.client = feed.WatchClient
+		if feed.RemoveTorrentFile {
+			to.file = folder.Path(torrent, target)
+		}
+	}
+	return to, nil
+}
+
+// destination picks where the feed's torrents go: its client, its delivery
+// or the download directory. name describes it for messages.
+func (p *Pipeline) destination(feed config.Feed) (d delivery.Delivery, target delivery.Target, name string, err error) {
+	if c, ok := p.clients[feed.Client]; ok {
+		return c.delivery, delivery.Target{Dir: feed.SavePath, Category: feed.Category, Seeding: feed.Seeding}, feed.Client, nil
+	}
+	if feed.Delivery != "" {
+		d, ok := p.deliveries[feed.Delivery]
+		if !ok {
+			return nil, target, "", fmt.Errorf("unknown delivery %q", feed.Delivery)
+		}
+		return d, delivery.Target{Dir: feed.SavePath}, feed.Delivery, nil
+	}
+	if p.folder == nil {
+		return nil, target, "", fmt.Errorf("no download directory configured")
+	}
+	// Feeds with their own directory may be on another filesystem
+	name = "the download directory"
+	if feed.DownloadPath != "" {
+		name = feed.DownloadPath
+	}
+	return p.folder, delivery.Target{Dir: feed.DownloadPath}, name, nil
+}
+
+// historyKey identifies an item across polls by the feed's dedupe key.
+// Keys other than GUIDs are prefixed so they never collide with one.
+func historyKey(feed config.Feed, item models.Item) string {
+	switch feed.DedupeKey {
+	case config.DedupeTitle:
+		if key := release.Key(item.Title); key != "" {
+			return "title:" + key
+		}
+	case config.DedupeInfoHash:
+		if item.InfoHash != "" {
+			return "infohash:" + item.InfoHash
+		}
+		return "url:" + downloadURL(item)
+	case config.DedupeURL:
+		return "url:" + downloadURL(item)
+	}
+	if item.GUID != "" {
+		return item.GUID
+	}
+	return item.Link
+}
+
+// downloadURL is where the item's torrent comes from, as far as the feed says
+func downloadURL(item models.Item) string {
+	if item.EnclosureURL != "" {
+		return item.EnclosureURL
+	}
+	return item.Link
+}
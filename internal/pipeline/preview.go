@@ -0,0 +1,59 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+
+	"torrent-rss/internal/config"
+	"torrent-rss/internal/models"
+)
+
+// Preview is a feed item as a poll would see it
+type Preview struct {
+	Item models.Item
+	// ContentType names the type of the item's categories, empty when it
+	// has none
+	ContentType string
+	// Filtered is the rule of the feed that rejects the item, empty when it
+	// matches
+	Filtered   string
+	Downloaded bool // Already in the history
+}
+
+// Preview fetches a feed and tells for each item whether its filters match
+// and whether it was downloaded, without grabbing anything or changing what
+// the next poll sees. Checks that need the tracker or other releases, like
+// freeleech, duplicates on other trackers and scoring, aren't made.
+func (p *Pipeline) Preview(ctx context.Context, feed config.Feed) ([]Preview, error) {
+	var items []models.Item
+	var err error
+	if feed.Torznab != nil {
+		items, err = p.parser.FetchTorznab(ctx, feed.URL, *feed.Torznab, feed.SearchTerms)
+	} else {
+		items, err = p.parser.Peek(ctx, feed.URL, feed.JSONAPI, feed.SearchTerms)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("feed %s: %w", feed.Name, err)
+	}
+	return p.previews(ctx, feed, items)
+}
+
+// previews checks each item against the feed's filters and the history
+func (p *Pipeline) previews(ctx context.Context, feed config.Feed, items []models.Item) ([]Preview, error) {
+	var err error
+	previews := make([]Preview, 0, len(items))
+	for _, item := range items {
+		preview := Preview{Item: item}
+		if t := config.DetectContentType(p.contentTypes, item.Categories); t != nil {
+			preview.ContentType = t.Type.Name
+		}
+		if _, preview.Filtered, err = p.match(ctx, feed, item); err != nil {
+			return nil, err
+		}
+		if preview.Downloaded, err = p.history.Has(historyKey(feed, item)); err != nil {
+			return nil, err
+		}
+		previews = append(previews, preview)
+	}
+	return previews, nil
+}
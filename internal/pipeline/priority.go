@@ -0,0 +1,31 @@
+package pipeline
+
+// SetTrackerPriority orders trackers from most to least preferred, trackers
+// not listed come last. Feeds are polled in that order, see
+// config.FeedsByPriority, and a release grabbed from one tracker is skipped
+// on those behind it.
+func (p *Pipeline) SetTrackerPriority(names []string) {
+	p.priority = make(map[string]int, len(names))
+	for i, name := range names {
+		p.priority[name] = i
+	}
+}
+
+// rank is a tracker's place in the priority order, lower is preferred
+func (p *Pipeline) rank(tracker string) int {
+	if rank, ok := p.priority[tracker]; ok {
+		return rank
+	}
+	return len(p.priority)
+}
+
+// preferredTracker returns the tracker the release was grabbed from when
+// it's preferred over this one. A release a preferred tracker carries but
+// failed to grab, or filtered out, is left to the others.
+func (p *Pipeline) preferredTracker(release, tracker string) (string, bool, error) {
+	existing, err := p.history.ByRelease(release)
+	if err != nil || existing == nil || existing.Tracker == "" {
+		return "", false, err
+	}
+	return existing.Tracker, p.rank(existing.Tracker) < p.rank(tracker), nil
+}
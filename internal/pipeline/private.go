@@ -0,0 +1,28 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+
+	"torrent-rss/internal/config"
+	"torrent-rss/internal/downloader"
+)
+
+// ErrNotPrivate means a feed marked private fetched a torrent without the
+// private flag, which clients would announce on DHT and share over PEX
+var ErrNotPrivate = errors.New("torrent is not private")
+
+// checkPrivate refuses public torrents and magnet links for private feeds.
+// A magnet carries no info dict to check, and is resolved over DHT anyway.
+func checkPrivate(feed config.Feed, torrent *downloader.Torrent) error {
+	if !feed.Private {
+		return nil
+	}
+	if torrent.Magnet != "" {
+		return fmt.Errorf("%w: magnet links can't be checked for the private flag", ErrNotPrivate)
+	}
+	if !torrent.Private {
+		return fmt.Errorf("%w: %s has no private flag", ErrNotPrivate, torrent.Name)
+	}
+	return nil
+}
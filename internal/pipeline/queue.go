@@ -0,0 +1,129 @@
+package pipeline
+
+import (
+	"context"
+
+	"torrent-rss/internal/config"
+	"torrent-rss/internal/downloader"
+	"torrent-rss/internal/episode"
+	"torrent-rss/internal/history"
+	"torrent-rss/internal/models"
+)
+
+// processQueued processes the items a poll picked, keeping them queued
+// until it's done with them, so a poll cut short by a crash or shutdown is
+// picked up where it left off. Items are dequeued together once the poll
+// ends, early or not; the ones downloaded already were when recorded.
+func (p *Pipeline) processQueued(ctx context.Context, feed config.Feed, keys []string, items []models.Item, polled map[string]bool) (err error) {
+	if len(items) == 0 {
+		return nil
+	}
+	if err := p.history.Enqueue(feed.Name, keys, items); err != nil {
+		return err
+	}
+
+	var done []string
+	defer func() {
+		if dequeueErr := p.history.Dequeue(done...); err == nil {
+			err = dequeueErr
+		}
+	}()
+
+	// Torrent pages are scraped ahead, several at a time, while the items
+	// are processed in order. Each page waits its turn with the tracker's
+	// downloads, so a grab delay spaces out the scraping too.
+	if d := p.downloaders[feed.Tracker]; d != nil && d.Prefetching() {
+		prefetchCtx, cancel := context.WithCancel(ctx)
+		prefetched := d.Prefetch(prefetchCtx, p.likelyGrabs(ctx, feed, keys, items), func(ctx context.Context) error {
+			return p.pacing.wait(ctx, feed.Tracker)
+		})
+		defer func() {
+			cancel()
+			<-prefetched
+		}()
+	}
+
+	for i, item := range items {
+		// Stop between items when the daemon is shutting down
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		polled[keys[i]] = true
+		if err := p.process(ctx, feed, item); err != nil {
+			return err
+		}
+		done = append(done, keys[i])
+	}
+	return nil
+}
+
+// resumeQueued finishes the items of the feed an earlier poll picked but
+// didn't get to. Items it already delivered are only recorded, the rest go
+// through the pipeline again, which checks the history as usual.
+func (p *Pipeline) resumeQueued(ctx context.Context, feed config.Feed, polled map[string]bool) error {
+	queue, err := p.history.Queue(feed.Name)
+	if err != nil || len(queue) == 0 {
+		return err
+	}
+
+	var keys []string
+	var items []models.Item
+	for _, queued := range queue {
+		if queued.Stage != history.QueueDelivered {
+			keys = append(keys, queued.Key)
+			items = append(items, queued.Item)
+			continue
+		}
+		polled[queued.Key] = true
+		media := p.lookup(ctx, queued.Item.Title)
+		if err := p.record(feed, queued.Key, queued.Item, queued.InfoHash, media, delivered{client: queued.Client, file: queued.File}); err != nil {
+			return err
+		}
+		p.onEvent(Event{Kind: EventDownloaded, Feed: feed.Name, Item: queued.Item, InfoHash: queued.InfoHash, Size: queued.Size,
+			Media: media, Reason: "delivered before the last poll stopped"})
+	}
+	return p.processQueued(ctx, feed, keys, items, polled)
+}
+
+// likelyGrabs are the sources of the items that pass the feed's filters and
+// the checks process skips items with, the ones whose torrents will likely
+// be fetched
+func (p *Pipeline) likelyGrabs(ctx context.Context, feed config.Feed, keys []string, items []models.Item) []downloader.Source {
+	var srcs []downloader.Source
+	for i, item := range items {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if likely, err := p.likelyGrab(ctx, feed, keys[i], item); err != nil || !likely {
+			continue
+		}
+		srcs = append(srcs, downloader.Source{PageURL: item.Link, EnclosureURL: item.EnclosureURL})
+	}
+	return srcs
+}
+
+// likelyGrab runs the checks of process that skip an item, without taking
+// their locks or reporting events. Items waiting on approval or on a contest
+// to be decided don't count, they're rarely grabbed by this poll.
+func (p *Pipeline) likelyGrab(ctx context.Context, feed config.Feed, key string, item models.Item) (bool, error) {
+	feed, rule, err := p.match(ctx, feed, item)
+	if err != nil || rule != "" || feed.Approval {
+		return false, err
+	}
+	if skip, _, _, err := p.skipCheck(feed, key, item, nil); err != nil || skip {
+		return false, err
+	}
+
+	info, isEpisode := episode.Parse(item.Title)
+	if !feed.TrackEpisodes || !isEpisode || !holdsReleases(feed) {
+		return true, nil
+	}
+	contest, err := p.history.Contest(contestKey(feed, info))
+	if err != nil {
+		return false, err
+	}
+	if contest != nil && contest.Winner != "" {
+		return contest.Winner == key, nil
+	}
+	return candidateDelay(feed, item) == 0, nil
+}
@@ -0,0 +1,147 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"torrent-rss/internal/config"
+	"torrent-rss/internal/history"
+	"torrent-rss/internal/models"
+)
+
+// ErrNoWatchlist means a watch-list search was asked of a feed that doesn't
+// follow the watch-list
+var ErrNoWatchlist = errors.New("feed doesn't follow the watch-list")
+
+// Search searches the feed's tracker, or its Torznab endpoint, for query and
+// tells for each result whether the feed's filters match and whether it was
+// downloaded, like Preview. The feed's search terms don't apply.
+func (p *Pipeline) Search(ctx context.Context, feed config.Feed, query string) ([]Preview, error) {
+	if err := p.checkFeed(feed); err != nil {
+		return nil, err
+	}
+	items, err := p.search(ctx, feed, query)
+	if err != nil {
+		return nil, fmt.Errorf("feed %s: %w", feed.Name, err)
+	}
+	return p.previews(ctx, feed, items)
+}
+
+// SearchWatchlist searches the feed's tracker for every title on the
+// watch-list and grabs what the feed's filters match, like a poll would, to
+// find what the feed missed or what was released before it was followed.
+// With episode tracking, episodes that were grabbed already are skipped. It
+// returns how many results the searches found.
+func (p *Pipeline) SearchWatchlist(ctx context.Context, feed config.Feed) (int, error) {
+	queries, err := p.watchlistQueries(feed)
+	if err != nil {
+		return 0, fmt.Errorf("feed %s: %w", feed.Name, err)
+	}
+	found, err := p.searchNow(ctx, feed, queries)
+	if err == nil {
+		p.stats.update(feed.Name, func(s *history.FeedStats) { s.LastSearch = time.Now() })
+		p.flushStats()
+	}
+	return found, err
+}
+
+// searchNow searches the feed's tracker for each query and grabs what the
+// feed's filters match, outside of a poll
+func (p *Pipeline) searchNow(ctx context.Context, feed config.Feed, queries []string) (int, error) {
+	if err := p.checkFeed(feed); err != nil {
+		return 0, err
+	}
+	if until, ok := p.cooldowns.coolingDown(feed.Tracker); ok {
+		return 0, fmt.Errorf("feed %s: %w, %s is left alone until %s", feed.Name, ErrCoolingDown, feed.Tracker, until.Format("15:04"))
+	}
+	defer p.flushStats()
+
+	found, err := p.searchFor(ctx, feed, queries, make(map[string]bool))
+	if err != nil {
+		if ctx.Err() != nil {
+			return found, ctx.Err()
+		}
+		return found, fmt.Errorf("feed %s: %w", feed.Name, err)
+	}
+	if holdsReleases(feed) {
+		if err := p.decideContests(ctx, feed); err != nil {
+			return found, fmt.Errorf("feed %s: %w", feed.Name, err)
+		}
+	}
+	return found, nil
+}
+
+// searchDue tells whether the feed's tracker is due to be searched for the
+// watch-list
+func (p *Pipeline) searchDue(feed config.Feed) bool {
+	if feed.WatchlistSearch == 0 || !feed.Watchlist || p.watchlist == nil {
+		return false
+	}
+	stats, err := p.history.FeedStats(feed.Name)
+	if err != nil {
+		return false
+	}
+	return time.Since(stats.LastSearch) >= feed.WatchlistSearch
+}
+
+// watchlistQueries are the searches for the titles on the watch-list. The
+// years are left out, release names often don't have one.
+func (p *Pipeline) watchlistQueries(feed config.Feed) ([]string, error) {
+	if !feed.Watchlist || p.watchlist == nil {
+		return nil, ErrNoWatchlist
+	}
+	entries, err := p.watchlist.Entries()
+	if err != nil {
+		return nil, err
+	}
+	queries := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		queries = append(queries, entry.Title)
+	}
+	return queries, nil
+}
+
+// searchFor processes the results of each query, except those in polled
+func (p *Pipeline) searchFor(ctx context.Context, feed config.Feed, queries []string, polled map[string]bool) (int, error) {
+	found := 0
+	for _, query := range queries {
+		if err := ctx.Err(); err != nil {
+			return found, err
+		}
+		items, err := p.search(ctx, feed, query)
+		if err != nil {
+			if trackerDown(err) {
+				p.cooldowns.coolDown(feed.Tracker, err)
+			}
+			return found, fmt.Errorf("search for %q: %w", query, err)
+		}
+		found += len(items)
+
+		items = rank(feed, items)
+		keys := make([]string, 0, len(items))
+		var picked []models.Item
+		for _, item := range items {
+			key := historyKey(feed, item)
+			if polled[key] {
+				continue
+			}
+			picked = append(picked, item)
+			keys = append(keys, key)
+		}
+		if err := p.processQueued(ctx, feed, keys, picked, polled); err != nil {
+			return found, err
+		}
+	}
+	return found, nil
+}
+
+// search runs a query against the feed's Torznab endpoint, or else its
+// tracker's own search
+func (p *Pipeline) search(ctx context.Context, feed config.Feed, query string) ([]models.Item, error) {
+	if feed.Torznab != nil {
+		return p.parser.FetchTorznab(ctx, feed.URL, *feed.Torznab, []string{query})
+	}
+	return p.downloaders[feed.Tracker].Search(ctx, query)
+}
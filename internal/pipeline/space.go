@@ -0,0 +1,69 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"torrent-rss/internal/bytesize"
+	"torrent-rss/internal/config"
+	"torrent-rss/internal/delivery"
+)
+
+// ErrLowDiskSpace is the error of EventPaused
+var ErrLowDiskSpace = errors.New("low disk space")
+
+// spaceGuard remembers which destinations are low on space, so pausing and
+// resuming are reported once rather than for every item
+type spaceGuard struct {
+	min int64 // Bytes, 0 disables the check
+
+	mu  sync.Mutex
+	low map[string]bool // Destination name to whether it's low
+}
+
+// SetMinFreeSpace pauses grabbing for feeds whose destination has less than
+// bytes free, until space is freed. 0 disables the check.
+func (p *Pipeline) SetMinFreeSpace(bytes int64) {
+	p.space.min = bytes
+}
+
+// hasSpace checks the free space where the feed's torrents end up against
+// the minimum. Destinations that can't tell, or fail to, never pause.
+func (p *Pipeline) hasSpace(ctx context.Context, feed config.Feed) bool {
+	if p.space.min <= 0 {
+		return true
+	}
+	d, target, name, err := p.destination(feed)
+	if err != nil {
+		return true
+	}
+	checker, ok := d.(delivery.SpaceChecker)
+	if !ok {
+		return true
+	}
+	free, err := checker.FreeSpace(ctx, target)
+	if err != nil {
+		return true
+	}
+
+	low := free < p.space.min
+	p.space.mu.Lock()
+	if p.space.low == nil {
+		p.space.low = make(map[string]bool)
+	}
+	was := p.space.low[name]
+	p.space.low[name] = low
+	p.space.mu.Unlock()
+
+	switch {
+	case low && !was:
+		p.onEvent(Event{Kind: EventPaused, Feed: feed.Name,
+			Err: fmt.Errorf("%w: %s free on %s, below min_free_space %s", ErrLowDiskSpace, bytesize.Format(free), name, bytesize.Format(p.space.min))})
+	case !low && was:
+		p.onEvent(Event{Kind: EventResumed, Feed: feed.Name,
+			Reason: fmt.Sprintf("%s free on %s", bytesize.Format(free), name)})
+	}
+	return !low
+}
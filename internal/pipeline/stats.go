@@ -0,0 +1,75 @@
+package pipeline
+
+import (
+	"sync"
+	"time"
+
+	"torrent-rss/internal/history"
+)
+
+// tally counts events per feed in memory until they're flushed to the
+// history, so a poll writes its counters once instead of once per item
+type tally struct {
+	mu      sync.Mutex
+	pending map[string]*history.FeedStats
+}
+
+// update applies fn to the pending counters of a feed
+func (t *tally) update(feed string, fn func(*history.FeedStats)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.pending == nil {
+		t.pending = make(map[string]*history.FeedStats)
+	}
+	stats, ok := t.pending[feed]
+	if !ok {
+		stats = &history.FeedStats{Feed: feed}
+		t.pending[feed] = stats
+	}
+	fn(stats)
+}
+
+// count adds an event to its feed's counters
+func (t *tally) count(e Event) {
+	if e.Feed == "" {
+		return
+	}
+	t.update(e.Feed, func(s *history.FeedStats) {
+		switch e.Kind {
+		case EventMatch:
+			s.Matched++
+		case EventDownloaded, EventUpgraded:
+			s.Downloaded++
+		case EventFiltered:
+			s.Filtered++
+		case EventFailed:
+			s.Failed++
+		}
+	})
+}
+
+// flushStats writes the pending counters to the history. Counters that
+// fail to save are kept for the next flush rather than failing the poll.
+func (p *Pipeline) flushStats() {
+	p.stats.mu.Lock()
+	defer p.stats.mu.Unlock()
+	if len(p.stats.pending) == 0 {
+		return
+	}
+	deltas := make([]history.FeedStats, 0, len(p.stats.pending))
+	for _, stats := range p.stats.pending {
+		deltas = append(deltas, *stats)
+	}
+	if err := p.history.AddStats(deltas...); err == nil {
+		p.stats.pending = nil
+	}
+}
+
+// timeFetch records how long resolving an item's torrent took
+func (p *Pipeline) timeFetch(feed string, started time.Time) {
+	took := time.Since(started)
+	p.stats.update(feed, func(s *history.FeedStats) {
+		s.Resolved++
+		s.ResolveTime += took
+	})
+}
@@ -0,0 +1,191 @@
+package quality
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"torrent-rss/internal/release"
+)
+
+// Quality is the resolution and source of a release
+type Quality struct {
+	Resolution string // 2160p, 1080p, 720p, 576p, 480p
+	Source     string // WEB-DL, WEBRip, WEB, BluRay, HDTV, HDRip, DVDRip
+}
+
+// Parse detects the quality of a release title. Unknown parts are left empty.
+func Parse(title string) Quality {
+	r := release.Parse(title)
+	return Quality{Resolution: r.Resolution, Source: r.Source}
+}
+
+func (q Quality) String() string {
+	parts := make([]string, 0, 2)
+	if q.Resolution != "" {
+		parts = append(parts, q.Resolution)
+	}
+	if q.Source != "" {
+		parts = append(parts, q.Source)
+	}
+	if len(parts) == 0 {
+		return "unknown"
+	}
+	return strings.Join(parts, " ")
+}
+
+// tier is one acceptable quality, e.g. "1080p WEB-DL" or just "720p"
+type tier struct {
+	label      string
+	resolution string
+	source     string
+	delay      time.Duration
+	delayed    bool // delay was set, even if to 0
+}
+
+func (t tier) matches(q Quality) bool {
+	if t.resolution != "" && !strings.EqualFold(t.resolution, q.Resolution) {
+		return false
+	}
+	if t.source != "" && !strings.EqualFold(t.source, q.Source) {
+		return false
+	}
+	return true
+}
+
+// Profile lists acceptable qualities from most to least preferred. Releases
+// matching no tier are rejected. With Upgrade set, an episode is grabbed
+// again when a release in a better tier appears, until the cutoff tier is reached.
+type Profile struct {
+	tiers   []tier
+	cutoff  int
+	Upgrade bool
+}
+
+// NewProfile parses tiers such as ["1080p WEB-DL", "1080p", "720p"]. An
+// empty cutoff means only the best tier stops upgrades.
+func NewProfile(tiers []string, cutoff string, upgrade bool) (*Profile, error) {
+	if len(tiers) == 0 {
+		return nil, fmt.Errorf("quality profile needs at least one tier")
+	}
+
+	p := &Profile{Upgrade: upgrade}
+	for _, label := range tiers {
+		t := tier{label: label}
+		for _, token := range strings.Fields(label) {
+			parsed := Parse(token)
+			switch {
+			case parsed.Resolution != "" && t.resolution == "":
+				t.resolution = parsed.Resolution
+			case parsed.Source != "" && t.source == "":
+				t.source = parsed.Source
+			default:
+				return nil, fmt.Errorf("quality tier %q: unknown token %q", label, token)
+			}
+		}
+		p.tiers = append(p.tiers, t)
+	}
+
+	p.cutoff = 0
+	if cutoff != "" {
+		p.cutoff = -1
+		for i, t := range p.tiers {
+			if strings.EqualFold(t.label, cutoff) {
+				p.cutoff = i
+			}
+		}
+		if p.cutoff < 0 {
+			return nil, fmt.Errorf("quality cutoff %q is not one of the tiers", cutoff)
+		}
+	}
+	return p, nil
+}
+
+// Rank returns the index of the best tier q matches, lower is better, or
+// -1 if the profile doesn't accept q
+func (p *Profile) Rank(q Quality) int {
+	for i, t := range p.tiers {
+		if t.matches(q) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Accepts reports whether q matches any tier
+func (p *Profile) Accepts(q Quality) bool {
+	return p.Rank(q) >= 0
+}
+
+// IsUpgrade reports whether replacing a release of quality current with one
+// of quality candidate is worth it under this profile
+func (p *Profile) IsUpgrade(current, candidate Quality) bool {
+	if !p.Upgrade {
+		return false
+	}
+	candidateRank := p.Rank(candidate)
+	if candidateRank < 0 {
+		return false
+	}
+	currentRank := p.Rank(current)
+	if currentRank < 0 {
+		// Anything acceptable beats something the profile doesn't accept
+		return true
+	}
+	return currentRank > p.cutoff && candidateRank < currentRank
+}
+
+// SetDelays sets how long to wait for a better release after the first
+// release of an episode in a tier shows up, by tier label
+func (p *Profile) SetDelays(delays map[string]time.Duration) error {
+	for label, delay := range delays {
+		if delay < 0 {
+			return fmt.Errorf("delay of quality tier %q must not be negative", label)
+		}
+		found := false
+		for i := range p.tiers {
+			if strings.EqualFold(p.tiers[i].label, label) {
+				p.tiers[i].delay, p.tiers[i].delayed = delay, true
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("quality delay %q is not one of the tiers", label)
+		}
+	}
+	return nil
+}
+
+// Delay returns the delay of the best tier q matches, and whether one is set
+func (p *Profile) Delay(q Quality) (time.Duration, bool) {
+	if p == nil {
+		return 0, false
+	}
+	rank := p.Rank(q)
+	if rank < 0 {
+		return 0, false
+	}
+	return p.tiers[rank].delay, p.tiers[rank].delayed
+}
+
+// HasDelays reports whether any tier has a delay
+func (p *Profile) HasDelays() bool {
+	if p == nil {
+		return false
+	}
+	for _, t := range p.tiers {
+		if t.delay > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Tiers returns the tier labels in order of preference
+func (p *Profile) Tiers() []string {
+	labels := make([]string, len(p.tiers))
+	for i, t := range p.tiers {
+		labels[i] = t.label
+	}
+	return labels
+}
@@ -0,0 +1,134 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BurstWindow is how many requests' worth of budget can be saved up and
+// spent at once, expressed as time at the configured rate
+const BurstWindow = 10 * time.Second
+
+// Limiter hands out request budgets per hostname as token buckets. A rate
+// set for a host also covers its subdomains, so "tracker.example" limits
+// "www.tracker.example" and "download.tracker.example" as one budget.
+// Hosts without a rate aren't limited.
+type Limiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	rate   float64 // Tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func New() *Limiter {
+	return &Limiter{buckets: make(map[string]*bucket)}
+}
+
+// SetRate limits host and its subdomains to perMinute requests a minute. A
+// leading "www." is ignored. Zero or less removes the limit.
+func (l *Limiter) SetRate(host string, perMinute int) {
+	host = normalizeHost(host)
+	if host == "" {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if perMinute <= 0 {
+		delete(l.buckets, host)
+		return
+	}
+	rate := float64(perMinute) / 60
+	burst := max(1, rate*BurstWindow.Seconds())
+	// Hosts shared by several trackers get the strictest budget
+	if b, ok := l.buckets[host]; ok && b.rate <= rate {
+		return
+	}
+	l.buckets[host] = &bucket{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// Wait blocks until host may be sent another request or ctx is done
+func (l *Limiter) Wait(ctx context.Context, host string) error {
+	l.mu.Lock()
+	b := l.lookup(normalizeHost(host))
+	if b == nil {
+		l.mu.Unlock()
+		return nil
+	}
+
+	now := time.Now()
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	// Take the token right away, going into debt, so waiters queue up in order
+	b.tokens--
+	delay := time.Duration(-b.tokens / b.rate * float64(time.Second))
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// Give the token back, the request is never sent
+		l.mu.Lock()
+		b.tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// lookup finds the bucket of host or its closest parent domain
+func (l *Limiter) lookup(host string) *bucket {
+	for host != "" {
+		if b, ok := l.buckets[host]; ok {
+			return b
+		}
+		_, parent, found := strings.Cut(host, ".")
+		if !found {
+			break
+		}
+		host = parent
+	}
+	return nil
+}
+
+func normalizeHost(host string) string {
+	return strings.TrimPrefix(strings.ToLower(host), "www.")
+}
+
+// Transport wraps next so every request waits for its host's budget. A nil
+// limiter returns next unchanged.
+func Transport(next http.RoundTripper, l *Limiter) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if l == nil {
+		return next
+	}
+	return &transport{next: next, limiter: l}
+}
+
+type transport struct {
+	next    http.RoundTripper
+	limiter *Limiter
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.Wait(req.Context(), req.URL.Hostname()); err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(req)
+}
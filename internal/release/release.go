@@ -0,0 +1,266 @@
+package release
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"torrent-rss/internal/episode"
+)
+
+// Release is a scene name broken down into its parts, e.g.
+// "Show.Name.S01E02.1080p.NF.WEB-DL.DDP5.1.H.264-GROUP"
+type Release struct {
+	Title      string        // Show or movie title, separators replaced by spaces
+	Year       int           // Zero when the name has none
+	Episode    *episode.Info // Nil for movies and anything else without an episode marker
+	Resolution string        // 2160p, 1080p, 720p, 576p, 480p
+	Source     string        // WEB-DL, WEBRip, WEB, BluRay, HDTV, HDRip, DVDRip
+	Codec      string        // x264, x265, H.264, H.265, AV1, XviD
+	Audio      string        // e.g. DDP5.1, AAC2.0, TrueHD7.1
+	Service    string        // Streaming service tag, e.g. NF, AMZN
+	Group      string        // Release group
+	Version    int           // Anime release version, e.g. 2 for "012v2", zero otherwise
+	// Proper marks a fixed re-release of an earlier one: PROPER, REPACK,
+	// RERIP or a version after the first
+	Proper bool
+
+	clean string
+}
+
+type tag struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+var (
+	resolutionPattern = regexp.MustCompile(`(?i)\b(2160p|4k|uhd|1080[pi]|720p|576p|480p)\b`)
+	sources           = []tag{
+		{"WEB-DL", regexp.MustCompile(`(?i)\bWEB[ .-]?DL\b`)},
+		{"WEBRip", regexp.MustCompile(`(?i)\bWEB[ .-]?Rip\b`)},
+		{"BluRay", regexp.MustCompile(`(?i)\b(Blu[ .-]?Ray|BDRip|BRRip|BDRemux)\b`)},
+		{"HDTV", regexp.MustCompile(`(?i)\bHDTV\b`)},
+		{"HDRip", regexp.MustCompile(`(?i)\bHDRip\b`)},
+		{"DVDRip", regexp.MustCompile(`(?i)\b(DVDRip|DVD)\b`)},
+		{"WEB", regexp.MustCompile(`(?i)\bWEB\b`)},
+	}
+	codecs = []tag{
+		{"x265", regexp.MustCompile(`(?i)\bx[ .]?265\b`)},
+		{"x264", regexp.MustCompile(`(?i)\bx[ .]?264\b`)},
+		{"H.265", regexp.MustCompile(`(?i)\b(H[ .]?265|HEVC)\b`)},
+		{"H.264", regexp.MustCompile(`(?i)\b(H[ .]?264|AVC)\b`)},
+		{"AV1", regexp.MustCompile(`(?i)\bAV1\b`)},
+		{"XviD", regexp.MustCompile(`(?i)\bXviD\b`)},
+	}
+	// Services are matched case-sensitively, "Nf" or "Max" in a title is not a tag
+	services = []tag{
+		{"NF", regexp.MustCompile(`\bNF\b`)},
+		{"AMZN", regexp.MustCompile(`\bAMZN\b`)},
+		{"DSNP", regexp.MustCompile(`\bDSNP\b`)},
+		{"HMAX", regexp.MustCompile(`\bHMAX\b`)},
+		{"ATVP", regexp.MustCompile(`\bATVP\b`)},
+		{"HULU", regexp.MustCompile(`\bHULU\b`)},
+		{"PCOK", regexp.MustCompile(`\bPCOK\b`)},
+		{"PMTP", regexp.MustCompile(`\bPMTP\b`)},
+	}
+	// The audio codec, then optional channels written as "5.1", "5 1" or "51"
+	audioPattern = regexp.MustCompile(`(?i)\b(DDP|DD\+|DD|E-?AC-?3|AC-?3|AAC|DTS-HD[ .]MA|DTS|TrueHD|FLAC|Opus)([ .]?[1-9][ .]?[0-2])?(\W|$)`)
+	audioNames   = map[string]string{
+		"ddp": "DDP", "dd+": "DDP", "eac3": "DDP", "e-ac-3": "DDP", "e-ac3": "DDP", "eac-3": "DDP",
+		"dd": "DD", "ac3": "DD", "ac-3": "DD",
+		"aac": "AAC", "dts": "DTS", "dts-hd ma": "DTS-HD MA", "dts-hd.ma": "DTS-HD MA",
+		"truehd": "TrueHD", "flac": "FLAC", "opus": "Opus",
+	}
+	properPattern    = regexp.MustCompile(`(?i)\b(PROPER|REPACK[0-9]?|RERIP)\b`)
+	yearPattern      = regexp.MustCompile(`\b(?:19|20)\d{2}\b`)
+	groupPattern     = regexp.MustCompile(`-([A-Za-z0-9]+)$`)
+	extensionPattern = regexp.MustCompile(`(?i)\.(torrent|mkv|mp4|avi)$`)
+	separatorPattern = regexp.MustCompile(`[._]+`)
+	removedPattern   = regexp.MustCompile(`[ ._-]*\x00[ ._\x00-]*`)
+	nonWordPattern   = regexp.MustCompile(`[^a-z0-9]+`)
+	// Brackets only around removed tags, as in "[1080p]", go with them
+	removedBracketPattern = regexp.MustCompile(`[\[(][ ._]*\x00[ ._\x00]*[\])]`)
+
+	// Anime put the group in front and a checksum of the file last, as in
+	// "[Group] Title - 012v2 [1080p] [ABCD1234]"
+	groupPrefixPattern = regexp.MustCompile(`^\[([^\]]+)\][ _]*`)
+	versionPattern     = regexp.MustCompile(`(?i)(?:\b\d{1,4}|\[)v(\d{1,2})\b`)
+	checksumPattern    = regexp.MustCompile(`\[[0-9A-Fa-f]{8}\]`)
+)
+
+// Key identifies a release across trackers, which write the same scene name
+// with dots, spaces or dashes and in any case, e.g. "show name s01e02 1080p web dl"
+func Key(name string) string {
+	name = extensionPattern.ReplaceAllString(strings.TrimSpace(name), "")
+	return strings.TrimSpace(nonWordPattern.ReplaceAllString(strings.ToLower(name), " "))
+}
+
+// Parse breaks a release name down into its parts. Parts the name doesn't
+// mention are left empty.
+func Parse(name string) Release {
+	name = extensionPattern.ReplaceAllString(strings.TrimSpace(name), "")
+
+	var r Release
+	removed := make([]bool, len(name))
+	remove := func(loc []int) {
+		for i := loc[0]; i < loc[1]; i++ {
+			removed[i] = true
+		}
+	}
+	// The title ends where the first thing that isn't part of it starts
+	titleEnd := len(name)
+	mark := func(loc []int) {
+		titleEnd = min(titleEnd, loc[0])
+	}
+
+	for _, loc := range resolutionPattern.FindAllStringIndex(name, -1) {
+		if r.Resolution == "" {
+			r.Resolution = normalizeResolution(name[loc[0]:loc[1]])
+		}
+		remove(loc)
+		mark(loc)
+	}
+	r.Source = findTags(name, sources, remove, mark)
+	r.Codec = findTags(name, codecs, remove, mark)
+	r.Service = findTags(name, services, remove, mark)
+	for _, loc := range checksumPattern.FindAllStringIndex(name, -1) {
+		remove(loc)
+		mark(loc)
+	}
+	for _, m := range audioPattern.FindAllStringSubmatchIndex(name, -1) {
+		end := m[3]
+		if m[5] >= 0 {
+			end = m[5]
+		}
+		if r.Audio == "" {
+			r.Audio = normalizeAudio(name[m[2]:m[3]], name[m[3]:end])
+		}
+		remove([]int{m[0], end})
+		mark(m)
+	}
+
+	// Kept in the clean name, which tells the fix apart from the original
+	if m := versionPattern.FindStringSubmatch(name); m != nil {
+		r.Version, _ = strconv.Atoi(m[1])
+	}
+	r.Proper = properPattern.MatchString(name) || r.Version > 1
+
+	info, episodeLoc := episode.Find(name)
+	if episodeLoc != nil {
+		r.Episode = &info
+		mark(episodeLoc)
+	}
+	// The year is the last one before the tags, those before it are part of
+	// the title as in "Blade Runner 2049 2017", or else the first after them
+	var year []int
+	for _, loc := range yearPattern.FindAllStringIndex(name, -1) {
+		// A leading number is the title, as in "1917", and air dates belong to the episode
+		if loc[0] == 0 || (episodeLoc != nil && loc[0] >= episodeLoc[0] && loc[0] < episodeLoc[1]) {
+			continue
+		}
+		if loc[0] < titleEnd {
+			year = loc
+			continue
+		}
+		if year == nil {
+			year = loc
+		}
+		break
+	}
+	if year != nil {
+		r.Year, _ = strconv.Atoi(name[year[0]:year[1]])
+		mark(year)
+	}
+
+	// Only a name with tags has a group, "Spider-Man" alone is just a title
+	titleStart := 0
+	if m := groupPattern.FindStringSubmatchIndex(name); m != nil && titleEnd < m[0] {
+		r.Group = name[m[2]:m[3]]
+		remove(m[:2])
+	} else if m := groupPrefixPattern.FindStringSubmatchIndex(name); m != nil && m[1] < titleEnd {
+		r.Group = name[m[2]:m[3]]
+		remove(m[:2])
+		titleStart = m[1]
+	}
+
+	r.Title = cleanTitle(name[titleStart:titleEnd])
+	r.clean = cleanName(name, removed)
+	return r
+}
+
+// Clean returns the name without any technical tags or the group, keeping
+// title, year, episode and anything unrecognized, e.g. "Show.Name.S01E02"
+func (r Release) Clean() string {
+	return r.clean
+}
+
+func findTags(name string, tags []tag, remove, mark func([]int)) string {
+	found := ""
+	for _, t := range tags {
+		for _, loc := range t.pattern.FindAllStringIndex(name, -1) {
+			if found == "" {
+				found = t.name
+			}
+			remove(loc)
+			mark(loc)
+		}
+	}
+	return found
+}
+
+func normalizeResolution(value string) string {
+	switch strings.ToLower(value) {
+	case "4k", "uhd":
+		return "2160p"
+	case "1080i":
+		return "1080p"
+	default:
+		return strings.ToLower(value)
+	}
+}
+
+func normalizeAudio(codec, channels string) string {
+	name, ok := audioNames[strings.ToLower(codec)]
+	if !ok {
+		name = codec
+	}
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, channels)
+	if len(digits) != 2 {
+		return name
+	}
+	if strings.Contains(name, " ") {
+		name += " "
+	}
+	return name + digits[:1] + "." + digits[1:]
+}
+
+func cleanTitle(raw string) string {
+	title := separatorPattern.ReplaceAllString(raw, " ")
+	return strings.TrimSpace(strings.Trim(title, " -[]()"))
+}
+
+// cleanName drops the removed bytes of name, joining what's left with the
+// separator the name already uses
+func cleanName(name string, removed []bool) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if !removed[i] {
+			b.WriteByte(name[i])
+		} else if i == 0 || !removed[i-1] {
+			b.WriteByte(0)
+		}
+	}
+
+	separator := "."
+	if strings.Contains(name, " ") {
+		separator = " "
+	}
+	cleaned := removedBracketPattern.ReplaceAllString(b.String(), "\x00")
+	cleaned = removedPattern.ReplaceAllString(cleaned, separator)
+	return strings.Trim(cleaned, " ._-")
+}
@@ -0,0 +1,38 @@
+package release
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		want Release
+	}{
+		{"Show.Name.S01E02.1080p.NF.WEB-DL.DDP5.1.H.264-GROUP", Release{Title: "Show Name", Resolution: "1080p", Source: "WEB-DL", Codec: "H.264", Audio: "DDP5.1", Service: "NF", Group: "GROUP"}},
+		{"Movie.Name.2024.2160p.BluRay.x265-GROUP", Release{Title: "Movie Name", Year: 2024, Resolution: "2160p", Source: "BluRay", Codec: "x265", Group: "GROUP"}},
+		// A year in the title is kept, the year of release is the last one
+		{"Blade.Runner.2049.2017.1080p.BluRay.x264-GROUP", Release{Title: "Blade Runner 2049", Year: 2017, Resolution: "1080p", Source: "BluRay", Codec: "x264", Group: "GROUP"}},
+		{"Blade Runner 2049 (2017) [1080p]", Release{Title: "Blade Runner 2049", Year: 2017, Resolution: "1080p"}},
+		{"2001.A.Space.Odyssey.1968.1080p.BluRay-GROUP", Release{Title: "2001 A Space Odyssey", Year: 1968, Resolution: "1080p", Source: "BluRay", Group: "GROUP"}},
+		{"1917.2019.720p.WEBRip-GROUP", Release{Title: "1917", Year: 2019, Resolution: "720p", Source: "WEBRip", Group: "GROUP"}},
+		{"Movie.Name.1080p.WEB.2021-GROUP", Release{Title: "Movie Name", Year: 2021, Resolution: "1080p", Source: "WEB", Group: "GROUP"}},
+		{"Show.Name.2019.S01E01.720p.HDTV.x264-GROUP", Release{Title: "Show Name", Year: 2019, Resolution: "720p", Source: "HDTV", Codec: "x264", Group: "GROUP"}},
+		{"Show.Name.S01E02.PROPER.1080p.WEB-GROUP", Release{Title: "Show Name", Resolution: "1080p", Source: "WEB", Group: "GROUP", Proper: true}},
+		{"[Group] Title - 012v2 [1080p] [ABCD1234]", Release{Title: "Title", Resolution: "1080p", Group: "Group", Version: 2, Proper: true}},
+		{"Spider-Man", Release{Title: "Spider-Man"}},
+	}
+	for _, tt := range tests {
+		got := Parse(tt.name)
+		got.Episode, got.clean = nil, ""
+		if got != tt.want {
+			t.Errorf("Parse(%q) =\n  %+v, want\n  %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestKey(t *testing.T) {
+	for _, name := range []string{"Show.Name.S01E02.1080p.WEB-DL", "show name s01e02 1080p web dl", "Show Name S01E02 1080p WEB-DL.torrent"} {
+		if got := Key(name); got != "show name s01e02 1080p web dl" {
+			t.Errorf("Key(%q) = %q", name, got)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package release
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Fields are what a name template sees of a release. Parts the name
+// doesn't mention are empty, so `{{with .Year}} ({{.}}){{end}}` leaves
+// nothing behind for names without one.
+type Fields struct {
+	Title      string
+	Year       string
+	Season     string // Two digits, e.g. "01"; empty for anime numbered absolutely
+	Episode    string // Two digits, or three above 99; empty for season packs
+	Date       string // Air date of daily shows, e.g. "2024-03-01"
+	Code       string // "S01E02", "S01E01-E03", "S01" for season packs, "E12" for anime or the air date
+	Resolution string
+	Source     string
+	Codec      string
+	Audio      string
+	Service    string
+	Group      string
+	Clean      string // The name without tags, as saved without a template
+	Original   string // The name as the tracker gave it, without extension
+}
+
+// Template names files after the parts of their release name, e.g.
+// `{{.Title}} {{.Code}} [{{.Resolution}}]`
+type Template struct {
+	text string
+	tmpl *template.Template
+}
+
+var (
+	// Characters that aren't allowed in filenames on some platform
+	unsafePattern = regexp.MustCompile(`[/\\:*?"<>|\x00-\x1f]`)
+	emptyPattern  = regexp.MustCompile(`\[\s*\]|\(\s*\)|\{\s*\}`)
+	spacePattern  = regexp.MustCompile(`\s+`)
+)
+
+// templateExample is rendered when a template is parsed, to catch mistakes
+const templateExample = "Show.Name.2024.S01E02.1080p.NF.WEB-DL.DDP5.1.H.264-GROUP"
+
+// NewTemplate parses a name template, checking it against an example
+// release so unknown fields are caught right away
+func NewTemplate(text string) (*Template, error) {
+	tmpl, err := template.New("name").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid name template: %w", err)
+	}
+	t := &Template{text: text, tmpl: tmpl}
+	if _, err := t.execute(templateExample, Parse(templateExample)); err != nil {
+		return nil, fmt.Errorf("invalid name template: %w", err)
+	}
+	return t, nil
+}
+
+// String returns the template as written
+func (t *Template) String() string {
+	return t.text
+}
+
+// Name renders the template for a release name, without extension. Path
+// separators and characters filenames can't hold are dropped, as are
+// brackets left empty by missing parts. It returns "" when nothing is left.
+func (t *Template) Name(name string) string {
+	name = extensionPattern.ReplaceAllString(strings.TrimSpace(name), "")
+	rendered, err := t.execute(name, Parse(name))
+	if err != nil {
+		return ""
+	}
+	rendered = unsafePattern.ReplaceAllString(rendered, " ")
+	rendered = emptyPattern.ReplaceAllString(rendered, "")
+	rendered = spacePattern.ReplaceAllString(rendered, " ")
+	return strings.Trim(rendered, " .-_")
+}
+
+func (t *Template) execute(name string, r Release) (string, error) {
+	fields := Fields{
+		Title:      r.Title,
+		Resolution: r.Resolution,
+		Source:     r.Source,
+		Codec:      r.Codec,
+		Audio:      r.Audio,
+		Service:    r.Service,
+		Group:      r.Group,
+		Clean:      r.Clean(),
+		Original:   name,
+	}
+	if r.Year != 0 {
+		fields.Year = strconv.Itoa(r.Year)
+	}
+	if info := r.Episode; info != nil {
+		fields.Code = info.Code()
+		if info.Date.IsZero() {
+			// Anime count episodes without seasons
+			if !info.Absolute {
+				fields.Season = fmt.Sprintf("%02d", info.Season)
+			}
+			if !info.Pack {
+				fields.Episode = fmt.Sprintf("%02d", info.Episode)
+			}
+		} else {
+			fields.Date = info.Date.Format("2006-01-02")
+		}
+	}
+
+	var b strings.Builder
+	if err := t.tmpl.Execute(&b, fields); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,171 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Policy controls how often and how patiently a request is retried
+type Policy struct {
+	Attempts   int           // Total tries including the first, 1 disables retries
+	Backoff    time.Duration // Delay before the first retry, doubled every time
+	MaxBackoff time.Duration // Upper bound for a single delay
+}
+
+// DefaultPolicy tries three times, waiting up to 1s and then up to 2s
+func DefaultPolicy() Policy {
+	return Policy{
+		Attempts:   3,
+		Backoff:    time.Second,
+		MaxBackoff: 30 * time.Second,
+	}
+}
+
+// Retryable reports whether a response status is worth trying again. Rate
+// limits and server errors are; auth failures and missing pages aren't.
+func Retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status == http.StatusRequestTimeout || status >= 500
+}
+
+// Transport wraps next so that failed requests are retried according to the
+// policy. Requests with a body are only retried if it can be rewound.
+func Transport(next http.RoundTripper, policy Policy) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if policy.Attempts < 1 {
+		policy.Attempts = 1
+	}
+	return &transport{next: next, policy: policy}
+}
+
+type transport struct {
+	next   http.RoundTripper
+	policy Policy
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	canRewind := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	for attempt := 1; ; attempt++ {
+		resp, err := t.next.RoundTrip(req)
+
+		last := attempt >= t.policy.Attempts || !canRewind
+		switch {
+		case err != nil:
+			// Cancellation is the caller giving up, not a transient failure
+			if last || ctx.Err() != nil || isPermanent(err) {
+				return nil, attemptsError(attempt, err)
+			}
+		case !Retryable(resp.StatusCode) || last:
+			return resp, nil
+		}
+
+		delay := t.delay(attempt, resp)
+		if resp != nil {
+			resp.Body.Close()
+		}
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("failed to rewind request body: %w", err)
+			}
+			req = req.Clone(ctx)
+			req.Body = body
+		}
+	}
+}
+
+// delay is the exponential backoff with jitter, or what the server asked
+// for in Retry-After
+func (t *transport) delay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if d, ok := RetryAfter(resp.Header.Get("retry-after")); ok {
+			if t.policy.MaxBackoff > 0 {
+				d = min(d, t.policy.MaxBackoff)
+			}
+			return d
+		}
+	}
+	return t.policy.backoff(attempt)
+}
+
+// backoff is the exponential backoff with jitter before the retry following
+// attempt
+func (p Policy) backoff(attempt int) time.Duration {
+	backoff := p.Backoff << (attempt - 1)
+	if backoff <= 0 || (p.MaxBackoff > 0 && backoff > p.MaxBackoff) {
+		backoff = p.MaxBackoff
+	}
+	if backoff <= 0 {
+		return 0
+	}
+	return backoff/2 + rand.N(backoff/2+1)
+}
+
+// Do calls fn until it succeeds, following the policy, for work that isn't
+// an HTTP request. Errors implementing Permanent() bool aren't retried.
+func Do(ctx context.Context, policy Policy, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if attempt >= policy.Attempts || ctx.Err() != nil || isPermanent(err) {
+			return attemptsError(attempt, err)
+		}
+		if err := sleep(ctx, policy.backoff(attempt)); err != nil {
+			return err
+		}
+	}
+}
+
+// RetryAfter reads a Retry-After header, in seconds or as a date
+func RetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// isPermanent reports whether err says retrying can't help, by implementing
+// Permanent() bool
+func isPermanent(err error) bool {
+	var permanent interface{ Permanent() bool }
+	return errors.As(err, &permanent) && permanent.Permanent()
+}
+
+func attemptsError(attempts int, err error) error {
+	if attempts == 1 || errors.Is(err, context.Canceled) {
+		return err
+	}
+	return fmt.Errorf("giving up after %d attempts: %w", attempts, err)
+}
@@ -0,0 +1,122 @@
+package score
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"torrent-rss/internal/models"
+	"torrent-rss/internal/quality"
+	"torrent-rss/internal/release"
+)
+
+// Options are the points a release earns for each of its traits. Traits
+// that aren't listed earn nothing; points may be negative.
+type Options struct {
+	Resolution map[string]int // e.g. 1080p: 30, 2160p: 10
+	Source     map[string]int // e.g. WEB-DL: 20, HDTV: -10
+	Group      map[string]int // e.g. NTb: 15, case-insensitive
+	Freeleech  int            // For releases the feed marks as freeleech
+	// TargetSize earns Size points for a release of exactly that size, less
+	// the further it's off, down to none at half or twice the size
+	TargetSize int64
+	Size       int
+	// Window is how long releases of an episode are collected after the
+	// first one shows up before the best of them is grabbed, 0 only
+	// compares releases within the same poll
+	Window time.Duration
+}
+
+// Profile scores releases so the best of several candidates can be grabbed
+type Profile struct {
+	opts Options
+}
+
+// New checks that every resolution and source is one releases are parsed to
+func New(opts Options) (*Profile, error) {
+	p := &Profile{opts: opts}
+	p.opts.Resolution = make(map[string]int, len(opts.Resolution))
+	for name, points := range opts.Resolution {
+		resolution := quality.Parse(name).Resolution
+		if resolution == "" {
+			return nil, fmt.Errorf("unknown resolution %q", name)
+		}
+		p.opts.Resolution[resolution] = points
+	}
+	p.opts.Source = make(map[string]int, len(opts.Source))
+	for name, points := range opts.Source {
+		source := quality.Parse(name).Source
+		if source == "" {
+			return nil, fmt.Errorf("unknown source %q", name)
+		}
+		p.opts.Source[source] = points
+	}
+	p.opts.Group = make(map[string]int, len(opts.Group))
+	for name, points := range opts.Group {
+		p.opts.Group[strings.ToLower(name)] = points
+	}
+	if opts.TargetSize < 0 {
+		return nil, fmt.Errorf("target size must not be negative")
+	}
+	if opts.Size != 0 && opts.TargetSize == 0 {
+		return nil, fmt.Errorf("size points need a target size")
+	}
+	if opts.Window < 0 {
+		return nil, fmt.Errorf("window must not be negative")
+	}
+	return p, nil
+}
+
+// Window is how long candidates are collected, see Options.Window
+func (p *Profile) Window() time.Duration {
+	return p.opts.Window
+}
+
+// Score adds up the points item earns. Only what the feed says counts:
+// freeleech releases must be marked as such in the feed, and releases of
+// unknown size earn no size points.
+func (p *Profile) Score(item models.Item) int {
+	r := release.Parse(item.Title)
+	score := p.opts.Resolution[r.Resolution] + p.opts.Source[r.Source]
+	if r.Group != "" {
+		score += p.opts.Group[strings.ToLower(r.Group)]
+	}
+	if item.Freeleech {
+		score += p.opts.Freeleech
+	}
+	return score + p.sizePoints(item.Size)
+}
+
+// sizePoints scales Size by how close size is to TargetSize. Being off is
+// measured as a ratio, so 2 GB for a 4 GB target is as far off as 8 GB.
+func (p *Profile) sizePoints(size int64) int {
+	if p.opts.Size == 0 || size <= 0 {
+		return 0
+	}
+	ratio := float64(size) / float64(p.opts.TargetSize)
+	if ratio < 1 {
+		ratio = 1 / ratio
+	}
+	if ratio >= 2 {
+		return 0
+	}
+	return int(float64(p.opts.Size) * (2 - ratio))
+}
+
+// ParsePoints reads traits and their points such as "1080p=30,720p=10"
+func ParsePoints(value string) (map[string]int, error) {
+	points := make(map[string]int)
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		name, number, ok := strings.Cut(part, "=")
+		n, err := strconv.Atoi(strings.TrimSpace(number))
+		if !ok || err != nil || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("must be name=points pairs like 1080p=30, got %q", part)
+		}
+		points[strings.TrimSpace(name)] = n
+	}
+	return points, nil
+}
@@ -0,0 +1,131 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"torrent-rss/internal/credentials"
+)
+
+// Prefix marks a value sealed with EncryptString, e.g. "enc:q83v..."
+const Prefix = "enc:"
+
+// KeyringAccount is the OS keyring account the master key is read from
+const KeyringAccount = "secret_key"
+
+// ErrNoKey means a value is encrypted but no master key is configured
+var ErrNoKey = errors.New("value is encrypted but no master key is set (TD_SECRET_KEY or keyring account " + KeyringAccount + ")")
+
+// Box encrypts secrets with AES-256-GCM under a key derived from a
+// passphrase. A nil Box leaves values as they are.
+type Box struct {
+	key []byte
+}
+
+// New returns a Box for the passphrase, or nil if it's empty
+func New(passphrase string) *Box {
+	if passphrase == "" {
+		return nil
+	}
+	sum := sha256.Sum256([]byte(passphrase))
+	return &Box{key: sum[:]}
+}
+
+// MasterKey returns the passphrase secrets are encrypted with, from
+// TD_SECRET_KEY or else the OS keyring under the torrent-rss service.
+// It's empty when neither has one.
+func MasterKey() (string, error) {
+	if key := os.Getenv("TD_SECRET_KEY"); key != "" {
+		return key, nil
+	}
+	key, err := credentials.Keyring{Service: "torrent-rss"}.Lookup(KeyringAccount)
+	if err != nil {
+		return "", fmt.Errorf("failed to read master key: %w", err)
+	}
+	return key, nil
+}
+
+// Encrypt seals data, prepending the random nonce
+func (b *Box) Encrypt(data []byte) ([]byte, error) {
+	if b == nil {
+		return data, nil
+	}
+	gcm, err := b.gcm()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, data, nil), nil
+}
+
+// Decrypt opens data sealed by Encrypt
+func (b *Box) Decrypt(data []byte) ([]byte, error) {
+	if b == nil {
+		return data, nil
+	}
+	gcm, err := b.gcm()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	nonce, sealed := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, sealed, nil)
+}
+
+// EncryptString seals a value into text that can go in a config file
+func (b *Box) EncryptString(value string) (string, error) {
+	if b == nil {
+		return "", ErrNoKey
+	}
+	sealed, err := b.Encrypt([]byte(value))
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt: %w", err)
+	}
+	return Prefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptString opens a value sealed by EncryptString. Values without the
+// prefix are plaintext and returned as they are.
+func (b *Box) DecryptString(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	if b == nil {
+		return "", ErrNoKey
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(value, Prefix))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt: %w", err)
+	}
+	data, err := b.Decrypt(sealed)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt (wrong key?): %w", err)
+	}
+	return string(data), nil
+}
+
+// IsEncrypted reports whether a value was sealed by EncryptString
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, Prefix)
+}
+
+func (b *Box) gcm() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(b.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,83 @@
+// Package service installs the daemon as a background service of the
+// Windows service manager or macOS launchd. Linux has systemd for that,
+// which needs no installer.
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Name is the name of the service, and its launchd label
+const Name = "torrent-rss"
+
+// DirEnv is the working directory of a Windows service, which the service
+// manager starts in the system directory
+const DirEnv = "TD_SERVICE_DIR"
+
+// LogFile is where a Windows service writes its output, in its working
+// directory, as services have no console
+const LogFile = "torrent-rss.log"
+
+// ErrUnsupported means the platform has no service manager this package
+// knows, like Linux, where a systemd unit does the job
+var ErrUnsupported = errors.New("no supported service manager on this platform, see the systemd section of the README")
+
+// ErrNotInstalled is returned when the service isn't installed
+var ErrNotInstalled = errors.New("service is not installed")
+
+// Config is the service to install
+type Config struct {
+	Executable string   // Absolute path of the binary
+	Args       []string // e.g. daemon
+	// Dir is the working directory, where .env is read from
+	Dir string
+	// Env are variables set for the service on top of .env, like TD_CONFIG
+	Env map[string]string
+}
+
+// Install registers the service to start at boot on Windows, or at login
+// on macOS. It doesn't start it.
+func Install(c Config) error {
+	if c.Executable == "" || c.Dir == "" {
+		return fmt.Errorf("service needs an executable and a working directory")
+	}
+	return install(c)
+}
+
+// Uninstall stops the service if it runs and removes it
+func Uninstall() error {
+	return uninstall()
+}
+
+// Start starts the installed service
+func Start() error {
+	return start()
+}
+
+// Stop stops the service until it's started again, or the next boot or
+// login
+func Stop() error {
+	return stop()
+}
+
+// Setup moves into the working directory of a service started by the
+// Windows service manager and sends its output to LogFile. Call it first,
+// before .env is read or anything is printed.
+func Setup() error {
+	if dir := os.Getenv(DirEnv); dir != "" {
+		if err := os.Chdir(dir); err != nil {
+			return fmt.Errorf("failed to enter service directory: %w", err)
+		}
+	}
+	return setupOutput()
+}
+
+// Attach returns a context that ends when the service manager stops the
+// service, and a function to call once the daemon has shut down. Outside a
+// Windows service it returns ctx and a no-op.
+func Attach(ctx context.Context) (context.Context, func()) {
+	return attach(ctx)
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// install writes a launch agent, which launchd loads at every login of the
+// user and restarts when it crashes
+func install(c Config) error {
+	path, err := plistPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("service %s is installed already at %s", Name, path)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+	logPath := filepath.Join(home, "Library", "Logs", LogFile)
+
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	b.WriteString(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n")
+	b.WriteString("<plist version=\"1.0\">\n<dict>\n")
+	plistString(&b, "Label", Name)
+	b.WriteString("\t<key>ProgramArguments</key>\n\t<array>\n")
+	for _, arg := range append([]string{c.Executable}, c.Args...) {
+		fmt.Fprintf(&b, "\t\t<string>%s</string>\n", escape(arg))
+	}
+	b.WriteString("\t</array>\n")
+	plistString(&b, "WorkingDirectory", c.Dir)
+	if len(c.Env) > 0 {
+		keys := make([]string, 0, len(c.Env))
+		for key := range c.Env {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		b.WriteString("\t<key>EnvironmentVariables</key>\n\t<dict>\n")
+		for _, key := range keys {
+			fmt.Fprintf(&b, "\t\t<key>%s</key>\n\t\t<string>%s</string>\n", escape(key), escape(c.Env[key]))
+		}
+		b.WriteString("\t</dict>\n")
+	}
+	b.WriteString("\t<key>RunAtLoad</key>\n\t<true/>\n")
+	// Restarted after a crash, but not after stopping on SIGTERM
+	b.WriteString("\t<key>KeepAlive</key>\n\t<dict>\n\t\t<key>SuccessfulExit</key>\n\t\t<false/>\n\t</dict>\n")
+	plistString(&b, "StandardOutPath", logPath)
+	plistString(&b, "StandardErrorPath", logPath)
+	b.WriteString("</dict>\n</plist>\n")
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create launch agents directory: %w", err)
+	}
+	if err := os.WriteFile(path, b.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write launch agent: %w", err)
+	}
+	return nil
+}
+
+func uninstall() error {
+	path, err := plistPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return ErrNotInstalled
+	}
+	if err := stop(); err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove launch agent: %w", err)
+	}
+	return nil
+}
+
+// start loads the launch agent, which starts it, or starts it again when
+// it's loaded already
+func start() error {
+	path, err := plistPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return ErrNotInstalled
+	}
+	if loaded() {
+		return launchctl("kickstart", target())
+	}
+	return launchctl("bootstrap", domain(), path)
+}
+
+// stop unloads the launch agent, so it isn't restarted until it's started
+// again or the user logs in anew
+func stop() error {
+	if !loaded() {
+		return nil
+	}
+	return launchctl("bootout", target())
+}
+
+func plistPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "Library", "LaunchAgents", Name+".plist"), nil
+}
+
+// domain is the launchd domain of the user's login session
+func domain() string {
+	return fmt.Sprintf("gui/%d", os.Getuid())
+}
+
+func target() string {
+	return domain() + "/" + Name
+}
+
+func loaded() bool {
+	return exec.Command("launchctl", "print", target()).Run() == nil
+}
+
+func launchctl(args ...string) error {
+	out, err := exec.Command("launchctl", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("launchctl %s failed: %w: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func plistString(b *bytes.Buffer, key, value string) {
+	fmt.Fprintf(b, "\t<key>%s</key>\n\t<string>%s</string>\n", key, escape(value))
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// launchd stops agents with SIGTERM, which the daemon handles already
+func setupOutput() error {
+	return nil
+}
+
+func attach(ctx context.Context) (context.Context, func()) {
+	return ctx, func() {}
+}
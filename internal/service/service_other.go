@@ -0,0 +1,29 @@
+//go:build !windows && !darwin
+
+package service
+
+import "context"
+
+func install(Config) error {
+	return ErrUnsupported
+}
+
+func uninstall() error {
+	return ErrUnsupported
+}
+
+func start() error {
+	return ErrUnsupported
+}
+
+func stop() error {
+	return ErrUnsupported
+}
+
+func setupOutput() error {
+	return nil
+}
+
+func attach(ctx context.Context) (context.Context, func()) {
+	return ctx, func() {}
+}
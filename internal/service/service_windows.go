@@ -0,0 +1,223 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// stopTimeout is how long stopping waits for the daemon to shut down
+const stopTimeout = 30 * time.Second
+
+func install(c Config) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the service manager, run as administrator: %w", err)
+	}
+	defer m.Disconnect()
+
+	if s, err := m.OpenService(Name); err == nil {
+		s.Close()
+		return fmt.Errorf("service %s is installed already", Name)
+	}
+	s, err := m.CreateService(Name, c.Executable, mgr.Config{
+		DisplayName: "torrent-rss",
+		Description: "Polls torrent RSS feeds and downloads matching releases",
+		StartType:   mgr.StartAutomatic,
+	}, c.Args...)
+	if err != nil {
+		return fmt.Errorf("failed to create service: %w", err)
+	}
+	defer s.Close()
+
+	// Restart after a crash, a minute later
+	if err := s.SetRecoveryActions([]mgr.RecoveryAction{{Type: mgr.ServiceRestart, Delay: time.Minute}}, uint32((24 * time.Hour).Seconds())); err != nil {
+		s.Delete()
+		return fmt.Errorf("failed to set recovery actions: %w", err)
+	}
+	if err := setEnvironment(c); err != nil {
+		s.Delete()
+		return err
+	}
+	return nil
+}
+
+// setEnvironment stores the variables the service manager starts the
+// service with, which it reads from the service's registry key
+func setEnvironment(c Config) error {
+	env := []string{DirEnv + "=" + c.Dir}
+	for key, value := range c.Env {
+		env = append(env, key+"="+value)
+	}
+	sort.Strings(env[1:])
+
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, `SYSTEM\CurrentControlSet\Services\`+Name, registry.SET_VALUE)
+	if err != nil {
+		return fmt.Errorf("failed to open service registry key: %w", err)
+	}
+	defer key.Close()
+	if err := key.SetStringsValue("Environment", env); err != nil {
+		return fmt.Errorf("failed to set service environment: %w", err)
+	}
+	return nil
+}
+
+func uninstall() error {
+	m, s, err := open()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	defer s.Close()
+
+	if err := stopService(s); err != nil {
+		return err
+	}
+	if err := s.Delete(); err != nil {
+		return fmt.Errorf("failed to delete service: %w", err)
+	}
+	return nil
+}
+
+func start() error {
+	m, s, err := open()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	defer s.Close()
+
+	if err := s.Start(); err != nil {
+		if errors.Is(err, windows.ERROR_SERVICE_ALREADY_RUNNING) {
+			return nil
+		}
+		return fmt.Errorf("failed to start service: %w", err)
+	}
+	return nil
+}
+
+func stop() error {
+	m, s, err := open()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	defer s.Close()
+	return stopService(s)
+}
+
+func open() (*mgr.Mgr, *mgr.Service, error) {
+	m, err := mgr.Connect()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to the service manager, run as administrator: %w", err)
+	}
+	s, err := m.OpenService(Name)
+	if err != nil {
+		m.Disconnect()
+		if errors.Is(err, windows.ERROR_SERVICE_DOES_NOT_EXIST) {
+			return nil, nil, ErrNotInstalled
+		}
+		return nil, nil, fmt.Errorf("failed to open service: %w", err)
+	}
+	return m, s, nil
+}
+
+// stopService asks a running service to stop and waits until it did
+func stopService(s *mgr.Service) error {
+	status, err := s.Query()
+	if err != nil {
+		return fmt.Errorf("failed to query service: %w", err)
+	}
+	if status.State == svc.Stopped {
+		return nil
+	}
+	if status.State != svc.StopPending {
+		if status, err = s.Control(svc.Stop); err != nil {
+			return fmt.Errorf("failed to stop service: %w", err)
+		}
+	}
+
+	deadline := time.Now().Add(stopTimeout)
+	for status.State != svc.Stopped {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("service didn't stop within %s", stopTimeout)
+		}
+		time.Sleep(300 * time.Millisecond)
+		if status, err = s.Query(); err != nil {
+			return fmt.Errorf("failed to query service: %w", err)
+		}
+	}
+	return nil
+}
+
+func setupOutput() error {
+	if isService, err := svc.IsWindowsService(); err != nil || !isService {
+		return err
+	}
+	f, err := os.OpenFile(LogFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open service log: %w", err)
+	}
+	os.Stdout = f
+	os.Stderr = f
+	log.SetOutput(f)
+	return nil
+}
+
+func attach(ctx context.Context) (context.Context, func()) {
+	if isService, err := svc.IsWindowsService(); err != nil || !isService {
+		return ctx, func() {}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	h := &handler{stop: cancel, done: make(chan struct{})}
+	reported := make(chan struct{})
+	go func() {
+		defer close(reported)
+		if err := svc.Run(Name, h); err != nil {
+			fmt.Fprintf(os.Stderr, "service manager: %v\n", err)
+		}
+		cancel()
+	}()
+	// The process must not exit before the service manager heard it stopped
+	return ctx, func() {
+		close(h.done)
+		<-reported
+	}
+}
+
+// handler reports the daemon running to the service manager, and stopped
+// once it shut down after a stop request
+type handler struct {
+	stop func()
+	done chan struct{}
+}
+
+func (h *handler) Execute(_ []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	const accepts = svc.AcceptStop | svc.AcceptShutdown
+	status <- svc.Status{State: svc.Running, Accepts: accepts}
+	for {
+		select {
+		case <-h.done:
+			// Run reports the service stopped once this returns
+			return false, 0
+		case r := <-requests:
+			switch r.Cmd {
+			case svc.Interrogate:
+				status <- r.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending, WaitHint: uint32(stopTimeout.Milliseconds())}
+				h.stop()
+			}
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package systemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Notify sends a state such as "READY=1" to the service manager over the
+// sd_notify protocol. Outside of a Type=notify unit it does nothing.
+func Notify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	// A leading @ is a socket in the abstract namespace
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("failed to connect to systemd: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("failed to notify systemd: %w", err)
+	}
+	return nil
+}
+
+// Ready tells systemd the service has started
+func Ready() error {
+	return Notify("READY=1")
+}
+
+// Reloading tells systemd the service is reloading its configuration, until
+// it's Ready again
+func Reloading() error {
+	return Notify("RELOADING=1")
+}
+
+// Stopping tells systemd the service is shutting down
+func Stopping() error {
+	return Notify("STOPPING=1")
+}
+
+// Status sets the status line shown by systemctl status
+func Status(text string) error {
+	return Notify("STATUS=" + text)
+}
+
+// Watchdog tells systemd the service is still alive
+func Watchdog() error {
+	return Notify("WATCHDOG=1")
+}
+
+// WatchdogInterval returns how often systemd expects a watchdog ping, or
+// false when the unit has no WatchdogSec or it's meant for another process
+func WatchdogInterval() (time.Duration, bool) {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0, false
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0, false
+	}
+	return time.Duration(usec) * time.Microsecond, true
+}
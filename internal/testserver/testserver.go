@@ -0,0 +1,328 @@
+// Package testserver runs a fake TorrentDay-style tracker on a local port, so
+// the whole pipeline can be exercised without touching a real site
+package testserver
+
+import (
+	"crypto/sha1"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"torrent-rss/internal/bencode"
+	"torrent-rss/internal/credentials"
+)
+
+// pieceLength is the piece size of generated torrents
+const pieceLength = 256 << 10
+
+// Torrent is a release the tracker lists
+type Torrent struct {
+	ID        string
+	Title     string
+	Size      int64 // Content size, 1 MiB when zero
+	Freeleech bool  // Marks the title and the torrent page
+	Public    bool  // Leaves the private flag out of the generated torrent
+	// Data is served as the .torrent file instead of a generated one, for
+	// broken or HTML downloads
+	Data []byte
+}
+
+// Options sets the credentials the tracker accepts, any left empty get a
+// fixed test value
+type Options struct {
+	Credentials credentials.Credentials
+	// Enclosures puts the download URL in each feed item, otherwise only
+	// the torrent page is linked and the download link has to be scraped
+	Enclosures bool
+}
+
+// Server is a running fake tracker. It serves the RSS feed at /t.rss, torrent
+// pages at /torrent.php?id= with an a.dl_Btn download link, and .torrent
+// files under /download.php/. The feed checks the RSS token, pages and
+// downloads the uid and pass cookies, redirecting to /login.php without them.
+type Server struct {
+	URL   string
+	Creds credentials.Credentials
+
+	srv        *httptest.Server
+	enclosures bool
+
+	mu          sync.Mutex
+	torrents    []Torrent
+	downloads   map[string]int
+	pages       map[string]int
+	maintenance bool
+}
+
+// New starts a tracker listing torrents, newest first. Close stops it.
+func New(opts Options, torrents ...Torrent) *Server {
+	creds := opts.Credentials
+	if creds.UserID == "" {
+		creds.UserID = "1234"
+	}
+	if creds.PassToken == "" {
+		creds.PassToken = "pass-token"
+	}
+	if creds.RSSToken == "" {
+		creds.RSSToken = "rss-token"
+	}
+
+	s := &Server{
+		Creds:      creds,
+		enclosures: opts.Enclosures,
+		torrents:   append([]Torrent(nil), torrents...),
+		downloads:  make(map[string]int),
+		pages:      make(map[string]int),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/t.rss", s.serveFeed)
+	mux.HandleFunc("/torrent.php", s.servePage)
+	mux.HandleFunc("/download.php/", s.serveDownload)
+	mux.HandleFunc("/login.php", s.serveLogin)
+	s.srv = httptest.NewServer(s.maintenanceMode(mux))
+	s.URL = s.srv.URL
+	return s
+}
+
+// Close shuts the tracker down
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// RSSURL is the feed URL with the RSS token, as TorrentDay hands it out
+func (s *Server) RSSURL() string {
+	return s.URL + "/t.rss?7;u=" + s.Creds.UserID + ";tp=" + s.Creds.RSSToken + ";private"
+}
+
+// Cookie is the session cookie pages and downloads need
+func (s *Server) Cookie() string {
+	return s.Creds.Cookie()
+}
+
+// Add lists another torrent at the top of the feed
+func (s *Server) Add(t Torrent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.torrents = append([]Torrent{t}, s.torrents...)
+}
+
+// Downloads reports how often a torrent's file was downloaded
+func (s *Server) Downloads(id string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.downloads[id]
+}
+
+// Pages reports how often a torrent's page was viewed
+func (s *Server) Pages(id string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pages[id]
+}
+
+// SetMaintenance makes every request answer with a maintenance page, or
+// back to normal
+func (s *Server) SetMaintenance(on bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maintenance = on
+}
+
+func (s *Server) maintenanceMode(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		down := s.maintenance
+		s.mu.Unlock()
+		if !down {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("content-type", "text/html; charset=utf-8")
+		w.Header().Set("retry-after", "60")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(w, "<html><head><title>Down for maintenance</title></head><body>We'll be back soon.</body></html>")
+	})
+}
+
+func (s *Server) torrent(id string) (Torrent, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.torrents {
+		if t.ID == id {
+			return t, true
+		}
+	}
+	return Torrent{}, false
+}
+
+type rss struct {
+	XMLName xml.Name `xml:"rss"`
+	Version string   `xml:"version,attr"`
+	Title   string   `xml:"channel>title"`
+	Items   []item   `xml:"channel>item"`
+}
+
+type item struct {
+	Title     string     `xml:"title"`
+	Link      string     `xml:"link"`
+	GUID      string     `xml:"guid"`
+	PubDate   string     `xml:"pubDate"`
+	Enclosure *enclosure `xml:"enclosure"`
+}
+
+type enclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+// serveFeed lists the torrents, with the RSS token from the
+// semicolon-separated query
+func (s *Server) serveFeed(w http.ResponseWriter, r *http.Request) {
+	params := make(map[string]string)
+	for _, param := range strings.Split(r.URL.RawQuery, ";") {
+		key, value, _ := strings.Cut(param, "=")
+		params[key] = value
+	}
+	if params["u"] != s.Creds.UserID || params["tp"] != s.Creds.RSSToken {
+		http.Error(w, "invalid RSS token", http.StatusForbidden)
+		return
+	}
+
+	s.mu.Lock()
+	torrents := append([]Torrent(nil), s.torrents...)
+	s.mu.Unlock()
+
+	feed := rss{Version: "2.0", Title: "TorrentDay"}
+	published := time.Now().UTC()
+	for i, t := range torrents {
+		title := t.Title
+		if t.Freeleech {
+			title += " [FL]"
+		}
+		it := item{
+			Title:   title,
+			Link:    s.URL + "/torrent.php?id=" + t.ID,
+			GUID:    s.URL + "/torrent.php?id=" + t.ID,
+			PubDate: published.Add(-time.Duration(i) * time.Minute).Format(time.RFC1123Z),
+		}
+		if s.enclosures {
+			it.Enclosure = &enclosure{URL: s.downloadURL(t), Length: size(t), Type: "application/x-bittorrent"}
+		}
+		feed.Items = append(feed.Items, it)
+	}
+
+	w.Header().Set("content-type", "application/rss+xml; charset=utf-8")
+	fmt.Fprint(w, xml.Header)
+	xml.NewEncoder(w).Encode(feed)
+}
+
+// servePage serves a torrent page with the download button
+func (s *Server) servePage(w http.ResponseWriter, r *http.Request) {
+	if !s.loggedIn(w, r) {
+		return
+	}
+	t, ok := s.torrent(r.URL.Query().Get("id"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	s.mu.Lock()
+	s.pages[t.ID]++
+	s.mu.Unlock()
+
+	w.Header().Set("content-type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, "<html><head><title>%s</title></head><body><h1>%s</h1>", html.EscapeString(t.Title), html.EscapeString(t.Title))
+	if t.Freeleech {
+		fmt.Fprint(w, `<img src="/fl.png" alt="Freeleech">`)
+	}
+	fmt.Fprintf(w, `<a class="dl_Btn" href="%s">Download</a></body></html>`, html.EscapeString(s.downloadURL(t)))
+}
+
+// serveDownload serves the .torrent file of /download.php/<id>/<name>.torrent
+func (s *Server) serveDownload(w http.ResponseWriter, r *http.Request) {
+	if !s.loggedIn(w, r) {
+		return
+	}
+	id, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/download.php/"), "/")
+	t, ok := s.torrent(id)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := t.Data
+	if data == nil {
+		data = metainfo(t)
+	}
+	s.mu.Lock()
+	s.downloads[t.ID]++
+	s.mu.Unlock()
+
+	w.Header().Set("content-type", "application/x-bittorrent")
+	w.Header().Set("content-disposition", fmt.Sprintf("attachment; filename=%q", t.Title+".torrent"))
+	w.Write(data)
+}
+
+func (s *Server) serveLogin(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "text/html; charset=utf-8")
+	fmt.Fprint(w, `<html><head><title>Login</title></head><body><form method="post"><input name="username"><input name="password" type="password"></form></body></html>`)
+}
+
+// loggedIn checks the session cookies, and otherwise redirects to the login
+// page like a tracker does
+func (s *Server) loggedIn(w http.ResponseWriter, r *http.Request) bool {
+	uid, err := r.Cookie("uid")
+	if err == nil {
+		if pass, err := r.Cookie("pass"); err == nil && uid.Value == s.Creds.UserID && pass.Value == s.Creds.PassToken {
+			return true
+		}
+	}
+	http.Redirect(w, r, "/login.php?returnto="+url.QueryEscape(r.URL.RequestURI()), http.StatusFound)
+	return false
+}
+
+func (s *Server) downloadURL(t Torrent) string {
+	return s.URL + "/download.php/" + t.ID + "/" + url.PathEscape(t.Title) + ".torrent"
+}
+
+func size(t Torrent) int64 {
+	if t.Size > 0 {
+		return t.Size
+	}
+	return 1 << 20
+}
+
+// metainfo generates a valid .torrent for t, private unless t.Public. The
+// piece hashes are made up, so nothing can actually be downloaded.
+func metainfo(t Torrent) []byte {
+	length := size(t)
+	pieces := make([]byte, 0, (length+pieceLength-1)/pieceLength*sha1.Size)
+	for i := int64(0); i*pieceLength < length; i++ {
+		sum := sha1.Sum([]byte(fmt.Sprintf("%s/%d", t.ID, i)))
+		pieces = append(pieces, sum[:]...)
+	}
+	info := map[string]any{
+		"name":         t.Title,
+		"length":       length,
+		"piece length": int64(pieceLength),
+		"pieces":       string(pieces),
+	}
+	if !t.Public {
+		info["private"] = int64(1)
+	}
+	data, err := bencode.Encode(map[string]any{
+		"announce": "http://tracker.invalid/announce",
+		"info":     info,
+	})
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
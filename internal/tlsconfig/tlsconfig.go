@@ -0,0 +1,68 @@
+// Package tlsconfig builds the TLS settings of trackers with self-signed
+// certificates or that ask for a client certificate
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// Options are a tracker's TLS settings. The zero value verifies the server
+// against the system roots and sends no client certificate.
+type Options struct {
+	// CAFile is a PEM bundle of certificates trusted besides the system roots
+	CAFile string
+	// CertFile and KeyFile are a PEM client certificate and its private
+	// key, sent to trackers that ask for one
+	CertFile string
+	KeyFile  string
+	// InsecureSkipVerify accepts any server certificate, which lets anyone
+	// on the way read the tracker's cookies and passkeys
+	InsecureSkipVerify bool
+}
+
+// IsZero reports whether the options change nothing
+func (o Options) IsZero() bool {
+	return o == Options{}
+}
+
+// Config loads the files into a tls.Config, or returns nil when the options
+// are zero
+func (o Options) Config() (*tls.Config, error) {
+	if o.IsZero() {
+		return nil, nil
+	}
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: o.InsecureSkipVerify,
+	}
+
+	if o.CAFile != "" {
+		pem, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA bundle %s holds no PEM certificates", o.CAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	switch {
+	case o.CertFile != "" && o.KeyFile != "":
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	case o.CertFile != "" || o.KeyFile != "":
+		return nil, fmt.Errorf("a client certificate needs both a certificate and a key file")
+	}
+	return config, nil
+}
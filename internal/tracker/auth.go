@@ -0,0 +1,100 @@
+package tracker
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Ways a tracker's requests can carry credentials
+const (
+	AuthCookie = "cookie" // A Cookie header, the default
+	AuthBearer = "bearer" // An "Authorization: Bearer" header
+	AuthQuery  = "query"  // An API key in a query parameter
+	AuthBasic  = "basic"  // HTTP basic auth
+)
+
+// DefaultAuthParam is the query parameter AuthQuery puts the key in
+const DefaultAuthParam = "apikey"
+
+// AuthOptions picks how requests to a tracker carry its credentials.
+// AuthCookie sends Options.Cookie.
+type AuthOptions struct {
+	Type     string // Empty means AuthCookie
+	Token    string // The bearer token or API key
+	Param    string // Query parameter of AuthQuery, DefaultAuthParam if empty
+	Username string // For AuthBasic
+	Password string
+}
+
+// Auth adds a tracker's credentials to requests for its pages and torrents
+type Auth interface {
+	Authorize(req *http.Request)
+}
+
+// NewAuth builds the auth strategy the options pick. cookie is the Cookie
+// header value of AuthCookie, where empty sends none.
+func NewAuth(opts AuthOptions, cookie string) (Auth, error) {
+	switch strings.ToLower(opts.Type) {
+	case "", AuthCookie:
+		return cookieAuth(cookie), nil
+	case AuthBearer:
+		if opts.Token == "" {
+			return nil, fmt.Errorf("bearer auth needs a token")
+		}
+		return bearerAuth(opts.Token), nil
+	case AuthQuery:
+		if opts.Token == "" {
+			return nil, fmt.Errorf("query auth needs a token")
+		}
+		param := opts.Param
+		if param == "" {
+			param = DefaultAuthParam
+		}
+		return queryAuth{param: param, key: opts.Token}, nil
+	case AuthBasic:
+		if opts.Username == "" {
+			return nil, fmt.Errorf("basic auth needs a username")
+		}
+		return basicAuth{username: opts.Username, password: opts.Password}, nil
+	default:
+		return nil, fmt.Errorf("unknown auth type %q (available: %s, %s, %s, %s)", opts.Type, AuthCookie, AuthBearer, AuthQuery, AuthBasic)
+	}
+}
+
+type cookieAuth string
+
+func (c cookieAuth) Authorize(req *http.Request) {
+	if c != "" {
+		req.Header.Set("cookie", string(c))
+	}
+}
+
+type bearerAuth string
+
+func (b bearerAuth) Authorize(req *http.Request) {
+	req.Header.Set("authorization", "Bearer "+string(b))
+}
+
+// queryAuth only sets the parameter on the request itself; Go's client
+// drops the Authorization and Cookie headers of the other strategies on
+// redirects to other hosts, but can't know about a key in the URL
+type queryAuth struct {
+	param string
+	key   string
+}
+
+func (q queryAuth) Authorize(req *http.Request) {
+	query := req.URL.Query()
+	query.Set(q.param, q.key)
+	req.URL.RawQuery = query.Encode()
+}
+
+type basicAuth struct {
+	username string
+	password string
+}
+
+func (b basicAuth) Authorize(req *http.Request) {
+	req.SetBasicAuth(b.username, b.password)
+}
@@ -0,0 +1,198 @@
+package tracker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"torrent-rss/internal/content"
+
+	"golang.org/x/net/html"
+)
+
+func init() {
+	Register("generic", func(opts Options) (Tracker, error) {
+		if opts.LinkSelector == "" {
+			return nil, fmt.Errorf("generic: link selector is required")
+		}
+		sel, err := parseSelector(opts.LinkSelector)
+		if err != nil {
+			return nil, fmt.Errorf("generic: %w", err)
+		}
+		if err := validateDirectURL(opts.DirectURL); err != nil {
+			return nil, fmt.Errorf("generic: %w", err)
+		}
+		auth, err := NewAuth(opts.Auth, opts.Cookie)
+		if err != nil {
+			return nil, fmt.Errorf("generic: %w", err)
+		}
+		g := &Generic{
+			baseURL:   strings.TrimRight(opts.BaseURL, "/"),
+			auth:      auth,
+			selector:  sel,
+			directURL: opts.DirectURL,
+			passkey:   opts.Passkey,
+		}
+		if (opts.SearchURL == "") != (opts.SearchSelector == "") {
+			return nil, fmt.Errorf("generic: search URL and search selector go together")
+		}
+		if err := validateSearchURL(opts.SearchURL); err != nil {
+			return nil, fmt.Errorf("generic: %w", err)
+		}
+		if opts.SearchURL != "" {
+			search, err := parseSelector(opts.SearchSelector)
+			if err != nil {
+				return nil, fmt.Errorf("generic: search %w", err)
+			}
+			g.searchURL, g.search = opts.SearchURL, &search
+		}
+		if opts.FreeleechSelector != "" {
+			freeleech, err := parseSelector(opts.FreeleechSelector)
+			if err != nil {
+				return nil, fmt.Errorf("generic: freeleech %w", err)
+			}
+			g.freeleech = &freeleech
+		}
+		return g, nil
+	})
+}
+
+// Generic finds the download anchor on any tracker page with a CSS selector
+// declared in config, so new trackers don't need a dedicated adapter
+type Generic struct {
+	baseURL   string // Resolves relative item links, optional
+	auth      Auth
+	selector  selector
+	freeleech *selector // Nil when freeleech can't be detected
+	directURL string
+	passkey   string
+	searchURL string    // Empty when the tracker can't be searched
+	search    *selector // Result links on the search page
+}
+
+func (g *Generic) Authorize(req *http.Request) {
+	g.auth.Authorize(req)
+}
+
+func (g *Generic) CleanName(filename string) string {
+	return cleanTorrentName(filename)
+}
+
+func (g *Generic) FindDownloadLink(ctx context.Context, client *http.Client, pageURL string) (string, error) {
+	pageURL = g.pageURL(pageURL)
+	node, err := findFirst(ctx, client, pageURL, g.auth, g.selector)
+	if err != nil {
+		return "", err
+	}
+	return resolveLink(node, g.selector, pageURL)
+}
+
+func (g *Generic) DirectLink(pageURL string) (string, bool) {
+	return directLink(g.directURL, g.passkey, g.pageURL(pageURL))
+}
+
+// pageURL resolves item links relative to the base URL, for feeds that
+// link to "/details.php?id=1" instead of a full URL
+func (g *Generic) pageURL(link string) string {
+	if g.baseURL == "" {
+		return link
+	}
+	base, err := url.Parse(g.baseURL + "/")
+	if err != nil {
+		return link
+	}
+	resolved, err := base.Parse(link)
+	if err != nil {
+		return link
+	}
+	return resolved.String()
+}
+
+func (g *Generic) IsFreeleech(ctx context.Context, client *http.Client, pageURL string) (bool, error) {
+	if g.freeleech == nil {
+		return false, ErrFreeleechUnknown
+	}
+	node, err := findFirst(ctx, client, g.pageURL(pageURL), g.auth, *g.freeleech)
+	if err != nil {
+		return false, err
+	}
+	return node != nil, nil
+}
+
+// resolveLink resolves the href of the element found matching sel, nil if
+// none did, against the page it was found on
+func resolveLink(node *html.Node, sel selector, pageURL string) (string, error) {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid page URL: %w", err)
+	}
+
+	if node == nil {
+		return "", fmt.Errorf("no element matches %q", sel.raw)
+	}
+
+	href := attrValue(node, "href")
+	if href == "" {
+		return "", fmt.Errorf("element matching %q has no href", sel.raw)
+	}
+
+	link, err := base.Parse(href)
+	if err != nil {
+		return "", fmt.Errorf("invalid download link %q: %w", href, err)
+	}
+	return link.String(), nil
+}
+
+func fetchHTML(ctx context.Context, client *http.Client, pageURL string, auth Auth) (*html.Node, error) {
+	var doc *html.Node
+	err := readPage(ctx, client, pageURL, auth, func(body io.Reader) (err error) {
+		if doc, err = html.Parse(body); err != nil {
+			return fmt.Errorf("failed to parse HTML: %w", err)
+		}
+		return nil
+	})
+	return doc, err
+}
+
+// findFirst fetches a page and returns the first element matching sel,
+// reading no more of the page than it takes to find it, or nil if none does
+func findFirst(ctx context.Context, client *http.Client, pageURL string, auth Auth, sel selector) (*html.Node, error) {
+	var node *html.Node
+	err := readPage(ctx, client, pageURL, auth, func(body io.Reader) (err error) {
+		node, err = scanFirst(body, sel)
+		return err
+	})
+	return node, err
+}
+
+// readPage fetches a page and hands read its body, converted to UTF-8
+func readPage(ctx context.Context, client *http.Client, pageURL string, auth Auth, read func(body io.Reader) error) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// The user agent and language come from the downloader's header profile
+	req.Header.Set("accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7")
+	req.Header.Set("cache-control", "max-age=0")
+	auth.Authorize(req)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch torrent page: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch torrent page: %w", &StatusError{Code: resp.StatusCode, Status: resp.Status})
+	}
+
+	body, err := content.UTF8(resp.Body, resp.Header.Get("content-type"))
+	if err != nil {
+		return fmt.Errorf("failed to read torrent page: %w", err)
+	}
+	return read(body)
+}
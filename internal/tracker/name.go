@@ -0,0 +1,25 @@
+package tracker
+
+import (
+	"net/url"
+	"strings"
+
+	"torrent-rss/internal/release"
+)
+
+// cleanTorrentName strips resolution, source, codec, audio, streaming service
+// and group tags from a torrent filename, keeping title, year and episode
+func cleanTorrentName(filename string) string {
+	// First, URL decode the name to handle encoded characters
+	decoded, err := url.QueryUnescape(filename)
+	if err != nil {
+		return filename // fallback to the original name if decoding fails
+	}
+
+	cleaned := release.Parse(strings.TrimSuffix(decoded, ".torrent")).Clean()
+	if cleaned == "" {
+		// Nothing but tags, keep them rather than saving ".torrent"
+		cleaned = strings.TrimSuffix(decoded, ".torrent")
+	}
+	return cleaned + ".torrent"
+}
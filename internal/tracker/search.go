@@ -0,0 +1,131 @@
+package tracker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"torrent-rss/internal/models"
+)
+
+// ErrSearchUnsupported means the tracker can't be searched, or isn't set up
+// for it
+var ErrSearchUnsupported = errors.New("tracker can't be searched")
+
+// Searcher is implemented by adapters that can search the tracker, finding
+// releases that are no longer, or never were, in its feeds
+type Searcher interface {
+	// Search returns the tracker's results for query, as feed items whose
+	// link is the torrent page, or ErrSearchUnsupported if the adapter isn't
+	// set up for it
+	Search(ctx context.Context, client *http.Client, query string) ([]models.Item, error)
+}
+
+// searchURL fills the {query} of a search URL template
+func searchURL(template, query string) string {
+	return strings.ReplaceAll(template, "{query}", url.QueryEscape(query))
+}
+
+// validateSearchURL checks that a search URL template has its placeholder
+func validateSearchURL(template string) error {
+	if template == "" {
+		return nil
+	}
+	if !strings.Contains(template, "{query}") {
+		return fmt.Errorf("search URL %q must contain {query}", template)
+	}
+	if _, err := url.Parse(searchURL(template, "x")); err != nil {
+		return fmt.Errorf("invalid search URL: %w", err)
+	}
+	return nil
+}
+
+// Search reads the links matching the search selector off the results page,
+// titled after their text
+func (g *Generic) Search(ctx context.Context, client *http.Client, query string) ([]models.Item, error) {
+	if g.searchURL == "" {
+		return nil, ErrSearchUnsupported
+	}
+	pageURL := searchURL(g.searchURL, query)
+	doc, err := fetchHTML(ctx, client, pageURL, g.auth)
+	if err != nil {
+		return nil, err
+	}
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid search URL: %w", err)
+	}
+
+	var items []models.Item
+	seen := make(map[string]bool)
+	for _, node := range g.search.all(doc) {
+		href := attrValue(node, "href")
+		title := strings.Join(strings.Fields(nodeText(node)), " ")
+		if href == "" || title == "" {
+			continue
+		}
+		link, err := base.Parse(href)
+		if err != nil || seen[link.String()] {
+			continue
+		}
+		seen[link.String()] = true
+		items = append(items, models.Item{Title: title, Link: link.String(), GUID: link.String()})
+	}
+	return items, nil
+}
+
+// torrentDayResult is a result of TorrentDay's JSON search
+type torrentDayResult struct {
+	ID         int64    `json:"t"`
+	Name       string   `json:"name"`
+	Category   int      `json:"c"`
+	Size       int64    `json:"size"`
+	Added      int64    `json:"ctime"` // Unix time
+	Multiplier *float64 `json:"download-multiplier"`
+}
+
+// Search queries TorrentDay's JSON search, the one its browse page uses
+func (t *TorrentDay) Search(ctx context.Context, client *http.Client, query string) ([]models.Item, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", t.baseURL+"/t.json?q="+url.QueryEscape(query), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("accept", "application/json")
+	req.Header.Set("x-requested-with", "XMLHttpRequest")
+	t.auth.Authorize(req)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to search: %w", &StatusError{Code: resp.StatusCode, Status: resp.Status})
+	}
+
+	var results []torrentDayResult
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		return nil, fmt.Errorf("failed to read search results: %w", err)
+	}
+	items := make([]models.Item, 0, len(results))
+	for _, r := range results {
+		id := strconv.FormatInt(r.ID, 10)
+		items = append(items, models.Item{
+			Title:        r.Name,
+			Link:         t.baseURL + "/t/" + id,
+			EnclosureURL: t.baseURL + "/download.php/" + id + "/" + url.PathEscape(r.Name) + ".torrent",
+			GUID:         id,
+			PubDate:      time.Unix(r.Added, 0),
+			Size:         r.Size,
+			Freeleech:    r.Multiplier != nil && *r.Multiplier == 0,
+			Categories:   []string{strconv.Itoa(r.Category)},
+		})
+	}
+	return items, nil
+}
@@ -0,0 +1,373 @@
+package tracker
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// selector is a CSS selector such as `a.dl_Btn`, `a[href^="/download.php"]`
+// or `table.torrents td.name > a`. Compound selectors can be joined with the
+// descendant (space) and child (>) combinators; sibling combinators and
+// selector lists are not supported. On top of CSS, `[attr=~"regexp"]`
+// matches an attribute against a regular expression and `:contains("text")`
+// an element whose text contains text, ignoring case.
+type selector struct {
+	raw         string
+	parts       []compound
+	combinators []byte // combinators[i] joins parts[i] and parts[i+1]
+}
+
+// compound is a single element test such as `a.dl_Btn[href]`
+type compound struct {
+	tag      string
+	id       string
+	classes  []string
+	attrs    []attrMatcher
+	contains []string // Lowercase text the element's text must contain
+}
+
+type attrMatcher struct {
+	key     string
+	op      string // "", "=", "^=", "$=", "*=", "~=", "=~"
+	value   string
+	pattern *regexp.Regexp // For "=~"
+}
+
+func parseSelector(raw string) (selector, error) {
+	sel := selector{raw: raw}
+	s := strings.TrimSpace(raw)
+	if s == "" {
+		return sel, fmt.Errorf("empty selector")
+	}
+
+	for {
+		part, rest, err := parseCompound(raw, s)
+		if err != nil {
+			return sel, err
+		}
+		sel.parts = append(sel.parts, part)
+
+		s = strings.TrimLeft(rest, " \t\n")
+		if s == "" {
+			return sel, nil
+		}
+		combinator := byte(' ')
+		switch s[0] {
+		case '>':
+			combinator = '>'
+			s = strings.TrimLeft(s[1:], " \t\n")
+		case '+', '~', ',':
+			return sel, fmt.Errorf("selector %q: %q is not supported", raw, s[0])
+		}
+		if s == "" {
+			return sel, fmt.Errorf("selector %q: nothing after %q", raw, combinator)
+		}
+		sel.combinators = append(sel.combinators, combinator)
+	}
+}
+
+// parseCompound reads one compound selector from the start of s and returns
+// it with whatever follows
+func parseCompound(raw, s string) (compound, string, error) {
+	var part compound
+
+	// Leading tag name, or * for any element
+	i := 0
+	if strings.HasPrefix(s, "*") {
+		i = 1
+	} else {
+		for i < len(s) && isIdentChar(s[i]) {
+			i++
+		}
+		part.tag = strings.ToLower(s[:i])
+	}
+	empty := i == 0
+	s = s[i:]
+
+	for s != "" {
+		switch s[0] {
+		case '.', '#':
+			j := 1
+			for j < len(s) && isIdentChar(s[j]) {
+				j++
+			}
+			if j == 1 {
+				return part, s, fmt.Errorf("selector %q: missing name after %q", raw, s[0])
+			}
+			if s[0] == '.' {
+				part.classes = append(part.classes, s[1:j])
+			} else {
+				part.id = s[1:j]
+			}
+			s = s[j:]
+		case '[':
+			end := attrEnd(s)
+			if end < 0 {
+				return part, s, fmt.Errorf("selector %q: unterminated attribute", raw)
+			}
+			matcher, err := parseAttrMatcher(s[1:end])
+			if err != nil {
+				return part, s, fmt.Errorf("selector %q: %w", raw, err)
+			}
+			part.attrs = append(part.attrs, matcher)
+			s = s[end+1:]
+		case ':':
+			text, rest, err := parseContains(raw, s)
+			if err != nil {
+				return part, s, err
+			}
+			part.contains = append(part.contains, strings.ToLower(text))
+			s = rest
+		case ' ', '\t', '\n', '>', '+', '~', ',':
+			if empty {
+				return part, s, fmt.Errorf("selector %q: expected an element before %q", raw, s[0])
+			}
+			return part, s, nil
+		default:
+			return part, s, fmt.Errorf("selector %q: unexpected %q", raw, s[0])
+		}
+		empty = false
+	}
+	return part, s, nil
+}
+
+func parseAttrMatcher(body string) (attrMatcher, error) {
+	// The operator is around the first "=", the value may have more
+	idx := strings.IndexByte(body, '=')
+	if idx < 0 {
+		key := strings.ToLower(strings.TrimSpace(body))
+		if key == "" {
+			return attrMatcher{}, fmt.Errorf("empty attribute")
+		}
+		return attrMatcher{key: key}, nil
+	}
+	key, op, value := body[:idx], "=", body[idx+1:]
+	if k := strings.TrimRight(key, " "); k != "" && strings.ContainsRune("^$*~", rune(k[len(k)-1])) {
+		key, op = k[:len(k)-1], k[len(k)-1:]+"="
+	} else if strings.HasPrefix(value, "~") {
+		op, value = "=~", value[1:]
+	}
+	m := attrMatcher{
+		key:   strings.ToLower(strings.TrimSpace(key)),
+		op:    op,
+		value: strings.Trim(strings.TrimSpace(value), `"'`),
+	}
+	if m.key == "" {
+		return m, fmt.Errorf("missing attribute before %q", op)
+	}
+	if op == "=~" {
+		var err error
+		if m.pattern, err = regexp.Compile(m.value); err != nil {
+			return m, fmt.Errorf("attribute %s: %w", m.key, err)
+		}
+	}
+	return m, nil
+}
+
+// attrEnd finds the "]" closing the attribute test at the start of s, past
+// any in its quoted value, or -1
+func attrEnd(s string) int {
+	var quote byte
+	for i := 1; i < len(s); i++ {
+		switch {
+		case quote != 0:
+			if s[i] == quote {
+				quote = 0
+			}
+		case s[i] == '"' || s[i] == '\'':
+			quote = s[i]
+		case s[i] == ']':
+			return i
+		}
+	}
+	return -1
+}
+
+// parseContains reads a `:contains(text)` from the start of s, the text
+// quoted or not, and returns the text with whatever follows
+func parseContains(raw, s string) (string, string, error) {
+	const prefix = ":contains("
+	if !strings.HasPrefix(s, prefix) {
+		return "", s, fmt.Errorf("selector %q: only :contains() is supported", raw)
+	}
+	s = strings.TrimLeft(s[len(prefix):], " ")
+	var text string
+	if s != "" && (s[0] == '"' || s[0] == '\'') {
+		end := strings.IndexByte(s[1:], s[0])
+		if end < 0 {
+			return "", s, fmt.Errorf("selector %q: unterminated text in :contains()", raw)
+		}
+		text, s = s[1:end+1], strings.TrimLeft(s[end+2:], " ")
+		if !strings.HasPrefix(s, ")") {
+			return "", s, fmt.Errorf("selector %q: expected ) after the text of :contains()", raw)
+		}
+	} else {
+		end := strings.IndexByte(s, ')')
+		if end < 0 {
+			return "", s, fmt.Errorf("selector %q: unterminated :contains()", raw)
+		}
+		text, s = strings.TrimSpace(s[:end]), s[end:]
+	}
+	if text == "" {
+		return "", s, fmt.Errorf("selector %q: empty :contains()", raw)
+	}
+	return text, s[1:], nil
+}
+
+func isIdentChar(c byte) bool {
+	return c == '-' || c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// first returns the first node in document order matching the selector
+func (sel selector) first(node *html.Node) *html.Node {
+	if sel.matches(node) {
+		return node
+	}
+	for c := node.FirstChild; c != nil; c = c.NextSibling {
+		if found := sel.first(c); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// all returns every node matching the selector, in document order
+func (sel selector) all(node *html.Node) []*html.Node {
+	var found []*html.Node
+	if sel.matches(node) {
+		found = append(found, node)
+	}
+	for c := node.FirstChild; c != nil; c = c.NextSibling {
+		found = append(found, sel.all(c)...)
+	}
+	return found
+}
+
+func (sel selector) matches(node *html.Node) bool {
+	return sel.matchFrom(node, len(sel.parts)-1)
+}
+
+// matchFrom checks node against parts[i], then its ancestors against the
+// parts before it, as the combinators require
+func (sel selector) matchFrom(node *html.Node, i int) bool {
+	if !sel.parts[i].matches(node) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	if sel.combinators[i-1] == '>' {
+		return node.Parent != nil && sel.matchFrom(node.Parent, i-1)
+	}
+	for parent := node.Parent; parent != nil; parent = parent.Parent {
+		if sel.matchFrom(parent, i-1) {
+			return true
+		}
+	}
+	return false
+}
+
+func (sel compound) matches(node *html.Node) bool {
+	if node.Type != html.ElementNode {
+		return false
+	}
+	if sel.tag != "" && node.Data != sel.tag {
+		return false
+	}
+	if sel.id != "" && attrValue(node, "id") != sel.id {
+		return false
+	}
+	if len(sel.classes) > 0 {
+		classes := strings.Fields(attrValue(node, "class"))
+		for _, want := range sel.classes {
+			if !containsString(classes, want) {
+				return false
+			}
+		}
+	}
+	for _, m := range sel.attrs {
+		value, ok := lookupAttr(node, m.key)
+		if !ok {
+			return false
+		}
+		switch m.op {
+		case "=":
+			ok = value == m.value
+		case "^=":
+			ok = strings.HasPrefix(value, m.value)
+		case "$=":
+			ok = strings.HasSuffix(value, m.value)
+		case "*=":
+			ok = strings.Contains(value, m.value)
+		case "~=":
+			ok = containsString(strings.Fields(value), m.value)
+		case "=~":
+			ok = m.pattern.MatchString(value)
+		}
+		if !ok {
+			return false
+		}
+	}
+	if len(sel.contains) > 0 {
+		text := strings.ToLower(strings.Join(strings.Fields(nodeText(node)), " "))
+		for _, want := range sel.contains {
+			if !strings.Contains(text, want) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// needsText tells whether the selector looks at the text of elements, which
+// a scan of the page's tags doesn't have yet
+func (sel selector) needsText() bool {
+	for _, part := range sel.parts {
+		if len(part.contains) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func lookupAttr(node *html.Node, key string) (string, bool) {
+	for _, attr := range node.Attr {
+		if attr.Key == key {
+			return attr.Val, true
+		}
+	}
+	return "", false
+}
+
+func attrValue(node *html.Node, key string) string {
+	value, _ := lookupAttr(node, key)
+	return value
+}
+
+// nodeText is the text inside a node
+func nodeText(node *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(node)
+	return b.String()
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
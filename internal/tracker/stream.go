@@ -0,0 +1,126 @@
+package tracker
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// voidElements never have content or an end tag
+var voidElements = map[atom.Atom]bool{
+	atom.Area: true, atom.Base: true, atom.Br: true, atom.Col: true,
+	atom.Embed: true, atom.Hr: true, atom.Img: true, atom.Input: true,
+	atom.Link: true, atom.Meta: true, atom.Param: true, atom.Source: true,
+	atom.Track: true, atom.Wbr: true,
+}
+
+// impliedEnd is which open elements a start tag closes when their end tag
+// was left out, looking no further up than the elements in stops
+type impliedEnd struct {
+	closes []atom.Atom
+	stops  []atom.Atom
+}
+
+// impliedEnds covers the end tags tracker pages leave out the most, mostly
+// in tables and lists, so child combinators see the parents a parser would
+var impliedEnds = map[atom.Atom]impliedEnd{
+	atom.Td:     {closes: []atom.Atom{atom.Td, atom.Th}, stops: []atom.Atom{atom.Tr, atom.Table}},
+	atom.Th:     {closes: []atom.Atom{atom.Td, atom.Th}, stops: []atom.Atom{atom.Tr, atom.Table}},
+	atom.Tr:     {closes: []atom.Atom{atom.Tr}, stops: []atom.Atom{atom.Tbody, atom.Thead, atom.Tfoot, atom.Table}},
+	atom.Tbody:  {closes: []atom.Atom{atom.Tbody, atom.Thead, atom.Tfoot}, stops: []atom.Atom{atom.Table}},
+	atom.Thead:  {closes: []atom.Atom{atom.Tbody, atom.Thead, atom.Tfoot}, stops: []atom.Atom{atom.Table}},
+	atom.Tfoot:  {closes: []atom.Atom{atom.Tbody, atom.Thead, atom.Tfoot}, stops: []atom.Atom{atom.Table}},
+	atom.Li:     {closes: []atom.Atom{atom.Li}, stops: []atom.Atom{atom.Ul, atom.Ol}},
+	atom.Dt:     {closes: []atom.Atom{atom.Dt, atom.Dd}, stops: []atom.Atom{atom.Dl}},
+	atom.Dd:     {closes: []atom.Atom{atom.Dt, atom.Dd}, stops: []atom.Atom{atom.Dl}},
+	atom.Option: {closes: []atom.Atom{atom.Option}, stops: []atom.Atom{atom.Select, atom.Optgroup, atom.Datalist}},
+	atom.P:      {closes: []atom.Atom{atom.P}, stops: []atom.Atom{atom.Table, atom.Td, atom.Th, atom.Button}},
+}
+
+// scanFirst reads an HTML page token by token and returns the first element
+// matching sel, without building the page's tree or reading the rest of it.
+// The element only has its attributes and the chain of its open ancestors.
+// Pages where nothing matched are parsed in full after all, as a parser
+// fixes up broken markup the scan doesn't, so the scan never misses what
+// sel.first would find. It returns nil when nothing matches.
+func scanFirst(r io.Reader, sel selector) (*html.Node, error) {
+	// Text only comes after the tag, so such selectors need the whole page
+	if sel.needsText() {
+		doc, err := html.Parse(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse HTML: %w", err)
+		}
+		return sel.first(doc), nil
+	}
+
+	var page bytes.Buffer
+	z := html.NewTokenizer(io.TeeReader(r, &page))
+	open := []*html.Node{{Type: html.DocumentNode}}
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			if err := z.Err(); err != io.EOF {
+				return nil, fmt.Errorf("failed to parse HTML: %w", err)
+			}
+			doc, err := html.Parse(&page)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse HTML: %w", err)
+			}
+			return sel.first(doc), nil
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			open = closeImplied(open, tok.DataAtom)
+			// A parser puts rows straight in a table into a tbody
+			if tok.DataAtom == atom.Tr && open[len(open)-1].DataAtom == atom.Table {
+				open = append(open, &html.Node{Type: html.ElementNode, Data: "tbody", DataAtom: atom.Tbody, Parent: open[len(open)-1]})
+			}
+			node := &html.Node{Type: html.ElementNode, Data: tok.Data, DataAtom: tok.DataAtom, Attr: tok.Attr, Parent: open[len(open)-1]}
+			if sel.matches(node) {
+				return node, nil
+			}
+			if tt == html.StartTagToken && !voidElements[tok.DataAtom] {
+				open = append(open, node)
+			}
+
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			for i := len(open) - 1; i > 0; i-- {
+				if open[i].Data == string(name) {
+					open = open[:i]
+					break
+				}
+			}
+		}
+	}
+}
+
+// closeImplied pops the open elements the start tag of tag ends
+func closeImplied(open []*html.Node, tag atom.Atom) []*html.Node {
+	end, ok := impliedEnds[tag]
+	if !ok {
+		return open
+	}
+	for i := len(open) - 1; i > 0; i-- {
+		switch {
+		case containsAtom(end.closes, open[i].DataAtom):
+			return open[:i]
+		case containsAtom(end.stops, open[i].DataAtom):
+			return open
+		}
+	}
+	return open
+}
+
+func containsAtom(list []atom.Atom, a atom.Atom) bool {
+	for _, item := range list {
+		if item == a {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package tracker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultTorrentDayLinkSelector matches the download button on torrent.php pages
+const DefaultTorrentDayLinkSelector = "a.dl_Btn"
+
+func init() {
+	Register("torrentday", func(opts Options) (Tracker, error) {
+		if opts.BaseURL == "" {
+			return nil, fmt.Errorf("torrentday: base URL is required")
+		}
+		if opts.LinkSelector == "" {
+			opts.LinkSelector = DefaultTorrentDayLinkSelector
+		}
+		sel, err := parseSelector(opts.LinkSelector)
+		if err != nil {
+			return nil, fmt.Errorf("torrentday: %w", err)
+		}
+		if err := validateDirectURL(opts.DirectURL); err != nil {
+			return nil, fmt.Errorf("torrentday: %w", err)
+		}
+		auth, err := NewAuth(opts.Auth, opts.Cookie)
+		if err != nil {
+			return nil, fmt.Errorf("torrentday: %w", err)
+		}
+		return &TorrentDay{
+			baseURL:   strings.TrimRight(opts.BaseURL, "/"),
+			auth:      auth,
+			selector:  sel,
+			directURL: opts.DirectURL,
+			passkey:   opts.Passkey,
+		}, nil
+	})
+}
+
+// TorrentDay scrapes the download button from torrent.php pages
+type TorrentDay struct {
+	baseURL   string
+	auth      Auth
+	selector  selector
+	directURL string // Optional, skips the page scrape when set
+	passkey   string
+}
+
+func (t *TorrentDay) Authorize(req *http.Request) {
+	t.auth.Authorize(req)
+}
+
+func (t *TorrentDay) CleanName(filename string) string {
+	return cleanTorrentName(filename)
+}
+
+func (t *TorrentDay) DirectLink(pageURL string) (string, bool) {
+	return directLink(t.directURL, t.passkey, pageURL)
+}
+
+func (t *TorrentDay) FindDownloadLink(ctx context.Context, client *http.Client, pageURL string) (string, error) {
+	torrentID, ok := pageID(pageURL)
+	if !ok {
+		return "", fmt.Errorf("torrentday: no torrent ID in %s", pageURL)
+	}
+	authenticatedURL := fmt.Sprintf("%s/torrent.php?id=%s", t.baseURL, url.QueryEscape(torrentID))
+
+	node, err := findFirst(ctx, client, authenticatedURL, t.auth, t.selector)
+	if err != nil {
+		return "", err
+	}
+	return resolveLink(node, t.selector, authenticatedURL)
+}
@@ -0,0 +1,27 @@
+package tracker
+
+import (
+	"context"
+	"net/http"
+)
+
+func init() {
+	Register("torznab", func(opts Options) (Tracker, error) {
+		return &Torznab{}, nil
+	})
+}
+
+// Torznab downloads results of Jackett or Prowlarr searches. Their item links
+// already point at the indexer's download proxy, so there's no page to scrape
+// and no tracker auth to send.
+type Torznab struct{}
+
+func (t *Torznab) Authorize(req *http.Request) {}
+
+func (t *Torznab) CleanName(filename string) string {
+	return cleanTorrentName(filename)
+}
+
+func (t *Torznab) FindDownloadLink(ctx context.Context, client *http.Client, pageURL string) (string, error) {
+	return pageURL, nil
+}
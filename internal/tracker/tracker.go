@@ -0,0 +1,175 @@
+package tracker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Tracker adapts the downloader to a single tracker's site layout and auth
+type Tracker interface {
+	// FindDownloadLink resolves a torrent page URL to the .torrent download URL
+	FindDownloadLink(ctx context.Context, client *http.Client, pageURL string) (string, error)
+	// Authorize adds the tracker's credentials to a request for one of its
+	// pages or torrents
+	Authorize(req *http.Request)
+	// CleanName turns a raw torrent filename into the name saved on disk
+	CleanName(filename string) string
+}
+
+// ErrFreeleechUnknown means the tracker can't tell whether a torrent is freeleech
+var ErrFreeleechUnknown = errors.New("tracker can't tell whether a torrent is freeleech")
+
+// StatusError is a request the tracker answered with an unexpected status
+type StatusError struct {
+	Code   int
+	Status string // e.g. "404 Not Found"
+}
+
+func (e *StatusError) Error() string {
+	return e.Status
+}
+
+// FreeleechChecker is implemented by adapters that can read the freeleech
+// status from a torrent page
+type FreeleechChecker interface {
+	// IsFreeleech reports whether downloading the torrent on pageURL is free
+	// for your ratio, or ErrFreeleechUnknown if the adapter isn't set up for it
+	IsFreeleech(ctx context.Context, client *http.Client, pageURL string) (bool, error)
+}
+
+// DirectLinker is implemented by adapters that can build the download URL
+// from the torrent ID and the passkey, without fetching the torrent page
+type DirectLinker interface {
+	// DirectLink returns the download URL for pageURL, or false if the
+	// adapter has no template or passkey or can't find the torrent ID
+	DirectLink(pageURL string) (string, bool)
+}
+
+// Options holds everything an adapter may need to be constructed
+type Options struct {
+	BaseURL string
+	Cookie  string
+	// LinkSelector is the CSS selector of the download anchor. Required by
+	// generic adapters, TorrentDay defaults to DefaultTorrentDayLinkSelector.
+	LinkSelector string
+	// FreeleechSelector matches an element only present on freeleech torrent
+	// pages, used by generic adapters
+	FreeleechSelector string
+	// DirectURL is a download URL template such as
+	// "https://tracker/download.php?torrent={id}&passkey={passkey}"
+	DirectURL string
+	// Passkey fills {passkey} in DirectURL, usually taken from the RSS URL
+	Passkey string
+	// Auth picks how requests carry credentials, sending Cookie by default
+	Auth AuthOptions
+	// SearchURL is the tracker's search page with {query} for the search,
+	// and SearchSelector matches the link of each result on it. Used by
+	// generic adapters.
+	SearchURL      string
+	SearchSelector string
+}
+
+// Factory builds a Tracker from options
+type Factory func(Options) (Tracker, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes an adapter available under the given name
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if factory == nil {
+		panic("tracker: Register factory is nil")
+	}
+	if _, exists := registry[name]; exists {
+		panic("tracker: Register called twice for " + name)
+	}
+	registry[name] = factory
+}
+
+// New builds the adapter registered under name
+func New(name string, opts Options) (Tracker, error) {
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown tracker %q (available: %v)", name, Names())
+	}
+	return factory(opts)
+}
+
+// Names lists the registered adapters in alphabetical order
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// directLink fills a DirectURL template with the torrent ID found in pageURL,
+// either its id or torrent query parameter or the last path segment
+func directLink(template, passkey, pageURL string) (string, bool) {
+	if template == "" || passkey == "" {
+		return "", false
+	}
+	id, ok := pageID(pageURL)
+	if !ok {
+		return "", false
+	}
+	return strings.NewReplacer(
+		"{id}", url.QueryEscape(id),
+		"{passkey}", url.QueryEscape(passkey),
+	).Replace(template), true
+}
+
+// pageID returns the torrent ID of a torrent page URL, from its id or
+// torrent parameter like torrent.php?id=123, or else its last path segment
+// like /t/123
+func pageID(pageURL string) (string, bool) {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return "", false
+	}
+	id := u.Query().Get("id")
+	if id == "" {
+		id = u.Query().Get("torrent")
+	}
+	if id == "" {
+		id = path.Base(strings.TrimRight(u.Path, "/"))
+	}
+	if id == "" || id == "." || id == "/" {
+		return "", false
+	}
+	return id, true
+}
+
+// validateDirectURL checks that a DirectURL template has both placeholders
+func validateDirectURL(template string) error {
+	if template == "" {
+		return nil
+	}
+	if !strings.Contains(template, "{id}") || !strings.Contains(template, "{passkey}") {
+		return fmt.Errorf("direct URL %q must contain {id} and {passkey}", template)
+	}
+	if _, err := url.Parse(strings.NewReplacer("{id}", "1", "{passkey}", "x").Replace(template)); err != nil {
+		return fmt.Errorf("invalid direct URL: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package tui
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"torrent-rss/internal/history"
+	"torrent-rss/internal/metadata"
+)
+
+// Client talks to the HTTP API of a running daemon
+type Client struct {
+	base string
+	key  string
+	http *http.Client
+}
+
+// NewClient builds a client of the API listening on addr, a listen address
+// like "127.0.0.1:8090" or ":8090", or a URL. key is the API's full-access
+// key, empty when it has none.
+func NewClient(addr, key string) (*Client, error) {
+	base := addr
+	if !strings.Contains(base, "://") {
+		// Listening on every interface includes localhost
+		if strings.HasPrefix(base, ":") {
+			base = "127.0.0.1" + base
+		}
+		base = "http://" + base
+	}
+	u, err := url.Parse(base)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid API address %q", addr)
+	}
+	return &Client{base: strings.TrimSuffix(u.String(), "/"), key: key, http: &http.Client{}}, nil
+}
+
+// Feed is a configured feed as the API lists it
+type Feed struct {
+	Name    string `json:"name"`
+	Tracker string `json:"tracker"`
+}
+
+// FeedItem is a feed item as the API shows it
+type FeedItem struct {
+	Title        string    `json:"title"`
+	Link         string    `json:"link"`
+	EnclosureURL string    `json:"enclosure_url"`
+	GUID         string    `json:"guid"`
+	PubDate      time.Time `json:"pub_date"`
+	Description  string    `json:"description"`
+	Size         int64     `json:"size"`
+	Freeleech    bool      `json:"freeleech"`
+	InfoHash     string    `json:"infohash"`
+	Categories   []string  `json:"categories"`
+}
+
+// Item is a feed item with whether the feed's filters match it
+type Item struct {
+	FeedItem
+	ContentType string `json:"content_type"`
+	Filtered    string `json:"filtered"` // Rule that rejects the item, empty when it matches
+	Downloaded  bool   `json:"downloaded"`
+}
+
+// Event is a pipeline event of the daemon
+type Event struct {
+	Time     time.Time      `json:"time"`
+	Event    string         `json:"event"`
+	Feed     string         `json:"feed"`
+	Item     *FeedItem      `json:"item"`
+	Reason   string         `json:"reason"`
+	Error    string         `json:"error"`
+	InfoHash string         `json:"infohash"`
+	Size     int64          `json:"size"`
+	Media    *metadata.Info `json:"media"`
+}
+
+// Feeds lists the daemon's feeds
+func (c *Client) Feeds(ctx context.Context) ([]Feed, error) {
+	var feeds []Feed
+	return feeds, c.do(ctx, "GET", "/api/v1/feeds", nil, &feeds)
+}
+
+// Items fetches a feed's current items, without grabbing anything
+func (c *Client) Items(ctx context.Context, feed string) ([]Item, error) {
+	var items []Item
+	return items, c.do(ctx, "GET", "/api/v1/feeds/"+url.PathEscape(feed)+"/items", nil, &items)
+}
+
+// History lists the latest limit downloads, newest first
+func (c *Client) History(ctx context.Context, limit int) ([]history.Entry, error) {
+	var entries []history.Entry
+	return entries, c.do(ctx, "GET", fmt.Sprintf("/api/v1/history?limit=%d", limit), nil, &entries)
+}
+
+// Grab has the daemon grab a feed item right away, bypassing its filters
+func (c *Client) Grab(ctx context.Context, feed string, item FeedItem) error {
+	req := map[string]string{
+		"feed":          feed,
+		"link":          item.Link,
+		"title":         item.Title,
+		"guid":          item.GUID,
+		"enclosure_url": item.EnclosureURL,
+	}
+	return c.do(ctx, "POST", "/api/v1/grab", req, nil)
+}
+
+// do sends a request with body as JSON and decodes the response into out
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, reader)
+	if err != nil {
+		return err
+	}
+	resp, err := c.send(req)
+	if err != nil {
+		return fmt.Errorf("daemon API not reachable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return apiError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// send sends a request with the API key
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	if c.key != "" {
+		req.Header.Set("x-api-key", c.key)
+	}
+	return c.http.Do(req)
+}
+
+// apiError reads the error of a failed response
+func apiError(resp *http.Response) error {
+	var failed struct {
+		Error string `json:"error"`
+	}
+	if json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&failed) == nil && failed.Error != "" {
+		return fmt.Errorf("%s", failed.Error)
+	}
+	return fmt.Errorf("daemon API answered %s", resp.Status)
+}
+
+// EventStream is the daemon's events as they happen
+type EventStream struct {
+	body io.ReadCloser
+	dec  *json.Decoder
+}
+
+// Events starts streaming the daemon's events, until ctx is done or Close
+func (c *Client) Events(ctx context.Context) (*EventStream, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.base+"/api/v1/events", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.send(req)
+	if err != nil {
+		return nil, fmt.Errorf("daemon API not reachable: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, apiError(resp)
+	}
+	return &EventStream{body: resp.Body, dec: json.NewDecoder(resp.Body)}, nil
+}
+
+// Next waits for the next event
+func (s *EventStream) Next() (Event, error) {
+	var e Event
+	err := s.dec.Decode(&e)
+	return e, err
+}
+
+func (s *EventStream) Close() error {
+	return s.body.Close()
+}
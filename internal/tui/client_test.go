@@ -0,0 +1,52 @@
+package tui
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientItemsAndGrab(t *testing.T) {
+	var grabbed map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/feeds/tv/items":
+			w.Write([]byte(`[{"title":"Show.S01E01","link":"https://tracker.example/details/1",` +
+				`"enclosure_url":"https://tracker.example/download/1.torrent","pub_date":"2024-01-02T03:04:05Z",` +
+				`"size":1024,"freeleech":true,"content_type":"tv","downloaded":false}]`))
+		case "/api/v1/grab":
+			json.NewDecoder(r.Body).Decode(&grabbed)
+			w.Write([]byte(`{}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err := c.Items(context.Background(), "tv")
+	if err != nil {
+		t.Fatalf("Items: %v", err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("got %d items, want 1", len(items))
+	}
+	item := items[0]
+	if item.EnclosureURL != "https://tracker.example/download/1.torrent" || !item.PubDate.Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)) ||
+		item.Size != 1024 || !item.Freeleech || item.ContentType != "tv" {
+		t.Errorf("item = %+v", item)
+	}
+
+	if err := c.Grab(context.Background(), "tv", item.FeedItem); err != nil {
+		t.Fatalf("Grab: %v", err)
+	}
+	if grabbed["enclosure_url"] != item.EnclosureURL || grabbed["link"] != item.Link {
+		t.Errorf("grab request = %v", grabbed)
+	}
+}
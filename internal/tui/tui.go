@@ -0,0 +1,589 @@
+// Package tui is an interactive terminal UI on top of the daemon's HTTP API:
+// the latest items of each feed with whether the feed would grab them,
+// grabbing them by hand, the download history and the daemon's events as
+// they happen
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"torrent-rss/internal/bytesize"
+	"torrent-rss/internal/history"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	// historyLimit is how many downloads the history view shows
+	historyLimit = 200
+	// logLimit is how many events the log view keeps
+	logLimit = 500
+	// reconnectDelay is how long to wait before reconnecting to the event
+	// stream, e.g. while the daemon restarts
+	reconnectDelay = 5 * time.Second
+)
+
+type view int
+
+const (
+	viewItems view = iota
+	viewHistory
+	viewLog
+)
+
+var viewNames = [...]string{"Items", "History", "Log"}
+
+// The colors of the CLI's cyberpunk theme
+var (
+	titleStyle      = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("13"))
+	tabStyle        = lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+	activeTabStyle  = lipgloss.NewStyle().Bold(true).Underline(true).Foreground(lipgloss.Color("14"))
+	cursorStyle     = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("13"))
+	matchStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("10"))
+	downloadedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("14"))
+	dimStyle        = lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+	warnStyle       = lipgloss.NewStyle().Foreground(lipgloss.Color("11"))
+	errorStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+)
+
+// Run shows the UI until the user quits
+func Run(ctx context.Context, client *Client) error {
+	// Ends the event stream along with the UI
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	_, err := tea.NewProgram(newModel(ctx, client), tea.WithAltScreen(), tea.WithContext(ctx)).Run()
+	if err == tea.ErrProgramKilled && ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+type model struct {
+	ctx    context.Context
+	client *Client
+	view   view
+	width  int
+	height int
+
+	feeds    []Feed
+	feed     int // The feed whose items are shown
+	items    []Item
+	selected map[int]bool
+	cursor   int
+	loading  bool
+
+	history       []history.Entry
+	historyCursor int
+
+	logs      []Event
+	logScroll int // Events scrolled back from the newest
+	stream    *EventStream
+
+	status string
+	failed bool // status is an error
+}
+
+func newModel(ctx context.Context, client *Client) model {
+	return model{ctx: ctx, client: client, selected: make(map[int]bool), loading: true, status: "Loading feeds..."}
+}
+
+type (
+	feedsMsg struct {
+		feeds []Feed
+		err   error
+	}
+	itemsMsg struct {
+		feed  string
+		items []Item
+		err   error
+	}
+	historyMsg struct {
+		entries []history.Entry
+		err     error
+	}
+	grabbedMsg struct {
+		grabbed int
+		err     error
+	}
+	streamMsg struct {
+		stream *EventStream
+		err    error
+	}
+	eventMsg       Event
+	streamEndedMsg struct{ err error }
+	reconnectMsg   struct{}
+)
+
+func (m model) Init() tea.Cmd {
+	return tea.Batch(m.loadFeeds(), m.loadHistory(), m.connect())
+}
+
+func (m model) loadFeeds() tea.Cmd {
+	return func() tea.Msg {
+		feeds, err := m.client.Feeds(m.ctx)
+		return feedsMsg{feeds: feeds, err: err}
+	}
+}
+
+func (m model) loadItems(feed string) tea.Cmd {
+	return func() tea.Msg {
+		items, err := m.client.Items(m.ctx, feed)
+		return itemsMsg{feed: feed, items: items, err: err}
+	}
+}
+
+func (m model) loadHistory() tea.Cmd {
+	return func() tea.Msg {
+		entries, err := m.client.History(m.ctx, historyLimit)
+		return historyMsg{entries: entries, err: err}
+	}
+}
+
+func (m model) connect() tea.Cmd {
+	return func() tea.Msg {
+		stream, err := m.client.Events(m.ctx)
+		return streamMsg{stream: stream, err: err}
+	}
+}
+
+// next waits for the next event of the stream
+func next(stream *EventStream) tea.Cmd {
+	return func() tea.Msg {
+		e, err := stream.Next()
+		if err != nil {
+			stream.Close()
+			return streamEndedMsg{err: err}
+		}
+		return eventMsg(e)
+	}
+}
+
+// grab grabs items of feed one after another, stopping at the first failure
+func (m model) grab(feed string, items []FeedItem) tea.Cmd {
+	return func() tea.Msg {
+		for i, item := range items {
+			if err := m.client.Grab(m.ctx, feed, item); err != nil {
+				return grabbedMsg{grabbed: i, err: fmt.Errorf("%s: %w", item.Title, err)}
+			}
+		}
+		return grabbedMsg{grabbed: len(items)}
+	}
+}
+
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		return m, nil
+
+	case tea.KeyMsg:
+		return m.key(msg)
+
+	case feedsMsg:
+		if msg.err != nil {
+			m.loading = false
+			return m.fail(msg.err), nil
+		}
+		m.feeds = msg.feeds
+		if len(m.feeds) == 0 {
+			m.loading = false
+			m.status = "The daemon has no feeds"
+			return m, nil
+		}
+		cmd := m.showFeed(0)
+		return m, cmd
+
+	case itemsMsg:
+		// Items of a feed that was switched away from in the meantime
+		if msg.feed != m.feedName() {
+			return m, nil
+		}
+		m.loading = false
+		if msg.err != nil {
+			return m.fail(msg.err), nil
+		}
+		m.items = msg.items
+		m.selected = make(map[int]bool)
+		m.cursor = min(m.cursor, max(len(m.items)-1, 0))
+		m.status, m.failed = fmt.Sprintf("%d items in %s", len(m.items), msg.feed), false
+		return m, nil
+
+	case historyMsg:
+		if msg.err != nil {
+			return m.fail(msg.err), nil
+		}
+		m.history = msg.entries
+		m.historyCursor = min(m.historyCursor, max(len(m.history)-1, 0))
+		return m, nil
+
+	case grabbedMsg:
+		if msg.err != nil {
+			m = m.fail(fmt.Errorf("grabbed %d, then failed: %w", msg.grabbed, msg.err))
+		} else {
+			m.status, m.failed = fmt.Sprintf("Grabbed %d item(s)", msg.grabbed), false
+		}
+		return m, tea.Batch(m.loadItems(m.feedName()), m.loadHistory())
+
+	case streamMsg:
+		if msg.err != nil {
+			m.addLog(Event{Time: time.Now(), Event: "disconnected", Error: msg.err.Error()})
+			return m, reconnect()
+		}
+		m.stream = msg.stream
+		m.addLog(Event{Time: time.Now(), Event: "connected"})
+		return m, next(m.stream)
+
+	case eventMsg:
+		m.addLog(Event(msg))
+		cmds := []tea.Cmd{next(m.stream)}
+		if msg.Event == "downloaded" || msg.Event == "upgraded" {
+			cmds = append(cmds, m.loadHistory())
+		}
+		return m, tea.Batch(cmds...)
+
+	case streamEndedMsg:
+		m.stream = nil
+		m.addLog(Event{Time: time.Now(), Event: "disconnected", Error: msg.err.Error()})
+		return m, reconnect()
+
+	case reconnectMsg:
+		return m, m.connect()
+	}
+	return m, nil
+}
+
+func reconnect() tea.Cmd {
+	return tea.Tick(reconnectDelay, func(time.Time) tea.Msg { return reconnectMsg{} })
+}
+
+func (m model) key(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c", "q":
+		return m, tea.Quit
+	case "tab":
+		m.view = (m.view + 1) % view(len(viewNames))
+		return m, nil
+	case "shift+tab":
+		m.view = (m.view + view(len(viewNames)) - 1) % view(len(viewNames))
+		return m, nil
+	case "1", "2", "3":
+		m.view = view(msg.String()[0] - '1')
+		return m, nil
+	}
+
+	switch m.view {
+	case viewItems:
+		return m.itemsKey(msg)
+	case viewHistory:
+		switch msg.String() {
+		case "up", "k":
+			m.historyCursor = max(m.historyCursor-1, 0)
+		case "down", "j":
+			m.historyCursor = min(m.historyCursor+1, max(len(m.history)-1, 0))
+		case "r":
+			return m, m.loadHistory()
+		}
+	case viewLog:
+		switch msg.String() {
+		case "up", "k":
+			m.logScroll = min(m.logScroll+1, max(len(m.logs)-1, 0))
+		case "down", "j":
+			m.logScroll = max(m.logScroll-1, 0)
+		case "end", "G":
+			m.logScroll = 0
+		}
+	}
+	return m, nil
+}
+
+func (m model) itemsKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "up", "k":
+		m.cursor = max(m.cursor-1, 0)
+	case "down", "j":
+		m.cursor = min(m.cursor+1, max(len(m.items)-1, 0))
+	case "pgup":
+		m.cursor = max(m.cursor-m.bodyHeight(), 0)
+	case "pgdown":
+		m.cursor = min(m.cursor+m.bodyHeight(), max(len(m.items)-1, 0))
+	case "left", "h":
+		if len(m.feeds) > 0 {
+			cmd := m.showFeed((m.feed + len(m.feeds) - 1) % len(m.feeds))
+			return m, cmd
+		}
+	case "right", "l":
+		if len(m.feeds) > 0 {
+			cmd := m.showFeed((m.feed + 1) % len(m.feeds))
+			return m, cmd
+		}
+	case " ":
+		if m.cursor < len(m.items) {
+			m.selected[m.cursor] = !m.selected[m.cursor]
+			m.cursor = min(m.cursor+1, len(m.items)-1)
+		}
+	case "r":
+		if len(m.feeds) > 0 {
+			cmd := m.showFeed(m.feed)
+			return m, cmd
+		}
+	case "enter":
+		var items []FeedItem
+		for i, item := range m.items {
+			if m.selected[i] {
+				items = append(items, item.FeedItem)
+			}
+		}
+		// Without a selection, the item under the cursor
+		if len(items) == 0 && m.cursor < len(m.items) {
+			items = append(items, m.items[m.cursor].FeedItem)
+		}
+		if len(items) == 0 {
+			return m, nil
+		}
+		m.status, m.failed = fmt.Sprintf("Grabbing %d item(s)...", len(items)), false
+		return m, m.grab(m.feedName(), items)
+	}
+	return m, nil
+}
+
+// showFeed switches to the i-th feed and fetches its items
+func (m *model) showFeed(i int) tea.Cmd {
+	if i != m.feed {
+		m.cursor = 0
+	}
+	m.feed = i
+	m.items = nil
+	m.selected = make(map[int]bool)
+	m.loading = true
+	m.status, m.failed = fmt.Sprintf("Fetching %s...", m.feedName()), false
+	return m.loadItems(m.feedName())
+}
+
+func (m model) feedName() string {
+	if m.feed < len(m.feeds) {
+		return m.feeds[m.feed].Name
+	}
+	return ""
+}
+
+func (m model) fail(err error) model {
+	m.status, m.failed = err.Error(), true
+	return m
+}
+
+func (m *model) addLog(e Event) {
+	m.logs = append(m.logs, e)
+	if len(m.logs) > logLimit {
+		m.logs = m.logs[len(m.logs)-logLimit:]
+	}
+	// Keep what's on screen in place while scrolled back, as far as the
+	// oldest event still kept
+	if m.logScroll > 0 {
+		m.logScroll = min(m.logScroll+1, len(m.logs)-1)
+	}
+}
+
+// bodyHeight is the number of lines between the header and the footer
+func (m model) bodyHeight() int {
+	return max(m.height-4, 1)
+}
+
+func (m model) View() string {
+	if m.width == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(m.header() + "\n")
+
+	var body []string
+	switch m.view {
+	case viewItems:
+		body = m.itemsView()
+	case viewHistory:
+		body = m.historyView()
+	case viewLog:
+		body = m.logView()
+	}
+	for i := 0; i < m.bodyHeight()+1; i++ {
+		if i < len(body) {
+			b.WriteString(body[i])
+		}
+		b.WriteString("\n")
+	}
+
+	status := truncate(m.status, m.width)
+	if m.failed {
+		status = errorStyle.Render(status)
+	}
+	b.WriteString(status + "\n")
+	b.WriteString(dimStyle.Render(truncate(m.help(), m.width)))
+	return b.String()
+}
+
+func (m model) header() string {
+	tabs := []string{titleStyle.Render("⚡️ torrent-rss")}
+	for i, name := range viewNames {
+		label := fmt.Sprintf("%d %s", i+1, name)
+		if view(i) == m.view {
+			tabs = append(tabs, activeTabStyle.Render(label))
+		} else {
+			tabs = append(tabs, tabStyle.Render(label))
+		}
+	}
+	return strings.Join(tabs, "  ")
+}
+
+func (m model) help() string {
+	switch m.view {
+	case viewItems:
+		return "←/→ feed  ↑/↓ move  space select  enter grab  r refresh  tab view  q quit"
+	case viewHistory:
+		return "↑/↓ move  r refresh  tab view  q quit"
+	}
+	return "↑/↓ scroll  end follow  tab view  q quit"
+}
+
+// itemsView lists the feed's items, marked ● when the feed would grab
+// them, ✓ when they were downloaded and · when they're filtered
+func (m model) itemsView() []string {
+	title := "No feeds"
+	if len(m.feeds) > 0 {
+		title = fmt.Sprintf("◀ %s (%s, %d of %d) ▶", m.feedName(), m.feeds[m.feed].Tracker, m.feed+1, len(m.feeds))
+	}
+	lines := []string{titleStyle.Render(truncate(title, m.width))}
+	if m.loading {
+		return lines
+	}
+
+	start, end := window(len(m.items), m.cursor, m.bodyHeight())
+	for i := start; i < end; i++ {
+		item := m.items[i]
+		check := "[ ]"
+		if m.selected[i] {
+			check = "[x]"
+		}
+		mark, style, note := "●", matchStyle, ""
+		switch {
+		case item.Downloaded:
+			mark, style, note = "✓", downloadedStyle, "downloaded"
+		case item.Filtered != "":
+			mark, style, note = "·", dimStyle, item.Filtered
+		}
+		details := []string{}
+		if item.Size > 0 {
+			details = append(details, bytesize.Format(item.Size))
+		}
+		if item.ContentType != "" {
+			details = append(details, item.ContentType)
+		}
+		if item.Freeleech {
+			details = append(details, "freeleech")
+		}
+		if note != "" {
+			details = append(details, note)
+		}
+		line := fmt.Sprintf("%s %s %s  %s", check, mark, formatTime(item.PubDate), item.Title)
+		if len(details) > 0 {
+			line += "  (" + strings.Join(details, ", ") + ")"
+		}
+		lines = append(lines, m.row(line, i == m.cursor, style))
+	}
+	return lines
+}
+
+func (m model) historyView() []string {
+	lines := []string{titleStyle.Render(fmt.Sprintf("Latest %d downloads", len(m.history)))}
+	start, end := window(len(m.history), m.historyCursor, m.bodyHeight())
+	for i := start; i < end; i++ {
+		entry := m.history[i]
+		line := fmt.Sprintf("%s  %-12s %s", formatTime(entry.DownloadedAt), entry.Feed, entry.Title)
+		if entry.Media != nil {
+			line += "  (" + entry.Media.String() + ")"
+		}
+		lines = append(lines, m.row(line, i == m.historyCursor, downloadedStyle))
+	}
+	return lines
+}
+
+// logView shows the newest events that fit, or older ones when scrolled back
+func (m model) logView() []string {
+	title := "Daemon events"
+	if m.stream == nil {
+		title += " (not connected)"
+	}
+	lines := []string{titleStyle.Render(title)}
+	end := len(m.logs) - m.logScroll
+	for _, e := range m.logs[max(end-m.bodyHeight(), 0):end] {
+		line := e.Time.Format(time.TimeOnly) + " " + e.Event
+		if e.Feed != "" {
+			line += " [" + e.Feed + "]"
+		}
+		if e.Item != nil {
+			line += " " + e.Item.Title
+		}
+		if e.Reason != "" {
+			line += " (" + e.Reason + ")"
+		}
+		if e.Error != "" {
+			line += ": " + e.Error
+		}
+		lines = append(lines, eventStyle(e.Event).Render(truncate(line, m.width)))
+	}
+	return lines
+}
+
+func eventStyle(event string) lipgloss.Style {
+	switch event {
+	case "downloaded", "upgraded", "completed", "resumed", "connected":
+		return matchStyle
+	case "failed", "disconnected":
+		return errorStyle
+	case "match", "pending", "paused", "stale":
+		return warnStyle
+	}
+	return dimStyle
+}
+
+// row renders a line of a list, highlighted under the cursor
+func (m model) row(line string, current bool, style lipgloss.Style) string {
+	if current {
+		return cursorStyle.Render(truncate("› "+line, m.width))
+	}
+	return style.Render(truncate("  "+line, m.width))
+}
+
+// window returns the rows of a list of n to show in height lines so the
+// cursor is in view
+func window(n, cursor, height int) (start, end int) {
+	if cursor >= height {
+		start = cursor - height + 1
+	}
+	return start, min(start+height, n)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return strings.Repeat(" ", 11)
+	}
+	return t.Local().Format("01-02 15:04")
+}
+
+// truncate cuts s to width columns, ending it with … when it's longer
+func truncate(s string, width int) string {
+	if lipgloss.Width(s) <= width {
+		return s
+	}
+	used := 0
+	for i, r := range s {
+		w := lipgloss.Width(string(r))
+		if used+w > width-1 {
+			return s[:i] + "…"
+		}
+		used += w
+	}
+	return s
+}
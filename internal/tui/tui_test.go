@@ -0,0 +1,25 @@
+package tui
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLogScrollStaysInRange(t *testing.T) {
+	m := newModel(context.Background(), nil)
+	m.height = 20
+	for range logLimit {
+		m.addLog(Event{Event: "downloaded"})
+	}
+	// Scrolled back to the oldest event, more keep coming
+	m.logScroll = len(m.logs) - 1
+	for range 2 {
+		m.addLog(Event{Event: "downloaded"})
+	}
+	if m.logScroll != len(m.logs)-1 {
+		t.Errorf("logScroll = %d, want %d", m.logScroll, len(m.logs)-1)
+	}
+	if lines := m.logView(); len(lines) < 2 {
+		t.Errorf("logView shows %d lines", len(lines))
+	}
+}
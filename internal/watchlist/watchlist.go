@@ -0,0 +1,284 @@
+package watchlist
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"torrent-rss/internal/episode"
+	"torrent-rss/internal/metadata"
+	"torrent-rss/internal/release"
+)
+
+// YearTolerance is how far apart the year on the list and the year in a
+// release name may be, since sites disagree about festival vs release years
+const YearTolerance = 1
+
+// Entry is a show or movie on the watch-list
+type Entry struct {
+	Title   string    `json:"title"`
+	Year    int       `json:"year,omitempty"` // Zero matches any year
+	AddedAt time.Time `json:"added_at"`
+	// Media is what a metadata provider found for the title when it was
+	// added, so releases can be matched by ID instead of by name
+	Media *metadata.Info `json:"media,omitempty"`
+}
+
+func (e Entry) String() string {
+	if e.Year == 0 {
+		return e.Title
+	}
+	return fmt.Sprintf("%s (%d)", e.Title, e.Year)
+}
+
+// matchesTitle reports whether a normalized title and year are the entry's
+func (e Entry) matchesTitle(title string, year int) bool {
+	if e.Year != 0 && year != 0 && abs(e.Year-year) > YearTolerance {
+		return false
+	}
+	return similar(normalize(e.Title), title)
+}
+
+// List is the watch-list, kept in a JSON file so it can be edited from the
+// command line while the daemon is running. Changes to the file are picked
+// up on the next match.
+type List struct {
+	path string
+
+	mu      sync.Mutex
+	entries []Entry
+	modTime time.Time
+}
+
+var titleYearPattern = regexp.MustCompile(`^(.*?)\s*\(?\b((?:19|20)\d{2})\)?$`)
+
+// Open loads the watch-list at path. A missing file is an empty list.
+func Open(path string) (*List, error) {
+	l := &List{path: path}
+	if err := l.load(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *List) load() error {
+	info, err := os.Stat(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		l.entries, l.modTime = nil, time.Time{}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read watch-list: %w", err)
+	}
+	if info.ModTime().Equal(l.modTime) {
+		return nil
+	}
+
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		return fmt.Errorf("failed to read watch-list: %w", err)
+	}
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("failed to parse watch-list %s: %w", l.path, err)
+	}
+	l.entries, l.modTime = entries, info.ModTime()
+	return nil
+}
+
+func (l *List) save() error {
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	data, err := json.MarshalIndent(l.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write watch-list: %w", err)
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write watch-list: %w", err)
+	}
+	if info, err := os.Stat(l.path); err == nil {
+		l.modTime = info.ModTime()
+	}
+	return nil
+}
+
+// ParseTitle splits "Dune (2021)" or "Dune 2021" into title and year
+func ParseTitle(value string) (string, int) {
+	value = strings.TrimSpace(value)
+	if m := titleYearPattern.FindStringSubmatch(value); m != nil && m[1] != "" {
+		year, _ := strconv.Atoi(m[2])
+		return m[1], year
+	}
+	return value, 0
+}
+
+// Add puts a title on the list, failing if it's already there. media is
+// what a metadata provider knows about it, or nil.
+func (l *List) Add(title string, year int, media *metadata.Info) (Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.load(); err != nil {
+		return Entry{}, err
+	}
+	entry := Entry{Title: strings.TrimSpace(title), Year: year, AddedAt: time.Now(), Media: media}
+	if normalize(entry.Title) == "" {
+		return Entry{}, fmt.Errorf("title %q has no letters or digits", title)
+	}
+	for _, existing := range l.entries {
+		if (normalize(existing.Title) == normalize(entry.Title) && existing.Year == entry.Year) || metadata.Same(existing.Media, media) {
+			return Entry{}, fmt.Errorf("%s is already on the watch-list", existing)
+		}
+	}
+	l.entries = append(l.entries, entry)
+	return entry, l.save()
+}
+
+// Remove takes every entry with the title off the list and returns them. A
+// zero year removes the title whatever its year.
+func (l *List) Remove(title string, year int) ([]Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.load(); err != nil {
+		return nil, err
+	}
+	var kept, removed []Entry
+	for _, entry := range l.entries {
+		if normalize(entry.Title) == normalize(title) && (year == 0 || entry.Year == year) {
+			removed = append(removed, entry)
+		} else {
+			kept = append(kept, entry)
+		}
+	}
+	if len(removed) == 0 {
+		return nil, nil
+	}
+	l.entries = kept
+	return removed, l.save()
+}
+
+// Entries returns the list sorted by title
+func (l *List) Entries() ([]Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.load(); err != nil {
+		return nil, err
+	}
+	entries := append([]Entry(nil), l.entries...)
+	sort.Slice(entries, func(i, j int) bool {
+		return normalize(entries[i].Title) < normalize(entries[j].Title)
+	})
+	return entries, nil
+}
+
+// Match finds the entry a release name is for, comparing normalized titles
+// with a little slack for typos and alternate spellings, and years within
+// YearTolerance when both sides have one
+func (l *List) Match(name string) (*Entry, error) {
+	return l.MatchMedia(name, nil)
+}
+
+// MatchMedia is Match for a release a metadata provider looked up. Entries
+// with metadata of their own match by ID, which tells remakes and shows of
+// the same name apart; the canonical title is tried like the release's own.
+func (l *List) MatchMedia(name string, media *metadata.Info) (*Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.load(); err != nil {
+		return nil, err
+	}
+
+	if media != nil {
+		for i, entry := range l.entries {
+			if metadata.Same(entry.Media, media) {
+				return &l.entries[i], nil
+			}
+		}
+	}
+
+	r := release.Parse(name)
+	title := normalize(r.Title)
+	for i, entry := range l.entries {
+		// The IDs already said it's something else
+		if media != nil && entry.Media != nil {
+			continue
+		}
+		if title != "" && entry.matchesTitle(title, r.Year) {
+			return &l.entries[i], nil
+		}
+		if media != nil && entry.matchesTitle(normalize(media.Title), media.Year) {
+			return &l.entries[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// normalize makes titles comparable: lowercase, no punctuation, and no
+// leading article or "and", so "The Office" matches "Office" and
+// "Law & Order" matches "Law and Order"
+func normalize(title string) string {
+	words := strings.Fields(episode.NormalizeShow(title))
+	kept := words[:0]
+	for i, word := range words {
+		if word == "and" || (i == 0 && (word == "the" || word == "a")) {
+			continue
+		}
+		kept = append(kept, word)
+	}
+	return strings.Join(kept, " ")
+}
+
+// similar allows one edit per 10 characters, so short titles must match exactly
+func similar(a, b string) bool {
+	if a == b {
+		return true
+	}
+	allowed := min(len(a), len(b)) / 10
+	return allowed > 0 && distance(a, b) <= allowed
+}
+
+// distance is the Levenshtein edit distance between two strings
+func distance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}